@@ -10,6 +10,7 @@ import (
 	"xetor.id/backend/internal/domain/partner"
 	"xetor.id/backend/internal/domain/user"
 	"xetor.id/backend/internal/notification"
+	"xetor.id/backend/internal/offline_qr"
 	"xetor.id/backend/internal/repository"
 	"xetor.id/backend/internal/server"
 	"xetor.id/backend/internal/temporary_token"
@@ -21,14 +22,29 @@ func main() {
 	db := database.DB
 
 	// Inisialisasi TokenStore untuk token sementara
-	tokenStore := temporary_token.NewTokenStore()
+	var tokenStore temporary_token.TokenStore
+	switch config.GetTokenStoreDriver() {
+	case "postgres":
+		tokenStore = temporary_token.NewPostgresTokenStore(db)
+	case "memory":
+		tokenStore = temporary_token.NewMemoryTokenStore()
+	default:
+		log.Fatalf("Unknown TOKEN_STORE driver: %s", config.GetTokenStoreDriver())
+	}
+	log.Printf("Using %s token store", config.GetTokenStoreDriver())
+
+	// Inisialisasi Signer untuk kode QR deposit offline
+	offlineQrSigner := offline_qr.NewSigner(db, config.GetOfflineQRKeyEncryptionKey())
+	if err := offlineQrSigner.SealLegacyKeys(); err != nil {
+		log.Fatalf("Failed to encrypt offline QR signing keys: %v", err)
+	}
 
 	// Inisialisasi NotificationService
 	notifService := notification.NewNotificationService()
 
 	// Komponen Admin
 	adminRepo := repository.NewAdminRepository(db)
-	adminService := admin.NewAdminService(adminRepo)
+	adminService := admin.NewAdminService(adminRepo, offlineQrSigner)
 	adminHandler := admin.NewAdminHandler(adminService)
 
 	// Komponen User
@@ -38,15 +54,15 @@ func main() {
 	midtransService := midtrans.NewMidtransService(userRepo, notifService)
 	midtransHandler := midtrans.NewMidtransHandler(midtransService)
 	
-	// UserService sekarang butuh MidtransService dan AdminRepository
-	userService := user.NewService(userRepo, adminRepo, tokenStore, notifService, midtransService)
-	userHandler := user.NewHandler(userService)
-
-	// Komponen Partner
+	// Komponen Partner (dibuat sebelum UserService karena penerimaan draft deposit ada di PartnerService)
 	partnerRepo := repository.NewPartnerRepository(db)
-	partnerService := partner.NewPartnerService(partnerRepo, userRepo, tokenStore, adminRepo, notifService)
+	partnerService := partner.NewPartnerService(partnerRepo, userRepo, tokenStore, offlineQrSigner, adminRepo, notifService)
 	partnerHandler := partner.NewPartnerHandler(partnerService)
 
+	// UserService sekarang butuh MidtransService, AdminRepository, dan PartnerService
+	userService := user.NewService(userRepo, adminRepo, tokenStore, offlineQrSigner, notifService, midtransService, partnerService)
+	userHandler := user.NewHandler(userService)
+
 	router := server.NewRouter(userHandler, adminHandler, midtransHandler, partnerHandler)
 	// Gunakan port 8081 untuk Xetor agar tidak bentrok dengan web portofolio di 8080
 	err := router.Run(":8081")
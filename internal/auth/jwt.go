@@ -11,7 +11,8 @@ import (
 )
 
 type JwtCustomClaims struct {
-	Role string `json:"role"`
+	Role    string `json:"role"`
+	StaffID int    `json:"staff_id,omitempty"` // ID staf partner yang login (Subject tetap ID partner)
 	jwt.RegisteredClaims
 }
 
@@ -20,8 +21,8 @@ func GenerateToken(entityID int, role string) (string, error) {
 	expirationTime := time.Now().Add(24 * time.Hour)
 
 	claims := &JwtCustomClaims{ // Gunakan struct custom
-		role, // Isi role
-		jwt.RegisteredClaims{
+		Role: role, // Isi role
+		RegisteredClaims: jwt.RegisteredClaims{
 			Subject:   strconv.Itoa(entityID), // ID User atau Partner
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 		},
@@ -34,4 +35,22 @@ func GenerateToken(entityID int, role string) (string, error) {
 	}
 
 	return tokenString, nil
-}
\ No newline at end of file
+}
+
+// GeneratePartnerStaffToken membuat token partner yang membawa ID partner (Subject) sekaligus ID staf
+func GeneratePartnerStaffToken(partnerID, staffID int) (string, error) {
+	jwtSecretKey := config.GetJWTSecret()
+	expirationTime := time.Now().Add(24 * time.Hour)
+
+	claims := &JwtCustomClaims{
+		Role:    "partner",
+		StaffID: staffID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(partnerID),
+			ExpiresAt: jwt.NewNumericDate(expirationTime),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(jwtSecretKey)
+}
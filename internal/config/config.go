@@ -88,3 +88,25 @@ func GetGoogleClientID() string {
 	}
 	return clientID
 }
+
+// GetOfflineQRKeyEncryptionKey mengambil secret OFFLINE_QR_KEY_ENCRYPTION_KEY untuk mengenkripsi
+// private key penanda tangan kode QR offline di database. Jangan diganti setelah kunci dibuat,
+// karena kunci lama tidak bisa didekripsi lagi (rotasi kunci dari admin tetap bisa dipakai).
+func GetOfflineQRKeyEncryptionKey() []byte {
+	key := os.Getenv("OFFLINE_QR_KEY_ENCRYPTION_KEY")
+	if key == "" {
+		log.Fatal("OFFLINE_QR_KEY_ENCRYPTION_KEY must be set in .env file")
+	}
+	return []byte(key)
+}
+
+// GetTokenStoreDriver menentukan penyimpanan token QR deposit: "memory" atau "postgres".
+// Gunakan TOKEN_STORE=postgres jika API dijalankan lebih dari satu replika.
+// Default ke "memory" jika tidak di-set.
+func GetTokenStoreDriver() string {
+	driver := strings.ToLower(os.Getenv("TOKEN_STORE"))
+	if driver == "" {
+		driver = "memory"
+	}
+	return driver
+}
@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"xetor.id/backend/internal/config"
@@ -630,4 +631,136 @@ func (h *AdminHandler) DeleteXetorPartner(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus Xetor partner"}); return
 	}
 	c.JSON(http.StatusOK, gin.H{"message": "Xetor partner berhasil dihapus"})
-}
\ No newline at end of file
+}
+// --- Recycler Handlers ---
+
+func (h *AdminHandler) CreateRecycler(c *gin.Context) {
+	var req CreateRecyclerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return
+	}
+	recycler, err := h.service.CreateRecycler(req)
+	if err != nil {
+		if strings.Contains(err.Error(), "tidak valid") || strings.Contains(err.Error(), "wajib diisi") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan recycler"}); return
+	}
+	c.JSON(http.StatusCreated, recycler)
+}
+
+// GetAllRecyclers - Daftar recycler, opsional ?status=Active|Inactive
+func (h *AdminHandler) GetAllRecyclers(c *gin.Context) {
+	recyclers, err := h.service.GetAllRecyclers(c.Query("status"))
+	if err != nil {
+		if strings.Contains(err.Error(), "tidak valid") { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil recycler"}); return
+	}
+	c.JSON(http.StatusOK, recyclers)
+}
+
+func (h *AdminHandler) GetRecyclerByID(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id")); if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"}); return
+	}
+	recycler, err := h.service.GetRecyclerByID(id); if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil recycler"}); return
+	}
+	if recycler == nil { c.JSON(http.StatusNotFound, gin.H{"error": "Recycler tidak ditemukan"}); return }
+	c.JSON(http.StatusOK, recycler)
+}
+
+func (h *AdminHandler) UpdateRecycler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id")); if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"}); return
+	}
+	var req UpdateRecyclerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return
+	}
+	if req.Name == "" && req.ContactName == "" && req.Phone == "" && req.Email == "" && req.Address == "" && req.City == "" && req.Status == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tidak ada data untuk diupdate"}); return
+	}
+
+	err = h.service.UpdateRecycler(id, req); if err != nil {
+		if err == sql.ErrNoRows { c.JSON(http.StatusNotFound, gin.H{"error": "Recycler tidak ditemukan"}); return }
+		if strings.Contains(err.Error(), "tidak valid") { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengupdate recycler"}); return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Recycler berhasil diupdate"})
+}
+
+func (h *AdminHandler) DeleteRecycler(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id")); if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"}); return
+	}
+	err = h.service.DeleteRecycler(id); if err != nil {
+		if err == sql.ErrNoRows { c.JSON(http.StatusNotFound, gin.H{"error": "Recycler tidak ditemukan"}); return }
+		if strings.Contains(err.Error(), "sudah memiliki") { c.JSON(http.StatusConflict, gin.H{"error": err.Error()}); return }
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus recycler"}); return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Recycler berhasil dihapus"})
+}
+
+// --- National Holiday Handlers ---
+
+// GetNationalHolidays - Daftar libur nasional, opsional ?year=2026
+func (h *AdminHandler) GetNationalHolidays(c *gin.Context) {
+	year := 0
+	if yearStr := c.Query("year"); yearStr != "" {
+		var err error
+		year, err = strconv.Atoi(yearStr); if err != nil || year < 2000 || year > 2100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Tahun tidak valid"}); return
+		}
+	}
+	holidays, err := h.service.GetNationalHolidays(year)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil libur nasional"}); return
+	}
+	c.JSON(http.StatusOK, holidays)
+}
+
+// ImportNationalHolidays - Import kalender libur nasional (tanggal yang sudah ada diperbarui)
+func (h *AdminHandler) ImportNationalHolidays(c *gin.Context) {
+	var req ImportNationalHolidaysRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return
+	}
+	count, err := h.service.ImportNationalHolidays(req)
+	if err != nil {
+		if strings.Contains(err.Error(), "tidak valid") || strings.Contains(err.Error(), "lebih dari sekali") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengimport libur nasional"}); return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Libur nasional berhasil diimport", "count": count})
+}
+
+// DeleteNationalHoliday - Hapus satu tanggal libur nasional
+func (h *AdminHandler) DeleteNationalHoliday(c *gin.Context) {
+	err := h.service.DeleteNationalHoliday(c.Param("date")); if err != nil {
+		if err == sql.ErrNoRows { c.JSON(http.StatusNotFound, gin.H{"error": "Libur nasional tidak ditemukan"}); return }
+		if strings.Contains(err.Error(), "tidak valid") { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menghapus libur nasional"}); return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Libur nasional berhasil dihapus"})
+}
+
+// --- Offline Deposit QR Key Handlers ---
+
+func (h *AdminHandler) GetOfflineQrPublicKeys(c *gin.Context) {
+	keys, err := h.service.GetOfflineQrPublicKeys()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil kunci kode QR offline"}); return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// RotateOfflineQrKey - Rotasi kunci untuk mencabut semua kode QR offline yang beredar
+func (h *AdminHandler) RotateOfflineQrKey(c *gin.Context) {
+	key, err := h.service.RotateOfflineQrKey()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal merotasi kunci kode QR offline"}); return
+	}
+	c.JSON(http.StatusCreated, key)
+}
@@ -187,4 +187,64 @@ type CreateXetorPartnerRequest struct {
 // UpdateXetorPartnerRequest - Admin hanya bisa update status
 type UpdateXetorPartnerRequest struct {
 	Status string `json:"status" binding:"required"` // Status wajib diisi saat update
-}
\ No newline at end of file
+}
+// NationalHoliday libur nasional; partner yang mengikuti libur nasional otomatis tutup pada tanggal ini
+type NationalHoliday struct {
+	Date      string    `json:"date"` // "YYYY-MM-DD"
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// NationalHolidayInput satu baris libur pada import kalender
+type NationalHolidayInput struct {
+	Date string `json:"date" binding:"required"` // "YYYY-MM-DD"
+	Name string `json:"name" binding:"required"`
+}
+
+// ImportNationalHolidaysRequest import kalender libur nasional; tanggal yang sudah ada akan diperbarui namanya
+type ImportNationalHolidaysRequest struct {
+	Holidays []NationalHolidayInput `json:"holidays" binding:"required,min=1,dive"`
+}
+
+// Status recycler
+const (
+	RecyclerStatusActive   = "Active"
+	RecyclerStatusInactive = "Inactive"
+)
+
+// Recycler merepresentasikan data dari tabel recyclers (offtaker yang membeli sampah terpilah dari partner)
+type Recycler struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	ContactName string    `json:"contact_name"`
+	Phone       string    `json:"phone"`
+	Email       string    `json:"email"`
+	Address     string    `json:"address"`
+	City        string    `json:"city"`
+	Status      string    `json:"status"` // "Active", "Inactive"
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// CreateRecyclerRequest data untuk membuat Recycler baru
+type CreateRecyclerRequest struct {
+	Name        string `json:"name" binding:"required"`
+	ContactName string `json:"contact_name"`
+	Phone       string `json:"phone"`
+	Email       string `json:"email" binding:"omitempty,email"`
+	Address     string `json:"address"`
+	City        string `json:"city"`
+	Status      string `json:"status"` // Default 'Active'
+}
+
+// UpdateRecyclerRequest data untuk mengupdate Recycler; field kosong tidak diubah
+type UpdateRecyclerRequest struct {
+	Name        string `json:"name"`
+	ContactName string `json:"contact_name"`
+	Phone       string `json:"phone"`
+	Email       string `json:"email" binding:"omitempty,email"`
+	Address     string `json:"address"`
+	City        string `json:"city"`
+	Status      string `json:"status"`
+}
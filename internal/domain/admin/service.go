@@ -3,6 +3,12 @@ package admin
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"xetor.id/backend/internal/offline_qr"
+	"xetor.id/backend/internal/schedule"
 )
 
 // Definisikan interface agar service tidak bergantung langsung pada implementasi repo
@@ -57,14 +63,27 @@ type AdminRepository interface {
 	GetXetorPartnerByID(id int) (*XetorPartner, error)
 	UpdateXetorPartnerStatus(id int, status string) error // Fungsi khusus update status
 	DeleteXetorPartner(id int) error
+
+	// Recycler methods
+	CreateRecycler(rc *Recycler) error
+	GetAllRecyclers(status string) ([]Recycler, error)
+	GetRecyclerByID(id int) (*Recycler, error)
+	UpdateRecycler(id int, req *UpdateRecyclerRequest) error
+	DeleteRecycler(id int) error
+
+	// NationalHoliday methods
+	GetNationalHolidays(year int) ([]NationalHoliday, error)
+	UpsertNationalHolidays(holidays []NationalHolidayInput) error
+	DeleteNationalHoliday(date string) error
 }
 
 type AdminService struct {
-	repo AdminRepository
+	repo      AdminRepository
+	offlineQr *offline_qr.Signer
 }
 
-func NewAdminService(repo AdminRepository) *AdminService {
-	return &AdminService{repo: repo}
+func NewAdminService(repo AdminRepository, offlineQr *offline_qr.Signer) *AdminService {
+	return &AdminService{repo: repo, offlineQr: offlineQr}
 }
 
 // --- Waste Type Service Methods ---
@@ -297,4 +316,102 @@ func (s *AdminService) UpdateXetorPartnerStatus(id int, req UpdateXetorPartnerRe
 
 func (s *AdminService) DeleteXetorPartner(id int) error {
 	return s.repo.DeleteXetorPartner(id)
-}
\ No newline at end of file
+}
+// --- Recycler Service Methods ---
+
+func (s *AdminService) CreateRecycler(req CreateRecyclerRequest) (*Recycler, error) {
+	if req.Status != "" && req.Status != RecyclerStatusActive && req.Status != RecyclerStatusInactive {
+		return nil, errors.New("status recycler tidak valid (Active, Inactive)")
+	}
+	rc := &Recycler{
+		Name:        strings.TrimSpace(req.Name),
+		ContactName: strings.TrimSpace(req.ContactName),
+		Phone:       strings.TrimSpace(req.Phone),
+		Email:       strings.TrimSpace(req.Email),
+		Address:     strings.TrimSpace(req.Address),
+		City:        strings.TrimSpace(req.City),
+		Status:      req.Status,
+	}
+	if rc.Name == "" {
+		return nil, errors.New("nama recycler wajib diisi")
+	}
+	if err := s.repo.CreateRecycler(rc); err != nil {
+		return nil, err
+	}
+	return rc, nil
+}
+
+// GetAllRecyclers - Daftar recycler, status kosong berarti semua
+func (s *AdminService) GetAllRecyclers(status string) ([]Recycler, error) {
+	if status != "" && status != RecyclerStatusActive && status != RecyclerStatusInactive {
+		return nil, errors.New("status recycler tidak valid (Active, Inactive)")
+	}
+	return s.repo.GetAllRecyclers(status)
+}
+
+func (s *AdminService) GetRecyclerByID(id int) (*Recycler, error) {
+	return s.repo.GetRecyclerByID(id)
+}
+
+func (s *AdminService) UpdateRecycler(id int, req UpdateRecyclerRequest) error {
+	if req.Status != "" && req.Status != RecyclerStatusActive && req.Status != RecyclerStatusInactive {
+		return errors.New("status recycler tidak valid (Active, Inactive)")
+	}
+	return s.repo.UpdateRecycler(id, &req)
+}
+
+// DeleteRecycler - Recycler yang sudah punya transaksi penjualan tidak bisa dihapus, hanya dinonaktifkan
+func (s *AdminService) DeleteRecycler(id int) error {
+	return s.repo.DeleteRecycler(id)
+}
+
+// --- National Holiday Service Methods ---
+
+// GetNationalHolidays - Daftar libur nasional, year = 0 berarti semua tahun
+func (s *AdminService) GetNationalHolidays(year int) ([]NationalHoliday, error) {
+	return s.repo.GetNationalHolidays(year)
+}
+
+// ImportNationalHolidays - Import kalender libur nasional (upsert per tanggal)
+func (s *AdminService) ImportNationalHolidays(req ImportNationalHolidaysRequest) (int, error) {
+	seen := make(map[string]bool)
+	for i := range req.Holidays {
+		h := &req.Holidays[i]
+		h.Date = strings.TrimSpace(h.Date)
+		h.Name = strings.TrimSpace(h.Name)
+		if _, err := time.Parse(schedule.DateLayout, h.Date); err != nil {
+			return 0, fmt.Errorf("tanggal libur tidak valid: %s (YYYY-MM-DD)", h.Date)
+		}
+		if h.Name == "" || len(h.Name) > 255 {
+			return 0, fmt.Errorf("nama libur tanggal %s tidak valid", h.Date)
+		}
+		if seen[h.Date] {
+			return 0, fmt.Errorf("tanggal libur %s muncul lebih dari sekali", h.Date)
+		}
+		seen[h.Date] = true
+	}
+	if err := s.repo.UpsertNationalHolidays(req.Holidays); err != nil {
+		return 0, err
+	}
+	return len(req.Holidays), nil
+}
+
+// DeleteNationalHoliday - Hapus satu tanggal libur nasional
+func (s *AdminService) DeleteNationalHoliday(date string) error {
+	if _, err := time.Parse(schedule.DateLayout, date); err != nil {
+		return errors.New("tanggal libur tidak valid (YYYY-MM-DD)")
+	}
+	return s.repo.DeleteNationalHoliday(date)
+}
+
+// --- Offline Deposit QR Key Service Methods ---
+
+// GetOfflineQrPublicKeys - Daftar public key aktif untuk kode QR offline
+func (s *AdminService) GetOfflineQrPublicKeys() ([]offline_qr.PublicKey, error) {
+	return s.offlineQr.PublicKeys()
+}
+
+// RotateOfflineQrKey - Membuat kunci baru; semua kode QR offline lama otomatis dicabut
+func (s *AdminService) RotateOfflineQrKey() (*offline_qr.PublicKey, error) {
+	return s.offlineQr.RotateKey()
+}
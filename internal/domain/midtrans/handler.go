@@ -4,6 +4,7 @@ package midtrans
 import (
 	"log"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -41,4 +42,30 @@ func (h *MidtransHandler) HandleNotification(c *gin.Context) {
 
 	// Jika semua sukses
 	c.JSON(http.StatusOK, gin.H{"status": "ok"})
-}
\ No newline at end of file
+}
+
+// RefundTopup menangani permintaan admin untuk merefund topup yang sudah berhasil
+func (h *MidtransHandler) RefundTopup(c *gin.Context) {
+	var req RefundTopupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result, err := h.service.RefundTopup(c.Param("id"), req)
+	if err != nil {
+		errMsg := err.Error()
+		if strings.Contains(errMsg, "tidak ditemukan") {
+			c.JSON(http.StatusNotFound, gin.H{"error": errMsg})
+		} else if strings.Contains(errMsg, "tidak valid") || strings.Contains(errMsg, "tidak dapat direfund") || strings.Contains(errMsg, "melebihi") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+		} else if strings.Contains(errMsg, "Midtrans") {
+			c.JSON(http.StatusBadGateway, gin.H{"error": errMsg})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memproses refund topup"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
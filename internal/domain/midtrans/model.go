@@ -26,6 +26,11 @@ type MidtransTransactionNotification struct {
 	ChannelResponseMessage string `json:"channel_response_message,omitempty"`
 	ApprovalCode         string `json:"approval_code,omitempty"` // Kode approval bank (jika ada)
 
+	// -- Fields untuk Refund / Chargeback --
+	// Dikirim Midtrans saat transaction_status "refund", "partial_refund", "chargeback" atau "partial_chargeback".
+	RefundAmount string           `json:"refund_amount,omitempty"` // Total kumulatif yang sudah direfund (string)
+	Refunds      []MidtransRefund `json:"refunds,omitempty"`       // Rincian setiap refund/chargeback
+
 	// -- Fields Spesifik untuk Disbursement (Withdraw) --
 	// Midtrans mungkin punya format notifikasi berbeda untuk disbursement,
 	// perlu dicek di dokumentasi Disbursement API mereka.
@@ -38,6 +43,15 @@ type MidtransTransactionNotification struct {
 	Timestamp        time.Time `json:"timestamp,omitempty"`        // Waktu proses disbursement
 }
 
+// MidtransRefund adalah satu entri refund/chargeback di dalam notifikasi Midtrans
+type MidtransRefund struct {
+	RefundChargebackID int    `json:"refund_chargeback_id"`
+	RefundAmount       string `json:"refund_amount"`
+	CreatedAt          string `json:"created_at"`
+	Reason             string `json:"reason"`
+	RefundKey          string `json:"refund_key"`
+}
+
 // Catatan Penting:
 // Struktur payload webhook Midtrans bisa bervariasi tergantung jenis transaksi
 // (pembayaran vs disbursement). Pastikan untuk memeriksa dokumentasi API Midtrans
@@ -56,4 +70,28 @@ type SnapTransactionRequest struct {
 type SnapTransactionResponse struct {
 	Token       string `json:"token"`        // Snap token untuk frontend
 	RedirectURL string `json:"redirect_url"` // URL redirect (alternatif)
-}
\ No newline at end of file
+}
+
+// TopupRefundInfo adalah ringkasan topup yang dibutuhkan untuk memproses refund
+type TopupRefundInfo struct {
+	TopupID       int
+	UserID        int
+	Amount        float64 // Jumlah topup awal
+	Status        string
+	TotalReversed float64 // Total yang sudah direfund/chargeback sebelumnya
+}
+
+// RefundTopupRequest data dari admin untuk merefund topup
+type RefundTopupRequest struct {
+	Amount float64 `json:"amount" binding:"omitempty,gt=0"` // Opsional, kosong = refund sisa penuh
+	Reason string  `json:"reason" binding:"required"`
+}
+
+// RefundTopupResponse data respons setelah refund topup berhasil diajukan ke Midtrans
+type RefundTopupResponse struct {
+	OrderID       string `json:"order_id"`
+	RefundKey     string `json:"refund_key"`
+	RefundAmount  string `json:"refund_amount"`  // Jumlah refund kali ini
+	TotalRefunded string `json:"total_refunded"` // Total refund kumulatif
+	Status        string `json:"status"`         // Status topup setelah refund
+}
@@ -10,8 +10,10 @@ import (
 	"os"
 	"strconv"
 	"strings" // Untuk memisahkan order_id
+	"time"
 
 	"github.com/midtrans/midtrans-go"
+	"github.com/midtrans/midtrans-go/coreapi"
 	"github.com/midtrans/midtrans-go/snap"
 	"xetor.id/backend/internal/config"
 	"xetor.id/backend/internal/notification"
@@ -21,6 +23,8 @@ import (
 type TransactionRepository interface {
 	UpdateWithdrawStatus(orderID string, newStatus string, transactionID string) error
 	UpdateTopupStatus(orderID string, newStatus string, transactionID string, amount float64, paymentMethodID int) (int, error) // Return userID juga
+	GetTopupRefundInfo(topupID int) (*TopupRefundInfo, error)
+	ReverseTopup(orderID string, reversalType string, totalReversed float64, transactionID string, reason string, source string) (int, float64, error) // Return userID & jumlah yang baru didebit
 }
 
 type MidtransService struct {
@@ -65,6 +69,16 @@ func (s *MidtransService) ProcessNotification(notification MidtransTransactionNo
 
 	log.Printf("Processing notification for Order ID: %s, Status: %s", notification.OrderID, notification.TransactionStatus)
 
+	// Refund & chargeback ditangani terpisah karena membalik saldo yang sudah dikreditkan
+	switch notification.TransactionStatus {
+	case "refund", "partial_refund", "chargeback", "partial_chargeback":
+		if transactionTypePrefix != "TP" {
+			log.Printf("Ignoring %s notification for non-topup Order ID: %s", notification.TransactionStatus, notification.OrderID)
+			return nil
+		}
+		return s.processTopupReversal(notification)
+	}
+
 	var updateErr error
 	finalStatus := ""
 
@@ -130,6 +144,150 @@ func (s *MidtransService) ProcessNotification(notification MidtransTransactionNo
 	return nil // Sukses
 }
 
+// processTopupReversal mendebit saldo user untuk topup yang direfund atau di-chargeback.
+// Midtrans mengirim total kumulatif, sehingga notifikasi duplikat tidak mendebit dua kali.
+func (s *MidtransService) processTopupReversal(notification MidtransTransactionNotification) error {
+	reversalType := "refund"
+	if strings.Contains(notification.TransactionStatus, "chargeback") {
+		reversalType = "chargeback"
+	}
+
+	totalReversed, err := reversalTotalFromNotification(notification)
+	if err != nil {
+		log.Printf("Error parsing reversal amount for Order ID %s: %v", notification.OrderID, err)
+		return errors.New("jumlah refund tidak valid")
+	}
+
+	reason := ""
+	if len(notification.Refunds) > 0 {
+		reason = notification.Refunds[len(notification.Refunds)-1].Reason
+	}
+
+	userID, debited, err := s.repo.ReverseTopup(notification.OrderID, reversalType, totalReversed, notification.TransactionID, reason, "webhook")
+	if err != nil {
+		return err
+	}
+	if debited > 0 && userID > 0 {
+		s.sendReversalNotification(userID, reversalType, debited)
+	}
+	return nil
+}
+
+// reversalTotalFromNotification menentukan total kumulatif refund/chargeback dari payload Midtrans.
+// Urutan: jumlah dari array refunds, lalu refund_amount, lalu gross_amount (refund penuh).
+func reversalTotalFromNotification(notification MidtransTransactionNotification) (float64, error) {
+	if len(notification.Refunds) > 0 {
+		total := 0.0
+		for _, refund := range notification.Refunds {
+			amount, err := strconv.ParseFloat(refund.RefundAmount, 64)
+			if err != nil {
+				return 0, err
+			}
+			total += amount
+		}
+		return total, nil
+	}
+	if notification.RefundAmount != "" {
+		return strconv.ParseFloat(notification.RefundAmount, 64)
+	}
+	return strconv.ParseFloat(notification.GrossAmount, 64)
+}
+
+// sendReversalNotification memberi tahu user bahwa saldonya didebit karena refund/chargeback
+func (s *MidtransService) sendReversalNotification(userID int, reversalType string, amount float64) {
+	go func(uid int, amt float64) {
+		notifTitle := "Top Up Direfund"
+		notifBody := fmt.Sprintf("Top up sebesar Rp %.0f telah direfund. Saldo Anda telah disesuaikan.", amt)
+		notifType := "TOPUP_REFUNDED"
+		if reversalType == "chargeback" {
+			notifTitle = "Top Up Dibatalkan (Chargeback)"
+			notifBody = fmt.Sprintf("Top up sebesar Rp %.0f dibatalkan oleh penerbit kartu. Saldo Anda telah disesuaikan.", amt)
+			notifType = "TOPUP_CHARGEBACK"
+		}
+		errNotif := s.notifService.SendNotification(uid, notifTitle, notifBody, notifType)
+		if errNotif != nil {
+			log.Printf("Gagal mengirim notifikasi %s ke user %d: %v", notifType, uid, errNotif)
+		}
+	}(userID, amount)
+}
+
+// RefundTopup mengajukan refund topup ke Midtrans atas permintaan admin,
+// lalu langsung mendebit saldo user dan mencatat entri reversal.
+func (s *MidtransService) RefundTopup(topupIDStr string, req RefundTopupRequest) (*RefundTopupResponse, error) {
+	topupID, err := strconv.Atoi(topupIDStr)
+	if err != nil {
+		return nil, errors.New("ID topup tidak valid")
+	}
+
+	info, err := s.repo.GetTopupRefundInfo(topupID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil data topup")
+	}
+	if info == nil {
+		return nil, errors.New("topup tidak ditemukan")
+	}
+	if info.Status != "Completed" && info.Status != "Partially Refunded" && info.Status != "Partially Charged Back" {
+		return nil, fmt.Errorf("topup dengan status %s tidak dapat direfund", info.Status)
+	}
+
+	remaining := info.Amount - info.TotalReversed
+	amount := req.Amount
+	if amount == 0 {
+		amount = remaining
+	}
+	if amount > remaining {
+		return nil, fmt.Errorf("jumlah refund melebihi sisa yang dapat direfund (Rp %.2f)", remaining)
+	}
+
+	orderID := fmt.Sprintf("TP-%d", topupID)
+	refundKey := fmt.Sprintf("RF-%d-%d", topupID, time.Now().Unix())
+
+	coreClient := coreapi.Client{}
+	coreClient.New(config.GetMidtransServerKey(), getMidtransEnv())
+	refundResp, midtransErr := coreClient.RefundTransaction(orderID, &coreapi.RefundReq{
+		RefundKey: refundKey,
+		Amount:    int64(amount),
+		Reason:    req.Reason,
+	})
+	if midtransErr != nil {
+		log.Printf("Error requesting Midtrans refund for Order ID %s: %v", orderID, midtransErr)
+		return nil, fmt.Errorf("gagal memproses refund di Midtrans: %s", midtransErr.GetMessage())
+	}
+
+	totalReversed := info.TotalReversed + amount
+	_, debited, err := s.repo.ReverseTopup(orderID, "refund", totalReversed, refundResp.TransactionID, req.Reason, "admin")
+	if err != nil {
+		// Refund di Midtrans sudah berhasil; webhook refund akan mencoba mendebit ulang secara idempoten
+		log.Printf("Midtrans refund succeeded but wallet reversal failed for Order ID %s: %v", orderID, err)
+		return nil, errors.New("refund berhasil di Midtrans tetapi gagal memperbarui saldo")
+	}
+	if debited > 0 {
+		s.sendReversalNotification(info.UserID, "refund", debited)
+	}
+
+	status := "Partially Refunded"
+	if totalReversed >= info.Amount {
+		status = "Refunded"
+	}
+
+	log.Printf("Admin refund processed for Order ID %s: amount %.2f, refund key %s", orderID, amount, refundKey)
+	return &RefundTopupResponse{
+		OrderID:       orderID,
+		RefundKey:     refundKey,
+		RefundAmount:  fmt.Sprintf("%.2f", amount),
+		TotalRefunded: fmt.Sprintf("%.2f", totalReversed),
+		Status:        status,
+	}, nil
+}
+
+// getMidtransEnv menentukan environment Midtrans (sandbox atau production) dari env variable
+func getMidtransEnv() midtrans.EnvironmentType {
+	if os.Getenv("MIDTRANS_ENV") == "production" {
+		return midtrans.Production
+	}
+	return midtrans.Sandbox
+}
+
 // CreateSnapTransaction adalah method adapter untuk interface (menerima interface{}, return interface{})
 func (s *MidtransService) CreateSnapTransaction(req interface{}) (interface{}, error) {
 	// Convert interface{} ke map atau SnapTransactionRequest
@@ -154,11 +312,7 @@ func (s *MidtransService) createSnapTransactionInternal(req SnapTransactionReque
 	serverKey := config.GetMidtransServerKey()
 
 	// Tentukan environment (sandbox atau production)
-	// Untuk sekarang kita pakai sandbox, bisa diubah via env variable nanti
-	env := midtrans.Sandbox
-	if os.Getenv("MIDTRANS_ENV") == "production" {
-		env = midtrans.Production
-	}
+	env := getMidtransEnv()
 
 	snapClient := snap.Client{}
 	snapClient.New(serverKey, env)
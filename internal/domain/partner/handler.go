@@ -1,12 +1,18 @@
 package partner
 
 import (
+	"bytes"
+	"fmt"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"xetor.id/backend/internal/domain/user"
+	"xetor.id/backend/internal/spreadsheet"
 )
 
 type PartnerHandler struct {
@@ -50,8 +56,7 @@ func (h *PartnerHandler) SignIn(c *gin.Context) {
 		return
 	}
 
-	// Panggil service yang sekarang mengembalikan 3 nilai
-	token, status, err := h.service.LoginPartner(req)
+	response, err := h.service.LoginPartner(req)
 	if err != nil {
 		// Jika error karena kredensial tidak valid atau status tidak approved/pending
 		if err.Error() == "kredensial tidak valid" {
@@ -62,11 +67,6 @@ func (h *PartnerHandler) SignIn(c *gin.Context) {
 		return
 	}
 
-	// Buat respons minimalis
-	response := PartnerLoginResponse{
-		Token:  token,
-		Status: status, // Sertakan status aktual
-	}
 	c.JSON(http.StatusOK, response) // Selalu 200 OK jika kredensial benar
 }
 
@@ -240,12 +240,58 @@ func (h *PartnerHandler) UpdateAddress(c *gin.Context) {
 
 	updatedAddress, err := h.service.UpdateAddress(partnerIDStrConv, req)
 	if err != nil {
+		if strings.Contains(err.Error(), "wajib diisi") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, updatedAddress)
 }
 
+// GetServiceArea menangani request get area & kapasitas layanan jemput partner
+func (h *PartnerHandler) GetServiceArea(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+
+	area, err := h.service.GetServiceArea(partnerIDStr.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if area == nil {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Alamat usaha belum diatur"})
+		return
+	}
+	c.JSON(http.StatusOK, area)
+}
+
+// UpdateServiceArea menangani request update area & kapasitas layanan jemput partner
+func (h *PartnerHandler) UpdateServiceArea(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+
+	var req UpdateServiceAreaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	area, err := h.service.UpdateServiceArea(partnerIDStr.(string), req)
+	if err != nil {
+		errMsg := err.Error()
+		switch {
+		case strings.Contains(errMsg, "belum diisi"):
+			c.JSON(http.StatusNotFound, gin.H{"error": errMsg})
+		case strings.Contains(errMsg, "wajib diisi") || strings.Contains(errMsg, "minimal 3 titik") || strings.Contains(errMsg, "tidak valid"):
+			c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": errMsg})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, area)
+}
+
 // --- Partner Schedule Handlers ---
 
 // GetSchedule menangani request get jadwal operasional (single row)
@@ -298,7 +344,7 @@ func (h *PartnerHandler) UpdateSchedule(c *gin.Context) {
 
 	updatedSchedule, err := h.service.UpdateSchedule(partnerIDStrConv, req)
 	if err != nil {
-		if strings.Contains(err.Error(), "tidak valid") || strings.Contains(err.Error(), "muncul lebih dari sekali") {
+		if strings.Contains(err.Error(), "tidak valid") || strings.Contains(err.Error(), "tumpang tindih") || strings.Contains(err.Error(), "tidak boleh sama") {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		} else {
 			log.Printf("Internal error updating partner schedule %s: %v", partnerIDStrConv, err)
@@ -309,6 +355,47 @@ func (h *PartnerHandler) UpdateSchedule(c *gin.Context) {
 	c.JSON(http.StatusOK, updatedSchedule)
 }
 
+// CreateScheduleException menambah pengecualian jadwal (tutup seharian / jam khusus) pada tanggal tertentu
+func (h *PartnerHandler) CreateScheduleException(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	var req CreateScheduleExceptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format data tidak valid: " + err.Error()})
+		return
+	}
+
+	exception, err := h.service.CreateScheduleException(partnerIDStr.(string), req)
+	if err != nil {
+		if strings.Contains(err.Error(), "tidak valid") || strings.Contains(err.Error(), "terlalu panjang") || strings.Contains(err.Error(), "belum diatur") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, exception)
+}
+
+// DeleteScheduleException menghapus pengecualian jadwal
+func (h *PartnerHandler) DeleteScheduleException(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	exceptionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID pengecualian tidak valid"})
+		return
+	}
+
+	if err := h.service.DeleteScheduleException(partnerIDStr.(string), exceptionID); err != nil {
+		if strings.Contains(err.Error(), "tidak ditemukan") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Pengecualian jadwal berhasil dihapus"})
+}
+
 // --- Partner Waste Price Handlers ---
 
 func (h *PartnerHandler) CreateWastePrice(c *gin.Context) {
@@ -333,6 +420,10 @@ func (h *PartnerHandler) CreateWastePrice(c *gin.Context) {
 
 	detail, err := h.service.CreateWastePrice(partnerIDStr.(string), req, imageFile)
 	if err != nil {
+		if strings.Contains(err.Error(), "satuan harga tidak valid") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -416,6 +507,8 @@ func (h *PartnerHandler) UpdateWastePrice(c *gin.Context) {
 		errMsg := err.Error()
 		if errMsg == "detail harga sampah tidak ditemukan atau bukan milik Anda" {
 			c.JSON(http.StatusNotFound, gin.H{"error": errMsg})
+		} else if strings.Contains(errMsg, "satuan harga tidak valid") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengupdate detail harga sampah: " + errMsg})
 		}
@@ -445,6 +538,127 @@ func (h *PartnerHandler) DeleteWastePrice(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "Detail harga sampah berhasil dihapus"})
 }
 
+// ExportWastePrices mengunduh daftar harga: ?format=csv|xlsx (default csv), format sama dengan file import
+func (h *PartnerHandler) ExportWastePrices(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	format := strings.ToLower(c.DefaultQuery("format", spreadsheet.FormatCSV))
+
+	var buf bytes.Buffer
+	if err := h.service.ExportWastePrices(partnerIDStr.(string), format, &buf); err != nil {
+		if strings.Contains(err.Error(), "tidak valid") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	filename := fmt.Sprintf("harga_sampah_%s.%s", time.Now().Format("20060102"), format)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, spreadsheet.ContentType[format], buf.Bytes())
+}
+
+// ImportWastePrices import harga dari csv/xlsx (multipart: file, dry_run=true untuk validasi saja)
+func (h *PartnerHandler) ImportWastePrices(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file import wajib diisi"})
+		return
+	}
+	dryRun, _ := strconv.ParseBool(c.DefaultPostForm("dry_run", "false"))
+
+	report, err := h.service.ImportWastePrices(partnerIDStr.(string), file, dryRun)
+	if err != nil {
+		if strings.Contains(err.Error(), "tidak valid") || strings.Contains(err.Error(), "wajib diisi") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if report.Errors > 0 {
+		// Tidak ada perubahan yang disimpan; laporan per baris dikirim untuk diperbaiki
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%d baris tidak valid, tidak ada perubahan yang disimpan", report.Errors), "report": report})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// GetWastePriceHistory riwayat versi harga satu item beserta jadwal perubahan harganya
+func (h *PartnerHandler) GetWastePriceHistory(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	detailID, err := strconv.Atoi(c.Param("detail_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID detail tidak valid"})
+		return
+	}
+
+	versions, err := h.service.GetWastePriceHistory(detailID, partnerIDStr.(string))
+	if err != nil {
+		respondWastePriceScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, versions)
+}
+
+// ScheduleWastePrice menjadwalkan harga baru (JSON: price, unit opsional, effective_at RFC3339)
+func (h *PartnerHandler) ScheduleWastePrice(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	detailID, err := strconv.Atoi(c.Param("detail_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID detail tidak valid"})
+		return
+	}
+	var req ScheduleWastePriceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request tidak valid: " + err.Error()})
+		return
+	}
+	req.StaffID = staffIDFromContext(c)
+
+	version, err := h.service.ScheduleWastePrice(detailID, partnerIDStr.(string), req)
+	if err != nil {
+		respondWastePriceScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, version)
+}
+
+// CancelWastePriceSchedule membatalkan jadwal harga yang belum berlaku
+func (h *PartnerHandler) CancelWastePriceSchedule(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	detailID, err := strconv.Atoi(c.Param("detail_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID detail tidak valid"})
+		return
+	}
+	versionID, err := strconv.Atoi(c.Param("schedule_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID jadwal tidak valid"})
+		return
+	}
+
+	if err := h.service.CancelWastePriceSchedule(versionID, detailID, partnerIDStr.(string)); err != nil {
+		respondWastePriceScheduleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Jadwal harga berhasil dibatalkan"})
+}
+
+func respondWastePriceScheduleError(c *gin.Context, err error) {
+	errMsg := err.Error()
+	switch {
+	case strings.Contains(errMsg, "tidak ditemukan"):
+		c.JSON(http.StatusNotFound, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "sudah ada"):
+		c.JSON(http.StatusConflict, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "tidak valid") || strings.Contains(errMsg, "harus positif"):
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errMsg})
+	}
+}
+
 // --- Partner Financial Transaction History Handler ---
 
 func (h *PartnerHandler) GetFinancialTransactionHistory(c *gin.Context) {
@@ -700,6 +914,7 @@ func (h *PartnerHandler) TransferXpoin(c *gin.Context) {
 		return
 	}
 
+	req.StaffID = staffIDFromContext(c)
 	orderID, err := h.service.TransferXpoin(partnerIDStrConv, req)
 	if err != nil {
 		errMsg := err.Error()
@@ -806,12 +1021,18 @@ func (h *PartnerHandler) ConvertRpToXp(c *gin.Context) {
 
 // VerifyDepositQrToken menangani request validasi token QR deposit
 func (h *PartnerHandler) VerifyDepositQrToken(c *gin.Context) {
-	_, exists := c.Get("entityID")
+	partnerIDInterface, exists := c.Get("entityID")
 	if !exists {
 		log.Println("VerifyDepositQrToken Error: entityID not found in context")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Kesalahan internal: ID partner tidak ada di context"})
 		return
 	}
+	partnerIDStrConv, ok := partnerIDInterface.(string)
+	if !ok {
+		log.Printf("VerifyDepositQrToken Error: Invalid type for entityID: %T", partnerIDInterface)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Kesalahan internal: Format ID partner tidak valid"})
+		return
+	}
 
 	var req VerifyQrTokenRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -819,10 +1040,12 @@ func (h *PartnerHandler) VerifyDepositQrToken(c *gin.Context) {
 		return
 	}
 
-	userData, err := h.service.VerifyDepositQrToken(req)
+	userData, err := h.service.VerifyDepositQrToken(partnerIDStrConv, req)
 	if err != nil {
 		errMsg := err.Error()
-		if strings.Contains(errMsg, "tidak ditemukan") || strings.Contains(errMsg, "kedaluwarsa") {
+		if strings.Contains(errMsg, "sudah digunakan") {
+			c.JSON(http.StatusConflict, gin.H{"error": errMsg})
+		} else if strings.Contains(errMsg, "tidak ditemukan") || strings.Contains(errMsg, "kedaluwarsa") {
 			c.JSON(http.StatusBadRequest, gin.H{"error": errMsg}) // Kirim pesan error spesifik
 		} else {
 			log.Printf("Internal error verifying QR token: %v", err)
@@ -834,6 +1057,17 @@ func (h *PartnerHandler) VerifyDepositQrToken(c *gin.Context) {
 	c.JSON(http.StatusOK, userData) // Kirim data user jika token valid
 }
 
+// GetOfflineQrPublicKeys menangani request public key untuk verifikasi kode QR offline.
+// Aplikasi partner menyimpan (cache) kunci ini agar bisa memverifikasi tanpa sinyal.
+func (h *PartnerHandler) GetOfflineQrPublicKeys(c *gin.Context) {
+	keys, err := h.service.GetOfflineQrPublicKeys()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
 // --- Check User Handler ---
 
 // CheckUserByEmail menangani request pengecekan user berdasarkan email
@@ -890,14 +1124,14 @@ func (h *PartnerHandler) CreateDeposit(c *gin.Context) {
 	req.ItemsJSON = c.PostForm("items_json")
 	req.Notes = c.PostForm("notes")
 
-	// 3. Baca & validasi field integer manual
-	userIDStr := c.PostForm("user_id")
-	userID, errUser := strconv.Atoi(userIDStr)
-	if errUser != nil || userID <= 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "user_id tidak valid atau kosong"})
+	// 3. Token QR user (menggantikan user_id mentah) & field integer manual
+	req.QrToken = c.PostForm("qr_token")
+	req.OfflineQr = c.PostForm("offline_qr")
+	req.ScannedAt = c.PostForm("scanned_at")
+	if req.QrToken == "" && req.OfflineQr == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "qr_token atau offline_qr wajib diisi"})
 		return
 	}
-	req.UserID = userID
 
 	depositMethodIDStr := c.PostForm("deposit_method_id")
 	depositMethodID, errMethod := strconv.Atoi(depositMethodIDStr)
@@ -913,26 +1147,66 @@ func (h *PartnerHandler) CreateDeposit(c *gin.Context) {
 		return
 	}
 
-	// 4. Ambil file foto (opsional)
+	// 4. Ambil file foto (opsional): "photo" (lama), "photos" (banyak), "item_photos_<index item>"
 	imageFile, _ := c.FormFile("photo") // Abaikan error jika file tidak ada
+	if form, err := c.MultipartForm(); err == nil {
+		req.Photos = form.File["photos"]
+		for key, files := range form.File {
+			if !strings.HasPrefix(key, "item_photos_") {
+				continue
+			}
+			index, err := strconv.Atoi(strings.TrimPrefix(key, "item_photos_"))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Nama field " + key + " tidak valid, gunakan item_photos_<index item>"})
+				return
+			}
+			if req.ItemPhotos == nil {
+				req.ItemPhotos = make(map[int][]*multipart.FileHeader)
+			}
+			req.ItemPhotos[index] = files
+		}
+	}
+
+	// 5. Mode dua tahap: simpan draft dan tunggu konfirmasi user
+	req.StaffID = staffIDFromContext(c)
+	req.RequireConfirmation = c.PostForm("require_confirmation") == "true"
+	if req.RequireConfirmation {
+		draft, fraudCase, err := h.service.CreateDepositDraft(partnerIDStrConv, req, imageFile)
+		if err != nil {
+			log.Printf("Error CreateDepositDraft handler: %v", err)
+			respondCreateDepositError(c, err)
+			return
+		}
+		if fraudCase != nil {
+			c.JSON(http.StatusAccepted, gin.H{
+				"message":       "Setoran ditahan untuk ditinjau admin, Xpoin belum dipindahkan",
+				"fraud_case_id": fraudCase.ID,
+			})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Setoran sampah menunggu konfirmasi pengguna",
+			"draft":   draft,
+		})
+		return
+	}
 
-	// 5. Panggil service
+	// 6. Panggil service
 	createdDepositHeader, err := h.service.CreateDeposit(partnerIDStrConv, req, imageFile)
 	if err != nil {
-		errMsg := err.Error()
 		log.Printf("Error CreateDeposit handler: %v", err) // Log detail error
-		// Bedakan error validasi (400) vs internal (500)
-		if strings.Contains(errMsg, "tidak valid") || strings.Contains(errMsg, "harus positif") ||
-			strings.Contains(errMsg, "tidak mencukupi") || strings.Contains(errMsg, "tidak ditemukan") ||
-			strings.Contains(errMsg, "minimal harus ada") {
-			c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memproses setoran sampah"})
-		}
+		respondCreateDepositError(c, err)
 		return
 	}
 
-	// 6. Kirim response sukses
+	// 7. Kirim response sukses (deposit yang ditahan aturan anti-fraud belum dijalankan)
+	if createdDepositHeader.FraudCaseID != 0 {
+		c.JSON(http.StatusAccepted, gin.H{
+			"message":       "Setoran ditahan untuk ditinjau admin, Xpoin belum dipindahkan",
+			"fraud_case_id": createdDepositHeader.FraudCaseID,
+		})
+		return
+	}
 	c.JSON(http.StatusCreated, gin.H{
 		"message":           "Setoran sampah berhasil dicatat",
 		"deposit_header_id": createdDepositHeader.ID, // Kirim ID header deposit baru
@@ -940,3 +1214,1421 @@ func (h *PartnerHandler) CreateDeposit(c *gin.Context) {
 		// "deposit_header":    createdDepositHeader,
 	})
 }
+
+// respondCreateDepositError membedakan error validasi (400), token dipakai mitra lain (409) vs internal (500)
+func respondCreateDepositError(c *gin.Context, err error) {
+	errMsg := err.Error()
+	if strings.Contains(errMsg, "anti-fraud") {
+		c.JSON(http.StatusForbidden, gin.H{"error": errMsg})
+	} else if strings.Contains(errMsg, "sudah digunakan") || strings.Contains(errMsg, "sudah dipakai") {
+		c.JSON(http.StatusConflict, gin.H{"error": errMsg})
+	} else if strings.Contains(errMsg, "bukan keduanya") || strings.Contains(errMsg, "lebih dari sekali") || strings.Contains(errMsg, "kedaluwarsa") || strings.Contains(errMsg, "dicabut") || strings.Contains(errMsg, "tidak valid") || strings.Contains(errMsg, "harus positif") ||
+		strings.Contains(errMsg, "tidak mencukupi") || strings.Contains(errMsg, "tidak ditemukan") ||
+		strings.Contains(errMsg, "minimal harus ada") || strings.Contains(errMsg, "bersatuan") || strings.Contains(errMsg, "maksimal") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+	} else {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal memproses setoran sampah"})
+	}
+}
+
+// SyncDepositBatch menangani sinkronisasi deposit yang dicatat offline (hasil per item, bukan semua-atau-tidak)
+func (h *PartnerHandler) SyncDepositBatch(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+
+	var req BatchDepositRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	req.StaffID = staffIDFromContext(c)
+	results, err := h.service.SyncDepositBatch(partnerIDStr.(string), req)
+	if err != nil {
+		respondCreateDepositError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// GetDepositDrafts menangani request daftar draft deposit dua tahap (query opsional: ?status=Pending)
+func (h *PartnerHandler) GetDepositDrafts(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+
+	drafts, err := h.service.GetDepositDrafts(partnerIDStr.(string), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil draft deposit"})
+		return
+	}
+	c.JSON(http.StatusOK, drafts)
+}
+
+// --- Deposit Session Handlers ---
+
+// GenerateStationQr menangani pembuatan token QR stasiun untuk check-in mandiri user
+func (h *PartnerHandler) GenerateStationQr(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+
+	var req GenerateStationQrRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "validity_hours harus antara 1 dan 168"})
+			return
+		}
+	}
+
+	resp, err := h.service.GenerateStationQr(partnerIDStr.(string), req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetDepositSessions menangani request daftar sesi deposit (query opsional: ?status=Open)
+func (h *PartnerHandler) GetDepositSessions(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+
+	sessions, err := h.service.GetDepositSessions(partnerIDStr.(string), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil sesi deposit"})
+		return
+	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+// GetDepositSessionByID menangani request detail sesi deposit
+func (h *PartnerHandler) GetDepositSessionByID(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	sessionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID sesi tidak valid"})
+		return
+	}
+
+	session, err := h.service.GetDepositSessionByID(sessionID, partnerIDStr.(string))
+	if err != nil {
+		respondDepositSessionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// UpdateDepositSessionItems menangani pengisian item hasil timbang ke sesi deposit
+func (h *PartnerHandler) UpdateDepositSessionItems(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	sessionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID sesi tidak valid"})
+		return
+	}
+
+	var req UpdateDepositSessionItemsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "minimal harus ada satu item sampah"})
+		return
+	}
+
+	session, err := h.service.UpdateDepositSessionItems(sessionID, partnerIDStr.(string), req)
+	if err != nil {
+		respondDepositSessionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// ConfirmDepositSession menangani konfirmasi sesi deposit menjadi deposit (multipart/form-data)
+func (h *PartnerHandler) ConfirmDepositSession(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	sessionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID sesi tidak valid"})
+		return
+	}
+
+	var req ConfirmDepositSessionRequest
+	if err := c.ShouldBind(&req); err != nil || req.DepositMethodID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "deposit_method_id tidak valid atau kosong"})
+		return
+	}
+	imageFile, _ := c.FormFile("photo") // Foto opsional
+
+	req.StaffID = staffIDFromContext(c)
+	session, err := h.service.ConfirmDepositSession(sessionID, partnerIDStr.(string), req, imageFile)
+	if err != nil {
+		log.Printf("Error ConfirmDepositSession handler: %v", err)
+		respondDepositSessionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// CancelDepositSession menangani pembatalan sesi deposit oleh partner
+func (h *PartnerHandler) CancelDepositSession(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	sessionID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID sesi tidak valid"})
+		return
+	}
+
+	if err := h.service.CancelDepositSession(sessionID, partnerIDStr.(string)); err != nil {
+		respondDepositSessionError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Sesi deposit berhasil dibatalkan"})
+}
+
+// respondDepositSessionError memetakan error sesi deposit ke status HTTP
+func respondDepositSessionError(c *gin.Context, err error) {
+	errMsg := err.Error()
+	switch {
+	case errMsg == "sesi deposit tidak ditemukan":
+		c.JSON(http.StatusNotFound, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "anti-fraud"):
+		c.JSON(http.StatusForbidden, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "sudah selesai"):
+		c.JSON(http.StatusConflict, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "tidak valid") || strings.Contains(errMsg, "tidak ditemukan") ||
+		strings.Contains(errMsg, "belum diisi") || strings.Contains(errMsg, "tidak mencukupi") ||
+		strings.Contains(errMsg, "minimal harus ada") || strings.Contains(errMsg, "bersatuan"):
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errMsg})
+	}
+}
+
+// --- Void & Koreksi Deposit ---
+
+// parseDepositAdjustmentIDs membaca :id dan (opsional) :detail_id dari URL
+func parseDepositAdjustmentIDs(c *gin.Context, withDetail bool) (int, int, bool) {
+	depositID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID deposit tidak valid"})
+		return 0, 0, false
+	}
+	if !withDetail {
+		return depositID, 0, true
+	}
+	detailID, err := strconv.Atoi(c.Param("detail_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID detail deposit tidak valid"})
+		return 0, 0, false
+	}
+	return depositID, detailID, true
+}
+
+func (h *PartnerHandler) VoidDeposit(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	depositID, _, ok := parseDepositAdjustmentIDs(c, false)
+	if !ok {
+		return
+	}
+
+	var req VoidDepositRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "alasan pembatalan wajib diisi"})
+		return
+	}
+
+	if err := h.service.VoidDeposit(depositID, partnerIDStr.(string), req); err != nil {
+		respondDepositAdjustmentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Deposit berhasil dibatalkan"})
+}
+
+func (h *PartnerHandler) CorrectDepositDetail(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	depositID, detailID, ok := parseDepositAdjustmentIDs(c, true)
+	if !ok {
+		return
+	}
+
+	var req CorrectDepositDetailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "alasan koreksi wajib diisi, berat/jumlah baru harus > 0"})
+		return
+	}
+
+	if err := h.service.CorrectDepositDetail(depositID, detailID, partnerIDStr.(string), req); err != nil {
+		respondDepositAdjustmentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Item deposit berhasil dikoreksi"})
+}
+
+// AddDepositPhotos menangani unggah foto bukti tambahan ke deposit (multipart: "photos", opsional "detail_id")
+func (h *PartnerHandler) AddDepositPhotos(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	depositID, _, ok := parseDepositAdjustmentIDs(c, false)
+	if !ok {
+		return
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Data form tidak valid"})
+		return
+	}
+	detailID := 0
+	if detailIDStr := c.PostForm("detail_id"); detailIDStr != "" {
+		detailID, err = strconv.Atoi(detailIDStr)
+		if err != nil || detailID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "detail_id tidak valid"})
+			return
+		}
+	}
+
+	photos, err := h.service.AddDepositPhotos(depositID, partnerIDStr.(string), detailID, form.File["photos"])
+	if err != nil {
+		errMsg := err.Error()
+		switch {
+		case strings.Contains(errMsg, "deposit tidak ditemukan"):
+			c.JSON(http.StatusNotFound, gin.H{"error": errMsg})
+		case strings.Contains(errMsg, "batas waktu"):
+			c.JSON(http.StatusForbidden, gin.H{"error": errMsg})
+		case strings.Contains(errMsg, "tidak valid") || strings.Contains(errMsg, "tidak ditemukan") ||
+			strings.Contains(errMsg, "minimal harus ada") || strings.Contains(errMsg, "maksimal"):
+			c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": errMsg})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, photos)
+}
+
+func (h *PartnerHandler) GetDepositAdjustments(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	depositID, _, ok := parseDepositAdjustmentIDs(c, false)
+	if !ok {
+		return
+	}
+
+	adjustments, err := h.service.GetDepositAdjustments(depositID, partnerIDStr.(string))
+	if err != nil {
+		respondDepositAdjustmentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, adjustments)
+}
+
+// AdminVoidDeposit dipasang di grup /admin (tanpa batas waktu)
+func (h *PartnerHandler) AdminVoidDeposit(c *gin.Context) {
+	depositID, _, ok := parseDepositAdjustmentIDs(c, false)
+	if !ok {
+		return
+	}
+
+	var req VoidDepositRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "alasan pembatalan wajib diisi"})
+		return
+	}
+
+	if err := h.service.AdminVoidDeposit(depositID, req); err != nil {
+		respondDepositAdjustmentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Deposit berhasil dibatalkan"})
+}
+
+// AdminCorrectDepositDetail dipasang di grup /admin (tanpa batas waktu)
+func (h *PartnerHandler) AdminCorrectDepositDetail(c *gin.Context) {
+	depositID, detailID, ok := parseDepositAdjustmentIDs(c, true)
+	if !ok {
+		return
+	}
+
+	var req CorrectDepositDetailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "alasan koreksi wajib diisi, berat/jumlah baru harus > 0"})
+		return
+	}
+
+	if err := h.service.AdminCorrectDepositDetail(depositID, detailID, req); err != nil {
+		respondDepositAdjustmentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Item deposit berhasil dikoreksi"})
+}
+
+func (h *PartnerHandler) AdminGetDepositAdjustments(c *gin.Context) {
+	depositID, _, ok := parseDepositAdjustmentIDs(c, false)
+	if !ok {
+		return
+	}
+
+	adjustments, err := h.service.AdminGetDepositAdjustments(depositID)
+	if err != nil {
+		respondDepositAdjustmentError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, adjustments)
+}
+
+func respondDepositAdjustmentError(c *gin.Context, err error) {
+	errMsg := err.Error()
+	switch {
+	case strings.Contains(errMsg, "tidak ditemukan"):
+		c.JSON(http.StatusNotFound, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "sudah dibatalkan") || strings.Contains(errMsg, "sudah lewat") ||
+		strings.Contains(errMsg, "sudah berubah"):
+		c.JSON(http.StatusConflict, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "tidak valid") || strings.Contains(errMsg, "tidak mencukupi") ||
+		strings.Contains(errMsg, "sama dengan") || strings.Contains(errMsg, "wajib diisi"):
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errMsg})
+	}
+}
+
+// --- Sengketa Deposit ---
+
+func (h *PartnerHandler) GetDepositDisputes(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	disputes, err := h.service.GetDepositDisputes(partnerIDStr.(string), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil sengketa deposit"})
+		return
+	}
+	c.JSON(http.StatusOK, disputes)
+}
+
+func (h *PartnerHandler) GetDepositDisputeByID(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	disputeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID sengketa tidak valid"})
+		return
+	}
+
+	dispute, err := h.service.GetDepositDisputeByID(disputeID, partnerIDStr.(string))
+	if err != nil {
+		respondDepositDisputeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, dispute)
+}
+
+func (h *PartnerHandler) RespondDepositDispute(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	disputeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID sengketa tidak valid"})
+		return
+	}
+
+	var req RespondDepositDisputeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tanggapan wajib diisi"})
+		return
+	}
+
+	dispute, err := h.service.RespondDepositDispute(disputeID, partnerIDStr.(string), req)
+	if err != nil {
+		respondDepositDisputeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, dispute)
+}
+
+// AdminGetDepositDisputes antrean sengketa untuk admin (dipasang di grup /admin)
+func (h *PartnerHandler) AdminGetDepositDisputes(c *gin.Context) {
+	disputes, err := h.service.AdminGetDepositDisputes(c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil antrean sengketa deposit"})
+		return
+	}
+	c.JSON(http.StatusOK, disputes)
+}
+
+func (h *PartnerHandler) AdminGetDepositDisputeByID(c *gin.Context) {
+	disputeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID sengketa tidak valid"})
+		return
+	}
+
+	dispute, err := h.service.AdminGetDepositDisputeByID(disputeID)
+	if err != nil {
+		respondDepositDisputeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, dispute)
+}
+
+func (h *PartnerHandler) AdminResolveDepositDispute(c *gin.Context) {
+	disputeID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID sengketa tidak valid"})
+		return
+	}
+
+	var req ResolveDepositDisputeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "decision (resolve/reject) dan note wajib diisi; action harus none, void, atau correction"})
+		return
+	}
+
+	dispute, err := h.service.AdminResolveDepositDispute(disputeID, req)
+	if err != nil {
+		respondDepositDisputeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, dispute)
+}
+
+func respondDepositDisputeError(c *gin.Context, err error) {
+	errMsg := err.Error()
+	switch {
+	case strings.Contains(errMsg, "tidak ditemukan"):
+		c.JSON(http.StatusNotFound, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "sudah ditanggapi") || strings.Contains(errMsg, "sudah diputuskan") ||
+		strings.Contains(errMsg, "sudah dibatalkan") || strings.Contains(errMsg, "sudah berubah"):
+		c.JSON(http.StatusConflict, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "tidak valid") || strings.Contains(errMsg, "wajib diisi") ||
+		strings.Contains(errMsg, "tidak dapat") || strings.Contains(errMsg, "tidak mencukupi") ||
+		strings.Contains(errMsg, "sama dengan"):
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errMsg})
+	}
+}
+
+// --- Ulasan Partner ---
+
+// GetPartnerReviews daftar ulasan atas partner, opsional ?status=Published|Hidden
+func (h *PartnerHandler) GetPartnerReviews(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	reviews, err := h.service.GetPartnerReviews(partnerIDStr.(string), c.Query("status"))
+	if err != nil {
+		respondPartnerReviewError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, reviews)
+}
+
+// ReplyPartnerReview balasan publik partner atas ulasan
+func (h *PartnerHandler) ReplyPartnerReview(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	reviewID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID ulasan tidak valid"})
+		return
+	}
+
+	var req ReplyPartnerReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "balasan wajib diisi"})
+		return
+	}
+
+	review, err := h.service.ReplyPartnerReview(reviewID, partnerIDStr.(string), req)
+	if err != nil {
+		respondPartnerReviewError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, review)
+}
+
+// AdminGetPartnerReviews daftar ulasan untuk moderasi (dipasang di grup /admin): ?status=&partner_id=
+func (h *PartnerHandler) AdminGetPartnerReviews(c *gin.Context) {
+	partnerID := 0
+	if v := c.Query("partner_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "partner_id tidak valid"})
+			return
+		}
+		partnerID = id
+	}
+
+	reviews, err := h.service.AdminGetPartnerReviews(c.Query("status"), partnerID)
+	if err != nil {
+		respondPartnerReviewError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, reviews)
+}
+
+func (h *PartnerHandler) AdminModeratePartnerReview(c *gin.Context) {
+	reviewID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID ulasan tidak valid"})
+		return
+	}
+
+	var req ModeratePartnerReviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "action (hide/publish) dan note wajib diisi"})
+		return
+	}
+
+	review, err := h.service.AdminModeratePartnerReview(reviewID, req)
+	if err != nil {
+		respondPartnerReviewError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, review)
+}
+
+func respondPartnerReviewError(c *gin.Context, err error) {
+	errMsg := err.Error()
+	switch {
+	case strings.Contains(errMsg, "tidak ditemukan"):
+		c.JSON(http.StatusNotFound, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "sudah berstatus") || strings.Contains(errMsg, "tidak dapat dibalas"):
+		c.JSON(http.StatusConflict, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "tidak valid") || strings.Contains(errMsg, "wajib diisi") ||
+		strings.Contains(errMsg, "terlalu panjang"):
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errMsg})
+	}
+}
+
+// --- Staf Partner ---
+
+// staffIDFromContext mengambil ID staf dari token (diisi AuthMiddleware), 0 jika tidak ada
+func staffIDFromContext(c *gin.Context) int {
+	staffID, _ := c.Get("staffID")
+	id, _ := staffID.(int)
+	return id
+}
+
+// RequireStaffRole middleware (dipasang setelah AuthMiddleware) yang membatasi endpoint partner untuk role
+// staf tertentu. Owner selalu diizinkan; status aktif & role dibaca ulang dari database di setiap request
+// sehingga penonaktifan staf langsung berlaku.
+func (h *PartnerHandler) RequireStaffRole(allowedRoles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		partnerIDStr, _ := c.Get("entityID")
+		staff, err := h.service.AuthorizePartnerStaff(partnerIDStr.(string), staffIDFromContext(c), allowedRoles...)
+		if err != nil {
+			errMsg := err.Error()
+			switch {
+			case strings.Contains(errMsg, "akses ditolak"):
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": errMsg})
+			case strings.Contains(errMsg, "tidak aktif") || strings.Contains(errMsg, "tidak valid"):
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": errMsg})
+			default:
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": errMsg})
+			}
+			return
+		}
+		c.Set("staffID", staff.ID)
+		c.Set("staffRole", staff.Role)
+		c.Next()
+	}
+}
+
+// GetPartnerStaffList daftar akun staf partner (owner)
+func (h *PartnerHandler) GetPartnerStaffList(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	staff, err := h.service.GetPartnerStaffList(partnerIDStr.(string))
+	if err != nil {
+		respondPartnerStaffError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, staff)
+}
+
+// GetMyPartnerStaff akun staf yang sedang login
+func (h *PartnerHandler) GetMyPartnerStaff(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	staff, err := h.service.GetMyPartnerStaff(partnerIDStr.(string), staffIDFromContext(c))
+	if err != nil {
+		respondPartnerStaffError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, staff)
+}
+
+func (h *PartnerHandler) CreatePartnerStaff(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	var req CreatePartnerStaffRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request tidak valid: " + err.Error()})
+		return
+	}
+
+	staff, err := h.service.CreatePartnerStaff(partnerIDStr.(string), req)
+	if err != nil {
+		respondPartnerStaffError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, staff)
+}
+
+func (h *PartnerHandler) UpdatePartnerStaff(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	staffID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID staf tidak valid"})
+		return
+	}
+
+	var req UpdatePartnerStaffRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request tidak valid: " + err.Error()})
+		return
+	}
+
+	staff, err := h.service.UpdatePartnerStaff(partnerIDStr.(string), staffID, req)
+	if err != nil {
+		respondPartnerStaffError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, staff)
+}
+
+// ResetPartnerStaffPassword owner mengatur password baru staf
+func (h *PartnerHandler) ResetPartnerStaffPassword(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	staffID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID staf tidak valid"})
+		return
+	}
+
+	var req ResetPartnerStaffPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Password baru wajib diisi (minimal 6 karakter)"})
+		return
+	}
+
+	if err := h.service.ResetPartnerStaffPassword(partnerIDStr.(string), staffID, req); err != nil {
+		respondPartnerStaffError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Password staf berhasil diperbarui"})
+}
+
+// ChangeMyStaffPassword staf mengganti password sendiri
+func (h *PartnerHandler) ChangeMyStaffPassword(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request tidak valid: " + err.Error()})
+		return
+	}
+
+	if err := h.service.ChangeMyStaffPassword(partnerIDStr.(string), staffIDFromContext(c), req); err != nil {
+		respondPartnerStaffError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Password berhasil diperbarui"})
+}
+
+func respondPartnerStaffError(c *gin.Context, err error) {
+	errMsg := err.Error()
+	switch {
+	case strings.Contains(errMsg, "tidak ditemukan"):
+		c.JSON(http.StatusNotFound, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "sudah terdaftar"):
+		c.JSON(http.StatusConflict, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "akses ditolak") || strings.Contains(errMsg, "tidak dapat diubah") ||
+		strings.Contains(errMsg, "owner mengganti password"):
+		c.JSON(http.StatusForbidden, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "tidak valid") || strings.Contains(errMsg, "wajib diisi") ||
+		strings.Contains(errMsg, "tidak cocok") || strings.Contains(errMsg, "password lama salah"):
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "tidak aktif"):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errMsg})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errMsg})
+	}
+}
+
+// --- Inventaris Sampah ---
+
+// GetInventory stok sampah partner saat ini beserta valuasinya
+func (h *PartnerHandler) GetInventory(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	summary, err := h.service.GetInventory(partnerIDStr.(string))
+	if err != nil {
+		respondInventoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+// GetInventoryMovements mutasi stok: ?waste_detail_id=&type=&limit=&offset=
+func (h *PartnerHandler) GetInventoryMovements(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	wasteDetailID, limit, offset := 0, 0, 0
+	for key, target := range map[string]*int{"waste_detail_id": &wasteDetailID, "limit": &limit, "offset": &offset} {
+		if v := c.Query(key); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": key + " tidak valid"})
+				return
+			}
+			*target = n
+		}
+	}
+
+	movements, err := h.service.GetInventoryMovements(partnerIDStr.(string), wasteDetailID, c.Query("type"), limit, offset)
+	if err != nil {
+		respondInventoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, movements)
+}
+
+// RecordInventoryOutbound stok keluar (dibuang); penjualan lewat offtake
+func (h *PartnerHandler) RecordInventoryOutbound(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	var req InventoryOutboundRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request tidak valid: " + err.Error()})
+		return
+	}
+	req.StaffID = staffIDFromContext(c)
+
+	summary, err := h.service.RecordInventoryOutbound(partnerIDStr.(string), req)
+	if err != nil {
+		respondInventoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+// AdjustInventory penyesuaian stok manual dengan alasan
+func (h *PartnerHandler) AdjustInventory(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	var req InventoryAdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request tidak valid: " + err.Error()})
+		return
+	}
+	req.StaffID = staffIDFromContext(c)
+
+	summary, err := h.service.AdjustInventory(partnerIDStr.(string), req)
+	if err != nil {
+		respondInventoryError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, summary)
+}
+
+func respondInventoryError(c *gin.Context, err error) {
+	errMsg := err.Error()
+	switch {
+	case strings.Contains(errMsg, "tidak mencukupi"):
+		c.JSON(http.StatusConflict, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "tidak valid") || strings.Contains(errMsg, "wajib diisi"):
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errMsg})
+	}
+}
+
+// --- Penjualan ke Recycler (Offtake) ---
+
+// GetOfftakeRecyclers daftar recycler aktif untuk dipilih saat mencatat penjualan
+func (h *PartnerHandler) GetOfftakeRecyclers(c *gin.Context) {
+	recyclers, err := h.service.GetOfftakeRecyclers()
+	if err != nil {
+		respondOfftakeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, recyclers)
+}
+
+// CreateOfftakeSale mencatat penjualan ke recycler (multipart: recycler_id, sale_date, invoice_number, notes, items_json, invoice_photo)
+func (h *PartnerHandler) CreateOfftakeSale(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	var req CreateOfftakeSaleRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request tidak valid: " + err.Error()})
+		return
+	}
+	req.InvoicePhoto, _ = c.FormFile("invoice_photo") // Wajib, divalidasi service
+	req.StaffID = staffIDFromContext(c)
+
+	sale, err := h.service.CreateOfftakeSale(partnerIDStr.(string), req)
+	if err != nil {
+		respondOfftakeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, sale)
+}
+
+// GetOfftakeSales daftar penjualan: ?from=&to= (YYYY-MM-DD, default bulan ini) &recycler_id=&limit=&offset=
+func (h *PartnerHandler) GetOfftakeSales(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	recyclerID, limit, offset := 0, 0, 0
+	for key, target := range map[string]*int{"recycler_id": &recyclerID, "limit": &limit, "offset": &offset} {
+		if v := c.Query(key); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": key + " tidak valid"})
+				return
+			}
+			*target = n
+		}
+	}
+
+	sales, err := h.service.GetOfftakeSales(partnerIDStr.(string), c.Query("from"), c.Query("to"), recyclerID, limit, offset)
+	if err != nil {
+		respondOfftakeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, sales)
+}
+
+func (h *PartnerHandler) GetOfftakeSaleByID(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	saleID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID penjualan tidak valid"})
+		return
+	}
+
+	sale, err := h.service.GetOfftakeSaleByID(partnerIDStr.(string), saleID)
+	if err != nil {
+		respondOfftakeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, sale)
+}
+
+// GetOfftakeMarginReport laporan pendapatan & margin: ?from=&to= (YYYY-MM-DD, default bulan ini)
+func (h *PartnerHandler) GetOfftakeMarginReport(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	report, err := h.service.GetOfftakeMarginReport(partnerIDStr.(string), c.Query("from"), c.Query("to"))
+	if err != nil {
+		respondOfftakeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+func respondOfftakeError(c *gin.Context, err error) {
+	errMsg := err.Error()
+	switch {
+	case strings.Contains(errMsg, "tidak ditemukan"):
+		c.JSON(http.StatusNotFound, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "tidak mencukupi"):
+		c.JSON(http.StatusConflict, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "tidak valid") || strings.Contains(errMsg, "wajib diisi"):
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errMsg})
+	}
+}
+
+// --- Onboarding (KYC) Partner ---
+
+// GetOnboardingApplication pengajuan onboarding milik partner (dibuat Draft jika belum ada)
+func (h *PartnerHandler) GetOnboardingApplication(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	app, err := h.service.GetOnboardingApplication(partnerIDStr.(string))
+	if err != nil {
+		respondOnboardingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, app)
+}
+
+// UpdateOnboardingApplication menyimpan data pemilik, NIB, dan rekening pencairan
+func (h *PartnerHandler) UpdateOnboardingApplication(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	var req UpdateOnboardingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Request tidak valid: " + err.Error()})
+		return
+	}
+
+	app, err := h.service.UpdateOnboardingApplication(partnerIDStr.(string), req)
+	if err != nil {
+		respondOnboardingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, app)
+}
+
+// AddOnboardingDocument unggah dokumen onboarding (multipart: type = nib|ktp|site_photo, file)
+func (h *PartnerHandler) AddOnboardingDocument(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file dokumen wajib diisi"})
+		return
+	}
+
+	doc, err := h.service.AddOnboardingDocument(partnerIDStr.(string), c.PostForm("type"), file)
+	if err != nil {
+		respondOnboardingError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, doc)
+}
+
+func (h *PartnerHandler) DeleteOnboardingDocument(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	documentID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID dokumen tidak valid"})
+		return
+	}
+
+	if err := h.service.DeleteOnboardingDocument(partnerIDStr.(string), documentID); err != nil {
+		respondOnboardingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Dokumen onboarding berhasil dihapus"})
+}
+
+// SubmitOnboardingApplication mengajukan onboarding ke antrean review admin
+func (h *PartnerHandler) SubmitOnboardingApplication(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	app, err := h.service.SubmitOnboardingApplication(partnerIDStr.(string))
+	if err != nil {
+		respondOnboardingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, app)
+}
+
+// AdminGetOnboardingApplications antrean review onboarding (dipasang di grup /admin): ?status= (default Submitted, "all")
+func (h *PartnerHandler) AdminGetOnboardingApplications(c *gin.Context) {
+	apps, err := h.service.AdminGetOnboardingApplications(c.Query("status"))
+	if err != nil {
+		respondOnboardingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, apps)
+}
+
+func (h *PartnerHandler) AdminGetOnboardingApplicationByID(c *gin.Context) {
+	applicationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID pengajuan tidak valid"})
+		return
+	}
+
+	app, err := h.service.AdminGetOnboardingApplicationByID(applicationID)
+	if err != nil {
+		respondOnboardingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, app)
+}
+
+func (h *PartnerHandler) AdminApproveOnboarding(c *gin.Context) {
+	h.adminReviewOnboarding(c, h.service.AdminApproveOnboarding)
+}
+
+func (h *PartnerHandler) AdminRejectOnboarding(c *gin.Context) {
+	h.adminReviewOnboarding(c, h.service.AdminRejectOnboarding)
+}
+
+func (h *PartnerHandler) AdminRequestOnboardingInfo(c *gin.Context) {
+	h.adminReviewOnboarding(c, h.service.AdminRequestOnboardingInfo)
+}
+
+// adminReviewOnboarding membaca ID & catatan lalu menjalankan keputusan admin; body boleh kosong
+func (h *PartnerHandler) adminReviewOnboarding(c *gin.Context, review func(int, ReviewOnboardingRequest) (*OnboardingApplication, error)) {
+	applicationID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID pengajuan tidak valid"})
+		return
+	}
+
+	var req ReviewOnboardingRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Request tidak valid: " + err.Error()})
+			return
+		}
+	}
+
+	app, err := review(applicationID, req)
+	if err != nil {
+		respondOnboardingError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, app)
+}
+
+func respondOnboardingError(c *gin.Context, err error) {
+	errMsg := err.Error()
+	switch {
+	case strings.Contains(errMsg, "tidak ditemukan"):
+		c.JSON(http.StatusNotFound, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "sudah berstatus") || strings.Contains(errMsg, "sudah diajukan") ||
+		strings.Contains(errMsg, "tidak dapat diubah"):
+		c.JSON(http.StatusConflict, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "tidak valid") || strings.Contains(errMsg, "wajib diisi") ||
+		strings.Contains(errMsg, "tidak dapat melebihi"):
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errMsg})
+	}
+}
+
+// --- Permintaan Jemput ---
+
+func (h *PartnerHandler) GetAvailablePickupRequests(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	pickups, err := h.service.GetAvailablePickupRequests(partnerIDStr.(string))
+	if err != nil {
+		respondPickupRequestError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, pickups)
+}
+
+func (h *PartnerHandler) GetPickupRequests(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	pickups, err := h.service.GetPickupRequests(partnerIDStr.(string), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil permintaan jemput"})
+		return
+	}
+	c.JSON(http.StatusOK, pickups)
+}
+
+func (h *PartnerHandler) GetPickupRequestByID(c *gin.Context) {
+	h.handlePickupAction(c, h.service.GetPickupRequestByID)
+}
+
+func (h *PartnerHandler) AcceptPickupRequest(c *gin.Context) {
+	h.handlePickupAction(c, h.service.AcceptPickupRequest)
+}
+
+func (h *PartnerHandler) StartPickupTrip(c *gin.Context) {
+	h.handlePickupAction(c, h.service.StartPickupTrip)
+}
+
+func (h *PartnerHandler) MarkPickupCollected(c *gin.Context) {
+	h.handlePickupAction(c, h.service.MarkPickupCollected)
+}
+
+// handlePickupAction menjalankan aksi permintaan jemput yang hanya butuh :id dan mengembalikan data terbaru
+func (h *PartnerHandler) handlePickupAction(c *gin.Context, action func(int, string) (*user.PickupRequest, error)) {
+	partnerIDStr, _ := c.Get("entityID")
+	pickupID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID permintaan jemput tidak valid"})
+		return
+	}
+
+	pickup, err := action(pickupID, partnerIDStr.(string))
+	if err != nil {
+		respondPickupRequestError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, pickup)
+}
+
+func (h *PartnerHandler) ReleasePickupRequest(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	pickupID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID permintaan jemput tidak valid"})
+		return
+	}
+
+	if err := h.service.ReleasePickupRequest(pickupID, partnerIDStr.(string)); err != nil {
+		respondPickupRequestError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Permintaan jemput dilepas untuk partner lain"})
+}
+
+func (h *PartnerHandler) CompletePickupRequest(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	pickupID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID permintaan jemput tidak valid"})
+		return
+	}
+
+	var req CompletePickupRequest
+	if err := c.ShouldBind(&req); err != nil || req.DepositMethodID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "deposit_method_id dan items_json wajib diisi"})
+		return
+	}
+	imageFile, _ := c.FormFile("photo") // Foto opsional
+
+	req.StaffID = staffIDFromContext(c)
+	pickup, err := h.service.CompletePickupRequest(pickupID, partnerIDStr.(string), req, imageFile)
+	if err != nil {
+		log.Printf("Error CompletePickupRequest handler: %v", err)
+		respondPickupRequestError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, pickup)
+}
+
+func respondPickupRequestError(c *gin.Context, err error) {
+	errMsg := err.Error()
+	switch {
+	case strings.Contains(errMsg, "permintaan jemput tidak ditemukan"):
+		c.JSON(http.StatusNotFound, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "belum disetujui") || strings.Contains(errMsg, "belum diaktifkan") || strings.Contains(errMsg, "anti-fraud"):
+		c.JSON(http.StatusForbidden, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "sudah diambil") || strings.Contains(errMsg, "sudah selesai") ||
+		strings.Contains(errMsg, "status permintaan jemput harus") || strings.Contains(errMsg, "sudah penuh") ||
+		strings.Contains(errMsg, "sudah dipakai"):
+		c.JSON(http.StatusConflict, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "tidak valid") || strings.Contains(errMsg, "tidak ditemukan") ||
+		strings.Contains(errMsg, "tidak mencukupi") || strings.Contains(errMsg, "harus positif") ||
+		strings.Contains(errMsg, "minimal harus ada") || strings.Contains(errMsg, "bersatuan") ||
+		strings.Contains(errMsg, "kedaluwarsa") || strings.Contains(errMsg, "bukan keduanya") || strings.Contains(errMsg, "lebih dari sekali"):
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errMsg})
+	}
+}
+
+// --- Timbangan Digital ---
+
+func (h *PartnerHandler) RegisterScaleDevice(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+
+	var req RegisterScaleDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	device, err := h.service.RegisterScaleDevice(partnerIDStr.(string), req)
+	if err != nil {
+		respondScaleError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, device)
+}
+
+func (h *PartnerHandler) GetScaleDevices(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+
+	devices, err := h.service.GetScaleDevices(partnerIDStr.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil daftar timbangan"})
+		return
+	}
+	c.JSON(http.StatusOK, devices)
+}
+
+func (h *PartnerHandler) RevokeScaleDevice(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+	deviceRowID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID timbangan tidak valid"})
+		return
+	}
+
+	if err := h.service.RevokeScaleDevice(deviceRowID, partnerIDStr.(string)); err != nil {
+		respondScaleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Timbangan berhasil dicabut"})
+}
+
+// GetScaleReadings menampilkan hasil timbang terbaru agar bisa dipilih saat membuat deposit (?unused=true)
+func (h *PartnerHandler) GetScaleReadings(c *gin.Context) {
+	partnerIDStr, _ := c.Get("entityID")
+
+	readings, err := h.service.GetScaleReadings(partnerIDStr.(string), c.Query("unused") == "true")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil hasil timbang"})
+		return
+	}
+	c.JSON(http.StatusOK, readings)
+}
+
+// IngestScaleReading menerima hasil timbang dari perangkat. Autentikasi lewat tanda tangan HMAC, bukan JWT.
+func (h *PartnerHandler) IngestScaleReading(c *gin.Context) {
+	var req ScaleReadingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	reading, err := h.service.IngestScaleReading(req)
+	if err != nil {
+		respondScaleError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, reading)
+}
+
+// AdminGetScaleDevices daftar timbangan semua partner (?certified=true|false)
+func (h *PartnerHandler) AdminGetScaleDevices(c *gin.Context) {
+	var certified *bool
+	if v := c.Query("certified"); v != "" {
+		parsed, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parameter certified tidak valid"})
+			return
+		}
+		certified = &parsed
+	}
+
+	devices, err := h.service.AdminGetScaleDevices(certified)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil daftar timbangan"})
+		return
+	}
+	c.JSON(http.StatusOK, devices)
+}
+
+func (h *PartnerHandler) AdminCertifyScaleDevice(c *gin.Context) {
+	deviceRowID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID timbangan tidak valid"})
+		return
+	}
+
+	var req CertifyScaleDeviceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.AdminCertifyScaleDevice(deviceRowID, req); err != nil {
+		respondScaleError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Sertifikasi timbangan diperbarui"})
+}
+
+func (h *PartnerHandler) AdminGetScaleUsage(c *gin.Context) {
+	usage, err := h.service.AdminGetScaleUsage()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil ringkasan pemakaian timbangan"})
+		return
+	}
+	c.JSON(http.StatusOK, usage)
+}
+
+func respondScaleError(c *gin.Context, err error) {
+	errMsg := err.Error()
+	switch {
+	case strings.Contains(errMsg, "tanda tangan"):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "dicabut"):
+		c.JSON(http.StatusForbidden, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "timbangan tidak ditemukan"):
+		c.JSON(http.StatusNotFound, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "sudah terdaftar") || strings.Contains(errMsg, "sudah pernah dikirim"):
+		c.JSON(http.StatusConflict, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "tidak valid") || strings.Contains(errMsg, "kedaluwarsa") || strings.Contains(errMsg, "wajib diisi"):
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errMsg})
+	}
+}
+
+// --- Deteksi Fraud Deposit (Admin) ---
+
+func (h *PartnerHandler) AdminGetFraudRules(c *gin.Context) {
+	rules, err := h.service.GetFraudRules()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil aturan fraud"})
+		return
+	}
+	c.JSON(http.StatusOK, rules)
+}
+
+func (h *PartnerHandler) AdminUpdateFraudRule(c *gin.Context) {
+	var req UpdateFraudRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "action (allow/hold/reject) wajib diisi"})
+		return
+	}
+
+	rule, err := h.service.UpdateFraudRule(c.Param("code"), req)
+	if err != nil {
+		respondFraudCaseError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, rule)
+}
+
+// AdminGetDepositFraudCases antrean deposit yang ditahan (default Held, ?status=all untuk semua)
+func (h *PartnerHandler) AdminGetDepositFraudCases(c *gin.Context) {
+	status := c.DefaultQuery("status", FraudCaseHeld)
+	if status == "all" {
+		status = ""
+	}
+
+	cases, err := h.service.AdminGetDepositFraudCases(status)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil kasus fraud deposit"})
+		return
+	}
+	c.JSON(http.StatusOK, cases)
+}
+
+func (h *PartnerHandler) AdminGetDepositFraudCaseByID(c *gin.Context) {
+	caseID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID kasus tidak valid"})
+		return
+	}
+
+	fraudCase, err := h.service.AdminGetDepositFraudCaseByID(caseID)
+	if err != nil {
+		respondFraudCaseError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, fraudCase)
+}
+
+func (h *PartnerHandler) AdminApproveDepositFraudCase(c *gin.Context) {
+	caseID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID kasus tidak valid"})
+		return
+	}
+	var req ReviewFraudCaseRequest
+	c.ShouldBindJSON(&req) // Catatan opsional
+
+	fraudCase, err := h.service.AdminApproveDepositFraudCase(caseID, req)
+	if err != nil {
+		respondFraudCaseError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, fraudCase)
+}
+
+func (h *PartnerHandler) AdminDeclineDepositFraudCase(c *gin.Context) {
+	caseID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID kasus tidak valid"})
+		return
+	}
+	var req ReviewFraudCaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "note wajib diisi"})
+		return
+	}
+
+	fraudCase, err := h.service.AdminDeclineDepositFraudCase(caseID, req)
+	if err != nil {
+		respondFraudCaseError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, fraudCase)
+}
+
+func respondFraudCaseError(c *gin.Context, err error) {
+	errMsg := err.Error()
+	switch {
+	case strings.Contains(errMsg, "tidak ditemukan"):
+		c.JSON(http.StatusNotFound, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "sudah diproses") || strings.Contains(errMsg, "sudah dipakai"):
+		c.JSON(http.StatusConflict, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "tidak valid") || strings.Contains(errMsg, "wajib diisi") ||
+		strings.Contains(errMsg, "tidak mencukupi"):
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errMsg})
+	}
+}
@@ -0,0 +1,54 @@
+package partner
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireStaffRoleStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := NewPartnerHandler(&PartnerService{repo: newStaffRepo()})
+
+	tests := []struct {
+		name       string
+		partnerID  string
+		staffID    int
+		roles      []string
+		wantStatus int
+		wantRole   string
+	}{
+		{"owner passes owner only", "7", 1, nil, http.StatusOK, StaffRoleOwner},
+		{"cashier passes cashier access", "7", 2, []string{StaffRoleCashier}, http.StatusOK, StaffRoleCashier},
+		{"cashier forbidden on finance access", "7", 2, []string{StaffRoleFinance}, http.StatusForbidden, ""},
+		{"finance forbidden on owner only", "7", 3, nil, http.StatusForbidden, ""},
+		{"inactive staff unauthorized", "7", 4, []string{StaffRoleCashier}, http.StatusUnauthorized, ""},
+		{"invalid partner unauthorized", "x", 1, nil, http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/", func(c *gin.Context) {
+				c.Set("entityID", tt.partnerID)
+				if tt.staffID != 0 {
+					c.Set("staffID", tt.staffID)
+				}
+				c.Next()
+			}, h.RequireStaffRole(tt.roles...), func(c *gin.Context) {
+				role, _ := c.Get("staffRole")
+				c.String(http.StatusOK, role.(string))
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (%s)", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantRole != "" && w.Body.String() != tt.wantRole {
+				t.Fatalf("staffRole = %q, want %q", w.Body.String(), tt.wantRole)
+			}
+		})
+	}
+}
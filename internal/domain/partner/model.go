@@ -2,8 +2,15 @@ package partner
 
 import (
 	"database/sql"
+	"encoding/json"
+	"mime/multipart"
 	"strings"
 	"time"
+
+	"xetor.id/backend/internal/domain/user"
+	"xetor.id/backend/internal/fraud"
+	"xetor.id/backend/internal/geo"
+	"xetor.id/backend/internal/schedule"
 )
 
 // Partner merepresentasikan data partner dari tabel partners
@@ -34,8 +41,10 @@ type PartnerLoginRequest struct {
 
 // PartnerLoginResponse data respons setelah login berhasil
 type PartnerLoginResponse struct {
-	Token  string `json:"token"`
-	Status string `json:"status"`
+	Token     string `json:"token"`
+	Status    string `json:"status"`
+	StaffID   int    `json:"staff_id"`
+	StaffRole string `json:"staff_role"` // owner, cashier, finance
 }
 
 // UpdatePartnerProfileRequest data untuk update profil partner
@@ -63,6 +72,11 @@ type PartnerAddress struct {
 	PostalCode  sql.NullString `json:"postal_code,omitempty"`
 	Latitude    sql.NullFloat64 `json:"latitude,omitempty"`    // Koordinat latitude
 	Longitude   sql.NullFloat64 `json:"longitude,omitempty"`   // Koordinat longitude
+	PickupEnabled bool         `json:"pickup_enabled"`        // Melayani permintaan jemput sampah
+	ServiceRadiusKm     float64     `json:"service_radius_km"`              // Radius layanan jemput dari alamat ini
+	ServiceAreaPolygon  []geo.Point `json:"service_area_polygon,omitempty"` // Jika diisi, menggantikan radius
+	DailyPickupCapacity int         `json:"daily_pickup_capacity"`          // Jumlah jemput per hari, 0 = tidak dibatasi
+	MinPickupWeight     float64     `json:"min_pickup_weight"`              // Estimasi berat minimal (kg) per jemput
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 }
@@ -75,42 +89,57 @@ type UpdatePartnerAddressRequest struct {
 	PostalCode  string   `json:"postal_code"`        // Opsional
 	Latitude    *float64 `json:"latitude"`          // Opsional, pointer agar bisa null
 	Longitude   *float64 `json:"longitude"`         // Opsional, pointer agar bisa null
+	PickupEnabled *bool  `json:"pickup_enabled"`    // Opsional, nil = tidak diubah
 }
 
-// PartnerSchedule merepresentasikan satu baris data dari tabel partner_schedules (struktur baru)
+// PartnerSchedule jadwal operasional partner: interval jam buka per hari (boleh lebih dari satu per hari,
+// mis. istirahat siang), pengecualian bertanggal, dan opsi mengikuti libur nasional
 type PartnerSchedule struct {
-	ID              int       `json:"id"`
-	PartnerID       int       `json:"partner_id"`
-	DaysOpen        []string  `json:"days_open"`        // Kirim sebagai array string ["Senin", "Selasa"]
-	OpenTime        string    `json:"open_time"`        // Format "HH:MM"
-	CloseTime       string    `json:"close_time"`       // Format "HH:MM"
-	OperatingStatus string    `json:"operating_status"` // "Open" atau "Closed"
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
+	ID                      int                  `json:"id"`
+	PartnerID               int                  `json:"partner_id"`
+	OperatingStatus         string               `json:"operating_status"`          // "Open" atau "Closed" (tutup sementara)
+	Timezone                string               `json:"timezone"`                  // Misal "Asia/Jakarta" (WIB), "Asia/Makassar" (WITA)
+	ObserveNationalHolidays bool                 `json:"observe_national_holidays"` // Tutup pada libur nasional
+	Intervals               []schedule.Interval  `json:"intervals"`
+	Exceptions              []schedule.Exception `json:"exceptions"` // Pengecualian mulai hari ini
+	IsOpenNow               bool                 `json:"is_open_now"`
+	NextOpenAt              *time.Time           `json:"next_open_at,omitempty"`
+	CreatedAt               time.Time            `json:"created_at"`
+	UpdatedAt               time.Time            `json:"updated_at"`
 }
 
-// UpdateScheduleRequest data untuk mengupdate seluruh jadwal operasional (struktur baru)
+// UpdateScheduleRequest data untuk mengupdate jadwal operasional. Field kosong/nil = tidak diubah.
 type UpdateScheduleRequest struct {
-	DaysOpen        []string `json:"days_open"`        // Array hari buka ["Senin", "Selasa", ...]
-	OpenTime        string   `json:"open_time"`        // Format "HH:MM"
-	CloseTime       string   `json:"close_time"`       // Format "HH:MM"
-	OperatingStatus string   `json:"operating_status"` // "Open" atau "Closed"
+	OperatingStatus         string               `json:"operating_status"`          // "Open" atau "Closed"
+	Timezone                string               `json:"timezone"`                  // Opsional, zona waktu Indonesia (Asia/Jakarta, Asia/Makassar, Asia/Jayapura)
+	ObserveNationalHolidays *bool                `json:"observe_national_holidays"` // Opsional
+	Intervals               *[]schedule.Interval `json:"intervals"`                 // Opsional, menggantikan seluruh interval; [] = tidak ada jam buka
 }
 
-// Helper function untuk mengubah string database ke slice
-func DaysOpenFromString(dbString sql.NullString) []string {
-	if !dbString.Valid || dbString.String == "" {
-		return []string{} // Return slice kosong jika null atau string kosong
-	}
-	return strings.Split(dbString.String, ",") // Pisahkan string berdasarkan koma
+// CreateScheduleExceptionRequest data pengecualian jadwal: tutup seharian (closed=true) atau jam khusus
+type CreateScheduleExceptionRequest struct {
+	Date      string `json:"date" binding:"required"` // "YYYY-MM-DD"
+	Closed    bool   `json:"closed"`
+	OpenTime  string `json:"open_time"`  // Wajib jika closed=false, "HH:MM"
+	CloseTime string `json:"close_time"` // Wajib jika closed=false, "HH:MM"
+	Reason    string `json:"reason"`     // Misal "Idul Fitri", "Renovasi"
 }
 
-// Helper function untuk mengubah slice ke string database
-func DaysOpenToString(days []string) sql.NullString {
-	if len(days) == 0 {
-		return sql.NullString{Valid: false} // Simpan sebagai NULL jika array kosong
+// Satuan harga sampah: kg dihitung per berat, pcs per jumlah buah
+const (
+	WasteUnitKg  = "kg"
+	WasteUnitPcs = "pcs"
+)
+
+// NormalizeWasteUnit menyeragamkan penulisan satuan (misal "Kg", "buah"), false jika tidak dikenal
+func NormalizeWasteUnit(unit string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(unit)) {
+	case "kg", "kilo", "kilogram":
+		return WasteUnitKg, true
+	case "pcs", "pc", "buah", "biji", "unit":
+		return WasteUnitPcs, true
 	}
-	return sql.NullString{String: strings.Join(days, ","), Valid: true} // Gabungkan dengan koma
+	return "", false
 }
 
 // PartnerWastePriceDetail merepresentasikan satu baris dari partner_waste_price_details
@@ -146,6 +175,54 @@ type UpdateWastePriceRequest struct {
 	// Image *multipart.FileHeader `form:"image"` // Ditangani terpisah
 }
 
+// Status versi harga sampah
+const (
+	WastePriceVersionScheduled = "Scheduled" // Menunggu effective_from
+	WastePriceVersionApplied   = "Applied"   // Sudah/sedang berlaku
+	WastePriceVersionCancelled = "Cancelled" // Jadwal dibatalkan
+)
+
+// Sumber perubahan harga yang tercatat di versi
+const (
+	WastePriceSourceManual   = "manual"
+	WastePriceSourceImport   = "import"
+	WastePriceSourceSchedule = "schedule"
+)
+
+// WastePriceVersion satu versi harga dari partner_waste_price_versions
+type WastePriceVersion struct {
+	ID                        int            `json:"id"`
+	PartnerWastePriceDetailID sql.NullInt32  `json:"partner_waste_price_detail_id,omitempty"` // NULL jika item harga sudah dihapus
+	WasteDetailID             sql.NullInt32  `json:"waste_detail_id,omitempty"`
+	Name                      string         `json:"name"`
+	Price                     string         `json:"price"` // Rp, sbg string
+	Unit                      string         `json:"unit"`
+	Xpoin                     int            `json:"xpoin"`
+	Status                    string         `json:"status"` // Scheduled, Applied, Cancelled
+	Source                    string         `json:"source"` // initial, manual, import, schedule
+	EffectiveFrom             time.Time      `json:"effective_from"`
+	EffectiveUntil            *time.Time     `json:"effective_until,omitempty"` // Kosong = masih berlaku
+	StaffID                   sql.NullInt64  `json:"staff_id,omitempty"`        // Staf yang menjadwalkan
+	StaffName                 sql.NullString `json:"staff_name,omitempty"`
+	CreatedAt                 time.Time      `json:"created_at"`
+}
+
+// ScheduleWastePriceRequest jadwal perubahan harga yang berlaku otomatis di masa depan
+type ScheduleWastePriceRequest struct {
+	Price       float64   `json:"price" binding:"required,gt=0"`
+	Unit        string    `json:"unit"`                            // Opsional, default satuan saat ini
+	EffectiveAt time.Time `json:"effective_at" binding:"required"` // RFC3339
+	StaffID     int       `json:"-"`                               // Diisi handler dari token staf
+}
+
+// AppliedWastePriceVersion hasil penerapan satu jadwal harga (untuk kalkulasi ulang Xpoin katalog)
+type AppliedWastePriceVersion struct {
+	VersionID     int
+	PartnerID     int
+	DetailID      int // ID partner_waste_price_details
+	WasteDetailID sql.NullInt32
+}
+
 // PartnerTransactionHistoryItem adalah format standar untuk riwayat transaksi finansial gabungan partner
 type PartnerTransactionHistoryItem struct {
 	ID          string         `json:"id"`               // ID unik (misal: "withdraw-5", "topup-2")
@@ -211,6 +288,7 @@ type PartnerTopupRequest struct {
 type PartnerTransferRequest struct {
 	RecipientEmail string `json:"recipient_email" binding:"required,email"`
 	Amount         int    `json:"amount" binding:"required,gt=0"` // Xpoin > 0
+	StaffID        int    `json:"-"`                              // Diisi handler dari token staf
 }
 
 // PartnerConversionRequest data umum untuk request konversi partner
@@ -251,9 +329,15 @@ type DepositHistoryDetailItem struct {
 	PartnerDepositHistoryID int            `json:"partner_deposit_history_id"`
 	WasteDetailID           sql.NullInt32  `json:"waste_detail_id,omitempty"`
 	WasteName               sql.NullString `json:"waste_name,omitempty"`   // Nama dari waste_types
-	WasteWeight             sql.NullString `json:"waste_weight,omitempty"` // Berat (kg), sbg string
+	WasteWeight             sql.NullString `json:"waste_weight,omitempty"` // Berat (kg), sbg string; untuk pcs hasil konversi
+	Unit                    string         `json:"unit"`                   // kg atau pcs
+	Quantity                sql.NullInt32  `json:"quantity,omitempty"`     // Jumlah buah (hanya pcs)
+	ScaleReadingID          sql.NullInt32  `json:"scale_reading_id,omitempty"` // Berat dari timbangan digital
 	Xpoin                   int            `json:"xpoin"`
-	Photo                   sql.NullString `json:"photo,omitempty"` // URL Foto bukti
+	PriceVersionID          sql.NullInt32  `json:"price_version_id,omitempty"` // Versi harga yang dipakai menghitung Xpoin
+	PricePerUnit            sql.NullString `json:"price_per_unit,omitempty"`   // Harga (Rp) pada versi tersebut
+	Photo                   sql.NullString `json:"photo,omitempty"` // URL Foto bukti utama item
+	Photos                  []user.DepositPhoto `json:"photos"`     // Semua foto bukti item
 	Notes                   sql.NullString `json:"notes,omitempty"`
 	Status                  string         `json:"status"`
 }
@@ -271,6 +355,10 @@ type DepositHistoryHeader struct {
 	CreatedAt       time.Time                  `json:"created_at"`
 	UpdatedAt       time.Time                  `json:"updated_at"`
 	Details         []DepositHistoryDetailItem `json:"details"` // Slice untuk menampung detail item
+	Photos          []user.DepositPhoto        `json:"photos"`  // Foto bukti level deposit
+	FraudCaseID     int                        `json:"fraud_case_id,omitempty"` // Terisi jika deposit ditahan untuk review (ID = 0)
+	StaffID         sql.NullInt64              `json:"staff_id,omitempty"`      // Staf yang mencatat deposit
+	StaffName       sql.NullString             `json:"staff_name,omitempty"`
 }
 
 // --- Structs untuk Create Deposit ---
@@ -278,18 +366,36 @@ type DepositHistoryHeader struct {
 // DepositWasteItem representasi satu item sampah dalam JSON string di request
 type DepositWasteItem struct {
 	PartnerWastePriceDetailID int     `json:"partner_waste_price_detail_id"` // ID dari partner_waste_price_details
-	Weight                    float64 `json:"weight"`                        // Berat dalam KG
+	Weight                    float64 `json:"weight"`                        // Berat dalam KG (harga bersatuan kg)
+	Quantity                  int     `json:"quantity"`                      // Jumlah buah (harga bersatuan pcs)
+	ScaleReadingID            int     `json:"scale_reading_id"`              // Alternatif weight: ID hasil timbangan digital
 	// Field internal untuk kalkulasi service, BUKAN dari JSON request
+	Unit            string        `json:"-"` // Satuan harga, diisi service
 	CalculatedXpoin int           `json:"-"` // Akan diisi oleh service
 	WasteDetailID   sql.NullInt32 `json:"-"` // Akan diisi oleh service
+	PriceVersionID  sql.NullInt32 `json:"-"` // Versi harga yang dipakai menghitung Xpoin, diisi service
+	Photos          []user.DepositPhoto `json:"-"` // Foto item yang sudah diunggah service
 }
 
 // CreateDepositRequest data yang diterima dari partner via multipart/form-data
 type CreateDepositRequest struct {
-	UserID          int    `form:"user_id" binding:"required"`           // ID User Penyetor
+	QrToken         string `form:"qr_token"`                             // Token QR user yang sudah diverifikasi partner ini
+	OfflineQr       string `form:"offline_qr"`                           // Alternatif: kode QR offline bertanda tangan
+	ScannedAt       string `form:"scanned_at"`                           // Waktu scan kode QR offline (RFC3339, opsional)
+	UserID          int    `form:"-"`                                    // Diisi service dari token QR
 	DepositMethodID int    `form:"deposit_method_id" binding:"required"` // ID Metode Deposit (DropOff/PickUp)
 	ItemsJSON       string `form:"items_json" binding:"required"`        // JSON string dari []DepositWasteItem
 	Notes           string `form:"notes"`                                // Catatan opsional
+	// Mode dua tahap: simpan sebagai draft, Xpoin baru berpindah setelah user menerima
+	RequireConfirmation bool `form:"require_confirmation"`
+	ClientID     string    `form:"-"` // Diisi dari batch sinkronisasi offline
+	// Foto bukti tambahan (field "photos") dan foto per item (field "item_photos_<index item>")
+	Photos     []*multipart.FileHeader         `form:"-"`
+	ItemPhotos map[int][]*multipart.FileHeader `form:"-"`
+	TransactedAt time.Time `form:"-"` // Waktu transaksi asli (batch offline), kosong = sekarang
+	StaffID      int       `form:"-"` // Diisi handler dari token staf yang mencatat
+	SourceType   string    `form:"-"` // Diisi service: asal deposit (sesi, jemput), lihat FraudCaseSource*
+	SourceID     int       `form:"-"`
 	// Photo *multipart.FileHeader `form:"photo"` // Akan diambil manual di handler
 }
 
@@ -299,6 +405,8 @@ type WastePriceInfo struct {
 	XpoinPerUnit  int
 	Unit          string
 	WasteDetailID sql.NullInt32 // Foreign Key ke waste_details
+	AverageUnitWeight sql.NullFloat64 // Rata-rata berat per pcs (kg) dari katalog waste_details
+	VersionID         sql.NullInt32   // Versi harga yang sedang berlaku
 }
 
 // ArgsDepositCreation struct untuk parameter fungsi transaksi deposit
@@ -311,5 +419,685 @@ type ArgsDepositCreation struct {
 	TotalXpoin       int
 	Notes            sql.NullString
 	PhotoURL         sql.NullString
+	Photos           []user.DepositPhoto // Foto level deposit; foto item ada di Items
 	TransactionTime  time.Time // Waktu transaksi aktual
-}
\ No newline at end of file
+	ClientID         sql.NullString // ID dari aplikasi partner untuk deposit yang disinkronkan offline
+	StaffID          sql.NullInt64  // Staf partner yang mencatat deposit
+	Consume          func(tx *sql.Tx) error // Opsional: memakai token/nonce QR di awal transaksi agar ikut rollback
+	FraudCaseID      int // Kasus fraud yang disetujui; hasil timbang yang dicadangkan kasus ini boleh dipakai
+	SourceType       string // Asal deposit (sesi, jemput, draft) yang ikut ditahan jika deposit ditahan anti-fraud
+	SourceID         int
+}
+
+// --- Structs untuk Sinkronisasi Deposit Offline (Batch) ---
+
+// Status hasil per item batch deposit
+const (
+	BatchDepositCreated   = "created"   // Deposit baru tersimpan
+	BatchDepositDuplicate = "duplicate" // client_id sudah pernah tersimpan, tidak diproses ulang
+	BatchDepositFailed    = "failed"    // Gagal, boleh dikirim ulang dengan client_id yang sama
+	BatchDepositHeld      = "held"      // Tersimpan tapi ditahan untuk review fraud oleh admin
+)
+
+// BatchDepositRequest kumpulan deposit yang dicatat offline di aplikasi partner
+type BatchDepositRequest struct {
+	Deposits []BatchDepositItem `json:"deposits" binding:"required,min=1,max=100,dive"`
+	StaffID  int                `json:"-"` // Diisi handler dari token staf
+}
+
+// BatchDepositItem satu deposit offline, diidentifikasi client_id buatan aplikasi partner
+type BatchDepositItem struct {
+	ClientID        string          `json:"client_id" binding:"required,max=64"`
+	QrToken         string          `json:"qr_token"`
+	OfflineQr       string          `json:"offline_qr"`
+	ScannedAt       string          `json:"scanned_at"`                                  // RFC3339, opsional
+	DepositMethodID int             `json:"deposit_method_id" binding:"required"`
+	Items           json.RawMessage `json:"items" binding:"required"`                    // []DepositWasteItem
+	Notes           string          `json:"notes"`
+	TransactedAt    time.Time       `json:"transacted_at" binding:"required"`            // Waktu transaksi asli (RFC3339)
+}
+
+// BatchDepositResult hasil pemrosesan satu item batch deposit
+type BatchDepositResult struct {
+	ClientID   string `json:"client_id"`
+	Status     string `json:"status"`
+	DepositID  int    `json:"deposit_id,omitempty"`
+	TotalXpoin int    `json:"total_xpoin,omitempty"`
+	FraudCaseID int   `json:"fraud_case_id,omitempty"` // Status held: deposit menunggu review admin
+	Error      string `json:"error,omitempty"`
+}
+// --- Structs untuk Sesi Deposit (QR Stasiun) ---
+
+// GenerateStationQrRequest data opsional saat partner membuat QR stasiun
+type GenerateStationQrRequest struct {
+	ValidityHours int `json:"validity_hours" binding:"omitempty,gte=1,lte=168"` // Default 24 jam, maksimal 7 hari
+}
+
+// StationQrResponse token QR stasiun yang ditampilkan di lokasi partner
+type StationQrResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// UpdateDepositSessionItemsRequest item hasil timbang yang diisi partner ke sesi deposit
+type UpdateDepositSessionItemsRequest struct {
+	Items []DepositWasteItem `json:"items" binding:"required,min=1"`
+}
+
+// ConfirmDepositSessionRequest data konfirmasi sesi deposit via multipart/form-data
+type ConfirmDepositSessionRequest struct {
+	DepositMethodID int    `form:"deposit_method_id" binding:"required"`
+	Notes           string `form:"notes"`
+	StaffID         int    `form:"-"` // Diisi handler dari token staf
+}
+
+// --- Structs untuk Void & Koreksi Deposit ---
+
+// VoidDepositRequest alasan pembatalan deposit
+type VoidDepositRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// CorrectDepositDetailRequest berat (item kg) atau jumlah (item pcs) baru untuk satu item deposit
+type CorrectDepositDetailRequest struct {
+	Weight   float64 `json:"weight" binding:"omitempty,gt=0"`   // Berat baru (kg)
+	Quantity int     `json:"quantity" binding:"omitempty,gt=0"` // Jumlah baru (pcs)
+	Reason   string  `json:"reason" binding:"required"`
+}
+
+// DepositAdjustment satu baris jejak audit void/koreksi deposit
+type DepositAdjustment struct {
+	ID                      int           `json:"id"`
+	PartnerDepositHistoryID int           `json:"partner_deposit_history_id"`
+	DetailID                sql.NullInt32 `json:"detail_id,omitempty"`
+	Type                    string        `json:"type"`       // "void" atau "correction"
+	ActorType               string        `json:"actor_type"` // "partner" atau "admin"
+	ActorID                 sql.NullInt32 `json:"actor_id,omitempty"`
+	Reason                  string        `json:"reason"`
+	OldWeight               string        `json:"old_weight"` // kg, sbg string
+	NewWeight               string        `json:"new_weight"`
+	OldQuantity             sql.NullInt32 `json:"old_quantity,omitempty"` // Hanya untuk item pcs
+	NewQuantity             sql.NullInt32 `json:"new_quantity,omitempty"`
+	OldXpoin                int           `json:"old_xpoin"`
+	NewXpoin                int           `json:"new_xpoin"`
+	CreatedAt               time.Time     `json:"created_at"`
+	Photos                  []user.DepositPhoto `json:"photos,omitempty"` // Foto bukti item yang dikoreksi
+}
+
+// DepositAdjustmentTarget data deposit yang akan di-void/dikoreksi (nilai numerik mentah)
+type DepositAdjustmentTarget struct {
+	ID                   int
+	PartnerID            int
+	UserID               int
+	UserDepositHistoryID sql.NullInt32
+	TransactionTime      time.Time
+	VoidedAt             sql.NullTime
+	Details              []DepositAdjustmentTargetDetail
+}
+
+// DepositAdjustmentTargetDetail satu item dari DepositAdjustmentTarget
+type DepositAdjustmentTargetDetail struct {
+	ID            int
+	WasteDetailID sql.NullInt32
+	Weight        float64
+	Xpoin         int
+	Unit          string
+	Quantity      sql.NullInt32
+	PriceXpoin    sql.NullInt32 // Xpoin per kg/pcs dari versi harga saat deposit, NULL untuk item lama
+}
+
+// DepositAdjustmentLine perubahan satu item beserta selisih dampak lingkungannya
+type DepositAdjustmentLine struct {
+	DetailID    int
+	OldWeight   float64
+	NewWeight   float64
+	OldQuantity sql.NullInt32 // Hanya untuk item pcs
+	NewQuantity sql.NullInt32 // Hanya untuk item pcs
+	OldXpoin    int
+	NewXpoin    int
+	EnergyDelta float64
+	CO2Delta    float64
+	WaterDelta  float64
+	TreeDelta   int
+}
+
+// ArgsDepositAdjustment parameter transaksi void/koreksi deposit
+type ArgsDepositAdjustment struct {
+	Target    *DepositAdjustmentTarget
+	Type      string // "void" atau "correction"
+	ActorType string // "partner" atau "admin"
+	ActorID   sql.NullInt32
+	Reason    string
+	Lines     []DepositAdjustmentLine
+}
+
+// --- Structs untuk Sengketa Deposit ---
+
+// RespondDepositDisputeRequest tanggapan partner atas sengketa deposit
+type RespondDepositDisputeRequest struct {
+	Response string `json:"response" binding:"required"`
+}
+
+// --- Structs untuk Ulasan Partner ---
+
+// ReplyPartnerReviewRequest balasan publik partner atas ulasan (mengganti balasan sebelumnya)
+type ReplyPartnerReviewRequest struct {
+	Reply string `json:"reply" binding:"required"`
+}
+
+// ModeratePartnerReviewRequest keputusan moderasi admin: sembunyikan atau tampilkan kembali ulasan
+type ModeratePartnerReviewRequest struct {
+	Action string `json:"action" binding:"required,oneof=hide publish"`
+	Note   string `json:"note" binding:"required"`
+}
+
+// ResolveDepositDisputeRequest keputusan admin atas sengketa deposit.
+// Action hanya berlaku untuk decision "resolve"; "correction" butuh detail_id & weight.
+type ResolveDepositDisputeRequest struct {
+	Decision string  `json:"decision" binding:"required,oneof=resolve reject"`
+	Note     string  `json:"note" binding:"required"`
+	Action   string  `json:"action" binding:"omitempty,oneof=none void correction"`
+	DetailID int     `json:"detail_id"`
+	Weight   float64 `json:"weight"`   // Berat baru (kg) untuk action "correction"
+	Quantity int     `json:"quantity"` // Jumlah baru untuk item pcs
+}
+
+// --- Structs untuk Permintaan Jemput ---
+
+// CompletePickupRequest hasil timbang sampah jemputan via multipart/form-data (foto di field "photo")
+type CompletePickupRequest struct {
+	DepositMethodID int    `form:"deposit_method_id" binding:"required"` // Metode deposit PickUp
+	ItemsJSON       string `form:"items_json" binding:"required"`        // JSON string dari []DepositWasteItem
+	Notes           string `form:"notes"`
+	StaffID         int    `form:"-"` // Diisi handler dari token staf
+}
+
+// UpdateServiceAreaRequest pengaturan area & kapasitas layanan jemput partner
+type UpdateServiceAreaRequest struct {
+	PickupEnabled       bool        `json:"pickup_enabled"`
+	ServiceRadiusKm     float64     `json:"service_radius_km" binding:"required,gt=0,lte=100"`
+	ServiceAreaPolygon  []geo.Point `json:"service_area_polygon"` // Opsional, minimal 3 titik
+	DailyPickupCapacity int         `json:"daily_pickup_capacity" binding:"gte=0"`
+	MinPickupWeight     float64     `json:"min_pickup_weight" binding:"gte=0"`
+}
+
+// --- Structs untuk Timbangan Digital ---
+
+// ScaleDevice timbangan digital terdaftar milik partner
+type ScaleDevice struct {
+	ID                  int            `json:"id"`
+	PartnerID           int            `json:"partner_id"`
+	PartnerName         sql.NullString `json:"partner_name,omitempty"` // Hanya di daftar admin
+	DeviceID            string         `json:"device_id"`
+	Name                string         `json:"name"`
+	Secret              string         `json:"secret,omitempty"` // Kunci HMAC, hanya dikembalikan saat registrasi
+	Certified           bool           `json:"certified"`
+	CertificationNumber sql.NullString `json:"certification_number,omitempty"`
+	CertifiedAt         *time.Time     `json:"certified_at,omitempty"`
+	LastReadingAt       *time.Time     `json:"last_reading_at,omitempty"`
+	RevokedAt           *time.Time     `json:"revoked_at,omitempty"`
+	ReadingsUsed        int            `json:"readings_used"` // Hasil timbang yang sudah dipakai di deposit
+	CreatedAt           time.Time      `json:"created_at"`
+}
+
+// RegisterScaleDeviceRequest data registrasi timbangan oleh partner
+type RegisterScaleDeviceRequest struct {
+	DeviceID string `json:"device_id" binding:"required,max=64"`
+	Name     string `json:"name" binding:"required,max=100"`
+}
+
+// CertifyScaleDeviceRequest status sertifikasi (tera) timbangan, diatur admin
+type CertifyScaleDeviceRequest struct {
+	Certified           bool   `json:"certified"`
+	CertificationNumber string `json:"certification_number"`
+}
+
+// ScaleReadingRequest hasil timbang yang dikirim perangkat. signature = hex(HMAC-SHA256(secret,
+// "<device_id>|<reading_id>|<weight>|<unix measured_at>")), weight ditandatangani persis seperti dikirim.
+type ScaleReadingRequest struct {
+	DeviceID   string    `json:"device_id" binding:"required"`
+	ReadingID  string    `json:"reading_id" binding:"required,max=64"` // Nomor urut/nonce dari perangkat
+	Weight     string    `json:"weight" binding:"required"`            // kg, misal "1.250"
+	MeasuredAt time.Time `json:"measured_at" binding:"required"`       // RFC3339
+	Signature  string    `json:"signature" binding:"required"`
+}
+
+// ScaleReading satu hasil timbang yang sudah diverifikasi
+type ScaleReading struct {
+	ID            int        `json:"id"`
+	ScaleDeviceID int        `json:"scale_device_id"`
+	DeviceName    string     `json:"device_name"`
+	Certified     bool       `json:"certified"`
+	Weight        string     `json:"weight"` // kg, sbg string
+	MeasuredAt    time.Time  `json:"measured_at"`
+	UsedAt        *time.Time `json:"used_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// ScaleReadingInfo data mentah hasil timbang untuk validasi saat deposit
+type ScaleReadingInfo struct {
+	ID            int
+	PartnerID     int
+	Weight        float64
+	MeasuredAt    time.Time
+	Used          bool
+	DeviceRevoked bool
+}
+
+// ScalePartnerUsage ringkasan pemakaian timbangan per partner untuk admin
+type ScalePartnerUsage struct {
+	PartnerID         int    `json:"partner_id"`
+	BusinessName      string `json:"business_name"`
+	Devices           int    `json:"devices"`
+	CertifiedDevices  int    `json:"certified_devices"`
+	ScaleWeighedItems int    `json:"scale_weighed_items"` // Item deposit 30 hari terakhir yang ditimbang perangkat
+	CertifiedItems    int    `json:"certified_items"`     // ... dengan timbangan bersertifikat
+	TotalItems        int    `json:"total_items"`         // Semua item deposit 30 hari terakhir
+}
+
+// --- Structs untuk Deteksi Fraud Deposit ---
+
+// Status kasus fraud deposit
+const (
+	FraudCaseHeld     = "Held"     // Deposit ditahan menunggu review admin
+	FraudCaseBlocked  = "Blocked"  // Deposit ditolak otomatis oleh aturan
+	FraudCaseApproved = "Approved" // Dirilis admin, deposit sudah dijalankan
+	FraudCaseDeclined = "Declined" // Deposit yang ditahan ditolak admin
+)
+
+// Asal deposit yang ditahan. Selama kasus Held, asalnya berstatus Held; disetujui = asal selesai dan
+// terhubung ke deposit, ditolak = asal dibatalkan.
+const (
+	FraudCaseSourceDepositSession = "deposit_session"
+	FraudCaseSourcePickupRequest  = "pickup_request"
+	FraudCaseSourceDepositDraft   = "deposit_draft"
+)
+
+// DepositFraudCase deposit yang memicu aturan fraud. Item disimpan dalam format draft agar bisa dijalankan saat dirilis.
+type DepositFraudCase struct {
+	ID                      int                     `json:"id"`
+	PartnerID               int                     `json:"partner_id"`
+	PartnerName             sql.NullString          `json:"partner_name,omitempty"`
+	UserID                  int                     `json:"user_id"`
+	UserName                sql.NullString          `json:"user_name,omitempty"`
+	Status                  string                  `json:"status"`
+	Hits                    []fraud.Hit             `json:"hits"`
+	DepositMethodID         int                     `json:"deposit_method_id"`
+	Items                   []user.DepositDraftItem `json:"items"`
+	TotalWeight             string                  `json:"total_weight"` // kg, sbg string
+	TotalXpoin              int                     `json:"total_xpoin"`
+	Notes                   sql.NullString          `json:"notes,omitempty"`
+	Photo                   sql.NullString          `json:"photo,omitempty"`
+	Photos                  []user.DepositPhoto     `json:"photos"`
+	ClientID                sql.NullString          `json:"client_id,omitempty"`
+	TransactionTime         time.Time               `json:"transaction_time"`
+	StaffID                 sql.NullInt64           `json:"staff_id,omitempty"` // Staf yang mencatat deposit
+	SourceType              sql.NullString          `json:"source_type,omitempty"` // deposit_session, pickup_request, deposit_draft; kosong = deposit langsung
+	SourceID                sql.NullInt32           `json:"source_id,omitempty"`
+	PartnerDepositHistoryID sql.NullInt32           `json:"partner_deposit_history_id,omitempty"` // Terisi setelah Approved
+	ReviewNote              sql.NullString          `json:"review_note,omitempty"`
+	ReviewedAt              *time.Time              `json:"reviewed_at,omitempty"`
+	CreatedAt               time.Time               `json:"created_at"`
+	UpdatedAt               time.Time               `json:"updated_at"`
+}
+
+// DepositFraudSignals data historis untuk evaluasi aturan fraud, dihitung repo sesuai window aturan
+type DepositFraudSignals struct {
+	UserWeightInDay float64
+	PastWeights     map[float64]int
+	DepositsToUser  int
+}
+
+// UpdateFraudRuleRequest perubahan konfigurasi aturan fraud oleh admin
+type UpdateFraudRuleRequest struct {
+	Action      string   `json:"action" binding:"required"` // allow, hold, reject
+	Threshold   *float64 `json:"threshold"`
+	WindowHours *int     `json:"window_hours"`
+}
+
+// ReviewFraudCaseRequest catatan admin saat merilis/menolak kasus
+type ReviewFraudCaseRequest struct {
+	Note string `json:"note"`
+}
+
+// --- Structs untuk Onboarding (KYC) Partner ---
+
+// Status pengajuan onboarding partner
+const (
+	OnboardingDraft     = "Draft"      // Sedang diisi partner
+	OnboardingSubmitted = "Submitted"  // Menunggu review admin
+	OnboardingNeedsInfo = "Needs Info" // Admin meminta data/dokumen tambahan, partner bisa mengubah & mengajukan ulang
+	OnboardingApproved  = "Approved"
+	OnboardingRejected  = "Rejected"
+)
+
+// Jenis dokumen onboarding
+const (
+	OnboardingDocNIB       = "nib"        // Dokumen NIB (OSS)
+	OnboardingDocKTP       = "ktp"        // KTP pemilik
+	OnboardingDocSitePhoto = "site_photo" // Foto lokasi usaha, boleh lebih dari satu
+)
+
+// OnboardingApplication pengajuan onboarding (KYC) partner, satu per partner
+type OnboardingApplication struct {
+	ID                int                    `json:"id"`
+	PartnerID         int                    `json:"partner_id"`
+	PartnerName       sql.NullString         `json:"partner_name,omitempty"`
+	Status            string                 `json:"status"`
+	OwnerName         string                 `json:"owner_name"`
+	OwnerNIK          string                 `json:"owner_nik"`
+	NIB               string                 `json:"nib"`
+	BankName          string                 `json:"bank_name"`
+	BankAccountNumber string                 `json:"bank_account_number"`
+	BankAccountHolder string                 `json:"bank_account_holder"`
+	ReviewNote        sql.NullString         `json:"review_note,omitempty"` // Alasan penolakan / info yang diminta
+	Documents         []OnboardingDocument   `json:"documents"`
+	Address           *PartnerAddress        `json:"address"`           // Alamat usaha dari /partner/address
+	History           []PartnerStatusHistory `json:"history,omitempty"` // Hanya di detail
+	SubmittedAt       *time.Time             `json:"submitted_at,omitempty"`
+	ReviewedAt        *time.Time             `json:"reviewed_at,omitempty"`
+	CreatedAt         time.Time              `json:"created_at"`
+	UpdatedAt         time.Time              `json:"updated_at"`
+}
+
+// OnboardingDocument satu dokumen pengajuan onboarding
+type OnboardingDocument struct {
+	ID            int       `json:"id"`
+	ApplicationID int       `json:"application_id"`
+	Type          string    `json:"type"`
+	URL           string    `json:"url"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// PartnerStatusHistory satu perubahan status pengajuan onboarding / status xetor_partners
+type PartnerStatusHistory struct {
+	ID            int            `json:"id"`
+	PartnerID     int            `json:"partner_id"`
+	ApplicationID sql.NullInt64  `json:"application_id,omitempty"`
+	FromStatus    sql.NullString `json:"from_status,omitempty"`
+	ToStatus      string         `json:"to_status"`
+	ActorType     string         `json:"actor_type"` // partner, admin
+	Note          sql.NullString `json:"note,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+// UpdateOnboardingRequest data pengajuan onboarding; field kosong = tidak diubah
+type UpdateOnboardingRequest struct {
+	OwnerName         string `json:"owner_name"`
+	OwnerNIK          string `json:"owner_nik"` // 16 digit
+	NIB               string `json:"nib"`       // 13 digit
+	BankName          string `json:"bank_name"`
+	BankAccountNumber string `json:"bank_account_number"`
+	BankAccountHolder string `json:"bank_account_holder"`
+}
+
+// ReviewOnboardingRequest catatan admin saat approve (opsional), reject (alasan), atau meminta info tambahan
+type ReviewOnboardingRequest struct {
+	Note string `json:"note"`
+}
+
+// ArgsOnboardingTransition perpindahan status pengajuan secara atomik beserta riwayatnya
+type ArgsOnboardingTransition struct {
+	ApplicationID int
+	FromStatuses  []string // Status saat ini yang diizinkan
+	ToStatus      string
+	ActorType     string
+	Note          string
+	XetorStatus   string // Jika diisi, status xetor_partners ikut diubah
+}
+
+// --- Structs untuk Staf Partner ---
+
+// Role staf partner
+const (
+	StaffRoleOwner   = "owner"   // Akses penuh; login memakai email & password partner
+	StaffRoleCashier = "cashier" // Hanya deposit & verifikasi QR
+	StaffRoleFinance = "finance" // Withdraw, topup, konversi, transfer
+)
+
+// PartnerStaff akun staf di bawah satu partner
+type PartnerStaff struct {
+	ID          int            `json:"id"`
+	PartnerID   int            `json:"partner_id"`
+	Name        string         `json:"name"`
+	Email       sql.NullString `json:"email,omitempty"` // Kosong untuk owner
+	Password    sql.NullString `json:"-"`
+	Role        string         `json:"role"`
+	IsActive    bool           `json:"is_active"`
+	LastLoginAt *time.Time     `json:"last_login_at,omitempty"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+// CreatePartnerStaffRequest data pembuatan akun staf oleh owner
+type CreatePartnerStaffRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=6"`
+	Role     string `json:"role" binding:"required,oneof=cashier finance"`
+}
+
+// UpdatePartnerStaffRequest perubahan nama, role, atau status aktif staf; field kosong = tidak diubah
+type UpdatePartnerStaffRequest struct {
+	Name     string `json:"name"`
+	Role     string `json:"role" binding:"omitempty,oneof=cashier finance"`
+	IsActive *bool  `json:"is_active"`
+}
+
+// ResetPartnerStaffPasswordRequest password baru staf yang diatur owner
+type ResetPartnerStaffPasswordRequest struct {
+	NewPassword string `json:"new_password" binding:"required,min=6"`
+}
+
+// --- Structs untuk Inventaris Sampah Partner ---
+
+// Jenis mutasi stok
+const (
+	InventoryMovementDeposit           = "deposit"            // Deposit user masuk
+	InventoryMovementDepositVoid       = "deposit_void"       // Deposit dibatalkan
+	InventoryMovementDepositCorrection = "deposit_correction" // Koreksi berat/jumlah deposit
+	InventoryMovementSale              = "sale"               // Dijual keluar
+	InventoryMovementDisposal          = "disposal"           // Dibuang / dimusnahkan
+	InventoryMovementAdjustment        = "adjustment"         // Penyesuaian manual (stock opname)
+)
+
+// InventoryItem stok satu jenis sampah beserta nilainya pada harga beli partner saat ini
+type InventoryItem struct {
+	WasteDetailID int            `json:"waste_detail_id"`
+	WasteName     sql.NullString `json:"waste_name,omitempty"`
+	Weight        string         `json:"weight"`   // kg, sbg string
+	Quantity      int            `json:"quantity"` // Hanya bermakna untuk item pcs
+	Unit          sql.NullString `json:"unit,omitempty"`
+	PricePerUnit  *float64       `json:"price_per_unit,omitempty"` // Kosong jika partner tidak lagi memasang harga
+	Value         float64        `json:"value"`                    // Rp
+	UpdatedAt     time.Time      `json:"updated_at"`
+}
+
+// InventorySummary stok partner saat ini beserta total valuasinya
+type InventorySummary struct {
+	Items       []InventoryItem `json:"items"`
+	TotalWeight string          `json:"total_weight"` // kg
+	TotalValue  float64         `json:"total_value"`  // Rp
+	ValuedAt    time.Time       `json:"valued_at"`
+}
+
+// InventoryMovement satu mutasi stok; perubahan negatif berarti stok keluar
+type InventoryMovement struct {
+	ID                      int            `json:"id"`
+	PartnerID               int            `json:"partner_id"`
+	WasteDetailID           int            `json:"waste_detail_id"`
+	WasteName               sql.NullString `json:"waste_name,omitempty"`
+	Type                    string         `json:"type"`
+	WeightChange            float64        `json:"weight_change"`
+	QuantityChange          int            `json:"quantity_change"`
+	PartnerDepositHistoryID sql.NullInt32  `json:"partner_deposit_history_id,omitempty"`
+	OfftakeSaleID           sql.NullInt32  `json:"offtake_sale_id,omitempty"` // Untuk mutasi penjualan ke recycler
+	Reason                  sql.NullString `json:"reason,omitempty"`
+	StaffID                 sql.NullInt64  `json:"staff_id,omitempty"`
+	StaffName               sql.NullString `json:"staff_name,omitempty"`
+	CreatedAt               time.Time      `json:"created_at"`
+}
+
+// InventoryOutboundRequest stok keluar karena dibuang. Penjualan dicatat lewat offtake agar pendapatannya tercatat.
+type InventoryOutboundRequest struct {
+	Type    string                  `json:"type" binding:"required"` // Hanya disposal
+	Reason  string                  `json:"reason"`
+	Items   []InventoryOutboundItem `json:"items" binding:"required,min=1,dive"`
+	StaffID int                     `json:"-"` // Diisi handler dari token staf
+}
+
+// InventoryOutboundItem jumlah yang keluar untuk satu jenis sampah
+type InventoryOutboundItem struct {
+	WasteDetailID int     `json:"waste_detail_id" binding:"required"`
+	Weight        float64 `json:"weight" binding:"gte=0"`   // kg
+	Quantity      int     `json:"quantity" binding:"gte=0"` // pcs
+}
+
+// InventoryAdjustmentRequest penyesuaian stok manual (misal hasil stock opname); nilai negatif mengurangi stok
+type InventoryAdjustmentRequest struct {
+	WasteDetailID  int     `json:"waste_detail_id" binding:"required"`
+	WeightChange   float64 `json:"weight_change"`
+	QuantityChange int     `json:"quantity_change"`
+	Reason         string  `json:"reason" binding:"required"`
+	StaffID        int     `json:"-"`
+}
+
+// --- Structs untuk Penjualan ke Recycler (Offtake) ---
+
+// OfftakeSale penjualan sampah terpilah partner ke recycler; TotalAmount dihitung sebagai pendapatan partner
+type OfftakeSale struct {
+	ID            int               `json:"id"`
+	PartnerID     int               `json:"partner_id"`
+	RecyclerID    int               `json:"recycler_id"`
+	RecyclerName  string            `json:"recycler_name"`
+	SaleDate      string            `json:"sale_date"` // "YYYY-MM-DD"
+	InvoiceNumber sql.NullString    `json:"invoice_number,omitempty"`
+	InvoicePhoto  sql.NullString    `json:"invoice_photo,omitempty"`
+	TotalWeight   string            `json:"total_weight"` // kg
+	TotalAmount   float64           `json:"total_amount"` // Rp
+	Notes         sql.NullString    `json:"notes,omitempty"`
+	StaffID       sql.NullInt64     `json:"staff_id,omitempty"`
+	StaffName     sql.NullString    `json:"staff_name,omitempty"`
+	Items         []OfftakeSaleItem `json:"items,omitempty"`
+	CreatedAt     time.Time         `json:"created_at"`
+}
+
+// OfftakeSaleItem satu baris penjualan per jenis sampah
+type OfftakeSaleItem struct {
+	ID            int            `json:"id"`
+	WasteDetailID int            `json:"waste_detail_id"`
+	WasteName     sql.NullString `json:"waste_name,omitempty"`
+	Weight        float64        `json:"weight"`   // kg
+	Quantity      int            `json:"quantity"` // pcs, hanya untuk item pcs
+	PricePerKg    float64        `json:"price_per_kg"`
+	Subtotal      float64        `json:"subtotal"`
+}
+
+// CreateOfftakeSaleRequest form-data pencatatan penjualan; foto invoice diambil manual di handler
+type CreateOfftakeSaleRequest struct {
+	RecyclerID    int                   `form:"recycler_id" binding:"required"`
+	SaleDate      string                `form:"sale_date"` // "YYYY-MM-DD", kosong = hari ini
+	InvoiceNumber string                `form:"invoice_number"`
+	Notes         string                `form:"notes"`
+	ItemsJSON     string                `form:"items_json" binding:"required"` // JSON string dari []OfftakeSaleItemInput
+	InvoicePhoto  *multipart.FileHeader `form:"-"`
+	StaffID       int                   `form:"-"` // Diisi handler dari token staf
+}
+
+// OfftakeSaleItemInput isi items_json
+type OfftakeSaleItemInput struct {
+	WasteDetailID int     `json:"waste_detail_id"`
+	Weight        float64 `json:"weight"`       // kg, wajib > 0
+	Quantity      int     `json:"quantity"`     // pcs, opsional untuk item pcs
+	PricePerKg    float64 `json:"price_per_kg"` // Harga jual ke recycler
+}
+
+// ArgsOfftakeSaleCreation data yang disimpan repo dalam satu transaksi bersama pengurangan stok
+type ArgsOfftakeSaleCreation struct {
+	Sale      OfftakeSale
+	Movements []InventoryMovement
+}
+
+// OfftakeMarginReport laporan pendapatan penjualan vs biaya beli sampah dari user dalam satu periode
+type OfftakeMarginReport struct {
+	From            string              `json:"from"`             // "YYYY-MM-DD"
+	To              string              `json:"to"`               // "YYYY-MM-DD", inklusif
+	Revenue         float64             `json:"revenue"`          // Rp dari penjualan ke recycler
+	SoldWeight      string              `json:"sold_weight"`      // kg
+	PurchaseCost    float64             `json:"purchase_cost"`    // Rp, Xpoin yang dibayar ke user x kurs Xpoin->Rp
+	PurchasedWeight string              `json:"purchased_weight"` // kg
+	GrossMargin     float64             `json:"gross_margin"`     // Revenue - PurchaseCost
+	MarginPercent   float64             `json:"margin_percent"`   // GrossMargin / Revenue x 100
+	SaleCount       int                 `json:"sale_count"`
+	Items           []OfftakeMarginItem `json:"items"`
+}
+
+// OfftakeMarginItem rincian laporan margin per jenis sampah
+type OfftakeMarginItem struct {
+	WasteDetailID         int            `json:"waste_detail_id"`
+	WasteName             sql.NullString `json:"waste_name,omitempty"`
+	SoldWeight            float64        `json:"sold_weight"`
+	Revenue               float64        `json:"revenue"`
+	AvgSalePricePerKg     float64        `json:"avg_sale_price_per_kg"`
+	PurchasedWeight       float64        `json:"purchased_weight"`
+	PurchaseXpoin         int            `json:"purchase_xpoin"`
+	PurchaseCost          float64        `json:"purchase_cost"`
+	AvgPurchasePricePerKg float64        `json:"avg_purchase_price_per_kg"`
+	MarginPerKg           float64        `json:"margin_per_kg"` // Harga jual rata-rata - harga beli rata-rata
+}
+
+// --- Structs untuk Import & Ekspor Harga Sampah ---
+
+// WastePriceFileColumns kolom file import/ekspor harga sampah; urutan bebas, dicocokkan dari baris header
+var WastePriceFileColumns = []string{"waste_detail_id", "name", "price", "unit", "action"}
+
+// Aksi per baris file import (kolom action); kosong berarti upsert
+const (
+	WastePriceImportUpsert = "upsert"
+	WastePriceImportDelete = "delete"
+)
+
+// Hasil validasi per baris import
+const (
+	WastePriceImportResultCreate    = "create"
+	WastePriceImportResultUpdate    = "update"
+	WastePriceImportResultDelete    = "delete"
+	WastePriceImportResultUnchanged = "unchanged"
+	WastePriceImportResultError     = "error"
+)
+
+// WastePriceImportRow hasil validasi satu baris file import
+type WastePriceImportRow struct {
+	Row           int    `json:"row"`                      // Nomor baris di file (header = 1)
+	Result        string `json:"result"`                   // create, update, delete, unchanged, error
+	WastePriceID  int    `json:"waste_price_id,omitempty"` // ID item harga yang cocok (update/delete)
+	WasteDetailID int    `json:"waste_detail_id,omitempty"`
+	Name          string `json:"name,omitempty"`
+	Price         string `json:"price,omitempty"`
+	OldPrice      string `json:"old_price,omitempty"`
+	Unit          string `json:"unit,omitempty"`
+	Xpoin         int    `json:"xpoin,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// WastePriceImportReport laporan import; perubahan hanya diterapkan jika tidak dry run dan tidak ada baris error
+type WastePriceImportReport struct {
+	DryRun    bool                  `json:"dry_run"`
+	Applied   bool                  `json:"applied"`
+	Format    string                `json:"format"`
+	TotalRows int                   `json:"total_rows"`
+	Created   int                   `json:"created"`
+	Updated   int                   `json:"updated"`
+	Deleted   int                   `json:"deleted"`
+	Unchanged int                   `json:"unchanged"`
+	Errors    int                   `json:"errors"`
+	Rows      []WastePriceImportRow `json:"rows"`
+}
+
+// WastePriceImportChanges perubahan yang disimpan repo dalam satu transaksi
+type WastePriceImportChanges struct {
+	HeaderID  int
+	Creates   []PartnerWastePriceDetail
+	Updates   []PartnerWastePriceDetail // Semua kolom (name, price, unit, xpoin, waste_detail_id) ditulis ulang
+	DeleteIDs []int
+}
@@ -1,7 +1,11 @@
 package partner
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,6 +14,7 @@ import (
 	"math"
 	"mime/multipart"
 	"os"
+	"path"
 	"path/filepath"
 	"regexp"
 	"sort"
@@ -20,9 +25,16 @@ import (
 	"golang.org/x/crypto/bcrypt"
 	"xetor.id/backend/internal/auth" // Import JWT generator
 	"xetor.id/backend/internal/config"
+	"xetor.id/backend/internal/domain/admin"
 	"xetor.id/backend/internal/domain/user"
+	"xetor.id/backend/internal/fraud"
+	"xetor.id/backend/internal/geo"
 	"xetor.id/backend/internal/notification"
+	"xetor.id/backend/internal/offline_qr"
+	"xetor.id/backend/internal/schedule"
+	"xetor.id/backend/internal/spreadsheet"
 	"xetor.id/backend/internal/temporary_token"
+	"xetor.id/backend/internal/thumbnail"
 )
 
 const conversionRateRpToXp = 1.0 / 5.0 // 1 Rp = 0.2 Xp
@@ -34,8 +46,40 @@ const (
 	minTopupAmount      = 10000.0
 )
 
+// offlineQrSyncGrace batas waktu sinkronisasi deposit setelah kode QR offline kedaluwarsa
+const offlineQrSyncGrace = 72 * time.Hour
+
+// maxDepositPhotos jumlah maksimal foto bukti per deposit (level deposit + semua item)
+const maxDepositPhotos = 10
+
+// batchDepositMaxAge batas umur transaksi offline yang masih boleh disinkronkan lewat batch
+const batchDepositMaxAge = 7 * 24 * time.Hour
+
+// Batas waktu hasil timbang digital: toleransi jam perangkat, umur maksimal saat dikirim ke server,
+// dan jarak maksimal antara waktu timbang dan waktu transaksi deposit yang memakainya
+const (
+	scaleReadingMaxClockSkew = 1 * time.Minute
+	scaleReadingMaxIngestAge = 15 * time.Minute
+	scaleReadingUseWindow    = 2 * time.Hour
+)
+
+// stationQrDefaultValidity masa berlaku default QR stasiun partner
+const stationQrDefaultValidity = 24 * time.Hour
+
+// depositDraftConfirmationWindow batas waktu user menerima/menolak draft deposit sebelum diterima otomatis
+const depositDraftConfirmationWindow = 24 * time.Hour
+
+// maxDepositDraftAttempts batas percobaan menjalankan draft yang gagal sebelum draft dinyatakan Failed
+const maxDepositDraftAttempts = 5
+
+// depositAdjustmentGracePeriod batas waktu partner membatalkan/mengoreksi deposit (admin tidak dibatasi)
+const depositAdjustmentGracePeriod = 24 * time.Hour
+
 type AdminRepositoryForPartner interface {
 	RecalculateAndUpdateWasteDetailXpoin(wasteDetailID int) error
+	GetAllWasteDetails() ([]admin.WasteDetail, error)
+	GetAllRecyclers(status string) ([]admin.Recycler, error)
+	GetRecyclerByID(id int) (*admin.Recycler, error)
 }
 
 // Definisikan interface repository yang dibutuhkan
@@ -53,13 +97,40 @@ type PartnerRepository interface {
 	GetBaseStatisticsByPartnerID(partnerID int) (*PartnerStatistic, error) // <-- Tambah/Ganti ini
 	CountUniqueCustomersByPartnerID(partnerID int) (int, error)
 
+	// Staf partner
+	GetPartnerStaffByID(staffID, partnerID int) (*PartnerStaff, error)
+	FindPartnerStaffByEmail(email string) (*PartnerStaff, error)
+	FindOrCreateOwnerStaff(partnerID int) (*PartnerStaff, error)
+	GetPartnerStaffByPartnerID(partnerID int) ([]PartnerStaff, error)
+	CreatePartnerStaff(st *PartnerStaff) error
+	UpdatePartnerStaff(st *PartnerStaff) error
+	UpdatePartnerStaffPassword(staffID, partnerID int, hashedPassword string) error
+	TouchPartnerStaffLogin(staffID int) error
+
+	// Inventaris sampah partner
+	GetInventory(partnerID int) ([]InventoryItem, error)
+	GetInventoryMovements(partnerID, wasteDetailID int, movementType string, limit, offset int) ([]InventoryMovement, error)
+	RecordInventoryMovements(movements []InventoryMovement) error
+
+	// Penjualan ke recycler (offtake)
+	CreateOfftakeSale(args *ArgsOfftakeSaleCreation) (int, error)
+	GetOfftakeSales(partnerID int, from, to string, recyclerID, limit, offset int) ([]OfftakeSale, error)
+	GetOfftakeSaleByID(saleID, partnerID int) (*OfftakeSale, error)
+	GetOfftakeRevenueByPartnerID(partnerID int) (float64, error)
+	GetOfftakeMarginItems(partnerID int, from, to time.Time) ([]OfftakeMarginItem, int, error)
+
 	// Alamat partner
 	GetAddressByPartnerID(partnerID int) (*PartnerAddress, error)
-	UpsertAddress(addr *PartnerAddress) error
+	UpsertAddress(addr *PartnerAddress, pickupEnabled sql.NullBool) error
+	UpdateServiceArea(partnerID int, req *UpdateServiceAreaRequest) error
 
 	// Jadwal operasional partner
 	GetScheduleByPartnerID(partnerID int) (*PartnerSchedule, error) // <-- Ganti nama & return type
 	UpsertSchedule(sched *PartnerSchedule) error
+	GetScheduleCalendar(partnerID int, from, to time.Time) (*schedule.Calendar, error)
+	GetScheduleExceptions(partnerID int, fromDate string) ([]schedule.Exception, error)
+	CreateScheduleException(partnerID int, ex *schedule.Exception) error
+	DeleteScheduleException(partnerID, exceptionID int) error
 
 	// Harga sampah partner
 	FindOrCreateWastePriceHeader(partnerID int) (int, error)
@@ -68,6 +139,11 @@ type PartnerRepository interface {
 	GetWastePriceDetailByID(detailID int, partnerID int) (*PartnerWastePriceDetail, error)
 	UpdateWastePriceDetail(detailID int, partnerID int, detail *PartnerWastePriceDetail) error
 	DeleteWastePriceDetail(detailID int, partnerID int) error
+	ApplyWastePriceImport(changes *WastePriceImportChanges) error
+	GetWastePriceVersions(detailID, partnerID int) ([]WastePriceVersion, error)
+	CreateWastePriceSchedule(detailID, partnerID int, price float64, unit string, xpoin int, effectiveAt time.Time, staffID sql.NullInt64) (int, error)
+	CancelWastePriceSchedule(versionID, detailID, partnerID int) error
+	ApplyDueWastePriceSchedules(now time.Time, limit int) ([]AppliedWastePriceVersion, error)
 
 	// Riwayat transaksi partner
 	GetWithdrawHistoryForPartner(partnerID int) ([]PartnerTransactionHistoryItem, error)
@@ -84,7 +160,7 @@ type PartnerRepository interface {
 	ExecutePartnerTopupTransaction(partnerID int, amountToAdd float64, paymentMethodID int) (string, error)
 
 	// Transfer Xpoin
-	ExecutePartnerTransferTransaction(senderPartnerID, amount int, recipientUserID *int, recipientPartnerID *int, recipientEmail string) (string, error)
+	ExecutePartnerTransferTransaction(senderPartnerID, amount int, recipientUserID *int, recipientPartnerID *int, recipientEmail string, staffID sql.NullInt64) (string, error)
 
 	// Conversion execution
 	ExecutePartnerConversionTransaction(partnerID int, xpoinChange int, balanceChange float64, conversionType string, amountXpInvolved int, amountRpInvolved float64, rate float64) (*PartnerWallet, error)
@@ -92,7 +168,96 @@ type PartnerRepository interface {
 	// Deposit execution
 	GetWastePriceInfoForCalculation(detailID int, partnerID int) (*WastePriceInfo, error) // Pastikan return type *WastePriceInfo (dari model)
 	ExecuteDepositCreationTransaction(args ArgsDepositCreation) (int, error)
+	FindDepositByClientID(partnerID int, clientID string) (*DepositHistoryHeader, error)
+	GetDepositPhotos(depositID int) ([]user.DepositPhoto, error)
+	AddDepositPhotos(depositID int, photos []user.DepositPhoto) error
 	UpdateUserDepositHistoryIDReference(partnerDepositHistoryID int, userDepositHistoryID int) error
+
+	// Sesi deposit (check-in user via QR stasiun)
+	GetDepositSessionsByPartnerID(partnerID int, status string) ([]user.DepositSession, error)
+	GetDepositSessionByIDForPartner(sessionID, partnerID int) (*user.DepositSession, error)
+	UpdateDepositSessionItems(sessionID, partnerID int, items []user.DepositSessionItem, totalWeight float64, estimatedXpoin int) error
+	UpdateDepositSessionStatus(sessionID, partnerID int, fromStatus, toStatus string) error
+	SetDepositSessionDepositHeader(sessionID, partnerDepositHistoryID int) error
+	CancelDepositSessionByPartner(sessionID, partnerID int) error
+
+	// Draft deposit dua tahap (user menerima/menolak hasil timbang)
+	CreateDepositDraft(draft *user.DepositDraft, totalWeight float64, consume func(tx *sql.Tx) error) error
+	GetDepositDraftsByPartnerID(partnerID int, status string) ([]user.DepositDraft, error)
+	GetDepositDraftByID(draftID int) (*user.DepositDraft, error)
+	AcceptDepositDraft(draftID int, decidedBy string) error
+	RevertFailedDepositDraft(draftID int, maxAttempts int) (string, error)
+	FailDepositDraft(draftID int, decidedBy string) error
+	SetDepositDraftDepositHeader(draftID, partnerDepositHistoryID int) error
+	UpdateDepositDraftStatus(draftID int, fromStatus, toStatus string) error
+	GetExpiredPendingDepositDrafts(now time.Time) ([]user.DepositDraft, error)
+
+	// Void & koreksi deposit
+	GetDepositForAdjustment(depositID int) (*DepositAdjustmentTarget, error)
+	ExecuteDepositAdjustmentTransaction(args ArgsDepositAdjustment) error
+	GetDepositAdjustments(depositID int) ([]DepositAdjustment, error)
+
+	// Sengketa deposit (tanggapan partner & keputusan admin)
+	GetDepositDisputesByPartnerID(partnerID int, status string) ([]user.DepositDispute, error)
+	GetDepositDisputeByID(disputeID int) (*user.DepositDispute, error)
+	RespondDepositDispute(disputeID, partnerID int, response string) error
+	GetDepositDisputesForAdmin(status string) ([]user.DepositDispute, error)
+	ResolveDepositDispute(disputeID int, status, note string, adjustmentType sql.NullString) error
+	FindUnresolvedDepositDisputeID(depositID int) (int, error)
+	RevertDepositDisputeResolution(disputeID int, previousStatus string) error
+	EscalateOverdueDepositDisputes(now time.Time) ([]user.DepositDispute, error)
+
+	// Ulasan partner (balasan partner & moderasi admin)
+	GetPartnerReviewsByPartnerID(partnerID int, status string) ([]user.PartnerReview, error)
+	GetPartnerReviewByID(reviewID int) (*user.PartnerReview, error)
+	ReplyPartnerReview(reviewID, partnerID int, reply string) error
+	GetPartnerReviewsForAdmin(status string, partnerID int) ([]user.PartnerReview, error)
+	ModeratePartnerReview(reviewID int, status, note string) error
+
+	// Onboarding (KYC) partner
+	GetOnboardingApplicationByPartnerID(partnerID int) (*OnboardingApplication, error)
+	GetOnboardingApplicationByID(applicationID int) (*OnboardingApplication, error)
+	CreateOnboardingApplication(partnerID int) (*OnboardingApplication, error)
+	UpdateOnboardingApplicationData(app *OnboardingApplication) error
+	GetOnboardingApplications(status string) ([]OnboardingApplication, error)
+	GetOnboardingDocuments(applicationID int) ([]OnboardingDocument, error)
+	AddOnboardingDocument(doc *OnboardingDocument, replaceType bool) error
+	DeleteOnboardingDocument(applicationID, documentID int) error
+	TransitionOnboardingApplication(args ArgsOnboardingTransition) error
+	GetPartnerStatusHistory(partnerID int) ([]PartnerStatusHistory, error)
+
+	// Permintaan jemput sampah
+	GetAvailablePickupRequests(partnerID int, radiusKm float64) ([]user.PickupRequest, error)
+	GetPickupRequestsByPartnerID(partnerID int, status string) ([]user.PickupRequest, error)
+	GetPickupRequestByID(pickupID int) (*user.PickupRequest, error)
+	AcceptPickupRequest(pickupID, partnerID int) error
+	UpdatePickupRequestStatus(pickupID, partnerID int, fromStatus, toStatus string) error
+	ReleasePickupRequest(pickupID, partnerID int) error
+	SetPickupRequestDepositHeader(pickupID, partnerDepositHistoryID int) error
+
+	// Timbangan digital
+	CreateScaleDevice(device *ScaleDevice) error
+	GetScaleDevicesByPartnerID(partnerID int) ([]ScaleDevice, error)
+	RevokeScaleDevice(deviceRowID, partnerID int) error
+	FindScaleDeviceByDeviceID(deviceID string) (*ScaleDevice, error)
+	CreateScaleReading(device *ScaleDevice, deviceReadingID string, weight float64, measuredAt time.Time) (*ScaleReading, error)
+	GetScaleReadingsByPartnerID(partnerID int, since time.Time, unusedOnly bool) ([]ScaleReading, error)
+	GetScaleReadingForDeposit(readingID int) (*ScaleReadingInfo, error)
+	GetScaleDevicesForAdmin(certified *bool) ([]ScaleDevice, error)
+	CertifyScaleDevice(deviceRowID int, certified bool, certificationNumber string) error
+	GetScaleUsageByPartner() ([]ScalePartnerUsage, error)
+
+	// Deteksi fraud deposit
+	GetFraudRules() ([]fraud.Rule, error)
+	UpdateFraudRule(rule *fraud.Rule) error
+	GetDepositFraudSignals(partnerID, userID int, dayStart, dayEnd, identicalSince, frequencySince time.Time) (*DepositFraudSignals, error)
+	CreateDepositFraudCase(fc *DepositFraudCase, totalWeight float64, consume func(tx *sql.Tx) error) error
+	GetDepositFraudCases(status string) ([]DepositFraudCase, error)
+	GetDepositFraudCaseByID(caseID int) (*DepositFraudCase, error)
+	FindDepositFraudCaseByClientID(partnerID int, clientID string) (*DepositFraudCase, error)
+	UpdateDepositFraudCaseStatus(caseID int, fromStatus, toStatus string, note sql.NullString) error
+	SetDepositFraudCaseDepositHeader(caseID, partnerDepositHistoryID int) error
+	ReleaseDepositFraudCaseScaleReadings(caseID int) error
 }
 
 type UserRepositoryForPartner interface {
@@ -110,13 +275,21 @@ type UserRepositoryForPartner interface {
 type PartnerService struct {
 	repo         PartnerRepository
 	userRepo     UserRepositoryForPartner
-	tokenStore   *temporary_token.TokenStore
+	tokenStore   temporary_token.TokenStore
+	offlineQr    *offline_qr.Signer
 	adminRepo    AdminRepositoryForPartner
 	notifService *notification.NotificationService
 }
 
-func NewPartnerService(repo PartnerRepository, userRepo UserRepositoryForPartner, tokenStore *temporary_token.TokenStore, adminRepo AdminRepositoryForPartner, notifService *notification.NotificationService) *PartnerService {
-	return &PartnerService{repo: repo, userRepo: userRepo, tokenStore: tokenStore, adminRepo: adminRepo, notifService: notifService}
+func NewPartnerService(repo PartnerRepository, userRepo UserRepositoryForPartner, tokenStore temporary_token.TokenStore, offlineQr *offline_qr.Signer, adminRepo AdminRepositoryForPartner, notifService *notification.NotificationService) *PartnerService {
+	s := &PartnerService{repo: repo, userRepo: userRepo, tokenStore: tokenStore, offlineQr: offlineQr, adminRepo: adminRepo, notifService: notifService}
+	// Terima otomatis draft deposit yang tidak dijawab user sampai batas waktu
+	go s.autoAcceptExpiredDepositDrafts(1 * time.Minute)
+	// Eskalasi sengketa deposit yang tidak ditanggapi partner ke antrean admin
+	go s.escalateOverdueDepositDisputes(5 * time.Minute)
+	// Terapkan jadwal perubahan harga sampah yang sudah jatuh tempo
+	go s.applyDueWastePriceSchedules(1 * time.Minute)
+	return s
 }
 
 // RegisterPartner memproses registrasi partner baru
@@ -129,6 +302,13 @@ func (s *PartnerService) RegisterPartner(req PartnerSignUpRequest) (*Partner, er
 	if existingPartner != nil {
 		return nil, errors.New("email sudah terdaftar")
 	}
+	existingStaff, err := s.repo.FindPartnerStaffByEmail(req.Email)
+	if err != nil {
+		return nil, errors.New("gagal memeriksa email")
+	}
+	if existingStaff != nil {
+		return nil, errors.New("email sudah terdaftar")
+	}
 	// TODO: Cek duplikasi nomor telepon jika perlu
 
 	partner := &Partner{
@@ -148,44 +328,62 @@ func (s *PartnerService) RegisterPartner(req PartnerSignUpRequest) (*Partner, er
 	return partner, nil
 }
 
-// LoginPartner memvalidasi login partner dan membuat token
-func (s *PartnerService) LoginPartner(req PartnerLoginRequest) (string, string, error) {
-	// 1. Cari partner berdasarkan email
+// LoginPartner memvalidasi login owner (email partner) atau staf (email staf) dan membuat token
+// yang membawa ID partner sekaligus ID staf
+func (s *PartnerService) LoginPartner(req PartnerLoginRequest) (*PartnerLoginResponse, error) {
+	// 1. Cari partner berdasarkan email; jika tidak ada, cari akun staf
+	var staff *PartnerStaff
 	partner, err := s.repo.FindPartnerByEmail(req.Email)
 	if err != nil {
-		return "", "", errors.New("gagal mencari partner") // Kembalikan string kosong untuk token & status
-	}
-	if partner == nil {
-		return "", "", errors.New("kredensial tidak valid")
+		return nil, errors.New("gagal mencari partner")
 	}
-
-	// 2. Bandingkan password
-	err = bcrypt.CompareHashAndPassword([]byte(partner.Password), []byte(req.Password))
-	if err != nil {
-		return "", "", errors.New("kredensial tidak valid")
+	if partner != nil {
+		// 2. Bandingkan password owner
+		if err := bcrypt.CompareHashAndPassword([]byte(partner.Password), []byte(req.Password)); err != nil {
+			return nil, errors.New("kredensial tidak valid")
+		}
+		staff, err = s.repo.FindOrCreateOwnerStaff(partner.ID)
+		if err != nil || staff == nil {
+			return nil, errors.New("gagal mengambil akun owner")
+		}
+	} else {
+		staff, err = s.repo.FindPartnerStaffByEmail(req.Email)
+		if err != nil {
+			return nil, errors.New("gagal mencari partner")
+		}
+		if staff == nil || !staff.Password.Valid {
+			return nil, errors.New("kredensial tidak valid")
+		}
+		if err := bcrypt.CompareHashAndPassword([]byte(staff.Password.String), []byte(req.Password)); err != nil {
+			return nil, errors.New("kredensial tidak valid")
+		}
+		if !staff.IsActive {
+			return nil, errors.New("kredensial tidak valid")
+		}
 	}
 
 	// 3. Cek status approval
-	status, err := s.repo.FindXetorPartnerStatusByID(partner.ID)
+	status, err := s.repo.FindXetorPartnerStatusByID(staff.PartnerID)
 	if err != nil {
 		if err != sql.ErrNoRows && status != "Not Registered" {
-			log.Printf("Error checking partner status for ID %d: %v", partner.ID, err)
-			return "", "", errors.New("gagal memeriksa status partner")
+			log.Printf("Error checking partner status for ID %d: %v", staff.PartnerID, err)
+			return nil, errors.New("gagal memeriksa status partner")
 		}
 		if status == "" {
 			status = "Not Registered"
 		}
 	}
 
-	// 4. Buat token JWT
-	token, err := auth.GenerateToken(partner.ID, "partner")
+	// 4. Buat token JWT (Subject = ID partner, staff_id = staf yang login)
+	token, err := auth.GeneratePartnerStaffToken(staff.PartnerID, staff.ID)
 	if err != nil {
-		log.Printf("Error generating token for partner ID %d: %v", partner.ID, err)
-		return "", "", errors.New("gagal membuat sesi login")
+		log.Printf("Error generating token for partner ID %d, staff ID %d: %v", staff.PartnerID, staff.ID, err)
+		return nil, errors.New("gagal membuat sesi login")
 	}
+	s.repo.TouchPartnerStaffLogin(staff.ID)
 
-	// 5. Kembalikan HANYA token dan status aktual
-	return token, status, nil
+	// 5. Kembalikan token, status aktual, dan role staf
+	return &PartnerLoginResponse{Token: token, Status: status, StaffID: staff.ID, StaffRole: staff.Role}, nil
 }
 
 func (s *PartnerService) GetProfile(partnerIDStr string) (*Partner, error) {
@@ -380,40 +578,113 @@ func (s *PartnerService) UpdateAddress(partnerIDStr string, req UpdatePartnerAdd
 		addr.Longitude = sql.NullFloat64{Valid: true, Float64: *req.Longitude}
 	}
 
-	err = s.repo.UpsertAddress(addr)
+	// pickup_enabled hanya diubah jika dikirim; penjemputan butuh titik lokasi untuk pencocokan jarak
+	if req.PickupEnabled != nil && *req.PickupEnabled && (req.Latitude == nil || req.Longitude == nil) {
+		return nil, errors.New("latitude dan longitude wajib diisi untuk mengaktifkan penjemputan")
+	}
+	pickupEnabled := sql.NullBool{}
+	if req.PickupEnabled != nil {
+		pickupEnabled = sql.NullBool{Bool: *req.PickupEnabled, Valid: true}
+	}
+
+	err = s.repo.UpsertAddress(addr, pickupEnabled)
 	if err != nil {
 		return nil, err
 	} // Repo sudah handle error
 
-	// Kembalikan data alamat yang baru disimpan (termasuk ID dan timestamp)
-	return addr, nil
+	// Ambil ulang agar pengaturan area layanan ikut dikembalikan
+	saved, err := s.repo.GetAddressByPartnerID(partnerID)
+	if err != nil || saved == nil {
+		return addr, nil
+	}
+	return saved, nil
+}
+
+// GetServiceArea mengambil pengaturan area & kapasitas layanan jemput (bagian dari alamat usaha)
+func (s *PartnerService) GetServiceArea(partnerIDStr string) (*PartnerAddress, error) {
+	return s.GetAddress(partnerIDStr)
+}
+
+// UpdateServiceArea memperbarui area layanan, kapasitas harian dan berat minimal jemput partner
+func (s *PartnerService) UpdateServiceArea(partnerIDStr string, req UpdateServiceAreaRequest) (*PartnerAddress, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+	if len(req.ServiceAreaPolygon) > 0 && len(req.ServiceAreaPolygon) < 3 {
+		return nil, errors.New("poligon area layanan minimal 3 titik")
+	}
+	for _, v := range req.ServiceAreaPolygon {
+		if v.Latitude < -90 || v.Latitude > 90 || v.Longitude < -180 || v.Longitude > 180 {
+			return nil, errors.New("koordinat poligon area layanan tidak valid")
+		}
+	}
+
+	addr, err := s.repo.GetAddressByPartnerID(partnerID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil alamat usaha")
+	}
+	if addr == nil {
+		return nil, errors.New("alamat usaha belum diisi")
+	}
+	if req.PickupEnabled && (!addr.Latitude.Valid || !addr.Longitude.Valid) {
+		return nil, errors.New("latitude dan longitude wajib diisi untuk mengaktifkan penjemputan")
+	}
+
+	err = s.repo.UpdateServiceArea(partnerID, &req)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("alamat usaha belum diisi")
+	}
+	if err != nil {
+		return nil, errors.New("gagal menyimpan area layanan")
+	}
+	return s.GetAddress(partnerIDStr)
 }
 
 // --- Partner Schedule Service Methods ---
 
-// GetSchedule mengambil jadwal operasional partner (single row)
+// GetSchedule mengambil jadwal operasional partner beserta pengecualian mendatang dan status buka saat ini
 func (s *PartnerService) GetSchedule(partnerIDStr string) (*PartnerSchedule, error) {
 	partnerID, err := strconv.Atoi(partnerIDStr)
 	if err != nil {
 		return nil, errors.New("ID partner tidak valid")
 	}
 
-	schedule, err := s.repo.GetScheduleByPartnerID(partnerID)
+	sched, err := s.repo.GetScheduleByPartnerID(partnerID)
 	if err != nil {
 		return nil, errors.New("gagal mengambil jadwal operasional")
 	}
 
 	// Jika belum ada jadwal, kembalikan struct kosong tapi valid, bukan nil
-	if schedule == nil {
-		return &PartnerSchedule{PartnerID: partnerID, DaysOpen: []string{}, OperatingStatus: "Closed"}, nil
+	if sched == nil {
+		return &PartnerSchedule{PartnerID: partnerID, OperatingStatus: "Closed", Timezone: schedule.DefaultTimezone,
+			ObserveNationalHolidays: true, Intervals: []schedule.Interval{}, Exceptions: []schedule.Exception{}}, nil
+	}
+
+	now := time.Now()
+	today := now.In(schedule.Location(sched.Timezone)).Format(schedule.DateLayout)
+	sched.Exceptions, err = s.repo.GetScheduleExceptions(partnerID, today)
+	if err != nil {
+		return nil, errors.New("gagal mengambil pengecualian jadwal")
+	}
+
+	from, to := schedule.Window(now)
+	cal, err := s.repo.GetScheduleCalendar(partnerID, from, to)
+	if err != nil {
+		return nil, errors.New("gagal menghitung status buka")
+	}
+	if cal != nil {
+		sched.IsOpenNow = cal.IsOpenAt(now)
+		sched.NextOpenAt = cal.NextOpenAt(now)
 	}
-	return schedule, nil
+	return sched, nil
 }
 
-// UpdateSchedule membuat/memperbarui jadwal operasional partner (single row)
+// UpdateSchedule membuat/memperbarui jadwal operasional partner. Interval yang dikirim menggantikan seluruh interval lama.
 func (s *PartnerService) UpdateSchedule(partnerIDStr string, req UpdateScheduleRequest) (*PartnerSchedule, error) {
 	partnerID, err := strconv.Atoi(partnerIDStr)
-	if err != nil { /* ... */
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
 	}
 
 	// 1. Ambil jadwal yang ada SEKARANG (jika ada)
@@ -423,72 +694,101 @@ func (s *PartnerService) UpdateSchedule(partnerIDStr string, req UpdateScheduleR
 	}
 	// Jika belum ada, buat struct default
 	if currentSchedule == nil {
-		currentSchedule = &PartnerSchedule{PartnerID: partnerID, OperatingStatus: "Closed"} // Default jika belum ada
+		currentSchedule = &PartnerSchedule{PartnerID: partnerID, OperatingStatus: "Closed", Timezone: schedule.DefaultTimezone,
+			ObserveNationalHolidays: true, Intervals: []schedule.Interval{}}
 	}
 
 	// 2. Validasi input & tentukan nilai baru (gunakan nilai lama jika request kosong)
-	timeRegex := regexp.MustCompile(`^([01]\d|2[0-3]):([0-5]\d)$`)
 	validStatuses := map[string]bool{"Open": true, "Closed": true}
 
-	finalDaysOpen := currentSchedule.DaysOpen // Default: pakai yg lama
-	if len(req.DaysOpen) > 0 {                // Hanya proses jika DaysOpen dikirim
-		// Validasi hari (ambil dari kode sebelumnya)
-		validDays := map[string]bool{"Senin": true, "Selasa": true, "Rabu": true, "Kamis": true, "Jumat": true, "Sabtu": true, "Minggu": true}
-		uniqueDays := make(map[string]bool)
-		validDaysList := []string{}
-		for _, day := range req.DaysOpen {
-			trimmedDay := strings.TrimSpace(day)
-			if !validDays[trimmedDay] {
-				return nil, fmt.Errorf("nama hari tidak valid: %s", trimmedDay)
-			}
-			if !uniqueDays[trimmedDay] {
-				uniqueDays[trimmedDay] = true
-				validDaysList = append(validDaysList, trimmedDay)
-			}
+	if req.OperatingStatus != "" {
+		if !validStatuses[req.OperatingStatus] {
+			return nil, errors.New("status operasional tidak valid (Open/Closed)")
 		}
-		finalDaysOpen = validDaysList
+		currentSchedule.OperatingStatus = req.OperatingStatus
 	}
 
-	finalOpenTime := currentSchedule.OpenTime // Default: pakai yg lama
-	if req.OpenTime != "" {
-		if !timeRegex.MatchString(req.OpenTime) {
-			return nil, errors.New("format jam buka tidak valid (HH:MM)")
+	if req.Timezone != "" {
+		if !schedule.ValidTimezone(req.Timezone) {
+			return nil, errors.New("zona waktu tidak valid (Asia/Jakarta, Asia/Pontianak, Asia/Makassar, Asia/Jayapura)")
 		}
-		finalOpenTime = req.OpenTime
+		currentSchedule.Timezone = req.Timezone
 	}
 
-	finalCloseTime := currentSchedule.CloseTime // Default: pakai yg lama
-	if req.CloseTime != "" {
-		if !timeRegex.MatchString(req.CloseTime) {
-			return nil, errors.New("format jam tutup tidak valid (HH:MM)")
-		}
-		finalCloseTime = req.CloseTime
+	if req.ObserveNationalHolidays != nil {
+		currentSchedule.ObserveNationalHolidays = *req.ObserveNationalHolidays
 	}
 
-	finalOpStatus := currentSchedule.OperatingStatus // Default: pakai yg lama
-	if req.OperatingStatus != "" {
-		if !validStatuses[req.OperatingStatus] {
-			return nil, errors.New("status operasional tidak valid (Open/Closed)")
+	if req.Intervals != nil {
+		if err := schedule.ValidateIntervals(*req.Intervals); err != nil {
+			return nil, err
 		}
-		finalOpStatus = req.OperatingStatus
+		currentSchedule.Intervals = *req.Intervals
 	}
 
-	// Siapkan data final untuk disimpan
-	schedToSave := &PartnerSchedule{
-		PartnerID:       partnerID,
-		DaysOpen:        finalDaysOpen,
-		OpenTime:        finalOpenTime,
-		CloseTime:       finalCloseTime,
-		OperatingStatus: finalOpStatus,
+	// 3. Simpan (upsert header + ganti interval)
+	if err := s.repo.UpsertSchedule(currentSchedule); err != nil {
+		return nil, err
+	}
+
+	return s.GetSchedule(partnerIDStr)
+}
+
+// CreateScheduleException menambah pengecualian jadwal (tutup seharian atau jam khusus) untuk tanggal tertentu
+func (s *PartnerService) CreateScheduleException(partnerIDStr string, req CreateScheduleExceptionRequest) (*schedule.Exception, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
 	}
 
-	// Panggil repo untuk upsert
-	err = s.repo.UpsertSchedule(schedToSave)
+	sched, err := s.repo.GetScheduleByPartnerID(partnerID)
 	if err != nil {
+		return nil, errors.New("gagal mengambil jadwal saat ini")
+	}
+	if sched == nil {
+		return nil, errors.New("jadwal operasional belum diatur")
+	}
+
+	ex := schedule.Exception{
+		Date:      strings.TrimSpace(req.Date),
+		Closed:    req.Closed,
+		OpenTime:  req.OpenTime,
+		CloseTime: req.CloseTime,
+		Reason:    strings.TrimSpace(req.Reason),
+	}
+	if err := schedule.ValidateException(ex); err != nil {
+		return nil, err
+	}
+	if len(ex.Reason) > 255 {
+		return nil, errors.New("alasan pengecualian terlalu panjang (maks 255 karakter)")
+	}
+	today := time.Now().In(schedule.Location(sched.Timezone)).Format(schedule.DateLayout)
+	if ex.Date < today {
+		return nil, errors.New("tanggal pengecualian tidak valid (sudah lewat)")
+	}
+	if ex.Closed {
+		ex.OpenTime, ex.CloseTime = "", ""
+	}
+
+	if err := s.repo.CreateScheduleException(partnerID, &ex); err != nil {
 		return nil, err
 	}
+	return &ex, nil
+}
 
-	return schedToSave, nil
+// DeleteScheduleException menghapus pengecualian jadwal milik partner
+func (s *PartnerService) DeleteScheduleException(partnerIDStr string, exceptionID int) error {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return errors.New("ID partner tidak valid")
+	}
+	if err := s.repo.DeleteScheduleException(partnerID, exceptionID); err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("pengecualian jadwal tidak ditemukan")
+		}
+		return errors.New("gagal menghapus pengecualian jadwal")
+	}
+	return nil
 }
 
 // --- Partner Waste Price Service Methods ---
@@ -562,7 +862,10 @@ func (s *PartnerService) CreateWastePrice(partnerIDStr string, req WastePriceReq
 	if req.Price <= 0 {
 		return nil, errors.New("harga harus positif")
 	}
-	// TODO: Validasi unit?
+	unit, ok := NormalizeWasteUnit(req.Unit)
+	if !ok {
+		return nil, errors.New("satuan harga tidak valid, gunakan kg atau pcs")
+	}
 
 	// Dapatkan atau buat header
 	headerID, err := s.repo.FindOrCreateWastePriceHeader(partnerID)
@@ -576,7 +879,7 @@ func (s *PartnerService) CreateWastePrice(partnerIDStr string, req WastePriceReq
 		WasteDetailID:       sql.NullInt32{Int32: int32(req.WasteDetailID), Valid: true}, // Set WasteDetailID
 		Name:                req.Name,
 		Price:               fmt.Sprintf("%.2f", req.Price),
-		Unit:                req.Unit,
+		Unit:                unit,
 		Xpoin:               xpoin,
 	}
 
@@ -666,7 +969,11 @@ func (s *PartnerService) UpdateWastePrice(detailID int, partnerIDStr string, req
 		needsUpdate = true
 	}
 	if req.Unit != "" {
-		updateData.Unit = req.Unit
+		unit, ok := NormalizeWasteUnit(req.Unit)
+		if !ok {
+			return nil, errors.New("satuan harga tidak valid, gunakan kg atau pcs")
+		}
+		updateData.Unit = unit
 		needsUpdate = true
 	}
 	if req.WasteDetailID != nil {
@@ -771,158 +1078,629 @@ func (s *PartnerService) DeleteWastePrice(detailID int, partnerIDStr string) err
 	return nil
 }
 
-// --- Partner Financial Transaction History Service ---
+// --- Riwayat & Jadwal Harga Sampah ---
 
-// GetFinancialTransactionHistory menggabungkan riwayat withdraw, topup, convert, transfer
-func (s *PartnerService) GetFinancialTransactionHistory(partnerIDStr string) ([]PartnerTransactionHistoryItem, error) {
+// Batas jadwal perubahan harga
+const (
+	minWastePriceScheduleLead = 5 * time.Minute // Jadwal minimal sekian menit ke depan
+	maxWastePriceScheduleDays = 180             // Jadwal maksimal sekian hari ke depan
+	wastePriceScheduleBatch   = 100             // Jumlah jadwal yang diterapkan per putaran
+)
+
+// GetWastePriceHistory mengambil riwayat versi dan jadwal harga satu item harga milik partner
+func (s *PartnerService) GetWastePriceHistory(detailID int, partnerIDStr string) ([]WastePriceVersion, error) {
 	partnerID, err := strconv.Atoi(partnerIDStr)
 	if err != nil {
 		return nil, errors.New("ID partner tidak valid")
 	}
-
-	allTransactions := make([]PartnerTransactionHistoryItem, 0)
-
-	// Ambil data dari masing-masing tabel (handle error per jenis)
-	withdrawHistory, errW := s.repo.GetWithdrawHistoryForPartner(partnerID)
-	if errW != nil {
-		log.Printf("Error getting withdraw history for partner %d: %v", partnerID, errW)
-	}
-	allTransactions = append(allTransactions, withdrawHistory...)
-
-	topupHistory, errT := s.repo.GetTopupHistoryForPartner(partnerID)
-	if errT != nil {
-		log.Printf("Error getting topup history for partner %d: %v", partnerID, errT)
+	detail, err := s.repo.GetWastePriceDetailByID(detailID, partnerID)
+	if err != nil {
+		return nil, errors.New("gagal memeriksa detail harga sampah")
 	}
-	allTransactions = append(allTransactions, topupHistory...)
-
-	convertHistory, errC := s.repo.GetConversionHistoryForPartner(partnerID)
-	if errC != nil {
-		log.Printf("Error getting conversion history for partner %d: %v", partnerID, errC)
+	if detail == nil {
+		return nil, errors.New("detail harga sampah tidak ditemukan atau bukan milik Anda")
 	}
-	allTransactions = append(allTransactions, convertHistory...)
-
-	transferHistory, errTr := s.repo.GetTransferHistoryForPartner(partnerID)
-	if errTr != nil {
-		log.Printf("Error getting transfer history for partner %d: %v", partnerID, errTr)
+	versions, err := s.repo.GetWastePriceVersions(detailID, partnerID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil riwayat harga sampah")
 	}
-	allTransactions = append(allTransactions, transferHistory...)
-
-	// Urutkan semua transaksi berdasarkan waktu (terbaru dulu)
-	sort.SliceStable(allTransactions, func(i, j int) bool {
-		return allTransactions[i].Timestamp.After(allTransactions[j].Timestamp)
-	})
-
-	return allTransactions, nil // Tidak return error utama jika salah satu gagal
+	return versions, nil
 }
 
-// --- Partner Deposit History Service ---
-
-// GetDepositHistory mengambil riwayat setoran sampah partner
-func (s *PartnerService) GetDepositHistory(partnerIDStr string) ([]DepositHistoryHeader, error) {
+// ScheduleWastePrice menjadwalkan harga baru yang otomatis berlaku pada waktu effective_at
+func (s *PartnerService) ScheduleWastePrice(detailID int, partnerIDStr string, req ScheduleWastePriceRequest) (*WastePriceVersion, error) {
 	partnerID, err := strconv.Atoi(partnerIDStr)
 	if err != nil {
 		return nil, errors.New("ID partner tidak valid")
 	}
+	if req.Price <= 0 {
+		return nil, errors.New("harga harus positif")
+	}
+	now := time.Now()
+	if req.EffectiveAt.Before(now.Add(minWastePriceScheduleLead)) {
+		return nil, fmt.Errorf("waktu berlaku tidak valid: minimal %d menit dari sekarang", int(minWastePriceScheduleLead.Minutes()))
+	}
+	if req.EffectiveAt.After(now.AddDate(0, 0, maxWastePriceScheduleDays)) {
+		return nil, fmt.Errorf("waktu berlaku tidak valid: maksimal %d hari ke depan", maxWastePriceScheduleDays)
+	}
 
-	history, err := s.repo.GetDepositHistoryByPartnerID(partnerID)
+	detail, err := s.repo.GetWastePriceDetailByID(detailID, partnerID)
 	if err != nil {
-		return nil, errors.New("gagal mengambil riwayat deposit")
+		return nil, errors.New("gagal memeriksa detail harga sampah")
+	}
+	if detail == nil {
+		return nil, errors.New("detail harga sampah tidak ditemukan atau bukan milik Anda")
+	}
+	unit := detail.Unit
+	if req.Unit != "" {
+		normalized, ok := NormalizeWasteUnit(req.Unit)
+		if !ok {
+			return nil, errors.New("satuan harga tidak valid, gunakan kg atau pcs")
+		}
+		unit = normalized
 	}
 
-	// Repo sudah handle jika kosong (return array kosong)
-	return history, nil
-}
-
-// DeleteAccount menghapus akun partner
-func (s *PartnerService) DeleteAccount(partnerIDStr string) error {
-	partnerID, err := strconv.Atoi(partnerIDStr)
+	versionID, err := s.repo.CreateWastePriceSchedule(detailID, partnerID, req.Price, unit, calculateXpoin(req.Price),
+		req.EffectiveAt, staffIDArg(req.StaffID))
 	if err != nil {
-		return errors.New("ID partner tidak valid")
+		return nil, err
+	}
+	if versionID == 0 {
+		return nil, errors.New("detail harga sampah tidak ditemukan atau bukan milik Anda")
 	}
 
-	err = s.repo.DeletePartnerByID(partnerID)
+	versions, err := s.repo.GetWastePriceVersions(detailID, partnerID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return errors.New("partner tidak ditemukan")
+		return nil, errors.New("gagal mengambil jadwal harga")
+	}
+	for i := range versions {
+		if versions[i].ID == versionID {
+			return &versions[i], nil
 		}
-		return err // Error teknis repo
 	}
-	return nil // Sukses
+	return nil, errors.New("jadwal harga tidak ditemukan")
 }
 
-// --- Partner Wallet Service Method ---
-
-// GetPartnerWallet mengambil data wallet partner (membuat jika belum ada)
-func (s *PartnerService) GetPartnerWallet(partnerIDStr string) (*PartnerWallet, error) {
+// CancelWastePriceSchedule membatalkan jadwal harga yang belum berlaku
+func (s *PartnerService) CancelWastePriceSchedule(versionID, detailID int, partnerIDStr string) error {
 	partnerID, err := strconv.Atoi(partnerIDStr)
 	if err != nil {
-		return nil, errors.New("ID partner tidak valid")
+		return errors.New("ID partner tidak valid")
 	}
-
-	wallet, err := s.repo.FindOrCreateWalletByPartnerID(partnerID)
-	if err != nil {
-		return nil, errors.New("gagal mengambil atau membuat wallet partner")
+	if err := s.repo.CancelWastePriceSchedule(versionID, detailID, partnerID); err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("jadwal harga tidak ditemukan atau sudah diterapkan")
+		}
+		return err
 	}
-	return wallet, nil
+	log.Printf("Price schedule %d of waste price detail ID %d cancelled by partner ID %d", versionID, detailID, partnerID)
+	return nil
 }
 
-// --- Partner Statistics Service Method ---
+// applyDueWastePriceSchedules berjalan di background untuk menerapkan jadwal harga yang jatuh tempo,
+// lalu menghitung ulang Xpoin katalog untuk jenis sampah yang terpengaruh.
+func (s *PartnerService) applyDueWastePriceSchedules(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
-// GetPartnerStatistics mengambil data statistik partner (membuat jika belum ada)
-func (s *PartnerService) GetPartnerStatistics(partnerIDStr string) (*PartnerStatistic, error) {
-	partnerID, err := strconv.Atoi(partnerIDStr)
-	if err != nil {
-		return nil, errors.New("ID partner tidak valid")
+	for range ticker.C {
+		applied, err := s.repo.ApplyDueWastePriceSchedules(time.Now(), wastePriceScheduleBatch)
+		if err != nil {
+			log.Printf("Failed to apply due waste price schedules: %v", err)
+			continue
+		}
+		recalc := map[int]bool{}
+		for _, v := range applied {
+			log.Printf("Price schedule %d applied to waste price detail ID %d of partner ID %d", v.VersionID, v.DetailID, v.PartnerID)
+			if v.WasteDetailID.Valid {
+				recalc[int(v.WasteDetailID.Int32)] = true
+			}
+		}
+		for wdID := range recalc {
+			if err := s.adminRepo.RecalculateAndUpdateWasteDetailXpoin(wdID); err != nil {
+				log.Printf("Error recalculating xpoin after price schedule for waste_detail_id %d: %v", wdID, err)
+			}
+		}
 	}
+}
 
-	// 1. Ambil data dasar (waste, transaction)
-	stats, err := s.repo.GetBaseStatisticsByPartnerID(partnerID) // Panggil fungsi repo yang baru
-	if err != nil {
-		return nil, errors.New("gagal mengambil data statistik dasar partner")
+// --- Import & Ekspor Harga Sampah ---
+
+// Batas file import harga sampah
+const (
+	maxWastePriceImportSizeMB = 5
+	maxWastePriceImportRows   = 1000
+)
+
+// parseImportPrice membaca harga dari sel: "2500", "2500.50", "2500,50", "Rp 2500"
+func parseImportPrice(value string) (float64, error) {
+	value = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(value), "Rp"))
+	if !strings.Contains(value, ".") {
+		value = strings.Replace(value, ",", ".", 1)
 	}
-	// Pastikan stats tidak nil (repo sudah handle create jika belum ada)
-	if stats == nil {
-		// Ini seharusnya tidak terjadi jika repo bekerja
-		log.Printf("Error: GetBaseStatisticsByPartnerID returned nil unexpectedly for partner %d", partnerID)
-		return nil, errors.New("gagal membuat data statistik dasar partner")
+	price, err := strconv.ParseFloat(value, 64)
+	if err != nil || price <= 0 || math.IsInf(price, 0) || math.IsNaN(price) {
+		return 0, errors.New("price tidak valid, harus angka positif")
 	}
+	return price, nil
+}
 
-	// 2. Ambil jumlah unique customer
-	customerCount, err := s.repo.CountUniqueCustomersByPartnerID(partnerID)
+// ExportWastePrices menulis daftar harga partner dalam format yang sama dengan file import
+func (s *PartnerService) ExportWastePrices(partnerIDStr string, format string, w io.Writer) error {
+	if format == "" {
+		format = spreadsheet.FormatCSV
+	}
+	if _, ok := spreadsheet.ContentType[format]; !ok {
+		return errors.New("format ekspor tidak valid (csv atau xlsx)")
+	}
+	details, err := s.GetAllWastePrices(partnerIDStr)
 	if err != nil {
-		// Log error tapi jangan gagalkan proses, set customer ke 0 saja
-		log.Printf("Warning: Failed to count unique customers for partner ID %d: %v", partnerID, err)
-		stats.Customer = 0
-	} else {
-		stats.Customer = customerCount // Isi field Customer
+		return err
 	}
 
-	// 3. Set Revenue ke 0.00 (sesuai permintaan)
-	stats.Revenue = "0.00"
-
-	return stats, nil // Kembalikan struct gabungan
+	rows := [][]string{WastePriceFileColumns}
+	for _, d := range details {
+		wasteDetailID := ""
+		if d.WasteDetailID.Valid {
+			wasteDetailID = strconv.Itoa(int(d.WasteDetailID.Int32))
+		}
+		rows = append(rows, []string{wasteDetailID, d.Name, d.Price, d.Unit, ""})
+	}
+	if err := spreadsheet.Write(w, format, "Harga Sampah", rows); err != nil {
+		log.Printf("Error writing waste price export for partner %s: %v", partnerIDStr, err)
+		return errors.New("gagal membuat file ekspor harga sampah")
+	}
+	return nil
 }
 
-// --- Partner Withdraw Service Method ---
-
-// RequestPartnerWithdrawal memproses permintaan penarikan saldo partner
-func (s *PartnerService) RequestPartnerWithdrawal(partnerIDStr string, req PartnerWithdrawRequest) (string, error) {
+// ImportWastePrices memvalidasi file csv/xlsx harga sampah lalu (jika bukan dry run dan tanpa error)
+// membuat, mengubah, atau menghapus item harga dalam satu transaksi. Baris dicocokkan ke item harga
+// yang ada lewat waste_detail_id, atau lewat nama jika waste_detail_id kosong.
+func (s *PartnerService) ImportWastePrices(partnerIDStr string, fileHeader *multipart.FileHeader, dryRun bool) (*WastePriceImportReport, error) {
 	partnerID, err := strconv.Atoi(partnerIDStr)
 	if err != nil {
-		return "", errors.New("ID partner tidak valid")
+		return nil, errors.New("ID partner tidak valid")
 	}
 
-	// 1. Validasi Input Dasar
-	if req.Amount < minWithdrawalAmount {
-		return "", fmt.Errorf("minimal penarikan adalah Rp %.0f", minWithdrawalAmount)
+	// 1. Baca file
+	if fileHeader == nil {
+		return nil, errors.New("file import wajib diisi")
 	}
-	// TODO: Validasi Payment Method ID
-	// TODO: Validasi Account Number (mungkin berdasarkan Payment Method)
-
-	// 2. Hitung Total dan Cek Saldo
-	totalDeduction := req.Amount + withdrawalFee
-	currentBalance, err := s.repo.GetPartnerCurrentBalanceByID(partnerID)
-	if err != nil {
+	format, ok := spreadsheet.FormatFromFilename(fileHeader.Filename)
+	if !ok {
+		return nil, errors.New("format file import tidak valid (csv atau xlsx)")
+	}
+	if fileHeader.Size > maxWastePriceImportSizeMB*1024*1024 {
+		return nil, fmt.Errorf("ukuran file import tidak valid (maksimal %d MB)", maxWastePriceImportSizeMB)
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		return nil, errors.New("gagal membaca file import")
+	}
+	defer file.Close()
+	rows, err := spreadsheet.Read(file, fileHeader.Size, format)
+	if err != nil {
+		return nil, errors.New("isi file import tidak valid: " + err.Error())
+	}
+
+	// 2. Petakan kolom dari baris header
+	if len(rows) == 0 {
+		return nil, errors.New("baris header file import wajib diisi")
+	}
+	columns := make(map[string]int)
+	for i, name := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	_, hasID := columns["waste_detail_id"]
+	_, hasName := columns["name"]
+	if !hasID && !hasName {
+		return nil, errors.New("kolom waste_detail_id atau name wajib diisi di header")
+	}
+	if len(rows)-1 > maxWastePriceImportRows {
+		return nil, fmt.Errorf("jumlah baris file import tidak valid (maksimal %d)", maxWastePriceImportRows)
+	}
+	cell := func(row []string, column string) string {
+		if i, ok := columns[column]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	// 3. Data pembanding: item harga partner & katalog jenis sampah
+	headerID, err := s.repo.FindOrCreateWastePriceHeader(partnerID)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.repo.GetWastePriceDetailsByPartnerID(partnerID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil daftar harga sampah")
+	}
+	catalog, err := s.adminRepo.GetAllWasteDetails()
+	if err != nil {
+		return nil, errors.New("gagal mengambil katalog jenis sampah")
+	}
+	catalogByID := make(map[int]admin.WasteDetail, len(catalog))
+	catalogByName := make(map[string][]admin.WasteDetail)
+	for _, wd := range catalog {
+		catalogByID[wd.ID] = wd
+		key := strings.ToLower(strings.TrimSpace(wd.Name))
+		catalogByName[key] = append(catalogByName[key], wd)
+	}
+
+	// 4. Validasi per baris
+	report := &WastePriceImportReport{DryRun: dryRun, Format: format, Rows: []WastePriceImportRow{}}
+	changes := &WastePriceImportChanges{HeaderID: headerID}
+	claimedRows := make(map[int]int)      // ID item harga -> nomor baris yang mengubahnya
+	createdDetails := make(map[int]int)   // waste_detail_id baru -> nomor baris
+	affectedDetails := make(map[int]bool) // waste_detail_id yang xpoin-nya perlu dihitung ulang
+	for i, row := range rows[1:] {
+		rowNum := i + 2
+		empty := true
+		for _, v := range row {
+			if strings.TrimSpace(v) != "" {
+				empty = false
+				break
+			}
+		}
+		if empty {
+			continue
+		}
+		report.TotalRows++
+
+		result := WastePriceImportRow{Row: rowNum}
+		fail := func(msg string) {
+			result.Result = WastePriceImportResultError
+			result.Error = msg
+			report.Errors++
+			report.Rows = append(report.Rows, result)
+		}
+
+		action := strings.ToLower(cell(row, "action"))
+		if action == "" {
+			action = WastePriceImportUpsert
+		}
+		if action != WastePriceImportUpsert && action != WastePriceImportDelete {
+			fail("action tidak valid (upsert atau delete)")
+			continue
+		}
+
+		wasteDetailID := 0
+		if v := cell(row, "waste_detail_id"); v != "" {
+			id, err := strconv.Atoi(v)
+			if err != nil || id <= 0 {
+				fail("waste_detail_id tidak valid")
+				continue
+			}
+			if _, ok := catalogByID[id]; !ok {
+				fail(fmt.Sprintf("waste_detail_id %d tidak ditemukan di katalog", id))
+				continue
+			}
+			wasteDetailID = id
+		}
+		name := cell(row, "name")
+		if wasteDetailID == 0 && name == "" {
+			fail("waste_detail_id atau name wajib diisi")
+			continue
+		}
+		result.WasteDetailID, result.Name = wasteDetailID, name
+
+		// Cocokkan ke item harga yang ada
+		var matches []PartnerWastePriceDetail
+		for _, d := range existing {
+			if (wasteDetailID > 0 && d.WasteDetailID.Valid && int(d.WasteDetailID.Int32) == wasteDetailID) ||
+				(wasteDetailID == 0 && strings.EqualFold(strings.TrimSpace(d.Name), name)) {
+				matches = append(matches, d)
+			}
+		}
+		if len(matches) > 1 {
+			fail(fmt.Sprintf("cocok dengan %d item harga, hapus duplikat lewat aplikasi terlebih dulu", len(matches)))
+			continue
+		}
+		var match *PartnerWastePriceDetail
+		if len(matches) == 1 {
+			match = &matches[0]
+			if prev, ok := claimedRows[match.ID]; ok {
+				fail(fmt.Sprintf("item harga yang sama sudah diubah di baris %d", prev))
+				continue
+			}
+			claimedRows[match.ID] = rowNum
+			result.WastePriceID = match.ID
+		}
+
+		// Hapus
+		if action == WastePriceImportDelete {
+			if match == nil {
+				fail("item harga yang akan dihapus tidak ditemukan")
+				continue
+			}
+			result.Result = WastePriceImportResultDelete
+			result.Name, result.OldPrice, result.Unit = match.Name, match.Price, match.Unit
+			if match.WasteDetailID.Valid {
+				result.WasteDetailID = int(match.WasteDetailID.Int32)
+				affectedDetails[result.WasteDetailID] = true
+			}
+			changes.DeleteIDs = append(changes.DeleteIDs, match.ID)
+			report.Deleted++
+			report.Rows = append(report.Rows, result)
+			continue
+		}
+
+		// Upsert: kolom kosong mempertahankan nilai lama (update) atau wajib diisi (create)
+		detail := PartnerWastePriceDetail{PartnerWastePriceID: headerID}
+		if match != nil {
+			detail = *match
+		}
+		if v := cell(row, "price"); v != "" {
+			price, err := parseImportPrice(v)
+			if err != nil {
+				fail(err.Error())
+				continue
+			}
+			detail.Price = fmt.Sprintf("%.2f", price)
+		} else if match == nil {
+			fail("price wajib diisi untuk item baru")
+			continue
+		}
+		if v := cell(row, "unit"); v != "" {
+			unit, ok := NormalizeWasteUnit(v)
+			if !ok {
+				fail("unit tidak valid, gunakan kg atau pcs")
+				continue
+			}
+			detail.Unit = unit
+		} else if match == nil {
+			fail("unit wajib diisi untuk item baru")
+			continue
+		}
+		if wasteDetailID == 0 && match == nil {
+			// Item baru tanpa ID: cari jenis sampah di katalog berdasarkan nama
+			candidates := catalogByName[strings.ToLower(name)]
+			if len(candidates) != 1 {
+				fail("nama tidak cocok dengan tepat satu jenis sampah di katalog, isi waste_detail_id")
+				continue
+			}
+			wasteDetailID = candidates[0].ID
+			for _, d := range existing {
+				if d.WasteDetailID.Valid && int(d.WasteDetailID.Int32) == wasteDetailID {
+					fail(fmt.Sprintf("jenis sampah ini sudah punya item harga \"%s\", isi waste_detail_id %d untuk mengubahnya", d.Name, wasteDetailID))
+					break
+				}
+			}
+			if result.Result == WastePriceImportResultError {
+				continue
+			}
+		}
+		if wasteDetailID > 0 {
+			detail.WasteDetailID = sql.NullInt32{Int32: int32(wasteDetailID), Valid: true}
+		}
+		if name != "" {
+			detail.Name = name
+		} else if match == nil {
+			detail.Name = catalogByID[wasteDetailID].Name
+		}
+		price, _ := strconv.ParseFloat(detail.Price, 64)
+		detail.Xpoin = calculateXpoin(price)
+
+		result.WasteDetailID, result.Name, result.Price, result.Unit, result.Xpoin =
+			int(detail.WasteDetailID.Int32), detail.Name, detail.Price, detail.Unit, detail.Xpoin
+
+		if match == nil {
+			if prev, ok := createdDetails[wasteDetailID]; ok {
+				fail(fmt.Sprintf("jenis sampah yang sama sudah ditambahkan di baris %d", prev))
+				continue
+			}
+			createdDetails[wasteDetailID] = rowNum
+			result.Result = WastePriceImportResultCreate
+			changes.Creates = append(changes.Creates, detail)
+			affectedDetails[wasteDetailID] = true
+			report.Created++
+		} else if detail.Name == match.Name && detail.Price == match.Price && detail.Unit == match.Unit && detail.WasteDetailID == match.WasteDetailID {
+			result.Result = WastePriceImportResultUnchanged
+			report.Unchanged++
+		} else {
+			result.Result = WastePriceImportResultUpdate
+			result.OldPrice = match.Price
+			changes.Updates = append(changes.Updates, detail)
+			if match.WasteDetailID.Valid {
+				affectedDetails[int(match.WasteDetailID.Int32)] = true
+			}
+			if detail.WasteDetailID.Valid {
+				affectedDetails[int(detail.WasteDetailID.Int32)] = true
+			}
+			report.Updated++
+		}
+		report.Rows = append(report.Rows, result)
+	}
+
+	if report.TotalRows == 0 {
+		return nil, errors.New("file import tidak valid: tidak ada baris data")
+	}
+	if dryRun || report.Errors > 0 {
+		return report, nil
+	}
+	if len(changes.Creates)+len(changes.Updates)+len(changes.DeleteIDs) == 0 {
+		report.Applied = true
+		return report, nil
+	}
+
+	// 5. Simpan semua perubahan dalam satu transaksi
+	if err := s.repo.ApplyWastePriceImport(changes); err != nil {
+		return nil, err
+	}
+	report.Applied = true
+
+	// Hitung ulang xpoin katalog sekali per jenis sampah yang terdampak
+	wasteDetailIDs := make([]int, 0, len(affectedDetails))
+	for id := range affectedDetails {
+		wasteDetailIDs = append(wasteDetailIDs, id)
+	}
+	go func(ids []int) {
+		for _, wdID := range ids {
+			if errRecalc := s.adminRepo.RecalculateAndUpdateWasteDetailXpoin(wdID); errRecalc != nil {
+				log.Printf("Error recalculating xpoin after import for waste_detail_id %d: %v", wdID, errRecalc)
+			}
+		}
+	}(wasteDetailIDs)
+	return report, nil
+}
+
+// --- Partner Financial Transaction History Service ---
+
+// GetFinancialTransactionHistory menggabungkan riwayat withdraw, topup, convert, transfer
+func (s *PartnerService) GetFinancialTransactionHistory(partnerIDStr string) ([]PartnerTransactionHistoryItem, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+
+	allTransactions := make([]PartnerTransactionHistoryItem, 0)
+
+	// Ambil data dari masing-masing tabel (handle error per jenis)
+	withdrawHistory, errW := s.repo.GetWithdrawHistoryForPartner(partnerID)
+	if errW != nil {
+		log.Printf("Error getting withdraw history for partner %d: %v", partnerID, errW)
+	}
+	allTransactions = append(allTransactions, withdrawHistory...)
+
+	topupHistory, errT := s.repo.GetTopupHistoryForPartner(partnerID)
+	if errT != nil {
+		log.Printf("Error getting topup history for partner %d: %v", partnerID, errT)
+	}
+	allTransactions = append(allTransactions, topupHistory...)
+
+	convertHistory, errC := s.repo.GetConversionHistoryForPartner(partnerID)
+	if errC != nil {
+		log.Printf("Error getting conversion history for partner %d: %v", partnerID, errC)
+	}
+	allTransactions = append(allTransactions, convertHistory...)
+
+	transferHistory, errTr := s.repo.GetTransferHistoryForPartner(partnerID)
+	if errTr != nil {
+		log.Printf("Error getting transfer history for partner %d: %v", partnerID, errTr)
+	}
+	allTransactions = append(allTransactions, transferHistory...)
+
+	// Urutkan semua transaksi berdasarkan waktu (terbaru dulu)
+	sort.SliceStable(allTransactions, func(i, j int) bool {
+		return allTransactions[i].Timestamp.After(allTransactions[j].Timestamp)
+	})
+
+	return allTransactions, nil // Tidak return error utama jika salah satu gagal
+}
+
+// --- Partner Deposit History Service ---
+
+// GetDepositHistory mengambil riwayat setoran sampah partner
+func (s *PartnerService) GetDepositHistory(partnerIDStr string) ([]DepositHistoryHeader, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+
+	history, err := s.repo.GetDepositHistoryByPartnerID(partnerID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil riwayat deposit")
+	}
+
+	// Repo sudah handle jika kosong (return array kosong)
+	return history, nil
+}
+
+// DeleteAccount menghapus akun partner
+func (s *PartnerService) DeleteAccount(partnerIDStr string) error {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return errors.New("ID partner tidak valid")
+	}
+
+	err = s.repo.DeletePartnerByID(partnerID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("partner tidak ditemukan")
+		}
+		return err // Error teknis repo
+	}
+	return nil // Sukses
+}
+
+// --- Partner Wallet Service Method ---
+
+// GetPartnerWallet mengambil data wallet partner (membuat jika belum ada)
+func (s *PartnerService) GetPartnerWallet(partnerIDStr string) (*PartnerWallet, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+
+	wallet, err := s.repo.FindOrCreateWalletByPartnerID(partnerID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil atau membuat wallet partner")
+	}
+	return wallet, nil
+}
+
+// --- Partner Statistics Service Method ---
+
+// GetPartnerStatistics mengambil data statistik partner (membuat jika belum ada)
+func (s *PartnerService) GetPartnerStatistics(partnerIDStr string) (*PartnerStatistic, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+
+	// 1. Ambil data dasar (waste, transaction)
+	stats, err := s.repo.GetBaseStatisticsByPartnerID(partnerID) // Panggil fungsi repo yang baru
+	if err != nil {
+		return nil, errors.New("gagal mengambil data statistik dasar partner")
+	}
+	// Pastikan stats tidak nil (repo sudah handle create jika belum ada)
+	if stats == nil {
+		// Ini seharusnya tidak terjadi jika repo bekerja
+		log.Printf("Error: GetBaseStatisticsByPartnerID returned nil unexpectedly for partner %d", partnerID)
+		return nil, errors.New("gagal membuat data statistik dasar partner")
+	}
+
+	// 2. Ambil jumlah unique customer
+	customerCount, err := s.repo.CountUniqueCustomersByPartnerID(partnerID)
+	if err != nil {
+		// Log error tapi jangan gagalkan proses, set customer ke 0 saja
+		log.Printf("Warning: Failed to count unique customers for partner ID %d: %v", partnerID, err)
+		stats.Customer = 0
+	} else {
+		stats.Customer = customerCount // Isi field Customer
+	}
+
+	// 3. Revenue dari penjualan sampah ke recycler
+	revenue, err := s.repo.GetOfftakeRevenueByPartnerID(partnerID)
+	if err != nil {
+		log.Printf("Warning: Failed to sum offtake revenue for partner ID %d: %v", partnerID, err)
+		revenue = 0
+	}
+	stats.Revenue = fmt.Sprintf("%.2f", revenue)
+
+	return stats, nil // Kembalikan struct gabungan
+}
+
+// --- Partner Withdraw Service Method ---
+
+// RequestPartnerWithdrawal memproses permintaan penarikan saldo partner
+func (s *PartnerService) RequestPartnerWithdrawal(partnerIDStr string, req PartnerWithdrawRequest) (string, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return "", errors.New("ID partner tidak valid")
+	}
+
+	// 1. Validasi Input Dasar
+	if req.Amount < minWithdrawalAmount {
+		return "", fmt.Errorf("minimal penarikan adalah Rp %.0f", minWithdrawalAmount)
+	}
+	// TODO: Validasi Payment Method ID
+	// TODO: Validasi Account Number (mungkin berdasarkan Payment Method)
+
+	// 2. Hitung Total dan Cek Saldo
+	totalDeduction := req.Amount + withdrawalFee
+	currentBalance, err := s.repo.GetPartnerCurrentBalanceByID(partnerID)
+	if err != nil {
 		return "", fmt.Errorf("gagal memeriksa saldo partner: %w", err)
 	}
 
@@ -1060,7 +1838,7 @@ func (s *PartnerService) TransferXpoin(senderPartnerIDStr string, req PartnerTra
 	}
 
 	// 4. Eksekusi Transaksi Database
-	orderID, err := s.repo.ExecutePartnerTransferTransaction(senderPartnerID, req.Amount, recipientUserID, recipientPartnerID, req.RecipientEmail)
+	orderID, err := s.repo.ExecutePartnerTransferTransaction(senderPartnerID, req.Amount, recipientUserID, recipientPartnerID, req.RecipientEmail, staffIDArg(req.StaffID))
 	if err != nil {
 		// Error spesifik (poin tidak cukup, dll) sudah ditangani di repo
 		return "", fmt.Errorf("gagal memproses transfer: %w", err)
@@ -1194,12 +1972,17 @@ func (s *PartnerService) ConvertRpToXp(partnerIDStr string, req PartnerConversio
 
 // --- Verify QR Token Service Method ---
 
-// VerifyDepositQrToken memvalidasi token QR dan mengembalikan data user
-func (s *PartnerService) VerifyDepositQrToken(req VerifyQrTokenRequest) (*VerifyQrTokenResponse, error) {
-	// 1. Validasi token menggunakan TokenStore
-	userID, err := s.tokenStore.ValidateToken(req.Token)
+// VerifyDepositQrToken memvalidasi token QR, mengklaimnya untuk partner ini, dan mengembalikan data user
+func (s *PartnerService) VerifyDepositQrToken(partnerIDStr string, req VerifyQrTokenRequest) (*VerifyQrTokenResponse, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+
+	// 1. Klaim token secara atomik; partner lain tidak bisa memakai token yang sama
+	userID, err := s.tokenStore.ClaimToken(req.Token, partnerID)
 	if err != nil {
-		// Error bisa "token tidak ditemukan" atau "token sudah kedaluwarsa"
+		// Error bisa "token tidak ditemukan", "token sudah kedaluwarsa" atau "token sudah digunakan oleh mitra lain"
 		return nil, err
 	}
 
@@ -1293,32 +2076,404 @@ func (s *PartnerService) uploadDepositImage(partnerID int, userID int, fileHeade
 	return imageURL, nil
 }
 
-// CreateDeposit memproses pembuatan setoran sampah baru
-func (s *PartnerService) CreateDeposit(partnerIDStr string, req CreateDepositRequest, imageFile *multipart.FileHeader) (*DepositHistoryHeader, error) {
-	partnerID, err := strconv.Atoi(partnerIDStr)
-	if err != nil {
-		return nil, errors.New("ID partner tidak valid")
+// saveDepositPhotos mengunggah beberapa foto bukti deposit beserta thumbnail-nya.
+// Thumbnail dibuat sebisanya; file yang bukan gambar JPEG/PNG/GIF tetap disimpan tanpa thumbnail.
+func (s *PartnerService) saveDepositPhotos(partnerID int, userID int, files []*multipart.FileHeader) ([]user.DepositPhoto, error) {
+	photos := []user.DepositPhoto{}
+	for _, fileHeader := range files {
+		imageURL, err := s.uploadDepositImage(partnerID, userID, fileHeader)
+		if err != nil {
+			removeDepositPhotoFiles(photos)
+			return nil, err
+		}
+		photos = append(photos, user.DepositPhoto{URL: imageURL, ThumbnailURL: s.createDepositThumbnail(fileHeader, path.Base(imageURL))})
 	}
+	return photos, nil
+}
 
-	// 1. Unmarshal & Validasi Items JSON
-	var itemsInput []DepositWasteItem
-	if err := json.Unmarshal([]byte(req.ItemsJSON), &itemsInput); err != nil {
-		return nil, errors.New("format data item sampah tidak valid")
-	}
-	if len(itemsInput) == 0 {
-		return nil, errors.New("minimal harus ada satu item sampah")
+// removeDepositPhotoFiles menghapus file foto (beserta thumbnail) yang sudah diunggah tetapi batal disimpan
+func removeDepositPhotoFiles(photos []user.DepositPhoto) {
+	for _, photo := range photos {
+		removeMediaFile(photo.URL)
+		removeMediaFile(photo.ThumbnailURL)
 	}
+}
 
-	// 2. Validasi User & Deposit Method ID
-	userData, err := s.userRepo.FindByID(req.UserID)
-	if err != nil || userData == nil {
-		return nil, errors.New("ID pengguna tidak valid atau tidak ditemukan")
+// removeMediaFile menghapus file di media lokal berdasarkan URL CDN-nya; URL di luar CDN diabaikan
+func removeMediaFile(url string) {
+	if url == "" {
+		return
 	}
-	// TODO: Validasi req.DepositMethodID (ambil dari DB master data)
-
-	// 3. Kalkulasi Total, Kumpulkan ID Detail, Cek Xpoin Partner
+	relPath := strings.TrimPrefix(url, config.GetCDNBaseURL()+"/")
+	if relPath == url || strings.Contains(relPath, "..") {
+		return
+	}
+	if err := os.Remove(filepath.Join(config.GetMediaBasePath(), filepath.FromSlash(relPath))); err != nil && !os.IsNotExist(err) {
+		log.Printf("Warning: failed to remove uploaded file %s: %v", url, err)
+	}
+}
+
+// createDepositThumbnail menyimpan thumbnail foto deposit di deposit_photos/thumbs, "" jika gagal
+func (s *PartnerService) createDepositThumbnail(fileHeader *multipart.FileHeader, filename string) string {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	thumbDir := filepath.Join(config.GetMediaBasePath(), "deposit_photos", "thumbs")
+	if err := os.MkdirAll(thumbDir, 0755); err != nil {
+		log.Printf("Error creating deposit thumbnail directory: %v", err)
+		return ""
+	}
+	thumbName := strings.TrimSuffix(filename, filepath.Ext(filename)) + ".jpg"
+	dst, err := os.Create(filepath.Join(thumbDir, thumbName))
+	if err != nil {
+		log.Printf("Error creating deposit thumbnail file: %v", err)
+		return ""
+	}
+	defer dst.Close()
+
+	if err := thumbnail.Generate(file, dst, thumbnail.DefaultMaxSize); err != nil {
+		log.Printf("Warning: Failed to generate thumbnail for %s: %v", filename, err)
+		os.Remove(dst.Name())
+		return ""
+	}
+	return fmt.Sprintf("%s/deposit_photos/thumbs/%s", config.GetCDNBaseURL(), thumbName)
+}
+
+// verifyOfflineQr memverifikasi kode QR offline saat deposit disinkronkan.
+// scannedAt (RFC3339, opsional) adalah waktu partner memindai kode di lokasi tanpa sinyal.
+func (s *PartnerService) verifyOfflineQr(code string, scannedAt string) (*offline_qr.Claims, error) {
+	claims, err := s.offlineQr.Verify(code)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	scanTime := now
+	if scannedAt != "" {
+		scanTime, err = time.Parse(time.RFC3339, scannedAt)
+		if err != nil || scanTime.After(now.Add(5*time.Minute)) {
+			return nil, errors.New("scanned_at tidak valid")
+		}
+	}
+	if scanTime.After(claims.ExpiresAt) {
+		return nil, errors.New("kode QR offline sudah kedaluwarsa")
+	}
+	if now.After(claims.ExpiresAt.Add(offlineQrSyncGrace)) {
+		return nil, errors.New("kode QR offline sudah kedaluwarsa untuk disinkronkan")
+	}
+	return claims, nil
+}
+
+// GetOfflineQrPublicKeys mengambil public key untuk verifikasi kode QR offline di aplikasi partner
+func (s *PartnerService) GetOfflineQrPublicKeys() ([]offline_qr.PublicKey, error) {
+	return s.offlineQr.PublicKeys()
+}
+
+// CreateDeposit memproses pembuatan setoran sampah baru
+func (s *PartnerService) CreateDeposit(partnerIDStr string, req CreateDepositRequest, imageFile *multipart.FileHeader) (*DepositHistoryHeader, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+
+	consume, err := s.resolveDepositUser(partnerID, &req)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.createDepositForUser(partnerID, req, imageFile, consume)
+}
+
+// SyncDepositBatch menyimpan deposit yang dicatat offline di aplikasi partner. Setiap item diproses
+// sendiri-sendiri lewat alur CreateDeposit dan idempoten per client_id: item yang sudah tersimpan
+// dilaporkan sebagai duplikat, sedangkan kegagalan satu item tidak menggagalkan item lainnya.
+func (s *PartnerService) SyncDepositBatch(partnerIDStr string, req BatchDepositRequest) ([]BatchDepositResult, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+
+	results := make([]BatchDepositResult, 0, len(req.Deposits))
+	seen := make(map[string]bool)
+	for _, item := range req.Deposits {
+		result := BatchDepositResult{ClientID: item.ClientID}
+		if seen[item.ClientID] {
+			result.Status = BatchDepositFailed
+			result.Error = "client_id duplikat dalam batch yang sama"
+			results = append(results, result)
+			continue
+		}
+		seen[item.ClientID] = true
+
+		header, err := s.syncBatchDepositItem(partnerID, req.StaffID, item)
+		switch {
+		case err == nil && header.FraudCaseID != 0:
+			result.Status = BatchDepositHeld
+			result.FraudCaseID = header.FraudCaseID
+			result.TotalXpoin = header.TotalXpoin
+		case err == nil:
+			result.Status = BatchDepositCreated
+			result.DepositID = header.ID
+			result.TotalXpoin = header.TotalXpoin
+		case strings.Contains(err.Error(), "client_id ini sudah tersimpan"):
+			result.Status = BatchDepositDuplicate
+			if existing, errFind := s.repo.FindDepositByClientID(partnerID, item.ClientID); errFind == nil && existing != nil {
+				result.DepositID = existing.ID
+				result.TotalXpoin = existing.TotalXpoin
+			}
+		default:
+			result.Status = BatchDepositFailed
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	log.Printf("Deposit batch synced for partner ID %d: %d item(s)", partnerID, len(results))
+	return results, nil
+}
+
+// syncBatchDepositItem memvalidasi satu item batch lalu membuat depositnya seperti CreateDeposit
+func (s *PartnerService) syncBatchDepositItem(partnerID, staffID int, item BatchDepositItem) (*DepositHistoryHeader, error) {
+	existing, err := s.repo.FindDepositByClientID(partnerID, item.ClientID)
+	if err != nil {
+		return nil, errors.New("gagal memeriksa deposit sebelumnya")
+	}
+	if existing != nil {
+		return nil, errors.New("deposit dengan client_id ini sudah tersimpan")
+	}
+	fraudCase, err := s.repo.FindDepositFraudCaseByClientID(partnerID, item.ClientID)
+	if err != nil {
+		return nil, errors.New("gagal memeriksa deposit sebelumnya")
+	}
+	if fraudCase != nil {
+		if fraudCase.Status == FraudCaseHeld {
+			return &DepositHistoryHeader{PartnerID: partnerID, UserID: fraudCase.UserID, TotalXpoin: fraudCase.TotalXpoin,
+				TransactionTime: fraudCase.TransactionTime, FraudCaseID: fraudCase.ID}, nil
+		}
+		return nil, errors.New("deposit ditolak sistem anti-fraud")
+	}
+
+	now := time.Now()
+	if item.TransactedAt.After(now.Add(5*time.Minute)) || item.TransactedAt.Before(now.Add(-batchDepositMaxAge)) {
+		return nil, errors.New("transacted_at tidak valid")
+	}
+	if item.QrToken == "" && item.OfflineQr == "" {
+		return nil, errors.New("qr_token atau offline_qr wajib diisi")
+	}
+
+	req := CreateDepositRequest{
+		QrToken:         item.QrToken,
+		OfflineQr:       item.OfflineQr,
+		ScannedAt:       item.ScannedAt,
+		DepositMethodID: item.DepositMethodID,
+		ItemsJSON:       string(item.Items),
+		Notes:           item.Notes,
+		ClientID:        item.ClientID,
+		TransactedAt:    item.TransactedAt,
+		StaffID:         staffID,
+	}
+	if req.OfflineQr != "" && req.ScannedAt == "" {
+		req.ScannedAt = item.TransactedAt.Format(time.RFC3339) // QR offline dipindai saat transaksi
+	}
+
+	consume, err := s.resolveDepositUser(partnerID, &req)
+	if err != nil {
+		return nil, err
+	}
+	return s.createDepositForUser(partnerID, req, nil, consume)
+}
+
+// depositConsumer memakai bukti sekali pakai sebuah deposit (token QR, nonce QR offline, atau status sesi)
+// di dalam transaksi repo yang menyimpan deposit. restore mengembalikan bukti yang tidak ikut rollback
+// database dan hanya dipanggil jika transaksi tersebut gagal.
+type depositConsumer func(tx *sql.Tx) (restore func(), err error)
+
+// inTx menjalankan persist dengan consumer sebagai hook di dalam transaksi repo. Jika transaksi gagal
+// setelah consumer berjalan, bukti dikembalikan agar partner bisa mencoba lagi dengan kode yang sama.
+func (c depositConsumer) inTx(persist func(hook func(tx *sql.Tx) error) error) error {
+	var restore func()
+	err := persist(func(tx *sql.Tx) error {
+		if c == nil {
+			return nil
+		}
+		var err error
+		restore, err = c(tx)
+		return err
+	})
+	if err != nil && restore != nil {
+		restore()
+	}
+	return err
+}
+
+// withRestore menambahkan langkah pemulihan (mis. menghapus foto yang sudah diunggah) yang dijalankan
+// jika transaksi penyimpanan gagal, termasuk saat consumer sendiri menolak buktinya.
+func (c depositConsumer) withRestore(undo func()) depositConsumer {
+	return func(tx *sql.Tx) (func(), error) {
+		var restore func()
+		if c != nil {
+			var err error
+			if restore, err = c(tx); err != nil {
+				undo()
+				return nil, err
+			}
+		}
+		return func() {
+			if restore != nil {
+				restore()
+			}
+			undo()
+		}, nil
+	}
+}
+
+// resolveDepositUser mengisi req.UserID dari token QR online (hanya partner yang mengklaim token
+// yang boleh memakainya) atau dari kode QR offline bertanda tangan. Consumer yang dikembalikan
+// memakai token/nonce di dalam transaksi penyimpanan, agar satu kode QR hanya menghasilkan satu deposit.
+func (s *PartnerService) resolveDepositUser(partnerID int, req *CreateDepositRequest) (depositConsumer, error) {
+	if req.OfflineQr != "" {
+		offlineClaims, err := s.verifyOfflineQr(req.OfflineQr, req.ScannedAt)
+		if err != nil {
+			return nil, err
+		}
+		req.UserID = offlineClaims.UserID
+		return func(tx *sql.Tx) (func(), error) {
+			return nil, s.offlineQr.ConsumeNonce(tx, offlineClaims, partnerID)
+		}, nil
+	}
+
+	userID, err := s.tokenStore.ClaimToken(req.QrToken, partnerID)
+	if err != nil {
+		return nil, err
+	}
+	req.UserID = userID
+	qrToken := req.QrToken
+	return func(tx *sql.Tx) (func(), error) {
+		_, restore, err := s.tokenStore.ConsumeToken(tx, qrToken, partnerID)
+		return restore, err
+	}, nil
+}
+
+// createDepositForUser menjalankan pembuatan deposit untuk req.UserID yang sudah diketahui.
+// consume dijalankan di dalam transaksi yang menyimpan deposit atau kasus fraud Held.
+// Deposit yang ditahan aturan fraud dikembalikan dengan ID 0 dan FraudCaseID terisi.
+func (s *PartnerService) createDepositForUser(partnerID int, req CreateDepositRequest, imageFile *multipart.FileHeader, consume depositConsumer) (*DepositHistoryHeader, error) {
+	depositArgs, err := s.prepareDeposit(partnerID, req, imageFile)
+	if err != nil {
+		return nil, err
+	}
+
+	decision, hits, err := s.screenDeposit(*depositArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Foto baru diunggah setelah deposit lolos pemeriksaan, dan dihapus lagi jika penyimpanan gagal
+	if err := s.attachDepositPhotos(depositArgs, req, imageFile); err != nil {
+		return nil, err
+	}
+	consume = consume.withRestore(func() { removeDepositArgsPhotos(*depositArgs) })
+
+	if decision == fraud.ActionHold {
+		fraudCase, err := s.recordDepositFraudCase(*depositArgs, hits, FraudCaseHeld, consume)
+		if err != nil {
+			return nil, err
+		}
+		return &DepositHistoryHeader{PartnerID: partnerID, UserID: depositArgs.UserID, TotalXpoin: depositArgs.TotalXpoin,
+			TransactionTime: depositArgs.TransactionTime, FraudCaseID: fraudCase.ID}, nil
+	}
+
+	return s.executeDeposit(*depositArgs, consume)
+}
+
+// applyWasteUnit memvalidasi input item sesuai satuan harga lalu menghitung Xpoin-nya. Item kg memakai weight,
+// item pcs memakai quantity dan beratnya (untuk statistik) dikonversi dari rata-rata berat per buah di katalog.
+func applyWasteUnit(item *DepositWasteItem, priceInfo *WastePriceInfo) (int, error) {
+	unit, ok := NormalizeWasteUnit(priceInfo.Unit)
+	if !ok {
+		unit = WasteUnitKg // Satuan lama yang tidak dikenal diperlakukan sebagai kg
+	}
+	item.Unit = unit
+
+	if unit == WasteUnitPcs {
+		if item.ScaleReadingID != 0 {
+			return 0, fmt.Errorf("item ID %d bersatuan pcs, hasil timbang hanya untuk item kg", item.PartnerWastePriceDetailID)
+		}
+		if item.Quantity <= 0 || item.Weight != 0 {
+			return 0, fmt.Errorf("item ID %d bersatuan pcs, isi quantity (bukan weight)", item.PartnerWastePriceDetailID)
+		}
+		item.Weight = 0
+		if priceInfo.AverageUnitWeight.Valid {
+			item.Weight = math.Round(float64(item.Quantity)*priceInfo.AverageUnitWeight.Float64*100) / 100
+		}
+		return item.Quantity * priceInfo.XpoinPerUnit, nil
+	}
+
+	if item.Weight <= 0 || item.Quantity != 0 {
+		return 0, fmt.Errorf("item ID %d bersatuan kg, isi weight (bukan quantity)", item.PartnerWastePriceDetailID)
+	}
+	itemXpoin := int(math.Floor(item.Weight * float64(priceInfo.XpoinPerUnit)))
+	if itemXpoin < 0 {
+		itemXpoin = 0
+	}
+	return itemXpoin, nil
+}
+
+// applyScaleReading mengisi berat item dari hasil timbang digital milik partner. Hasil timbang harus belum dipakai,
+// tidak dipakai dua kali dalam satu deposit, dan diukur dekat dengan waktu transaksi. Pemakaian final dikunci di transaksi DB.
+func (s *PartnerService) applyScaleReading(partnerID int, item *DepositWasteItem, transactionTime time.Time, usedReadings map[int]bool) error {
+	if item.Weight != 0 {
+		return fmt.Errorf("item ID %d: isi weight atau scale_reading_id, bukan keduanya", item.PartnerWastePriceDetailID)
+	}
+	if usedReadings[item.ScaleReadingID] {
+		return fmt.Errorf("hasil timbang ID %d dipakai lebih dari sekali", item.ScaleReadingID)
+	}
+
+	reading, err := s.repo.GetScaleReadingForDeposit(item.ScaleReadingID)
+	if err != nil {
+		return errors.New("gagal mengambil hasil timbang")
+	}
+	if reading == nil || reading.PartnerID != partnerID {
+		return fmt.Errorf("hasil timbang ID %d tidak ditemukan", item.ScaleReadingID)
+	}
+	if reading.Used {
+		return fmt.Errorf("hasil timbang ID %d sudah dipakai", item.ScaleReadingID)
+	}
+	if reading.DeviceRevoked {
+		return fmt.Errorf("hasil timbang ID %d berasal dari timbangan yang sudah dicabut", item.ScaleReadingID)
+	}
+	if gap := transactionTime.Sub(reading.MeasuredAt); gap > scaleReadingUseWindow || gap < -scaleReadingMaxClockSkew {
+		return fmt.Errorf("hasil timbang ID %d sudah kedaluwarsa, timbang ulang", item.ScaleReadingID)
+	}
+
+	usedReadings[item.ScaleReadingID] = true
+	item.Weight = reading.Weight
+	return nil
+}
+
+// prepareDeposit memvalidasi item, jumlah foto, menghitung Xpoin, dan mengecek saldo Xpoin partner.
+// Belum ada perubahan wallet maupun file yang diunggah pada tahap ini.
+func (s *PartnerService) prepareDeposit(partnerID int, req CreateDepositRequest, imageFile *multipart.FileHeader) (*ArgsDepositCreation, error) {
+	// 1. Unmarshal & Validasi Items JSON
+	var itemsInput []DepositWasteItem
+	if err := json.Unmarshal([]byte(req.ItemsJSON), &itemsInput); err != nil {
+		return nil, errors.New("format data item sampah tidak valid")
+	}
+	if len(itemsInput) == 0 {
+		return nil, errors.New("minimal harus ada satu item sampah")
+	}
+
+	// 2. Validasi User & Deposit Method ID
+	userData, err := s.userRepo.FindByID(req.UserID)
+	if err != nil || userData == nil {
+		return nil, errors.New("ID pengguna tidak valid atau tidak ditemukan")
+	}
+	// TODO: Validasi req.DepositMethodID (ambil dari DB master data)
+
+	// 3. Kalkulasi Total, Cek Xpoin Partner
 	totalWeight, totalXpoin := 0.0, 0
-	wasteDetailIDs := []int{}               // Kumpulkan ID waste_details untuk ambil faktor
 	calculatedItems := []DepositWasteItem{} // Simpan item dgn data lengkap
 
 	partnerWallet, errWallet := s.repo.FindOrCreateWalletByPartnerID(partnerID)
@@ -1326,29 +2481,37 @@ func (s *PartnerService) CreateDeposit(partnerIDStr string, req CreateDepositReq
 		return nil, errors.New("gagal memeriksa wallet partner")
 	}
 
-	for _, item := range itemsInput {
-		if item.Weight <= 0 {
-			return nil, fmt.Errorf("berat item tidak valid")
-		}
+	transactionTime := time.Now()
+	if !req.TransactedAt.IsZero() {
+		transactionTime = req.TransactedAt // Deposit offline memakai waktu transaksi asli
+	}
 
+	usedReadings := map[int]bool{}
+	for _, item := range itemsInput {
 		priceInfo, err := s.repo.GetWastePriceInfoForCalculation(item.PartnerWastePriceDetailID, partnerID)
 		if err != nil {
 			return nil, fmt.Errorf("gagal mengambil info harga untuk item ID %d: %w", item.PartnerWastePriceDetailID, err)
 		}
 
-		itemXpoin := int(math.Floor(item.Weight * float64(priceInfo.XpoinPerUnit))) // Asumsi XpoinPerUnit per KG
-		if itemXpoin < 0 {
-			itemXpoin = 0
+		if item.ScaleReadingID != 0 {
+			if err := s.applyScaleReading(partnerID, &item, transactionTime, usedReadings); err != nil {
+				return nil, err
+			}
+		}
+
+		itemXpoin, err := applyWasteUnit(&item, priceInfo)
+		if err != nil {
+			return nil, err
 		}
 
 		totalWeight += item.Weight
 		totalXpoin += itemXpoin
 
 		if priceInfo.WasteDetailID.Valid {
-			wasteDetailIDs = append(wasteDetailIDs, int(priceInfo.WasteDetailID.Int32))
 			item.WasteDetailID = priceInfo.WasteDetailID // Simpan wasteDetailID di item
 		}
 		item.CalculatedXpoin = itemXpoin // Simpan Xpoin hasil hitung
+		item.PriceVersionID = priceInfo.VersionID
 		calculatedItems = append(calculatedItems, item)
 	}
 
@@ -1357,16 +2520,94 @@ func (s *PartnerService) CreateDeposit(partnerIDStr string, req CreateDepositReq
 		return nil, errors.New("xpoin partner tidak mencukupi untuk transaksi ini")
 	}
 
-	// 4. Ambil Faktor Konversi Statistik User
+	// 4. Validasi jumlah foto; file baru diunggah lewat attachDepositPhotos setelah pemeriksaan lain lolos
+	photoCount := len(req.Photos)
+	if imageFile != nil {
+		photoCount++
+	}
+	for index, files := range req.ItemPhotos {
+		if index < 0 || index >= len(calculatedItems) {
+			return nil, fmt.Errorf("foto item ke-%d tidak valid, item tidak ditemukan", index)
+		}
+		photoCount += len(files)
+	}
+	if photoCount > maxDepositPhotos {
+		return nil, fmt.Errorf("maksimal %d foto per deposit", maxDepositPhotos)
+	}
+
+	// 5. Siapkan Argumen untuk Transaksi Utama Partner
+	return &ArgsDepositCreation{ // Gunakan struct dari model partner
+		PartnerID:       partnerID,
+		UserID:          req.UserID,
+		DepositMethodID: req.DepositMethodID,
+		Items:           calculatedItems, // Kirim item dgn xpoin & wasteDetailID
+		TotalWeight:     totalWeight,
+		TotalXpoin:      totalXpoin,
+		Notes:           sql.NullString{String: req.Notes, Valid: req.Notes != ""},
+		TransactionTime: transactionTime,
+		ClientID:        sql.NullString{String: req.ClientID, Valid: req.ClientID != ""},
+		StaffID:         staffIDArg(req.StaffID),
+		SourceType:      req.SourceType,
+		SourceID:        req.SourceID,
+	}, nil
+}
+
+// attachDepositPhotos mengunggah foto deposit & foto per item (masing-masing dengan thumbnail) ke args.
+// File yang sudah terunggah dihapus lagi jika salah satu unggahan gagal.
+func (s *PartnerService) attachDepositPhotos(args *ArgsDepositCreation, req CreateDepositRequest, imageFile *multipart.FileHeader) error {
+	headerFiles := req.Photos
+	if imageFile != nil {
+		headerFiles = append([]*multipart.FileHeader{imageFile}, headerFiles...) // Field lama "photo" tetap didukung
+	}
+	headerPhotos, err := s.saveDepositPhotos(args.PartnerID, args.UserID, headerFiles)
+	if err != nil {
+		return err
+	}
+	args.Photos = headerPhotos
+	for index, files := range req.ItemPhotos {
+		args.Items[index].Photos, err = s.saveDepositPhotos(args.PartnerID, args.UserID, files)
+		if err != nil {
+			removeDepositArgsPhotos(*args)
+			return err
+		}
+	}
+	if len(headerPhotos) > 0 {
+		args.PhotoURL = sql.NullString{String: headerPhotos[0].URL, Valid: true}
+	}
+	return nil
+}
+
+// removeDepositArgsPhotos menghapus semua foto deposit & item yang diunggah untuk args
+func removeDepositArgsPhotos(args ArgsDepositCreation) {
+	removeDepositPhotoFiles(args.Photos)
+	for _, item := range args.Items {
+		removeDepositPhotoFiles(item.Photos)
+	}
+}
+
+// executeDeposit mendebit Xpoin partner, mengkredit wallet & statistik user, lalu mengirim notifikasi.
+// consume (opsional) dijalankan di dalam transaksi sisi partner.
+func (s *PartnerService) executeDeposit(depositArgs ArgsDepositCreation, consume depositConsumer) (*DepositHistoryHeader, error) {
+	partnerID, userID := depositArgs.PartnerID, depositArgs.UserID
+	totalWeight, totalXpoin := depositArgs.TotalWeight, depositArgs.TotalXpoin
+	transactionTime := depositArgs.TransactionTime
+
+	// 1. Ambil Faktor Konversi Statistik User
+	wasteDetailIDs := []int{} // Kumpulkan ID waste_details untuk ambil faktor
+	for _, item := range depositArgs.Items {
+		if item.WasteDetailID.Valid {
+			wasteDetailIDs = append(wasteDetailIDs, int(item.WasteDetailID.Int32))
+		}
+	}
 	factorsMap, err := s.userRepo.GetWasteDetailFactors(wasteDetailIDs)
 	if err != nil {
 		log.Printf("Warning: Failed to get waste detail factors: %v", err)
 		factorsMap = make(map[int]user.ImpactFactors)
 	}
 
-	// 5. Hitung Dampak Lingkungan
+	// 2. Hitung Dampak Lingkungan
 	totalEnergySaved, totalCo2Saved, totalWaterSaved, totalTreesSaved := 0.0, 0.0, 0.0, 0.0
-	for _, item := range calculatedItems {
+	for _, item := range depositArgs.Items {
 		if item.WasteDetailID.Valid {
 			detailID := int(item.WasteDetailID.Int32)
 			if factors, ok := factorsMap[detailID]; ok {
@@ -1379,58 +2620,42 @@ func (s *PartnerService) CreateDeposit(partnerIDStr string, req CreateDepositReq
 	}
 	treesSavedInt := int(math.Round(totalTreesSaved))
 
-	// 6. Upload Foto Deposit (jika ada) - gunakan fungsi baru
-	imageURL, err := s.uploadDepositImage(partnerID, req.UserID, imageFile)
-	if err != nil {
-		return nil, err
-	}
-	photoURLDB := sql.NullString{String: imageURL, Valid: imageURL != ""}
-
-	// 7. Siapkan Argumen untuk Transaksi Utama Partner
-	transactionTime := time.Now()
-	depositArgs := ArgsDepositCreation{ // Gunakan struct dari model partner
-		PartnerID:       partnerID,
-		UserID:          req.UserID,
-		DepositMethodID: req.DepositMethodID,
-		Items:           calculatedItems, // Kirim item dgn xpoin & wasteDetailID
-		TotalWeight:     totalWeight,
-		TotalXpoin:      totalXpoin,
-		Notes:           sql.NullString{String: req.Notes, Valid: req.Notes != ""},
-		PhotoURL:        photoURLDB,
-		TransactionTime: transactionTime,
-	}
-
-	// 8. Eksekusi Transaksi Database Utama (Partner side)
-	depositHeaderID, err := s.repo.ExecuteDepositCreationTransaction(depositArgs)
+	// 3. Eksekusi Transaksi Database Utama (Partner side)
+	var depositHeaderID int
+	err = consume.inTx(func(hook func(tx *sql.Tx) error) (err error) {
+		depositArgs.Consume = hook
+		depositHeaderID, err = s.repo.ExecuteDepositCreationTransaction(depositArgs)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	} // Error transaksi utama (termasuk xpoin partner tdk cukup)
 
-	// 9. Update Data User (Idealnya dalam transaksi yg sama)
+	// 4. Update Data User (Idealnya dalam transaksi yg sama)
 	// Pastikan wallet & stats user ada
-	_, errWU := s.userRepo.FindOrCreateWalletByUserID(req.UserID)
+	_, errWU := s.userRepo.FindOrCreateWalletByUserID(userID)
 	if errWU != nil {
 		log.Printf("Failed FindOrCreate User Wallet: %v", errWU) /* Lanjutkan? */
 	}
-	_, errSU := s.userRepo.FindOrCreateStatisticsByUserID(req.UserID)
+	_, errSU := s.userRepo.FindOrCreateStatisticsByUserID(userID)
 	if errSU != nil {
 		log.Printf("Failed FindOrCreate User Stats: %v", errSU) /* Lanjutkan? */
 	}
 
 	// Panggil AddDepositHistory dan TANGKAP ID nya
-	userDepositHistoryID, err := s.userRepo.AddDepositHistory(req.UserID, partnerID, depositArgs.TotalXpoin, transactionTime)
+	userDepositHistoryID, err := s.userRepo.AddDepositHistory(userID, partnerID, totalXpoin, transactionTime)
 	if err != nil {
 		log.Printf("CRITICAL: Failed AddUserDepositHistory after partner tx commit: %v. Data potentially inconsistent.", err)
 		// Harusnya ada mekanisme rollback manual atau notifikasi error
 		return nil, err // Atau return error parsial?
 	}
 
-	err = s.userRepo.UpdateUserWalletOnDeposit(req.UserID, totalXpoin)
+	err = s.userRepo.UpdateUserWalletOnDeposit(userID, totalXpoin)
 	if err != nil {
 		log.Printf("Failed UpdateUserWalletOnDeposit: %v", err) /* Rollback manual? */
 	}
 
-	err = s.userRepo.UpdateUserStatisticsOnDeposit(req.UserID, totalWeight, totalEnergySaved, totalCo2Saved, totalWaterSaved, treesSavedInt)
+	err = s.userRepo.UpdateUserStatisticsOnDeposit(userID, totalWeight, totalEnergySaved, totalCo2Saved, totalWaterSaved, treesSavedInt)
 	if err != nil {
 		log.Printf("Failed UpdateUserStatisticsOnDeposit: %v", err) /* Rollback manual? */
 	}
@@ -1443,11 +2668,2715 @@ func (s *PartnerService) CreateDeposit(partnerIDStr string, req CreateDepositReq
 	// Kirim notifikasi ke USER bahwa deposit berhasil
 	go func() {
 		notifTitle := "Deposit Berhasil!"
-		notifBody := fmt.Sprintf("Kamu menerima %d Xpoin dari setoran sampah.", depositArgs.TotalXpoin)
-		s.notifService.SendNotification(req.UserID, notifTitle, notifBody, "DEPOSIT_SUCCESS")
+		notifBody := fmt.Sprintf("Kamu menerima %d Xpoin dari setoran sampah.", totalXpoin)
+		s.notifService.SendNotification(userID, notifTitle, notifBody, "DEPOSIT_SUCCESS")
 	}()
 
-	// 10. Kembalikan data header deposit yang baru dibuat
+	// 5. Kembalikan data header deposit yang baru dibuat
 	// (Untuk sementara kembalikan ID saja, atau bisa buat fungsi GetDepositHeaderByID di repo)
-	return &DepositHistoryHeader{ID: depositHeaderID, PartnerID: partnerID, UserID: req.UserID, TotalXpoin: totalXpoin, TransactionTime: transactionTime}, nil
+	return &DepositHistoryHeader{ID: depositHeaderID, PartnerID: partnerID, UserID: userID, TotalXpoin: totalXpoin, TransactionTime: transactionTime}, nil
+}
+
+// --- Deposit Session Service Methods ---
+
+// GenerateStationQr membuat token QR stasiun yang dipindai user untuk membuka sesi deposit
+func (s *PartnerService) GenerateStationQr(partnerIDStr string, req GenerateStationQrRequest) (*StationQrResponse, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+
+	validity := stationQrDefaultValidity
+	if req.ValidityHours > 0 {
+		validity = time.Duration(req.ValidityHours) * time.Hour
+	}
+
+	token, expiresAt, err := s.tokenStore.CreateStationToken(partnerID, validity)
+	if err != nil {
+		return nil, err
+	}
+	return &StationQrResponse{Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// GetDepositSessions mengambil sesi deposit di partner, opsional difilter status
+func (s *PartnerService) GetDepositSessions(partnerIDStr string, status string) ([]user.DepositSession, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+	return s.repo.GetDepositSessionsByPartnerID(partnerID, status)
+}
+
+// GetDepositSessionByID mengambil detail satu sesi deposit di partner
+func (s *PartnerService) GetDepositSessionByID(sessionID int, partnerIDStr string) (*user.DepositSession, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+	session, err := s.repo.GetDepositSessionByIDForPartner(sessionID, partnerID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil sesi deposit")
+	}
+	if session == nil {
+		return nil, errors.New("sesi deposit tidak ditemukan")
+	}
+	return session, nil
+}
+
+// UpdateDepositSessionItems mengisi (atau mengganti) item hasil timbang pada sesi deposit
+func (s *PartnerService) UpdateDepositSessionItems(sessionID int, partnerIDStr string, req UpdateDepositSessionItemsRequest) (*user.DepositSession, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+
+	session, err := s.GetDepositSessionByID(sessionID, partnerIDStr)
+	if err != nil {
+		return nil, err
+	}
+	if session.Status != user.DepositSessionOpen && session.Status != user.DepositSessionItemsAdded {
+		return nil, errors.New("sesi deposit sudah selesai")
+	}
+
+	items, totalWeight, estimatedXpoin, err := s.priceDepositSessionItems(partnerID, req.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.UpdateDepositSessionItems(sessionID, partnerID, items, totalWeight, estimatedXpoin)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("sesi deposit sudah selesai")
+	}
+	if err != nil {
+		return nil, errors.New("gagal menyimpan item sesi deposit")
+	}
+
+	go func() {
+		notifTitle := "Sampahmu Sudah Ditimbang"
+		notifBody := fmt.Sprintf("Mitra mencatat %.2f kg sampah, estimasi %d Xpoin.", totalWeight, estimatedXpoin)
+		s.notifService.SendNotification(session.UserID, notifTitle, notifBody, "DEPOSIT_SESSION_ITEMS_ADDED")
+	}()
+
+	return s.GetDepositSessionByID(sessionID, partnerIDStr)
+}
+
+// priceDepositSessionItems menghitung estimasi Xpoin item sesi dengan aturan satuan yang sama seperti
+// createDepositForUser: item kg memakai weight, item pcs memakai quantity
+func (s *PartnerService) priceDepositSessionItems(partnerID int, reqItems []DepositWasteItem) ([]user.DepositSessionItem, float64, int, error) {
+	items := []user.DepositSessionItem{}
+	totalWeight, estimatedXpoin := 0.0, 0
+	for _, reqItem := range reqItems {
+		detail, err := s.repo.GetWastePriceDetailByID(reqItem.PartnerWastePriceDetailID, partnerID)
+		if err != nil {
+			return nil, 0, 0, errors.New("gagal mengambil info harga sampah")
+		}
+		if detail == nil {
+			return nil, 0, 0, fmt.Errorf("detail harga sampah ID %d tidak ditemukan", reqItem.PartnerWastePriceDetailID)
+		}
+		priceInfo, err := s.repo.GetWastePriceInfoForCalculation(detail.ID, partnerID)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+
+		item := DepositWasteItem{PartnerWastePriceDetailID: detail.ID, Weight: reqItem.Weight, Quantity: reqItem.Quantity}
+		itemXpoin, err := applyWasteUnit(&item, priceInfo)
+		if err != nil {
+			return nil, 0, 0, err
+		}
+		items = append(items, user.DepositSessionItem{
+			PartnerWastePriceDetailID: detail.ID,
+			Name:                      detail.Name,
+			Weight:                    item.Weight,
+			Unit:                      item.Unit,
+			Quantity:                  item.Quantity,
+			EstimatedXpoin:            itemXpoin,
+		})
+		totalWeight += item.Weight
+		estimatedXpoin += itemXpoin
+	}
+	return items, totalWeight, estimatedXpoin, nil
+}
+
+// depositItemsFromSession menyusun ulang item deposit dari item sesi: item pcs dikirim sebagai quantity
+// (beratnya hasil konversi), item kg dan item sesi lama tanpa satuan sebagai weight
+func depositItemsFromSession(items []user.DepositSessionItem) []DepositWasteItem {
+	depositItems := make([]DepositWasteItem, 0, len(items))
+	for _, item := range items {
+		depositItem := DepositWasteItem{PartnerWastePriceDetailID: item.PartnerWastePriceDetailID, Weight: item.Weight}
+		if item.Unit == WasteUnitPcs {
+			depositItem = DepositWasteItem{PartnerWastePriceDetailID: item.PartnerWastePriceDetailID, Quantity: item.Quantity}
+		}
+		depositItems = append(depositItems, depositItem)
+	}
+	return depositItems
+}
+
+// ConfirmDepositSession mengubah sesi deposit menjadi deposit nyata (Xpoin dikirim ke user)
+func (s *PartnerService) ConfirmDepositSession(sessionID int, partnerIDStr string, req ConfirmDepositSessionRequest, imageFile *multipart.FileHeader) (*user.DepositSession, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+
+	session, err := s.GetDepositSessionByID(sessionID, partnerIDStr)
+	if err != nil {
+		return nil, err
+	}
+	switch session.Status {
+	case user.DepositSessionOpen:
+		return nil, errors.New("item sampah sesi deposit belum diisi")
+	case user.DepositSessionItemsAdded:
+	default:
+		return nil, errors.New("sesi deposit sudah selesai")
+	}
+
+	// Susun ulang items_json dari item sesi agar bisa memakai alur CreateDeposit
+	itemsJSON, err := json.Marshal(depositItemsFromSession(session.Items))
+	if err != nil {
+		return nil, errors.New("gagal memproses item sesi deposit")
+	}
+
+	depositReq := CreateDepositRequest{
+		UserID:          session.UserID,
+		DepositMethodID: req.DepositMethodID,
+		ItemsJSON:       string(itemsJSON),
+		Notes:           req.Notes,
+		StaffID:         req.StaffID,
+		SourceType:      FraudCaseSourceDepositSession,
+		SourceID:        sessionID,
+	}
+
+	// Kunci sesi (Items Added -> Confirmed) tepat sebelum transaksi agar tidak dikonfirmasi dua kali
+	// dan tidak bisa dibatalkan user di tengah proses. Status hanya dikembalikan jika transaksi deposit
+	// gagal; error setelah Xpoin partner terdebit tidak membuka sesi lagi. Deposit yang ditahan anti-fraud
+	// memindahkan sesi ke Held di transaksi kasus fraud sampai admin memutuskan.
+	consume := func(*sql.Tx) (func(), error) {
+		if err := s.repo.UpdateDepositSessionStatus(sessionID, partnerID, user.DepositSessionItemsAdded, user.DepositSessionConfirmed); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, errors.New("sesi deposit sudah selesai")
+			}
+			return nil, errors.New("gagal mengonfirmasi sesi deposit")
+		}
+		return func() {
+			// Kembalikan status agar partner bisa mencoba lagi
+			if errRevert := s.repo.UpdateDepositSessionStatus(sessionID, partnerID, user.DepositSessionConfirmed, user.DepositSessionItemsAdded); errRevert != nil {
+				log.Printf("Failed to revert deposit session %d after deposit error: %v", sessionID, errRevert)
+			}
+		}, nil
+	}
+
+	header, err := s.createDepositForUser(partnerID, depositReq, imageFile, consume)
+	if err != nil {
+		return nil, err
+	}
+
+	if header.FraudCaseID != 0 {
+		log.Printf("Deposit session %d held for fraud review (case %d)", sessionID, header.FraudCaseID)
+	} else if err := s.repo.SetDepositSessionDepositHeader(sessionID, header.ID); err != nil {
+		log.Printf("Warning: deposit session %d confirmed as deposit %d but link was not saved", sessionID, header.ID)
+	}
+
+	return s.GetDepositSessionByID(sessionID, partnerIDStr)
+}
+
+// CancelDepositSession membatalkan sesi deposit di partner yang belum dikonfirmasi
+func (s *PartnerService) CancelDepositSession(sessionID int, partnerIDStr string) error {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return errors.New("ID partner tidak valid")
+	}
+
+	session, err := s.GetDepositSessionByID(sessionID, partnerIDStr)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.CancelDepositSessionByPartner(sessionID, partnerID)
+	if err == sql.ErrNoRows {
+		return errors.New("sesi deposit sudah selesai")
+	}
+	if err != nil {
+		return errors.New("gagal membatalkan sesi deposit")
+	}
+
+	go func() {
+		notifTitle := "Sesi Deposit Dibatalkan"
+		notifBody := "Mitra membatalkan sesi setoran sampahmu."
+		s.notifService.SendNotification(session.UserID, notifTitle, notifBody, "DEPOSIT_SESSION_CANCELLED")
+	}()
+	return nil
+}
+
+// --- Deposit Draft (Dua Tahap) Service Methods ---
+
+// CreateDepositDraft membuat draft deposit yang harus diterima user sebelum Xpoin berpindah.
+// Token/kode QR tetap dipakai saat draft dibuat sehingga tidak bisa dipakai ulang. Draft melewati aturan
+// fraud yang sama dengan deposit langsung; deposit yang ditahan disimpan sebagai kasus fraud, bukan draft.
+func (s *PartnerService) CreateDepositDraft(partnerIDStr string, req CreateDepositRequest, imageFile *multipart.FileHeader) (*user.DepositDraft, *DepositFraudCase, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, nil, errors.New("ID partner tidak valid")
+	}
+
+	consume, err := s.resolveDepositUser(partnerID, &req)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	depositArgs, err := s.prepareDeposit(partnerID, req, imageFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	decision, hits, err := s.screenDeposit(*depositArgs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := s.attachDepositPhotos(depositArgs, req, imageFile); err != nil {
+		return nil, nil, err
+	}
+	consume = consume.withRestore(func() { removeDepositArgsPhotos(*depositArgs) })
+
+	if decision == fraud.ActionHold {
+		fraudCase, err := s.recordDepositFraudCase(*depositArgs, hits, FraudCaseHeld, consume)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, fraudCase, nil
+	}
+
+	// Simpan item lengkap dengan nama agar user bisa memeriksa hasil timbang
+	items := s.draftItemsFromDeposit(partnerID, depositArgs.Items)
+
+	draft := &user.DepositDraft{
+		PartnerID:       partnerID,
+		UserID:          req.UserID,
+		DepositMethodID: req.DepositMethodID,
+		Items:           items,
+		TotalXpoin:      depositArgs.TotalXpoin,
+		Notes:           depositArgs.Notes,
+		Photo:           depositArgs.PhotoURL,
+		Photos:          depositArgs.Photos,
+		ExpiresAt:       time.Now().Add(depositDraftConfirmationWindow),
+		StaffID:         depositArgs.StaffID,
+	}
+	err = consume.inTx(func(hook func(tx *sql.Tx) error) error {
+		return s.repo.CreateDepositDraft(draft, depositArgs.TotalWeight, hook)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	log.Printf("Deposit draft %d created by partner ID %d for user ID %d (%d Xpoin)", draft.ID, partnerID, draft.UserID, draft.TotalXpoin)
+
+	go func() {
+		notifTitle := "Konfirmasi Setoran Sampah"
+		notifBody := fmt.Sprintf("Mitra mencatat %s kg sampah senilai %d Xpoin. Terima atau tolak sebelum %s.",
+			draft.TotalWeight, draft.TotalXpoin, draft.ExpiresAt.Format("02 Jan 15:04"))
+		s.notifService.SendNotification(draft.UserID, notifTitle, notifBody, "DEPOSIT_CONFIRMATION_REQUIRED")
+	}()
+
+	return draft, nil, nil
+}
+
+// draftItemsFromDeposit menyimpan item deposit yang sudah dihitung dalam format draft (lengkap dengan nama)
+func (s *PartnerService) draftItemsFromDeposit(partnerID int, depositItems []DepositWasteItem) []user.DepositDraftItem {
+	items := make([]user.DepositDraftItem, 0, len(depositItems))
+	for _, item := range depositItems {
+		draftItem := user.DepositDraftItem{
+			PartnerWastePriceDetailID: item.PartnerWastePriceDetailID,
+			Weight:                    item.Weight,
+			Unit:                      item.Unit,
+			Quantity:                  item.Quantity,
+			ScaleReadingID:            item.ScaleReadingID,
+			Xpoin:                     item.CalculatedXpoin,
+			WasteDetailID:             int(item.WasteDetailID.Int32),
+			PriceVersionID:            int(item.PriceVersionID.Int32),
+			Photos:                    item.Photos,
+		}
+		if detail, err := s.repo.GetWastePriceDetailByID(item.PartnerWastePriceDetailID, partnerID); err == nil && detail != nil {
+			draftItem.Name = detail.Name
+		}
+		items = append(items, draftItem)
+	}
+	return items
+}
+
+// depositItemsFromDraft mengembalikan item format draft menjadi item deposit siap dijalankan beserta total beratnya
+func depositItemsFromDraft(draftItems []user.DepositDraftItem) ([]DepositWasteItem, float64) {
+	items := make([]DepositWasteItem, 0, len(draftItems))
+	totalWeight := 0.0
+	for _, item := range draftItems {
+		if item.Unit == "" {
+			item.Unit = WasteUnitKg // Draft lama sebelum satuan dicatat
+		}
+		items = append(items, DepositWasteItem{
+			PartnerWastePriceDetailID: item.PartnerWastePriceDetailID,
+			Weight:                    item.Weight,
+			Quantity:                  item.Quantity,
+			Unit:                      item.Unit,
+			ScaleReadingID:            item.ScaleReadingID,
+			CalculatedXpoin:           item.Xpoin,
+			WasteDetailID:             sql.NullInt32{Int32: int32(item.WasteDetailID), Valid: item.WasteDetailID != 0},
+			PriceVersionID:            sql.NullInt32{Int32: int32(item.PriceVersionID), Valid: item.PriceVersionID != 0},
+			Photos:                    item.Photos,
+		})
+		totalWeight += item.Weight
+	}
+	return items, totalWeight
+}
+
+// GetDepositDrafts mengambil draft deposit yang dibuat partner, opsional difilter status
+func (s *PartnerService) GetDepositDrafts(partnerIDStr string, status string) ([]user.DepositDraft, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+	return s.repo.GetDepositDraftsByPartnerID(partnerID, status)
+}
+
+// AcceptDepositDraft dipanggil saat user menerima draft deposit miliknya
+func (s *PartnerService) AcceptDepositDraft(draftID int, userID int) (*user.DepositDraft, error) {
+	draft, err := s.repo.GetDepositDraftByID(draftID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil draft deposit")
+	}
+	if draft == nil || draft.UserID != userID {
+		return nil, errors.New("draft deposit tidak ditemukan")
+	}
+
+	if err := s.acceptDepositDraft(draft, "user"); err != nil {
+		return nil, err
+	}
+	return s.repo.GetDepositDraftByID(draftID)
+}
+
+// acceptDepositDraft mengunci draft lalu menjalankan deposit (wallet & statistik berubah di sini)
+func (s *PartnerService) acceptDepositDraft(draft *user.DepositDraft, decidedBy string) error {
+	// Draft dikunci (Pending -> Accepted) tepat sebelum transaksi. Jika transaksi deposit gagal, draft
+	// kembali ke Pending untuk dicoba lagi (misal setelah partner top up Xpoin) sampai batas percobaan;
+	// error setelah Xpoin partner terdebit tidak mengembalikan draft agar tidak diterima dua kali.
+	consume := func(*sql.Tx) (func(), error) {
+		if err := s.repo.AcceptDepositDraft(draft.ID, decidedBy); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, errors.New("draft deposit sudah diproses")
+			}
+			return nil, errors.New("gagal menerima draft deposit")
+		}
+		return func() { s.revertFailedDepositDraft(draft) }, nil
+	}
+
+	items, totalWeight := depositItemsFromDraft(draft.Items)
+	depositArgs := ArgsDepositCreation{
+		PartnerID:       draft.PartnerID,
+		UserID:          draft.UserID,
+		DepositMethodID: draft.DepositMethodID,
+		Items:           items,
+		TotalWeight:     totalWeight,
+		TotalXpoin:      draft.TotalXpoin,
+		Notes:           draft.Notes,
+		PhotoURL:        draft.Photo,
+		Photos:          draft.Photos,
+		TransactionTime: time.Now(),
+		StaffID:         draft.StaffID,
+		SourceType:      FraudCaseSourceDepositDraft,
+		SourceID:        draft.ID,
+	}
+
+	// Aturan fraud dinilai ulang pada waktu transaksi sebenarnya (riwayat user bisa berubah sejak draft dibuat)
+	decision, hits, err := s.screenDeposit(depositArgs)
+	if err != nil {
+		if decision == fraud.ActionReject {
+			s.failDepositDraft(draft, decidedBy, err.Error())
+		}
+		return err
+	}
+	if decision == fraud.ActionHold {
+		fraudCase, err := s.recordDepositFraudCase(depositArgs, hits, FraudCaseHeld, consume)
+		if err != nil {
+			return err
+		}
+		log.Printf("Deposit draft %d accepted (%s) but held for fraud review (case %d)", draft.ID, decidedBy, fraudCase.ID)
+		go func() {
+			notifBody := fmt.Sprintf("Setoran %d Xpoin ditahan untuk ditinjau admin sebelum Xpoin dipindahkan.", draft.TotalXpoin)
+			s.notifService.SendNotification(draft.UserID, "Setoran Ditinjau", notifBody, "DEPOSIT_HELD_FOR_REVIEW")
+		}()
+		return nil
+	}
+
+	header, err := s.executeDeposit(depositArgs, consume)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.SetDepositDraftDepositHeader(draft.ID, header.ID); err != nil {
+		log.Printf("Warning: deposit draft %d accepted as deposit %d but link was not saved", draft.ID, header.ID)
+	}
+	log.Printf("Deposit draft %d accepted (%s), deposit ID %d", draft.ID, decidedBy, header.ID)
+	return nil
+}
+
+// revertFailedDepositDraft mencatat percobaan draft yang gagal; draft yang mencapai batas percobaan menjadi Failed
+func (s *PartnerService) revertFailedDepositDraft(draft *user.DepositDraft) {
+	status, err := s.repo.RevertFailedDepositDraft(draft.ID, maxDepositDraftAttempts)
+	if err != nil || status != user.DepositDraftFailed {
+		return
+	}
+	log.Printf("Deposit draft %d failed after %d attempts", draft.ID, maxDepositDraftAttempts)
+
+	go func() {
+		notifBody := fmt.Sprintf("Setoran %d Xpoin tidak dapat diproses setelah %d percobaan. Hubungi mitra untuk mencatat ulang.", draft.TotalXpoin, maxDepositDraftAttempts)
+		s.notifService.SendNotification(draft.UserID, "Setoran Gagal Diproses", notifBody, "DEPOSIT_DRAFT_FAILED")
+		partnerBody := fmt.Sprintf("Draft setoran %d Xpoin gagal diproses setelah %d percobaan (periksa saldo Xpoin).", draft.TotalXpoin, maxDepositDraftAttempts)
+		s.notifService.SendPartnerNotification(draft.PartnerID, "Draft Setoran Gagal", partnerBody, "DEPOSIT_DRAFT_FAILED")
+	}()
+}
+
+// failDepositDraft menghentikan draft Pending yang ditolak aturan anti-fraud saat akan diterima
+func (s *PartnerService) failDepositDraft(draft *user.DepositDraft, decidedBy string, reason string) {
+	if err := s.repo.FailDepositDraft(draft.ID, decidedBy); err != nil {
+		log.Printf("Failed to mark deposit draft %d as failed after fraud rejection: %v", draft.ID, err)
+		return
+	}
+	log.Printf("Deposit draft %d failed (%s): %s", draft.ID, decidedBy, reason)
+
+	go func() {
+		notifBody := fmt.Sprintf("Setoran %d Xpoin tidak dapat diproses karena ditolak sistem anti-fraud.", draft.TotalXpoin)
+		s.notifService.SendNotification(draft.UserID, "Setoran Gagal Diproses", notifBody, "DEPOSIT_DRAFT_FAILED")
+		s.notifService.SendPartnerNotification(draft.PartnerID, "Draft Setoran Gagal", notifBody, "DEPOSIT_DRAFT_FAILED")
+	}()
+}
+
+// autoAcceptExpiredDepositDrafts berjalan di background untuk menerima draft yang melewati batas waktu.
+// Aman dijalankan di banyak replika karena penerimaan draft bersifat atomik.
+func (s *PartnerService) autoAcceptExpiredDepositDrafts(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		drafts, err := s.repo.GetExpiredPendingDepositDrafts(time.Now())
+		if err != nil {
+			continue
+		}
+		for i := range drafts {
+			if err := s.acceptDepositDraft(&drafts[i], "auto"); err != nil {
+				log.Printf("Failed to auto-accept deposit draft %d: %v", drafts[i].ID, err)
+			}
+		}
+	}
+}
+
+// --- Deposit Void & Correction Service Methods ---
+
+// loadDepositForAdjustment mengambil deposit yang boleh di-void/dikoreksi.
+// partnerID 0 berarti dipanggil admin: tanpa cek kepemilikan dan batas waktu.
+func (s *PartnerService) loadDepositForAdjustment(depositID int, partnerID int) (*DepositAdjustmentTarget, error) {
+	target, err := s.repo.GetDepositForAdjustment(depositID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil data deposit")
+	}
+	if target == nil || (partnerID != 0 && target.PartnerID != partnerID) {
+		return nil, errors.New("deposit tidak ditemukan")
+	}
+	if target.VoidedAt.Valid {
+		return nil, errors.New("deposit sudah dibatalkan")
+	}
+	if partnerID != 0 && time.Since(target.TransactionTime) > depositAdjustmentGracePeriod {
+		return nil, errors.New("batas waktu koreksi deposit sudah lewat, hubungi admin")
+	}
+	return target, nil
+}
+
+// applyDepositAdjustment menghitung selisih dampak lingkungan tiap item, menjalankan transaksi, lalu memberi tahu user
+func (s *PartnerService) applyDepositAdjustment(args ArgsDepositAdjustment) error {
+	wasteDetailIDs := []int{}
+	for _, d := range args.Target.Details {
+		if d.WasteDetailID.Valid {
+			wasteDetailIDs = append(wasteDetailIDs, int(d.WasteDetailID.Int32))
+		}
+	}
+	factorsMap, err := s.userRepo.GetWasteDetailFactors(wasteDetailIDs)
+	if err != nil {
+		log.Printf("Warning: Failed to get waste detail factors: %v", err)
+		factorsMap = make(map[int]user.ImpactFactors)
+	}
+
+	wasteDetailByDetailID := make(map[int]sql.NullInt32, len(args.Target.Details))
+	for _, d := range args.Target.Details {
+		wasteDetailByDetailID[d.ID] = d.WasteDetailID
+	}
+	xpoinDelta := 0
+	for i := range args.Lines {
+		line := &args.Lines[i]
+		xpoinDelta += line.NewXpoin - line.OldXpoin
+		wasteDetailID := wasteDetailByDetailID[line.DetailID]
+		if !wasteDetailID.Valid {
+			continue
+		}
+		if factors, ok := factorsMap[int(wasteDetailID.Int32)]; ok {
+			weightDelta := line.NewWeight - line.OldWeight
+			line.EnergyDelta = weightDelta * factors.Energy
+			line.CO2Delta = weightDelta * factors.CO2
+			line.WaterDelta = weightDelta * factors.Water
+			line.TreeDelta = int(math.Round(weightDelta * factors.Tree))
+		}
+	}
+
+	if err := s.repo.ExecuteDepositAdjustmentTransaction(args); err != nil {
+		return err
+	}
+
+	userID, depositID := args.Target.UserID, args.Target.ID
+	go func() {
+		if args.Type == "void" {
+			notifBody := fmt.Sprintf("Deposit #%d dibatalkan: %s. %d Xpoin ditarik dari saldomu.", depositID, args.Reason, -xpoinDelta)
+			s.notifService.SendNotification(userID, "Deposit Dibatalkan", notifBody, "DEPOSIT_VOIDED")
+			return
+		}
+		var notifBody string
+		switch {
+		case xpoinDelta > 0:
+			notifBody = fmt.Sprintf("Deposit #%d dikoreksi: %s. Kamu menerima tambahan %d Xpoin.", depositID, args.Reason, xpoinDelta)
+		case xpoinDelta < 0:
+			notifBody = fmt.Sprintf("Deposit #%d dikoreksi: %s. %d Xpoin ditarik dari saldomu.", depositID, args.Reason, -xpoinDelta)
+		default:
+			notifBody = fmt.Sprintf("Deposit #%d dikoreksi: %s.", depositID, args.Reason)
+		}
+		s.notifService.SendNotification(userID, "Deposit Dikoreksi", notifBody, "DEPOSIT_CORRECTED")
+	}()
+	return nil
+}
+
+// voidDeposit membatalkan seluruh item deposit dan membalik Xpoin serta statistiknya
+func (s *PartnerService) voidDeposit(depositID int, partnerID int, actorType string, actorID sql.NullInt32, reason string) error {
+	target, err := s.loadDepositForAdjustment(depositID, partnerID)
+	if err != nil {
+		return err
+	}
+
+	lines := make([]DepositAdjustmentLine, 0, len(target.Details))
+	for _, d := range target.Details {
+		lines = append(lines, DepositAdjustmentLine{DetailID: d.ID, OldWeight: d.Weight, OldQuantity: d.Quantity, OldXpoin: d.Xpoin})
+	}
+	return s.applyDepositAdjustment(ArgsDepositAdjustment{
+		Target: target, Type: "void", ActorType: actorType, ActorID: actorID, Reason: reason, Lines: lines,
+	})
+}
+
+// correctDepositDetail mengganti berat (kg) atau jumlah (pcs) satu item
+func (s *PartnerService) correctDepositDetail(depositID, detailID int, partnerID int, actorType string, actorID sql.NullInt32, req CorrectDepositDetailRequest) error {
+	target, err := s.loadDepositForAdjustment(depositID, partnerID)
+	if err != nil {
+		return err
+	}
+
+	var detail *DepositAdjustmentTargetDetail
+	for i := range target.Details {
+		if target.Details[i].ID == detailID {
+			detail = &target.Details[i]
+			break
+		}
+	}
+	if detail == nil {
+		return errors.New("detail deposit tidak ditemukan")
+	}
+
+	line, err := correctedDepositLine(*detail, req)
+	if err != nil {
+		return err
+	}
+	return s.applyDepositAdjustment(ArgsDepositAdjustment{
+		Target: target, Type: "correction", ActorType: actorType, ActorID: actorID, Reason: req.Reason,
+		Lines: []DepositAdjustmentLine{line},
+	})
+}
+
+// correctedDepositLine menghitung nilai baru item yang dikoreksi. Xpoin dihitung ulang dari Xpoin per kg/pcs
+// versi harga yang dipakai saat deposit (bukan rasio Xpoin lama) sehingga koreksi berulang tidak menggerus Xpoin.
+// Item lama tanpa versi harga memakai rasio terhadap nilai sebelumnya.
+func correctedDepositLine(detail DepositAdjustmentTargetDetail, req CorrectDepositDetailRequest) (DepositAdjustmentLine, error) {
+	line := DepositAdjustmentLine{DetailID: detail.ID, OldWeight: detail.Weight, OldQuantity: detail.Quantity, OldXpoin: detail.Xpoin}
+	if detail.Unit == WasteUnitPcs && detail.Quantity.Valid && detail.Quantity.Int32 > 0 {
+		// Item pcs dikoreksi lewat jumlah; berat mengikuti berat per buah saat deposit
+		oldQuantity := int(detail.Quantity.Int32)
+		if req.Quantity <= 0 {
+			return line, errors.New("jumlah baru (quantity) wajib diisi untuk item bersatuan pcs")
+		}
+		if req.Quantity == oldQuantity {
+			return line, errors.New("jumlah baru sama dengan jumlah sebelumnya")
+		}
+		line.NewQuantity = sql.NullInt32{Int32: int32(req.Quantity), Valid: true}
+		line.NewWeight = math.Round(detail.Weight*float64(req.Quantity)/float64(oldQuantity)*100) / 100
+		if detail.PriceXpoin.Valid {
+			line.NewXpoin = req.Quantity * int(detail.PriceXpoin.Int32)
+		} else {
+			line.NewXpoin = int(math.Floor(float64(req.Quantity) * float64(detail.Xpoin) / float64(oldQuantity)))
+		}
+		return line, nil
+	}
+
+	if req.Weight <= 0 {
+		return line, errors.New("berat baru (weight) wajib diisi untuk item bersatuan kg")
+	}
+	if detail.Weight == req.Weight {
+		return line, errors.New("berat baru sama dengan berat sebelumnya")
+	}
+	line.NewWeight = req.Weight
+	if detail.PriceXpoin.Valid {
+		line.NewXpoin = int(math.Floor(req.Weight * float64(detail.PriceXpoin.Int32)))
+	} else if detail.Weight > 0 {
+		line.NewXpoin = int(math.Floor(req.Weight * float64(detail.Xpoin) / detail.Weight))
+	}
+	return line, nil
+}
+
+// VoidDeposit membatalkan deposit milik partner dalam masa tenggang
+func (s *PartnerService) VoidDeposit(depositID int, partnerIDStr string, req VoidDepositRequest) error {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return errors.New("ID partner tidak valid")
+	}
+	return s.voidDeposit(depositID, partnerID, "partner", sql.NullInt32{Int32: int32(partnerID), Valid: true}, req.Reason)
+}
+
+// CorrectDepositDetail mengoreksi berat satu item deposit milik partner dalam masa tenggang
+func (s *PartnerService) CorrectDepositDetail(depositID, detailID int, partnerIDStr string, req CorrectDepositDetailRequest) error {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return errors.New("ID partner tidak valid")
+	}
+	return s.correctDepositDetail(depositID, detailID, partnerID, "partner", sql.NullInt32{Int32: int32(partnerID), Valid: true}, req)
+}
+
+// GetDepositAdjustments mengambil jejak audit void/koreksi deposit milik partner
+func (s *PartnerService) GetDepositAdjustments(depositID int, partnerIDStr string) ([]DepositAdjustment, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+	target, err := s.repo.GetDepositForAdjustment(depositID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil data deposit")
+	}
+	if target == nil || target.PartnerID != partnerID {
+		return nil, errors.New("deposit tidak ditemukan")
+	}
+	return s.depositAdjustmentsWithPhotos(depositID)
+}
+
+// AdminVoidDeposit membatalkan deposit oleh admin tanpa batas waktu
+func (s *PartnerService) AdminVoidDeposit(depositID int, req VoidDepositRequest) error {
+	return s.voidDeposit(depositID, 0, "admin", sql.NullInt32{}, req.Reason)
+}
+
+// AdminCorrectDepositDetail mengoreksi item deposit oleh admin tanpa batas waktu
+func (s *PartnerService) AdminCorrectDepositDetail(depositID, detailID int, req CorrectDepositDetailRequest) error {
+	return s.correctDepositDetail(depositID, detailID, 0, "admin", sql.NullInt32{}, req)
+}
+
+// AdminGetDepositAdjustments mengambil jejak audit void/koreksi deposit mana pun
+func (s *PartnerService) AdminGetDepositAdjustments(depositID int) ([]DepositAdjustment, error) {
+	target, err := s.repo.GetDepositForAdjustment(depositID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil data deposit")
+	}
+	if target == nil {
+		return nil, errors.New("deposit tidak ditemukan")
+	}
+	return s.depositAdjustmentsWithPhotos(depositID)
+}
+
+// depositAdjustmentsWithPhotos mengambil jejak audit deposit, koreksi item disertai foto bukti item tersebut
+func (s *PartnerService) depositAdjustmentsWithPhotos(depositID int) ([]DepositAdjustment, error) {
+	adjustments, err := s.repo.GetDepositAdjustments(depositID)
+	if err != nil {
+		return nil, err
+	}
+	photos, err := s.repo.GetDepositPhotos(depositID)
+	if err != nil {
+		log.Printf("Warning: Failed to get photos for deposit %d adjustments: %v", depositID, err)
+		return adjustments, nil
+	}
+	for i := range adjustments {
+		if !adjustments[i].DetailID.Valid {
+			continue
+		}
+		for _, photo := range photos {
+			if photo.DetailID == adjustments[i].DetailID {
+				adjustments[i].Photos = append(adjustments[i].Photos, photo)
+			}
+		}
+	}
+	return adjustments, nil
+}
+
+// AddDepositPhotos menambahkan foto bukti ke deposit yang sudah tercatat (opsional untuk satu item).
+// Diizinkan selama masa koreksi, atau selama ada sengketa yang belum selesai (foto ditautkan ke sengketa).
+func (s *PartnerService) AddDepositPhotos(depositID int, partnerIDStr string, detailID int, files []*multipart.FileHeader) ([]user.DepositPhoto, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+	if len(files) == 0 {
+		return nil, errors.New("minimal harus ada satu foto")
+	}
+	if len(files) > maxDepositPhotos {
+		return nil, fmt.Errorf("maksimal %d foto per unggahan", maxDepositPhotos)
+	}
+
+	target, err := s.repo.GetDepositForAdjustment(depositID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil data deposit")
+	}
+	if target == nil || target.PartnerID != partnerID {
+		return nil, errors.New("deposit tidak ditemukan")
+	}
+	detail := sql.NullInt32{}
+	if detailID != 0 {
+		for _, d := range target.Details {
+			if d.ID == detailID {
+				detail = sql.NullInt32{Int32: int32(detailID), Valid: true}
+			}
+		}
+		if !detail.Valid {
+			return nil, errors.New("detail deposit tidak ditemukan")
+		}
+	}
+
+	disputeID, err := s.repo.FindUnresolvedDepositDisputeID(depositID)
+	if err != nil {
+		return nil, errors.New("gagal memeriksa sengketa deposit")
+	}
+	if disputeID == 0 && time.Since(target.TransactionTime) > depositAdjustmentGracePeriod {
+		return nil, errors.New("batas waktu menambah foto deposit sudah lewat")
+	}
+
+	photos, err := s.saveDepositPhotos(partnerID, target.UserID, files)
+	if err != nil {
+		return nil, err
+	}
+	for i := range photos {
+		photos[i].DetailID = detail
+		photos[i].DepositDisputeID = sql.NullInt32{Int32: int32(disputeID), Valid: disputeID != 0}
+	}
+	if err := s.repo.AddDepositPhotos(depositID, photos); err != nil {
+		removeDepositPhotoFiles(photos)
+		return nil, err
+	}
+	log.Printf("%d photo(s) added to deposit ID %d by partner ID %d", len(photos), depositID, partnerID)
+	return s.repo.GetDepositPhotos(depositID)
+}
+
+// --- Deposit Dispute Service Methods ---
+
+// GetDepositDisputes mengambil sengketa atas deposit partner, opsional difilter status
+func (s *PartnerService) GetDepositDisputes(partnerIDStr string, status string) ([]user.DepositDispute, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+	return s.repo.GetDepositDisputesByPartnerID(partnerID, status)
+}
+
+// GetDepositDisputeByID mengambil detail satu sengketa atas deposit partner
+func (s *PartnerService) GetDepositDisputeByID(disputeID int, partnerIDStr string) (*user.DepositDispute, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+	dispute, err := s.repo.GetDepositDisputeByID(disputeID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil sengketa deposit")
+	}
+	if dispute == nil || dispute.PartnerID != partnerID {
+		return nil, errors.New("sengketa deposit tidak ditemukan")
+	}
+	s.attachDisputeDepositPhotos(dispute)
+	return dispute, nil
+}
+
+// attachDisputeDepositPhotos mengisi foto bukti deposit pada detail sengketa
+func (s *PartnerService) attachDisputeDepositPhotos(dispute *user.DepositDispute) {
+	photos, err := s.repo.GetDepositPhotos(dispute.PartnerDepositHistoryID)
+	if err != nil {
+		log.Printf("Warning: Failed to get deposit photos for dispute %d: %v", dispute.ID, err)
+		return
+	}
+	dispute.DepositPhotos = photos
+}
+
+// RespondDepositDispute menyimpan tanggapan partner; sengketa lalu menunggu keputusan admin
+func (s *PartnerService) RespondDepositDispute(disputeID int, partnerIDStr string, req RespondDepositDisputeRequest) (*user.DepositDispute, error) {
+	dispute, err := s.GetDepositDisputeByID(disputeID, partnerIDStr)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.RespondDepositDispute(disputeID, dispute.PartnerID, req.Response)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("sengketa deposit sudah ditanggapi atau diputuskan")
+	}
+	if err != nil {
+		return nil, errors.New("gagal menyimpan tanggapan sengketa")
+	}
+
+	go func() {
+		notifBody := fmt.Sprintf("Partner menanggapi keberatanmu atas deposit #%d. Admin akan meninjau dan memberi keputusan.", dispute.UserDepositHistoryID)
+		s.notifService.SendNotification(dispute.UserID, "Sengketa Deposit Ditanggapi", notifBody, "DEPOSIT_DISPUTE_RESPONDED")
+	}()
+	return s.repo.GetDepositDisputeByID(disputeID)
+}
+
+// --- Ulasan Partner ---
+
+// maxPartnerReviewReply batas panjang balasan partner atas ulasan
+const maxPartnerReviewReply = 1000
+
+// GetPartnerReviews mengambil ulasan atas partner (termasuk yang disembunyikan admin), opsional difilter status
+func (s *PartnerService) GetPartnerReviews(partnerIDStr string, status string) ([]user.PartnerReview, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+	reviews, err := s.repo.GetPartnerReviewsByPartnerID(partnerID, status)
+	if err != nil {
+		return nil, errors.New("gagal mengambil ulasan")
+	}
+	return reviews, nil
+}
+
+// ReplyPartnerReview menyimpan balasan publik partner; balasan baru mengganti yang lama
+func (s *PartnerService) ReplyPartnerReview(reviewID int, partnerIDStr string, req ReplyPartnerReviewRequest) (*user.PartnerReview, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+	reply := strings.TrimSpace(req.Reply)
+	if reply == "" {
+		return nil, errors.New("balasan wajib diisi")
+	}
+	if len(reply) > maxPartnerReviewReply {
+		return nil, fmt.Errorf("balasan terlalu panjang (maks %d karakter)", maxPartnerReviewReply)
+	}
+
+	review, err := s.repo.GetPartnerReviewByID(reviewID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil ulasan")
+	}
+	if review == nil || review.PartnerID != partnerID {
+		return nil, errors.New("ulasan tidak ditemukan")
+	}
+
+	err = s.repo.ReplyPartnerReview(reviewID, partnerID, reply)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("ulasan yang disembunyikan tidak dapat dibalas")
+	}
+	if err != nil {
+		return nil, errors.New("gagal menyimpan balasan ulasan")
+	}
+
+	go func() {
+		notifBody := fmt.Sprintf("%s membalas ulasanmu untuk deposit #%d.", review.PartnerName.String, review.UserDepositHistoryID)
+		s.notifService.SendNotification(review.UserID, "Ulasan Dibalas", notifBody, "PARTNER_REVIEW_REPLIED")
+	}()
+	return s.repo.GetPartnerReviewByID(reviewID)
+}
+
+// AdminGetPartnerReviews mengambil ulasan untuk moderasi, opsional difilter status & partner
+func (s *PartnerService) AdminGetPartnerReviews(status string, partnerID int) ([]user.PartnerReview, error) {
+	if status != "" && status != user.PartnerReviewPublished && status != user.PartnerReviewHidden {
+		return nil, errors.New("status ulasan tidak valid (Published/Hidden)")
+	}
+	reviews, err := s.repo.GetPartnerReviewsForAdmin(status, partnerID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil ulasan")
+	}
+	return reviews, nil
+}
+
+// AdminModeratePartnerReview menyembunyikan ulasan (tidak tampil & tidak dihitung rating) atau menampilkannya kembali
+func (s *PartnerService) AdminModeratePartnerReview(reviewID int, req ModeratePartnerReviewRequest) (*user.PartnerReview, error) {
+	review, err := s.repo.GetPartnerReviewByID(reviewID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil ulasan")
+	}
+	if review == nil {
+		return nil, errors.New("ulasan tidak ditemukan")
+	}
+
+	status := user.PartnerReviewPublished
+	if req.Action == "hide" {
+		status = user.PartnerReviewHidden
+	}
+	if review.Status == status {
+		return nil, fmt.Errorf("ulasan sudah berstatus %s", status)
+	}
+	if err := s.repo.ModeratePartnerReview(reviewID, status, req.Note); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("ulasan tidak ditemukan")
+		}
+		return nil, errors.New("gagal memoderasi ulasan")
+	}
+
+	if status == user.PartnerReviewHidden {
+		go func() {
+			notifBody := fmt.Sprintf("Ulasanmu untuk deposit #%d disembunyikan admin: %s", review.UserDepositHistoryID, req.Note)
+			s.notifService.SendNotification(review.UserID, "Ulasan Disembunyikan", notifBody, "PARTNER_REVIEW_HIDDEN")
+		}()
+	}
+	return s.repo.GetPartnerReviewByID(reviewID)
+}
+
+// AdminGetDepositDisputes mengambil antrean sengketa untuk admin
+func (s *PartnerService) AdminGetDepositDisputes(status string) ([]user.DepositDispute, error) {
+	return s.repo.GetDepositDisputesForAdmin(status)
+}
+
+// AdminGetDepositDisputeByID mengambil detail sengketa mana pun
+func (s *PartnerService) AdminGetDepositDisputeByID(disputeID int) (*user.DepositDispute, error) {
+	dispute, err := s.repo.GetDepositDisputeByID(disputeID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil sengketa deposit")
+	}
+	if dispute == nil {
+		return nil, errors.New("sengketa deposit tidak ditemukan")
+	}
+	s.attachDisputeDepositPhotos(dispute)
+	return dispute, nil
+}
+
+// AdminResolveDepositDispute memutuskan sengketa, opsional sekaligus membatalkan/mengoreksi deposit
+func (s *PartnerService) AdminResolveDepositDispute(disputeID int, req ResolveDepositDisputeRequest) (*user.DepositDispute, error) {
+	dispute, err := s.AdminGetDepositDisputeByID(disputeID)
+	if err != nil {
+		return nil, err
+	}
+	if dispute.Status == user.DepositDisputeResolved || dispute.Status == user.DepositDisputeRejected {
+		return nil, errors.New("sengketa deposit sudah diputuskan")
+	}
+
+	action := req.Action
+	if action == "" {
+		action = "none"
+	}
+	if req.Decision == "reject" && action != "none" {
+		return nil, errors.New("sengketa yang ditolak tidak dapat disertai void/koreksi deposit")
+	}
+	if action == "correction" && (req.DetailID == 0 || (req.Weight <= 0 && req.Quantity <= 0)) {
+		return nil, errors.New("detail_id dan berat/jumlah baru (> 0) wajib diisi untuk koreksi")
+	}
+
+	status := user.DepositDisputeRejected
+	if req.Decision == "resolve" {
+		status = user.DepositDisputeResolved
+	}
+	adjustmentType := sql.NullString{}
+	if action != "none" {
+		adjustmentType = sql.NullString{String: action, Valid: true}
+	}
+
+	// Kunci sengketa dulu agar void/koreksi tidak dijalankan dua kali oleh admin berbeda
+	err = s.repo.ResolveDepositDispute(disputeID, status, req.Note, adjustmentType)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("sengketa deposit sudah diputuskan")
+	}
+	if err != nil {
+		return nil, errors.New("gagal menyimpan keputusan sengketa")
+	}
+
+	reason := fmt.Sprintf("Sengketa #%d: %s", disputeID, req.Note)
+	switch action {
+	case "void":
+		err = s.voidDeposit(dispute.PartnerDepositHistoryID, 0, "admin", sql.NullInt32{}, reason)
+	case "correction":
+		err = s.correctDepositDetail(dispute.PartnerDepositHistoryID, req.DetailID, 0, "admin", sql.NullInt32{},
+			CorrectDepositDetailRequest{Weight: req.Weight, Quantity: req.Quantity, Reason: reason})
+	}
+	if err != nil {
+		s.repo.RevertDepositDisputeResolution(disputeID, dispute.Status)
+		return nil, err
+	}
+	log.Printf("Deposit dispute %d decided by admin: %s (action: %s)", disputeID, status, action)
+
+	go func() {
+		var userBody, partnerBody string
+		if status == user.DepositDisputeResolved {
+			userBody = fmt.Sprintf("Keberatanmu atas deposit #%d diterima. %s", dispute.UserDepositHistoryID, req.Note)
+			partnerBody = fmt.Sprintf("Sengketa deposit #%d diputuskan untuk pelanggan. %s", dispute.PartnerDepositHistoryID, req.Note)
+		} else {
+			userBody = fmt.Sprintf("Keberatanmu atas deposit #%d ditolak. %s", dispute.UserDepositHistoryID, req.Note)
+			partnerBody = fmt.Sprintf("Sengketa deposit #%d ditolak admin. %s", dispute.PartnerDepositHistoryID, req.Note)
+		}
+		s.notifService.SendNotification(dispute.UserID, "Keputusan Sengketa Deposit", userBody, "DEPOSIT_DISPUTE_DECIDED")
+		s.notifService.SendPartnerNotification(dispute.PartnerID, "Keputusan Sengketa Deposit", partnerBody, "DEPOSIT_DISPUTE_DECIDED")
+	}()
+	return s.repo.GetDepositDisputeByID(disputeID)
+}
+
+// escalateOverdueDepositDisputes berjalan di background untuk mengeskalasi sengketa yang tidak ditanggapi partner.
+// Aman dijalankan di banyak replika karena eskalasi dilakukan dengan satu UPDATE bersyarat.
+func (s *PartnerService) escalateOverdueDepositDisputes(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		disputes, err := s.repo.EscalateOverdueDepositDisputes(time.Now())
+		if err != nil {
+			continue
+		}
+		for _, dispute := range disputes {
+			log.Printf("Deposit dispute %d escalated to admin (no partner response)", dispute.ID)
+			userBody := fmt.Sprintf("Partner belum menanggapi keberatanmu atas deposit #%d. Sengketa diteruskan ke admin.", dispute.UserDepositHistoryID)
+			s.notifService.SendNotification(dispute.UserID, "Sengketa Deposit Diteruskan", userBody, "DEPOSIT_DISPUTE_ESCALATED")
+			partnerBody := fmt.Sprintf("Batas waktu tanggapan sengketa deposit #%d sudah lewat. Sengketa diteruskan ke admin.", dispute.PartnerDepositHistoryID)
+			s.notifService.SendPartnerNotification(dispute.PartnerID, "Sengketa Deposit Diteruskan", partnerBody, "DEPOSIT_DISPUTE_ESCALATED")
+		}
+	}
+}
+
+// --- Pickup Request Service Methods ---
+
+// ensurePickupPartner memastikan partner sudah disetujui dan mengaktifkan penjemputan, lalu mengembalikan alamatnya
+func (s *PartnerService) ensurePickupPartner(partnerID int) (*PartnerAddress, error) {
+	status, err := s.repo.FindXetorPartnerStatusByID(partnerID)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, errors.New("gagal memeriksa status partner")
+	}
+	if status != "Approved" {
+		return nil, errors.New("partner belum disetujui sebagai mitra Xetor")
+	}
+	addr, err := s.repo.GetAddressByPartnerID(partnerID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil alamat partner")
+	}
+	if addr == nil || !addr.PickupEnabled {
+		return nil, errors.New("layanan jemput belum diaktifkan di alamat usaha")
+	}
+	return addr, nil
+}
+
+// GetAvailablePickupRequests mengambil permintaan jemput terdekat yang belum diambil partner lain,
+// disaring sesuai area layanan (poligon atau radius) dan berat minimal partner
+func (s *PartnerService) GetAvailablePickupRequests(partnerIDStr string) ([]user.PickupRequest, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+	addr, err := s.ensurePickupPartner(partnerID)
+	if err != nil {
+		return nil, err
+	}
+
+	center := geo.Point{Latitude: addr.Latitude.Float64, Longitude: addr.Longitude.Float64}
+	pickups, err := s.repo.GetAvailablePickupRequests(partnerID, geo.ReachKm(center, addr.ServiceRadiusKm, addr.ServiceAreaPolygon))
+	if err != nil {
+		return nil, errors.New("gagal mengambil permintaan jemput")
+	}
+
+	available := []user.PickupRequest{}
+	for _, p := range pickups {
+		point := geo.Point{Latitude: p.Latitude, Longitude: p.Longitude}
+		if !geo.InServiceArea(point, center, addr.ServiceRadiusKm, addr.ServiceAreaPolygon) {
+			continue
+		}
+		weight, _ := strconv.ParseFloat(p.EstimatedWeight, 64)
+		if weight < addr.MinPickupWeight {
+			continue
+		}
+		available = append(available, p)
+	}
+	return available, nil
+}
+
+// GetPickupRequests mengambil permintaan jemput yang sudah diterima partner, opsional difilter status
+func (s *PartnerService) GetPickupRequests(partnerIDStr string, status string) ([]user.PickupRequest, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+	return s.repo.GetPickupRequestsByPartnerID(partnerID, status)
+}
+
+// GetPickupRequestByID mengambil detail permintaan jemput milik partner (atau yang masih terbuka)
+func (s *PartnerService) GetPickupRequestByID(pickupID int, partnerIDStr string) (*user.PickupRequest, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+	pickup, err := s.repo.GetPickupRequestByID(pickupID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil permintaan jemput")
+	}
+	if pickup == nil {
+		return nil, errors.New("permintaan jemput tidak ditemukan")
+	}
+	if pickup.Status != user.PickupRequested && (!pickup.PartnerID.Valid || int(pickup.PartnerID.Int32) != partnerID) {
+		return nil, errors.New("permintaan jemput tidak ditemukan")
+	}
+	return pickup, nil
+}
+
+// AcceptPickupRequest menerima permintaan jemput dalam jangkauan partner
+func (s *PartnerService) AcceptPickupRequest(pickupID int, partnerIDStr string) (*user.PickupRequest, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+
+	available, err := s.GetAvailablePickupRequests(partnerIDStr)
+	if err != nil {
+		return nil, err
+	}
+	inRange := false
+	for _, p := range available {
+		if p.ID == pickupID {
+			inRange = true
+			break
+		}
+	}
+	if !inRange {
+		return nil, errors.New("permintaan jemput sudah diambil atau di luar jangkauan")
+	}
+
+	err = s.repo.AcceptPickupRequest(pickupID, partnerID)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("permintaan jemput sudah diambil atau di luar jangkauan")
+	}
+	if err != nil {
+		if strings.Contains(err.Error(), "kapasitas jemput") {
+			return nil, err
+		}
+		return nil, errors.New("gagal menerima permintaan jemput")
+	}
+
+	pickup, err := s.GetPickupRequestByID(pickupID, partnerIDStr)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		notifBody := fmt.Sprintf("%s akan menjemput sampahmu pada %s.", pickup.PartnerName.String, pickup.SlotStart.Format("02 Jan 2006 15:04"))
+		s.notifService.SendNotification(pickup.UserID, "Jemput Sampah Terjadwal", notifBody, "PICKUP_SCHEDULED")
+	}()
+	return pickup, nil
+}
+
+// advancePickupRequest memindahkan status permintaan jemput milik partner lalu memberi tahu user
+func (s *PartnerService) advancePickupRequest(pickupID int, partnerIDStr string, fromStatus, toStatus, notifTitle, notifBody, notifType string) (*user.PickupRequest, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+	pickup, err := s.GetPickupRequestByID(pickupID, partnerIDStr)
+	if err != nil {
+		return nil, err
+	}
+	if !pickup.PartnerID.Valid {
+		return nil, errors.New("permintaan jemput tidak ditemukan")
+	}
+	if pickup.Status != fromStatus {
+		return nil, fmt.Errorf("status permintaan jemput harus %s", fromStatus)
+	}
+
+	err = s.repo.UpdatePickupRequestStatus(pickupID, partnerID, fromStatus, toStatus)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("status permintaan jemput harus %s", fromStatus)
+	}
+	if err != nil {
+		return nil, errors.New("gagal mengubah status permintaan jemput")
+	}
+
+	go s.notifService.SendNotification(pickup.UserID, notifTitle, notifBody, notifType)
+	return s.GetPickupRequestByID(pickupID, partnerIDStr)
+}
+
+// StartPickupTrip menandai partner sedang menuju alamat user
+func (s *PartnerService) StartPickupTrip(pickupID int, partnerIDStr string) (*user.PickupRequest, error) {
+	return s.advancePickupRequest(pickupID, partnerIDStr, user.PickupScheduled, user.PickupOnTheWay,
+		"Mitra Dalam Perjalanan", "Mitra sedang menuju alamatmu untuk menjemput sampah.", "PICKUP_ON_THE_WAY")
+}
+
+// MarkPickupCollected menandai sampah sudah diambil dan menunggu ditimbang
+func (s *PartnerService) MarkPickupCollected(pickupID int, partnerIDStr string) (*user.PickupRequest, error) {
+	return s.advancePickupRequest(pickupID, partnerIDStr, user.PickupOnTheWay, user.PickupCollected,
+		"Sampah Sudah Dijemput", "Sampahmu sudah diambil mitra. Xpoin dikirim setelah ditimbang.", "PICKUP_COLLECTED")
+}
+
+// ReleasePickupRequest melepas jadwal jemput agar bisa diambil partner lain
+func (s *PartnerService) ReleasePickupRequest(pickupID int, partnerIDStr string) error {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return errors.New("ID partner tidak valid")
+	}
+	pickup, err := s.GetPickupRequestByID(pickupID, partnerIDStr)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.ReleasePickupRequest(pickupID, partnerID)
+	if err == sql.ErrNoRows {
+		return fmt.Errorf("status permintaan jemput harus %s", user.PickupScheduled)
+	}
+	if err != nil {
+		return errors.New("gagal melepas permintaan jemput")
+	}
+
+	go func() {
+		notifBody := "Mitra batal menjemput. Permintaanmu dibuka kembali untuk mitra lain di sekitarmu."
+		s.notifService.SendNotification(pickup.UserID, "Jadwal Jemput Berubah", notifBody, "PICKUP_RELEASED")
+	}()
+	return nil
+}
+
+// CompletePickupRequest mencatat hasil timbang sampah jemputan sebagai deposit biasa
+func (s *PartnerService) CompletePickupRequest(pickupID int, partnerIDStr string, req CompletePickupRequest, imageFile *multipart.FileHeader) (*user.PickupRequest, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+	pickup, err := s.GetPickupRequestByID(pickupID, partnerIDStr)
+	if err != nil {
+		return nil, err
+	}
+	if pickup.Status != user.PickupCollected {
+		return nil, fmt.Errorf("status permintaan jemput harus %s", user.PickupCollected)
+	}
+
+	depositReq := CreateDepositRequest{
+		UserID:          pickup.UserID,
+		DepositMethodID: req.DepositMethodID,
+		ItemsJSON:       req.ItemsJSON,
+		Notes:           req.Notes,
+		StaffID:         req.StaffID,
+		SourceType:      FraudCaseSourcePickupRequest,
+		SourceID:        pickupID,
+	}
+
+	// Kunci permintaan (Collected -> Completed) tepat sebelum transaksi agar tidak tercatat dua kali.
+	// Status hanya dikembalikan jika transaksi deposit gagal, bukan setelah Xpoin partner terdebit.
+	// Deposit yang ditahan anti-fraud memindahkan permintaan ke Held sampai admin memutuskan.
+	consume := func(*sql.Tx) (func(), error) {
+		if err := s.repo.UpdatePickupRequestStatus(pickupID, partnerID, user.PickupCollected, user.PickupCompleted); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, errors.New("permintaan jemput sudah selesai")
+			}
+			return nil, errors.New("gagal menyelesaikan permintaan jemput")
+		}
+		return func() {
+			if errRevert := s.repo.UpdatePickupRequestStatus(pickupID, partnerID, user.PickupCompleted, user.PickupCollected); errRevert != nil {
+				log.Printf("Failed to revert pickup request %d after deposit error: %v", pickupID, errRevert)
+			}
+		}, nil
+	}
+
+	header, err := s.createDepositForUser(partnerID, depositReq, imageFile, consume)
+	if err != nil {
+		return nil, err
+	}
+
+	if header.FraudCaseID != 0 {
+		log.Printf("Pickup request %d held for fraud review (case %d)", pickupID, header.FraudCaseID)
+	} else if err := s.repo.SetPickupRequestDepositHeader(pickupID, header.ID); err != nil {
+		log.Printf("Warning: pickup request %d completed as deposit %d but link was not saved", pickupID, header.ID)
+	}
+	return s.GetPickupRequestByID(pickupID, partnerIDStr)
+}
+
+// --- Deposit Fraud Service Methods ---
+
+// evaluateDepositFraud menghitung sinyal deposit lalu menjalankan aturan fraud aktif
+func (s *PartnerService) evaluateDepositFraud(args ArgsDepositCreation) ([]fraud.Hit, error) {
+	rules, err := s.repo.GetFraudRules()
+	if err != nil {
+		return nil, errors.New("gagal memeriksa aturan anti-fraud")
+	}
+	rulesByCode := make(map[string]fraud.Rule, len(rules))
+	for _, rule := range rules {
+		rulesByCode[rule.Code] = rule
+	}
+
+	userData, err := s.userRepo.FindByID(args.UserID)
+	if err != nil || userData == nil {
+		return nil, errors.New("ID pengguna tidak valid atau tidak ditemukan")
+	}
+
+	// Hari dihitung di zona waktu partner; partner tanpa jadwal memakai zona default
+	txTime := args.TransactionTime
+	from, to := schedule.Window(txTime)
+	cal, err := s.repo.GetScheduleCalendar(args.PartnerID, from, to)
+	if err != nil {
+		cal = nil
+	}
+	location := schedule.Location("")
+	if cal != nil {
+		location = schedule.Location(cal.Timezone)
+	}
+	local := txTime.In(location)
+	dayStart := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, location)
+	signals, err := s.repo.GetDepositFraudSignals(args.PartnerID, args.UserID, dayStart, dayStart.AddDate(0, 0, 1),
+		txTime.Add(-rulesByCode[fraud.RuleRepeatedWeight].Window()), txTime.Add(-rulesByCode[fraud.RuleSameUserFrequency].Window()))
+	if err != nil {
+		return nil, errors.New("gagal memeriksa riwayat deposit untuk anti-fraud")
+	}
+
+	input := fraud.Input{
+		TotalWeight:     args.TotalWeight,
+		TotalXpoin:      args.TotalXpoin,
+		UserWeightInDay: signals.UserWeightInDay,
+		PastWeights:     signals.PastWeights,
+		DepositsToUser:  signals.DepositsToUser,
+		UserCreatedAt:   userData.CreatedAt,
+		TransactionTime: txTime,
+	}
+	for _, item := range args.Items {
+		if item.Unit != WasteUnitPcs && item.Weight > 0 {
+			input.ItemWeights = append(input.ItemWeights, item.Weight)
+		}
+	}
+	if cal != nil { // Partner tanpa jadwal tidak bisa dinilai di luar jam operasional
+		input.OutsideSchedule = !cal.IsOpenAt(txTime)
+	}
+
+	hits := fraud.Evaluate(rules, input)
+	if len(hits) > 0 {
+		log.Printf("Deposit fraud rules hit for partner ID %d, user ID %d: %+v", args.PartnerID, args.UserID, hits)
+	}
+	return hits, nil
+}
+
+// screenDeposit menjalankan aturan fraud untuk deposit yang sudah dihitung. Deposit yang ditolak dicatat
+// sebagai kasus Blocked untuk audit admin dan dikembalikan sebagai error.
+func (s *PartnerService) screenDeposit(args ArgsDepositCreation) (string, []fraud.Hit, error) {
+	hits, err := s.evaluateDepositFraud(args)
+	if err != nil {
+		return "", nil, err
+	}
+	decision := fraud.Decide(hits)
+	if decision == fraud.ActionReject {
+		if _, err := s.recordDepositFraudCase(args, hits, FraudCaseBlocked, nil); err != nil {
+			return decision, hits, err
+		}
+		return decision, hits, fmt.Errorf("deposit ditolak sistem anti-fraud: %s", hits[0].Detail)
+	}
+	return decision, hits, nil
+}
+
+// recordDepositFraudCase menyimpan deposit yang memicu aturan. Kasus Held bisa dijalankan admin nanti;
+// consume dijalankan di transaksi yang sama agar kode QR tidak bisa dipakai lagi selama ditinjau.
+func (s *PartnerService) recordDepositFraudCase(args ArgsDepositCreation, hits []fraud.Hit, status string, consume depositConsumer) (*DepositFraudCase, error) {
+	fraudCase := &DepositFraudCase{
+		PartnerID:       args.PartnerID,
+		UserID:          args.UserID,
+		Status:          status,
+		Hits:            hits,
+		DepositMethodID: args.DepositMethodID,
+		Items:           s.draftItemsFromDeposit(args.PartnerID, args.Items),
+		TotalXpoin:      args.TotalXpoin,
+		Notes:           args.Notes,
+		Photo:           args.PhotoURL,
+		Photos:          args.Photos,
+		ClientID:        args.ClientID,
+		TransactionTime: args.TransactionTime,
+		StaffID:         args.StaffID,
+		SourceType:      sql.NullString{String: args.SourceType, Valid: args.SourceType != ""},
+		SourceID:        sql.NullInt32{Int32: int32(args.SourceID), Valid: args.SourceType != ""},
+	}
+	err := consume.inTx(func(hook func(tx *sql.Tx) error) error {
+		return s.repo.CreateDepositFraudCase(fraudCase, args.TotalWeight, hook)
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Deposit fraud case %d (%s) recorded for partner ID %d, user ID %d", fraudCase.ID, status, args.PartnerID, args.UserID)
+
+	if status == FraudCaseHeld {
+		go func() {
+			notifBody := fmt.Sprintf("Setoran %s kg (%d Xpoin) ditahan untuk ditinjau admin. Xpoin belum dipotong.", fraudCase.TotalWeight, fraudCase.TotalXpoin)
+			s.notifService.SendPartnerNotification(fraudCase.PartnerID, "Deposit Ditahan", notifBody, "DEPOSIT_HELD_FOR_REVIEW")
+		}()
+	}
+	return fraudCase, nil
+}
+
+// GetFraudRules mengambil konfigurasi aturan fraud untuk admin
+func (s *PartnerService) GetFraudRules() ([]fraud.Rule, error) {
+	return s.repo.GetFraudRules()
+}
+
+// UpdateFraudRule mengubah aksi dan (opsional) threshold/window satu aturan fraud
+func (s *PartnerService) UpdateFraudRule(code string, req UpdateFraudRuleRequest) (*fraud.Rule, error) {
+	if !fraud.ValidAction(req.Action) {
+		return nil, errors.New("aksi aturan tidak valid, gunakan allow, hold, atau reject")
+	}
+
+	rules, err := s.repo.GetFraudRules()
+	if err != nil {
+		return nil, errors.New("gagal mengambil aturan fraud")
+	}
+	var rule *fraud.Rule
+	for i := range rules {
+		if rules[i].Code == code {
+			rule = &rules[i]
+			break
+		}
+	}
+	if rule == nil {
+		return nil, errors.New("aturan fraud tidak ditemukan")
+	}
+
+	rule.Action = req.Action
+	if req.Threshold != nil {
+		if *req.Threshold < 0 {
+			return nil, errors.New("threshold aturan tidak valid")
+		}
+		rule.Threshold = *req.Threshold
+	}
+	if req.WindowHours != nil {
+		if *req.WindowHours < 0 {
+			return nil, errors.New("window_hours aturan tidak valid")
+		}
+		rule.WindowHours = *req.WindowHours
+	}
+
+	if err := s.repo.UpdateFraudRule(rule); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("aturan fraud tidak ditemukan")
+		}
+		return nil, errors.New("gagal menyimpan aturan fraud")
+	}
+	return rule, nil
+}
+
+// AdminGetDepositFraudCases mengambil kasus fraud deposit, default yang menunggu review
+func (s *PartnerService) AdminGetDepositFraudCases(status string) ([]DepositFraudCase, error) {
+	return s.repo.GetDepositFraudCases(status)
+}
+
+// AdminGetDepositFraudCaseByID mengambil detail satu kasus fraud deposit
+func (s *PartnerService) AdminGetDepositFraudCaseByID(caseID int) (*DepositFraudCase, error) {
+	fraudCase, err := s.repo.GetDepositFraudCaseByID(caseID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil kasus fraud deposit")
+	}
+	if fraudCase == nil {
+		return nil, errors.New("kasus fraud deposit tidak ditemukan")
+	}
+	return fraudCase, nil
+}
+
+// AdminApproveDepositFraudCase merilis deposit yang ditahan: deposit dijalankan dengan waktu transaksi aslinya
+// dan asal deposit (sesi, jemput, draft) diselesaikan
+func (s *PartnerService) AdminApproveDepositFraudCase(caseID int, req ReviewFraudCaseRequest) (*DepositFraudCase, error) {
+	fraudCase, err := s.AdminGetDepositFraudCaseByID(caseID)
+	if err != nil {
+		return nil, err
+	}
+	// Kasus dikunci (Held -> Approved) tepat sebelum transaksi dan kembali ke Held hanya jika transaksi deposit
+	// gagal (misal Xpoin partner kurang), agar bisa dicoba lagi tanpa menjalankan deposit dua kali
+	note := sql.NullString{String: req.Note, Valid: req.Note != ""}
+	consume := func(*sql.Tx) (func(), error) {
+		if err := s.repo.UpdateDepositFraudCaseStatus(caseID, FraudCaseHeld, FraudCaseApproved, note); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, errors.New("kasus fraud deposit sudah diproses")
+			}
+			return nil, errors.New("gagal memproses kasus fraud deposit")
+		}
+		return func() {
+			if errRevert := s.repo.UpdateDepositFraudCaseStatus(caseID, FraudCaseApproved, FraudCaseHeld, sql.NullString{}); errRevert != nil {
+				log.Printf("Failed to revert deposit fraud case %d after deposit error: %v", caseID, errRevert)
+			}
+		}, nil
+	}
+
+	items, totalWeight := depositItemsFromDraft(fraudCase.Items)
+	header, err := s.executeDeposit(ArgsDepositCreation{
+		PartnerID:       fraudCase.PartnerID,
+		UserID:          fraudCase.UserID,
+		DepositMethodID: fraudCase.DepositMethodID,
+		Items:           items,
+		TotalWeight:     totalWeight,
+		TotalXpoin:      fraudCase.TotalXpoin,
+		Notes:           fraudCase.Notes,
+		PhotoURL:        fraudCase.Photo,
+		Photos:          fraudCase.Photos,
+		TransactionTime: fraudCase.TransactionTime,
+		ClientID:        fraudCase.ClientID,
+		StaffID:         fraudCase.StaffID,
+		FraudCaseID:     caseID,
+	}, consume)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.SetDepositFraudCaseDepositHeader(caseID, header.ID); err != nil {
+		log.Printf("Warning: deposit fraud case %d approved as deposit %d but link was not saved", caseID, header.ID)
+	}
+	s.completeFraudCaseSource(fraudCase, header.ID)
+	log.Printf("Deposit fraud case %d approved, deposit ID %d", caseID, header.ID)
+
+	go func() {
+		notifBody := fmt.Sprintf("Setoran %d Xpoin yang ditahan sudah disetujui admin dan diproses.", fraudCase.TotalXpoin)
+		s.notifService.SendPartnerNotification(fraudCase.PartnerID, "Deposit Disetujui", notifBody, "DEPOSIT_HOLD_APPROVED")
+	}()
+	return s.AdminGetDepositFraudCaseByID(caseID)
+}
+
+// AdminDeclineDepositFraudCase menolak deposit yang ditahan; tidak ada Xpoin yang berpindah dan asal deposit dibatalkan
+func (s *PartnerService) AdminDeclineDepositFraudCase(caseID int, req ReviewFraudCaseRequest) (*DepositFraudCase, error) {
+	fraudCase, err := s.AdminGetDepositFraudCaseByID(caseID)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(req.Note) == "" {
+		return nil, errors.New("alasan penolakan wajib diisi")
+	}
+	if err := s.repo.UpdateDepositFraudCaseStatus(caseID, FraudCaseHeld, FraudCaseDeclined, sql.NullString{String: req.Note, Valid: true}); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("kasus fraud deposit sudah diproses")
+		}
+		return nil, errors.New("gagal memproses kasus fraud deposit")
+	}
+	log.Printf("Deposit fraud case %d declined", caseID)
+	if err := s.repo.ReleaseDepositFraudCaseScaleReadings(caseID); err != nil {
+		log.Printf("Warning: scale readings of declined deposit fraud case %d were not released: %v", caseID, err)
+	}
+	s.cancelFraudCaseSource(fraudCase)
+
+	go func() {
+		notifBody := fmt.Sprintf("Setoran %d Xpoin yang ditahan ditolak admin: %s", fraudCase.TotalXpoin, req.Note)
+		s.notifService.SendPartnerNotification(fraudCase.PartnerID, "Deposit Ditolak", notifBody, "DEPOSIT_HOLD_DECLINED")
+		if fraudCase.SourceType.Valid {
+			userBody := fmt.Sprintf("Setoran %d Xpoin yang ditinjau admin ditolak dan dibatalkan: %s", fraudCase.TotalXpoin, req.Note)
+			s.notifService.SendNotification(fraudCase.UserID, "Setoran Ditolak", userBody, "DEPOSIT_HOLD_DECLINED")
+		}
+	}()
+	return s.AdminGetDepositFraudCaseByID(caseID)
+}
+
+// completeFraudCaseSource menyelesaikan asal deposit (sesi, jemput, draft) dari kasus yang disetujui
+// dan menghubungkannya ke deposit yang dijalankan
+func (s *PartnerService) completeFraudCaseSource(fraudCase *DepositFraudCase, headerID int) {
+	if !fraudCase.SourceType.Valid {
+		return
+	}
+	sourceID := int(fraudCase.SourceID.Int32)
+	var errLink, errStatus error
+	switch fraudCase.SourceType.String {
+	case FraudCaseSourceDepositSession:
+		errLink = s.repo.SetDepositSessionDepositHeader(sourceID, headerID)
+		errStatus = s.repo.UpdateDepositSessionStatus(sourceID, fraudCase.PartnerID, user.DepositSessionHeld, user.DepositSessionConfirmed)
+	case FraudCaseSourcePickupRequest:
+		errLink = s.repo.SetPickupRequestDepositHeader(sourceID, headerID)
+		errStatus = s.repo.UpdatePickupRequestStatus(sourceID, fraudCase.PartnerID, user.PickupHeld, user.PickupCompleted)
+	case FraudCaseSourceDepositDraft:
+		errLink = s.repo.SetDepositDraftDepositHeader(sourceID, headerID)
+		errStatus = s.repo.UpdateDepositDraftStatus(sourceID, user.DepositDraftHeld, user.DepositDraftAccepted)
+	}
+	if errLink != nil || errStatus != nil {
+		log.Printf("Warning: %s %d of approved deposit fraud case %d was not completed (link: %v, status: %v)",
+			fraudCase.SourceType.String, sourceID, fraudCase.ID, errLink, errStatus)
+	}
+}
+
+// cancelFraudCaseSource membatalkan asal deposit (sesi, jemput, draft) dari kasus yang ditolak admin
+func (s *PartnerService) cancelFraudCaseSource(fraudCase *DepositFraudCase) {
+	if !fraudCase.SourceType.Valid {
+		return
+	}
+	sourceID := int(fraudCase.SourceID.Int32)
+	var err error
+	switch fraudCase.SourceType.String {
+	case FraudCaseSourceDepositSession:
+		err = s.repo.UpdateDepositSessionStatus(sourceID, fraudCase.PartnerID, user.DepositSessionHeld, user.DepositSessionCancelled)
+	case FraudCaseSourcePickupRequest:
+		err = s.repo.UpdatePickupRequestStatus(sourceID, fraudCase.PartnerID, user.PickupHeld, user.PickupCancelled)
+	case FraudCaseSourceDepositDraft:
+		err = s.repo.UpdateDepositDraftStatus(sourceID, user.DepositDraftHeld, user.DepositDraftFailed)
+	}
+	if err != nil {
+		log.Printf("Warning: %s %d of declined deposit fraud case %d was not cancelled: %v", fraudCase.SourceType.String, sourceID, fraudCase.ID, err)
+	}
+}
+
+// --- Scale Device Service Methods ---
+
+// scaleReadingSignature menghitung tanda tangan HMAC-SHA256 (hex) hasil timbang
+func scaleReadingSignature(secret string, req ScaleReadingRequest) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(fmt.Sprintf("%s|%s|%s|%d", req.DeviceID, req.ReadingID, req.Weight, req.MeasuredAt.Unix())))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RegisterScaleDevice mendaftarkan timbangan digital partner dan mengembalikan secret HMAC-nya (sekali saja)
+func (s *PartnerService) RegisterScaleDevice(partnerIDStr string, req RegisterScaleDeviceRequest) (*ScaleDevice, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return nil, errors.New("gagal membuat kunci timbangan")
+	}
+	device := &ScaleDevice{
+		PartnerID: partnerID,
+		DeviceID:  strings.TrimSpace(req.DeviceID),
+		Name:      strings.TrimSpace(req.Name),
+		Secret:    hex.EncodeToString(secretBytes),
+	}
+	if device.DeviceID == "" {
+		return nil, errors.New("device_id timbangan tidak valid")
+	}
+	if err := s.repo.CreateScaleDevice(device); err != nil {
+		return nil, err
+	}
+	log.Printf("Scale device %s registered by partner ID %d", device.DeviceID, partnerID)
+	return device, nil
+}
+
+// GetScaleDevices mengambil timbangan milik partner (tanpa secret)
+func (s *PartnerService) GetScaleDevices(partnerIDStr string) ([]ScaleDevice, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+	return s.repo.GetScaleDevicesByPartnerID(partnerID)
+}
+
+// RevokeScaleDevice mencabut timbangan; hasil timbang berikutnya dari perangkat ini ditolak
+func (s *PartnerService) RevokeScaleDevice(deviceRowID int, partnerIDStr string) error {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return errors.New("ID partner tidak valid")
+	}
+	if err := s.repo.RevokeScaleDevice(deviceRowID, partnerID); err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("timbangan tidak ditemukan")
+		}
+		return errors.New("gagal mencabut timbangan")
+	}
+	return nil
+}
+
+// GetScaleReadings mengambil hasil timbang partner dalam jendela pemakaian, opsional hanya yang belum dipakai
+func (s *PartnerService) GetScaleReadings(partnerIDStr string, unusedOnly bool) ([]ScaleReading, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+	return s.repo.GetScaleReadingsByPartnerID(partnerID, time.Now().Add(-scaleReadingUseWindow), unusedOnly)
+}
+
+// IngestScaleReading memverifikasi tanda tangan & kesegaran hasil timbang dari perangkat lalu menyimpannya
+func (s *PartnerService) IngestScaleReading(req ScaleReadingRequest) (*ScaleReading, error) {
+	device, err := s.repo.FindScaleDeviceByDeviceID(req.DeviceID)
+	if err != nil {
+		return nil, errors.New("gagal memeriksa timbangan")
+	}
+	// Perangkat tidak dikenal dan tanda tangan salah diberi pesan yang sama
+	if device == nil || !hmac.Equal([]byte(scaleReadingSignature(device.Secret, req)), []byte(strings.ToLower(req.Signature))) {
+		return nil, errors.New("tanda tangan hasil timbang tidak valid")
+	}
+	if device.RevokedAt != nil {
+		return nil, errors.New("timbangan sudah dicabut")
+	}
+
+	weight, err := strconv.ParseFloat(req.Weight, 64)
+	if err != nil || weight <= 0 || math.IsInf(weight, 0) {
+		return nil, errors.New("berat hasil timbang tidak valid")
+	}
+
+	now := time.Now()
+	if req.MeasuredAt.After(now.Add(scaleReadingMaxClockSkew)) {
+		return nil, errors.New("waktu timbang tidak valid, periksa jam perangkat")
+	}
+	if now.Sub(req.MeasuredAt) > scaleReadingMaxIngestAge {
+		return nil, errors.New("hasil timbang sudah kedaluwarsa")
+	}
+
+	return s.repo.CreateScaleReading(device, req.ReadingID, weight, req.MeasuredAt)
+}
+
+// AdminGetScaleDevices mengambil semua timbangan terdaftar, opsional difilter status sertifikasi
+func (s *PartnerService) AdminGetScaleDevices(certified *bool) ([]ScaleDevice, error) {
+	return s.repo.GetScaleDevicesForAdmin(certified)
+}
+
+// AdminCertifyScaleDevice mencatat (atau mencabut) sertifikasi tera timbangan
+func (s *PartnerService) AdminCertifyScaleDevice(deviceRowID int, req CertifyScaleDeviceRequest) error {
+	certificationNumber := strings.TrimSpace(req.CertificationNumber)
+	if req.Certified && certificationNumber == "" {
+		return errors.New("nomor sertifikasi wajib diisi")
+	}
+	if !req.Certified {
+		certificationNumber = ""
+	}
+	if err := s.repo.CertifyScaleDevice(deviceRowID, req.Certified, certificationNumber); err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("timbangan tidak ditemukan")
+		}
+		return errors.New("gagal memperbarui sertifikasi timbangan")
+	}
+	return nil
+}
+
+// AdminGetScaleUsage meringkas partner yang memakai timbangan (bersertifikat) untuk deposit
+func (s *PartnerService) AdminGetScaleUsage() ([]ScalePartnerUsage, error) {
+	return s.repo.GetScaleUsageByPartner()
+}
+
+// --- Onboarding (KYC) Partner ---
+
+// maxOnboardingSitePhotos jumlah maksimal foto lokasi usaha dalam satu pengajuan
+const maxOnboardingSitePhotos = 5
+
+var (
+	ownerNIKRegex    = regexp.MustCompile(`^\d{16}$`)
+	nibRegex         = regexp.MustCompile(`^\d{13}$`)
+	bankAccountRegex = regexp.MustCompile(`^\d{5,30}$`)
+)
+
+// loadOnboardingApplication melengkapi pengajuan dengan dokumen, alamat usaha, dan (opsional) riwayat status
+func (s *PartnerService) loadOnboardingApplication(app *OnboardingApplication, withHistory bool) (*OnboardingApplication, error) {
+	docs, err := s.repo.GetOnboardingDocuments(app.ID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil dokumen onboarding")
+	}
+	app.Documents = docs
+	addr, err := s.repo.GetAddressByPartnerID(app.PartnerID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil alamat partner")
+	}
+	app.Address = addr
+	if withHistory {
+		history, err := s.repo.GetPartnerStatusHistory(app.PartnerID)
+		if err != nil {
+			return nil, errors.New("gagal mengambil riwayat status partner")
+		}
+		app.History = history
+	}
+	return app, nil
+}
+
+// getOrCreateOnboardingApplication mengambil pengajuan partner, membuat Draft jika belum ada
+func (s *PartnerService) getOrCreateOnboardingApplication(partnerIDStr string) (*OnboardingApplication, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+	app, err := s.repo.GetOnboardingApplicationByPartnerID(partnerID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil pengajuan onboarding")
+	}
+	if app == nil {
+		app, err = s.repo.CreateOnboardingApplication(partnerID)
+		if err != nil || app == nil {
+			return nil, errors.New("gagal membuat pengajuan onboarding")
+		}
+	}
+	return app, nil
+}
+
+// onboardingEditable memastikan pengajuan masih bisa diubah partner
+func onboardingEditable(app *OnboardingApplication) error {
+	if app.Status != OnboardingDraft && app.Status != OnboardingNeedsInfo {
+		return fmt.Errorf("pengajuan onboarding sudah berstatus %s dan tidak dapat diubah", app.Status)
+	}
+	return nil
+}
+
+// GetOnboardingApplication mengambil pengajuan onboarding partner beserta dokumen & riwayat statusnya
+func (s *PartnerService) GetOnboardingApplication(partnerIDStr string) (*OnboardingApplication, error) {
+	app, err := s.getOrCreateOnboardingApplication(partnerIDStr)
+	if err != nil {
+		return nil, err
+	}
+	return s.loadOnboardingApplication(app, true)
+}
+
+// UpdateOnboardingApplication menyimpan data pemilik, NIB, dan rekening pencairan selama pengajuan masih Draft / Needs Info
+func (s *PartnerService) UpdateOnboardingApplication(partnerIDStr string, req UpdateOnboardingRequest) (*OnboardingApplication, error) {
+	app, err := s.getOrCreateOnboardingApplication(partnerIDStr)
+	if err != nil {
+		return nil, err
+	}
+	if err := onboardingEditable(app); err != nil {
+		return nil, err
+	}
+
+	if v := strings.TrimSpace(req.OwnerName); v != "" {
+		app.OwnerName = v
+	}
+	if v := strings.TrimSpace(req.OwnerNIK); v != "" {
+		if !ownerNIKRegex.MatchString(v) {
+			return nil, errors.New("NIK pemilik tidak valid (16 digit angka)")
+		}
+		app.OwnerNIK = v
+	}
+	if v := strings.TrimSpace(req.NIB); v != "" {
+		if !nibRegex.MatchString(v) {
+			return nil, errors.New("NIB tidak valid (13 digit angka)")
+		}
+		app.NIB = v
+	}
+	if v := strings.TrimSpace(req.BankName); v != "" {
+		app.BankName = v
+	}
+	if v := strings.TrimSpace(req.BankAccountNumber); v != "" {
+		if !bankAccountRegex.MatchString(v) {
+			return nil, errors.New("nomor rekening tidak valid (hanya angka)")
+		}
+		app.BankAccountNumber = v
+	}
+	if v := strings.TrimSpace(req.BankAccountHolder); v != "" {
+		app.BankAccountHolder = v
+	}
+
+	if err := s.repo.UpdateOnboardingApplicationData(app); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("pengajuan onboarding sedang direview dan tidak dapat diubah")
+		}
+		return nil, err
+	}
+	return s.loadOnboardingApplication(app, false)
+}
+
+// uploadOnboardingDocument menyimpan dokumen onboarding ke storage lokal (VPS) dan mengembalikan URL CDN
+func (s *PartnerService) uploadOnboardingDocument(partnerID int, docType string, fileHeader *multipart.FileHeader) (string, error) {
+	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
+	switch ext {
+	case ".jpg", ".jpeg", ".png", ".pdf":
+	case "":
+		ext = ".jpg"
+	default:
+		return "", errors.New("format dokumen tidak valid (jpg, png, atau pdf)")
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return "", errors.New("gagal membaca file dokumen")
+	}
+	defer file.Close()
+
+	docDir := filepath.Join(config.GetMediaBasePath(), "partner_documents")
+	if err := os.MkdirAll(docDir, 0755); err != nil {
+		log.Printf("Error creating partner document directory: %v", err)
+		return "", errors.New("gagal menyiapkan penyimpanan dokumen")
+	}
+
+	filename := fmt.Sprintf("partner_%d_%s_%d%s", partnerID, docType, time.Now().UnixNano(), ext)
+	dst, err := os.Create(filepath.Join(docDir, filename))
+	if err != nil {
+		log.Printf("Error creating destination file for partner document: %v", err)
+		return "", errors.New("gagal menyimpan dokumen")
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, file); err != nil {
+		log.Printf("Error copying partner document to destination: %v", err)
+		return "", errors.New("gagal menyimpan dokumen")
+	}
+	return fmt.Sprintf("%s/partner_documents/%s", config.GetCDNBaseURL(), filename), nil
+}
+
+// AddOnboardingDocument mengunggah dokumen NIB / KTP (mengganti yang lama) atau menambah foto lokasi usaha
+func (s *PartnerService) AddOnboardingDocument(partnerIDStr string, docType string, fileHeader *multipart.FileHeader) (*OnboardingDocument, error) {
+	if docType != OnboardingDocNIB && docType != OnboardingDocKTP && docType != OnboardingDocSitePhoto {
+		return nil, errors.New("jenis dokumen tidak valid (nib, ktp, site_photo)")
+	}
+	if fileHeader == nil {
+		return nil, errors.New("file dokumen wajib diisi")
+	}
+	app, err := s.getOrCreateOnboardingApplication(partnerIDStr)
+	if err != nil {
+		return nil, err
+	}
+	if err := onboardingEditable(app); err != nil {
+		return nil, err
+	}
+
+	if docType == OnboardingDocSitePhoto {
+		docs, err := s.repo.GetOnboardingDocuments(app.ID)
+		if err != nil {
+			return nil, errors.New("gagal mengambil dokumen onboarding")
+		}
+		count := 0
+		for _, doc := range docs {
+			if doc.Type == OnboardingDocSitePhoto {
+				count++
+			}
+		}
+		if count >= maxOnboardingSitePhotos {
+			return nil, fmt.Errorf("foto lokasi usaha tidak dapat melebihi %d foto", maxOnboardingSitePhotos)
+		}
+	}
+
+	url, err := s.uploadOnboardingDocument(app.PartnerID, docType, fileHeader)
+	if err != nil {
+		return nil, err
+	}
+	doc := &OnboardingDocument{ApplicationID: app.ID, Type: docType, URL: url}
+	if err := s.repo.AddOnboardingDocument(doc, docType != OnboardingDocSitePhoto); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// DeleteOnboardingDocument menghapus dokumen selama pengajuan masih bisa diubah
+func (s *PartnerService) DeleteOnboardingDocument(partnerIDStr string, documentID int) error {
+	app, err := s.getOrCreateOnboardingApplication(partnerIDStr)
+	if err != nil {
+		return err
+	}
+	if err := onboardingEditable(app); err != nil {
+		return err
+	}
+	if err := s.repo.DeleteOnboardingDocument(app.ID, documentID); err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("dokumen onboarding tidak ditemukan")
+		}
+		return errors.New("gagal menghapus dokumen onboarding")
+	}
+	return nil
+}
+
+// SubmitOnboardingApplication mengajukan (ulang) onboarding ke antrean review admin setelah data & dokumen lengkap
+func (s *PartnerService) SubmitOnboardingApplication(partnerIDStr string) (*OnboardingApplication, error) {
+	app, err := s.getOrCreateOnboardingApplication(partnerIDStr)
+	if err != nil {
+		return nil, err
+	}
+	if err := onboardingEditable(app); err != nil {
+		return nil, err
+	}
+	if app, err = s.loadOnboardingApplication(app, false); err != nil {
+		return nil, err
+	}
+
+	missing := []string{}
+	for field, value := range map[string]string{
+		"owner_name": app.OwnerName, "owner_nik": app.OwnerNIK, "nib": app.NIB,
+		"bank_name": app.BankName, "bank_account_number": app.BankAccountNumber, "bank_account_holder": app.BankAccountHolder,
+	} {
+		if value == "" {
+			missing = append(missing, field)
+		}
+	}
+	docCount := make(map[string]int)
+	for _, doc := range app.Documents {
+		docCount[doc.Type]++
+	}
+	for _, docType := range []string{OnboardingDocNIB, OnboardingDocKTP, OnboardingDocSitePhoto} {
+		if docCount[docType] == 0 {
+			missing = append(missing, "dokumen "+docType)
+		}
+	}
+	if app.Address == nil {
+		missing = append(missing, "alamat usaha")
+	}
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return nil, fmt.Errorf("data onboarding wajib diisi: %s", strings.Join(missing, ", "))
+	}
+
+	err = s.repo.TransitionOnboardingApplication(ArgsOnboardingTransition{
+		ApplicationID: app.ID,
+		FromStatuses:  []string{OnboardingDraft, OnboardingNeedsInfo},
+		ToStatus:      OnboardingSubmitted,
+		ActorType:     "partner",
+	})
+	if err == sql.ErrNoRows {
+		return nil, errors.New("pengajuan onboarding sudah diajukan")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.GetOnboardingApplication(partnerIDStr)
+}
+
+// AdminGetOnboardingApplications antrean review onboarding; default Submitted, "all" untuk semua status
+func (s *PartnerService) AdminGetOnboardingApplications(status string) ([]OnboardingApplication, error) {
+	switch status {
+	case "":
+		status = OnboardingSubmitted
+	case "all":
+		status = ""
+	case OnboardingDraft, OnboardingSubmitted, OnboardingNeedsInfo, OnboardingApproved, OnboardingRejected:
+	default:
+		return nil, errors.New("status onboarding tidak valid")
+	}
+	apps, err := s.repo.GetOnboardingApplications(status)
+	if err != nil {
+		return nil, errors.New("gagal mengambil pengajuan onboarding")
+	}
+	return apps, nil
+}
+
+// AdminGetOnboardingApplicationByID detail pengajuan beserta dokumen, alamat, dan riwayat status
+func (s *PartnerService) AdminGetOnboardingApplicationByID(applicationID int) (*OnboardingApplication, error) {
+	app, err := s.repo.GetOnboardingApplicationByID(applicationID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil pengajuan onboarding")
+	}
+	if app == nil {
+		return nil, errors.New("pengajuan onboarding tidak ditemukan")
+	}
+	return s.loadOnboardingApplication(app, true)
+}
+
+// reviewOnboardingApplication menjalankan keputusan admin atas pengajuan Submitted lalu memberi tahu partner
+func (s *PartnerService) reviewOnboardingApplication(applicationID int, toStatus, xetorStatus, note, notifTitle, notifBody, notifType string) (*OnboardingApplication, error) {
+	app, err := s.AdminGetOnboardingApplicationByID(applicationID)
+	if err != nil {
+		return nil, err
+	}
+	err = s.repo.TransitionOnboardingApplication(ArgsOnboardingTransition{
+		ApplicationID: applicationID,
+		FromStatuses:  []string{OnboardingSubmitted},
+		ToStatus:      toStatus,
+		ActorType:     "admin",
+		Note:          note,
+		XetorStatus:   xetorStatus,
+	})
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("pengajuan onboarding sudah berstatus %s", app.Status)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	go s.notifService.SendPartnerNotification(app.PartnerID, notifTitle, notifBody, notifType)
+	return s.AdminGetOnboardingApplicationByID(applicationID)
+}
+
+// AdminApproveOnboarding menyetujui pengajuan dan mengaktifkan partner
+func (s *PartnerService) AdminApproveOnboarding(applicationID int, req ReviewOnboardingRequest) (*OnboardingApplication, error) {
+	return s.reviewOnboardingApplication(applicationID, OnboardingApproved, "Approved", strings.TrimSpace(req.Note),
+		"Onboarding Disetujui", "Selamat! Pengajuan onboarding usahamu telah disetujui. Kamu sudah bisa menerima deposit.",
+		"PARTNER_ONBOARDING_APPROVED")
+}
+
+// AdminRejectOnboarding menolak pengajuan dengan alasan
+func (s *PartnerService) AdminRejectOnboarding(applicationID int, req ReviewOnboardingRequest) (*OnboardingApplication, error) {
+	note := strings.TrimSpace(req.Note)
+	if note == "" {
+		return nil, errors.New("alasan penolakan wajib diisi")
+	}
+	return s.reviewOnboardingApplication(applicationID, OnboardingRejected, "Rejected", note,
+		"Onboarding Ditolak", fmt.Sprintf("Pengajuan onboarding usahamu ditolak: %s", note),
+		"PARTNER_ONBOARDING_REJECTED")
+}
+
+// AdminRequestOnboardingInfo mengembalikan pengajuan ke partner untuk dilengkapi
+func (s *PartnerService) AdminRequestOnboardingInfo(applicationID int, req ReviewOnboardingRequest) (*OnboardingApplication, error) {
+	note := strings.TrimSpace(req.Note)
+	if note == "" {
+		return nil, errors.New("informasi yang diminta wajib diisi")
+	}
+	return s.reviewOnboardingApplication(applicationID, OnboardingNeedsInfo, "", note,
+		"Onboarding Perlu Dilengkapi", fmt.Sprintf("Admin meminta data tambahan untuk onboarding usahamu: %s", note),
+		"PARTNER_ONBOARDING_NEEDS_INFO")
+}
+
+// --- Staf Partner ---
+
+// staffIDArg mengubah ID staf dari token menjadi kolom nullable (0 = tidak diketahui)
+func staffIDArg(staffID int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(staffID), Valid: staffID > 0}
+}
+
+// AuthorizePartnerStaff memastikan staf pada token masih aktif dan role-nya diizinkan. Owner selalu diizinkan;
+// token tanpa staff_id (sebelum fitur staf) diperlakukan sebagai owner. Mengembalikan staf yang sedang login.
+func (s *PartnerService) AuthorizePartnerStaff(partnerIDStr string, staffID int, allowedRoles ...string) (*PartnerStaff, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+
+	var staff *PartnerStaff
+	if staffID == 0 {
+		staff, err = s.repo.FindOrCreateOwnerStaff(partnerID)
+	} else {
+		staff, err = s.repo.GetPartnerStaffByID(staffID, partnerID)
+	}
+	if err != nil {
+		return nil, errors.New("gagal memeriksa akun staf")
+	}
+	if staff == nil || !staff.IsActive {
+		return nil, errors.New("akun staf tidak aktif")
+	}
+	if staff.Role == StaffRoleOwner {
+		return staff, nil
+	}
+	for _, role := range allowedRoles {
+		if staff.Role == role {
+			return staff, nil
+		}
+	}
+	return nil, errors.New("akses ditolak untuk role staf ini")
+}
+
+// GetPartnerStaffList mengambil semua akun staf partner
+func (s *PartnerService) GetPartnerStaffList(partnerIDStr string) ([]PartnerStaff, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+	if _, err := s.repo.FindOrCreateOwnerStaff(partnerID); err != nil {
+		return nil, errors.New("gagal mengambil akun owner")
+	}
+	staff, err := s.repo.GetPartnerStaffByPartnerID(partnerID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil daftar staf")
+	}
+	return staff, nil
+}
+
+// GetMyPartnerStaff mengambil akun staf yang sedang login
+func (s *PartnerService) GetMyPartnerStaff(partnerIDStr string, staffID int) (*PartnerStaff, error) {
+	return s.AuthorizePartnerStaff(partnerIDStr, staffID, StaffRoleCashier, StaffRoleFinance)
+}
+
+// CreatePartnerStaff membuat akun staf (cashier/finance) dengan email login sendiri
+func (s *PartnerService) CreatePartnerStaff(partnerIDStr string, req CreatePartnerStaffRequest) (*PartnerStaff, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, errors.New("nama staf wajib diisi")
+	}
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+
+	// Email staf tidak boleh sama dengan email login partner mana pun
+	existingPartner, err := s.repo.FindPartnerByEmail(email)
+	if err != nil {
+		return nil, errors.New("gagal memeriksa email")
+	}
+	if existingPartner != nil {
+		return nil, errors.New("email sudah terdaftar")
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, errors.New("gagal memproses password")
+	}
+	staff := &PartnerStaff{
+		PartnerID: partnerID,
+		Name:      name,
+		Email:     sql.NullString{String: email, Valid: true},
+		Password:  sql.NullString{String: string(hashedPassword), Valid: true},
+		Role:      req.Role,
+	}
+	if err := s.repo.CreatePartnerStaff(staff); err != nil {
+		return nil, err
+	}
+	log.Printf("Partner ID %d added %s staff ID %d", partnerID, staff.Role, staff.ID)
+	return staff, nil
+}
+
+// UpdatePartnerStaff mengubah nama, role, atau menonaktifkan staf; akun owner tidak dapat diubah di sini
+func (s *PartnerService) UpdatePartnerStaff(partnerIDStr string, staffID int, req UpdatePartnerStaffRequest) (*PartnerStaff, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+	staff, err := s.repo.GetPartnerStaffByID(staffID, partnerID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil akun staf")
+	}
+	if staff == nil {
+		return nil, errors.New("akun staf tidak ditemukan")
+	}
+	if staff.Role == StaffRoleOwner {
+		return nil, errors.New("akun owner tidak dapat diubah")
+	}
+
+	if name := strings.TrimSpace(req.Name); name != "" {
+		staff.Name = name
+	}
+	if req.Role != "" {
+		staff.Role = req.Role
+	}
+	if req.IsActive != nil {
+		staff.IsActive = *req.IsActive
+	}
+	if err := s.repo.UpdatePartnerStaff(staff); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("akun staf tidak ditemukan")
+		}
+		return nil, errors.New("gagal memperbarui akun staf")
+	}
+	return s.repo.GetPartnerStaffByID(staffID, partnerID)
+}
+
+// ResetPartnerStaffPassword mengatur password baru staf oleh owner
+func (s *PartnerService) ResetPartnerStaffPassword(partnerIDStr string, staffID int, req ResetPartnerStaffPasswordRequest) error {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return errors.New("ID partner tidak valid")
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return errors.New("gagal memproses password baru")
+	}
+	if err := s.repo.UpdatePartnerStaffPassword(staffID, partnerID, string(hashedPassword)); err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("akun staf tidak ditemukan")
+		}
+		return errors.New("gagal memperbarui password staf")
+	}
+	return nil
+}
+
+// ChangeMyStaffPassword mengganti password staf yang sedang login; owner memakai ChangePassword
+func (s *PartnerService) ChangeMyStaffPassword(partnerIDStr string, staffID int, req ChangePasswordRequest) error {
+	if req.NewPassword != req.ConfirmNewPassword {
+		return errors.New("konfirmasi password baru tidak cocok")
+	}
+	staff, err := s.GetMyPartnerStaff(partnerIDStr, staffID)
+	if err != nil {
+		return err
+	}
+	if staff.Role == StaffRoleOwner {
+		return errors.New("owner mengganti password lewat /partner/password")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(staff.Password.String), []byte(req.OldPassword)); err != nil {
+		return errors.New("password lama salah")
+	}
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return errors.New("gagal memproses password baru")
+	}
+	if err := s.repo.UpdatePartnerStaffPassword(staff.ID, staff.PartnerID, string(hashedPassword)); err != nil {
+		return errors.New("gagal memperbarui password staf")
+	}
+	return nil
+}
+
+// --- Inventaris Sampah Partner ---
+
+// Batas halaman mutasi stok
+const (
+	defaultInventoryMovementLimit = 50
+	maxInventoryMovementLimit     = 200
+)
+
+// GetInventory mengambil stok partner saat ini beserta valuasi pada harga beli partner saat ini
+func (s *PartnerService) GetInventory(partnerIDStr string) (*InventorySummary, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+	items, err := s.repo.GetInventory(partnerID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil stok sampah")
+	}
+
+	totalWeight, totalValue := 0.0, 0.0
+	for _, item := range items {
+		weight, _ := strconv.ParseFloat(item.Weight, 64)
+		totalWeight += weight
+		totalValue += item.Value
+	}
+	return &InventorySummary{
+		Items:       items,
+		TotalWeight: fmt.Sprintf("%.2f", totalWeight),
+		TotalValue:  math.Round(totalValue*100) / 100,
+		ValuedAt:    time.Now(),
+	}, nil
+}
+
+// GetInventoryMovements mengambil mutasi stok, opsional difilter jenis sampah & jenis mutasi
+func (s *PartnerService) GetInventoryMovements(partnerIDStr string, wasteDetailID int, movementType string, limit, offset int) ([]InventoryMovement, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+	switch movementType {
+	case "", InventoryMovementDeposit, InventoryMovementDepositVoid, InventoryMovementDepositCorrection,
+		InventoryMovementSale, InventoryMovementDisposal, InventoryMovementAdjustment:
+	default:
+		return nil, errors.New("jenis mutasi stok tidak valid")
+	}
+	if limit <= 0 {
+		limit = defaultInventoryMovementLimit
+	}
+	if limit > maxInventoryMovementLimit {
+		limit = maxInventoryMovementLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	movements, err := s.repo.GetInventoryMovements(partnerID, wasteDetailID, movementType, limit, offset)
+	if err != nil {
+		return nil, errors.New("gagal mengambil mutasi stok")
+	}
+	return movements, nil
+}
+
+// RecordInventoryOutbound mengurangi stok karena dibuang; seluruh item gagal jika salah satu stoknya kurang.
+// Stok yang dijual harus lewat CreateOfftakeSale agar penjualan selalu punya recycler, invoice, dan pendapatan.
+func (s *PartnerService) RecordInventoryOutbound(partnerIDStr string, req InventoryOutboundRequest) (*InventorySummary, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+	if req.Type == InventoryMovementSale {
+		return nil, errors.New("type sale tidak valid, catat penjualan lewat /partner/offtake/sales")
+	}
+	if req.Type != InventoryMovementDisposal {
+		return nil, errors.New("type stok keluar tidak valid, gunakan disposal")
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, errors.New("alasan pembuangan wajib diisi")
+	}
+
+	movements := make([]InventoryMovement, 0, len(req.Items))
+	for i, item := range req.Items {
+		if item.Weight <= 0 && item.Quantity <= 0 {
+			return nil, fmt.Errorf("item %d: weight atau quantity wajib diisi", i+1)
+		}
+		movements = append(movements, InventoryMovement{
+			PartnerID:      partnerID,
+			WasteDetailID:  item.WasteDetailID,
+			Type:           req.Type,
+			WeightChange:   -item.Weight,
+			QuantityChange: -item.Quantity,
+			Reason:         sql.NullString{String: reason, Valid: reason != ""},
+			StaffID:        staffIDArg(req.StaffID),
+		})
+	}
+	if err := s.repo.RecordInventoryMovements(movements); err != nil {
+		return nil, err
+	}
+	return s.GetInventory(partnerIDStr)
+}
+
+// AdjustInventory mencatat penyesuaian stok manual dengan alasan (misal selisih stock opname)
+func (s *PartnerService) AdjustInventory(partnerIDStr string, req InventoryAdjustmentRequest) (*InventorySummary, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, errors.New("alasan penyesuaian wajib diisi")
+	}
+	if req.WeightChange == 0 && req.QuantityChange == 0 {
+		return nil, errors.New("weight_change atau quantity_change wajib diisi")
+	}
+
+	err = s.repo.RecordInventoryMovements([]InventoryMovement{{
+		PartnerID:      partnerID,
+		WasteDetailID:  req.WasteDetailID,
+		Type:           InventoryMovementAdjustment,
+		WeightChange:   req.WeightChange,
+		QuantityChange: req.QuantityChange,
+		Reason:         sql.NullString{String: reason, Valid: true},
+		StaffID:        staffIDArg(req.StaffID),
+	}})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetInventory(partnerIDStr)
+}
+
+// --- Penjualan ke Recycler (Offtake) ---
+
+// Batas halaman daftar penjualan & rentang laporan margin
+const (
+	defaultOfftakeSaleLimit = 50
+	maxOfftakeSaleLimit     = 200
+	maxOfftakeReportDays    = 366
+	maxOfftakeInvoiceSizeMB = 10
+)
+
+// parseOfftakePeriod membaca rentang tanggal inklusif "YYYY-MM-DD" di zona waktu default;
+// default awal bulan ini s.d. hari ini. Mengembalikan awal hari `from` dan awal hari setelah `to`.
+func parseOfftakePeriod(fromStr, toStr string) (time.Time, time.Time, error) {
+	loc := schedule.Location(schedule.DefaultTimezone)
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, loc)
+	to := today
+	var err error
+	if fromStr != "" {
+		if from, err = time.ParseInLocation(schedule.DateLayout, fromStr, loc); err != nil {
+			return time.Time{}, time.Time{}, errors.New("tanggal from tidak valid (YYYY-MM-DD)")
+		}
+	}
+	if toStr != "" {
+		if to, err = time.ParseInLocation(schedule.DateLayout, toStr, loc); err != nil {
+			return time.Time{}, time.Time{}, errors.New("tanggal to tidak valid (YYYY-MM-DD)")
+		}
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, errors.New("rentang tanggal tidak valid: to sebelum from")
+	}
+	if to.Sub(from) > maxOfftakeReportDays*24*time.Hour {
+		return time.Time{}, time.Time{}, fmt.Errorf("rentang tanggal tidak valid: maksimal %d hari", maxOfftakeReportDays)
+	}
+	return from, to.AddDate(0, 0, 1), nil
+}
+
+// uploadOfftakeInvoice menyimpan foto/PDF invoice penjualan ke storage lokal dan mengembalikan URL CDN
+func (s *PartnerService) uploadOfftakeInvoice(partnerID int, fileHeader *multipart.FileHeader) (string, error) {
+	if fileHeader.Size > maxOfftakeInvoiceSizeMB*1024*1024 {
+		return "", fmt.Errorf("ukuran invoice tidak valid (maksimal %d MB)", maxOfftakeInvoiceSizeMB)
+	}
+	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
+	switch ext {
+	case ".jpg", ".jpeg", ".png", ".pdf":
+	case "":
+		ext = ".jpg"
+	default:
+		return "", errors.New("format invoice tidak valid (jpg, png, atau pdf)")
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		return "", errors.New("gagal membaca file invoice")
+	}
+	defer file.Close()
+
+	invoiceDir := filepath.Join(config.GetMediaBasePath(), "offtake_invoices")
+	if err := os.MkdirAll(invoiceDir, 0755); err != nil {
+		log.Printf("Error creating offtake invoice directory: %v", err)
+		return "", errors.New("gagal menyiapkan penyimpanan invoice")
+	}
+
+	filename := fmt.Sprintf("partner_%d_%d%s", partnerID, time.Now().UnixNano(), ext)
+	dst, err := os.Create(filepath.Join(invoiceDir, filename))
+	if err != nil {
+		log.Printf("Error creating destination file for offtake invoice: %v", err)
+		return "", errors.New("gagal menyimpan invoice")
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, file); err != nil {
+		log.Printf("Error copying offtake invoice to destination: %v", err)
+		os.Remove(dst.Name())
+		return "", errors.New("gagal menyimpan invoice")
+	}
+	return fmt.Sprintf("%s/offtake_invoices/%s", config.GetCDNBaseURL(), filename), nil
+}
+
+// GetOfftakeRecyclers daftar recycler aktif yang bisa dipilih partner
+func (s *PartnerService) GetOfftakeRecyclers() ([]admin.Recycler, error) {
+	recyclers, err := s.adminRepo.GetAllRecyclers(admin.RecyclerStatusActive)
+	if err != nil {
+		return nil, errors.New("gagal mengambil daftar recycler")
+	}
+	return recyclers, nil
+}
+
+// CreateOfftakeSale mencatat penjualan ke recycler dan mengurangi stok partner dalam satu transaksi
+func (s *PartnerService) CreateOfftakeSale(partnerIDStr string, req CreateOfftakeSaleRequest) (*OfftakeSale, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+
+	// 1. Validasi item
+	var items []OfftakeSaleItemInput
+	if err := json.Unmarshal([]byte(req.ItemsJSON), &items); err != nil {
+		return nil, errors.New("format items_json tidak valid")
+	}
+	if len(items) == 0 {
+		return nil, errors.New("items_json wajib diisi minimal satu item")
+	}
+	seen := make(map[int]bool)
+	for i, item := range items {
+		if item.WasteDetailID <= 0 {
+			return nil, fmt.Errorf("item %d: waste_detail_id tidak valid", i+1)
+		}
+		if seen[item.WasteDetailID] {
+			return nil, fmt.Errorf("item %d: waste_detail_id %d tidak valid, muncul lebih dari sekali", i+1, item.WasteDetailID)
+		}
+		seen[item.WasteDetailID] = true
+		if item.Weight <= 0 || item.Quantity < 0 {
+			return nil, fmt.Errorf("item %d: berat tidak valid", i+1)
+		}
+		if item.PricePerKg <= 0 {
+			return nil, fmt.Errorf("item %d: price_per_kg tidak valid", i+1)
+		}
+	}
+
+	// 2. Tanggal penjualan (default hari ini, tidak boleh di masa depan)
+	loc := schedule.Location(schedule.DefaultTimezone)
+	today := time.Now().In(loc).Format(schedule.DateLayout)
+	saleDate := strings.TrimSpace(req.SaleDate)
+	if saleDate == "" {
+		saleDate = today
+	} else if _, err := time.ParseInLocation(schedule.DateLayout, saleDate, loc); err != nil || saleDate > today {
+		return nil, errors.New("sale_date tidak valid (YYYY-MM-DD, tidak boleh di masa depan)")
+	}
+
+	// 3. Recycler harus terdaftar dan aktif
+	recycler, err := s.adminRepo.GetRecyclerByID(req.RecyclerID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil data recycler")
+	}
+	if recycler == nil || recycler.Status != admin.RecyclerStatusActive {
+		return nil, errors.New("recycler tidak ditemukan atau tidak aktif")
+	}
+
+	// 4. Foto invoice wajib sebagai bukti pendapatan
+	if req.InvoicePhoto == nil {
+		return nil, errors.New("invoice_photo wajib diisi")
+	}
+	invoiceURL, err := s.uploadOfftakeInvoice(partnerID, req.InvoicePhoto)
+	if err != nil {
+		return nil, err
+	}
+
+	// 5. Susun penjualan & mutasi stok keluar
+	invoiceNumber := strings.TrimSpace(req.InvoiceNumber)
+	notes := strings.TrimSpace(req.Notes)
+	reason := fmt.Sprintf("Dijual ke %s", recycler.Name)
+	if invoiceNumber != "" {
+		reason += " (invoice " + invoiceNumber + ")"
+	}
+	args := &ArgsOfftakeSaleCreation{
+		Sale: OfftakeSale{
+			PartnerID:     partnerID,
+			RecyclerID:    recycler.ID,
+			SaleDate:      saleDate,
+			InvoiceNumber: sql.NullString{String: invoiceNumber, Valid: invoiceNumber != ""},
+			InvoicePhoto:  sql.NullString{String: invoiceURL, Valid: true},
+			Notes:         sql.NullString{String: notes, Valid: notes != ""},
+			StaffID:       staffIDArg(req.StaffID),
+		},
+	}
+	totalWeight, totalAmount := 0.0, 0.0
+	for _, item := range items {
+		subtotal := math.Round(item.Weight*item.PricePerKg*100) / 100
+		totalWeight += item.Weight
+		totalAmount += subtotal
+		args.Sale.Items = append(args.Sale.Items, OfftakeSaleItem{
+			WasteDetailID: item.WasteDetailID,
+			Weight:        item.Weight,
+			Quantity:      item.Quantity,
+			PricePerKg:    item.PricePerKg,
+			Subtotal:      subtotal,
+		})
+		args.Movements = append(args.Movements, InventoryMovement{
+			PartnerID:      partnerID,
+			WasteDetailID:  item.WasteDetailID,
+			Type:           InventoryMovementSale,
+			WeightChange:   -item.Weight,
+			QuantityChange: -item.Quantity,
+			Reason:         sql.NullString{String: reason, Valid: true},
+			StaffID:        args.Sale.StaffID,
+		})
+	}
+	args.Sale.TotalWeight = fmt.Sprintf("%.2f", totalWeight)
+	args.Sale.TotalAmount = math.Round(totalAmount*100) / 100
+
+	saleID, err := s.repo.CreateOfftakeSale(args)
+	if err != nil {
+		removeMediaFile(invoiceURL) // Invoice tanpa penjualan tidak disimpan
+		return nil, err
+	}
+	sale, err := s.repo.GetOfftakeSaleByID(saleID, partnerID)
+	if err != nil || sale == nil {
+		return nil, errors.New("penjualan tersimpan, tetapi gagal mengambil datanya")
+	}
+	return sale, nil
+}
+
+// GetOfftakeSales daftar penjualan partner dalam rentang tanggal, opsional per recycler
+func (s *PartnerService) GetOfftakeSales(partnerIDStr, fromStr, toStr string, recyclerID, limit, offset int) ([]OfftakeSale, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+	from, to, err := parseOfftakePeriod(fromStr, toStr)
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = defaultOfftakeSaleLimit
+	}
+	if limit > maxOfftakeSaleLimit {
+		limit = maxOfftakeSaleLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	sales, err := s.repo.GetOfftakeSales(partnerID, from.Format(schedule.DateLayout), to.AddDate(0, 0, -1).Format(schedule.DateLayout), recyclerID, limit, offset)
+	if err != nil {
+		return nil, errors.New("gagal mengambil daftar penjualan")
+	}
+	return sales, nil
+}
+
+// GetOfftakeSaleByID detail satu penjualan beserta rinciannya
+func (s *PartnerService) GetOfftakeSaleByID(partnerIDStr string, saleID int) (*OfftakeSale, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+	sale, err := s.repo.GetOfftakeSaleByID(saleID, partnerID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil data penjualan")
+	}
+	if sale == nil {
+		return nil, errors.New("penjualan tidak ditemukan")
+	}
+	return sale, nil
+}
+
+// GetOfftakeMarginReport laporan pendapatan penjualan ke recycler dibanding biaya beli sampah dari user.
+// Biaya beli = Xpoin yang dibayarkan pada deposit yang tidak dibatalkan, dikonversi dengan kurs Xpoin->Rp.
+func (s *PartnerService) GetOfftakeMarginReport(partnerIDStr, fromStr, toStr string) (*OfftakeMarginReport, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil {
+		return nil, errors.New("ID partner tidak valid")
+	}
+	from, to, err := parseOfftakePeriod(fromStr, toStr)
+	if err != nil {
+		return nil, err
+	}
+	items, saleCount, err := s.repo.GetOfftakeMarginItems(partnerID, from, to)
+	if err != nil {
+		return nil, errors.New("gagal mengambil laporan margin")
+	}
+
+	report := &OfftakeMarginReport{
+		From:      from.Format(schedule.DateLayout),
+		To:        to.AddDate(0, 0, -1).Format(schedule.DateLayout),
+		SaleCount: saleCount,
+		Items:     items,
+	}
+	soldWeight, purchasedWeight := 0.0, 0.0
+	for i := range report.Items {
+		item := &report.Items[i]
+		item.PurchaseCost = float64(item.PurchaseXpoin) * conversionRateXpToRp
+		if item.SoldWeight > 0 {
+			item.AvgSalePricePerKg = math.Round(item.Revenue/item.SoldWeight*100) / 100
+		}
+		if item.PurchasedWeight > 0 {
+			item.AvgPurchasePricePerKg = math.Round(item.PurchaseCost/item.PurchasedWeight*100) / 100
+		}
+		if item.SoldWeight > 0 && item.PurchasedWeight > 0 {
+			item.MarginPerKg = math.Round((item.AvgSalePricePerKg-item.AvgPurchasePricePerKg)*100) / 100
+		}
+		report.Revenue += item.Revenue
+		report.PurchaseCost += item.PurchaseCost
+		soldWeight += item.SoldWeight
+		purchasedWeight += item.PurchasedWeight
+	}
+	report.Revenue = math.Round(report.Revenue*100) / 100
+	report.PurchaseCost = math.Round(report.PurchaseCost*100) / 100
+	report.GrossMargin = math.Round((report.Revenue-report.PurchaseCost)*100) / 100
+	if report.Revenue > 0 {
+		report.MarginPercent = math.Round(report.GrossMargin/report.Revenue*10000) / 100
+	}
+	report.SoldWeight = fmt.Sprintf("%.2f", soldWeight)
+	report.PurchasedWeight = fmt.Sprintf("%.2f", purchasedWeight)
+	return report, nil
 }
@@ -0,0 +1,868 @@
+package partner
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"xetor.id/backend/internal/domain/admin"
+	"xetor.id/backend/internal/domain/user"
+	"xetor.id/backend/internal/fraud"
+	"xetor.id/backend/internal/schedule"
+)
+
+func TestDepositConsumerInTx(t *testing.T) {
+	errConsume := errors.New("kode QR offline sudah digunakan")
+	errPersist := errors.New("xpoin partner tidak mencukupi")
+
+	tests := []struct {
+		name        string
+		consumeErr  error
+		persistErr  error
+		wantErr     error
+		wantRestore bool
+	}{
+		{"committed transaction keeps claim", nil, nil, nil, false},
+		{"failed transaction restores claim", nil, errPersist, errPersist, true},
+		{"rejected claim has nothing to restore", errConsume, nil, errConsume, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restored := false
+			consume := depositConsumer(func(tx *sql.Tx) (func(), error) {
+				if tt.consumeErr != nil {
+					return nil, tt.consumeErr
+				}
+				return func() { restored = true }, nil
+			})
+
+			err := consume.inTx(func(hook func(tx *sql.Tx) error) error {
+				// Meniru repo: hook dijalankan di awal transaksi, error-nya membatalkan transaksi
+				if err := hook(nil); err != nil {
+					return err
+				}
+				return tt.persistErr
+			})
+			if err != tt.wantErr {
+				t.Fatalf("inTx error = %v, want %v", err, tt.wantErr)
+			}
+			if restored != tt.wantRestore {
+				t.Fatalf("restored = %v, want %v", restored, tt.wantRestore)
+			}
+		})
+	}
+
+	t.Run("nil consumer", func(t *testing.T) {
+		var consume depositConsumer
+		called := false
+		err := consume.inTx(func(hook func(tx *sql.Tx) error) error {
+			called = true
+			return hook(nil)
+		})
+		if err != nil || !called {
+			t.Fatalf("inTx with nil consumer = %v, persist called %v", err, called)
+		}
+	})
+}
+
+func TestDepositConsumerWithRestore(t *testing.T) {
+	errConsume := errors.New("token sudah digunakan oleh mitra lain")
+	errPersist := errors.New("gagal menyimpan header deposit")
+
+	tests := []struct {
+		name       string
+		consumer   depositConsumer
+		persistErr error
+		wantUndo   bool
+	}{
+		{"committed", nil, nil, false},
+		{"transaction failed", nil, errPersist, true},
+		{"consumer rejected", func(*sql.Tx) (func(), error) { return nil, errConsume }, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			undone := 0
+			consume := tt.consumer.withRestore(func() { undone++ })
+			consume.inTx(func(hook func(tx *sql.Tx) error) error {
+				if err := hook(nil); err != nil {
+					return err
+				}
+				return tt.persistErr
+			})
+			if (undone == 1) != tt.wantUndo || undone > 1 {
+				t.Fatalf("undo called %d times, want called = %v", undone, tt.wantUndo)
+			}
+		})
+	}
+}
+
+func TestRemoveMediaFile(t *testing.T) {
+	mediaDir := t.TempDir()
+	t.Setenv("MEDIA_BASE_PATH", mediaDir)
+	t.Setenv("CDN_BASE_URL", "https://cdn.example.test/")
+
+	photoPath := filepath.Join(mediaDir, "deposit_photos", "deposit_1_2_3.jpg")
+	if err := os.MkdirAll(filepath.Dir(photoPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(photoPath, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	outside := filepath.Join(mediaDir, "keep.jpg")
+	if err := os.WriteFile(outside, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	removeDepositPhotoFiles([]user.DepositPhoto{
+		{URL: "https://cdn.example.test/deposit_photos/deposit_1_2_3.jpg"},
+		{URL: "https://other.example.test/keep.jpg"},
+		{URL: "https://cdn.example.test/deposit_photos/../keep.jpg"},
+	})
+	if _, err := os.Stat(photoPath); !os.IsNotExist(err) {
+		t.Fatalf("uploaded photo not removed: %v", err)
+	}
+	if _, err := os.Stat(outside); err != nil {
+		t.Fatalf("file outside CDN path removed: %v", err)
+	}
+}
+
+// staffRepo memalsukan penyimpanan akun staf untuk AuthorizePartnerStaff
+type staffRepo struct {
+	PartnerRepository
+	staff map[int]*PartnerStaff // Per ID staf
+	owner *PartnerStaff
+	err   error
+}
+
+func (r *staffRepo) GetPartnerStaffByID(staffID, partnerID int) (*PartnerStaff, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	if st, ok := r.staff[staffID]; ok && st.PartnerID == partnerID {
+		return st, nil
+	}
+	return nil, nil
+}
+
+func (r *staffRepo) FindOrCreateOwnerStaff(partnerID int) (*PartnerStaff, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return r.owner, nil
+}
+
+func newStaffRepo() *staffRepo {
+	return &staffRepo{
+		owner: &PartnerStaff{ID: 1, PartnerID: 7, Role: StaffRoleOwner, IsActive: true},
+		staff: map[int]*PartnerStaff{
+			1: {ID: 1, PartnerID: 7, Role: StaffRoleOwner, IsActive: true},
+			2: {ID: 2, PartnerID: 7, Role: StaffRoleCashier, IsActive: true},
+			3: {ID: 3, PartnerID: 7, Role: StaffRoleFinance, IsActive: true},
+			4: {ID: 4, PartnerID: 7, Role: StaffRoleCashier, IsActive: false},
+			5: {ID: 5, PartnerID: 8, Role: StaffRoleFinance, IsActive: true},
+		},
+	}
+}
+
+func TestAuthorizePartnerStaffRoleMatrix(t *testing.T) {
+	// Grup akses sama dengan middleware di router
+	groups := []struct {
+		name  string
+		roles []string
+	}{
+		{"owner only", nil},
+		{"cashier access", []string{StaffRoleCashier}},
+		{"finance access", []string{StaffRoleFinance}},
+		{"any staff", []string{StaffRoleCashier, StaffRoleFinance}},
+	}
+	staff := []struct {
+		name    string
+		staffID int
+		allowed map[string]bool // Grup yang boleh diakses; nil = semua ditolak dengan errMsg
+		errMsg  string
+	}{
+		{"owner", 1, map[string]bool{"owner only": true, "cashier access": true, "finance access": true, "any staff": true}, ""},
+		{"legacy token without staff id", 0, map[string]bool{"owner only": true, "cashier access": true, "finance access": true, "any staff": true}, ""},
+		{"cashier", 2, map[string]bool{"cashier access": true, "any staff": true}, "akses ditolak untuk role staf ini"},
+		{"finance", 3, map[string]bool{"finance access": true, "any staff": true}, "akses ditolak untuk role staf ini"},
+		{"inactive cashier", 4, nil, "akun staf tidak aktif"},
+		{"staff of other partner", 5, nil, "akun staf tidak aktif"},
+		{"unknown staff", 99, nil, "akun staf tidak aktif"},
+	}
+
+	s := &PartnerService{repo: newStaffRepo()}
+	for _, st := range staff {
+		for _, g := range groups {
+			t.Run(st.name+"/"+g.name, func(t *testing.T) {
+				got, err := s.AuthorizePartnerStaff("7", st.staffID, g.roles...)
+				if st.allowed[g.name] {
+					if err != nil {
+						t.Fatalf("AuthorizePartnerStaff error = %v, want allowed", err)
+					}
+					if got == nil || (st.staffID != 0 && got.ID != st.staffID) {
+						t.Fatalf("AuthorizePartnerStaff returned %+v for staff %d", got, st.staffID)
+					}
+					return
+				}
+				if err == nil || err.Error() != st.errMsg {
+					t.Fatalf("AuthorizePartnerStaff error = %v, want %q", err, st.errMsg)
+				}
+			})
+		}
+	}
+
+	t.Run("invalid partner id", func(t *testing.T) {
+		if _, err := s.AuthorizePartnerStaff("abc", 1); err == nil || err.Error() != "ID partner tidak valid" {
+			t.Fatalf("error = %v, want invalid partner id", err)
+		}
+	})
+	t.Run("repository error", func(t *testing.T) {
+		repo := newStaffRepo()
+		repo.err = errors.New("connection refused")
+		if _, err := (&PartnerService{repo: repo}).AuthorizePartnerStaff("7", 2, StaffRoleCashier); err == nil || err.Error() != "gagal memeriksa akun staf" {
+			t.Fatalf("error = %v, want repository error", err)
+		}
+	})
+}
+
+// offtakeRepo memalsukan penyimpanan penjualan offtake yang selalu gagal (misal stok tidak mencukupi)
+type offtakeRepo struct {
+	PartnerRepository
+	err error
+}
+
+func (r *offtakeRepo) CreateOfftakeSale(args *ArgsOfftakeSaleCreation) (int, error) {
+	return 0, r.err
+}
+
+type offtakeAdminRepo struct {
+	AdminRepositoryForPartner
+}
+
+func (r *offtakeAdminRepo) GetRecyclerByID(id int) (*admin.Recycler, error) {
+	return &admin.Recycler{ID: id, Name: "Recycler Uji", Status: admin.RecyclerStatusActive}, nil
+}
+
+// uploadFileHeader membuat file upload multipart seperti yang diterima handler
+func uploadFileHeader(t *testing.T, filename string, content []byte) *multipart.FileHeader {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	fw, err := mw.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fw.Write(content)
+	mw.Close()
+	form, err := multipart.NewReader(&body, mw.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { form.RemoveAll() })
+	return form.File["file"][0]
+}
+
+func TestCreateOfftakeSaleRemovesInvoiceOnFailure(t *testing.T) {
+	mediaDir := t.TempDir()
+	t.Setenv("MEDIA_BASE_PATH", mediaDir)
+	t.Setenv("CDN_BASE_URL", "https://cdn.example.test")
+
+	errStock := errors.New("stok sampah tidak mencukupi")
+	s := &PartnerService{repo: &offtakeRepo{err: errStock}, adminRepo: &offtakeAdminRepo{}}
+	_, err := s.CreateOfftakeSale("7", CreateOfftakeSaleRequest{
+		RecyclerID:   3,
+		ItemsJSON:    `[{"waste_detail_id": 1, "weight": 12.5, "price_per_kg": 2000}]`,
+		InvoicePhoto: uploadFileHeader(t, "invoice.png", []byte("png")),
+	})
+	if err != errStock {
+		t.Fatalf("CreateOfftakeSale error = %v, want %v", err, errStock)
+	}
+	files, _ := filepath.Glob(filepath.Join(mediaDir, "offtake_invoices", "*"))
+	if len(files) != 0 {
+		t.Fatalf("invoice files left after failed sale: %v", files)
+	}
+}
+
+func TestRecordInventoryOutboundOnlyDisposal(t *testing.T) {
+	s := &PartnerService{} // Repo tidak boleh dipanggil untuk request yang ditolak
+	items := []InventoryOutboundItem{{WasteDetailID: 1, Weight: 2}}
+
+	tests := []struct {
+		name string
+		req  InventoryOutboundRequest
+		want string
+	}{
+		{"sale goes through offtake", InventoryOutboundRequest{Type: InventoryMovementSale, Items: items},
+			"type sale tidak valid, catat penjualan lewat /partner/offtake/sales"},
+		{"unknown type", InventoryOutboundRequest{Type: InventoryMovementAdjustment, Reason: "opname", Items: items},
+			"type stok keluar tidak valid, gunakan disposal"},
+		{"disposal needs reason", InventoryOutboundRequest{Type: InventoryMovementDisposal, Items: items},
+			"alasan pembuangan wajib diisi"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := s.RecordInventoryOutbound("7", tt.req)
+			if err == nil || err.Error() != tt.want {
+				t.Fatalf("RecordInventoryOutbound error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+// wastePriceImportRepo memalsukan daftar harga partner dan mencatat perubahan yang diterapkan import
+type wastePriceImportRepo struct {
+	PartnerRepository
+	existing []PartnerWastePriceDetail
+	applied  *WastePriceImportChanges
+}
+
+func (r *wastePriceImportRepo) FindOrCreateWastePriceHeader(partnerID int) (int, error) {
+	return 50, nil
+}
+
+func (r *wastePriceImportRepo) GetWastePriceDetailsByPartnerID(partnerID int) ([]PartnerWastePriceDetail, error) {
+	return r.existing, nil
+}
+
+func (r *wastePriceImportRepo) ApplyWastePriceImport(changes *WastePriceImportChanges) error {
+	r.applied = changes
+	return nil
+}
+
+type wasteCatalogRepo struct {
+	AdminRepositoryForPartner
+}
+
+func (r *wasteCatalogRepo) GetAllWasteDetails() ([]admin.WasteDetail, error) {
+	return []admin.WasteDetail{
+		{ID: 1, Name: "Botol PET"}, {ID: 2, Name: "Kardus"}, {ID: 3, Name: "Kaleng"},
+		{ID: 4, Name: "Besi"}, {ID: 5, Name: "Kaca"}, {ID: 6, Name: "Kaca"},
+	}, nil
+}
+
+func (r *wasteCatalogRepo) RecalculateAndUpdateWasteDetailXpoin(wasteDetailID int) error {
+	return nil
+}
+
+func newWastePriceImportService() (*PartnerService, *wastePriceImportRepo) {
+	repo := &wastePriceImportRepo{existing: []PartnerWastePriceDetail{
+		{ID: 100, WasteDetailID: sql.NullInt32{Int32: 1, Valid: true}, Name: "Botol PET", Price: "2500.00", Unit: WasteUnitKg},
+		{ID: 101, WasteDetailID: sql.NullInt32{Int32: 2, Valid: true}, Name: "Kardus", Price: "1200.00", Unit: WasteUnitKg},
+		{ID: 102, Name: "Campuran", Price: "500.00", Unit: WasteUnitKg},
+	}}
+	return &PartnerService{repo: repo, adminRepo: &wasteCatalogRepo{}}, repo
+}
+
+func TestImportWastePricesRowValidation(t *testing.T) {
+	file := strings.Join([]string{
+		"waste_detail_id,name,price,unit,action",
+		"1,,2600,,",           // 2: update harga
+		"2,,1200,kg,",         // 3: tidak berubah
+		"3,,15000,kg,",        // 4: item baru
+		",Campuran,,,delete",  // 5: hapus lewat nama
+		"abc,,1000,kg,",       // 6
+		"99,,1000,kg,",        // 7
+		",,1000,kg,",          // 8
+		"4,,,kg,",             // 9
+		"4,,Rp 1000,ton,",     // 10
+		"4,,-5,kg,",           // 11
+		"1,,2700,,",           // 12
+		",Kaca,1000,kg,",      // 13
+		",Besi,800,kg,",       // 14: item baru dari nama katalog
+		"4,,900,kg,",          // 15
+		"5,,100,kg,remove",    // 16
+		",Tidak Ada,,,delete", // 17
+		",,,,",                // 18: baris kosong dilewati
+	}, "\n")
+	s, repo := newWastePriceImportService()
+	report, err := s.ImportWastePrices("7", uploadFileHeader(t, "harga.csv", []byte(file)), false)
+	if err != nil {
+		t.Fatalf("ImportWastePrices: %v", err)
+	}
+
+	want := map[int]struct{ result, err string }{
+		2:  {WastePriceImportResultUpdate, ""},
+		3:  {WastePriceImportResultUnchanged, ""},
+		4:  {WastePriceImportResultCreate, ""},
+		5:  {WastePriceImportResultDelete, ""},
+		6:  {WastePriceImportResultError, "waste_detail_id tidak valid"},
+		7:  {WastePriceImportResultError, "waste_detail_id 99 tidak ditemukan di katalog"},
+		8:  {WastePriceImportResultError, "waste_detail_id atau name wajib diisi"},
+		9:  {WastePriceImportResultError, "price wajib diisi untuk item baru"},
+		10: {WastePriceImportResultError, "unit tidak valid, gunakan kg atau pcs"},
+		11: {WastePriceImportResultError, "price tidak valid, harus angka positif"},
+		12: {WastePriceImportResultError, "item harga yang sama sudah diubah di baris 2"},
+		13: {WastePriceImportResultError, "nama tidak cocok dengan tepat satu jenis sampah di katalog, isi waste_detail_id"},
+		14: {WastePriceImportResultCreate, ""},
+		15: {WastePriceImportResultError, "jenis sampah yang sama sudah ditambahkan di baris 14"},
+		16: {WastePriceImportResultError, "action tidak valid (upsert atau delete)"},
+		17: {WastePriceImportResultError, "item harga yang akan dihapus tidak ditemukan"},
+	}
+	if len(report.Rows) != len(want) {
+		t.Fatalf("got %d report rows, want %d: %+v", len(report.Rows), len(want), report.Rows)
+	}
+	for _, row := range report.Rows {
+		w, ok := want[row.Row]
+		if !ok || row.Result != w.result || row.Error != w.err {
+			t.Errorf("row %d = %s %q, want %s %q", row.Row, row.Result, row.Error, w.result, w.err)
+		}
+	}
+	if report.TotalRows != 16 || report.Created != 2 || report.Updated != 1 || report.Deleted != 1 || report.Unchanged != 1 || report.Errors != 11 {
+		t.Errorf("unexpected totals %+v", *report)
+	}
+	if report.Applied || repo.applied != nil {
+		t.Fatal("import with error rows must not be applied")
+	}
+
+	for _, row := range report.Rows {
+		switch row.Row {
+		case 2:
+			if row.WastePriceID != 100 || row.OldPrice != "2500.00" || row.Price != "2600.00" || row.Xpoin != calculateXpoin(2600) {
+				t.Errorf("update row = %+v", row)
+			}
+		case 14:
+			if row.WasteDetailID != 4 || row.Name != "Besi" || row.Unit != WasteUnitKg {
+				t.Errorf("create by name row = %+v", row)
+			}
+		}
+	}
+}
+
+func TestImportWastePricesApply(t *testing.T) {
+	file := "Name;Price;Unit;Waste_Detail_ID\nBotol PET;2600,50;;1\n;15000;kg;3\n"
+	s, repo := newWastePriceImportService()
+
+	report, err := s.ImportWastePrices("7", uploadFileHeader(t, "harga.csv", []byte(file)), true)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if !report.DryRun || report.Applied || repo.applied != nil {
+		t.Fatalf("dry run applied changes: %+v", *report)
+	}
+
+	report, err = s.ImportWastePrices("7", uploadFileHeader(t, "harga.csv", []byte(file)), false)
+	if err != nil {
+		t.Fatalf("ImportWastePrices: %v", err)
+	}
+	if !report.Applied || repo.applied == nil {
+		t.Fatalf("valid import not applied: %+v", *report)
+	}
+	changes := repo.applied
+	if changes.HeaderID != 50 || len(changes.Updates) != 1 || len(changes.Creates) != 1 || len(changes.DeleteIDs) != 0 {
+		t.Fatalf("unexpected changes %+v", *changes)
+	}
+	if u := changes.Updates[0]; u.ID != 100 || u.Price != "2600.50" || u.Unit != WasteUnitKg {
+		t.Errorf("update = %+v", u)
+	}
+	if c := changes.Creates[0]; c.PartnerWastePriceID != 50 || c.Name != "Kaleng" || c.Price != "15000.00" || int(c.WasteDetailID.Int32) != 3 {
+		t.Errorf("create = %+v", c)
+	}
+}
+
+func TestImportWastePricesFileErrors(t *testing.T) {
+	tooMany := "name,price,unit\n" + strings.Repeat("Botol PET,1000,kg\n", maxWastePriceImportRows+1)
+	tests := []struct {
+		name     string
+		filename string
+		content  string
+		want     string
+	}{
+		{"unsupported format", "harga.xls", "name\n", "format file import tidak valid (csv atau xlsx)"},
+		{"empty file", "harga.csv", "", "baris header file import wajib diisi"},
+		{"missing key column", "harga.csv", "price,unit\n1000,kg\n", "kolom waste_detail_id atau name wajib diisi di header"},
+		{"only blank rows", "harga.csv", "name,price\n,\n", "file import tidak valid: tidak ada baris data"},
+		{"too many rows", "harga.csv", tooMany, "jumlah baris file import tidak valid (maksimal 1000)"},
+		{"broken xlsx", "harga.xlsx", "name,price\n", "isi file import tidak valid: file xlsx tidak valid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newWastePriceImportService()
+			_, err := s.ImportWastePrices("7", uploadFileHeader(t, tt.filename, []byte(tt.content)), true)
+			if err == nil || err.Error() != tt.want {
+				t.Fatalf("ImportWastePrices error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseImportPrice(t *testing.T) {
+	tests := []struct {
+		in   string
+		want float64
+		ok   bool
+	}{
+		{"2500", 2500, true},
+		{"2500.50", 2500.5, true},
+		{"2500,50", 2500.5, true},
+		{"Rp 2500", 2500, true},
+		{" Rp2500 ", 2500, true},
+		{"0", 0, false},
+		{"-1", 0, false},
+		{"abc", 0, false},
+		{"Inf", 0, false},
+		{"NaN", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := parseImportPrice(tt.in)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("parseImportPrice(%q) = %v, %v; want %v, ok %v", tt.in, got, err, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCorrectedDepositLine(t *testing.T) {
+	kg := DepositAdjustmentTargetDetail{ID: 1, Weight: 0.7, Xpoin: 233, Unit: WasteUnitKg, PriceXpoin: sql.NullInt32{Int32: 333, Valid: true}}
+	pcs := DepositAdjustmentTargetDetail{ID: 2, Weight: 1.5, Xpoin: 300, Unit: WasteUnitPcs,
+		Quantity: sql.NullInt32{Int32: 3, Valid: true}, PriceXpoin: sql.NullInt32{Int32: 100, Valid: true}}
+	legacyKg := kg
+	legacyKg.PriceXpoin = sql.NullInt32{}
+
+	tests := []struct {
+		name         string
+		detail       DepositAdjustmentTargetDetail
+		req          CorrectDepositDetailRequest
+		wantWeight   float64
+		wantQuantity int32
+		wantXpoin    int
+		wantErr      string
+	}{
+		{"kg from price version", kg, CorrectDepositDetailRequest{Weight: 0.3}, 0.3, 0, 99, ""},
+		{"kg without price version uses ratio", legacyKg, CorrectDepositDetailRequest{Weight: 1.4}, 1.4, 0, 466, ""},
+		{"kg requires weight", kg, CorrectDepositDetailRequest{Quantity: 2}, 0, 0, 0, "berat baru (weight) wajib diisi untuk item bersatuan kg"},
+		{"kg same weight", kg, CorrectDepositDetailRequest{Weight: 0.7}, 0, 0, 0, "berat baru sama dengan berat sebelumnya"},
+		{"pcs from price version", pcs, CorrectDepositDetailRequest{Quantity: 5}, 2.5, 5, 500, ""},
+		{"pcs requires quantity", pcs, CorrectDepositDetailRequest{Weight: 2}, 0, 0, 0, "jumlah baru (quantity) wajib diisi untuk item bersatuan pcs"},
+		{"pcs same quantity", pcs, CorrectDepositDetailRequest{Quantity: 3}, 0, 0, 0, "jumlah baru sama dengan jumlah sebelumnya"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line, err := correctedDepositLine(tt.detail, tt.req)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("correctedDepositLine: %v", err)
+			}
+			if line.NewWeight != tt.wantWeight || line.NewQuantity.Int32 != tt.wantQuantity || line.NewXpoin != tt.wantXpoin {
+				t.Fatalf("line = %+v, want weight %v quantity %d xpoin %d", line, tt.wantWeight, tt.wantQuantity, tt.wantXpoin)
+			}
+			if line.OldWeight != tt.detail.Weight || line.OldQuantity != tt.detail.Quantity || line.OldXpoin != tt.detail.Xpoin {
+				t.Fatalf("old values = %+v, want detail %+v", line, tt.detail)
+			}
+		})
+	}
+
+	t.Run("repeated corrections keep xpoin", func(t *testing.T) {
+		detail := kg
+		for _, weight := range []float64{0.3, 0.7, 0.3, 0.7} {
+			line, err := correctedDepositLine(detail, CorrectDepositDetailRequest{Weight: weight})
+			if err != nil {
+				t.Fatalf("correctedDepositLine(%v): %v", weight, err)
+			}
+			detail.Weight, detail.Xpoin = line.NewWeight, line.NewXpoin
+		}
+		if detail.Xpoin != kg.Xpoin {
+			t.Fatalf("xpoin after corrections back to %v kg = %d, want %d", kg.Weight, detail.Xpoin, kg.Xpoin)
+		}
+	})
+}
+
+func TestApplyWasteUnit(t *testing.T) {
+	kg := &WastePriceInfo{Unit: "Kg", XpoinPerUnit: 333}
+	pcs := &WastePriceInfo{Unit: "buah", XpoinPerUnit: 50, AverageUnitWeight: sql.NullFloat64{Float64: 0.015, Valid: true}}
+	pcsNoWeight := &WastePriceInfo{Unit: "pcs", XpoinPerUnit: 50}
+	unknown := &WastePriceInfo{Unit: "liter", XpoinPerUnit: 100}
+
+	tests := []struct {
+		name       string
+		item       DepositWasteItem
+		price      *WastePriceInfo
+		wantXpoin  int
+		wantUnit   string
+		wantWeight float64
+		wantErr    string
+	}{
+		{"kg floors xpoin", DepositWasteItem{PartnerWastePriceDetailID: 1, Weight: 0.7}, kg, 233, WasteUnitKg, 0.7, ""},
+		{"kg rejects quantity", DepositWasteItem{PartnerWastePriceDetailID: 1, Weight: 1, Quantity: 2}, kg, 0, "", 0, "item ID 1 bersatuan kg, isi weight (bukan quantity)"},
+		{"kg rejects zero weight", DepositWasteItem{PartnerWastePriceDetailID: 1}, kg, 0, "", 0, "item ID 1 bersatuan kg, isi weight (bukan quantity)"},
+		{"kg rejects negative weight", DepositWasteItem{PartnerWastePriceDetailID: 1, Weight: -1}, kg, 0, "", 0, "item ID 1 bersatuan kg, isi weight (bukan quantity)"},
+		{"pcs converts weight from catalog", DepositWasteItem{PartnerWastePriceDetailID: 2, Quantity: 12}, pcs, 600, WasteUnitPcs, 0.18, ""},
+		{"pcs without average weight", DepositWasteItem{PartnerWastePriceDetailID: 2, Quantity: 3}, pcsNoWeight, 150, WasteUnitPcs, 0, ""},
+		{"pcs rejects weight", DepositWasteItem{PartnerWastePriceDetailID: 2, Quantity: 3, Weight: 1}, pcs, 0, "", 0, "item ID 2 bersatuan pcs, isi quantity (bukan weight)"},
+		{"pcs rejects zero quantity", DepositWasteItem{PartnerWastePriceDetailID: 2}, pcs, 0, "", 0, "item ID 2 bersatuan pcs, isi quantity (bukan weight)"},
+		{"pcs rejects scale reading", DepositWasteItem{PartnerWastePriceDetailID: 2, Quantity: 3, ScaleReadingID: 9}, pcs, 0, "", 0, "item ID 2 bersatuan pcs, hasil timbang hanya untuk item kg"},
+		{"unknown unit treated as kg", DepositWasteItem{PartnerWastePriceDetailID: 3, Weight: 1.5}, unknown, 150, WasteUnitKg, 1.5, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item := tt.item
+			xpoin, err := applyWasteUnit(&item, tt.price)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("applyWasteUnit error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyWasteUnit: %v", err)
+			}
+			if xpoin != tt.wantXpoin || item.Unit != tt.wantUnit || item.Weight != tt.wantWeight {
+				t.Fatalf("applyWasteUnit = %d, unit %q, weight %v; want %d, %q, %v", xpoin, item.Unit, item.Weight, tt.wantXpoin, tt.wantUnit, tt.wantWeight)
+			}
+		})
+	}
+}
+
+// sessionPriceRepo memalsukan katalog harga partner untuk item sesi deposit
+type sessionPriceRepo struct {
+	PartnerRepository
+	prices map[int]*WastePriceInfo
+}
+
+func (r *sessionPriceRepo) GetWastePriceDetailByID(detailID int, partnerID int) (*PartnerWastePriceDetail, error) {
+	info, ok := r.prices[detailID]
+	if !ok {
+		return nil, nil
+	}
+	return &PartnerWastePriceDetail{ID: detailID, Name: fmt.Sprintf("Item %d", detailID), Unit: info.Unit, Xpoin: info.XpoinPerUnit}, nil
+}
+
+func (r *sessionPriceRepo) GetWastePriceInfoForCalculation(detailID int, partnerID int) (*WastePriceInfo, error) {
+	info, ok := r.prices[detailID]
+	if !ok {
+		return nil, errors.New("detail harga sampah tidak ditemukan")
+	}
+	copied := *info
+	return &copied, nil
+}
+
+func TestDepositSessionPcsItem(t *testing.T) {
+	repo := &sessionPriceRepo{prices: map[int]*WastePriceInfo{
+		1: {Unit: WasteUnitKg, XpoinPerUnit: 333},
+		2: {Unit: WasteUnitPcs, XpoinPerUnit: 50, AverageUnitWeight: sql.NullFloat64{Float64: 0.015, Valid: true}},
+	}}
+	s := &PartnerService{repo: repo}
+
+	items, totalWeight, estimatedXpoin, err := s.priceDepositSessionItems(7, []DepositWasteItem{
+		{PartnerWastePriceDetailID: 1, Weight: 0.7},
+		{PartnerWastePriceDetailID: 2, Quantity: 12},
+	})
+	if err != nil {
+		t.Fatalf("priceDepositSessionItems: %v", err)
+	}
+	want := []user.DepositSessionItem{
+		{PartnerWastePriceDetailID: 1, Name: "Item 1", Weight: 0.7, Unit: WasteUnitKg, EstimatedXpoin: 233},
+		{PartnerWastePriceDetailID: 2, Name: "Item 2", Weight: 0.18, Unit: WasteUnitPcs, Quantity: 12, EstimatedXpoin: 600},
+	}
+	if !reflect.DeepEqual(items, want) || math.Abs(totalWeight-0.88) > 1e-9 || estimatedXpoin != 833 {
+		t.Fatalf("session items = %+v (%v kg, %d xpoin), want %+v (0.88 kg, 833 xpoin)", items, totalWeight, estimatedXpoin, want)
+	}
+
+	// Konfirmasi menyusun ulang item deposit yang lolos aturan satuan dengan Xpoin yang sama
+	depositItems := depositItemsFromSession(items)
+	wantDeposit := []DepositWasteItem{{PartnerWastePriceDetailID: 1, Weight: 0.7}, {PartnerWastePriceDetailID: 2, Quantity: 12}}
+	if !reflect.DeepEqual(depositItems, wantDeposit) {
+		t.Fatalf("deposit items = %+v, want %+v", depositItems, wantDeposit)
+	}
+	for i, item := range depositItems {
+		xpoin, err := applyWasteUnit(&item, repo.prices[item.PartnerWastePriceDetailID])
+		if err != nil {
+			t.Fatalf("applyWasteUnit on confirmed item %d: %v", i, err)
+		}
+		if xpoin != items[i].EstimatedXpoin {
+			t.Fatalf("confirmed item %d xpoin = %d, want estimate %d", i, xpoin, items[i].EstimatedXpoin)
+		}
+	}
+
+	// Item sesi lama tanpa satuan tetap dikirim sebagai weight
+	legacy := depositItemsFromSession([]user.DepositSessionItem{{PartnerWastePriceDetailID: 1, Weight: 2}})
+	if !reflect.DeepEqual(legacy, []DepositWasteItem{{PartnerWastePriceDetailID: 1, Weight: 2}}) {
+		t.Fatalf("legacy deposit items = %+v", legacy)
+	}
+
+	for _, tt := range []struct {
+		name    string
+		items   []DepositWasteItem
+		wantErr string
+	}{
+		{"pcs item sent as weight", []DepositWasteItem{{PartnerWastePriceDetailID: 2, Weight: 0.5}}, "item ID 2 bersatuan pcs, isi quantity (bukan weight)"},
+		{"kg item sent as quantity", []DepositWasteItem{{PartnerWastePriceDetailID: 1, Quantity: 3}}, "item ID 1 bersatuan kg, isi weight (bukan quantity)"},
+		{"unknown price detail", []DepositWasteItem{{PartnerWastePriceDetailID: 9, Weight: 1}}, "detail harga sampah ID 9 tidak ditemukan"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, _, err := s.priceDepositSessionItems(7, tt.items); err == nil || err.Error() != tt.wantErr {
+				t.Fatalf("priceDepositSessionItems error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// fraudCaseRepo memalsukan aturan fraud dan mencatat perubahan asal deposit kasus fraud
+type fraudCaseRepo struct {
+	PartnerRepository
+	rules     []fraud.Rule
+	createErr error
+	created   []*DepositFraudCase
+	calls     []string
+	statusErr error
+}
+
+func (r *fraudCaseRepo) GetFraudRules() ([]fraud.Rule, error) {
+	return r.rules, nil
+}
+
+func (r *fraudCaseRepo) GetScheduleCalendar(partnerID int, from, to time.Time) (*schedule.Calendar, error) {
+	return nil, nil
+}
+
+func (r *fraudCaseRepo) GetDepositFraudSignals(partnerID, userID int, dayStart, dayEnd, identicalSince, frequencySince time.Time) (*DepositFraudSignals, error) {
+	return &DepositFraudSignals{PastWeights: map[float64]int{}}, nil
+}
+
+func (r *fraudCaseRepo) GetWastePriceDetailByID(detailID int, partnerID int) (*PartnerWastePriceDetail, error) {
+	return nil, nil
+}
+
+func (r *fraudCaseRepo) CreateDepositFraudCase(fc *DepositFraudCase, totalWeight float64, consume func(tx *sql.Tx) error) error {
+	r.created = append(r.created, fc)
+	return r.createErr
+}
+
+func (r *fraudCaseRepo) record(call string) error {
+	r.calls = append(r.calls, call)
+	return nil
+}
+
+func (r *fraudCaseRepo) recordStatus(call string) error {
+	r.calls = append(r.calls, call)
+	return r.statusErr
+}
+
+func (r *fraudCaseRepo) SetDepositSessionDepositHeader(sessionID, headerID int) error {
+	return r.record(fmt.Sprintf("session %d -> deposit %d", sessionID, headerID))
+}
+
+func (r *fraudCaseRepo) UpdateDepositSessionStatus(sessionID, partnerID int, fromStatus, toStatus string) error {
+	return r.recordStatus(fmt.Sprintf("session %d: %s -> %s", sessionID, fromStatus, toStatus))
+}
+
+func (r *fraudCaseRepo) SetPickupRequestDepositHeader(pickupID, headerID int) error {
+	return r.record(fmt.Sprintf("pickup %d -> deposit %d", pickupID, headerID))
+}
+
+func (r *fraudCaseRepo) UpdatePickupRequestStatus(pickupID, partnerID int, fromStatus, toStatus string) error {
+	return r.recordStatus(fmt.Sprintf("pickup %d: %s -> %s", pickupID, fromStatus, toStatus))
+}
+
+func (r *fraudCaseRepo) SetDepositDraftDepositHeader(draftID, headerID int) error {
+	return r.record(fmt.Sprintf("draft %d -> deposit %d", draftID, headerID))
+}
+
+func (r *fraudCaseRepo) UpdateDepositDraftStatus(draftID int, fromStatus, toStatus string) error {
+	return r.recordStatus(fmt.Sprintf("draft %d: %s -> %s", draftID, fromStatus, toStatus))
+}
+
+type fraudUserRepo struct {
+	UserRepositoryForPartner
+	createdAt time.Time
+}
+
+func (r *fraudUserRepo) FindByID(id int) (*user.User, error) {
+	return &user.User{ID: id, CreatedAt: r.createdAt}, nil
+}
+
+func TestScreenDepositBlocked(t *testing.T) {
+	rules := []fraud.Rule{{Code: fraud.RuleNewAccountCredit, Action: fraud.ActionReject, Threshold: 500, WindowHours: 72}}
+	args := ArgsDepositCreation{PartnerID: 3, UserID: 9, TotalXpoin: 800, TransactionTime: time.Now(),
+		SourceType: FraudCaseSourceDepositSession, SourceID: 21}
+
+	tests := []struct {
+		name      string
+		createErr error
+		wantErr   string
+	}{
+		{"blocked case recorded", nil, "deposit ditolak sistem anti-fraud: "},
+		{"recording failure is returned", errors.New("gagal menyimpan kasus fraud deposit"), "gagal menyimpan kasus fraud deposit"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fraudCaseRepo{rules: rules, createErr: tt.createErr}
+			s := &PartnerService{repo: repo, userRepo: &fraudUserRepo{createdAt: time.Now().Add(-time.Hour)}}
+
+			decision, hits, err := s.screenDeposit(args)
+			if decision != fraud.ActionReject || len(hits) != 1 {
+				t.Fatalf("screenDeposit = %q, %+v; want reject with one hit", decision, hits)
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Fatalf("screenDeposit error = %v, want prefix %q", err, tt.wantErr)
+			}
+			if len(repo.created) != 1 {
+				t.Fatalf("recorded %d fraud cases, want 1", len(repo.created))
+			}
+			fc := repo.created[0]
+			if fc.Status != FraudCaseBlocked || fc.SourceType.String != FraudCaseSourceDepositSession || fc.SourceID.Int32 != 21 {
+				t.Fatalf("recorded case %+v, want Blocked case from deposit session 21", fc)
+			}
+		})
+	}
+}
+
+func TestFraudCaseSource(t *testing.T) {
+	source := func(sourceType string, id int) *DepositFraudCase {
+		return &DepositFraudCase{ID: 5, PartnerID: 3, SourceType: sql.NullString{String: sourceType, Valid: true},
+			SourceID: sql.NullInt32{Int32: int32(id), Valid: true}}
+	}
+	tests := []struct {
+		name        string
+		fraudCase   *DepositFraudCase
+		wantApprove []string
+		wantDecline []string
+	}{
+		{"deposit session", source(FraudCaseSourceDepositSession, 21),
+			[]string{"session 21 -> deposit 77", "session 21: Held -> Confirmed"},
+			[]string{"session 21: Held -> Cancelled"}},
+		{"pickup request", source(FraudCaseSourcePickupRequest, 22),
+			[]string{"pickup 22 -> deposit 77", "pickup 22: Held -> Completed"},
+			[]string{"pickup 22: Held -> Cancelled"}},
+		{"deposit draft", source(FraudCaseSourceDepositDraft, 23),
+			[]string{"draft 23 -> deposit 77", "draft 23: Held -> Accepted"},
+			[]string{"draft 23: Held -> Failed"}},
+		{"direct deposit", &DepositFraudCase{ID: 5, PartnerID: 3}, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fraudCaseRepo{}
+			s := &PartnerService{repo: repo}
+			s.completeFraudCaseSource(tt.fraudCase, 77)
+			if !reflect.DeepEqual(repo.calls, tt.wantApprove) {
+				t.Fatalf("approve calls = %q, want %q", repo.calls, tt.wantApprove)
+			}
+
+			repo.calls = nil
+			s.cancelFraudCaseSource(tt.fraudCase)
+			if !reflect.DeepEqual(repo.calls, tt.wantDecline) {
+				t.Fatalf("decline calls = %q, want %q", repo.calls, tt.wantDecline)
+			}
+		})
+	}
+
+	t.Run("link kept when status already moved", func(t *testing.T) {
+		repo := &fraudCaseRepo{statusErr: sql.ErrNoRows}
+		(&PartnerService{repo: repo}).completeFraudCaseSource(source(FraudCaseSourceDepositSession, 21), 77)
+		if len(repo.calls) != 2 || repo.calls[0] != "session 21 -> deposit 77" {
+			t.Fatalf("calls = %q, want link before status update", repo.calls)
+		}
+	})
+}
@@ -2,11 +2,17 @@
 package user
 
 import (
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/text/cases"
@@ -14,6 +20,9 @@ import (
 	"xetor.id/backend/internal/auth"
 )
 
+// partnerProfileCacheMaxAge lama cache (detik) profil publik mitra
+const partnerProfileCacheMaxAge = 60
+
 type Handler struct {
 	service *Service
 }
@@ -164,6 +173,10 @@ func (h *Handler) AddUserAddress(c *gin.Context) {
 
 	address, err := h.service.AddUserAddress(userIDStr.(string), req)
 	if err != nil {
+		if strings.Contains(err.Error(), "harus diisi bersamaan") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal menyimpan alamat"})
 		return
 	}
@@ -222,7 +235,7 @@ func (h *Handler) UpdateUserAddress(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Alamat tidak ditemukan atau bukan milik Anda"})
 			return
 		}
-		if err.Error() == "tidak ada data untuk diupdate" {
+		if err.Error() == "tidak ada data untuk diupdate" || strings.Contains(err.Error(), "harus diisi bersamaan") {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
@@ -369,6 +382,76 @@ func (h *Handler) GetApprovedPartners(c *gin.Context) {
 	c.JSON(http.StatusOK, partners)
 }
 
+// GetNearbyPartners mencari mitra terdekat (public endpoint): ?lat=&lng=&radius=&open_now=true&waste_detail_id=
+func (h *Handler) GetNearbyPartners(c *gin.Context) {
+	var q NearbyPartnerQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "lat dan lng wajib diisi dengan angka yang valid"})
+		return
+	}
+
+	partners, err := h.service.GetNearbyPartners(q)
+	if err != nil {
+		if strings.Contains(err.Error(), "tidak valid") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mencari mitra terdekat"})
+		return
+	}
+	c.JSON(http.StatusOK, partners)
+}
+
+// GetPartnerProfile mengambil profil publik mitra (public endpoint). Respons bisa di-cache singkat oleh klien/CDN;
+// ETag memungkinkan klien memakai If-None-Match untuk mendapat 304.
+func (h *Handler) GetPartnerProfile(c *gin.Context) {
+	profile, err := h.service.GetPartnerProfile(c.Param("id"))
+	if err != nil {
+		if strings.Contains(err.Error(), "tidak valid") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else if strings.Contains(err.Error(), "tidak ditemukan") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil profil mitra"})
+		}
+		return
+	}
+
+	body, err := json.Marshal(profile)
+	if err != nil {
+		log.Printf("Error encoding partner profile %d: %v", profile.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil profil mitra"})
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+
+	// Status buka ikut di respons, jadi cache dibuat singkat
+	c.Header("Cache-Control", fmt.Sprintf("public, max-age=%d", partnerProfileCacheMaxAge))
+	c.Header("ETag", etag)
+	if match := c.GetHeader("If-None-Match"); match != "" && match == etag {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// GetPartnerSchedule mengambil jadwal publik mitra beserta is_open_now/next_open_at (public endpoint)
+func (h *Handler) GetPartnerSchedule(c *gin.Context) {
+	result, err := h.service.GetPartnerSchedule(c.Param("id"))
+	if err != nil {
+		if strings.Contains(err.Error(), "tidak valid") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		} else if strings.Contains(err.Error(), "tidak ditemukan") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil jadwal mitra"})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
 // --- User Withdraw Handler ---
 
 // RequestWithdrawal menangani request penarikan saldo
@@ -551,6 +634,557 @@ func (h *Handler) GenerateDepositQrToken(c *gin.Context) {
 	c.JSON(http.StatusOK, response)
 }
 
+// GenerateOfflineDepositQr menangani request pembuatan kode QR deposit offline
+func (h *Handler) GenerateOfflineDepositQr(c *gin.Context) {
+	userIDStr, exists := c.Get("entityID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Gagal mendapatkan ID pengguna dari token"})
+		return
+	}
+
+	response, err := h.service.GenerateOfflineDepositQr(userIDStr.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetActiveDepositQrTokens menangani request daftar token QR deposit yang masih aktif
+func (h *Handler) GetActiveDepositQrTokens(c *gin.Context) {
+	userIDStr, exists := c.Get("entityID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Gagal mendapatkan ID pengguna dari token"})
+		return
+	}
+
+	tokens, err := h.service.GetActiveDepositQrTokens(userIDStr.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, tokens)
+}
+
+// RevokeDepositQrToken menangani request pembatalan token QR deposit
+func (h *Handler) RevokeDepositQrToken(c *gin.Context) {
+	userIDStr, exists := c.Get("entityID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Gagal mendapatkan ID pengguna dari token"})
+		return
+	}
+
+	err := h.service.RevokeDepositQrToken(userIDStr.(string), c.Param("token"))
+	if err != nil {
+		if err.Error() == "token tidak ditemukan" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Token QR berhasil dibatalkan"})
+}
+
+// --- Deposit Session Handlers ---
+
+// OpenDepositSession menangani check-in user setelah memindai QR stasiun partner
+func (h *Handler) OpenDepositSession(c *gin.Context) {
+	userIDStr, exists := c.Get("entityID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Gagal mendapatkan ID pengguna dari token"})
+		return
+	}
+
+	var req OpenDepositSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "station_token tidak boleh kosong"})
+		return
+	}
+
+	session, err := h.service.OpenDepositSession(userIDStr.(string), req)
+	if err != nil {
+		errMsg := err.Error()
+		if strings.Contains(errMsg, "tidak ditemukan") || strings.Contains(errMsg, "kedaluwarsa") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errMsg})
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// GetDepositSessions menangani request daftar sesi deposit milik user
+func (h *Handler) GetDepositSessions(c *gin.Context) {
+	userIDStr, exists := c.Get("entityID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Gagal mendapatkan ID pengguna dari token"})
+		return
+	}
+
+	sessions, err := h.service.GetDepositSessions(userIDStr.(string))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil sesi deposit"})
+		return
+	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+// GetDepositSessionByID menangani request detail sesi deposit milik user
+func (h *Handler) GetDepositSessionByID(c *gin.Context) {
+	userIDStr, exists := c.Get("entityID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Gagal mendapatkan ID pengguna dari token"})
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID sesi tidak valid"})
+		return
+	}
+
+	session, err := h.service.GetDepositSessionByID(id, userIDStr.(string))
+	if err != nil {
+		if err.Error() == "sesi deposit tidak ditemukan" {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// CancelDepositSession menangani pembatalan sesi deposit oleh user
+func (h *Handler) CancelDepositSession(c *gin.Context) {
+	userIDStr, exists := c.Get("entityID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Gagal mendapatkan ID pengguna dari token"})
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID sesi tidak valid"})
+		return
+	}
+
+	if err := h.service.CancelDepositSession(id, userIDStr.(string)); err != nil {
+		if strings.Contains(err.Error(), "tidak ditemukan") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Sesi deposit berhasil dibatalkan"})
+}
+
+// --- Deposit Draft Handlers ---
+
+// GetDepositDrafts menangani request daftar draft deposit milik user (query opsional: ?status=Pending)
+func (h *Handler) GetDepositDrafts(c *gin.Context) {
+	userIDStr, exists := c.Get("entityID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Gagal mendapatkan ID pengguna dari token"})
+		return
+	}
+
+	drafts, err := h.service.GetDepositDrafts(userIDStr.(string), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil draft deposit"})
+		return
+	}
+	c.JSON(http.StatusOK, drafts)
+}
+
+// GetDepositDraftByID menangani request detail draft deposit
+func (h *Handler) GetDepositDraftByID(c *gin.Context) {
+	userIDStr, exists := c.Get("entityID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Gagal mendapatkan ID pengguna dari token"})
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID draft tidak valid"})
+		return
+	}
+
+	draft, err := h.service.GetDepositDraftByID(id, userIDStr.(string))
+	if err != nil {
+		respondDepositDraftError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, draft)
+}
+
+// AcceptDepositDraft menangani penerimaan draft deposit oleh user
+func (h *Handler) AcceptDepositDraft(c *gin.Context) {
+	userIDStr, exists := c.Get("entityID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Gagal mendapatkan ID pengguna dari token"})
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID draft tidak valid"})
+		return
+	}
+
+	draft, err := h.service.AcceptDepositDraft(id, userIDStr.(string))
+	if err != nil {
+		respondDepositDraftError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, draft)
+}
+
+// RejectDepositDraft menangani penolakan draft deposit oleh user
+func (h *Handler) RejectDepositDraft(c *gin.Context) {
+	userIDStr, exists := c.Get("entityID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Gagal mendapatkan ID pengguna dari token"})
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID draft tidak valid"})
+		return
+	}
+
+	var req RejectDepositDraftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Alasan penolakan wajib diisi"})
+		return
+	}
+
+	if err := h.service.RejectDepositDraft(id, userIDStr.(string), req); err != nil {
+		respondDepositDraftError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Draft deposit berhasil ditolak"})
+}
+
+// respondDepositDraftError memetakan error draft deposit ke status HTTP
+func respondDepositDraftError(c *gin.Context, err error) {
+	errMsg := err.Error()
+	switch {
+	case errMsg == "draft deposit tidak ditemukan":
+		c.JSON(http.StatusNotFound, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "sudah diproses") || strings.Contains(errMsg, "sudah lewat"):
+		c.JSON(http.StatusConflict, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "tidak mencukupi"):
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errMsg})
+	}
+}
+
+// --- Deposit Dispute Handlers ---
+
+// OpenDepositDispute menangani pengajuan sengketa deposit (multipart/form-data, foto di field "photos")
+func (h *Handler) OpenDepositDispute(c *gin.Context) {
+	userIDStr, exists := c.Get("entityID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Gagal mendapatkan ID pengguna dari token"})
+		return
+	}
+
+	var req OpenDepositDisputeRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID deposit dan alasan sengketa wajib diisi"})
+		return
+	}
+
+	var photos []*multipart.FileHeader
+	if form, err := c.MultipartForm(); err == nil && form.File != nil {
+		photos = form.File["photos"] // Foto opsional
+	}
+
+	dispute, err := h.service.OpenDepositDispute(userIDStr.(string), req, photos)
+	if err != nil {
+		respondDepositDisputeError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, dispute)
+}
+
+// GetDepositDisputes menangani request daftar sengketa deposit user
+func (h *Handler) GetDepositDisputes(c *gin.Context) {
+	userIDStr, exists := c.Get("entityID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Gagal mendapatkan ID pengguna dari token"})
+		return
+	}
+
+	disputes, err := h.service.GetDepositDisputes(userIDStr.(string), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil sengketa deposit"})
+		return
+	}
+	c.JSON(http.StatusOK, disputes)
+}
+
+// GetDepositDisputeByID menangani request detail sengketa deposit
+func (h *Handler) GetDepositDisputeByID(c *gin.Context) {
+	userIDStr, exists := c.Get("entityID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Gagal mendapatkan ID pengguna dari token"})
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID sengketa tidak valid"})
+		return
+	}
+
+	dispute, err := h.service.GetDepositDisputeByID(id, userIDStr.(string))
+	if err != nil {
+		respondDepositDisputeError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, dispute)
+}
+
+// respondDepositDisputeError memetakan error sengketa deposit ke status HTTP
+func respondDepositDisputeError(c *gin.Context, err error) {
+	errMsg := err.Error()
+	switch {
+	case strings.Contains(errMsg, "tidak ditemukan"):
+		c.JSON(http.StatusNotFound, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "sudah memiliki sengketa") || strings.Contains(errMsg, "sudah dibatalkan") ||
+		strings.Contains(errMsg, "sudah lewat"):
+		c.JSON(http.StatusConflict, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "tidak valid") || strings.Contains(errMsg, "maksimal"):
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errMsg})
+	}
+}
+
+// --- Partner Review Handlers ---
+
+// CreatePartnerReview menangani ulasan user atas partner untuk satu deposit (multipart, foto opsional di field "photo")
+func (h *Handler) CreatePartnerReview(c *gin.Context) {
+	userIDStr, exists := c.Get("entityID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Gagal mendapatkan ID pengguna dari token"})
+		return
+	}
+
+	var req CreatePartnerReviewRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID deposit dan rating (1-5) wajib diisi"})
+		return
+	}
+
+	photo, err := c.FormFile("photo")
+	if err != nil {
+		photo = nil // Foto opsional
+	}
+
+	review, err := h.service.CreatePartnerReview(userIDStr.(string), req, photo)
+	if err != nil {
+		respondPartnerReviewError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, review)
+}
+
+// GetMyPartnerReviews menangani request daftar ulasan yang ditulis user
+func (h *Handler) GetMyPartnerReviews(c *gin.Context) {
+	userIDStr, exists := c.Get("entityID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Gagal mendapatkan ID pengguna dari token"})
+		return
+	}
+
+	reviews, err := h.service.GetMyPartnerReviews(userIDStr.(string))
+	if err != nil {
+		respondPartnerReviewError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, reviews)
+}
+
+// GetPublicPartnerReviews daftar ulasan publik mitra (public endpoint): ?limit=&offset=
+func (h *Handler) GetPublicPartnerReviews(c *gin.Context) {
+	limit, offset := 0, 0
+	var err error
+	if v := c.Query("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit tidak valid"})
+			return
+		}
+	}
+	if v := c.Query("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "offset tidak valid"})
+			return
+		}
+	}
+
+	reviews, err := h.service.GetPublicPartnerReviews(c.Param("id"), limit, offset)
+	if err != nil {
+		respondPartnerReviewError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, reviews)
+}
+
+func respondPartnerReviewError(c *gin.Context, err error) {
+	errMsg := err.Error()
+	switch {
+	case strings.Contains(errMsg, "tidak ditemukan"):
+		c.JSON(http.StatusNotFound, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "sudah ada") || strings.Contains(errMsg, "sudah dibatalkan") ||
+		strings.Contains(errMsg, "sudah lewat"):
+		c.JSON(http.StatusConflict, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "tidak valid") || strings.Contains(errMsg, "terlalu panjang"):
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errMsg})
+	}
+}
+
+// --- Pickup Request Handlers ---
+
+// CreatePickupRequest menangani pembuatan permintaan jemput sampah
+func (h *Handler) CreatePickupRequest(c *gin.Context) {
+	userIDStr, exists := c.Get("entityID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Gagal mendapatkan ID pengguna dari token"})
+		return
+	}
+
+	var req CreatePickupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "address_id, items (nama & estimasi berat > 0), slot_start dan slot_end (RFC3339) wajib diisi"})
+		return
+	}
+
+	pickup, err := h.service.CreatePickupRequest(userIDStr.(string), req)
+	if err != nil {
+		respondPickupRequestError(c, err)
+		return
+	}
+	c.JSON(http.StatusCreated, pickup)
+}
+
+// GetPickupRequests menangani request daftar permintaan jemput user
+func (h *Handler) GetPickupRequests(c *gin.Context) {
+	userIDStr, exists := c.Get("entityID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Gagal mendapatkan ID pengguna dari token"})
+		return
+	}
+
+	pickups, err := h.service.GetPickupRequests(userIDStr.(string), c.Query("status"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Gagal mengambil permintaan jemput"})
+		return
+	}
+	c.JSON(http.StatusOK, pickups)
+}
+
+// GetEligiblePickupPartners menangani request daftar partner yang dapat menjemput dari alamat user
+func (h *Handler) GetEligiblePickupPartners(c *gin.Context) {
+	userIDStr, exists := c.Get("entityID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Gagal mendapatkan ID pengguna dari token"})
+		return
+	}
+	addressID, err := strconv.Atoi(c.Query("address_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "address_id tidak valid"})
+		return
+	}
+	slotDate := time.Now()
+	if dateStr := c.Query("date"); dateStr != "" {
+		slotDate, err = time.Parse("2006-01-02", dateStr)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Format date tidak valid, gunakan YYYY-MM-DD"})
+			return
+		}
+	}
+	estimatedWeight := 0.0
+	if weightStr := c.Query("estimated_weight"); weightStr != "" {
+		estimatedWeight, err = strconv.ParseFloat(weightStr, 64)
+		if err != nil || estimatedWeight < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "estimated_weight tidak valid"})
+			return
+		}
+	}
+
+	partners, err := h.service.GetEligiblePickupPartners(userIDStr.(string), addressID, slotDate, estimatedWeight)
+	if err != nil {
+		respondPickupRequestError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, partners)
+}
+
+// GetPickupRequestByID menangani request detail permintaan jemput
+func (h *Handler) GetPickupRequestByID(c *gin.Context) {
+	userIDStr, exists := c.Get("entityID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Gagal mendapatkan ID pengguna dari token"})
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID permintaan jemput tidak valid"})
+		return
+	}
+
+	pickup, err := h.service.GetPickupRequestByID(id, userIDStr.(string))
+	if err != nil {
+		respondPickupRequestError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, pickup)
+}
+
+// CancelPickupRequest menangani pembatalan permintaan jemput oleh user
+func (h *Handler) CancelPickupRequest(c *gin.Context) {
+	userIDStr, exists := c.Get("entityID")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Gagal mendapatkan ID pengguna dari token"})
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID permintaan jemput tidak valid"})
+		return
+	}
+
+	var req CancelPickupRequest
+	_ = c.ShouldBindJSON(&req) // Alasan opsional
+
+	if err := h.service.CancelPickupRequest(id, userIDStr.(string), req); err != nil {
+		respondPickupRequestError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Permintaan jemput berhasil dibatalkan"})
+}
+
+// respondPickupRequestError memetakan error permintaan jemput ke status HTTP
+func respondPickupRequestError(c *gin.Context, err error) {
+	errMsg := err.Error()
+	switch {
+	case strings.Contains(errMsg, "tidak ditemukan"):
+		c.JSON(http.StatusNotFound, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "tidak dapat dibatalkan"):
+		c.JSON(http.StatusConflict, gin.H{"error": errMsg})
+	case strings.Contains(errMsg, "tidak valid") || strings.Contains(errMsg, "titik lokasi") ||
+		strings.Contains(errMsg, "slot") || strings.Contains(errMsg, "belum ada partner jemput"):
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errMsg})
+	}
+}
+
 // --- User Profile Update Handlers ---
 
 // UpdateProfile menangani request update profil user
@@ -650,6 +1284,30 @@ func (h *Handler) GoogleAuth(c *gin.Context) {
 	})
 }
 
+// GetWastePriceTrend tren harga harian satu jenis sampah di seluruh mitra (?from=&to=, YYYY-MM-DD)
+func (h *Handler) GetWastePriceTrend(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ID tidak valid"})
+		return
+	}
+
+	trend, err := h.service.GetWastePriceTrend(id, c.Query("from"), c.Query("to"))
+	if err != nil {
+		errMsg := err.Error()
+		switch {
+		case strings.Contains(errMsg, "tidak ditemukan"):
+			c.JSON(http.StatusNotFound, gin.H{"error": errMsg})
+		case strings.Contains(errMsg, "tidak valid"):
+			c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": errMsg})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, trend)
+}
+
 // GetWasteDetailByID handler untuk mengambil waste detail berdasarkan ID
 func (h *Handler) GetWasteDetailByID(c *gin.Context) {
 	idStr := c.Param("id")
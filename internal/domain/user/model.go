@@ -3,6 +3,9 @@ package user
 import (
 	"database/sql"
 	"time"
+
+	"xetor.id/backend/internal/geo"
+	"xetor.id/backend/internal/schedule"
 )
 
 // User adalah representasi data user di dalam database
@@ -42,6 +45,8 @@ type UserAddress struct {
 	CityRegency string         `json:"city_regency"`
 	Province    string         `json:"province"`
 	PostalCode  sql.NullString `json:"postal_code,omitempty"`
+	Latitude    sql.NullFloat64 `json:"latitude,omitempty"`  // Dibutuhkan untuk permintaan jemput
+	Longitude   sql.NullFloat64 `json:"longitude,omitempty"`
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 }
@@ -54,6 +59,8 @@ type CreateUserAddressRequest struct {
 	CityRegency string `json:"city_regency" binding:"required"`
 	Province    string `json:"province" binding:"required"`
 	PostalCode  string `json:"postal_code"` // Opsional
+	Latitude    *float64 `json:"latitude"`  // Opsional, pointer agar bisa null
+	Longitude   *float64 `json:"longitude"` // Opsional, pointer agar bisa null
 }
 
 // UpdateUserAddressRequest data untuk mengupdate alamat
@@ -64,6 +71,8 @@ type UpdateUserAddressRequest struct {
 	CityRegency string `json:"city_regency"`
 	Province    string `json:"province"`
 	PostalCode  string `json:"postal_code"`
+	Latitude    *float64 `json:"latitude"`
+	Longitude   *float64 `json:"longitude"`
 }
 
 // PartnerInfo adalah informasi partner untuk deposit history (optional, hanya untuk type="deposit")
@@ -76,7 +85,7 @@ type PartnerInfo struct {
 // TransactionHistoryItem adalah format standar untuk riwayat transaksi gabungan
 type TransactionHistoryItem struct {
 	ID             string         `json:"id"`               // ID unik (misal: "deposit-1", "withdraw-5")
-	Type           string         `json:"type"`             // 'deposit', 'withdraw', 'topup', 'transfer', 'convert'
+	Type           string         `json:"type"`             // 'deposit', 'withdraw', 'topup', 'transfer', 'convert', 'refund'
 	Amount         sql.NullString `json:"amount,omitempty"` // Jumlah (Rp) untuk withdraw, topup, transfer
 	Points         sql.NullInt32  `json:"points,omitempty"` // Jumlah poin untuk deposit
 	Status         string         `json:"status"`
@@ -160,6 +169,305 @@ type GenerateQrTokenResponse struct {
 	ExpiresAt time.Time `json:"expires_at"` // Waktu kedaluwarsa dalam format timestamp
 }
 
+// OfflineQrResponse data respons untuk kode QR deposit offline bertanda tangan
+type OfflineQrResponse struct {
+	Code      string    `json:"code"`   // Isi kode QR (format XQ1.<key_id>.<payload>.<signature>)
+	KeyID     string    `json:"key_id"` // ID kunci yang menandatangani
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// QrTokenInfo data token QR deposit yang masih aktif milik user
+type QrTokenInfo struct {
+	Token              string     `json:"token"`
+	CreatedAt          time.Time  `json:"created_at"`
+	ExpiresAt          time.Time  `json:"expires_at"`
+	Claimed            bool       `json:"claimed"`                         // true jika sudah discan oleh mitra
+	ClaimedByPartnerID int        `json:"claimed_by_partner_id,omitempty"` // ID mitra yang memindai token
+	ClaimedAt          *time.Time `json:"claimed_at,omitempty"`
+}
+
+// Status sesi deposit mandiri (user memindai QR stasiun partner)
+const (
+	DepositSessionOpen       = "Open"        // User sudah check-in, menunggu partner menimbang
+	DepositSessionItemsAdded = "Items Added" // Partner sudah mengisi item hasil timbang
+	DepositSessionConfirmed  = "Confirmed"   // Deposit sudah dibuat dan Xpoin dikirim
+	DepositSessionHeld       = "Held"        // Deposit ditahan anti-fraud, menunggu review admin
+	DepositSessionCancelled  = "Cancelled"
+)
+
+// DepositSession sesi deposit yang dibuka user dengan memindai QR stasiun partner
+type DepositSession struct {
+	ID              int                  `json:"id"`
+	PartnerID       int                  `json:"partner_id"`
+	PartnerName     sql.NullString       `json:"partner_name,omitempty"` // business_name partner
+	UserID          int                  `json:"user_id"`
+	UserName        sql.NullString       `json:"user_name,omitempty"`
+	Status          string               `json:"status"`
+	Items           []DepositSessionItem `json:"items"`
+	TotalWeight     string               `json:"total_weight"` // Berat total (kg), sbg string
+	EstimatedXpoin  int                  `json:"estimated_xpoin"`
+	DepositHeaderID sql.NullInt32        `json:"deposit_header_id,omitempty"` // Terisi setelah Confirmed
+	CancelledBy     sql.NullString       `json:"cancelled_by,omitempty"`      // "user" atau "partner"
+	CreatedAt       time.Time            `json:"created_at"`
+	UpdatedAt       time.Time            `json:"updated_at"`
+}
+
+// DepositSessionItem satu item sampah hasil timbang dalam sesi deposit
+type DepositSessionItem struct {
+	PartnerWastePriceDetailID int     `json:"partner_waste_price_detail_id"`
+	Name                      string  `json:"name"`
+	Weight                    float64 `json:"weight"` // Berat dalam KG (pcs: hasil konversi)
+	Unit                      string  `json:"unit,omitempty"`
+	Quantity                  int     `json:"quantity,omitempty"` // Jumlah buah untuk satuan pcs
+	EstimatedXpoin            int     `json:"estimated_xpoin"`
+}
+
+// OpenDepositSessionRequest data yang dikirim user setelah memindai QR stasiun partner
+type OpenDepositSessionRequest struct {
+	StationToken string `json:"station_token" binding:"required"`
+}
+
+// Status draft deposit dua tahap (user mengonfirmasi hasil timbang partner)
+const (
+	DepositDraftPending  = "Pending"  // Menunggu jawaban user
+	DepositDraftAccepted = "Accepted" // Diterima user atau otomatis setelah batas waktu
+	DepositDraftHeld     = "Held"     // Diterima tapi ditahan anti-fraud, menunggu review admin
+	DepositDraftRejected = "Rejected"
+	DepositDraftFailed   = "Failed" // Deposit gagal dijalankan berulang kali, tidak dicoba lagi
+)
+
+// DepositDraft deposit yang diajukan partner dan baru diproses setelah user menerimanya
+type DepositDraft struct {
+	ID              int                `json:"id"`
+	PartnerID       int                `json:"partner_id"`
+	PartnerName     sql.NullString     `json:"partner_name,omitempty"`
+	UserID          int                `json:"user_id"`
+	UserName        sql.NullString     `json:"user_name,omitempty"`
+	DepositMethodID int                `json:"deposit_method_id"`
+	Status          string             `json:"status"`
+	Items           []DepositDraftItem `json:"items"`
+	TotalWeight     string             `json:"total_weight"` // Berat total (kg), sbg string
+	TotalXpoin      int                `json:"total_xpoin"`
+	Notes           sql.NullString     `json:"notes,omitempty"`
+	Photo           sql.NullString     `json:"photo,omitempty"`
+	Photos          []DepositPhoto     `json:"photos"` // Foto level deposit (foto per item ada di Items)
+	ExpiresAt       time.Time          `json:"expires_at"`           // Lewat dari ini draft otomatis diterima
+	DecidedBy       sql.NullString     `json:"decided_by,omitempty"` // "user" atau "auto"
+	DecidedAt       *time.Time         `json:"decided_at,omitempty"`
+	RejectReason    sql.NullString     `json:"reject_reason,omitempty"`
+	DepositHeaderID sql.NullInt32      `json:"deposit_header_id,omitempty"` // Terisi setelah Accepted
+	StaffID         sql.NullInt64      `json:"staff_id,omitempty"`          // Staf partner yang menimbang
+	Attempts        int                `json:"attempts"`                    // Percobaan menjalankan deposit yang gagal
+	CreatedAt       time.Time          `json:"created_at"`
+	UpdatedAt       time.Time          `json:"updated_at"`
+}
+
+// DepositPhoto foto bukti deposit beserta thumbnail. Tanpa DetailID berarti foto level deposit.
+type DepositPhoto struct {
+	ID               int           `json:"id,omitempty"`
+	DetailID         sql.NullInt32 `json:"detail_id,omitempty"`
+	DepositDisputeID sql.NullInt32 `json:"deposit_dispute_id,omitempty"` // Bukti tambahan partner saat sengketa
+	URL              string        `json:"url"`
+	ThumbnailURL     string        `json:"thumbnail_url,omitempty"`
+}
+
+// DepositDraftItem satu item sampah dalam draft deposit (Xpoin sudah dihitung saat draft dibuat)
+type DepositDraftItem struct {
+	PartnerWastePriceDetailID int     `json:"partner_waste_price_detail_id"`
+	Name                      string  `json:"name"`
+	Weight                    float64 `json:"weight"` // Berat dalam KG (pcs: hasil konversi)
+	Unit                      string  `json:"unit,omitempty"`
+	Quantity                  int     `json:"quantity,omitempty"` // Jumlah buah untuk satuan pcs
+	ScaleReadingID            int     `json:"scale_reading_id,omitempty"`
+	Xpoin                     int     `json:"xpoin"`
+	WasteDetailID             int     `json:"waste_detail_id,omitempty"` // 0 jika tidak terhubung ke waste_details
+	PriceVersionID            int     `json:"price_version_id,omitempty"` // Versi harga saat draft dibuat
+	Photos                    []DepositPhoto `json:"photos,omitempty"`
+}
+
+// RejectDepositDraftRequest alasan user menolak draft deposit
+type RejectDepositDraftRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// Status sengketa deposit
+const (
+	DepositDisputeOpen      = "Open"              // Menunggu tanggapan partner
+	DepositDisputeResponded = "Partner Responded" // Menunggu keputusan admin
+	DepositDisputeEscalated = "Escalated"         // Partner tidak menanggapi sampai batas waktu, menunggu admin
+	DepositDisputeResolved  = "Resolved"          // Keberatan user diterima admin
+	DepositDisputeRejected  = "Rejected"          // Keberatan user ditolak admin
+)
+
+// DepositDispute keberatan user atas deposit yang sudah tercatat
+type DepositDispute struct {
+	ID                      int            `json:"id"`
+	PartnerDepositHistoryID int            `json:"partner_deposit_history_id"`
+	UserDepositHistoryID    int            `json:"user_deposit_history_id"` // ID yang tampil di riwayat transaksi user
+	UserID                  int            `json:"user_id"`
+	UserName                sql.NullString `json:"user_name,omitempty"`
+	PartnerID               int            `json:"partner_id"`
+	PartnerName             sql.NullString `json:"partner_name,omitempty"`
+	Status                  string         `json:"status"`
+	Reason                  string         `json:"reason"`
+	Photos                  []string       `json:"photos"`
+	DepositPhotos           []DepositPhoto `json:"deposit_photos,omitempty"` // Foto bukti deposit (hanya di detail)
+	ResponseDeadline        time.Time      `json:"response_deadline"` // Batas waktu tanggapan partner
+	PartnerResponse         sql.NullString `json:"partner_response,omitempty"`
+	PartnerRespondedAt      *time.Time     `json:"partner_responded_at,omitempty"`
+	ResolutionNote          sql.NullString `json:"resolution_note,omitempty"`
+	AdjustmentType          sql.NullString `json:"adjustment_type,omitempty"` // "void" atau "correction"
+	ResolvedAt              *time.Time     `json:"resolved_at,omitempty"`
+	CreatedAt               time.Time      `json:"created_at"`
+	UpdatedAt               time.Time      `json:"updated_at"`
+}
+
+// DisputableDeposit data deposit yang dibutuhkan saat user membuka sengketa
+type DisputableDeposit struct {
+	PartnerDepositHistoryID int
+	PartnerID               int
+	TransactionTime         time.Time
+	Voided                  bool
+}
+
+// OpenDepositDisputeRequest data pengajuan sengketa via multipart/form-data (foto di field "photos")
+type OpenDepositDisputeRequest struct {
+	DepositID int    `form:"deposit_id" binding:"required"` // ID deposit dari riwayat transaksi user
+	Reason    string `form:"reason" binding:"required"`
+}
+
+// Status ulasan partner
+const (
+	PartnerReviewPublished = "Published" // Tampil publik & dihitung dalam rating
+	PartnerReviewHidden    = "Hidden"    // Disembunyikan admin, tidak tampil publik & tidak dihitung
+)
+
+// PartnerReview ulasan user atas partner untuk satu deposit
+type PartnerReview struct {
+	ID                      int            `json:"id"`
+	UserDepositHistoryID    int            `json:"user_deposit_history_id"` // ID deposit di riwayat transaksi user
+	PartnerDepositHistoryID int            `json:"partner_deposit_history_id"`
+	UserID                  int            `json:"user_id"`
+	UserName                sql.NullString `json:"user_name,omitempty"`
+	PartnerID               int            `json:"partner_id"`
+	PartnerName             sql.NullString `json:"partner_name,omitempty"`
+	Rating                  int            `json:"rating"` // 1-5
+	Comment                 sql.NullString `json:"comment,omitempty"`
+	Photo                   sql.NullString `json:"photo,omitempty"`
+	Status                  string         `json:"status"`
+	PartnerReply            sql.NullString `json:"partner_reply,omitempty"`
+	PartnerRepliedAt        *time.Time     `json:"partner_replied_at,omitempty"`
+	ModerationNote          sql.NullString `json:"moderation_note,omitempty"`
+	ModeratedAt             *time.Time     `json:"moderated_at,omitempty"`
+	CreatedAt               time.Time      `json:"created_at"`
+	UpdatedAt               time.Time      `json:"updated_at"`
+}
+
+// CreatePartnerReviewRequest data ulasan via multipart/form-data (foto opsional di field "photo")
+type CreatePartnerReviewRequest struct {
+	DepositID int    `form:"deposit_id" binding:"required"` // ID deposit dari riwayat transaksi user
+	Rating    int    `form:"rating" binding:"required,min=1,max=5"`
+	Comment   string `form:"comment"`
+}
+
+// PublicPartnerReview ulasan yang tampil publik; nama user disamarkan
+type PublicPartnerReview struct {
+	ID               int        `json:"id"`
+	UserName         string     `json:"user_name"`
+	Rating           int        `json:"rating"`
+	Comment          string     `json:"comment,omitempty"`
+	Photo            string     `json:"photo,omitempty"`
+	PartnerReply     string     `json:"partner_reply,omitempty"`
+	PartnerRepliedAt *time.Time `json:"partner_replied_at,omitempty"`
+	CreatedAt        time.Time  `json:"created_at"`
+}
+
+// PartnerRatingSummary agregat rating ulasan yang tampil publik
+type PartnerRatingSummary struct {
+	AverageRating *float64 `json:"average_rating"` // null jika belum ada ulasan
+	ReviewCount   int      `json:"review_count"`
+}
+
+// Status permintaan jemput sampah
+const (
+	PickupRequested = "Requested"  // Menunggu diterima partner terdekat
+	PickupScheduled = "Scheduled"  // Diterima partner, menunggu slot
+	PickupOnTheWay  = "On The Way" // Partner menuju alamat user
+	PickupCollected = "Collected"  // Sampah sudah diambil, menunggu ditimbang
+	PickupCompleted = "Completed"  // Sudah dicatat sebagai deposit
+	PickupHeld      = "Held"       // Deposit ditahan anti-fraud, menunggu review admin
+	PickupCancelled = "Cancelled"
+)
+
+// PickupRequest permintaan jemput sampah dari alamat user
+type PickupRequest struct {
+	ID              int                 `json:"id"`
+	UserID          int                 `json:"user_id"`
+	UserName        sql.NullString      `json:"user_name,omitempty"`
+	UserAddressID   sql.NullInt32       `json:"user_address_id,omitempty"`
+	PartnerID       sql.NullInt32       `json:"partner_id,omitempty"`
+	PartnerName     sql.NullString      `json:"partner_name,omitempty"`
+	Status          string              `json:"status"`
+	ContactName     string              `json:"contact_name"`
+	ContactPhone    string              `json:"contact_phone"`
+	Address         string              `json:"address"`
+	Latitude        float64             `json:"latitude"`
+	Longitude       float64             `json:"longitude"`
+	DistanceKm      sql.NullFloat64     `json:"distance_km,omitempty"` // Hanya di daftar permintaan untuk partner
+	Items           []PickupRequestItem `json:"items"`
+	EstimatedWeight string              `json:"estimated_weight"` // Estimasi berat total (kg), sbg string
+	SlotStart       time.Time           `json:"slot_start"`
+	SlotEnd         time.Time           `json:"slot_end"`
+	Notes           sql.NullString      `json:"notes,omitempty"`
+	CancelledBy     sql.NullString      `json:"cancelled_by,omitempty"`
+	CancelReason    sql.NullString      `json:"cancel_reason,omitempty"`
+	DepositHeaderID sql.NullInt32       `json:"deposit_header_id,omitempty"` // Terisi setelah Completed
+	ScheduledAt     *time.Time          `json:"scheduled_at,omitempty"`
+	OnTheWayAt      *time.Time          `json:"on_the_way_at,omitempty"`
+	CollectedAt     *time.Time          `json:"collected_at,omitempty"`
+	CompletedAt     *time.Time          `json:"completed_at,omitempty"`
+	CreatedAt       time.Time           `json:"created_at"`
+	UpdatedAt       time.Time           `json:"updated_at"`
+}
+
+// PickupRequestItem estimasi satu jenis sampah yang akan dijemput
+type PickupRequestItem struct {
+	WasteDetailID   int     `json:"waste_detail_id,omitempty"` // Opsional, ID dari waste_details
+	Name            string  `json:"name" binding:"required"`
+	EstimatedWeight float64 `json:"estimated_weight" binding:"required,gt=0"` // Estimasi berat (kg)
+}
+
+// CreatePickupRequest data permintaan jemput dari user
+type CreatePickupRequest struct {
+	AddressID int                 `json:"address_id" binding:"required"`
+	Items     []PickupRequestItem `json:"items" binding:"required,min=1,dive"`
+	SlotStart time.Time           `json:"slot_start" binding:"required"` // RFC3339
+	SlotEnd   time.Time           `json:"slot_end" binding:"required"`
+	Notes     string              `json:"notes"`
+}
+
+// CancelPickupRequest alasan user membatalkan permintaan jemput
+type CancelPickupRequest struct {
+	Reason string `json:"reason"`
+}
+
+// EligiblePickupPartner partner jemput yang melayani alamat user pada tanggal tertentu
+type EligiblePickupPartner struct {
+	PartnerID           int         `json:"partner_id"`
+	BusinessName        string      `json:"business_name"`
+	Address             string      `json:"address"`
+	CityRegency         string      `json:"city_regency"`
+	DistanceKm          float64     `json:"distance_km"`
+	MinPickupWeight     string      `json:"min_pickup_weight"` // Estimasi berat minimal (kg), sbg string
+	DailyPickupCapacity int         `json:"daily_pickup_capacity"`   // 0 = tidak dibatasi
+	RemainingCapacity   *int        `json:"remaining_capacity"`      // nil jika tidak dibatasi
+	Latitude            float64     `json:"-"`
+	Longitude           float64     `json:"-"`
+	ServiceRadiusKm     float64     `json:"-"`
+	ServiceAreaPolygon  []geo.Point `json:"-"`
+	BookedCount         int         `json:"-"` // Jemput terjadwal pada tanggal yang diminta
+}
+
 // UpdateUserProfileRequest data untuk update profil user
 type UpdateUserProfileRequest struct {
 	Fullname string `json:"fullname"`
@@ -216,6 +524,120 @@ type PublicPartnerResponse struct {
 	PostalCode   sql.NullString `json:"postal_code,omitempty"`
 	Latitude     sql.NullFloat64 `json:"latitude,omitempty"`
 	Longitude    sql.NullFloat64 `json:"longitude,omitempty"`
+	IsOpenNow    bool            `json:"is_open_now"`
+	NextOpenAt   *time.Time      `json:"next_open_at,omitempty"`
+	PartnerRatingSummary
+}
+
+// NearbyPartnerQuery parameter pencarian mitra terdekat (GET /public/partners/nearby)
+type NearbyPartnerQuery struct {
+	Latitude      *float64 `form:"lat" binding:"required"`
+	Longitude     *float64 `form:"lng" binding:"required"`
+	RadiusKm      float64  `form:"radius"`          // Default 10 km, maksimal 50 km
+	OpenNow       bool     `form:"open_now"`        // Hanya mitra yang sedang buka
+	WasteDetailID int      `form:"waste_detail_id"` // Hanya mitra yang menerima jenis sampah ini
+}
+
+// NearbyPartner mitra hasil pencarian terdekat beserta jarak, status buka, dan harga saat ini
+type NearbyPartner struct {
+	ID              int                `json:"id"` // Partner ID
+	BusinessName    string             `json:"business_name"`
+	Photo           sql.NullString     `json:"photo,omitempty"`
+	Address         string             `json:"address"`
+	CityRegency     string             `json:"city_regency"`
+	Province        string             `json:"province"`
+	Latitude        float64            `json:"latitude"`
+	Longitude       float64            `json:"longitude"`
+	DistanceKm      float64            `json:"distance_km"`
+	IsOpenNow       bool               `json:"is_open_now"`
+	NextOpenAt      *time.Time         `json:"next_open_at,omitempty"`
+	Timezone        string             `json:"timezone,omitempty"`
+	Prices          []PublicWastePrice `json:"prices"`
+}
+
+// PublicPartnerSchedule jadwal mitra untuk publik (GET /public/partners/:id/schedule)
+type PublicPartnerSchedule struct {
+	PartnerID       int                  `json:"partner_id"`
+	OperatingStatus string               `json:"operating_status"`
+	Timezone        string               `json:"timezone"`
+	Intervals       []schedule.Interval  `json:"intervals"`
+	Exceptions      []schedule.Exception `json:"exceptions"` // Pengecualian dalam schedule.LookaheadDays hari ke depan
+	Holidays        []PublicHoliday      `json:"holidays"`   // Libur nasional (tutup) dalam schedule.LookaheadDays hari ke depan
+	IsOpenNow       bool                 `json:"is_open_now"`
+	NextOpenAt      *time.Time           `json:"next_open_at,omitempty"`
+}
+
+// PublicHoliday libur nasional yang diikuti mitra
+type PublicHoliday struct {
+	Date string `json:"date"` // "YYYY-MM-DD"
+	Name string `json:"name"`
+}
+
+// PublicPartnerProfile profil publik mitra (GET /public/partners/:id)
+type PublicPartnerProfile struct {
+	ID            int                    `json:"id"` // Partner ID
+	BusinessName  string                 `json:"business_name"`
+	Photo         sql.NullString         `json:"photo,omitempty"`
+	MemberSince   time.Time              `json:"member_since"`
+	Address       *PublicPartnerAddress  `json:"address"` // null jika mitra belum mengisi alamat
+	Schedule      *PublicPartnerSchedule `json:"schedule"`
+	Prices        []PublicWastePrice     `json:"prices"`
+	Stats         PublicPartnerStats     `json:"stats"`
+	PartnerRatingSummary
+	RecentReviews []PublicPartnerReview `json:"recent_reviews"`
+}
+
+// PublicPartnerAddress alamat mitra yang ditampilkan publik
+type PublicPartnerAddress struct {
+	Address       string          `json:"address"`
+	CityRegency   string          `json:"city_regency"`
+	Province      string          `json:"province"`
+	PostalCode    sql.NullString  `json:"postal_code,omitempty"`
+	Latitude      sql.NullFloat64 `json:"latitude,omitempty"`
+	Longitude     sql.NullFloat64 `json:"longitude,omitempty"`
+	PickupEnabled bool            `json:"pickup_enabled"`
+}
+
+// PublicPartnerStats statistik agregat deposit mitra (deposit yang di-void tidak dihitung)
+type PublicPartnerStats struct {
+	TotalDeposits  int        `json:"total_deposits"`
+	TotalWeight    string     `json:"total_weight"` // kg, sbg string
+	TotalCustomers int        `json:"total_customers"`
+	LastDepositAt  *time.Time `json:"last_deposit_at,omitempty"`
+}
+
+// PublicWastePrice harga sampah mitra yang ditampilkan publik
+type PublicWastePrice struct {
+	ID            int            `json:"id"` // ID partner_waste_price_details
+	WasteDetailID sql.NullInt32  `json:"waste_detail_id,omitempty"`
+	Name          string         `json:"name"`
+	Image         sql.NullString `json:"image,omitempty"`
+	Price         string         `json:"price"` // Rp, sbg string
+	Unit          string         `json:"unit"`
+	Xpoin         int            `json:"xpoin"`
+	// Harga terjadwal terdekat yang sudah diumumkan mitra
+	NextPrice       string     `json:"next_price,omitempty"`
+	NextUnit        string     `json:"next_unit,omitempty"`
+	NextEffectiveAt *time.Time `json:"next_effective_at,omitempty"`
+}
+
+// WastePriceTrend tren harga harian satu jenis sampah di seluruh mitra, dipisah per satuan
+type WastePriceTrend struct {
+	WasteDetailID int                    `json:"waste_detail_id"`
+	Name          string                 `json:"name"`
+	From          string                 `json:"from"` // "YYYY-MM-DD"
+	To            string                 `json:"to"`
+	Points        []WastePriceTrendPoint `json:"points"`
+}
+
+// WastePriceTrendPoint harga yang berlaku di akhir satu hari
+type WastePriceTrendPoint struct {
+	Date         string  `json:"date"` // "YYYY-MM-DD"
+	Unit         string  `json:"unit"`
+	AveragePrice float64 `json:"average_price"`
+	MinPrice     float64 `json:"min_price"`
+	MaxPrice     float64 `json:"max_price"`
+	PartnerCount int     `json:"partner_count"`
 }
 
 // WasteDetailResponse untuk response endpoint /user/waste-details/:id
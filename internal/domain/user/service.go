@@ -22,7 +22,10 @@ import (
 	"xetor.id/backend/internal/auth"
 	"xetor.id/backend/internal/config"
 	"xetor.id/backend/internal/domain/admin"
+	"xetor.id/backend/internal/geo"
 	"xetor.id/backend/internal/notification"
+	"xetor.id/backend/internal/offline_qr"
+	"xetor.id/backend/internal/schedule"
 	"xetor.id/backend/internal/temporary_token"
 )
 
@@ -30,6 +33,26 @@ const (
 	minWithdrawalAmount = 10000.0 // Minimal penarikan Rp 10.000
 	withdrawalFee       = 2500.0  // Biaya admin Rp 2.500
 	minTopupAmount      = 10000.0 // Minimal topup Rp 10.000
+
+	offlineQrValidity = 24 * time.Hour // Masa berlaku kode QR offline (token online hanya 5 menit)
+
+	depositDisputeOpenWindow     = 14 * 24 * time.Hour // Sengketa hanya bisa diajukan 14 hari sejak deposit
+	depositDisputeResponseWindow = 72 * time.Hour      // Batas tanggapan partner sebelum dieskalasi ke admin
+	maxDepositDisputePhotos      = 5
+	partnerReviewWindow          = 30 * 24 * time.Hour // Ulasan hanya bisa ditulis 30 hari sejak deposit
+	maxPartnerReviewComment      = 1000
+	partnerProfileRecentReviews  = 5
+	publicReviewsDefaultLimit    = 20
+	publicReviewsMaxLimit        = 100
+
+	pickupMinLeadTime   = 1 * time.Hour       // Slot jemput paling cepat 1 jam dari sekarang
+	pickupMaxAdvance    = 14 * 24 * time.Hour // Slot jemput paling lambat 14 hari ke depan
+	pickupMaxSlotLength = 4 * time.Hour       // Rentang slot jemput maksimal
+
+	nearbyPartnerDefaultRadiusKm = 10.0 // Radius default pencarian mitra terdekat
+	nearbyPartnerMaxRadiusKm     = 50.0
+	nearbyPartnerLimit           = 50  // Jumlah maksimal mitra yang dikembalikan
+	nearbyPartnerOpenNowPage     = 200 // Kandidat per halaman saat filter open_now (status buka dihitung di Go)
 )
 
 const conversionRateXpToRp = 5.0 // 1 Xp = 5 Rp
@@ -40,6 +63,12 @@ type MidtransServiceInterface interface {
 	CreateSnapTransactionFromMap(reqMap map[string]interface{}) (map[string]interface{}, error)
 }
 
+// DepositDraftServiceInterface dipakai untuk menerima draft deposit dua tahap.
+// Diimplementasikan oleh PartnerService karena penerimaan draft memindahkan Xpoin partner.
+type DepositDraftServiceInterface interface {
+	AcceptDepositDraft(draftID int, userID int) (*DepositDraft, error)
+}
+
 type Repository interface {
 	// User-related methods
 	CreateUserFromGoogle(u *User) error
@@ -67,6 +96,7 @@ type Repository interface {
 	GetTopupHistoryForUser(userID int) ([]TransactionHistoryItem, error)
 	GetTransferHistoryForUser(userID int) ([]TransactionHistoryItem, error)
 	GetConversionHistoryForUser(userID int) ([]TransactionHistoryItem, error)
+	GetTopupReversalHistoryForUser(userID int) ([]TransactionHistoryItem, error)
 
 	// Withdraw methods
 	GetCurrentBalanceByUserID(userID int) (float64, error)
@@ -81,6 +111,12 @@ type Repository interface {
 
 	// Public partners
 	GetAllApprovedPartners() ([]PublicPartnerResponse, error)
+	GetNearbyPartners(latitude, longitude, radiusKm float64, wasteDetailID int, limit, offset int) ([]NearbyPartner, error)
+	GetPublicWastePrices(partnerIDs []int) (map[int][]PublicWastePrice, error)
+	GetWastePriceTrend(wasteDetailID int, fromDate, toDate, timezone string) ([]WastePriceTrendPoint, error)
+	IsApprovedPartner(partnerID int) (bool, error)
+	GetPublicPartnerProfile(partnerID int) (*PublicPartnerProfile, error)
+	GetScheduleCalendars(partnerIDs []int, from, to time.Time) (map[int]*schedule.Calendar, error)
 
 	// Topup methods
 	CreateTopupTransaction(userID int, amount float64, paymentMethodID int) (string, error)
@@ -93,24 +129,61 @@ type Repository interface {
 
 	// Conversion methods
 	ExecuteConversionTransaction(userID int, xpoinChange int, balanceChange float64, conversionType string, amountXpInvolved int, amountRpInvolved float64, rate float64) (*UserWallet, error)
+
+	// Deposit session methods (check-in via QR stasiun partner)
+	CreateDepositSession(session *DepositSession) error
+	FindActiveDepositSession(userID, partnerID int) (*DepositSession, error)
+	GetDepositSessionsByUserID(userID int) ([]DepositSession, error)
+	GetDepositSessionByIDForUser(sessionID, userID int) (*DepositSession, error)
+	CancelDepositSessionByUser(sessionID, userID int) error
+
+	// Deposit draft methods (deposit dua tahap)
+	GetDepositDraftsByUserID(userID int, status string) ([]DepositDraft, error)
+	GetDepositDraftByIDForUser(draftID, userID int) (*DepositDraft, error)
+	RejectDepositDraft(draftID, userID int, reason string) error
+
+	// Deposit dispute methods (keberatan user atas deposit)
+	FindDepositForDispute(userDepositHistoryID, userID int) (*DisputableDeposit, error)
+	CreateDepositDispute(dispute *DepositDispute) error
+	GetDepositDisputesByUserID(userID int, status string) ([]DepositDispute, error)
+	GetDepositDisputeByIDForUser(disputeID, userID int) (*DepositDispute, error)
+	GetDepositPhotos(depositID int) ([]DepositPhoto, error)
+
+	// Partner review methods (ulasan user atas partner)
+	PartnerReviewExists(userDepositHistoryID int) (bool, error)
+	CreatePartnerReview(review *PartnerReview) error
+	GetPartnerReviewsByUserID(userID int) ([]PartnerReview, error)
+	GetPublishedPartnerReviews(partnerID, limit, offset int) ([]PartnerReview, error)
+	GetPartnerRatingSummaries(partnerIDs []int) (map[int]PartnerRatingSummary, error)
+
+	// Pickup request methods (permintaan jemput sampah)
+	CreatePickupRequest(pickup *PickupRequest, estimatedWeight float64) error
+	GetPickupRequestsByUserID(userID int, status string) ([]PickupRequest, error)
+	GetPickupRequestByIDForUser(pickupID, userID int) (*PickupRequest, error)
+	CancelPickupRequestByUser(pickupID, userID int, reason string) error
+	GetPickupPartnerCandidates(latitude, longitude float64, slotDate time.Time) ([]EligiblePickupPartner, error)
 }
 
 type Service struct {
 	repo            Repository
 	adminRepo      admin.AdminRepository
-	tokenStore      *temporary_token.TokenStore
+	tokenStore      temporary_token.TokenStore
+	offlineQr       *offline_qr.Signer
 	notifService    *notification.NotificationService
 	midtransService MidtransServiceInterface
+	depositDrafts   DepositDraftServiceInterface
 }
 
 // NewService membuat instance baru dari Service
-func NewService(repo Repository, adminRepo admin.AdminRepository, tokenStore *temporary_token.TokenStore, notifService *notification.NotificationService, midtransService MidtransServiceInterface) *Service {
+func NewService(repo Repository, adminRepo admin.AdminRepository, tokenStore temporary_token.TokenStore, offlineQr *offline_qr.Signer, notifService *notification.NotificationService, midtransService MidtransServiceInterface, depositDrafts DepositDraftServiceInterface) *Service {
 	return &Service{
 		repo:            repo,
 		adminRepo:      adminRepo,
 		tokenStore:      tokenStore,
+		offlineQr:       offlineQr,
 		notifService:    notifService,
 		midtransService: midtransService,
+		depositDrafts:   depositDrafts,
 	}
 }
 
@@ -234,6 +307,13 @@ func (s *Service) AddUserAddress(userIDStr string, req CreateUserAddressRequest)
 		Province:    req.Province,
 		PostalCode:  sql.NullString{String: req.PostalCode, Valid: req.PostalCode != ""},
 	}
+	if (req.Latitude == nil) != (req.Longitude == nil) {
+		return nil, errors.New("latitude dan longitude harus diisi bersamaan")
+	}
+	if req.Latitude != nil {
+		addr.Latitude = sql.NullFloat64{Float64: *req.Latitude, Valid: true}
+		addr.Longitude = sql.NullFloat64{Float64: *req.Longitude, Valid: true}
+	}
 
 	err = s.repo.CreateAddress(addr)
 	if err != nil {
@@ -264,7 +344,10 @@ func (s *Service) UpdateUserAddress(id int, userIDStr string, req UpdateUserAddr
 		return errors.New("ID pengguna tidak valid")
 	}
 	// Cek apakah ada data yang diupdate
-	if req.Fullname == "" && req.Phone == "" && req.Address == "" && req.CityRegency == "" && req.Province == "" && req.PostalCode == "" {
+	if (req.Latitude == nil) != (req.Longitude == nil) {
+		return errors.New("latitude dan longitude harus diisi bersamaan")
+	}
+	if req.Fullname == "" && req.Phone == "" && req.Address == "" && req.CityRegency == "" && req.Province == "" && req.PostalCode == "" && req.Latitude == nil {
 		return errors.New("tidak ada data untuk diupdate")
 	}
 	return s.repo.UpdateAddress(id, userID, &req)
@@ -320,6 +403,12 @@ func (s *Service) GetTransactionHistory(userIDStr string) ([]TransactionHistoryI
 	}
 	allTransactions = append(allTransactions, conversionHistory...)
 
+	reversalHistory, err := s.repo.GetTopupReversalHistoryForUser(userID)
+	if err != nil {
+		log.Printf("Error getting topup reversal history: %v", err) /* Lanjutkan saja */
+	}
+	allTransactions = append(allTransactions, reversalHistory...)
+
 	// Urutkan semua transaksi berdasarkan waktu (terbaru dulu)
 	sort.SliceStable(allTransactions, func(i, j int) bool {
 		return allTransactions[i].Timestamp.After(allTransactions[j].Timestamp)
@@ -372,6 +461,209 @@ func (s *Service) GetAllApprovedPartners() ([]PublicPartnerResponse, error) {
 	if err != nil {
 		return nil, errors.New("gagal mengambil daftar mitra")
 	}
+
+	partnerIDs := make([]int, len(partners))
+	for i, p := range partners {
+		partnerIDs[i] = p.ID
+	}
+	now := time.Now()
+	calendars, err := s.loadScheduleCalendars(partnerIDs, now)
+	if err != nil {
+		return nil, err
+	}
+	ratings, err := s.repo.GetPartnerRatingSummaries(partnerIDs)
+	if err != nil {
+		return nil, errors.New("gagal mengambil rating mitra")
+	}
+	for i := range partners {
+		if cal, ok := calendars[partners[i].ID]; ok {
+			partners[i].IsOpenNow = cal.IsOpenAt(now)
+			partners[i].NextOpenAt = cal.NextOpenAt(now)
+		}
+		partners[i].PartnerRatingSummary = ratings[partners[i].ID]
+	}
+	return partners, nil
+}
+
+// loadScheduleCalendars memuat kalender jadwal mitra untuk menghitung is_open_now/next_open_at di sekitar waktu t
+func (s *Service) loadScheduleCalendars(partnerIDs []int, t time.Time) (map[int]*schedule.Calendar, error) {
+	from, to := schedule.Window(t)
+	calendars, err := s.repo.GetScheduleCalendars(partnerIDs, from, to)
+	if err != nil {
+		return nil, errors.New("gagal mengambil jadwal mitra")
+	}
+	return calendars, nil
+}
+
+// GetPartnerProfile mengambil profil publik mitra: info usaha, alamat, jadwal + status buka, daftar harga,
+// statistik deposit, dan rating
+func (s *Service) GetPartnerProfile(partnerIDStr string) (*PublicPartnerProfile, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil || partnerID <= 0 {
+		return nil, errors.New("ID mitra tidak valid")
+	}
+	profile, err := s.repo.GetPublicPartnerProfile(partnerID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil profil mitra")
+	}
+	if profile == nil {
+		return nil, errors.New("mitra tidak ditemukan")
+	}
+
+	profile.Schedule, err = s.GetPartnerSchedule(partnerIDStr)
+	if err != nil {
+		return nil, err
+	}
+
+	prices, err := s.repo.GetPublicWastePrices([]int{partnerID})
+	if err != nil {
+		return nil, errors.New("gagal mengambil harga sampah mitra")
+	}
+	profile.Prices = prices[partnerID]
+	if profile.Prices == nil {
+		profile.Prices = []PublicWastePrice{}
+	}
+
+	ratings, err := s.repo.GetPartnerRatingSummaries([]int{partnerID})
+	if err != nil {
+		return nil, errors.New("gagal mengambil rating mitra")
+	}
+	profile.PartnerRatingSummary = ratings[partnerID]
+	reviews, err := s.repo.GetPublishedPartnerReviews(partnerID, partnerProfileRecentReviews, 0)
+	if err != nil {
+		return nil, errors.New("gagal mengambil ulasan mitra")
+	}
+	profile.RecentReviews = toPublicPartnerReviews(reviews)
+	return profile, nil
+}
+
+// GetPartnerSchedule mengambil jadwal publik mitra yang disetujui beserta status buka saat ini
+func (s *Service) GetPartnerSchedule(partnerIDStr string) (*PublicPartnerSchedule, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil || partnerID <= 0 {
+		return nil, errors.New("ID mitra tidak valid")
+	}
+	approved, err := s.repo.IsApprovedPartner(partnerID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil data mitra")
+	}
+	if !approved {
+		return nil, errors.New("mitra tidak ditemukan")
+	}
+
+	now := time.Now()
+	calendars, err := s.loadScheduleCalendars([]int{partnerID}, now)
+	if err != nil {
+		return nil, err
+	}
+	result := &PublicPartnerSchedule{
+		PartnerID:       partnerID,
+		OperatingStatus: "Closed",
+		Timezone:        schedule.DefaultTimezone,
+		Intervals:       []schedule.Interval{},
+		Exceptions:      []schedule.Exception{},
+		Holidays:        []PublicHoliday{},
+	}
+	cal, ok := calendars[partnerID]
+	if !ok { // Mitra belum mengatur jadwal
+		return result, nil
+	}
+
+	result.OperatingStatus = cal.OperatingStatus
+	result.Timezone = cal.Timezone
+	result.Intervals = cal.Intervals
+	result.IsOpenNow = cal.IsOpenAt(now)
+	result.NextOpenAt = cal.NextOpenAt(now)
+
+	// Hanya tampilkan pengecualian & libur mulai hari ini (waktu lokal mitra)
+	local := now.In(schedule.Location(cal.Timezone))
+	today := local.Format(schedule.DateLayout)
+	until := local.AddDate(0, 0, schedule.LookaheadDays).Format(schedule.DateLayout)
+	for _, ex := range cal.Exceptions {
+		if ex.Date >= today && ex.Date <= until {
+			result.Exceptions = append(result.Exceptions, ex)
+		}
+	}
+	for date, name := range cal.Holidays {
+		if date >= today && date <= until {
+			result.Holidays = append(result.Holidays, PublicHoliday{Date: date, Name: name})
+		}
+	}
+	sort.Slice(result.Holidays, func(i, j int) bool { return result.Holidays[i].Date < result.Holidays[j].Date })
+	return result, nil
+}
+
+// GetNearbyPartners mencari mitra terdekat dari titik tertentu, opsional hanya yang sedang buka
+// dan/atau menerima jenis sampah tertentu, lengkap dengan harga saat ini
+func (s *Service) GetNearbyPartners(q NearbyPartnerQuery) ([]NearbyPartner, error) {
+	latitude, longitude := *q.Latitude, *q.Longitude
+	if latitude < -90 || latitude > 90 || longitude < -180 || longitude > 180 {
+		return nil, errors.New("koordinat tidak valid")
+	}
+	radiusKm := q.RadiusKm
+	if radiusKm == 0 {
+		radiusKm = nearbyPartnerDefaultRadiusKm
+	}
+	if radiusKm < 0 || radiusKm > nearbyPartnerMaxRadiusKm {
+		return nil, fmt.Errorf("radius tidak valid, maksimal %.0f km", nearbyPartnerMaxRadiusKm)
+	}
+	if q.WasteDetailID < 0 {
+		return nil, errors.New("waste_detail_id tidak valid")
+	}
+
+	// Tanpa open_now cukup satu halaman. Dengan open_now, status buka dihitung di Go sehingga kandidat
+	// dibaca per halaman sampai hasil penuh atau semua mitra dalam radius sudah diperiksa.
+	pageSize := nearbyPartnerLimit
+	if q.OpenNow {
+		pageSize = nearbyPartnerOpenNowPage
+	}
+	now := time.Now()
+	partners := []NearbyPartner{}
+	partnerIDs := []int{}
+	for offset := 0; len(partners) < nearbyPartnerLimit; offset += pageSize {
+		candidates, err := s.repo.GetNearbyPartners(latitude, longitude, radiusKm, q.WasteDetailID, pageSize, offset)
+		if err != nil {
+			return nil, errors.New("gagal mencari mitra terdekat")
+		}
+
+		candidateIDs := make([]int, len(candidates))
+		for i, p := range candidates {
+			candidateIDs[i] = p.ID
+		}
+		calendars, err := s.loadScheduleCalendars(candidateIDs, now)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range candidates {
+			if cal, ok := calendars[p.ID]; ok {
+				p.IsOpenNow = cal.IsOpenAt(now)
+				p.NextOpenAt = cal.NextOpenAt(now)
+			}
+			if q.OpenNow && !p.IsOpenNow {
+				continue
+			}
+			p.DistanceKm = math.Round(p.DistanceKm*100) / 100
+			partners = append(partners, p)
+			partnerIDs = append(partnerIDs, p.ID)
+			if len(partners) == nearbyPartnerLimit {
+				break
+			}
+		}
+		if len(candidates) < pageSize || !q.OpenNow {
+			break // Radius sudah habis diperiksa
+		}
+	}
+
+	prices, err := s.repo.GetPublicWastePrices(partnerIDs)
+	if err != nil {
+		return nil, errors.New("gagal mengambil harga sampah mitra")
+	}
+	for i := range partners {
+		if list, ok := prices[partners[i].ID]; ok {
+			partners[i].Prices = list
+		}
+	}
 	return partners, nil
 }
 
@@ -716,6 +1008,692 @@ func (s *Service) GenerateDepositQrToken(userIDStr string) (string, time.Time, e
 	return s.tokenStore.CreateToken(userIDStr, validityDuration)
 }
 
+// GenerateOfflineDepositQr membuat kode QR bertanda tangan yang bisa diverifikasi partner tanpa koneksi
+func (s *Service) GenerateOfflineDepositQr(userIDStr string) (*OfflineQrResponse, error) {
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		return nil, errors.New("ID pengguna tidak valid")
+	}
+
+	code, claims, err := s.offlineQr.Issue(userID, offlineQrValidity)
+	if err != nil {
+		return nil, err
+	}
+	return &OfflineQrResponse{Code: code, KeyID: claims.KeyID, ExpiresAt: claims.ExpiresAt}, nil
+}
+
+// GetActiveDepositQrTokens mengambil token QR deposit milik user yang belum kedaluwarsa
+func (s *Service) GetActiveDepositQrTokens(userIDStr string) ([]QrTokenInfo, error) {
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		return nil, errors.New("ID pengguna tidak valid")
+	}
+
+	activeTokens, err := s.tokenStore.ListActiveTokens(userID)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make([]QrTokenInfo, 0, len(activeTokens))
+	for _, t := range activeTokens {
+		info := QrTokenInfo{
+			Token:     t.Token,
+			CreatedAt: t.Data.CreatedAt,
+			ExpiresAt: t.Data.ExpiresAt,
+			Claimed:   t.Data.ClaimedBy != 0,
+		}
+		if info.Claimed {
+			claimedAt := t.Data.ClaimedAt
+			info.ClaimedByPartnerID = t.Data.ClaimedBy
+			info.ClaimedAt = &claimedAt
+		}
+		tokens = append(tokens, info)
+	}
+
+	// Urutkan token terbaru dulu
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+	})
+	return tokens, nil
+}
+
+// RevokeDepositQrToken membatalkan token QR deposit milik user
+func (s *Service) RevokeDepositQrToken(userIDStr string, token string) error {
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		return errors.New("ID pengguna tidak valid")
+	}
+	return s.tokenStore.RevokeToken(token, userID)
+}
+
+// --- Deposit Session Service Methods ---
+
+// OpenDepositSession membuka sesi deposit setelah user memindai QR stasiun partner.
+// Jika user masih punya sesi aktif di partner yang sama, sesi itu yang dikembalikan.
+func (s *Service) OpenDepositSession(userIDStr string, req OpenDepositSessionRequest) (*DepositSession, error) {
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		return nil, errors.New("ID pengguna tidak valid")
+	}
+
+	partnerID, err := s.tokenStore.ResolveStationToken(req.StationToken)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.FindActiveDepositSession(userID, partnerID)
+	if err != nil {
+		return nil, errors.New("gagal membuka sesi deposit")
+	}
+	if existing != nil {
+		return existing, nil
+	}
+
+	session := &DepositSession{PartnerID: partnerID, UserID: userID}
+	if err := s.repo.CreateDepositSession(session); err != nil {
+		return nil, err
+	}
+	log.Printf("Deposit session %d opened by user ID %d at partner ID %d", session.ID, userID, partnerID)
+
+	// Ambil ulang agar nama partner ikut terisi
+	if created, err := s.repo.GetDepositSessionByIDForUser(session.ID, userID); err == nil && created != nil {
+		session = created
+	}
+	return session, nil
+}
+
+// GetDepositSessions mengambil riwayat sesi deposit milik user
+func (s *Service) GetDepositSessions(userIDStr string) ([]DepositSession, error) {
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		return nil, errors.New("ID pengguna tidak valid")
+	}
+	return s.repo.GetDepositSessionsByUserID(userID)
+}
+
+// GetDepositSessionByID mengambil detail satu sesi deposit milik user
+func (s *Service) GetDepositSessionByID(sessionID int, userIDStr string) (*DepositSession, error) {
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		return nil, errors.New("ID pengguna tidak valid")
+	}
+	session, err := s.repo.GetDepositSessionByIDForUser(sessionID, userID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil sesi deposit")
+	}
+	if session == nil {
+		return nil, errors.New("sesi deposit tidak ditemukan")
+	}
+	return session, nil
+}
+
+// CancelDepositSession membatalkan sesi deposit milik user yang belum dikonfirmasi partner
+func (s *Service) CancelDepositSession(sessionID int, userIDStr string) error {
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		return errors.New("ID pengguna tidak valid")
+	}
+	err = s.repo.CancelDepositSessionByUser(sessionID, userID)
+	if err == sql.ErrNoRows {
+		return errors.New("sesi deposit tidak ditemukan atau sudah selesai")
+	}
+	if err != nil {
+		return errors.New("gagal membatalkan sesi deposit")
+	}
+	return nil
+}
+
+// --- Deposit Draft Service Methods ---
+
+// GetDepositDrafts mengambil draft deposit milik user, opsional difilter status
+func (s *Service) GetDepositDrafts(userIDStr string, status string) ([]DepositDraft, error) {
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		return nil, errors.New("ID pengguna tidak valid")
+	}
+	return s.repo.GetDepositDraftsByUserID(userID, status)
+}
+
+// GetDepositDraftByID mengambil detail satu draft deposit milik user
+func (s *Service) GetDepositDraftByID(draftID int, userIDStr string) (*DepositDraft, error) {
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		return nil, errors.New("ID pengguna tidak valid")
+	}
+	draft, err := s.repo.GetDepositDraftByIDForUser(draftID, userID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil draft deposit")
+	}
+	if draft == nil {
+		return nil, errors.New("draft deposit tidak ditemukan")
+	}
+	return draft, nil
+}
+
+// AcceptDepositDraft menerima draft deposit; Xpoin & statistik user diperbarui saat ini
+func (s *Service) AcceptDepositDraft(draftID int, userIDStr string) (*DepositDraft, error) {
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		return nil, errors.New("ID pengguna tidak valid")
+	}
+	return s.depositDrafts.AcceptDepositDraft(draftID, userID)
+}
+
+// RejectDepositDraft menolak draft deposit sebelum batas waktu; tidak ada Xpoin yang berpindah
+func (s *Service) RejectDepositDraft(draftID int, userIDStr string, req RejectDepositDraftRequest) error {
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		return errors.New("ID pengguna tidak valid")
+	}
+
+	draft, err := s.GetDepositDraftByID(draftID, userIDStr)
+	if err != nil {
+		return err
+	}
+	if draft.Status != DepositDraftPending {
+		return errors.New("draft deposit sudah diproses")
+	}
+	if time.Now().After(draft.ExpiresAt) {
+		return errors.New("batas waktu konfirmasi sudah lewat")
+	}
+
+	err = s.repo.RejectDepositDraft(draftID, userID, req.Reason)
+	if err == sql.ErrNoRows {
+		return errors.New("draft deposit sudah diproses")
+	}
+	if err != nil {
+		return errors.New("gagal menolak draft deposit")
+	}
+	log.Printf("Deposit draft %d rejected by user ID %d", draftID, userID)
+	return nil
+}
+
+// --- Deposit Dispute Service Methods ---
+
+// uploadDepositDisputePhoto menyimpan satu foto bukti sengketa ke storage lokal
+func (s *Service) uploadDepositDisputePhoto(userID int, index int, fileHeader *multipart.FileHeader) (string, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return "", errors.New("gagal membaca file foto sengketa")
+	}
+	defer file.Close()
+
+	basePath := config.GetMediaBasePath()
+	disputeDir := filepath.Join(basePath, "dispute_photos")
+	if err := os.MkdirAll(disputeDir, 0755); err != nil {
+		log.Printf("Error creating dispute photos directory: %v", err)
+		return "", errors.New("gagal menyiapkan penyimpanan foto sengketa")
+	}
+
+	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
+	if ext == "" {
+		ext = ".jpg"
+	}
+	filename := fmt.Sprintf("dispute_%d_%d_%d%s", userID, time.Now().UnixNano(), index, ext)
+	fullPath := filepath.Join(disputeDir, filename)
+
+	dst, err := os.Create(fullPath)
+	if err != nil {
+		log.Printf("Error creating destination file for dispute photo: %v", err)
+		return "", errors.New("gagal menyimpan foto sengketa")
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, file); err != nil {
+		log.Printf("Error copying dispute photo to destination: %v", err)
+		return "", errors.New("gagal menyimpan foto sengketa")
+	}
+
+	cdnBase := config.GetCDNBaseURL()
+	return fmt.Sprintf("%s/dispute_photos/%s", cdnBase, filename), nil
+}
+
+// OpenDepositDispute mengajukan keberatan atas deposit; partner punya waktu menanggapi sebelum dieskalasi ke admin
+func (s *Service) OpenDepositDispute(userIDStr string, req OpenDepositDisputeRequest, photos []*multipart.FileHeader) (*DepositDispute, error) {
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		return nil, errors.New("ID pengguna tidak valid")
+	}
+	if len(photos) > maxDepositDisputePhotos {
+		return nil, fmt.Errorf("maksimal %d foto bukti", maxDepositDisputePhotos)
+	}
+
+	deposit, err := s.repo.FindDepositForDispute(req.DepositID, userID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil data deposit")
+	}
+	if deposit == nil {
+		return nil, errors.New("deposit tidak ditemukan")
+	}
+	if deposit.Voided {
+		return nil, errors.New("deposit sudah dibatalkan")
+	}
+	if time.Since(deposit.TransactionTime) > depositDisputeOpenWindow {
+		return nil, errors.New("batas waktu pengajuan sengketa sudah lewat")
+	}
+
+	photoURLs := make([]string, 0, len(photos))
+	for i, fileHeader := range photos {
+		url, err := s.uploadDepositDisputePhoto(userID, i, fileHeader)
+		if err != nil {
+			return nil, err
+		}
+		photoURLs = append(photoURLs, url)
+	}
+
+	dispute := &DepositDispute{
+		PartnerDepositHistoryID: deposit.PartnerDepositHistoryID,
+		UserDepositHistoryID:    req.DepositID,
+		UserID:                  userID,
+		PartnerID:               deposit.PartnerID,
+		Reason:                  req.Reason,
+		Photos:                  photoURLs,
+		ResponseDeadline:        time.Now().Add(depositDisputeResponseWindow),
+	}
+	if err := s.repo.CreateDepositDispute(dispute); err != nil {
+		return nil, err
+	}
+	log.Printf("Deposit dispute %d opened by user ID %d for deposit ID %d", dispute.ID, userID, dispute.PartnerDepositHistoryID)
+
+	go func() {
+		notifBody := fmt.Sprintf("Pelanggan mengajukan keberatan atas deposit #%d. Tanggapi sebelum %s.",
+			dispute.PartnerDepositHistoryID, dispute.ResponseDeadline.Format("02 Jan 2006 15:04"))
+		s.notifService.SendPartnerNotification(dispute.PartnerID, "Sengketa Deposit Baru", notifBody, "DEPOSIT_DISPUTE_OPENED")
+	}()
+	return dispute, nil
+}
+
+// GetDepositDisputes mengambil sengketa deposit milik user, opsional difilter status
+func (s *Service) GetDepositDisputes(userIDStr string, status string) ([]DepositDispute, error) {
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		return nil, errors.New("ID pengguna tidak valid")
+	}
+	return s.repo.GetDepositDisputesByUserID(userID, status)
+}
+
+// GetDepositDisputeByID mengambil detail satu sengketa deposit milik user
+func (s *Service) GetDepositDisputeByID(disputeID int, userIDStr string) (*DepositDispute, error) {
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		return nil, errors.New("ID pengguna tidak valid")
+	}
+	dispute, err := s.repo.GetDepositDisputeByIDForUser(disputeID, userID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil sengketa deposit")
+	}
+	if dispute == nil {
+		return nil, errors.New("sengketa deposit tidak ditemukan")
+	}
+	if photos, err := s.repo.GetDepositPhotos(dispute.PartnerDepositHistoryID); err == nil {
+		dispute.DepositPhotos = photos
+	} else {
+		log.Printf("Warning: Failed to get deposit photos for dispute %d: %v", dispute.ID, err)
+	}
+	return dispute, nil
+}
+
+// --- Partner Review Service Methods ---
+
+// uploadPartnerReviewPhoto menyimpan foto ulasan ke storage lokal
+func (s *Service) uploadPartnerReviewPhoto(userID int, fileHeader *multipart.FileHeader) (string, error) {
+	file, err := fileHeader.Open()
+	if err != nil {
+		return "", errors.New("gagal membaca file foto ulasan")
+	}
+	defer file.Close()
+
+	basePath := config.GetMediaBasePath()
+	reviewDir := filepath.Join(basePath, "review_photos")
+	if err := os.MkdirAll(reviewDir, 0755); err != nil {
+		log.Printf("Error creating review photos directory: %v", err)
+		return "", errors.New("gagal menyiapkan penyimpanan foto ulasan")
+	}
+
+	ext := strings.ToLower(filepath.Ext(fileHeader.Filename))
+	if ext == "" {
+		ext = ".jpg"
+	}
+	filename := fmt.Sprintf("review_%d_%d%s", userID, time.Now().UnixNano(), ext)
+	fullPath := filepath.Join(reviewDir, filename)
+
+	dst, err := os.Create(fullPath)
+	if err != nil {
+		log.Printf("Error creating destination file for review photo: %v", err)
+		return "", errors.New("gagal menyimpan foto ulasan")
+	}
+	defer dst.Close()
+
+	if _, err := io.Copy(dst, file); err != nil {
+		log.Printf("Error copying review photo to destination: %v", err)
+		return "", errors.New("gagal menyimpan foto ulasan")
+	}
+
+	cdnBase := config.GetCDNBaseURL()
+	return fmt.Sprintf("%s/review_photos/%s", cdnBase, filename), nil
+}
+
+// removeMediaFile menghapus file di media lokal berdasarkan URL CDN-nya; URL di luar CDN diabaikan
+func removeMediaFile(url string) {
+	if url == "" {
+		return
+	}
+	relPath := strings.TrimPrefix(url, config.GetCDNBaseURL()+"/")
+	if relPath == url || strings.Contains(relPath, "..") {
+		return
+	}
+	if err := os.Remove(filepath.Join(config.GetMediaBasePath(), filepath.FromSlash(relPath))); err != nil && !os.IsNotExist(err) {
+		log.Printf("Warning: failed to remove uploaded file %s: %v", url, err)
+	}
+}
+
+// CreatePartnerReview menyimpan ulasan user atas partner untuk satu deposit (satu ulasan per deposit)
+func (s *Service) CreatePartnerReview(userIDStr string, req CreatePartnerReviewRequest, photo *multipart.FileHeader) (*PartnerReview, error) {
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		return nil, errors.New("ID pengguna tidak valid")
+	}
+	comment := strings.TrimSpace(req.Comment)
+	if len(comment) > maxPartnerReviewComment {
+		return nil, fmt.Errorf("komentar ulasan terlalu panjang (maks %d karakter)", maxPartnerReviewComment)
+	}
+
+	deposit, err := s.repo.FindDepositForDispute(req.DepositID, userID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil data deposit")
+	}
+	if deposit == nil {
+		return nil, errors.New("deposit tidak ditemukan")
+	}
+	if deposit.Voided {
+		return nil, errors.New("deposit sudah dibatalkan")
+	}
+	if time.Since(deposit.TransactionTime) > partnerReviewWindow {
+		return nil, errors.New("batas waktu memberi ulasan sudah lewat")
+	}
+	exists, err := s.repo.PartnerReviewExists(req.DepositID)
+	if err != nil {
+		return nil, errors.New("gagal memeriksa ulasan deposit")
+	}
+	if exists {
+		return nil, errors.New("ulasan untuk deposit ini sudah ada")
+	}
+
+	review := &PartnerReview{
+		UserDepositHistoryID:    req.DepositID,
+		PartnerDepositHistoryID: deposit.PartnerDepositHistoryID,
+		UserID:                  userID,
+		PartnerID:               deposit.PartnerID,
+		Rating:                  req.Rating,
+		Comment:                 sql.NullString{String: comment, Valid: comment != ""},
+	}
+	if photo != nil {
+		url, err := s.uploadPartnerReviewPhoto(userID, photo)
+		if err != nil {
+			return nil, err
+		}
+		review.Photo = sql.NullString{String: url, Valid: true}
+	}
+	if err := s.repo.CreatePartnerReview(review); err != nil {
+		removeMediaFile(review.Photo.String)
+		return nil, err
+	}
+	log.Printf("Partner review %d created by user ID %d for partner ID %d (rating %d)", review.ID, userID, review.PartnerID, review.Rating)
+
+	go func() {
+		notifBody := fmt.Sprintf("Pelanggan memberi rating %d/5 untuk deposit #%d.", review.Rating, review.PartnerDepositHistoryID)
+		s.notifService.SendPartnerNotification(review.PartnerID, "Ulasan Baru", notifBody, "PARTNER_REVIEW_CREATED")
+	}()
+	return review, nil
+}
+
+// GetMyPartnerReviews mengambil ulasan yang pernah ditulis user
+func (s *Service) GetMyPartnerReviews(userIDStr string) ([]PartnerReview, error) {
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		return nil, errors.New("ID pengguna tidak valid")
+	}
+	reviews, err := s.repo.GetPartnerReviewsByUserID(userID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil ulasan")
+	}
+	return reviews, nil
+}
+
+// GetPublicPartnerReviews mengambil ulasan publik mitra yang disetujui, terbaru dulu
+func (s *Service) GetPublicPartnerReviews(partnerIDStr string, limit, offset int) ([]PublicPartnerReview, error) {
+	partnerID, err := strconv.Atoi(partnerIDStr)
+	if err != nil || partnerID <= 0 {
+		return nil, errors.New("ID mitra tidak valid")
+	}
+	if limit <= 0 {
+		limit = publicReviewsDefaultLimit
+	}
+	if limit > publicReviewsMaxLimit || offset < 0 {
+		return nil, fmt.Errorf("limit/offset tidak valid (limit maksimal %d)", publicReviewsMaxLimit)
+	}
+	approved, err := s.repo.IsApprovedPartner(partnerID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil data mitra")
+	}
+	if !approved {
+		return nil, errors.New("mitra tidak ditemukan")
+	}
+
+	reviews, err := s.repo.GetPublishedPartnerReviews(partnerID, limit, offset)
+	if err != nil {
+		return nil, errors.New("gagal mengambil ulasan mitra")
+	}
+	return toPublicPartnerReviews(reviews), nil
+}
+
+// toPublicPartnerReviews mengubah ulasan menjadi bentuk publik (tanpa data moderasi, nama disamarkan)
+func toPublicPartnerReviews(reviews []PartnerReview) []PublicPartnerReview {
+	result := make([]PublicPartnerReview, 0, len(reviews))
+	for _, rv := range reviews {
+		result = append(result, PublicPartnerReview{
+			ID:               rv.ID,
+			UserName:         maskReviewerName(rv.UserName.String),
+			Rating:           rv.Rating,
+			Comment:          rv.Comment.String,
+			Photo:            rv.Photo.String,
+			PartnerReply:     rv.PartnerReply.String,
+			PartnerRepliedAt: rv.PartnerRepliedAt,
+			CreatedAt:        rv.CreatedAt,
+		})
+	}
+	return result
+}
+
+// maskReviewerName menyamarkan nama pengulas: "Budi Santoso" -> "Budi S."
+func maskReviewerName(fullname string) string {
+	parts := strings.Fields(fullname)
+	if len(parts) == 0 {
+		return "Pengguna Xetor"
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	last := []rune(parts[len(parts)-1])
+	return parts[0] + " " + strings.ToUpper(string(last[0])) + "."
+}
+
+// --- Pickup Request Service Methods ---
+
+// CreatePickupRequest membuat permintaan jemput dari alamat user; partner terdekat yang melayani jemput bisa menerimanya
+func (s *Service) CreatePickupRequest(userIDStr string, req CreatePickupRequest) (*PickupRequest, error) {
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		return nil, errors.New("ID pengguna tidak valid")
+	}
+
+	addr, err := s.repo.GetAddressByID(req.AddressID, userID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil alamat")
+	}
+	if addr == nil {
+		return nil, errors.New("alamat tidak ditemukan")
+	}
+	if !addr.Latitude.Valid || !addr.Longitude.Valid {
+		return nil, errors.New("alamat belum memiliki titik lokasi (latitude/longitude)")
+	}
+
+	now := time.Now()
+	switch {
+	case !req.SlotEnd.After(req.SlotStart):
+		return nil, errors.New("slot_end harus setelah slot_start")
+	case req.SlotStart.Before(now.Add(pickupMinLeadTime)):
+		return nil, fmt.Errorf("slot jemput paling cepat %d jam dari sekarang", int(pickupMinLeadTime.Hours()))
+	case req.SlotStart.After(now.Add(pickupMaxAdvance)):
+		return nil, fmt.Errorf("slot jemput paling lambat %d hari ke depan", int(pickupMaxAdvance.Hours()/24))
+	case req.SlotEnd.Sub(req.SlotStart) > pickupMaxSlotLength:
+		return nil, fmt.Errorf("rentang slot jemput maksimal %d jam", int(pickupMaxSlotLength.Hours()))
+	}
+
+	estimatedWeight := 0.0
+	for _, item := range req.Items {
+		estimatedWeight += item.EstimatedWeight
+	}
+
+	eligible, err := s.eligiblePickupPartners(addr.Latitude.Float64, addr.Longitude.Float64, req.SlotStart, estimatedWeight)
+	if err != nil {
+		return nil, err
+	}
+	if len(eligible) == 0 {
+		return nil, errors.New("belum ada partner jemput yang melayani alamat ini pada tanggal dan estimasi berat tersebut")
+	}
+
+	addressText := addr.Address + ", " + addr.CityRegency + ", " + addr.Province
+	if addr.PostalCode.Valid {
+		addressText += " " + addr.PostalCode.String
+	}
+	pickup := &PickupRequest{
+		UserID:        userID,
+		UserAddressID: sql.NullInt32{Int32: int32(addr.ID), Valid: true},
+		ContactName:   addr.Fullname,
+		ContactPhone:  addr.Phone,
+		Address:       addressText,
+		Latitude:      addr.Latitude.Float64,
+		Longitude:     addr.Longitude.Float64,
+		Items:         req.Items,
+		SlotStart:     req.SlotStart,
+		SlotEnd:       req.SlotEnd,
+		Notes:         sql.NullString{String: req.Notes, Valid: req.Notes != ""},
+	}
+	if err := s.repo.CreatePickupRequest(pickup, estimatedWeight); err != nil {
+		return nil, err
+	}
+	log.Printf("Pickup request %d created by user ID %d", pickup.ID, userID)
+	return pickup, nil
+}
+
+// eligiblePickupPartners menyaring kandidat partner jemput berdasarkan area layanan (poligon atau radius),
+// berat minimal dan sisa kapasitas harian. estimatedWeight 0 berarti berat belum diketahui (tidak disaring).
+func (s *Service) eligiblePickupPartners(latitude, longitude float64, slotDate time.Time, estimatedWeight float64) ([]EligiblePickupPartner, error) {
+	candidates, err := s.repo.GetPickupPartnerCandidates(latitude, longitude, slotDate)
+	if err != nil {
+		return nil, errors.New("gagal mengambil partner jemput")
+	}
+
+	point := geo.Point{Latitude: latitude, Longitude: longitude}
+	eligible := []EligiblePickupPartner{}
+	for _, c := range candidates {
+		center := geo.Point{Latitude: c.Latitude, Longitude: c.Longitude}
+		if !geo.InServiceArea(point, center, c.ServiceRadiusKm, c.ServiceAreaPolygon) {
+			continue
+		}
+		minWeight, _ := strconv.ParseFloat(c.MinPickupWeight, 64)
+		if estimatedWeight > 0 && estimatedWeight < minWeight {
+			continue
+		}
+		if c.DailyPickupCapacity > 0 {
+			remaining := c.DailyPickupCapacity - c.BookedCount
+			if remaining <= 0 {
+				continue
+			}
+			c.RemainingCapacity = &remaining
+		}
+		c.DistanceKm = math.Round(c.DistanceKm*100) / 100
+		eligible = append(eligible, c)
+	}
+	return eligible, nil
+}
+
+// GetEligiblePickupPartners mengambil partner yang dapat menjemput dari alamat user pada tanggal tertentu
+func (s *Service) GetEligiblePickupPartners(userIDStr string, addressID int, slotDate time.Time, estimatedWeight float64) ([]EligiblePickupPartner, error) {
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		return nil, errors.New("ID pengguna tidak valid")
+	}
+	addr, err := s.repo.GetAddressByID(addressID, userID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil alamat")
+	}
+	if addr == nil {
+		return nil, errors.New("alamat tidak ditemukan")
+	}
+	if !addr.Latitude.Valid || !addr.Longitude.Valid {
+		return nil, errors.New("alamat belum memiliki titik lokasi (latitude/longitude)")
+	}
+	return s.eligiblePickupPartners(addr.Latitude.Float64, addr.Longitude.Float64, slotDate, estimatedWeight)
+}
+
+// GetPickupRequests mengambil permintaan jemput milik user, opsional difilter status
+func (s *Service) GetPickupRequests(userIDStr string, status string) ([]PickupRequest, error) {
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		return nil, errors.New("ID pengguna tidak valid")
+	}
+	return s.repo.GetPickupRequestsByUserID(userID, status)
+}
+
+// GetPickupRequestByID mengambil detail satu permintaan jemput milik user
+func (s *Service) GetPickupRequestByID(pickupID int, userIDStr string) (*PickupRequest, error) {
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		return nil, errors.New("ID pengguna tidak valid")
+	}
+	pickup, err := s.repo.GetPickupRequestByIDForUser(pickupID, userID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil permintaan jemput")
+	}
+	if pickup == nil {
+		return nil, errors.New("permintaan jemput tidak ditemukan")
+	}
+	return pickup, nil
+}
+
+// CancelPickupRequest membatalkan permintaan jemput sebelum partner berangkat
+func (s *Service) CancelPickupRequest(pickupID int, userIDStr string, req CancelPickupRequest) error {
+	userID, err := strconv.Atoi(userIDStr)
+	if err != nil {
+		return errors.New("ID pengguna tidak valid")
+	}
+
+	pickup, err := s.GetPickupRequestByID(pickupID, userIDStr)
+	if err != nil {
+		return err
+	}
+	if pickup.Status != PickupRequested && pickup.Status != PickupScheduled {
+		return errors.New("permintaan jemput tidak dapat dibatalkan karena partner sudah berangkat")
+	}
+
+	err = s.repo.CancelPickupRequestByUser(pickupID, userID, req.Reason)
+	if err == sql.ErrNoRows {
+		return errors.New("permintaan jemput tidak dapat dibatalkan karena partner sudah berangkat")
+	}
+	if err != nil {
+		return errors.New("gagal membatalkan permintaan jemput")
+	}
+
+	if pickup.PartnerID.Valid {
+		go func() {
+			notifBody := fmt.Sprintf("Pelanggan membatalkan jadwal jemput #%d (%s).", pickupID, pickup.SlotStart.Format("02 Jan 2006 15:04"))
+			s.notifService.SendPartnerNotification(int(pickup.PartnerID.Int32), "Jemput Sampah Dibatalkan", notifBody, "PICKUP_CANCELLED")
+		}()
+	}
+	return nil
+}
+
 // --- User Profile Update Service ---
 
 // UpdateProfile memproses update data profil user
@@ -883,6 +1861,48 @@ func stringToPtr(s string) *string {
 	return &s // Mengambil alamat memori dari string
 }
 
+// maxWastePriceTrendDays batas rentang tanggal tren harga sampah
+const maxWastePriceTrendDays = 366
+
+// GetWastePriceTrend tren harga harian satu jenis sampah di seluruh mitra (default 30 hari terakhir)
+func (s *Service) GetWastePriceTrend(wasteDetailID int, fromStr, toStr string) (*WastePriceTrend, error) {
+	wd, err := s.adminRepo.GetWasteDetailByID(wasteDetailID)
+	if err != nil {
+		return nil, errors.New("gagal mengambil detail sampah")
+	}
+	if wd == nil {
+		return nil, errors.New("detail sampah tidak ditemukan")
+	}
+
+	loc := schedule.Location(schedule.DefaultTimezone)
+	now := time.Now().In(loc)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	from := to.AddDate(0, 0, -29)
+	if fromStr != "" {
+		if from, err = time.ParseInLocation(schedule.DateLayout, fromStr, loc); err != nil {
+			return nil, errors.New("tanggal from tidak valid (YYYY-MM-DD)")
+		}
+	}
+	if toStr != "" {
+		if to, err = time.ParseInLocation(schedule.DateLayout, toStr, loc); err != nil {
+			return nil, errors.New("tanggal to tidak valid (YYYY-MM-DD)")
+		}
+	}
+	if to.Before(from) {
+		return nil, errors.New("rentang tanggal tidak valid: to sebelum from")
+	}
+	if to.Sub(from) > maxWastePriceTrendDays*24*time.Hour {
+		return nil, fmt.Errorf("rentang tanggal tidak valid: maksimal %d hari", maxWastePriceTrendDays)
+	}
+
+	fromDate, toDate := from.Format(schedule.DateLayout), to.Format(schedule.DateLayout)
+	points, err := s.repo.GetWastePriceTrend(wasteDetailID, fromDate, toDate, schedule.DefaultTimezone)
+	if err != nil {
+		return nil, errors.New("gagal mengambil tren harga sampah")
+	}
+	return &WastePriceTrend{WasteDetailID: wd.ID, Name: wd.Name, From: fromDate, To: toDate, Points: points}, nil
+}
+
 // GetWasteDetailByID mengambil waste detail berdasarkan ID dan transform ke response model
 func (s *Service) GetWasteDetailByID(id int) (*WasteDetailResponse, error) {
 	wd, err := s.adminRepo.GetWasteDetailByID(id)
@@ -0,0 +1,176 @@
+package user
+
+import (
+	"bytes"
+	"errors"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"xetor.id/backend/internal/schedule"
+)
+
+// nearbyPartnerRepo memalsukan query mitra terdekat: kandidat sudah urut jarak, mitra di open buka 24 jam
+type nearbyPartnerRepo struct {
+	Repository
+	candidates []NearbyPartner
+	open       map[int]bool
+	queries    int
+}
+
+func (r *nearbyPartnerRepo) GetNearbyPartners(latitude, longitude, radiusKm float64, wasteDetailID int, limit, offset int) ([]NearbyPartner, error) {
+	r.queries++
+	if offset >= len(r.candidates) {
+		return []NearbyPartner{}, nil
+	}
+	end := offset + limit
+	if end > len(r.candidates) {
+		end = len(r.candidates)
+	}
+	return append([]NearbyPartner{}, r.candidates[offset:end]...), nil
+}
+
+func (r *nearbyPartnerRepo) GetScheduleCalendars(partnerIDs []int, from, to time.Time) (map[int]*schedule.Calendar, error) {
+	calendars := make(map[int]*schedule.Calendar, len(partnerIDs))
+	for _, id := range partnerIDs {
+		cal := &schedule.Calendar{OperatingStatus: "Closed", Timezone: schedule.DefaultTimezone}
+		if r.open[id] {
+			cal.OperatingStatus = "Open"
+			for weekday := 0; weekday < 7; weekday++ {
+				cal.Intervals = append(cal.Intervals, schedule.Interval{Weekday: weekday, OpenTime: "00:00", CloseTime: "00:00"})
+			}
+		}
+		calendars[id] = cal
+	}
+	return calendars, nil
+}
+
+func (r *nearbyPartnerRepo) GetPublicWastePrices(partnerIDs []int) (map[int][]PublicWastePrice, error) {
+	return map[int][]PublicWastePrice{}, nil
+}
+
+func newNearbyPartnerRepo(total int, isOpen func(id int) bool) *nearbyPartnerRepo {
+	repo := &nearbyPartnerRepo{open: map[int]bool{}}
+	for id := 1; id <= total; id++ {
+		repo.candidates = append(repo.candidates, NearbyPartner{ID: id, DistanceKm: float64(id) / 100})
+		repo.open[id] = isOpen(id)
+	}
+	return repo
+}
+
+func TestGetNearbyPartnersOpenNow(t *testing.T) {
+	lat, lng := -6.2, 106.8
+	tests := []struct {
+		name        string
+		total       int
+		isOpen      func(id int) bool
+		openNow     bool
+		wantIDs     []int // ID pertama & terakhir hasil
+		wantCount   int
+		wantQueries int
+	}{
+		{"open partners beyond first page", 600, func(id int) bool { return id > 450 }, true, []int{451, 500}, nearbyPartnerLimit, 3},
+		{"radius exhausted before limit", 230, func(id int) bool { return id%20 == 0 }, true, []int{20, 220}, 11, 2},
+		{"no open partners", 450, func(int) bool { return false }, true, nil, 0, 3},
+		{"without open_now only nearest page", 600, func(int) bool { return false }, false, []int{1, 50}, nearbyPartnerLimit, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newNearbyPartnerRepo(tt.total, tt.isOpen)
+			s := &Service{repo: repo}
+
+			partners, err := s.GetNearbyPartners(NearbyPartnerQuery{Latitude: &lat, Longitude: &lng, OpenNow: tt.openNow})
+			if err != nil {
+				t.Fatalf("GetNearbyPartners: %v", err)
+			}
+			if len(partners) != tt.wantCount {
+				t.Fatalf("got %d partners, want %d", len(partners), tt.wantCount)
+			}
+			if tt.wantIDs != nil && (partners[0].ID != tt.wantIDs[0] || partners[len(partners)-1].ID != tt.wantIDs[1]) {
+				t.Fatalf("got IDs %d..%d, want %d..%d", partners[0].ID, partners[len(partners)-1].ID, tt.wantIDs[0], tt.wantIDs[1])
+			}
+			for _, p := range partners {
+				if tt.openNow && !p.IsOpenNow {
+					t.Fatalf("partner %d returned but closed", p.ID)
+				}
+			}
+			if repo.queries != tt.wantQueries {
+				t.Fatalf("repo queried %d times, want %d", repo.queries, tt.wantQueries)
+			}
+		})
+	}
+}
+
+// partnerReviewRepo memalsukan penyimpanan ulasan; createErr dikembalikan saat insert
+type partnerReviewRepo struct {
+	Repository
+	exists    bool
+	createErr error
+	created   int
+}
+
+func (r *partnerReviewRepo) FindDepositForDispute(userDepositHistoryID, userID int) (*DisputableDeposit, error) {
+	return &DisputableDeposit{PartnerDepositHistoryID: 70, PartnerID: 3, TransactionTime: time.Now()}, nil
+}
+
+func (r *partnerReviewRepo) PartnerReviewExists(userDepositHistoryID int) (bool, error) {
+	return r.exists, nil
+}
+
+func (r *partnerReviewRepo) CreatePartnerReview(review *PartnerReview) error {
+	r.created++
+	return r.createErr
+}
+
+// reviewPhoto membuat FileHeader foto ulasan seperti hasil parsing multipart
+func reviewPhoto(t *testing.T) *multipart.FileHeader {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("photo", "ulasan.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte("jpeg"))
+	w.Close()
+	form, err := multipart.NewReader(&body, w.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return form.File["photo"][0]
+}
+
+func TestCreatePartnerReviewPhotoCleanup(t *testing.T) {
+	mediaDir := t.TempDir()
+	t.Setenv("MEDIA_BASE_PATH", mediaDir)
+	t.Setenv("CDN_BASE_URL", "https://cdn.example.test")
+	duplicate := errors.New("ulasan untuk deposit ini sudah ada")
+
+	tests := []struct {
+		name        string
+		repo        *partnerReviewRepo
+		wantErr     error
+		wantCreated int
+	}{
+		{"existing review skips upload", &partnerReviewRepo{exists: true}, duplicate, 0},
+		{"insert failure removes photo", &partnerReviewRepo{createErr: duplicate}, duplicate, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Service{repo: tt.repo}
+			_, err := s.CreatePartnerReview("5", CreatePartnerReviewRequest{DepositID: 9, Rating: 4}, reviewPhoto(t))
+			if err == nil || err.Error() != tt.wantErr.Error() {
+				t.Fatalf("CreatePartnerReview error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.repo.created != tt.wantCreated {
+				t.Fatalf("insert called %d times, want %d", tt.repo.created, tt.wantCreated)
+			}
+			files, _ := os.ReadDir(filepath.Join(mediaDir, "review_photos"))
+			if len(files) != 0 {
+				t.Fatalf("%d review photos left in media storage", len(files))
+			}
+		})
+	}
+}
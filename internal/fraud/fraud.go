@@ -0,0 +1,129 @@
+package fraud
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Aksi yang bisa diambil sebuah aturan, urut dari yang paling ringan
+const (
+	ActionAllow  = "allow"  // Tidak melakukan apa-apa (aturan nonaktif)
+	ActionHold   = "hold"   // Deposit ditahan menunggu review admin
+	ActionReject = "reject" // Deposit ditolak langsung
+)
+
+// Kode aturan bawaan (lihat seed di migrasi fraud_rules)
+const (
+	RuleDailyUserWeight   = "daily_user_weight"        // Total berat user dalam sehari (kg) melebihi threshold
+	RuleRepeatedWeight    = "repeated_identical_weight" // Berat identik ke user yang sama berulang >= threshold kali
+	RuleSameUserFrequency = "same_user_frequency"       // Deposit partner ke user yang sama >= threshold kali dalam window
+	RuleNewAccountCredit  = "new_account_large_credit"  // Akun berumur < window menerima >= threshold Xpoin
+	RuleOutsideSchedule   = "outside_schedule"          // Deposit di luar jam operasional partner
+)
+
+// Rule konfigurasi satu aturan. Arti Threshold & WindowHours bergantung pada kode aturan.
+type Rule struct {
+	Code        string    `json:"code"`
+	Description string    `json:"description"`
+	Action      string    `json:"action"`
+	Threshold   float64   `json:"threshold"`
+	WindowHours int       `json:"window_hours"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Window durasi jendela aturan
+func (r Rule) Window() time.Duration {
+	return time.Duration(r.WindowHours) * time.Hour
+}
+
+// Hit satu aturan yang terpicu beserta alasannya
+type Hit struct {
+	Code   string `json:"code"`
+	Action string `json:"action"`
+	Detail string `json:"detail"`
+}
+
+// Input sinyal satu deposit. Angka historis TIDAK termasuk deposit yang sedang dievaluasi.
+type Input struct {
+	TotalWeight     float64
+	TotalXpoin      int
+	ItemWeights     []float64       // Berat per item kg (item pcs tidak ikut)
+	UserWeightInDay float64         // Total berat user di semua partner pada hari transaksi
+	PastWeights     map[float64]int // Berat item partner->user dalam window aturan berat identik, beserta jumlahnya
+	DepositsToUser  int             // Jumlah deposit partner->user dalam window aturan frekuensi
+	UserCreatedAt   time.Time
+	TransactionTime time.Time
+	OutsideSchedule bool
+}
+
+// ValidAction memeriksa apakah aksi dikenal
+func ValidAction(action string) bool {
+	return action == ActionAllow || action == ActionHold || action == ActionReject
+}
+
+// Evaluate menjalankan semua aturan aktif (aksi selain allow) dan mengembalikan aturan yang terpicu
+func Evaluate(rules []Rule, in Input) []Hit {
+	hits := []Hit{}
+	for _, rule := range rules {
+		if rule.Action == ActionAllow {
+			continue
+		}
+		if detail, ok := check(rule, in); ok {
+			hits = append(hits, Hit{Code: rule.Code, Action: rule.Action, Detail: detail})
+		}
+	}
+	return hits
+}
+
+// check mengevaluasi satu aturan; aturan dengan kode tidak dikenal diabaikan
+func check(rule Rule, in Input) (string, bool) {
+	switch rule.Code {
+	case RuleDailyUserWeight:
+		total := in.UserWeightInDay + in.TotalWeight
+		if total > rule.Threshold {
+			return fmt.Sprintf("total setoran user hari ini %.2f kg melebihi %.2f kg", total, rule.Threshold), true
+		}
+	case RuleRepeatedWeight:
+		for _, w := range in.ItemWeights {
+			count := in.PastWeights[Round(w)] + 1
+			if float64(count) >= rule.Threshold {
+				return fmt.Sprintf("berat %.2f kg tercatat %d kali ke user yang sama", w, count), true
+			}
+		}
+	case RuleSameUserFrequency:
+		count := in.DepositsToUser + 1
+		if float64(count) >= rule.Threshold {
+			return fmt.Sprintf("%d deposit ke user yang sama dalam %d jam", count, rule.WindowHours), true
+		}
+	case RuleNewAccountCredit:
+		age := in.TransactionTime.Sub(in.UserCreatedAt)
+		if age < rule.Window() && float64(in.TotalXpoin) >= rule.Threshold {
+			return fmt.Sprintf("akun berumur %.0f jam menerima %d Xpoin", age.Hours(), in.TotalXpoin), true
+		}
+	case RuleOutsideSchedule:
+		if in.OutsideSchedule {
+			return "deposit di luar jam operasional partner", true
+		}
+	}
+	return "", false
+}
+
+// Decide menentukan aksi akhir dari aturan yang terpicu (reject > hold > allow)
+func Decide(hits []Hit) string {
+	action := ActionAllow
+	for _, hit := range hits {
+		if hit.Action == ActionReject {
+			return ActionReject
+		}
+		if hit.Action == ActionHold {
+			action = ActionHold
+		}
+	}
+	return action
+}
+
+// Round membulatkan berat ke 2 desimal agar perbandingan berat identik stabil
+func Round(weight float64) float64 {
+	return math.Round(weight*100) / 100
+}
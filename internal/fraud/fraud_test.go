@@ -0,0 +1,80 @@
+package fraud
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecide(t *testing.T) {
+	tests := []struct {
+		name string
+		hits []Hit
+		want string
+	}{
+		{"no hits", nil, ActionAllow},
+		{"empty hits", []Hit{}, ActionAllow},
+		{"single hold", []Hit{{Code: RuleDailyUserWeight, Action: ActionHold}}, ActionHold},
+		{"single reject", []Hit{{Code: RuleOutsideSchedule, Action: ActionReject}}, ActionReject},
+		{"reject wins over earlier hold", []Hit{
+			{Code: RuleDailyUserWeight, Action: ActionHold},
+			{Code: RuleNewAccountCredit, Action: ActionReject},
+		}, ActionReject},
+		{"reject wins over later hold", []Hit{
+			{Code: RuleNewAccountCredit, Action: ActionReject},
+			{Code: RuleDailyUserWeight, Action: ActionHold},
+		}, ActionReject},
+		{"allow hit does not hold", []Hit{{Code: RuleSameUserFrequency, Action: ActionAllow}}, ActionAllow},
+		{"unknown action ignored", []Hit{
+			{Code: RuleRepeatedWeight, Action: "block"},
+			{Code: RuleDailyUserWeight, Action: ActionHold},
+		}, ActionHold},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Decide(tt.hits); got != tt.want {
+				t.Fatalf("Decide() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	now := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+	rules := []Rule{
+		{Code: RuleDailyUserWeight, Action: ActionHold, Threshold: 100, WindowHours: 24},
+		{Code: RuleRepeatedWeight, Action: ActionHold, Threshold: 3, WindowHours: 168},
+		{Code: RuleSameUserFrequency, Action: ActionAllow, Threshold: 1, WindowHours: 24},
+		{Code: RuleNewAccountCredit, Action: ActionReject, Threshold: 500, WindowHours: 72},
+		{Code: RuleOutsideSchedule, Action: ActionHold},
+	}
+
+	tests := []struct {
+		name  string
+		input Input
+		want  []string
+	}{
+		{"clean deposit", Input{TotalWeight: 5, ItemWeights: []float64{5}, UserCreatedAt: now.AddDate(0, -1, 0), TransactionTime: now}, nil},
+		{"daily weight counts current deposit", Input{TotalWeight: 30, UserWeightInDay: 80.5, UserCreatedAt: now.AddDate(0, -1, 0), TransactionTime: now},
+			[]string{RuleDailyUserWeight}},
+		{"repeated identical weight", Input{TotalWeight: 2.5, ItemWeights: []float64{2.499}, PastWeights: map[float64]int{2.5: 2},
+			UserCreatedAt: now.AddDate(0, -1, 0), TransactionTime: now}, []string{RuleRepeatedWeight}},
+		{"new account large credit", Input{TotalXpoin: 500, UserCreatedAt: now.Add(-time.Hour), TransactionTime: now},
+			[]string{RuleNewAccountCredit}},
+		{"old account large credit", Input{TotalXpoin: 500, UserCreatedAt: now.Add(-73 * time.Hour), TransactionTime: now}, nil},
+		{"outside schedule", Input{OutsideSchedule: true, UserCreatedAt: now.AddDate(0, -1, 0), TransactionTime: now},
+			[]string{RuleOutsideSchedule}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hits := Evaluate(rules, tt.input)
+			if len(hits) != len(tt.want) {
+				t.Fatalf("Evaluate() = %+v, want codes %v", hits, tt.want)
+			}
+			for i, hit := range hits {
+				if hit.Code != tt.want[i] || hit.Detail == "" {
+					t.Fatalf("hit %d = %+v, want code %s with detail", i, hit, tt.want[i])
+				}
+			}
+		})
+	}
+}
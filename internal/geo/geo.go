@@ -0,0 +1,59 @@
+package geo
+
+import "math"
+
+const earthRadiusKm = 6371.0
+
+// Point adalah satu titik koordinat (derajat desimal)
+type Point struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// DistanceKm menghitung jarak great-circle (haversine) antara dua titik dalam km
+func DistanceKm(a, b Point) float64 {
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLng := (b.Longitude - a.Longitude) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// InPolygon memeriksa apakah titik berada di dalam poligon (ray casting).
+// Poligon minimal 3 titik dan tidak perlu ditutup ulang dengan titik pertama.
+func InPolygon(p Point, polygon []Point) bool {
+	if len(polygon) < 3 {
+		return false
+	}
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[i], polygon[j]
+		if (a.Latitude > p.Latitude) != (b.Latitude > p.Latitude) &&
+			p.Longitude < (b.Longitude-a.Longitude)*(p.Latitude-a.Latitude)/(b.Latitude-a.Latitude)+a.Longitude {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// InServiceArea memeriksa apakah titik dilayani area partner. Jika poligon diisi,
+// poligon yang dipakai; jika tidak, radius dari titik pusat (alamat partner).
+func InServiceArea(p Point, center Point, radiusKm float64, polygon []Point) bool {
+	if len(polygon) >= 3 {
+		return InPolygon(p, polygon)
+	}
+	return DistanceKm(p, center) <= radiusKm
+}
+
+// ReachKm jarak terjauh area layanan dari titik pusat, dipakai sebagai prafilter query berbasis radius
+func ReachKm(center Point, radiusKm float64, polygon []Point) float64 {
+	if len(polygon) < 3 {
+		return radiusKm
+	}
+	reach := 0.0
+	for _, v := range polygon {
+		reach = math.Max(reach, DistanceKm(center, v))
+	}
+	return reach
+}
@@ -0,0 +1,108 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistanceKm(t *testing.T) {
+	monas := Point{Latitude: -6.1754, Longitude: 106.8272}
+	tests := []struct {
+		name string
+		a, b Point
+		want float64
+	}{
+		{"same point", monas, monas, 0},
+		{"one degree of latitude", Point{0, 0}, Point{1, 0}, 111.195},
+		{"one degree of longitude at equator", Point{0, 0}, Point{0, 1}, 111.195},
+		{"across the antimeridian", Point{0, 179.5}, Point{0, -179.5}, 111.195},
+		{"antipodal points", Point{0, 0}, Point{0, 180}, math.Pi * earthRadiusKm},
+		{"pole to pole", Point{90, 0}, Point{-90, 0}, math.Pi * earthRadiusKm},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DistanceKm(tt.a, tt.b); math.Abs(got-tt.want) > 0.01 {
+				t.Fatalf("DistanceKm(%v, %v) = %.3f, want %.3f", tt.a, tt.b, got, tt.want)
+			}
+			if got, back := DistanceKm(tt.a, tt.b), DistanceKm(tt.b, tt.a); math.Abs(got-back) > 1e-9 {
+				t.Fatalf("DistanceKm not symmetric: %v vs %v", got, back)
+			}
+		})
+	}
+}
+
+func TestInPolygon(t *testing.T) {
+	square := []Point{{0, 0}, {0, 1}, {1, 1}, {1, 0}}
+	// Bentuk L: kotak 2x2 tanpa kuadran kanan atas
+	lShape := []Point{{0, 0}, {0, 2}, {1, 2}, {1, 1}, {2, 1}, {2, 0}}
+
+	tests := []struct {
+		name    string
+		p       Point
+		polygon []Point
+		want    bool
+	}{
+		{"inside square", Point{0.5, 0.5}, square, true},
+		{"outside square", Point{1.5, 0.5}, square, false},
+		{"left of square", Point{0.5, -0.5}, square, false},
+		{"closed polygon repeats first vertex", Point{0.5, 0.5}, append(square, square[0]), true},
+		{"inside l shape", Point{0.5, 1.5}, lShape, true},
+		{"in l shape notch", Point{1.5, 1.5}, lShape, false},
+		{"fewer than three points", Point{0.5, 0.5}, square[:2], false},
+		{"empty polygon", Point{0.5, 0.5}, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := InPolygon(tt.p, tt.polygon); got != tt.want {
+				t.Fatalf("InPolygon(%v) = %v, want %v", tt.p, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInServiceArea(t *testing.T) {
+	center := Point{0, 0}
+	polygon := []Point{{1, 1}, {1, 2}, {2, 2}, {2, 1}}
+
+	tests := []struct {
+		name     string
+		p        Point
+		radiusKm float64
+		polygon  []Point
+		want     bool
+	}{
+		{"within radius", Point{0.5, 0}, 60, nil, true},
+		{"outside radius", Point{1, 0}, 60, nil, false},
+		{"polygon replaces radius", Point{1.5, 1.5}, 1, polygon, true},
+		{"center outside polygon", center, 1000, polygon, false},
+		{"degenerate polygon falls back to radius", Point{0.5, 0}, 60, polygon[:2], true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := InServiceArea(tt.p, center, tt.radiusKm, tt.polygon); got != tt.want {
+				t.Fatalf("InServiceArea(%v) = %v, want %v", tt.p, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReachKm(t *testing.T) {
+	center := Point{0, 0}
+	tests := []struct {
+		name     string
+		radiusKm float64
+		polygon  []Point
+		want     float64
+	}{
+		{"radius only", 5, nil, 5},
+		{"degenerate polygon", 5, []Point{{1, 0}, {2, 0}}, 5},
+		{"farthest vertex", 5, []Point{{1, 0}, {0, 2}, {-1, 0}}, DistanceKm(center, Point{0, 2})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ReachKm(center, tt.radiusKm, tt.polygon); math.Abs(got-tt.want) > 1e-9 {
+				t.Fatalf("ReachKm() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
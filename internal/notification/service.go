@@ -62,4 +62,28 @@ func (s *NotificationService) SendNotification(userID int, title string, body st
 
 	log.Printf("Successfully sent notification to user %d: %s", userID, title)
 	return nil
-}
\ No newline at end of file
+}
+// SendPartnerNotification mengirim notifikasi ke koleksi partner di Firestore
+func (s *NotificationService) SendPartnerNotification(partnerID int, title string, body string, notifType string) error {
+	ctx := context.Background()
+
+	notificationData := map[string]interface{}{
+		"title":     title,
+		"body":      body,
+		"type":      notifType,
+		"is_read":   false,
+		"timestamp": time.Now(),
+	}
+
+	// Koleksi terpisah dari user agar ID partner & user tidak bertabrakan
+	collectionPath := fmt.Sprintf("notifications_partner_%d", partnerID)
+	_, _, err := s.firestoreClient.Collection(collectionPath).Add(ctx, notificationData)
+
+	if err != nil {
+		log.Printf("Error sending notification to Firestore for partner %d: %v", partnerID, err)
+		return err
+	}
+
+	log.Printf("Successfully sent notification to partner %d: %s", partnerID, title)
+	return nil
+}
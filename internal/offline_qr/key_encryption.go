@@ -0,0 +1,60 @@
+package offline_qr
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+)
+
+// keyCipher mengenkripsi private key penanda tangan sebelum disimpan ke database
+// (AES-256-GCM). Kunci enkripsi diturunkan dari secret di environment sehingga
+// dump database saja tidak cukup untuk memalsukan kode QR offline.
+type keyCipher struct {
+	aead cipher.AEAD
+}
+
+// newKeyCipher membuat keyCipher dari secret OFFLINE_QR_KEY_ENCRYPTION_KEY
+func newKeyCipher(secret []byte) (*keyCipher, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("kunci enkripsi kode QR offline wajib diisi")
+	}
+	key := sha256.Sum256(secret)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &keyCipher{aead: aead}, nil
+}
+
+// seal mengenkripsi private key; hasilnya nonce diikuti ciphertext. ID kunci dipakai
+// sebagai additional data agar ciphertext tidak bisa dipindah ke baris kunci lain.
+func (c *keyCipher) seal(keyID string, privateKey ed25519.PrivateKey) ([]byte, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return c.aead.Seal(nonce, nonce, privateKey, []byte(keyID)), nil
+}
+
+// open mendekripsi private key hasil seal
+func (c *keyCipher) open(keyID string, sealed []byte) (ed25519.PrivateKey, error) {
+	nonceSize := c.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return nil, errors.New("private key terenkripsi tidak valid")
+	}
+	privateKey, err := c.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(keyID))
+	if err != nil {
+		return nil, err
+	}
+	if len(privateKey) != ed25519.PrivateKeySize {
+		return nil, errors.New("private key terenkripsi tidak valid")
+	}
+	return ed25519.PrivateKey(privateKey), nil
+}
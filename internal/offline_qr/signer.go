@@ -0,0 +1,483 @@
+package offline_qr
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// Format kode QR offline:
+//
+//	XQ1.<key_id>.<payload>.<signature>
+//
+// payload   = base64url(user_id uint32 BE | expires_at unix int64 BE | nonce 8 byte) -> 27 karakter
+// signature = base64url(Ed25519(private_key, "XQ1.<key_id>.<payload>"))              -> 86 karakter
+//
+// Aplikasi partner cukup menyimpan public key (lihat PublicKeys) untuk memverifikasi tanpa sinyal.
+const codePrefix = "XQ1"
+
+var encoding = base64.RawURLEncoding
+
+// SigningKey adalah pasangan kunci Ed25519 untuk menandatangani kode QR offline
+type SigningKey struct {
+	ID         string
+	PublicKey  ed25519.PublicKey
+	PrivateKey ed25519.PrivateKey
+	Status     string // "Active" atau "Retired"
+	CreatedAt  time.Time
+	RetiredAt  sql.NullTime
+}
+
+// PublicKey adalah data public key yang dibagikan ke aplikasi partner
+type PublicKey struct {
+	KeyID     string    `json:"key_id"`
+	Algorithm string    `json:"algorithm"`
+	PublicKey string    `json:"public_key"` // base64url tanpa padding
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Claims adalah isi kode QR offline yang sudah diverifikasi
+type Claims struct {
+	KeyID     string
+	UserID    int
+	ExpiresAt time.Time
+	Nonce     string // hex
+}
+
+// Signer menerbitkan dan memverifikasi kode QR offline. Kunci disimpan di tabel
+// deposit_qr_signing_keys; nonce yang sudah dipakai di deposit_qr_nonces.
+type Signer struct {
+	db   *sql.DB
+	keys keyStore
+}
+
+// keyStore menyimpan pasangan kunci penanda tangan kode QR offline
+type keyStore interface {
+	// activeKey mengambil kunci aktif terbaru, membuat kunci pertama jika belum ada
+	activeKey() (*SigningKey, error)
+	// activeKeys mengambil semua kunci aktif, terbaru dulu
+	activeKeys() ([]SigningKey, error)
+	// keyByID mengambil kunci berdasarkan ID, nil jika tidak ada
+	keyByID(keyID string) (*SigningKey, error)
+	// rotate mempensiunkan semua kunci aktif dan membuat kunci baru secara atomik
+	rotate() (*SigningKey, error)
+	// sealLegacyKeys mengenkripsi private key yang masih tersimpan plaintext
+	sealLegacyKeys() (int, error)
+}
+
+// NewSigner membuat instance Signer baru. encryptionKey dipakai untuk mengenkripsi
+// private key yang disimpan di database.
+func NewSigner(db *sql.DB, encryptionKey []byte) *Signer {
+	cipher, err := newKeyCipher(encryptionKey)
+	if err != nil {
+		log.Fatalf("Invalid offline QR key encryption key: %v", err)
+	}
+	return &Signer{db: db, keys: &postgresKeyStore{db: db, cipher: cipher}}
+}
+
+// SealLegacyKeys mengenkripsi private key lama yang masih tersimpan plaintext.
+// Dipanggil sekali saat aplikasi dijalankan.
+func (s *Signer) SealLegacyKeys() error {
+	sealed, err := s.keys.sealLegacyKeys()
+	if err != nil {
+		log.Printf("Error encrypting legacy offline QR signing keys: %v", err)
+		return errors.New("gagal mengenkripsi kunci kode QR offline")
+	}
+	if sealed > 0 {
+		log.Printf("Encrypted %d legacy offline QR signing key(s)", sealed)
+	}
+	return nil
+}
+
+// Issue membuat kode QR offline bertanda tangan untuk user
+func (s *Signer) Issue(userID int, validityDuration time.Duration) (string, *Claims, error) {
+	key, err := s.keys.activeKey()
+	if err != nil {
+		return "", nil, err
+	}
+
+	nonce := make([]byte, 8)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, errors.New("gagal membuat kode QR offline")
+	}
+	expiresAt := time.Now().Add(validityDuration).Truncate(time.Second)
+
+	payload := make([]byte, 20)
+	binary.BigEndian.PutUint32(payload[0:4], uint32(userID))
+	binary.BigEndian.PutUint64(payload[4:12], uint64(expiresAt.Unix()))
+	copy(payload[12:20], nonce)
+
+	signedPart := fmt.Sprintf("%s.%s.%s", codePrefix, key.ID, encoding.EncodeToString(payload))
+	signature := ed25519.Sign(key.PrivateKey, []byte(signedPart))
+	code := signedPart + "." + encoding.EncodeToString(signature)
+
+	claims := &Claims{KeyID: key.ID, UserID: userID, ExpiresAt: expiresAt, Nonce: hex.EncodeToString(nonce)}
+	return code, claims, nil
+}
+
+// Verify memeriksa format dan tanda tangan kode QR offline. Kode yang ditandatangani
+// kunci yang sudah dirotasi dianggap dicabut. Pengecekan expiry dilakukan pemanggil
+// karena bergantung pada waktu scan di lokasi partner.
+func (s *Signer) Verify(code string) (*Claims, error) {
+	parts := strings.Split(code, ".")
+	if len(parts) != 4 || parts[0] != codePrefix {
+		return nil, errors.New("format kode QR offline tidak valid")
+	}
+
+	payload, err := encoding.DecodeString(parts[2])
+	if err != nil || len(payload) != 20 {
+		return nil, errors.New("format kode QR offline tidak valid")
+	}
+	signature, err := encoding.DecodeString(parts[3])
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return nil, errors.New("format kode QR offline tidak valid")
+	}
+
+	key, err := s.keys.keyByID(parts[1])
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, errors.New("kunci kode QR offline tidak ditemukan")
+	}
+	if key.Status != "Active" {
+		return nil, errors.New("kode QR offline sudah dicabut")
+	}
+
+	signedPart := strings.Join(parts[:3], ".")
+	if !ed25519.Verify(key.PublicKey, []byte(signedPart), signature) {
+		return nil, errors.New("tanda tangan kode QR offline tidak valid")
+	}
+
+	return &Claims{
+		KeyID:     key.ID,
+		UserID:    int(binary.BigEndian.Uint32(payload[0:4])),
+		ExpiresAt: time.Unix(int64(binary.BigEndian.Uint64(payload[4:12])), 0),
+		Nonce:     hex.EncodeToString(payload[12:20]),
+	}, nil
+}
+
+// ConsumeNonce mencatat nonce kode QR offline sehingga satu kode hanya menghasilkan satu deposit.
+// Jika tx diisi, nonce dicatat di transaksi tersebut sehingga ikut batal bila deposit gagal disimpan.
+func (s *Signer) ConsumeNonce(tx *sql.Tx, claims *Claims, partnerID int) error {
+	query := `
+		INSERT INTO deposit_qr_nonces (nonce, key_id, user_id, partner_id, expires_at, used_at)
+		VALUES ($1, $2, $3, $4, $5, NOW())
+		ON CONFLICT (nonce) DO NOTHING`
+	var result sql.Result
+	var err error
+	if tx != nil {
+		result, err = tx.Exec(query, claims.Nonce, claims.KeyID, claims.UserID, partnerID, claims.ExpiresAt)
+	} else {
+		result, err = s.db.Exec(query, claims.Nonce, claims.KeyID, claims.UserID, partnerID, claims.ExpiresAt)
+	}
+	if err != nil {
+		log.Printf("Error recording offline QR nonce %s: %v", claims.Nonce, err)
+		return errors.New("gagal memvalidasi kode QR offline")
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return errors.New("kode QR offline sudah digunakan")
+	}
+	return nil
+}
+
+// PublicKeys mengembalikan public key aktif untuk di-cache aplikasi partner
+func (s *Signer) PublicKeys() ([]PublicKey, error) {
+	// Pastikan selalu ada minimal satu kunci aktif
+	if _, err := s.keys.activeKey(); err != nil {
+		return nil, err
+	}
+
+	activeKeys, err := s.keys.activeKeys()
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]PublicKey, 0, len(activeKeys))
+	for _, key := range activeKeys {
+		keys = append(keys, publicKeyOf(&key))
+	}
+	return keys, nil
+}
+
+// RotateKey membuat kunci baru dan mempensiunkan kunci lama. Semua kode QR offline
+// yang ditandatangani kunci lama otomatis tidak berlaku lagi.
+func (s *Signer) RotateKey() (*PublicKey, error) {
+	key, err := s.keys.rotate()
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Offline QR signing key rotated, new key ID: %s", key.ID)
+	publicKey := publicKeyOf(key)
+	return &publicKey, nil
+}
+
+// publicKeyOf menyusun data public key yang dibagikan ke aplikasi partner
+func publicKeyOf(key *SigningKey) PublicKey {
+	return PublicKey{
+		KeyID:     key.ID,
+		Algorithm: "Ed25519",
+		PublicKey: encoding.EncodeToString(key.PublicKey),
+		Status:    key.Status,
+		CreatedAt: key.CreatedAt,
+	}
+}
+
+// postgresKeyStore menyimpan kunci di tabel deposit_qr_signing_keys. Private key
+// disimpan terenkripsi (private_key_encrypted = TRUE); baris lama yang masih plaintext
+// dienkripsi ulang oleh sealLegacyKeys saat aplikasi dijalankan.
+type postgresKeyStore struct {
+	db     *sql.DB
+	cipher *keyCipher
+}
+
+// signingKeyLockID adalah kunci pg_advisory_xact_lock untuk pembuatan & rotasi kunci
+// sehingga beberapa replika yang start bersamaan tidak membuat kunci aktif ganda
+const signingKeyLockID = 7301001
+
+const selectKeyColumns = `SELECT id, public_key, private_key, private_key_encrypted, status, created_at, retired_at
+		FROM deposit_qr_signing_keys`
+
+func (k *postgresKeyStore) activeKey() (*SigningKey, error) {
+	query := selectKeyColumns + `
+		WHERE status = 'Active'
+		ORDER BY created_at DESC
+		LIMIT 1`
+	key, err := k.scanKey(k.db.QueryRow(query))
+	if err != nil {
+		log.Printf("Error getting active offline QR key: %v", err)
+		return nil, errors.New("gagal mengambil kunci kode QR offline")
+	}
+	if key != nil {
+		return key, nil
+	}
+	return k.createFirstKey()
+}
+
+// createFirstKey membuat kunci aktif pertama di bawah advisory lock. Replika lain yang
+// menunggu lock akan memakai kunci yang sudah dibuat alih-alih membuat kunci baru.
+func (k *postgresKeyStore) createFirstKey() (key *SigningKey, err error) {
+	tx, err := k.db.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction for first offline QR key: %v", err)
+		return nil, errors.New("gagal menyimpan kunci kode QR offline")
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if _, err = tx.Exec(`SELECT pg_advisory_xact_lock($1)`, signingKeyLockID); err != nil {
+		log.Printf("Error locking offline QR signing keys: %v", err)
+		return nil, errors.New("gagal menyimpan kunci kode QR offline")
+	}
+	query := selectKeyColumns + `
+		WHERE status = 'Active'
+		ORDER BY created_at DESC
+		LIMIT 1`
+	key, err = k.scanKey(tx.QueryRow(query))
+	if err != nil {
+		log.Printf("Error getting active offline QR key: %v", err)
+		return nil, errors.New("gagal mengambil kunci kode QR offline")
+	}
+	if key != nil {
+		return key, nil
+	}
+
+	key, err = k.insertNewKey(tx)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Created first offline QR signing key: %s", key.ID)
+	return key, nil
+}
+
+func (k *postgresKeyStore) activeKeys() ([]SigningKey, error) {
+	query := selectKeyColumns + `
+		WHERE status = 'Active'
+		ORDER BY created_at DESC`
+	rows, err := k.db.Query(query)
+	if err != nil {
+		log.Printf("Error listing offline QR public keys: %v", err)
+		return nil, errors.New("gagal mengambil public key")
+	}
+	defer rows.Close()
+
+	keys := []SigningKey{}
+	for rows.Next() {
+		key, err := k.scanKey(rows)
+		if err != nil {
+			log.Printf("Error scanning offline QR public key: %v", err)
+			return nil, errors.New("gagal mengambil public key")
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+func (k *postgresKeyStore) keyByID(keyID string) (*SigningKey, error) {
+	query := selectKeyColumns + `
+		WHERE id = $1`
+	key, err := k.scanKey(k.db.QueryRow(query, keyID))
+	if err != nil {
+		log.Printf("Error getting offline QR key %s: %v", keyID, err)
+		return nil, errors.New("gagal mengambil kunci kode QR offline")
+	}
+	return key, nil
+}
+
+func (k *postgresKeyStore) rotate() (key *SigningKey, err error) {
+	tx, err := k.db.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction for offline QR key rotation: %v", err)
+		return nil, errors.New("gagal merotasi kunci")
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if _, err = tx.Exec(`SELECT pg_advisory_xact_lock($1)`, signingKeyLockID); err != nil {
+		log.Printf("Error locking offline QR signing keys: %v", err)
+		return nil, errors.New("gagal merotasi kunci")
+	}
+	_, err = tx.Exec(`UPDATE deposit_qr_signing_keys SET status = 'Retired', retired_at = NOW() WHERE status = 'Active'`)
+	if err != nil {
+		log.Printf("Error retiring offline QR keys: %v", err)
+		return nil, errors.New("gagal merotasi kunci")
+	}
+	return k.insertNewKey(tx)
+}
+
+func (k *postgresKeyStore) sealLegacyKeys() (sealed int, err error) {
+	tx, err := k.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if _, err = tx.Exec(`SELECT pg_advisory_xact_lock($1)`, signingKeyLockID); err != nil {
+		return 0, err
+	}
+	rows, err := tx.Query(`SELECT id, private_key FROM deposit_qr_signing_keys WHERE private_key_encrypted = FALSE FOR UPDATE`)
+	if err != nil {
+		return 0, err
+	}
+	plaintext := map[string][]byte{}
+	for rows.Next() {
+		var id string
+		var privateKey []byte
+		if err = rows.Scan(&id, &privateKey); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		plaintext[id] = privateKey
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for id, privateKey := range plaintext {
+		encrypted, err := k.cipher.seal(id, ed25519.PrivateKey(privateKey))
+		if err != nil {
+			return 0, err
+		}
+		if _, err := tx.Exec(`UPDATE deposit_qr_signing_keys SET private_key = $1, private_key_encrypted = TRUE WHERE id = $2`, encrypted, id); err != nil {
+			return 0, err
+		}
+	}
+	return len(plaintext), nil
+}
+
+// rowScanner adalah *sql.Row atau *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanKey membaca satu baris deposit_qr_signing_keys dan mendekripsi private key, nil jika tidak ada
+func (k *postgresKeyStore) scanKey(row rowScanner) (*SigningKey, error) {
+	var key SigningKey
+	var publicKey, privateKey []byte
+	var encrypted bool
+	err := row.Scan(&key.ID, &publicKey, &privateKey, &encrypted, &key.Status, &key.CreatedAt, &key.RetiredAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	key.PublicKey = ed25519.PublicKey(publicKey)
+	key.PrivateKey = ed25519.PrivateKey(privateKey)
+	if encrypted {
+		if key.PrivateKey, err = k.cipher.open(key.ID, privateKey); err != nil {
+			return nil, fmt.Errorf("decrypt private key %s: %w", key.ID, err)
+		}
+	}
+	return &key, nil
+}
+
+// newSigningKey membuat pasangan kunci Ed25519 baru berstatus aktif (belum disimpan)
+func newSigningKey() (*SigningKey, error) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, errors.New("gagal membuat kunci kode QR offline")
+	}
+	idBytes := make([]byte, 4)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, errors.New("gagal membuat kunci kode QR offline")
+	}
+	return &SigningKey{ID: hex.EncodeToString(idBytes), PublicKey: publicKey, PrivateKey: privateKey, Status: "Active"}, nil
+}
+
+// insertNewKey membuat pasangan kunci Ed25519 baru dan menyimpannya (terenkripsi) sebagai kunci aktif
+func (k *postgresKeyStore) insertNewKey(tx *sql.Tx) (*SigningKey, error) {
+	key, err := newSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := k.cipher.seal(key.ID, key.PrivateKey)
+	if err != nil {
+		log.Printf("Error encrypting offline QR signing key: %v", err)
+		return nil, errors.New("gagal menyimpan kunci kode QR offline")
+	}
+	query := `
+		INSERT INTO deposit_qr_signing_keys (id, public_key, private_key, private_key_encrypted, status, created_at)
+		VALUES ($1, $2, $3, TRUE, 'Active', NOW())
+		RETURNING created_at`
+	if err := tx.QueryRow(query, key.ID, []byte(key.PublicKey), encrypted).Scan(&key.CreatedAt); err != nil {
+		log.Printf("Error inserting offline QR signing key: %v", err)
+		return nil, errors.New("gagal menyimpan kunci kode QR offline")
+	}
+	return key, nil
+}
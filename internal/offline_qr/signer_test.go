@@ -0,0 +1,232 @@
+package offline_qr
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// memoryKeyStore adalah keyStore di memori untuk pengujian tanpa database
+type memoryKeyStore struct {
+	keys []*SigningKey // Urut dari yang paling lama dibuat
+}
+
+func (m *memoryKeyStore) activeKey() (*SigningKey, error) {
+	for i := len(m.keys) - 1; i >= 0; i-- {
+		if m.keys[i].Status == "Active" {
+			return m.keys[i], nil
+		}
+	}
+	return m.add()
+}
+
+func (m *memoryKeyStore) activeKeys() ([]SigningKey, error) {
+	keys := []SigningKey{}
+	for i := len(m.keys) - 1; i >= 0; i-- {
+		if m.keys[i].Status == "Active" {
+			keys = append(keys, *m.keys[i])
+		}
+	}
+	return keys, nil
+}
+
+func (m *memoryKeyStore) keyByID(keyID string) (*SigningKey, error) {
+	for _, key := range m.keys {
+		if key.ID == keyID {
+			return key, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryKeyStore) rotate() (*SigningKey, error) {
+	for _, key := range m.keys {
+		key.Status = "Retired"
+	}
+	return m.add()
+}
+
+func (m *memoryKeyStore) sealLegacyKeys() (int, error) {
+	return 0, nil
+}
+
+func (m *memoryKeyStore) add() (*SigningKey, error) {
+	key, err := newSigningKey()
+	if err != nil {
+		return nil, err
+	}
+	key.CreatedAt = time.Now()
+	m.keys = append(m.keys, key)
+	return key, nil
+}
+
+func newTestSigner() *Signer {
+	return &Signer{keys: &memoryKeyStore{}}
+}
+
+func TestIssueVerifyRoundTrip(t *testing.T) {
+	signer := newTestSigner()
+	code, issued, err := signer.Issue(4242, 10*time.Minute)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if !strings.HasPrefix(code, codePrefix+".") || len(strings.Split(code, ".")) != 4 {
+		t.Fatalf("unexpected code format %q", code)
+	}
+
+	claims, err := signer.Verify(code)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if claims.UserID != 4242 || claims.KeyID != issued.KeyID || claims.Nonce != issued.Nonce {
+		t.Fatalf("claims = %+v, want %+v", claims, issued)
+	}
+	if !claims.ExpiresAt.Equal(issued.ExpiresAt) {
+		t.Fatalf("ExpiresAt = %v, want %v", claims.ExpiresAt, issued.ExpiresAt)
+	}
+
+	_, second, err := signer.Issue(4242, 10*time.Minute)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	if second.Nonce == issued.Nonce {
+		t.Fatalf("two codes share nonce %s", issued.Nonce)
+	}
+}
+
+func TestVerifyRejectsInvalidCodes(t *testing.T) {
+	signer := newTestSigner()
+	code, _, err := signer.Issue(7, time.Minute)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	parts := strings.Split(code, ".")
+	otherCode, _, err := newTestSigner().Issue(7, time.Minute)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	otherParts := strings.Split(otherCode, ".")
+	code8, _, err := signer.Issue(8, time.Minute)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+	payload8 := strings.Split(code8, ".")[2]
+
+	tests := []struct {
+		name string
+		code string
+		want string
+	}{
+		{"empty", "", "format kode QR offline tidak valid"},
+		{"wrong prefix", "XQ2." + strings.Join(parts[1:], "."), "format kode QR offline tidak valid"},
+		{"missing part", strings.Join(parts[:3], "."), "format kode QR offline tidak valid"},
+		{"short payload", strings.Join([]string{parts[0], parts[1], parts[2][:10], parts[3]}, "."), "format kode QR offline tidak valid"},
+		{"bad signature encoding", strings.Join([]string{parts[0], parts[1], parts[2], "!!"}, "."), "format kode QR offline tidak valid"},
+		{"unknown key", strings.Join([]string{parts[0], "deadbeef", parts[2], parts[3]}, "."), "kunci kode QR offline tidak ditemukan"},
+		{"swapped payload", strings.Join([]string{parts[0], parts[1], payload8, parts[3]}, "."), "tanda tangan kode QR offline tidak valid"},
+		{"signature from other key", strings.Join([]string{parts[0], parts[1], parts[2], otherParts[3]}, "."), "tanda tangan kode QR offline tidak valid"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := signer.Verify(tt.code)
+			if err == nil || err.Error() != tt.want {
+				t.Fatalf("Verify error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestRotateKeyRevokesOldCodes(t *testing.T) {
+	signer := newTestSigner()
+	oldCode, oldClaims, err := signer.Issue(1, time.Minute)
+	if err != nil {
+		t.Fatalf("Issue: %v", err)
+	}
+
+	rotated, err := signer.RotateKey()
+	if err != nil {
+		t.Fatalf("RotateKey: %v", err)
+	}
+	if rotated.KeyID == oldClaims.KeyID || rotated.Status != "Active" || rotated.Algorithm != "Ed25519" {
+		t.Fatalf("unexpected rotated key %+v", rotated)
+	}
+
+	if _, err := signer.Verify(oldCode); err == nil || err.Error() != "kode QR offline sudah dicabut" {
+		t.Fatalf("Verify old code error = %v, want revoked", err)
+	}
+
+	newCode, newClaims, err := signer.Issue(1, time.Minute)
+	if err != nil {
+		t.Fatalf("Issue after rotation: %v", err)
+	}
+	if newClaims.KeyID != rotated.KeyID {
+		t.Fatalf("new code signed with %s, want %s", newClaims.KeyID, rotated.KeyID)
+	}
+	if _, err := signer.Verify(newCode); err != nil {
+		t.Fatalf("Verify new code: %v", err)
+	}
+
+	keys, err := signer.PublicKeys()
+	if err != nil {
+		t.Fatalf("PublicKeys: %v", err)
+	}
+	if len(keys) != 1 || keys[0].KeyID != rotated.KeyID || keys[0].PublicKey != rotated.PublicKey {
+		t.Fatalf("PublicKeys = %+v, want only %s", keys, rotated.KeyID)
+	}
+}
+
+func TestKeyCipherRoundTrip(t *testing.T) {
+	key, err := newSigningKey()
+	if err != nil {
+		t.Fatalf("newSigningKey: %v", err)
+	}
+	c, err := newKeyCipher([]byte("rahasia"))
+	if err != nil {
+		t.Fatalf("newKeyCipher: %v", err)
+	}
+	sealed, err := c.seal(key.ID, key.PrivateKey)
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if bytes.Contains(sealed, key.PrivateKey.Seed()) {
+		t.Fatal("sealed key contains plaintext seed")
+	}
+	opened, err := c.open(key.ID, sealed)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if !opened.Equal(key.PrivateKey) {
+		t.Fatal("opened key differs from original")
+	}
+
+	otherCipher, err := newKeyCipher([]byte("rahasia lain"))
+	if err != nil {
+		t.Fatalf("newKeyCipher: %v", err)
+	}
+	tampered := append([]byte{}, sealed...)
+	tampered[len(tampered)-1] ^= 1
+
+	tests := []struct {
+		name   string
+		cipher *keyCipher
+		keyID  string
+		sealed []byte
+	}{
+		{"wrong secret", otherCipher, key.ID, sealed},
+		{"moved to other key row", c, "deadbeef", sealed},
+		{"tampered ciphertext", c, key.ID, tampered},
+		{"truncated", c, key.ID, sealed[:4]},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := tt.cipher.open(tt.keyID, tt.sealed); err == nil {
+				t.Fatal("open succeeded, want error")
+			}
+		})
+	}
+
+	if _, err := newKeyCipher(nil); err == nil {
+		t.Fatal("newKeyCipher with empty secret succeeded")
+	}
+}
@@ -700,7 +700,17 @@ func (r *AdminRepository) GetXetorPartnerByID(id int) (*admin.XetorPartner, erro
 
 // UpdateXetorPartnerStatus - Fungsi khusus untuk admin approve/reject
 func (r *AdminRepository) UpdateXetorPartnerStatus(id int, status string) error {
-	query := `UPDATE xetor_partners SET status = $1, updated_at = NOW() WHERE id = $2`
+	// Perubahan manual oleh admin ikut dicatat di riwayat status partner (tanpa pengajuan onboarding)
+	query := `
+		WITH prev AS (
+			SELECT id, partner_id, status FROM xetor_partners WHERE id = $2 FOR UPDATE
+		), upd AS (
+			UPDATE xetor_partners xp SET status = $1, updated_at = NOW()
+			FROM prev WHERE xp.id = prev.id
+			RETURNING prev.partner_id, prev.status AS from_status
+		)
+		INSERT INTO partner_status_histories (partner_id, from_status, to_status, actor_type)
+		SELECT partner_id, from_status, $1, 'admin' FROM upd`
 	result, err := r.db.Exec(query, status, id)
 	if err != nil { log.Printf("Error updating xetor partner status ID %d: %v", id, err); return err }
 	rowsAffected, _ := result.RowsAffected(); if rowsAffected == 0 { return sql.ErrNoRows }
@@ -718,6 +728,179 @@ func (r *AdminRepository) DeleteXetorPartner(id int) error {
 	return nil
 }
 
+// --- Recycler CRUD ---
+
+const recyclerSelect = `
+	SELECT id, name, COALESCE(contact_name, ''), COALESCE(phone, ''), COALESCE(email, ''),
+	       COALESCE(address, ''), COALESCE(city, ''), status, created_at, updated_at
+	FROM recyclers`
+
+func scanRecycler(scanner interface{ Scan(dest ...interface{}) error }) (*admin.Recycler, error) {
+	var rc admin.Recycler
+	err := scanner.Scan(&rc.ID, &rc.Name, &rc.ContactName, &rc.Phone, &rc.Email, &rc.Address, &rc.City,
+		&rc.Status, &rc.CreatedAt, &rc.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &rc, nil
+}
+
+func (r *AdminRepository) CreateRecycler(rc *admin.Recycler) error {
+	query := `
+		INSERT INTO recyclers (name, contact_name, phone, email, address, city, status)
+		VALUES ($1, NULLIF($2, ''), NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), NULLIF($6, ''), $7)
+		RETURNING id, created_at, updated_at`
+
+	if rc.Status == "" {
+		rc.Status = admin.RecyclerStatusActive
+	}
+	err := r.db.QueryRow(query, rc.Name, rc.ContactName, rc.Phone, rc.Email, rc.Address, rc.City, rc.Status).
+		Scan(&rc.ID, &rc.CreatedAt, &rc.UpdatedAt)
+	if err != nil {
+		log.Printf("Error creating recycler: %v", err)
+		return err
+	}
+	log.Printf("Recycler created with ID: %d", rc.ID)
+	return nil
+}
+
+// GetAllRecyclers mengambil recycler, status kosong berarti semua
+func (r *AdminRepository) GetAllRecyclers(status string) ([]admin.Recycler, error) {
+	rows, err := r.db.Query(recyclerSelect+` WHERE ($1 = '' OR status = $1) ORDER BY name ASC`, status)
+	if err != nil {
+		log.Printf("Error getting recyclers: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	recyclers := []admin.Recycler{}
+	for rows.Next() {
+		rc, err := scanRecycler(rows)
+		if err != nil {
+			log.Printf("Error scanning recycler row: %v", err)
+			return nil, err
+		}
+		recyclers = append(recyclers, *rc)
+	}
+	return recyclers, rows.Err()
+}
+
+func (r *AdminRepository) GetRecyclerByID(id int) (*admin.Recycler, error) {
+	rc, err := scanRecycler(r.db.QueryRow(recyclerSelect+` WHERE id = $1`, id))
+	if err != nil {
+		if err == sql.ErrNoRows { return nil, nil }
+		log.Printf("Error getting recycler by ID %d: %v", id, err)
+		return nil, err
+	}
+	return rc, nil
+}
+
+func (r *AdminRepository) UpdateRecycler(id int, req *admin.UpdateRecyclerRequest) error {
+	fields := []string{}
+	args := []interface{}{}
+	argId := 1
+
+	if req.Name != "" { fields = append(fields, fmt.Sprintf("name = $%d", argId)); args = append(args, req.Name); argId++ }
+	if req.ContactName != "" { fields = append(fields, fmt.Sprintf("contact_name = $%d", argId)); args = append(args, req.ContactName); argId++ }
+	if req.Phone != "" { fields = append(fields, fmt.Sprintf("phone = $%d", argId)); args = append(args, req.Phone); argId++ }
+	if req.Email != "" { fields = append(fields, fmt.Sprintf("email = $%d", argId)); args = append(args, req.Email); argId++ }
+	if req.Address != "" { fields = append(fields, fmt.Sprintf("address = $%d", argId)); args = append(args, req.Address); argId++ }
+	if req.City != "" { fields = append(fields, fmt.Sprintf("city = $%d", argId)); args = append(args, req.City); argId++ }
+	if req.Status != "" { fields = append(fields, fmt.Sprintf("status = $%d", argId)); args = append(args, req.Status); argId++ }
+
+	if len(fields) == 0 { return nil }
+	args = append(args, id)
+	query := fmt.Sprintf("UPDATE recyclers SET %s, updated_at = NOW() WHERE id = $%d", strings.Join(fields, ", "), argId)
+
+	result, err := r.db.Exec(query, args...)
+	if err != nil { log.Printf("Error updating recycler ID %d: %v", id, err); return err }
+	rowsAffected, _ := result.RowsAffected(); if rowsAffected == 0 { return sql.ErrNoRows }
+	log.Printf("Recycler updated for ID: %d", id)
+	return nil
+}
+
+func (r *AdminRepository) DeleteRecycler(id int) error {
+	result, err := r.db.Exec(`DELETE FROM recyclers WHERE id = $1`, id)
+	if err != nil {
+		if strings.Contains(err.Error(), "violates foreign key constraint") {
+			return errors.New("recycler sudah memiliki transaksi penjualan, ubah status menjadi Inactive")
+		}
+		log.Printf("Error deleting recycler ID %d: %v", id, err); return err
+	}
+	rowsAffected, _ := result.RowsAffected(); if rowsAffected == 0 { return sql.ErrNoRows }
+	log.Printf("Recycler deleted for ID: %d", id)
+	return nil
+}
+
+// --- National Holiday Functions ---
+
+// GetNationalHolidays mengambil libur nasional, year = 0 berarti semua tahun
+func (r *AdminRepository) GetNationalHolidays(year int) ([]admin.NationalHoliday, error) {
+	query := `
+		SELECT to_char(date, 'YYYY-MM-DD'), name, created_at, updated_at
+		FROM national_holidays
+		WHERE ($1 = 0 OR EXTRACT(YEAR FROM date) = $1)
+		ORDER BY date`
+	rows, err := r.db.Query(query, year)
+	if err != nil {
+		log.Printf("Error getting national holidays: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	holidays := []admin.NationalHoliday{}
+	for rows.Next() {
+		var h admin.NationalHoliday
+		if err := rows.Scan(&h.Date, &h.Name, &h.CreatedAt, &h.UpdatedAt); err != nil {
+			log.Printf("Error scanning national holiday row: %v", err)
+			return nil, err
+		}
+		holidays = append(holidays, h)
+	}
+	return holidays, rows.Err()
+}
+
+// UpsertNationalHolidays menyimpan kalender libur nasional dalam satu transaksi (upsert per tanggal)
+func (r *AdminRepository) UpsertNationalHolidays(holidays []admin.NationalHolidayInput) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		log.Printf("Error starting tx for national holiday import: %v", err)
+		return errors.New("gagal memulai transaksi")
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	query := `
+		INSERT INTO national_holidays (date, name, created_at, updated_at)
+		VALUES ($1, $2, NOW(), NOW())
+		ON CONFLICT (date) DO UPDATE SET name = EXCLUDED.name, updated_at = NOW()`
+	for _, h := range holidays {
+		if _, err = tx.Exec(query, h.Date, h.Name); err != nil {
+			log.Printf("Error upserting national holiday %s: %v", h.Date, err)
+			return errors.New("gagal menyimpan libur nasional")
+		}
+	}
+	log.Printf("National holidays imported: %d rows", len(holidays))
+	return nil
+}
+
+// DeleteNationalHoliday menghapus satu tanggal libur nasional
+func (r *AdminRepository) DeleteNationalHoliday(date string) error {
+	result, err := r.db.Exec(`DELETE FROM national_holidays WHERE date = $1`, date)
+	if err != nil { log.Printf("Error deleting national holiday %s: %v", date, err); return err }
+	rowsAffected, _ := result.RowsAffected(); if rowsAffected == 0 { return sql.ErrNoRows }
+	log.Printf("National holiday deleted: %s", date)
+	return nil
+}
+
 // --- Waste Detail Xpoin Recalculation ---
 
 // RecalculateAndUpdateWasteDetailXpoin menghitung rata-rata xpoin dari partner
@@ -0,0 +1,251 @@
+// internal/repository/deposit_adjustment_repo.go
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+
+	"xetor.id/backend/internal/domain/partner"
+)
+
+// GetDepositForAdjustment mengambil deposit beserta item aktifnya untuk void/koreksi, nil jika tidak ada
+func (r *PartnerRepository) GetDepositForAdjustment(depositID int) (*partner.DepositAdjustmentTarget, error) {
+	queryHeader := `
+		SELECT id, partner_id, user_id, user_deposit_history_id, transaction_time, voided_at
+		FROM partner_deposit_histories
+		WHERE id = $1`
+	var target partner.DepositAdjustmentTarget
+	err := r.db.QueryRow(queryHeader, depositID).Scan(
+		&target.ID, &target.PartnerID, &target.UserID, &target.UserDepositHistoryID, &target.TransactionTime, &target.VoidedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("Error getting deposit ID %d for adjustment: %v", depositID, err)
+		return nil, err
+	}
+
+	queryDetails := `
+		SELECT pdd.id, pdd.waste_detail_id, pdd.waste_weight, pdd.xpoin, pdd.unit, pdd.quantity, pwpv.xpoin
+		FROM partner_deposit_history_details pdd
+		LEFT JOIN partner_waste_price_versions pwpv ON pwpv.id = pdd.partner_waste_price_version_id
+		WHERE pdd.partner_deposit_history_id = $1 AND pdd.status <> 'Voided'
+		ORDER BY pdd.id`
+	rows, err := r.db.Query(queryDetails, depositID)
+	if err != nil {
+		log.Printf("Error getting details of deposit ID %d for adjustment: %v", depositID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	target.Details = []partner.DepositAdjustmentTargetDetail{}
+	for rows.Next() {
+		var d partner.DepositAdjustmentTargetDetail
+		if err := rows.Scan(&d.ID, &d.WasteDetailID, &d.Weight, &d.Xpoin, &d.Unit, &d.Quantity, &d.PriceXpoin); err != nil {
+			log.Printf("Error scanning deposit detail for adjustment: %v", err)
+			return nil, err
+		}
+		target.Details = append(target.Details, d)
+	}
+	return &target, rows.Err()
+}
+
+// ExecuteDepositAdjustmentTransaction menerapkan void/koreksi deposit dalam satu transaksi:
+// item & header deposit, wallet partner & user (Xpoin kompensasi), statistik, dan jejak audit.
+func (r *PartnerRepository) ExecuteDepositAdjustmentTransaction(args partner.ArgsDepositAdjustment) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		log.Printf("Error starting tx for deposit adjustment: %v", err)
+		return errors.New("gagal memulai transaksi")
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+			log.Printf("Rolling back deposit adjustment tx due to error: %v", err)
+		} else {
+			err = tx.Commit()
+			if err != nil {
+				log.Printf("Error committing deposit adjustment tx: %v", err)
+			}
+		}
+	}()
+
+	target := args.Target
+	isVoid := args.Type == "void"
+
+	// 1. Kunci header agar void/koreksi tidak berjalan bersamaan
+	var voidedAt sql.NullTime
+	err = tx.QueryRow(`SELECT voided_at FROM partner_deposit_histories WHERE id = $1 FOR UPDATE`, target.ID).Scan(&voidedAt)
+	if err != nil {
+		return errors.New("gagal mengunci data deposit")
+	}
+	if voidedAt.Valid {
+		return errors.New("deposit sudah dibatalkan")
+	}
+
+	// 2. Update item (dengan cek nilai lama) & catat audit
+	queryDetail := `
+		UPDATE partner_deposit_history_details
+		SET waste_weight = $1, xpoin = $2, status = CASE WHEN $3 THEN 'Voided' ELSE status END,
+		    quantity = COALESCE($8, quantity)
+		WHERE id = $4 AND partner_deposit_history_id = $5 AND waste_weight = $6 AND xpoin = $7 AND status <> 'Voided'`
+	queryAudit := `
+		INSERT INTO deposit_adjustments
+			(partner_deposit_history_id, detail_id, type, actor_type, actor_id, reason, old_weight, new_weight, old_xpoin, new_xpoin,
+			 old_quantity, new_quantity)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`
+
+	weightDelta, xpoinDelta := 0.0, 0
+	energyDelta, co2Delta, waterDelta, treeDelta := 0.0, 0.0, 0.0, 0
+	for _, line := range args.Lines {
+		result, errExec := tx.Exec(queryDetail, line.NewWeight, line.NewXpoin, isVoid, line.DetailID, target.ID, line.OldWeight, line.OldXpoin, line.NewQuantity)
+		if errExec != nil {
+			log.Printf("Error updating detail ID %d of deposit ID %d: %v", line.DetailID, target.ID, errExec)
+			return errors.New("gagal mengupdate item deposit")
+		}
+		if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+			return errors.New("item deposit sudah berubah, muat ulang data")
+		}
+		// Jumlah item pcs ikut dicatat; void mengosongkan jumlahnya
+		newQuantity := line.NewQuantity
+		if !newQuantity.Valid && line.OldQuantity.Valid {
+			newQuantity = line.OldQuantity
+			if isVoid {
+				newQuantity = sql.NullInt32{Int32: 0, Valid: true}
+			}
+		}
+		_, err = tx.Exec(queryAudit, target.ID, line.DetailID, args.Type, args.ActorType, args.ActorID, args.Reason,
+			line.OldWeight, line.NewWeight, line.OldXpoin, line.NewXpoin, line.OldQuantity, newQuantity)
+		if err != nil {
+			return errors.New("gagal mencatat audit deposit")
+		}
+
+		// Stok fisik partner mengikuti perubahan item (void mengeluarkan seluruh item dari stok)
+		for _, d := range target.Details {
+			if d.ID != line.DetailID || !d.WasteDetailID.Valid {
+				continue
+			}
+			quantityChange := 0
+			if isVoid {
+				quantityChange = -int(d.Quantity.Int32)
+			} else if line.NewQuantity.Valid {
+				quantityChange = int(line.NewQuantity.Int32 - d.Quantity.Int32)
+			}
+			movementType := partner.InventoryMovementDepositCorrection
+			if isVoid {
+				movementType = partner.InventoryMovementDepositVoid
+			}
+			err = applyInventoryMovement(tx, partner.InventoryMovement{
+				PartnerID:               target.PartnerID,
+				WasteDetailID:           int(d.WasteDetailID.Int32),
+				Type:                    movementType,
+				WeightChange:            line.NewWeight - line.OldWeight,
+				QuantityChange:          quantityChange,
+				PartnerDepositHistoryID: sql.NullInt32{Int32: int32(target.ID), Valid: true},
+				Reason:                  sql.NullString{String: args.Reason, Valid: args.Reason != ""},
+			}, false)
+			if err != nil {
+				return err
+			}
+		}
+
+		weightDelta += line.NewWeight - line.OldWeight
+		xpoinDelta += line.NewXpoin - line.OldXpoin
+		energyDelta += line.EnergyDelta
+		co2Delta += line.CO2Delta
+		waterDelta += line.WaterDelta
+		treeDelta += line.TreeDelta
+	}
+
+	// 3. Xpoin kompensasi: xpoinDelta > 0 berarti partner membayar tambahan ke user,
+	// xpoinDelta < 0 berarti Xpoin ditarik dari user dan dikembalikan ke partner
+	result, err := tx.Exec(`UPDATE partner_wallets SET xpoin = xpoin - $1, updated_at = NOW() WHERE partner_id = $2 AND ($1 <= 0 OR xpoin >= $1)`, xpoinDelta, target.PartnerID)
+	if err != nil {
+		return errors.New("gagal mengupdate xpoin partner")
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return errors.New("xpoin partner tidak mencukupi")
+	}
+	// Saldo Xpoin user boleh negatif jika poin sudah terpakai (sama seperti refund topup)
+	_, err = tx.Exec(`UPDATE user_wallets SET xpoin = xpoin + $1, updated_at = NOW() WHERE user_id = $2`, xpoinDelta, target.UserID)
+	if err != nil {
+		return errors.New("gagal mengupdate xpoin user")
+	}
+
+	// 4. Statistik user & partner
+	_, err = tx.Exec(`
+		UPDATE user_statistics
+		SET waste = waste + $1, energy = energy + $2, co2 = co2 + $3, water = water + $4, tree = tree + $5, updated_at = NOW()
+		WHERE user_id = $6`, weightDelta, energyDelta, co2Delta, waterDelta, treeDelta, target.UserID)
+	if err != nil {
+		return errors.New("gagal update statistik user")
+	}
+	transactionDelta := 0
+	if isVoid {
+		transactionDelta = -1
+	}
+	_, err = tx.Exec(`UPDATE partner_statistics SET waste = waste + $1, transaction = transaction + $2, updated_at = NOW() WHERE partner_id = $3`,
+		weightDelta, transactionDelta, target.PartnerID)
+	if err != nil {
+		return errors.New("gagal update statistik partner")
+	}
+
+	// 5. Header deposit partner & riwayat deposit user
+	_, err = tx.Exec(`
+		UPDATE partner_deposit_histories
+		SET total_weight = total_weight + $1, total_xpoin = total_xpoin + $2,
+		    voided_at = CASE WHEN $3 THEN NOW() ELSE voided_at END, updated_at = NOW()
+		WHERE id = $4`, weightDelta, xpoinDelta, isVoid, target.ID)
+	if err != nil {
+		return errors.New("gagal mengupdate header deposit")
+	}
+	if target.UserDepositHistoryID.Valid {
+		_, err = tx.Exec(`
+			UPDATE user_deposit_histories
+			SET total_points = total_points + $1, status = CASE WHEN $2 THEN 'Voided' ELSE status END
+			WHERE id = $3`, xpoinDelta, isVoid, target.UserDepositHistoryID.Int32)
+		if err != nil {
+			return errors.New("gagal mengupdate riwayat deposit pengguna")
+		}
+	}
+
+	log.Printf("Deposit %d adjusted (%s by %s): weight %+.2f kg, xpoin %+d", target.ID, args.Type, args.ActorType, weightDelta, xpoinDelta)
+	return nil
+}
+
+// GetDepositAdjustments mengambil jejak audit void/koreksi sebuah deposit
+func (r *PartnerRepository) GetDepositAdjustments(depositID int) ([]partner.DepositAdjustment, error) {
+	query := `
+		SELECT id, partner_deposit_history_id, detail_id, type, actor_type, actor_id, reason,
+		       old_weight, new_weight, old_quantity, new_quantity, old_xpoin, new_xpoin, created_at
+		FROM deposit_adjustments
+		WHERE partner_deposit_history_id = $1
+		ORDER BY created_at, id`
+	rows, err := r.db.Query(query, depositID)
+	if err != nil {
+		log.Printf("Error getting adjustments for deposit ID %d: %v", depositID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	adjustments := []partner.DepositAdjustment{}
+	for rows.Next() {
+		var a partner.DepositAdjustment
+		var oldWeight, newWeight float64
+		if err := rows.Scan(&a.ID, &a.PartnerDepositHistoryID, &a.DetailID, &a.Type, &a.ActorType, &a.ActorID, &a.Reason,
+			&oldWeight, &newWeight, &a.OldQuantity, &a.NewQuantity, &a.OldXpoin, &a.NewXpoin, &a.CreatedAt); err != nil {
+			log.Printf("Error scanning deposit adjustment row: %v", err)
+			return nil, err
+		}
+		a.OldWeight = fmt.Sprintf("%.2f", oldWeight)
+		a.NewWeight = fmt.Sprintf("%.2f", newWeight)
+		adjustments = append(adjustments, a)
+	}
+	return adjustments, rows.Err()
+}
@@ -0,0 +1,294 @@
+// internal/repository/deposit_dispute_repo.go
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"xetor.id/backend/internal/domain/user"
+)
+
+// Sengketa deposit dibaca oleh user, partner, dan admin; query ketiganya disatukan di file ini.
+
+const depositDisputeSelect = `
+	SELECT dd.id, dd.partner_deposit_history_id, dd.user_deposit_history_id, dd.user_id, u.fullname,
+	       dd.partner_id, p.business_name, dd.status, dd.reason, dd.photos, dd.response_deadline,
+	       dd.partner_response, dd.partner_responded_at, dd.resolution_note, dd.adjustment_type,
+	       dd.resolved_at, dd.created_at, dd.updated_at
+	FROM deposit_disputes dd
+	LEFT JOIN partners p ON p.id = dd.partner_id
+	LEFT JOIN users u ON u.id = dd.user_id`
+
+// scanDepositDispute membaca satu baris hasil depositDisputeSelect
+func scanDepositDispute(scanner interface{ Scan(dest ...interface{}) error }) (*user.DepositDispute, error) {
+	var d user.DepositDispute
+	var photosRaw []byte
+	var respondedAt, resolvedAt sql.NullTime
+	err := scanner.Scan(
+		&d.ID, &d.PartnerDepositHistoryID, &d.UserDepositHistoryID, &d.UserID, &d.UserName,
+		&d.PartnerID, &d.PartnerName, &d.Status, &d.Reason, &photosRaw, &d.ResponseDeadline,
+		&d.PartnerResponse, &respondedAt, &d.ResolutionNote, &d.AdjustmentType,
+		&resolvedAt, &d.CreatedAt, &d.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	d.Photos = []string{}
+	if err := json.Unmarshal(photosRaw, &d.Photos); err != nil {
+		return nil, fmt.Errorf("gagal membaca foto sengketa deposit: %w", err)
+	}
+	if respondedAt.Valid {
+		d.PartnerRespondedAt = &respondedAt.Time
+	}
+	if resolvedAt.Valid {
+		d.ResolvedAt = &resolvedAt.Time
+	}
+	return &d, nil
+}
+
+// queryDepositDisputes menjalankan query daftar sengketa deposit
+func queryDepositDisputes(db *sql.DB, query string, args ...interface{}) ([]user.DepositDispute, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	disputes := []user.DepositDispute{}
+	for rows.Next() {
+		d, err := scanDepositDispute(rows)
+		if err != nil {
+			return nil, err
+		}
+		disputes = append(disputes, *d)
+	}
+	return disputes, rows.Err()
+}
+
+// listDepositDisputes mengambil sengketa milik user/partner, opsional difilter status
+func listDepositDisputes(db *sql.DB, ownerColumn string, ownerID int, status string) ([]user.DepositDispute, error) {
+	query := depositDisputeSelect + fmt.Sprintf(" WHERE dd.%s = $1", ownerColumn)
+	args := []interface{}{ownerID}
+	if status != "" {
+		query += ` AND dd.status = $2`
+		args = append(args, status)
+	}
+	query += ` ORDER BY dd.created_at DESC`
+
+	disputes, err := queryDepositDisputes(db, query, args...)
+	if err != nil {
+		log.Printf("Error getting deposit disputes for %s %d: %v", ownerColumn, ownerID, err)
+		return nil, err
+	}
+	return disputes, nil
+}
+
+// getDepositDispute mengambil satu sengketa, opsional dibatasi pemilik; nil jika tidak ada
+func getDepositDispute(db *sql.DB, disputeID int, ownerColumn string, ownerID int) (*user.DepositDispute, error) {
+	query := depositDisputeSelect + ` WHERE dd.id = $1`
+	args := []interface{}{disputeID}
+	if ownerColumn != "" {
+		query += fmt.Sprintf(" AND dd.%s = $2", ownerColumn)
+		args = append(args, ownerID)
+	}
+	d, err := scanDepositDispute(db.QueryRow(query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("Error getting deposit dispute ID %d: %v", disputeID, err)
+		return nil, err
+	}
+	return d, nil
+}
+
+// --- Sisi User ---
+
+// FindDepositForDispute mencari deposit dari ID riwayat deposit user, nil jika tidak ada
+func (r *UserRepository) FindDepositForDispute(userDepositHistoryID, userID int) (*user.DisputableDeposit, error) {
+	query := `
+		SELECT pdh.id, pdh.partner_id, pdh.transaction_time, pdh.voided_at IS NOT NULL
+		FROM partner_deposit_histories pdh
+		JOIN user_deposit_histories udh ON udh.id = pdh.user_deposit_history_id
+		WHERE udh.id = $1 AND udh.user_id = $2`
+	var d user.DisputableDeposit
+	err := r.db.QueryRow(query, userDepositHistoryID, userID).Scan(&d.PartnerDepositHistoryID, &d.PartnerID, &d.TransactionTime, &d.Voided)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("Error finding deposit %d of user ID %d for dispute: %v", userDepositHistoryID, userID, err)
+		return nil, err
+	}
+	return &d, nil
+}
+
+// CreateDepositDispute menyimpan sengketa baru berstatus Open
+func (r *UserRepository) CreateDepositDispute(dispute *user.DepositDispute) error {
+	photosJSON, err := json.Marshal(dispute.Photos)
+	if err != nil {
+		return errors.New("gagal menyimpan sengketa deposit")
+	}
+	query := `
+		INSERT INTO deposit_disputes
+			(partner_deposit_history_id, user_deposit_history_id, user_id, partner_id, status, reason, photos, response_deadline)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, updated_at`
+	dispute.Status = user.DepositDisputeOpen
+	err = r.db.QueryRow(query, dispute.PartnerDepositHistoryID, dispute.UserDepositHistoryID, dispute.UserID, dispute.PartnerID,
+		dispute.Status, dispute.Reason, photosJSON, dispute.ResponseDeadline,
+	).Scan(&dispute.ID, &dispute.CreatedAt, &dispute.UpdatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return errors.New("deposit ini sudah memiliki sengketa yang sedang diproses")
+		}
+		log.Printf("Error creating deposit dispute for deposit ID %d: %v", dispute.PartnerDepositHistoryID, err)
+		return errors.New("gagal menyimpan sengketa deposit")
+	}
+	return nil
+}
+
+// GetDepositDisputesByUserID mengambil sengketa milik user, opsional difilter status
+func (r *UserRepository) GetDepositDisputesByUserID(userID int, status string) ([]user.DepositDispute, error) {
+	return listDepositDisputes(r.db, "user_id", userID, status)
+}
+
+// GetDepositDisputeByIDForUser mengambil satu sengketa milik user, nil jika tidak ada
+func (r *UserRepository) GetDepositDisputeByIDForUser(disputeID, userID int) (*user.DepositDispute, error) {
+	return getDepositDispute(r.db, disputeID, "user_id", userID)
+}
+
+// --- Sisi Partner & Admin ---
+
+// GetDepositDisputesByPartnerID mengambil sengketa atas deposit partner, opsional difilter status
+func (r *PartnerRepository) GetDepositDisputesByPartnerID(partnerID int, status string) ([]user.DepositDispute, error) {
+	return listDepositDisputes(r.db, "partner_id", partnerID, status)
+}
+
+// GetDepositDisputeByID mengambil satu sengketa tanpa filter pemilik, nil jika tidak ada
+func (r *PartnerRepository) GetDepositDisputeByID(disputeID int) (*user.DepositDispute, error) {
+	return getDepositDispute(r.db, disputeID, "", 0)
+}
+
+// RespondDepositDispute menyimpan tanggapan partner selama sengketa belum diputuskan admin.
+// Mengembalikan sql.ErrNoRows jika sengketa sudah diputuskan atau sudah ditanggapi.
+func (r *PartnerRepository) RespondDepositDispute(disputeID, partnerID int, response string) error {
+	query := `
+		UPDATE deposit_disputes
+		SET status = $1, partner_response = $2, partner_responded_at = NOW(), updated_at = NOW()
+		WHERE id = $3 AND partner_id = $4 AND status IN ($5, $6)`
+	result, err := r.db.Exec(query, user.DepositDisputeResponded, response, disputeID, partnerID,
+		user.DepositDisputeOpen, user.DepositDisputeEscalated)
+	if err != nil {
+		log.Printf("Error responding to deposit dispute ID %d: %v", disputeID, err)
+		return err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetDepositDisputesForAdmin mengambil antrean sengketa untuk admin.
+// Tanpa filter status: yang menunggu keputusan (sudah ditanggapi atau tereskalasi), terlama dulu.
+func (r *PartnerRepository) GetDepositDisputesForAdmin(status string) ([]user.DepositDispute, error) {
+	var disputes []user.DepositDispute
+	var err error
+	if status != "" {
+		disputes, err = queryDepositDisputes(r.db, depositDisputeSelect+` WHERE dd.status = $1 ORDER BY dd.created_at`, status)
+	} else {
+		disputes, err = queryDepositDisputes(r.db, depositDisputeSelect+` WHERE dd.status IN ($1, $2) ORDER BY dd.created_at`,
+			user.DepositDisputeResponded, user.DepositDisputeEscalated)
+	}
+	if err != nil {
+		log.Printf("Error getting deposit dispute queue: %v", err)
+		return nil, err
+	}
+	return disputes, nil
+}
+
+// ResolveDepositDispute menutup sengketa yang belum diputuskan secara atomik.
+// Mengembalikan sql.ErrNoRows jika sengketa sudah diputuskan.
+func (r *PartnerRepository) ResolveDepositDispute(disputeID int, status, note string, adjustmentType sql.NullString) error {
+	query := `
+		UPDATE deposit_disputes
+		SET status = $1, resolution_note = $2, adjustment_type = $3, resolved_at = NOW(), updated_at = NOW()
+		WHERE id = $4 AND status NOT IN ($5, $6)`
+	result, err := r.db.Exec(query, status, note, adjustmentType, disputeID, user.DepositDisputeResolved, user.DepositDisputeRejected)
+	if err != nil {
+		log.Printf("Error resolving deposit dispute ID %d: %v", disputeID, err)
+		return err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RevertDepositDisputeResolution membuka kembali sengketa jika void/koreksi deposit gagal
+func (r *PartnerRepository) RevertDepositDisputeResolution(disputeID int, previousStatus string) error {
+	query := `
+		UPDATE deposit_disputes
+		SET status = $1, resolution_note = NULL, adjustment_type = NULL, resolved_at = NULL, updated_at = NOW()
+		WHERE id = $2`
+	_, err := r.db.Exec(query, previousStatus, disputeID)
+	if err != nil {
+		log.Printf("Error reverting resolution of deposit dispute ID %d: %v", disputeID, err)
+	}
+	return err
+}
+
+// FindUnresolvedDepositDisputeID mengambil ID sengketa yang belum selesai untuk satu deposit, 0 jika tidak ada
+func (r *PartnerRepository) FindUnresolvedDepositDisputeID(depositID int) (int, error) {
+	var disputeID int
+	query := `SELECT id FROM deposit_disputes WHERE partner_deposit_history_id = $1 AND status NOT IN ($2, $3)`
+	err := r.db.QueryRow(query, depositID, user.DepositDisputeResolved, user.DepositDisputeRejected).Scan(&disputeID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		log.Printf("Error finding unresolved dispute for deposit ID %d: %v", depositID, err)
+		return 0, err
+	}
+	return disputeID, nil
+}
+
+// EscalateOverdueDepositDisputes memindahkan sengketa Open yang lewat batas tanggapan ke antrean admin
+func (r *PartnerRepository) EscalateOverdueDepositDisputes(now time.Time) ([]user.DepositDispute, error) {
+	query := `
+		UPDATE deposit_disputes
+		SET status = $1, updated_at = NOW()
+		WHERE status = $2 AND response_deadline <= $3
+		RETURNING id`
+	rows, err := r.db.Query(query, user.DepositDisputeEscalated, user.DepositDisputeOpen, now)
+	if err != nil {
+		log.Printf("Error escalating overdue deposit disputes: %v", err)
+		return nil, err
+	}
+	ids := []string{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids = append(ids, fmt.Sprint(id))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return []user.DepositDispute{}, nil
+	}
+
+	// ID berasal dari RETURNING (integer), aman disusun langsung ke query
+	return queryDepositDisputes(r.db, depositDisputeSelect+` WHERE dd.id IN (`+strings.Join(ids, ",")+`) ORDER BY dd.id`)
+}
@@ -0,0 +1,298 @@
+// internal/repository/deposit_draft_repo.go
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"xetor.id/backend/internal/domain/user"
+)
+
+// Draft deposit dua tahap: partner membuat draft, user menerima/menolak. Seperti sesi deposit,
+// query untuk kedua sisi disatukan di file ini.
+
+const depositDraftSelect = `
+	SELECT dd.id, dd.partner_id, p.business_name, dd.user_id, u.fullname, dd.deposit_method_id, dd.status,
+	       dd.items, dd.total_weight, dd.total_xpoin, dd.notes, dd.photo, dd.photos, dd.expires_at, dd.decided_by,
+	       dd.decided_at, dd.reject_reason, dd.partner_deposit_history_id, dd.staff_id, dd.attempts, dd.created_at, dd.updated_at
+	FROM deposit_drafts dd
+	LEFT JOIN partners p ON p.id = dd.partner_id
+	LEFT JOIN users u ON u.id = dd.user_id`
+
+// scanDepositDraft membaca satu baris hasil depositDraftSelect
+func scanDepositDraft(scanner interface{ Scan(dest ...interface{}) error }) (*user.DepositDraft, error) {
+	var d user.DepositDraft
+	var itemsRaw, photosRaw []byte
+	var totalWeight float64
+	var decidedAt sql.NullTime
+	err := scanner.Scan(
+		&d.ID, &d.PartnerID, &d.PartnerName, &d.UserID, &d.UserName, &d.DepositMethodID, &d.Status,
+		&itemsRaw, &totalWeight, &d.TotalXpoin, &d.Notes, &d.Photo, &photosRaw, &d.ExpiresAt, &d.DecidedBy,
+		&decidedAt, &d.RejectReason, &d.DepositHeaderID, &d.StaffID, &d.Attempts, &d.CreatedAt, &d.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	d.Items = []user.DepositDraftItem{}
+	if err := json.Unmarshal(itemsRaw, &d.Items); err != nil {
+		return nil, fmt.Errorf("gagal membaca item draft deposit: %w", err)
+	}
+	d.Photos = []user.DepositPhoto{}
+	if err := json.Unmarshal(photosRaw, &d.Photos); err != nil {
+		return nil, fmt.Errorf("gagal membaca foto draft deposit: %w", err)
+	}
+	d.TotalWeight = fmt.Sprintf("%.2f", totalWeight)
+	if decidedAt.Valid {
+		d.DecidedAt = &decidedAt.Time
+	}
+	return &d, nil
+}
+
+// queryDepositDrafts menjalankan query daftar draft deposit
+func queryDepositDrafts(db *sql.DB, query string, args ...interface{}) ([]user.DepositDraft, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	drafts := []user.DepositDraft{}
+	for rows.Next() {
+		d, err := scanDepositDraft(rows)
+		if err != nil {
+			return nil, err
+		}
+		drafts = append(drafts, *d)
+	}
+	return drafts, rows.Err()
+}
+
+// listDepositDrafts mengambil draft milik user/partner, opsional difilter status
+func listDepositDrafts(db *sql.DB, ownerColumn string, ownerID int, status string) ([]user.DepositDraft, error) {
+	query := depositDraftSelect + fmt.Sprintf(" WHERE dd.%s = $1", ownerColumn)
+	args := []interface{}{ownerID}
+	if status != "" {
+		query += ` AND dd.status = $2`
+		args = append(args, status)
+	}
+	query += ` ORDER BY dd.created_at DESC`
+
+	drafts, err := queryDepositDrafts(db, query, args...)
+	if err != nil {
+		log.Printf("Error getting deposit drafts for %s %d: %v", ownerColumn, ownerID, err)
+		return nil, err
+	}
+	return drafts, nil
+}
+
+// --- Sisi User ---
+
+// GetDepositDraftsByUserID mengambil draft deposit milik user, opsional difilter status
+func (r *UserRepository) GetDepositDraftsByUserID(userID int, status string) ([]user.DepositDraft, error) {
+	return listDepositDrafts(r.db, "user_id", userID, status)
+}
+
+// GetDepositDraftByIDForUser mengambil satu draft deposit milik user, nil jika tidak ada
+func (r *UserRepository) GetDepositDraftByIDForUser(draftID, userID int) (*user.DepositDraft, error) {
+	d, err := scanDepositDraft(r.db.QueryRow(depositDraftSelect+` WHERE dd.id = $1 AND dd.user_id = $2`, draftID, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("Error getting deposit draft ID %d for user ID %d: %v", draftID, userID, err)
+		return nil, err
+	}
+	return d, nil
+}
+
+// RejectDepositDraft menolak draft yang masih Pending dan belum melewati batas waktu
+func (r *UserRepository) RejectDepositDraft(draftID, userID int, reason string) error {
+	query := `
+		UPDATE deposit_drafts
+		SET status = $1, decided_by = 'user', decided_at = NOW(), reject_reason = $2, updated_at = NOW()
+		WHERE id = $3 AND user_id = $4 AND status = $5 AND expires_at > NOW()`
+	result, err := r.db.Exec(query, user.DepositDraftRejected, reason, draftID, userID, user.DepositDraftPending)
+	if err != nil {
+		log.Printf("Error rejecting deposit draft ID %d: %v", draftID, err)
+		return err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// --- Sisi Partner ---
+
+// CreateDepositDraft menyimpan draft deposit baru berstatus Pending
+func (r *PartnerRepository) CreateDepositDraft(draft *user.DepositDraft, totalWeight float64, consume func(tx *sql.Tx) error) (err error) {
+	itemsJSON, err := json.Marshal(draft.Items)
+	if err != nil {
+		return errors.New("gagal menyimpan draft deposit")
+	}
+	if draft.Photos == nil {
+		draft.Photos = []user.DepositPhoto{}
+	}
+	photosJSON, err := json.Marshal(draft.Photos)
+	if err != nil {
+		return errors.New("gagal menyimpan draft deposit")
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		log.Printf("Error starting tx for deposit draft: %v", err)
+		return errors.New("gagal memulai transaksi")
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	// Token/nonce QR dipakai di transaksi yang sama agar tidak hangus jika draft gagal disimpan
+	if consume != nil {
+		if err = consume(tx); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO deposit_drafts
+			(partner_id, user_id, deposit_method_id, status, items, total_weight, total_xpoin, notes, photo, photos, expires_at, staff_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		RETURNING id, created_at, updated_at`
+	draft.Status = user.DepositDraftPending
+	err = tx.QueryRow(query, draft.PartnerID, draft.UserID, draft.DepositMethodID, draft.Status, itemsJSON,
+		totalWeight, draft.TotalXpoin, draft.Notes, draft.Photo, photosJSON, draft.ExpiresAt, draft.StaffID,
+	).Scan(&draft.ID, &draft.CreatedAt, &draft.UpdatedAt)
+	if err != nil {
+		log.Printf("Error creating deposit draft for user ID %d at partner ID %d: %v", draft.UserID, draft.PartnerID, err)
+		return errors.New("gagal menyimpan draft deposit")
+	}
+	draft.TotalWeight = fmt.Sprintf("%.2f", totalWeight)
+	return nil
+}
+
+// GetDepositDraftsByPartnerID mengambil draft deposit yang dibuat partner, opsional difilter status
+func (r *PartnerRepository) GetDepositDraftsByPartnerID(partnerID int, status string) ([]user.DepositDraft, error) {
+	return listDepositDrafts(r.db, "partner_id", partnerID, status)
+}
+
+// GetDepositDraftByID mengambil satu draft deposit tanpa filter pemilik, nil jika tidak ada
+func (r *PartnerRepository) GetDepositDraftByID(draftID int) (*user.DepositDraft, error) {
+	d, err := scanDepositDraft(r.db.QueryRow(depositDraftSelect+` WHERE dd.id = $1`, draftID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("Error getting deposit draft ID %d: %v", draftID, err)
+		return nil, err
+	}
+	return d, nil
+}
+
+// AcceptDepositDraft menandai draft Pending sebagai Accepted secara atomik.
+// Mengembalikan sql.ErrNoRows jika draft sudah diproses.
+func (r *PartnerRepository) AcceptDepositDraft(draftID int, decidedBy string) error {
+	query := `
+		UPDATE deposit_drafts
+		SET status = $1, decided_by = $2, decided_at = NOW(), updated_at = NOW()
+		WHERE id = $3 AND status = $4`
+	result, err := r.db.Exec(query, user.DepositDraftAccepted, decidedBy, draftID, user.DepositDraftPending)
+	if err != nil {
+		log.Printf("Error accepting deposit draft ID %d: %v", draftID, err)
+		return err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RevertFailedDepositDraft mencatat percobaan yang gagal sebelum transaksi deposit commit. Draft kembali ke
+// Pending agar bisa dicoba lagi, atau menjadi Failed setelah maxAttempts percobaan. Mengembalikan status baru.
+func (r *PartnerRepository) RevertFailedDepositDraft(draftID int, maxAttempts int) (string, error) {
+	query := `
+		UPDATE deposit_drafts
+		SET attempts = attempts + 1,
+		    status = CASE WHEN attempts + 1 >= $2 THEN $3 ELSE $4 END,
+		    decided_by = CASE WHEN attempts + 1 >= $2 THEN decided_by END,
+		    decided_at = CASE WHEN attempts + 1 >= $2 THEN decided_at END,
+		    updated_at = NOW()
+		WHERE id = $1 AND status = $5 AND partner_deposit_history_id IS NULL
+		RETURNING status`
+	var status string
+	err := r.db.QueryRow(query, draftID, maxAttempts, user.DepositDraftFailed, user.DepositDraftPending, user.DepositDraftAccepted).Scan(&status)
+	if err != nil {
+		log.Printf("Error reverting deposit draft ID %d: %v", draftID, err)
+		return "", err
+	}
+	return status, nil
+}
+
+// FailDepositDraft menandai draft Pending sebagai Failed (misal ditolak aturan anti-fraud saat diterima).
+// Mengembalikan sql.ErrNoRows jika draft sudah diproses.
+func (r *PartnerRepository) FailDepositDraft(draftID int, decidedBy string) error {
+	query := `
+		UPDATE deposit_drafts
+		SET status = $1, decided_by = $2, decided_at = NOW(), updated_at = NOW()
+		WHERE id = $3 AND status = $4`
+	result, err := r.db.Exec(query, user.DepositDraftFailed, decidedBy, draftID, user.DepositDraftPending)
+	if err != nil {
+		log.Printf("Error failing deposit draft ID %d: %v", draftID, err)
+		return err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// UpdateDepositDraftStatus mengubah status draft hanya jika status saat ini sama dengan fromStatus
+// (dipakai saat kasus fraud draft yang ditahan diputuskan admin)
+func (r *PartnerRepository) UpdateDepositDraftStatus(draftID int, fromStatus, toStatus string) error {
+	query := `UPDATE deposit_drafts SET status = $1, updated_at = NOW() WHERE id = $2 AND status = $3`
+	result, err := r.db.Exec(query, toStatus, draftID, fromStatus)
+	if err != nil {
+		log.Printf("Error updating status of deposit draft ID %d: %v", draftID, err)
+		return err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetDepositDraftDepositHeader menyimpan ID deposit yang dihasilkan draft
+func (r *PartnerRepository) SetDepositDraftDepositHeader(draftID, partnerDepositHistoryID int) error {
+	query := `UPDATE deposit_drafts SET partner_deposit_history_id = $1, updated_at = NOW() WHERE id = $2`
+	_, err := r.db.Exec(query, partnerDepositHistoryID, draftID)
+	if err != nil {
+		log.Printf("Error linking deposit draft ID %d to deposit %d: %v", draftID, partnerDepositHistoryID, err)
+	}
+	return err
+}
+
+// GetExpiredPendingDepositDrafts mengambil draft Pending yang sudah melewati batas waktu jawaban user
+func (r *PartnerRepository) GetExpiredPendingDepositDrafts(now time.Time) ([]user.DepositDraft, error) {
+	query := depositDraftSelect + ` WHERE dd.status = $1 AND dd.expires_at <= $2 ORDER BY dd.expires_at`
+	drafts, err := queryDepositDrafts(r.db, query, user.DepositDraftPending, now)
+	if err != nil {
+		log.Printf("Error getting expired deposit drafts: %v", err)
+		return nil, err
+	}
+	return drafts, nil
+}
@@ -0,0 +1,80 @@
+// internal/repository/deposit_photo_repo.go
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+
+	"xetor.id/backend/internal/domain/user"
+)
+
+// Foto bukti deposit dibaca partner (riwayat, koreksi) maupun user (sengketa); query disatukan di file ini.
+
+// getDepositPhotos mengambil semua foto satu deposit, urut sesuai waktu unggah
+func getDepositPhotos(db *sql.DB, depositID int) ([]user.DepositPhoto, error) {
+	query := `
+		SELECT id, partner_deposit_history_detail_id, deposit_dispute_id, url, thumbnail_url
+		FROM deposit_photos
+		WHERE partner_deposit_history_id = $1
+		ORDER BY id`
+	rows, err := db.Query(query, depositID)
+	if err != nil {
+		log.Printf("Error getting photos for deposit ID %d: %v", depositID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	photos := []user.DepositPhoto{}
+	for rows.Next() {
+		var p user.DepositPhoto
+		var thumbnailURL sql.NullString
+		if err := rows.Scan(&p.ID, &p.DetailID, &p.DepositDisputeID, &p.URL, &thumbnailURL); err != nil {
+			log.Printf("Error scanning photo for deposit ID %d: %v", depositID, err)
+			return nil, err
+		}
+		p.ThumbnailURL = thumbnailURL.String
+		photos = append(photos, p)
+	}
+	return photos, rows.Err()
+}
+
+// GetDepositPhotos mengambil foto bukti deposit untuk ditampilkan di detail sengketa user
+func (r *UserRepository) GetDepositPhotos(depositID int) ([]user.DepositPhoto, error) {
+	return getDepositPhotos(r.db, depositID)
+}
+
+// GetDepositPhotos mengambil foto bukti satu deposit partner
+func (r *PartnerRepository) GetDepositPhotos(depositID int) ([]user.DepositPhoto, error) {
+	return getDepositPhotos(r.db, depositID)
+}
+
+// AddDepositPhotos menambahkan foto bukti ke deposit yang sudah tercatat (misal saat sengketa)
+func (r *PartnerRepository) AddDepositPhotos(depositID int, photos []user.DepositPhoto) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	query := `
+		INSERT INTO deposit_photos (partner_deposit_history_id, partner_deposit_history_detail_id, deposit_dispute_id, url, thumbnail_url)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''))`
+	for _, p := range photos {
+		_, err = tx.Exec(query, depositID, p.DetailID, p.DepositDisputeID, p.URL, p.ThumbnailURL)
+		if err != nil {
+			log.Printf("Error adding photo to deposit ID %d: %v", depositID, err)
+			return errors.New("gagal menyimpan foto deposit")
+		}
+	}
+	return nil
+}
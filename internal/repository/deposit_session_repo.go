@@ -0,0 +1,232 @@
+// internal/repository/deposit_session_repo.go
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+
+	"xetor.id/backend/internal/domain/user"
+)
+
+// Sesi deposit dipakai dari dua sisi: user membuka & membatalkan sesi, partner mengisi item
+// dan mengonfirmasi. Query disatukan di file ini agar format scan-nya sama.
+
+const depositSessionSelect = `
+	SELECT ds.id, ds.partner_id, p.business_name, ds.user_id, u.fullname, ds.status, ds.items,
+	       ds.total_weight, ds.estimated_xpoin, ds.partner_deposit_history_id, ds.cancelled_by,
+	       ds.created_at, ds.updated_at
+	FROM deposit_sessions ds
+	LEFT JOIN partners p ON p.id = ds.partner_id
+	LEFT JOIN users u ON u.id = ds.user_id`
+
+// scanDepositSession membaca satu baris hasil depositSessionSelect
+func scanDepositSession(scanner interface{ Scan(dest ...interface{}) error }) (*user.DepositSession, error) {
+	var s user.DepositSession
+	var itemsRaw []byte
+	var totalWeight float64
+	err := scanner.Scan(
+		&s.ID, &s.PartnerID, &s.PartnerName, &s.UserID, &s.UserName, &s.Status, &itemsRaw,
+		&totalWeight, &s.EstimatedXpoin, &s.DepositHeaderID, &s.CancelledBy,
+		&s.CreatedAt, &s.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	s.Items = []user.DepositSessionItem{}
+	if len(itemsRaw) > 0 {
+		if err := json.Unmarshal(itemsRaw, &s.Items); err != nil {
+			return nil, fmt.Errorf("gagal membaca item sesi deposit: %w", err)
+		}
+	}
+	s.TotalWeight = fmt.Sprintf("%.2f", totalWeight)
+	return &s, nil
+}
+
+// queryDepositSessions menjalankan query daftar sesi deposit
+func queryDepositSessions(db *sql.DB, query string, args ...interface{}) ([]user.DepositSession, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []user.DepositSession{}
+	for rows.Next() {
+		s, err := scanDepositSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, *s)
+	}
+	return sessions, rows.Err()
+}
+
+// getDepositSession mengambil satu sesi dengan filter pemilik, nil jika tidak ada
+func getDepositSession(db *sql.DB, ownerColumn string, sessionID, ownerID int) (*user.DepositSession, error) {
+	query := depositSessionSelect + fmt.Sprintf(" WHERE ds.id = $1 AND ds.%s = $2", ownerColumn)
+	s, err := scanDepositSession(db.QueryRow(query, sessionID, ownerID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("Error getting deposit session ID %d for %s %d: %v", sessionID, ownerColumn, ownerID, err)
+		return nil, err
+	}
+	return s, nil
+}
+
+// cancelDepositSession membatalkan sesi yang belum dikonfirmasi
+func cancelDepositSession(db *sql.DB, ownerColumn, cancelledBy string, sessionID, ownerID int) error {
+	query := fmt.Sprintf(`
+		UPDATE deposit_sessions
+		SET status = $1, cancelled_by = $2, updated_at = NOW()
+		WHERE id = $3 AND %s = $4 AND status IN ($5, $6)`, ownerColumn)
+	result, err := db.Exec(query, user.DepositSessionCancelled, cancelledBy, sessionID, ownerID,
+		user.DepositSessionOpen, user.DepositSessionItemsAdded)
+	if err != nil {
+		log.Printf("Error cancelling deposit session ID %d: %v", sessionID, err)
+		return err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// --- Sisi User ---
+
+// CreateDepositSession membuat sesi deposit baru berstatus Open
+func (r *UserRepository) CreateDepositSession(session *user.DepositSession) error {
+	query := `
+		INSERT INTO deposit_sessions (partner_id, user_id, status)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at, updated_at`
+	session.Status = user.DepositSessionOpen
+	err := r.db.QueryRow(query, session.PartnerID, session.UserID, session.Status).Scan(&session.ID, &session.CreatedAt, &session.UpdatedAt)
+	if err != nil {
+		log.Printf("Error creating deposit session for user ID %d at partner ID %d: %v", session.UserID, session.PartnerID, err)
+		return errors.New("gagal membuka sesi deposit")
+	}
+	session.Items = []user.DepositSessionItem{}
+	session.TotalWeight = "0.00"
+	return nil
+}
+
+// FindActiveDepositSession mencari sesi user di partner tertentu yang belum selesai, nil jika tidak ada
+func (r *UserRepository) FindActiveDepositSession(userID, partnerID int) (*user.DepositSession, error) {
+	query := depositSessionSelect + `
+		WHERE ds.user_id = $1 AND ds.partner_id = $2 AND ds.status IN ($3, $4)
+		ORDER BY ds.created_at DESC
+		LIMIT 1`
+	s, err := scanDepositSession(r.db.QueryRow(query, userID, partnerID, user.DepositSessionOpen, user.DepositSessionItemsAdded))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("Error finding active deposit session for user ID %d at partner ID %d: %v", userID, partnerID, err)
+		return nil, err
+	}
+	return s, nil
+}
+
+// GetDepositSessionsByUserID mengambil semua sesi deposit milik user, terbaru dulu
+func (r *UserRepository) GetDepositSessionsByUserID(userID int) ([]user.DepositSession, error) {
+	sessions, err := queryDepositSessions(r.db, depositSessionSelect+` WHERE ds.user_id = $1 ORDER BY ds.created_at DESC`, userID)
+	if err != nil {
+		log.Printf("Error getting deposit sessions for user ID %d: %v", userID, err)
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// GetDepositSessionByIDForUser mengambil sesi deposit milik user, nil jika tidak ada
+func (r *UserRepository) GetDepositSessionByIDForUser(sessionID, userID int) (*user.DepositSession, error) {
+	return getDepositSession(r.db, "user_id", sessionID, userID)
+}
+
+// CancelDepositSessionByUser membatalkan sesi milik user yang belum dikonfirmasi
+func (r *UserRepository) CancelDepositSessionByUser(sessionID, userID int) error {
+	return cancelDepositSession(r.db, "user_id", "user", sessionID, userID)
+}
+
+// --- Sisi Partner ---
+
+// GetDepositSessionsByPartnerID mengambil sesi deposit di partner, opsional difilter status
+func (r *PartnerRepository) GetDepositSessionsByPartnerID(partnerID int, status string) ([]user.DepositSession, error) {
+	query := depositSessionSelect + ` WHERE ds.partner_id = $1`
+	args := []interface{}{partnerID}
+	if status != "" {
+		query += ` AND ds.status = $2`
+		args = append(args, status)
+	}
+	query += ` ORDER BY ds.created_at DESC`
+
+	sessions, err := queryDepositSessions(r.db, query, args...)
+	if err != nil {
+		log.Printf("Error getting deposit sessions for partner ID %d: %v", partnerID, err)
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// GetDepositSessionByIDForPartner mengambil sesi deposit di partner, nil jika tidak ada
+func (r *PartnerRepository) GetDepositSessionByIDForPartner(sessionID, partnerID int) (*user.DepositSession, error) {
+	return getDepositSession(r.db, "partner_id", sessionID, partnerID)
+}
+
+// UpdateDepositSessionItems menyimpan item hasil timbang dan mengubah status menjadi Items Added
+func (r *PartnerRepository) UpdateDepositSessionItems(sessionID, partnerID int, items []user.DepositSessionItem, totalWeight float64, estimatedXpoin int) error {
+	itemsJSON, err := json.Marshal(items)
+	if err != nil {
+		return err
+	}
+	query := `
+		UPDATE deposit_sessions
+		SET items = $1, total_weight = $2, estimated_xpoin = $3, status = $4, updated_at = NOW()
+		WHERE id = $5 AND partner_id = $6 AND status IN ($7, $4)`
+	result, err := r.db.Exec(query, itemsJSON, totalWeight, estimatedXpoin, user.DepositSessionItemsAdded,
+		sessionID, partnerID, user.DepositSessionOpen)
+	if err != nil {
+		log.Printf("Error updating items for deposit session ID %d: %v", sessionID, err)
+		return err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// UpdateDepositSessionStatus mengubah status sesi hanya jika status saat ini sama dengan fromStatus
+func (r *PartnerRepository) UpdateDepositSessionStatus(sessionID, partnerID int, fromStatus, toStatus string) error {
+	query := `UPDATE deposit_sessions SET status = $1, updated_at = NOW() WHERE id = $2 AND partner_id = $3 AND status = $4`
+	result, err := r.db.Exec(query, toStatus, sessionID, partnerID, fromStatus)
+	if err != nil {
+		log.Printf("Error updating status of deposit session ID %d: %v", sessionID, err)
+		return err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetDepositSessionDepositHeader menyimpan ID deposit yang dihasilkan sesi
+func (r *PartnerRepository) SetDepositSessionDepositHeader(sessionID, partnerDepositHistoryID int) error {
+	query := `UPDATE deposit_sessions SET partner_deposit_history_id = $1, updated_at = NOW() WHERE id = $2`
+	_, err := r.db.Exec(query, partnerDepositHistoryID, sessionID)
+	if err != nil {
+		log.Printf("Error linking deposit session ID %d to deposit %d: %v", sessionID, partnerDepositHistoryID, err)
+	}
+	return err
+}
+
+// CancelDepositSessionByPartner membatalkan sesi di partner yang belum dikonfirmasi
+func (r *PartnerRepository) CancelDepositSessionByPartner(sessionID, partnerID int) error {
+	return cancelDepositSession(r.db, "partner_id", "partner", sessionID, partnerID)
+}
@@ -0,0 +1,345 @@
+// internal/repository/fraud_repo.go
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"xetor.id/backend/internal/domain/partner"
+	"xetor.id/backend/internal/domain/user"
+	"xetor.id/backend/internal/fraud"
+)
+
+// Aturan & kasus fraud deposit. Sinyal dihitung dari riwayat deposit partner (deposit yang di-void tidak dihitung).
+
+// GetFraudRules mengambil semua aturan fraud
+func (r *PartnerRepository) GetFraudRules() ([]fraud.Rule, error) {
+	query := `SELECT code, description, action, threshold, window_hours, updated_at FROM fraud_rules ORDER BY code`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		log.Printf("Error getting fraud rules: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := []fraud.Rule{}
+	for rows.Next() {
+		var rule fraud.Rule
+		if err := rows.Scan(&rule.Code, &rule.Description, &rule.Action, &rule.Threshold, &rule.WindowHours, &rule.UpdatedAt); err != nil {
+			log.Printf("Error scanning fraud rule: %v", err)
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// UpdateFraudRule menyimpan aksi, threshold & window aturan. Mengembalikan sql.ErrNoRows jika kode tidak ada.
+func (r *PartnerRepository) UpdateFraudRule(rule *fraud.Rule) error {
+	query := `
+		UPDATE fraud_rules SET action = $1, threshold = $2, window_hours = $3, updated_at = NOW()
+		WHERE code = $4
+		RETURNING description, updated_at`
+	err := r.db.QueryRow(query, rule.Action, rule.Threshold, rule.WindowHours, rule.Code).Scan(&rule.Description, &rule.UpdatedAt)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			log.Printf("Error updating fraud rule %s: %v", rule.Code, err)
+		}
+		return err
+	}
+	return nil
+}
+
+// GetDepositFraudSignals menghitung sinyal historis untuk satu deposit partner ke user
+func (r *PartnerRepository) GetDepositFraudSignals(partnerID, userID int, dayStart, dayEnd, identicalSince, frequencySince time.Time) (*partner.DepositFraudSignals, error) {
+	signals := &partner.DepositFraudSignals{PastWeights: map[float64]int{}}
+
+	queryDay := `
+		SELECT COALESCE(SUM(total_weight), 0)
+		FROM partner_deposit_histories
+		WHERE user_id = $1 AND transaction_time >= $2 AND transaction_time < $3 AND voided_at IS NULL`
+	if err := r.db.QueryRow(queryDay, userID, dayStart, dayEnd).Scan(&signals.UserWeightInDay); err != nil {
+		log.Printf("Error getting daily deposit weight for user ID %d: %v", userID, err)
+		return nil, err
+	}
+
+	queryCount := `
+		SELECT COUNT(*)
+		FROM partner_deposit_histories
+		WHERE partner_id = $1 AND user_id = $2 AND transaction_time >= $3 AND voided_at IS NULL`
+	if err := r.db.QueryRow(queryCount, partnerID, userID, frequencySince).Scan(&signals.DepositsToUser); err != nil {
+		log.Printf("Error counting deposits from partner ID %d to user ID %d: %v", partnerID, userID, err)
+		return nil, err
+	}
+
+	queryWeights := `
+		SELECT ROUND(pdd.waste_weight::numeric, 2), COUNT(*)
+		FROM partner_deposit_history_details pdd
+		JOIN partner_deposit_histories pdh ON pdh.id = pdd.partner_deposit_history_id
+		WHERE pdh.partner_id = $1 AND pdh.user_id = $2 AND pdh.transaction_time >= $3 AND pdh.voided_at IS NULL
+		  AND pdd.unit = 'kg' AND pdd.waste_weight > 0
+		GROUP BY 1`
+	rows, err := r.db.Query(queryWeights, partnerID, userID, identicalSince)
+	if err != nil {
+		log.Printf("Error getting repeated deposit weights for partner ID %d: %v", partnerID, err)
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var weight float64
+		var count int
+		if err := rows.Scan(&weight, &count); err != nil {
+			return nil, err
+		}
+		signals.PastWeights[fraud.Round(weight)] = count
+	}
+	return signals, rows.Err()
+}
+
+const depositFraudCaseSelect = `
+	SELECT fc.id, fc.partner_id, p.business_name, fc.user_id, u.fullname, fc.status, fc.hits, fc.deposit_method_id,
+	       fc.items, fc.total_weight, fc.total_xpoin, fc.notes, fc.photo, fc.photos, fc.client_id, fc.transaction_time,
+	       fc.staff_id, fc.source_type, fc.source_id, fc.partner_deposit_history_id, fc.review_note, fc.reviewed_at, fc.created_at, fc.updated_at
+	FROM deposit_fraud_cases fc
+	LEFT JOIN partners p ON p.id = fc.partner_id
+	LEFT JOIN users u ON u.id = fc.user_id`
+
+// scanDepositFraudCase membaca satu baris hasil depositFraudCaseSelect
+func scanDepositFraudCase(scanner interface{ Scan(dest ...interface{}) error }) (*partner.DepositFraudCase, error) {
+	var fc partner.DepositFraudCase
+	var hitsRaw, itemsRaw, photosRaw []byte
+	var totalWeight float64
+	var reviewedAt sql.NullTime
+	err := scanner.Scan(
+		&fc.ID, &fc.PartnerID, &fc.PartnerName, &fc.UserID, &fc.UserName, &fc.Status, &hitsRaw, &fc.DepositMethodID,
+		&itemsRaw, &totalWeight, &fc.TotalXpoin, &fc.Notes, &fc.Photo, &photosRaw, &fc.ClientID, &fc.TransactionTime,
+		&fc.StaffID, &fc.SourceType, &fc.SourceID, &fc.PartnerDepositHistoryID, &fc.ReviewNote, &reviewedAt, &fc.CreatedAt, &fc.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	fc.Hits = []fraud.Hit{}
+	fc.Items = []user.DepositDraftItem{}
+	fc.Photos = []user.DepositPhoto{}
+	if err := json.Unmarshal(hitsRaw, &fc.Hits); err != nil {
+		return nil, fmt.Errorf("gagal membaca aturan kasus fraud: %w", err)
+	}
+	if err := json.Unmarshal(itemsRaw, &fc.Items); err != nil {
+		return nil, fmt.Errorf("gagal membaca item kasus fraud: %w", err)
+	}
+	if err := json.Unmarshal(photosRaw, &fc.Photos); err != nil {
+		return nil, fmt.Errorf("gagal membaca foto kasus fraud: %w", err)
+	}
+	fc.TotalWeight = fmt.Sprintf("%.2f", totalWeight)
+	if reviewedAt.Valid {
+		fc.ReviewedAt = &reviewedAt.Time
+	}
+	return &fc, nil
+}
+
+// CreateDepositFraudCase menyimpan deposit yang memicu aturan fraud (Held atau Blocked)
+func (r *PartnerRepository) CreateDepositFraudCase(fc *partner.DepositFraudCase, totalWeight float64, consume func(tx *sql.Tx) error) (err error) {
+	if fc.Photos == nil {
+		fc.Photos = []user.DepositPhoto{}
+	}
+	hitsJSON, errHits := json.Marshal(fc.Hits)
+	itemsJSON, errItems := json.Marshal(fc.Items)
+	photosJSON, errPhotos := json.Marshal(fc.Photos)
+	if errHits != nil || errItems != nil || errPhotos != nil {
+		return errors.New("gagal menyimpan kasus fraud deposit")
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		log.Printf("Error starting tx for deposit fraud case: %v", err)
+		return errors.New("gagal memulai transaksi")
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	// Kasus Held memakai token/nonce QR di transaksi yang sama; kasus Blocked tidak memakainya
+	if consume != nil {
+		if err = consume(tx); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO deposit_fraud_cases
+			(partner_id, user_id, status, hits, deposit_method_id, items, total_weight, total_xpoin, notes, photo, photos, client_id, transaction_time, staff_id,
+			 source_type, source_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		RETURNING id, created_at, updated_at`
+	err = tx.QueryRow(query, fc.PartnerID, fc.UserID, fc.Status, hitsJSON, fc.DepositMethodID, itemsJSON, totalWeight,
+		fc.TotalXpoin, fc.Notes, fc.Photo, photosJSON, fc.ClientID, fc.TransactionTime, fc.StaffID, fc.SourceType, fc.SourceID,
+	).Scan(&fc.ID, &fc.CreatedAt, &fc.UpdatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return errors.New("deposit dengan client_id ini sudah tersimpan")
+		}
+		log.Printf("Error creating deposit fraud case for partner ID %d, user ID %d: %v", fc.PartnerID, fc.UserID, err)
+		return errors.New("gagal menyimpan kasus fraud deposit")
+	}
+
+	// Kasus Held mencadangkan hasil timbangnya agar tidak dipakai deposit lain selama ditinjau
+	if fc.Status == partner.FraudCaseHeld {
+		queryReserveReading := `UPDATE scale_readings SET used_at = NOW(), deposit_fraud_case_id = $1 WHERE id = $2 AND partner_id = $3 AND used_at IS NULL`
+		for _, item := range fc.Items {
+			if item.ScaleReadingID <= 0 {
+				continue
+			}
+			result, errReserve := tx.Exec(queryReserveReading, fc.ID, item.ScaleReadingID, fc.PartnerID)
+			if errReserve != nil {
+				log.Printf("Error reserving scale reading %d for deposit fraud case %d: %v", item.ScaleReadingID, fc.ID, errReserve)
+				return errors.New("gagal menandai hasil timbang")
+			}
+			if n, _ := result.RowsAffected(); n == 0 {
+				return fmt.Errorf("hasil timbang ID %d sudah dipakai", item.ScaleReadingID)
+			}
+		}
+	}
+
+	// Asal deposit (sesi, jemput, draft) yang sudah dikunci consume ikut ditahan di transaksi yang sama
+	if fc.Status == partner.FraudCaseHeld && fc.SourceType.Valid {
+		if err = holdDepositFraudCaseSource(tx, fc); err != nil {
+			return err
+		}
+	}
+	fc.TotalWeight = fmt.Sprintf("%.2f", totalWeight)
+	return nil
+}
+
+// holdDepositFraudCaseSource memindahkan asal deposit dari status kunci consume-nya ke Held
+func holdDepositFraudCaseSource(tx *sql.Tx, fc *partner.DepositFraudCase) error {
+	var query, heldStatus, lockedStatus string
+	switch fc.SourceType.String {
+	case partner.FraudCaseSourceDepositSession:
+		query = `UPDATE deposit_sessions SET status = $1, updated_at = NOW() WHERE id = $2 AND partner_id = $3 AND status = $4`
+		heldStatus, lockedStatus = user.DepositSessionHeld, user.DepositSessionConfirmed
+	case partner.FraudCaseSourcePickupRequest:
+		query = `UPDATE pickup_requests SET status = $1, completed_at = NULL, updated_at = NOW() WHERE id = $2 AND partner_id = $3 AND status = $4`
+		heldStatus, lockedStatus = user.PickupHeld, user.PickupCompleted
+	case partner.FraudCaseSourceDepositDraft:
+		query = `UPDATE deposit_drafts SET status = $1, updated_at = NOW() WHERE id = $2 AND partner_id = $3 AND status = $4`
+		heldStatus, lockedStatus = user.DepositDraftHeld, user.DepositDraftAccepted
+	default:
+		return fmt.Errorf("asal deposit %s tidak valid", fc.SourceType.String)
+	}
+
+	result, err := tx.Exec(query, heldStatus, fc.SourceID, fc.PartnerID, lockedStatus)
+	if err != nil {
+		log.Printf("Error holding %s ID %d for deposit fraud case %d: %v", fc.SourceType.String, fc.SourceID.Int32, fc.ID, err)
+		return errors.New("gagal menahan asal deposit")
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errors.New("asal deposit sudah diproses")
+	}
+	return nil
+}
+
+// ReleaseDepositFraudCaseScaleReadings melepas hasil timbang yang dicadangkan kasus fraud yang ditolak
+func (r *PartnerRepository) ReleaseDepositFraudCaseScaleReadings(caseID int) error {
+	query := `UPDATE scale_readings SET used_at = NULL, deposit_fraud_case_id = NULL WHERE deposit_fraud_case_id = $1 AND partner_deposit_history_detail_id IS NULL`
+	if _, err := r.db.Exec(query, caseID); err != nil {
+		log.Printf("Error releasing scale readings of deposit fraud case %d: %v", caseID, err)
+		return errors.New("gagal melepas hasil timbang")
+	}
+	return nil
+}
+
+// GetDepositFraudCases mengambil kasus fraud untuk admin, opsional difilter status (terbaru dulu)
+func (r *PartnerRepository) GetDepositFraudCases(status string) ([]partner.DepositFraudCase, error) {
+	query := depositFraudCaseSelect
+	args := []interface{}{}
+	if status != "" {
+		query += ` WHERE fc.status = $1`
+		args = append(args, status)
+	}
+	query += ` ORDER BY fc.created_at DESC`
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		log.Printf("Error getting deposit fraud cases: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	cases := []partner.DepositFraudCase{}
+	for rows.Next() {
+		fc, err := scanDepositFraudCase(rows)
+		if err != nil {
+			log.Printf("Error scanning deposit fraud case: %v", err)
+			return nil, err
+		}
+		cases = append(cases, *fc)
+	}
+	return cases, rows.Err()
+}
+
+// GetDepositFraudCaseByID mengambil satu kasus fraud, nil jika tidak ada
+func (r *PartnerRepository) GetDepositFraudCaseByID(caseID int) (*partner.DepositFraudCase, error) {
+	fc, err := scanDepositFraudCase(r.db.QueryRow(depositFraudCaseSelect+` WHERE fc.id = $1`, caseID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("Error getting deposit fraud case ID %d: %v", caseID, err)
+		return nil, err
+	}
+	return fc, nil
+}
+
+// FindDepositFraudCaseByClientID mengambil kasus fraud dari deposit offline dengan client_id tertentu, nil jika tidak ada
+func (r *PartnerRepository) FindDepositFraudCaseByClientID(partnerID int, clientID string) (*partner.DepositFraudCase, error) {
+	fc, err := scanDepositFraudCase(r.db.QueryRow(depositFraudCaseSelect+` WHERE fc.partner_id = $1 AND fc.client_id = $2`, partnerID, clientID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("Error finding deposit fraud case by client ID %s for partner ID %d: %v", clientID, partnerID, err)
+		return nil, err
+	}
+	return fc, nil
+}
+
+// UpdateDepositFraudCaseStatus memindahkan status kasus secara atomik beserta catatan review.
+// Mengembalikan sql.ErrNoRows jika status kasus sudah berubah.
+func (r *PartnerRepository) UpdateDepositFraudCaseStatus(caseID int, fromStatus, toStatus string, note sql.NullString) error {
+	query := `
+		UPDATE deposit_fraud_cases
+		SET status = $1, review_note = COALESCE($2, review_note), reviewed_at = NOW(), updated_at = NOW()
+		WHERE id = $3 AND status = $4`
+	result, err := r.db.Exec(query, toStatus, note, caseID, fromStatus)
+	if err != nil {
+		log.Printf("Error updating deposit fraud case ID %d to %s: %v", caseID, toStatus, err)
+		return err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetDepositFraudCaseDepositHeader menyimpan ID deposit yang dijalankan saat kasus dirilis
+func (r *PartnerRepository) SetDepositFraudCaseDepositHeader(caseID, partnerDepositHistoryID int) error {
+	query := `UPDATE deposit_fraud_cases SET partner_deposit_history_id = $1, updated_at = NOW() WHERE id = $2`
+	_, err := r.db.Exec(query, partnerDepositHistoryID, caseID)
+	if err != nil {
+		log.Printf("Error linking deposit fraud case ID %d to deposit %d: %v", caseID, partnerDepositHistoryID, err)
+	}
+	return err
+}
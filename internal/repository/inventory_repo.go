@@ -0,0 +1,158 @@
+// internal/repository/inventory_repo.go
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+
+	"xetor.id/backend/internal/domain/partner"
+)
+
+// Stok sampah partner per waste_detail_id dan mutasinya. Mutasi dari deposit, void & koreksi
+// dicatat di dalam transaksi deposit masing-masing lewat applyInventoryMovement.
+
+// applyInventoryMovement mengubah stok dan mencatat mutasinya di dalam transaksi yang sedang berjalan.
+// enforceStock = true menolak mutasi yang membuat stok menjadi negatif (stok keluar & penyesuaian manual).
+func applyInventoryMovement(tx *sql.Tx, m partner.InventoryMovement, enforceStock bool) error {
+	var weight float64
+	var quantity int
+	err := tx.QueryRow(`
+		INSERT INTO partner_inventories (partner_id, waste_detail_id, weight, quantity)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (partner_id, waste_detail_id) DO UPDATE
+		SET weight = partner_inventories.weight + EXCLUDED.weight,
+		    quantity = partner_inventories.quantity + EXCLUDED.quantity,
+		    updated_at = NOW()
+		RETURNING weight, quantity`,
+		m.PartnerID, m.WasteDetailID, m.WeightChange, m.QuantityChange,
+	).Scan(&weight, &quantity)
+	if err != nil {
+		log.Printf("Error updating inventory of partner ID %d, waste detail ID %d: %v", m.PartnerID, m.WasteDetailID, err)
+		return errors.New("gagal memperbarui stok")
+	}
+	// Toleransi pembulatan NUMERIC(12,2)
+	if enforceStock && (weight < -0.005 || quantity < 0) {
+		return fmt.Errorf("stok sampah ID %d tidak mencukupi", m.WasteDetailID)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO partner_inventory_movements
+			(partner_id, waste_detail_id, type, weight_change, quantity_change, partner_deposit_history_id, partner_offtake_sale_id, reason, staff_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		m.PartnerID, m.WasteDetailID, m.Type, m.WeightChange, m.QuantityChange, m.PartnerDepositHistoryID, m.OfftakeSaleID, m.Reason, m.StaffID)
+	if err != nil {
+		log.Printf("Error recording inventory movement for partner ID %d: %v", m.PartnerID, err)
+		return errors.New("gagal mencatat mutasi stok")
+	}
+	return nil
+}
+
+// RecordInventoryMovements mencatat beberapa mutasi stok manual (penjualan, pembuangan, penyesuaian) secara atomik
+func (r *PartnerRepository) RecordInventoryMovements(movements []partner.InventoryMovement) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		log.Printf("Error starting tx for inventory movements: %v", err)
+		return errors.New("gagal memulai transaksi")
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	for _, m := range movements {
+		if err = applyInventoryMovement(tx, m, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetInventory mengambil stok partner yang masih ada beserta harga beli partner untuk valuasi.
+// Harga dicocokkan dengan satuan stok: stok yang bermutasi per pcs dinilai dengan harga pcs, sisanya dengan harga kg.
+func (r *PartnerRepository) GetInventory(partnerID int) ([]partner.InventoryItem, error) {
+	query := `
+		SELECT pi.waste_detail_id, wd.name, pi.weight, pi.quantity, pr.unit, pr.price, pi.updated_at
+		FROM partner_inventories pi
+		LEFT JOIN waste_details wd ON wd.id = pi.waste_detail_id
+		LEFT JOIN LATERAL (
+			SELECT pwpd.unit, pwpd.price
+			FROM partner_waste_price_details pwpd
+			JOIN partner_waste_prices pwp ON pwp.id = pwpd.partner_waste_price_id
+			WHERE pwp.partner_id = pi.partner_id AND pwpd.waste_detail_id = pi.waste_detail_id
+			  AND pwpd.unit = CASE WHEN pi.quantity <> 0 THEN $2 ELSE $3 END
+			ORDER BY pwpd.id DESC
+			LIMIT 1
+		) pr ON TRUE
+		WHERE pi.partner_id = $1 AND (pi.weight <> 0 OR pi.quantity <> 0)
+		ORDER BY wd.name`
+	rows, err := r.db.Query(query, partnerID, partner.WasteUnitPcs, partner.WasteUnitKg)
+	if err != nil {
+		log.Printf("Error getting inventory of partner ID %d: %v", partnerID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []partner.InventoryItem{}
+	for rows.Next() {
+		var item partner.InventoryItem
+		var weight float64
+		var price sql.NullFloat64
+		if err := rows.Scan(&item.WasteDetailID, &item.WasteName, &weight, &item.Quantity, &item.Unit, &price, &item.UpdatedAt); err != nil {
+			log.Printf("Error scanning inventory item: %v", err)
+			return nil, err
+		}
+		item.Weight = fmt.Sprintf("%.2f", weight)
+		if price.Valid {
+			item.PricePerUnit = &price.Float64
+			if item.Unit.String == partner.WasteUnitPcs {
+				item.Value = price.Float64 * float64(item.Quantity)
+			} else {
+				item.Value = price.Float64 * weight
+			}
+			item.Value = math.Round(item.Value*100) / 100
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// GetInventoryMovements mengambil mutasi stok partner terbaru dulu, opsional difilter jenis sampah & jenis mutasi
+func (r *PartnerRepository) GetInventoryMovements(partnerID, wasteDetailID int, movementType string, limit, offset int) ([]partner.InventoryMovement, error) {
+	query := `
+		SELECT m.id, m.partner_id, m.waste_detail_id, wd.name, m.type, m.weight_change, m.quantity_change,
+		       m.partner_deposit_history_id, m.partner_offtake_sale_id, m.reason, m.staff_id, ps.name, m.created_at
+		FROM partner_inventory_movements m
+		LEFT JOIN waste_details wd ON wd.id = m.waste_detail_id
+		LEFT JOIN partner_staff ps ON ps.id = m.staff_id
+		WHERE m.partner_id = $1 AND ($2 = 0 OR m.waste_detail_id = $2) AND ($3 = '' OR m.type = $3)
+		ORDER BY m.created_at DESC, m.id DESC
+		LIMIT $4 OFFSET $5`
+	rows, err := r.db.Query(query, partnerID, wasteDetailID, movementType, limit, offset)
+	if err != nil {
+		log.Printf("Error getting inventory movements of partner ID %d: %v", partnerID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	movements := []partner.InventoryMovement{}
+	for rows.Next() {
+		var m partner.InventoryMovement
+		err := rows.Scan(&m.ID, &m.PartnerID, &m.WasteDetailID, &m.WasteName, &m.Type, &m.WeightChange, &m.QuantityChange,
+			&m.PartnerDepositHistoryID, &m.OfftakeSaleID, &m.Reason, &m.StaffID, &m.StaffName, &m.CreatedAt)
+		if err != nil {
+			log.Printf("Error scanning inventory movement: %v", err)
+			return nil, err
+		}
+		movements = append(movements, m)
+	}
+	return movements, rows.Err()
+}
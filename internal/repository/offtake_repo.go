@@ -0,0 +1,214 @@
+// internal/repository/offtake_repo.go
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"xetor.id/backend/internal/domain/partner"
+)
+
+// Penjualan sampah partner ke recycler (offtake). Penjualan dan pengurangan stoknya dicatat dalam satu transaksi.
+
+// CreateOfftakeSale menyimpan penjualan beserta rinciannya dan mengurangi stok; gagal seluruhnya jika stok kurang
+func (r *PartnerRepository) CreateOfftakeSale(args *partner.ArgsOfftakeSaleCreation) (saleID int, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		log.Printf("Error starting tx for offtake sale: %v", err)
+		return 0, errors.New("gagal memulai transaksi")
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	sale := args.Sale
+	querySale := `
+		INSERT INTO partner_offtake_sales
+			(partner_id, recycler_id, sale_date, invoice_number, invoice_photo, total_weight, total_amount, notes, staff_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id`
+	err = tx.QueryRow(querySale, sale.PartnerID, sale.RecyclerID, sale.SaleDate, sale.InvoiceNumber, sale.InvoicePhoto,
+		sale.TotalWeight, sale.TotalAmount, sale.Notes, sale.StaffID).Scan(&saleID)
+	if err != nil {
+		log.Printf("Error creating offtake sale for partner ID %d: %v", sale.PartnerID, err)
+		return 0, errors.New("gagal menyimpan penjualan")
+	}
+
+	queryDetail := `
+		INSERT INTO partner_offtake_sale_details
+			(partner_offtake_sale_id, waste_detail_id, weight, quantity, price_per_kg, subtotal)
+		VALUES ($1, $2, $3, $4, $5, $6)`
+	for _, item := range sale.Items {
+		_, err = tx.Exec(queryDetail, saleID, item.WasteDetailID, item.Weight, item.Quantity, item.PricePerKg, item.Subtotal)
+		if err != nil {
+			log.Printf("Error creating offtake sale detail for sale ID %d: %v", saleID, err)
+			return 0, errors.New("gagal menyimpan rincian penjualan")
+		}
+	}
+
+	for _, m := range args.Movements {
+		m.OfftakeSaleID = sql.NullInt32{Int32: int32(saleID), Valid: true}
+		if err = applyInventoryMovement(tx, m, true); err != nil {
+			return 0, err
+		}
+	}
+	return saleID, nil
+}
+
+const offtakeSaleSelect = `
+	SELECT s.id, s.partner_id, s.recycler_id, rc.name, s.sale_date, s.invoice_number, s.invoice_photo,
+	       s.total_weight, s.total_amount, s.notes, s.staff_id, ps.name, s.created_at
+	FROM partner_offtake_sales s
+	JOIN recyclers rc ON rc.id = s.recycler_id
+	LEFT JOIN partner_staff ps ON ps.id = s.staff_id`
+
+// scanOfftakeSale membaca satu baris hasil offtakeSaleSelect
+func scanOfftakeSale(scanner interface{ Scan(dest ...interface{}) error }) (*partner.OfftakeSale, error) {
+	var sale partner.OfftakeSale
+	var saleDate time.Time
+	var totalWeight float64
+	err := scanner.Scan(&sale.ID, &sale.PartnerID, &sale.RecyclerID, &sale.RecyclerName, &saleDate, &sale.InvoiceNumber,
+		&sale.InvoicePhoto, &totalWeight, &sale.TotalAmount, &sale.Notes, &sale.StaffID, &sale.StaffName, &sale.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	sale.SaleDate = saleDate.Format("2006-01-02")
+	sale.TotalWeight = fmt.Sprintf("%.2f", totalWeight)
+	return &sale, nil
+}
+
+// GetOfftakeSales mengambil penjualan partner terbaru dulu dalam rentang sale_date (inklusif), opsional per recycler
+func (r *PartnerRepository) GetOfftakeSales(partnerID int, from, to string, recyclerID, limit, offset int) ([]partner.OfftakeSale, error) {
+	query := offtakeSaleSelect + `
+		WHERE s.partner_id = $1 AND s.sale_date BETWEEN $2 AND $3 AND ($4 = 0 OR s.recycler_id = $4)
+		ORDER BY s.sale_date DESC, s.id DESC
+		LIMIT $5 OFFSET $6`
+	rows, err := r.db.Query(query, partnerID, from, to, recyclerID, limit, offset)
+	if err != nil {
+		log.Printf("Error getting offtake sales of partner ID %d: %v", partnerID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	sales := []partner.OfftakeSale{}
+	for rows.Next() {
+		sale, err := scanOfftakeSale(rows)
+		if err != nil {
+			log.Printf("Error scanning offtake sale: %v", err)
+			return nil, err
+		}
+		sales = append(sales, *sale)
+	}
+	return sales, rows.Err()
+}
+
+// GetOfftakeSaleByID mengambil satu penjualan milik partner beserta rinciannya, nil jika tidak ada
+func (r *PartnerRepository) GetOfftakeSaleByID(saleID, partnerID int) (*partner.OfftakeSale, error) {
+	sale, err := scanOfftakeSale(r.db.QueryRow(offtakeSaleSelect+` WHERE s.id = $1 AND s.partner_id = $2`, saleID, partnerID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("Error getting offtake sale ID %d: %v", saleID, err)
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`
+		SELECT d.id, d.waste_detail_id, wd.name, d.weight, d.quantity, d.price_per_kg, d.subtotal
+		FROM partner_offtake_sale_details d
+		LEFT JOIN waste_details wd ON wd.id = d.waste_detail_id
+		WHERE d.partner_offtake_sale_id = $1
+		ORDER BY d.id`, saleID)
+	if err != nil {
+		log.Printf("Error getting details of offtake sale ID %d: %v", saleID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	sale.Items = []partner.OfftakeSaleItem{}
+	for rows.Next() {
+		var item partner.OfftakeSaleItem
+		if err := rows.Scan(&item.ID, &item.WasteDetailID, &item.WasteName, &item.Weight, &item.Quantity, &item.PricePerKg, &item.Subtotal); err != nil {
+			log.Printf("Error scanning offtake sale detail: %v", err)
+			return nil, err
+		}
+		sale.Items = append(sale.Items, item)
+	}
+	return sale, rows.Err()
+}
+
+// GetOfftakeRevenueByPartnerID total pendapatan partner dari seluruh penjualan ke recycler
+func (r *PartnerRepository) GetOfftakeRevenueByPartnerID(partnerID int) (float64, error) {
+	var revenue float64
+	err := r.db.QueryRow(`SELECT COALESCE(SUM(total_amount), 0) FROM partner_offtake_sales WHERE partner_id = $1`, partnerID).Scan(&revenue)
+	if err != nil {
+		log.Printf("Error summing offtake revenue of partner ID %d: %v", partnerID, err)
+		return 0, err
+	}
+	return revenue, nil
+}
+
+// GetOfftakeMarginItems menjumlahkan penjualan (per sale_date) dan pembelian dari deposit user yang tidak dibatalkan
+// (per transaction_time) untuk tiap jenis sampah dalam rentang [from, to). Mengembalikan juga jumlah transaksi penjualan.
+func (r *PartnerRepository) GetOfftakeMarginItems(partnerID int, from, to time.Time) ([]partner.OfftakeMarginItem, int, error) {
+	query := `
+		WITH sold AS (
+			SELECT d.waste_detail_id, SUM(d.weight) AS weight, SUM(d.subtotal) AS revenue
+			FROM partner_offtake_sale_details d
+			JOIN partner_offtake_sales s ON s.id = d.partner_offtake_sale_id
+			WHERE s.partner_id = $1 AND s.sale_date >= $4 AND s.sale_date < $5
+			GROUP BY d.waste_detail_id
+		), bought AS (
+			SELECT pdd.waste_detail_id, SUM(pdd.waste_weight) AS weight, SUM(pdd.xpoin) AS xpoin
+			FROM partner_deposit_history_details pdd
+			JOIN partner_deposit_histories pdh ON pdh.id = pdd.partner_deposit_history_id
+			WHERE pdh.partner_id = $1 AND pdh.transaction_time >= $2 AND pdh.transaction_time < $3
+			  AND pdh.voided_at IS NULL AND pdd.status <> 'Voided' AND pdd.waste_detail_id IS NOT NULL
+			GROUP BY pdd.waste_detail_id
+		)
+		SELECT COALESCE(sold.waste_detail_id, bought.waste_detail_id), wd.name,
+		       COALESCE(sold.weight, 0), COALESCE(sold.revenue, 0), COALESCE(bought.weight, 0), COALESCE(bought.xpoin, 0)
+		FROM sold
+		FULL OUTER JOIN bought ON bought.waste_detail_id = sold.waste_detail_id
+		LEFT JOIN waste_details wd ON wd.id = COALESCE(sold.waste_detail_id, bought.waste_detail_id)
+		ORDER BY wd.name`
+	fromDate, toDate := from.Format("2006-01-02"), to.Format("2006-01-02")
+	rows, err := r.db.Query(query, partnerID, from, to, fromDate, toDate)
+	if err != nil {
+		log.Printf("Error getting offtake margin of partner ID %d: %v", partnerID, err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	items := []partner.OfftakeMarginItem{}
+	for rows.Next() {
+		var item partner.OfftakeMarginItem
+		if err := rows.Scan(&item.WasteDetailID, &item.WasteName, &item.SoldWeight, &item.Revenue, &item.PurchasedWeight, &item.PurchaseXpoin); err != nil {
+			log.Printf("Error scanning offtake margin item: %v", err)
+			return nil, 0, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var saleCount int
+	err = r.db.QueryRow(`SELECT COUNT(*) FROM partner_offtake_sales WHERE partner_id = $1 AND sale_date >= $2 AND sale_date < $3`,
+		partnerID, fromDate, toDate).Scan(&saleCount)
+	if err != nil {
+		log.Printf("Error counting offtake sales of partner ID %d: %v", partnerID, err)
+		return nil, 0, err
+	}
+	return items, saleCount, nil
+}
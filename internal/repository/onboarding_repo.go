@@ -0,0 +1,308 @@
+// internal/repository/onboarding_repo.go
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"xetor.id/backend/internal/domain/partner"
+)
+
+// Pengajuan onboarding (KYC) partner, dokumen, dan riwayat status.
+
+const onboardingApplicationSelect = `
+	SELECT oa.id, oa.partner_id, p.business_name, oa.status,
+	       COALESCE(oa.owner_name, ''), COALESCE(oa.owner_nik, ''), COALESCE(oa.nib, ''),
+	       COALESCE(oa.bank_name, ''), COALESCE(oa.bank_account_number, ''), COALESCE(oa.bank_account_holder, ''),
+	       oa.review_note, oa.submitted_at, oa.reviewed_at, oa.created_at, oa.updated_at
+	FROM partner_onboarding_applications oa
+	LEFT JOIN partners p ON p.id = oa.partner_id`
+
+// scanOnboardingApplication membaca satu baris hasil onboardingApplicationSelect
+func scanOnboardingApplication(scanner interface{ Scan(dest ...interface{}) error }) (*partner.OnboardingApplication, error) {
+	var app partner.OnboardingApplication
+	var submittedAt, reviewedAt sql.NullTime
+	err := scanner.Scan(
+		&app.ID, &app.PartnerID, &app.PartnerName, &app.Status,
+		&app.OwnerName, &app.OwnerNIK, &app.NIB,
+		&app.BankName, &app.BankAccountNumber, &app.BankAccountHolder,
+		&app.ReviewNote, &submittedAt, &reviewedAt, &app.CreatedAt, &app.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if submittedAt.Valid {
+		app.SubmittedAt = &submittedAt.Time
+	}
+	if reviewedAt.Valid {
+		app.ReviewedAt = &reviewedAt.Time
+	}
+	app.Documents = []partner.OnboardingDocument{}
+	return &app, nil
+}
+
+// getOnboardingApplication mengambil satu pengajuan berdasarkan kolom tertentu, nil jika tidak ada
+func (r *PartnerRepository) getOnboardingApplication(column string, value int) (*partner.OnboardingApplication, error) {
+	app, err := scanOnboardingApplication(r.db.QueryRow(onboardingApplicationSelect+fmt.Sprintf(" WHERE oa.%s = $1", column), value))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("Error getting onboarding application by %s %d: %v", column, value, err)
+		return nil, err
+	}
+	return app, nil
+}
+
+// GetOnboardingApplicationByPartnerID mengambil pengajuan milik partner, nil jika belum ada
+func (r *PartnerRepository) GetOnboardingApplicationByPartnerID(partnerID int) (*partner.OnboardingApplication, error) {
+	return r.getOnboardingApplication("partner_id", partnerID)
+}
+
+// GetOnboardingApplicationByID mengambil satu pengajuan, nil jika tidak ada
+func (r *PartnerRepository) GetOnboardingApplicationByID(applicationID int) (*partner.OnboardingApplication, error) {
+	return r.getOnboardingApplication("id", applicationID)
+}
+
+// CreateOnboardingApplication membuat pengajuan Draft untuk partner (idempoten)
+func (r *PartnerRepository) CreateOnboardingApplication(partnerID int) (*partner.OnboardingApplication, error) {
+	query := `
+		INSERT INTO partner_onboarding_applications (partner_id, status)
+		VALUES ($1, $2)
+		ON CONFLICT (partner_id) DO NOTHING`
+	if _, err := r.db.Exec(query, partnerID, partner.OnboardingDraft); err != nil {
+		log.Printf("Error creating onboarding application for partner ID %d: %v", partnerID, err)
+		return nil, errors.New("gagal membuat pengajuan onboarding")
+	}
+	return r.GetOnboardingApplicationByPartnerID(partnerID)
+}
+
+// UpdateOnboardingApplicationData menyimpan data pengajuan selama masih bisa diubah (Draft / Needs Info).
+// Mengembalikan sql.ErrNoRows jika pengajuan sudah diajukan atau diputuskan.
+func (r *PartnerRepository) UpdateOnboardingApplicationData(app *partner.OnboardingApplication) error {
+	query := `
+		UPDATE partner_onboarding_applications
+		SET owner_name = NULLIF($1, ''), owner_nik = NULLIF($2, ''), nib = NULLIF($3, ''),
+		    bank_name = NULLIF($4, ''), bank_account_number = NULLIF($5, ''), bank_account_holder = NULLIF($6, ''),
+		    updated_at = NOW()
+		WHERE id = $7 AND status IN ($8, $9)
+		RETURNING updated_at`
+	err := r.db.QueryRow(query, app.OwnerName, app.OwnerNIK, app.NIB, app.BankName, app.BankAccountNumber, app.BankAccountHolder,
+		app.ID, partner.OnboardingDraft, partner.OnboardingNeedsInfo,
+	).Scan(&app.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return err
+		}
+		log.Printf("Error updating onboarding application ID %d: %v", app.ID, err)
+		return errors.New("gagal menyimpan pengajuan onboarding")
+	}
+	return nil
+}
+
+// GetOnboardingApplications mengambil antrean pengajuan, opsional difilter status; terlama diajukan dulu
+func (r *PartnerRepository) GetOnboardingApplications(status string) ([]partner.OnboardingApplication, error) {
+	query := onboardingApplicationSelect + ` WHERE ($1 = '' OR oa.status = $1) ORDER BY oa.submitted_at NULLS LAST, oa.created_at`
+	rows, err := r.db.Query(query, status)
+	if err != nil {
+		log.Printf("Error getting onboarding applications: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	apps := []partner.OnboardingApplication{}
+	for rows.Next() {
+		app, err := scanOnboardingApplication(rows)
+		if err != nil {
+			log.Printf("Error scanning onboarding application: %v", err)
+			return nil, err
+		}
+		apps = append(apps, *app)
+	}
+	return apps, rows.Err()
+}
+
+// --- Dokumen ---
+
+// GetOnboardingDocuments mengambil dokumen sebuah pengajuan
+func (r *PartnerRepository) GetOnboardingDocuments(applicationID int) ([]partner.OnboardingDocument, error) {
+	rows, err := r.db.Query(`
+		SELECT id, application_id, type, url, created_at
+		FROM partner_onboarding_documents WHERE application_id = $1
+		ORDER BY type, created_at`, applicationID)
+	if err != nil {
+		log.Printf("Error getting onboarding documents for application ID %d: %v", applicationID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	docs := []partner.OnboardingDocument{}
+	for rows.Next() {
+		var doc partner.OnboardingDocument
+		if err := rows.Scan(&doc.ID, &doc.ApplicationID, &doc.Type, &doc.URL, &doc.CreatedAt); err != nil {
+			log.Printf("Error scanning onboarding document: %v", err)
+			return nil, err
+		}
+		docs = append(docs, doc)
+	}
+	return docs, rows.Err()
+}
+
+// AddOnboardingDocument menyimpan dokumen baru. replaceType = true menghapus dokumen lain dengan jenis sama
+// (untuk NIB & KTP yang hanya boleh satu).
+func (r *PartnerRepository) AddOnboardingDocument(doc *partner.OnboardingDocument, replaceType bool) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		log.Printf("Error starting tx for onboarding document: %v", err)
+		return errors.New("gagal memulai transaksi")
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if replaceType {
+		_, err = tx.Exec(`DELETE FROM partner_onboarding_documents WHERE application_id = $1 AND type = $2`, doc.ApplicationID, doc.Type)
+		if err != nil {
+			log.Printf("Error replacing onboarding document %s for application ID %d: %v", doc.Type, doc.ApplicationID, err)
+			return errors.New("gagal menyimpan dokumen onboarding")
+		}
+	}
+	err = tx.QueryRow(`
+		INSERT INTO partner_onboarding_documents (application_id, type, url)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`, doc.ApplicationID, doc.Type, doc.URL).Scan(&doc.ID, &doc.CreatedAt)
+	if err != nil {
+		log.Printf("Error adding onboarding document for application ID %d: %v", doc.ApplicationID, err)
+		return errors.New("gagal menyimpan dokumen onboarding")
+	}
+	return nil
+}
+
+// DeleteOnboardingDocument menghapus dokumen sebuah pengajuan
+func (r *PartnerRepository) DeleteOnboardingDocument(applicationID, documentID int) error {
+	result, err := r.db.Exec(`DELETE FROM partner_onboarding_documents WHERE id = $1 AND application_id = $2`, documentID, applicationID)
+	if err != nil {
+		log.Printf("Error deleting onboarding document ID %d: %v", documentID, err)
+		return err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// --- Status & Riwayat ---
+
+// TransitionOnboardingApplication memindahkan status pengajuan secara atomik, mencatat riwayat,
+// dan opsional mengubah status xetor_partners. Mengembalikan sql.ErrNoRows jika status saat ini tidak sesuai.
+func (r *PartnerRepository) TransitionOnboardingApplication(args partner.ArgsOnboardingTransition) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		log.Printf("Error starting tx for onboarding transition: %v", err)
+		return errors.New("gagal memulai transaksi")
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var partnerID int
+	var fromStatus string
+	err = tx.QueryRow(`SELECT partner_id, status FROM partner_onboarding_applications WHERE id = $1 FOR UPDATE`, args.ApplicationID).
+		Scan(&partnerID, &fromStatus)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return err
+		}
+		log.Printf("Error locking onboarding application ID %d: %v", args.ApplicationID, err)
+		return errors.New("gagal memperbarui status pengajuan")
+	}
+	allowed := false
+	for _, status := range args.FromStatuses {
+		if status == fromStatus {
+			allowed = true
+			break
+		}
+	}
+	if !allowed {
+		err = sql.ErrNoRows
+		return err
+	}
+
+	// Pengajuan oleh partner mengisi submitted_at; keputusan admin mengisi reviewed_at & review_note
+	var setClause string
+	if args.ActorType == "partner" {
+		setClause = `submitted_at = NOW(), review_note = NULL, reviewed_at = NULL`
+	} else {
+		setClause = `reviewed_at = NOW(), review_note = NULLIF($3, '')`
+	}
+	query := `UPDATE partner_onboarding_applications SET status = $1, ` + setClause + `, updated_at = NOW() WHERE id = $2`
+	queryArgs := []interface{}{args.ToStatus, args.ApplicationID}
+	if args.ActorType != "partner" {
+		queryArgs = append(queryArgs, args.Note)
+	}
+	if _, err = tx.Exec(query, queryArgs...); err != nil {
+		log.Printf("Error updating onboarding application ID %d status: %v", args.ApplicationID, err)
+		return errors.New("gagal memperbarui status pengajuan")
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO partner_status_histories (partner_id, application_id, from_status, to_status, actor_type, note)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))`,
+		partnerID, args.ApplicationID, fromStatus, args.ToStatus, args.ActorType, strings.TrimSpace(args.Note))
+	if err != nil {
+		log.Printf("Error recording status history for application ID %d: %v", args.ApplicationID, err)
+		return errors.New("gagal mencatat riwayat status")
+	}
+
+	if args.XetorStatus != "" {
+		_, err = tx.Exec(`UPDATE xetor_partners SET status = $1, updated_at = NOW() WHERE partner_id = $2`, args.XetorStatus, partnerID)
+		if err != nil {
+			log.Printf("Error updating xetor partner status for partner ID %d: %v", partnerID, err)
+			return errors.New("gagal memperbarui status partner")
+		}
+	}
+	log.Printf("Onboarding application ID %d moved %s -> %s by %s", args.ApplicationID, fromStatus, args.ToStatus, args.ActorType)
+	return nil
+}
+
+// GetPartnerStatusHistory mengambil riwayat status partner, terlama dulu
+func (r *PartnerRepository) GetPartnerStatusHistory(partnerID int) ([]partner.PartnerStatusHistory, error) {
+	rows, err := r.db.Query(`
+		SELECT id, partner_id, application_id, from_status, to_status, actor_type, note, created_at
+		FROM partner_status_histories WHERE partner_id = $1
+		ORDER BY created_at, id`, partnerID)
+	if err != nil {
+		log.Printf("Error getting status history for partner ID %d: %v", partnerID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []partner.PartnerStatusHistory{}
+	for rows.Next() {
+		var h partner.PartnerStatusHistory
+		if err := rows.Scan(&h.ID, &h.PartnerID, &h.ApplicationID, &h.FromStatus, &h.ToStatus, &h.ActorType, &h.Note, &h.CreatedAt); err != nil {
+			log.Printf("Error scanning partner status history: %v", err)
+			return nil, err
+		}
+		history = append(history, h)
+	}
+	return history, rows.Err()
+}
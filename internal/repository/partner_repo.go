@@ -2,6 +2,7 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
@@ -11,6 +12,7 @@ import (
 	"golang.org/x/crypto/bcrypt"
 	"xetor.id/backend/internal/config"
 	"xetor.id/backend/internal/domain/partner"
+	"xetor.id/backend/internal/domain/user"
 )
 
 type PartnerRepository struct {
@@ -80,6 +82,13 @@ func (r *PartnerRepository) SavePartner(p *partner.Partner) error {
 		return errors.New("gagal mendaftarkan partner ke sistem Xetor")
 	}
 
+	// 5. Buat baris staf owner (login tetap memakai email & password partner)
+	_, err = tx.Exec(`INSERT INTO partner_staff (partner_id, name, role) VALUES ($1, $2, 'owner')`, p.ID, p.BusinessName)
+	if err != nil {
+		log.Printf("Error saving owner staff for partner ID %d: %v", p.ID, err)
+		return errors.New("gagal menyimpan data partner")
+	}
+
 	log.Printf("Partner %s (ID: %d) successfully saved with Pending status.", p.Email, p.ID)
 	return err // Akan nil jika commit berhasil
 }
@@ -243,15 +252,18 @@ func (r *PartnerRepository) UpdatePassword(id int, newHashedPassword string) err
 // GetAddressByPartnerID mengambil alamat usaha partner
 func (r *PartnerRepository) GetAddressByPartnerID(partnerID int) (*partner.PartnerAddress, error) {
 	query := `
-		SELECT id, partner_id, address, city_regency, province, postal_code, latitude, longitude, created_at, updated_at
+		SELECT id, partner_id, address, city_regency, province, postal_code, latitude, longitude, pickup_enabled,
+		       service_radius_km, service_area_polygon, daily_pickup_capacity, min_pickup_weight, created_at, updated_at
 		FROM partner_addresses
 		WHERE partner_id = $1`
 
 	var addr partner.PartnerAddress
+	var polygonRaw []byte
 	err := r.db.QueryRow(query, partnerID).Scan(
 		&addr.ID, &addr.PartnerID, &addr.Address, &addr.CityRegency,
 		&addr.Province, &addr.PostalCode, &addr.Latitude, &addr.Longitude,
-		&addr.CreatedAt, &addr.UpdatedAt,
+		&addr.PickupEnabled, &addr.ServiceRadiusKm, &polygonRaw, &addr.DailyPickupCapacity,
+		&addr.MinPickupWeight, &addr.CreatedAt, &addr.UpdatedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -260,14 +272,20 @@ func (r *PartnerRepository) GetAddressByPartnerID(partnerID int) (*partner.Partn
 		log.Printf("Error getting address for partner ID %d: %v", partnerID, err)
 		return nil, err
 	}
+	if len(polygonRaw) > 0 {
+		if err := json.Unmarshal(polygonRaw, &addr.ServiceAreaPolygon); err != nil {
+			log.Printf("Error decoding service area polygon for partner ID %d: %v", partnerID, err)
+			return nil, err
+		}
+	}
 	return &addr, nil
 }
 
 // UpsertAddress membuat atau mengupdate alamat partner (INSERT ON CONFLICT)
-func (r *PartnerRepository) UpsertAddress(addr *partner.PartnerAddress) error {
+func (r *PartnerRepository) UpsertAddress(addr *partner.PartnerAddress, pickupEnabled sql.NullBool) error {
 	query := `
-		INSERT INTO partner_addresses (partner_id, address, city_regency, province, postal_code, latitude, longitude, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW(), NOW())
+		INSERT INTO partner_addresses (partner_id, address, city_regency, province, postal_code, latitude, longitude, pickup_enabled, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, COALESCE($8, FALSE), NOW(), NOW())
 		ON CONFLICT (partner_id) DO UPDATE SET -- Jika partner_id sudah ada, update saja
 			address = EXCLUDED.address,
 			city_regency = EXCLUDED.city_regency,
@@ -275,8 +293,9 @@ func (r *PartnerRepository) UpsertAddress(addr *partner.PartnerAddress) error {
 			postal_code = EXCLUDED.postal_code,
 			latitude = EXCLUDED.latitude,
 			longitude = EXCLUDED.longitude,
+			pickup_enabled = COALESCE($8, partner_addresses.pickup_enabled), -- NULL = tidak diubah
 			updated_at = NOW()
-		RETURNING id, created_at, updated_at` // Kembalikan ID dan timestamp
+		RETURNING id, pickup_enabled, created_at, updated_at` // Kembalikan ID dan timestamp
 
 	var postalCode sql.NullString
 	if addr.PostalCode.Valid {
@@ -294,8 +313,8 @@ func (r *PartnerRepository) UpsertAddress(addr *partner.PartnerAddress) error {
 	}
 
 	err := r.db.QueryRow(query,
-		addr.PartnerID, addr.Address, addr.CityRegency, addr.Province, postalCode, latitude, longitude,
-	).Scan(&addr.ID, &addr.CreatedAt, &addr.UpdatedAt) // Scan ID dan timestamp baru/update
+		addr.PartnerID, addr.Address, addr.CityRegency, addr.Province, postalCode, latitude, longitude, pickupEnabled,
+	).Scan(&addr.ID, &addr.PickupEnabled, &addr.CreatedAt, &addr.UpdatedAt) // Scan ID dan timestamp baru/update
 
 	if err != nil {
 		log.Printf("Error upserting address for partner ID %d: %v", addr.PartnerID, err)
@@ -305,81 +324,40 @@ func (r *PartnerRepository) UpsertAddress(addr *partner.PartnerAddress) error {
 	return nil
 }
 
-// --- Partner Schedule Functions ---
-
-// GetScheduleByPartnerID mengambil satu baris jadwal operasional partner
-func (r *PartnerRepository) GetScheduleByPartnerID(partnerID int) (*partner.PartnerSchedule, error) {
-	query := `
-		SELECT id, partner_id, days_open,
-		       COALESCE(to_char(open_time, 'HH24:MI'), '') as open_time, -- Handle NULL time
-		       COALESCE(to_char(close_time, 'HH24:MI'), '') as close_time,-- Handle NULL time
-		       operating_status, created_at, updated_at
-		FROM partner_schedules
-		WHERE partner_id = $1`
-
-	var ps partner.PartnerSchedule
-	var daysOpenDB sql.NullString // Baca days_open sebagai NullString
-
-	err := r.db.QueryRow(query, partnerID).Scan(
-		&ps.ID, &ps.PartnerID, &daysOpenDB, &ps.OpenTime, &ps.CloseTime,
-		&ps.OperatingStatus, &ps.CreatedAt, &ps.UpdatedAt,
-	)
-
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil // Belum punya jadwal
+// UpdateServiceArea menyimpan pengaturan area & kapasitas jemput pada alamat partner.
+// Mengembalikan sql.ErrNoRows jika partner belum memiliki alamat.
+func (r *PartnerRepository) UpdateServiceArea(partnerID int, req *partner.UpdateServiceAreaRequest) error {
+	var polygon interface{} // NULL = pakai radius
+	if len(req.ServiceAreaPolygon) > 0 {
+		polygonJSON, err := json.Marshal(req.ServiceAreaPolygon)
+		if err != nil {
+			return err
 		}
-		log.Printf("Error getting schedule for partner ID %d: %v", partnerID, err)
-		return nil, err
+		polygon = string(polygonJSON)
 	}
 
-	// Konversi string DB ke slice
-	ps.DaysOpen = partner.DaysOpenFromString(daysOpenDB)
-
-	return &ps, nil
-}
-
-// UpsertSchedule membuat atau mengupdate satu baris jadwal partner
-func (r *PartnerRepository) UpsertSchedule(sched *partner.PartnerSchedule) error {
 	query := `
-		INSERT INTO partner_schedules (partner_id, days_open, open_time, close_time, operating_status, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, NOW(), NOW())
-		ON CONFLICT (partner_id) DO UPDATE SET
-			days_open = EXCLUDED.days_open,
-			open_time = EXCLUDED.open_time,
-			close_time = EXCLUDED.close_time,
-			operating_status = EXCLUDED.operating_status,
-			updated_at = NOW()
-		RETURNING id, created_at, updated_at`
-
-	// Konversi slice ke string DB
-	daysOpenDB := partner.DaysOpenToString(sched.DaysOpen)
-
-	// Konversi string HH:MM ke tipe TIME atau NULL jika kosong/invalid (lebih aman di service)
-	var openTime, closeTime interface{} // Gunakan interface{} untuk handle NULL
-	if sched.OpenTime == "" {
-		openTime = nil
-	} else {
-		openTime = sched.OpenTime
-	}
-	if sched.CloseTime == "" {
-		closeTime = nil
-	} else {
-		closeTime = sched.CloseTime
-	}
-
-	err := r.db.QueryRow(query,
-		sched.PartnerID, daysOpenDB, openTime, closeTime, sched.OperatingStatus,
-	).Scan(&sched.ID, &sched.CreatedAt, &sched.UpdatedAt) // Scan ID dan timestamp baru/update
-
+		UPDATE partner_addresses
+		SET pickup_enabled = $1, service_radius_km = $2, service_area_polygon = $3::jsonb,
+		    daily_pickup_capacity = $4, min_pickup_weight = $5, updated_at = NOW()
+		WHERE partner_id = $6`
+	result, err := r.db.Exec(query,
+		req.PickupEnabled, req.ServiceRadiusKm, polygon, req.DailyPickupCapacity, req.MinPickupWeight, partnerID,
+	)
 	if err != nil {
-		log.Printf("Error upserting schedule for partner ID %d: %v", sched.PartnerID, err)
-		return errors.New("gagal menyimpan jadwal usaha")
+		log.Printf("Error updating service area for partner ID %d: %v", partnerID, err)
+		return err
+	}
+	rows, _ := result.RowsAffected()
+	if rows == 0 {
+		return sql.ErrNoRows
 	}
-	log.Printf("Schedule upserted with ID: %d for Partner ID: %d", sched.ID, sched.PartnerID)
+	log.Printf("Service area updated for Partner ID: %d", partnerID)
 	return nil
 }
 
+// --- Partner Schedule Functions: lihat schedule_repo.go ---
+
 // --- Partner Waste Price Functions ---
 
 // FindOrCreateWastePriceHeader mencari atau membuat baris header di partner_waste_prices
@@ -411,8 +389,8 @@ func (r *PartnerRepository) FindOrCreateWastePriceHeader(partnerID int) (int, er
 	return headerID, nil
 }
 
-// CreateWastePriceDetail menambahkan item harga sampah baru
-func (r *PartnerRepository) CreateWastePriceDetail(detail *partner.PartnerWastePriceDetail) error {
+// CreateWastePriceDetail menambahkan item harga sampah baru beserta versi harga pertamanya
+func (r *PartnerRepository) CreateWastePriceDetail(detail *partner.PartnerWastePriceDetail) (err error) {
 	query := `
 		INSERT INTO partner_waste_price_details
 			(partner_waste_price_id, waste_detail_id, image, name, price, unit, xpoin) -- Tambah waste_detail_id
@@ -431,7 +409,23 @@ func (r *PartnerRepository) CreateWastePriceDetail(detail *partner.PartnerWasteP
 		wasteDetailID = detail.WasteDetailID
 	}
 
-	err = r.db.QueryRow(query,
+	tx, err := r.db.Begin()
+	if err != nil {
+		log.Printf("Error starting tx for waste price detail: %v", err)
+		return errors.New("gagal memulai transaksi")
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	err = tx.QueryRow(query,
 		detail.PartnerWastePriceID, wasteDetailID, detail.Image, detail.Name, // Masukkan wasteDetailID
 		priceFloat, detail.Unit, detail.Xpoin,
 	).Scan(&detail.ID, &detail.CreatedAt, &detail.UpdatedAt)
@@ -440,6 +434,9 @@ func (r *PartnerRepository) CreateWastePriceDetail(detail *partner.PartnerWasteP
 		log.Printf("Error creating waste price detail: %v", err)
 		return errors.New("gagal menyimpan detail harga sampah")
 	}
+	if err = recordWastePriceVersion(tx, detail.ID, partner.WastePriceSourceManual); err != nil {
+		return err
+	}
 	log.Printf("Waste price detail created with ID: %d", detail.ID)
 	return nil
 }
@@ -510,8 +507,8 @@ func (r *PartnerRepository) GetWastePriceDetailByID(detailID int, partnerID int)
 	return &pd, nil
 }
 
-// UpdateWastePriceDetail mengupdate item harga sampah
-func (r *PartnerRepository) UpdateWastePriceDetail(detailID int, partnerID int, detail *partner.PartnerWastePriceDetail) error {
+// UpdateWastePriceDetail mengupdate item harga sampah; perubahan harga/satuan/katalog dicatat sebagai versi baru
+func (r *PartnerRepository) UpdateWastePriceDetail(detailID int, partnerID int, detail *partner.PartnerWastePriceDetail) (err error) {
 	headerID, err := r.FindOrCreateWastePriceHeader(partnerID)
 	if err != nil {
 		return err
@@ -549,32 +546,71 @@ func (r *PartnerRepository) UpdateWastePriceDetail(detailID int, partnerID int,
 	query := fmt.Sprintf("UPDATE partner_waste_price_details SET %s, updated_at = NOW() WHERE id = $%d AND partner_waste_price_id = $%d",
 		strings.Join(fields, ", "), argId, argId+1)
 
-	result, err := r.db.Exec(query, args...)
+	tx, err := r.db.Begin()
+	if err != nil {
+		log.Printf("Error starting tx for waste price detail ID %d: %v", detailID, err)
+		return errors.New("gagal memulai transaksi")
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	result, err := tx.Exec(query, args...)
 	if err != nil {
 		log.Printf("Error updating waste price detail ID %d: %v", detailID, err)
 		return errors.New("gagal mengupdate detail harga sampah")
 	}
-	rowsAffected, _ := result.RowsAffected(); if rowsAffected == 0 { return sql.ErrNoRows }
+	rowsAffected, _ := result.RowsAffected(); if rowsAffected == 0 { err = sql.ErrNoRows; return err }
+	if err = recordWastePriceVersion(tx, detailID, partner.WastePriceSourceManual); err != nil {
+		return err
+	}
 	log.Printf("Waste price detail updated for ID: %d", detailID)
 	return nil
 }
 
-// DeleteWastePriceDetail menghapus item harga sampah
-func (r *PartnerRepository) DeleteWastePriceDetail(detailID int, partnerID int) error {
+// DeleteWastePriceDetail menghapus item harga sampah; riwayat versinya tetap disimpan
+func (r *PartnerRepository) DeleteWastePriceDetail(detailID int, partnerID int) (err error) {
 	headerID, err := r.FindOrCreateWastePriceHeader(partnerID)
 	if err != nil {
 		return err
 	}
 
+	tx, err := r.db.Begin()
+	if err != nil {
+		log.Printf("Error starting tx for deleting waste price detail ID %d: %v", detailID, err)
+		return errors.New("gagal memulai transaksi")
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	if err = closeWastePriceVersions(tx, detailID); err != nil {
+		return err
+	}
 	query := `DELETE FROM partner_waste_price_details WHERE id = $1 AND partner_waste_price_id = $2`
-	result, err := r.db.Exec(query, detailID, headerID)
+	result, err := tx.Exec(query, detailID, headerID)
 	if err != nil {
 		log.Printf("Error deleting waste price detail ID %d: %v", detailID, err)
 		return errors.New("gagal menghapus detail harga sampah")
 	}
 	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected == 0 {
-		return sql.ErrNoRows /* Not found or not owned */
+		err = sql.ErrNoRows /* Not found or not owned */
+		return err
 	}
 	log.Printf("Waste price detail deleted for ID: %d", detailID)
 	return nil
@@ -741,9 +777,11 @@ func (r *PartnerRepository) GetDepositHistoryByPartnerID(partnerID int) ([]partn
 	queryHeader := `
 		SELECT
 			pdh.id, pdh.partner_id, pdh.user_id, u.fullname as user_name, u.email as user_email,
-			pdh.total_weight, pdh.total_xpoin, pdh.transaction_time, pdh.created_at, pdh.updated_at
+			pdh.total_weight, pdh.total_xpoin, pdh.transaction_time, pdh.created_at, pdh.updated_at,
+			pdh.staff_id, ps.name as staff_name
 		FROM partner_deposit_histories pdh
 		JOIN users u ON pdh.user_id = u.id -- Join dengan tabel users
+		LEFT JOIN partner_staff ps ON ps.id = pdh.staff_id
 		WHERE pdh.partner_id = $1
 		ORDER BY pdh.transaction_time DESC`
 
@@ -765,6 +803,7 @@ func (r *PartnerRepository) GetDepositHistoryByPartnerID(partnerID int) ([]partn
 		err := rowsHeader.Scan(
 			&header.ID, &header.PartnerID, &header.UserID, &header.UserName, &header.UserEmail,
 			&totalWeight, &header.TotalXpoin, &header.TransactionTime, &header.CreatedAt, &header.UpdatedAt,
+			&header.StaffID, &header.StaffName,
 		)
 		if err != nil {
 			log.Printf("Error scanning deposit history header row for partner ID %d: %v", partnerID, err)
@@ -775,6 +814,7 @@ func (r *PartnerRepository) GetDepositHistoryByPartnerID(partnerID int) ([]partn
 			header.TotalWeight = sql.NullString{String: fmt.Sprintf("%.2f", totalWeight.Float64), Valid: true}
 		}
 		header.Details = []partner.DepositHistoryDetailItem{} // Inisialisasi slice detail
+		header.Photos = []user.DepositPhoto{}
 
 		historiesMap[header.ID] = &header
 		historyOrder = append(historyOrder, header.ID) // Simpan urutan ID header
@@ -795,10 +835,12 @@ func (r *PartnerRepository) GetDepositHistoryByPartnerID(partnerID int) ([]partn
 		SELECT
 			pdd.id, pdd.partner_deposit_history_id, pdd.waste_detail_id, -- Ganti dari waste_type_id
             wd.name as waste_name, -- Ambil nama dari waste_details
-            pdd.waste_weight, pdd.xpoin, pdd.photo, pdd.notes, pdd.status
+            pdd.waste_weight, pdd.unit, pdd.quantity, pdd.scale_reading_id, pdd.xpoin, pdd.photo, pdd.notes, pdd.status,
+            pdd.partner_waste_price_version_id, pwpv.price
         FROM partner_deposit_history_details pdd
         -- JOIN ke waste_details berdasarkan waste_detail_id
         LEFT JOIN waste_details wd ON pdd.waste_detail_id = wd.id
+        LEFT JOIN partner_waste_price_versions pwpv ON pwpv.id = pdd.partner_waste_price_version_id
         JOIN partner_deposit_histories pdh ON pdd.partner_deposit_history_id = pdh.id
         WHERE pdh.partner_id = $1`
 
@@ -814,10 +856,12 @@ func (r *PartnerRepository) GetDepositHistoryByPartnerID(partnerID int) ([]partn
 		var detail partner.DepositHistoryDetailItem
 		var headerID int
 		var wasteWeight sql.NullFloat64 // Baca DECIMAL sbg NullFloat64
+		var versionPrice sql.NullFloat64
 
 		err := rowsDetails.Scan(
 			&detail.ID, &headerID, &detail.WasteDetailID, &detail.WasteName,
-            &wasteWeight, &detail.Xpoin, &detail.Photo, &detail.Notes, &detail.Status,
+            &wasteWeight, &detail.Unit, &detail.Quantity, &detail.ScaleReadingID, &detail.Xpoin, &detail.Photo, &detail.Notes, &detail.Status,
+			&detail.PriceVersionID, &versionPrice,
 		)
 		if err != nil {
 			log.Printf("Error scanning deposit history detail row for partner ID %d: %v", partnerID, err)
@@ -826,6 +870,10 @@ func (r *PartnerRepository) GetDepositHistoryByPartnerID(partnerID int) ([]partn
 		if wasteWeight.Valid {
 			detail.WasteWeight = sql.NullString{String: fmt.Sprintf("%.2f", wasteWeight.Float64), Valid: true}
 		}
+		if versionPrice.Valid {
+			detail.PricePerUnit = sql.NullString{String: fmt.Sprintf("%.2f", versionPrice.Float64), Valid: true}
+		}
+		detail.Photos = []user.DepositPhoto{}
 
 		// Masukkan detail ke header yang benar di map
 		if header, ok := historiesMap[headerID]; ok {
@@ -837,6 +885,54 @@ func (r *PartnerRepository) GetDepositHistoryByPartnerID(partnerID int) ([]partn
 		return nil, err
 	}
 
+	// Query ketiga untuk foto bukti: foto item masuk ke detail, sisanya ke header
+	queryPhotos := `
+		SELECT dp.id, dp.partner_deposit_history_id, dp.partner_deposit_history_detail_id, dp.deposit_dispute_id, dp.url, dp.thumbnail_url
+		FROM deposit_photos dp
+		JOIN partner_deposit_histories pdh ON dp.partner_deposit_history_id = pdh.id
+		WHERE pdh.partner_id = $1
+		ORDER BY dp.id`
+
+	rowsPhotos, err := r.db.Query(queryPhotos, partnerID)
+	if err != nil {
+		log.Printf("Error querying deposit photos for partner ID %d: %v", partnerID, err)
+		return nil, err
+	}
+	defer rowsPhotos.Close()
+
+	for rowsPhotos.Next() {
+		var photo user.DepositPhoto
+		var headerID int
+		var thumbnailURL sql.NullString
+		if err := rowsPhotos.Scan(&photo.ID, &headerID, &photo.DetailID, &photo.DepositDisputeID, &photo.URL, &thumbnailURL); err != nil {
+			log.Printf("Error scanning deposit photo row for partner ID %d: %v", partnerID, err)
+			return nil, err
+		}
+		photo.ThumbnailURL = thumbnailURL.String
+
+		header, ok := historiesMap[headerID]
+		if !ok {
+			continue
+		}
+		attached := false
+		if photo.DetailID.Valid {
+			for i := range header.Details {
+				if header.Details[i].ID == int(photo.DetailID.Int32) {
+					header.Details[i].Photos = append(header.Details[i].Photos, photo)
+					attached = true
+					break
+				}
+			}
+		}
+		if !attached {
+			header.Photos = append(header.Photos, photo)
+		}
+	}
+	if err = rowsPhotos.Err(); err != nil {
+		log.Printf("Error after iterating deposit photo rows for partner ID %d: %v", partnerID, err)
+		return nil, err
+	}
+
 	// Susun hasil akhir sesuai urutan header
 	finalHistories := make([]partner.DepositHistoryHeader, len(historyOrder))
 	for i, id := range historyOrder {
@@ -1099,7 +1195,7 @@ func (r *PartnerRepository) ExecutePartnerTopupTransaction(partnerID int, amount
 // --- Partner Transfer Xpoin ---
 
 // ExecutePartnerTransferTransaction memproses transfer xpoin dari partner ke partner lain atau user
-func (r *PartnerRepository) ExecutePartnerTransferTransaction(senderPartnerID, amount int, recipientUserID *int, recipientPartnerID *int, recipientEmail string) (string, error) {
+func (r *PartnerRepository) ExecutePartnerTransferTransaction(senderPartnerID, amount int, recipientUserID *int, recipientPartnerID *int, recipientEmail string, staffID sql.NullInt64) (string, error) {
 	tx, err := r.db.Begin()
 	if err != nil { /* handle tx begin error */
 	}
@@ -1162,12 +1258,12 @@ func (r *PartnerRepository) ExecutePartnerTransferTransaction(senderPartnerID, a
 
 	// 3. Catat riwayat transfer partner
 	queryInsertHistory := `
-        INSERT INTO partner_transfer_histories (partner_id, amount, recipient_email, status, transfer_time)
-        VALUES ($1, $2, $3, 'Completed', NOW())
+        INSERT INTO partner_transfer_histories (partner_id, amount, recipient_email, status, transfer_time, staff_id)
+        VALUES ($1, $2, $3, 'Completed', NOW(), $4)
         RETURNING id`
 	var transferID int
 	// Simpan amount sebagai DECIMAL (meskipun asalnya int xpoin)
-	err = tx.QueryRow(queryInsertHistory, senderPartnerID, float64(amount), recipientEmail).Scan(&transferID)
+	err = tx.QueryRow(queryInsertHistory, senderPartnerID, float64(amount), recipientEmail, staffID).Scan(&transferID)
 	if err != nil {
 		log.Printf("Error inserting partner transfer history: %v", err)
 		return "", errors.New("gagal mencatat riwayat transfer partner")
@@ -1250,12 +1346,18 @@ func (r *PartnerRepository) GetWastePriceInfoForCalculation(detailID int, partne
 	headerID, err := r.FindOrCreateWastePriceHeader(partnerID)
 	if err != nil { return nil, err }
 
-	query := `SELECT price, xpoin, unit, waste_detail_id FROM partner_waste_price_details WHERE id = $1 AND partner_waste_price_id = $2`
+	query := `
+		SELECT pwpd.price, pwpd.xpoin, pwpd.unit, pwpd.waste_detail_id, wd.average_unit_weight, v.id
+		FROM partner_waste_price_details pwpd
+		LEFT JOIN waste_details wd ON wd.id = pwpd.waste_detail_id
+		LEFT JOIN partner_waste_price_versions v
+			ON v.partner_waste_price_detail_id = pwpd.id AND v.status = 'Applied' AND v.effective_until IS NULL
+		WHERE pwpd.id = $1 AND pwpd.partner_waste_price_id = $2`
 
 	var info partner.WastePriceInfo // Gunakan struct dari model
 	var priceDB float64
 	// Scan waste_detail_id
-	err = r.db.QueryRow(query, detailID, headerID).Scan(&priceDB, &info.XpoinPerUnit, &info.Unit, &info.WasteDetailID)
+	err = r.db.QueryRow(query, detailID, headerID).Scan(&priceDB, &info.XpoinPerUnit, &info.Unit, &info.WasteDetailID, &info.AverageUnitWeight, &info.VersionID)
 	if err != nil {
 		if err == sql.ErrNoRows { return nil, errors.New("detail harga sampah tidak ditemukan") }
 		log.Printf("Error getting waste price info for detail ID %d: %v", detailID, err)
@@ -1268,7 +1370,8 @@ func (r *PartnerRepository) GetWastePriceInfoForCalculation(detailID int, partne
 // --- Partner Deposit Creation ---
 
 // ExecuteDepositCreationTransaction menjalankan semua operasi DB sisi partner untuk deposit baru
-func (r *PartnerRepository) ExecuteDepositCreationTransaction(args partner.ArgsDepositCreation) (int, error) { // Parameter dari model
+// Return bernama agar semua error (termasuk commit) membatalkan transaksi dan sampai ke pemanggil
+func (r *PartnerRepository) ExecuteDepositCreationTransaction(args partner.ArgsDepositCreation) (headerID int, err error) { // Parameter dari model
 	tx, err := r.db.Begin()
 	if err != nil { log.Printf("Error starting tx: %v", err); return 0, errors.New("gagal memulai transaksi") }
 	defer func() {
@@ -1277,6 +1380,11 @@ func (r *PartnerRepository) ExecuteDepositCreationTransaction(args partner.ArgsD
 		{ err = tx.Commit(); if err != nil { log.Printf("Error committing tx: %v", err) } }
 	}()
 
+	// 0. Pakai token/nonce QR di transaksi yang sama agar tidak hangus jika deposit gagal
+	if args.Consume != nil {
+		if err = args.Consume(tx); err != nil { return 0, err }
+	}
+
 	// 1. Cek & Kurangi Xpoin Partner
 	queryUpdatePartnerWallet := `UPDATE partner_wallets SET xpoin = xpoin - $1, updated_at = NOW() WHERE partner_id = $2 AND xpoin >= $1`
 	result, err := tx.Exec(queryUpdatePartnerWallet, args.TotalXpoin, args.PartnerID)
@@ -1288,26 +1396,75 @@ func (r *PartnerRepository) ExecuteDepositCreationTransaction(args partner.ArgsD
 	}
 
 	// 2. Insert Header Deposit Partner
-	queryInsertHeader := `INSERT INTO partner_deposit_histories (partner_id, user_id, total_weight, total_xpoin, transaction_time) VALUES ($1, $2, $3, $4, $5) RETURNING id`
+	queryInsertHeader := `INSERT INTO partner_deposit_histories (partner_id, user_id, total_weight, total_xpoin, transaction_time, client_id, staff_id) VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`
 	var depositHeaderID int
-	err = tx.QueryRow(queryInsertHeader, args.PartnerID, args.UserID, args.TotalWeight, args.TotalXpoin, args.TransactionTime).Scan(&depositHeaderID)
-	if err != nil { return 0, errors.New("gagal menyimpan header deposit") }
+	err = tx.QueryRow(queryInsertHeader, args.PartnerID, args.UserID, args.TotalWeight, args.TotalXpoin, args.TransactionTime, args.ClientID, args.StaffID).Scan(&depositHeaderID)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return 0, errors.New("deposit dengan client_id ini sudah tersimpan")
+		}
+		return 0, errors.New("gagal menyimpan header deposit")
+	}
 
 	// 3. Insert Detail Deposit Partner
-	queryInsertDetail := `INSERT INTO partner_deposit_history_details (partner_deposit_history_id, waste_detail_id, waste_weight, deposit_method_id, photo, xpoin, notes, status) VALUES ($1, $2, $3, $4, $5, $6, $7, 'Verified')`
+	queryInsertDetail := `INSERT INTO partner_deposit_history_details (partner_deposit_history_id, waste_detail_id, waste_weight, deposit_method_id, photo, xpoin, notes, status, unit, quantity, scale_reading_id, partner_waste_price_version_id) VALUES ($1, $2, $3, $4, $5, $6, $7, 'Verified', $8, $9, $10, $11) RETURNING id`
 	stmtDetail, err := tx.Prepare(queryInsertDetail); if err != nil { return 0, errors.New("gagal menyiapkan detail deposit") }
 	defer stmtDetail.Close()
+	// Hasil timbang hanya bisa dipakai sekali; baris yang sudah terpakai membatalkan seluruh transaksi
+	queryUseScaleReading := `UPDATE scale_readings SET used_at = NOW(), partner_deposit_history_detail_id = $1 WHERE id = $2 AND partner_id = $3 AND (used_at IS NULL OR (deposit_fraud_case_id = $4 AND partner_deposit_history_detail_id IS NULL))`
+	queryInsertPhoto := `INSERT INTO deposit_photos (partner_deposit_history_id, partner_deposit_history_detail_id, url, thumbnail_url) VALUES ($1, $2, $3, NULLIF($4, ''))`
 	for _, item := range args.Items { // args.Items sekarang tipe []partner.DepositWasteItem
-		_, err = stmtDetail.Exec(
+		photo := args.PhotoURL // Foto utama item, jika tidak ada pakai foto deposit
+		if len(item.Photos) > 0 {
+			photo = sql.NullString{String: item.Photos[0].URL, Valid: true}
+		}
+		var detailID int
+		err = stmtDetail.QueryRow(
 			depositHeaderID,
 			item.WasteDetailID, // ID dari waste_details (sudah diisi service)
 			item.Weight,
 			args.DepositMethodID,
-			args.PhotoURL,
+			photo,
 			item.CalculatedXpoin, // Xpoin per item (sudah diisi service)
 			args.Notes,
-		)
+			item.Unit,
+			sql.NullInt32{Int32: int32(item.Quantity), Valid: item.Quantity > 0},
+			sql.NullInt32{Int32: int32(item.ScaleReadingID), Valid: item.ScaleReadingID > 0},
+			item.PriceVersionID, // Versi harga saat Xpoin dihitung
+		).Scan(&detailID)
 		if err != nil { return 0, errors.New("gagal menyimpan item detail deposit") }
+
+		if item.ScaleReadingID > 0 {
+			resultReading, errReading := tx.Exec(queryUseScaleReading, detailID, item.ScaleReadingID, args.PartnerID, args.FraudCaseID)
+			if errReading != nil { err = errReading; return 0, errors.New("gagal menandai hasil timbang") }
+			if n, _ := resultReading.RowsAffected(); n == 0 {
+				err = fmt.Errorf("hasil timbang ID %d sudah dipakai", item.ScaleReadingID)
+				return 0, err
+			}
+		}
+
+		for _, p := range item.Photos {
+			_, err = tx.Exec(queryInsertPhoto, depositHeaderID, detailID, p.URL, p.ThumbnailURL)
+			if err != nil { return 0, errors.New("gagal menyimpan foto item deposit") }
+		}
+
+		// Sampah yang disetor menambah stok fisik partner
+		if item.WasteDetailID.Valid {
+			err = applyInventoryMovement(tx, partner.InventoryMovement{
+				PartnerID:               args.PartnerID,
+				WasteDetailID:           int(item.WasteDetailID.Int32),
+				Type:                    partner.InventoryMovementDeposit,
+				WeightChange:            item.Weight,
+				QuantityChange:          item.Quantity,
+				PartnerDepositHistoryID: sql.NullInt32{Int32: int32(depositHeaderID), Valid: true},
+				StaffID:                 args.StaffID,
+			}, false)
+			if err != nil { return 0, err }
+		}
+	}
+	for _, p := range args.Photos {
+		_, err = tx.Exec(queryInsertPhoto, depositHeaderID, nil, p.URL, p.ThumbnailURL)
+		if err != nil { return 0, errors.New("gagal menyimpan foto deposit") }
 	}
 
 	// 4. Update Partner Statistics (Waste & Transaction)
@@ -1328,7 +1485,22 @@ func (r *PartnerRepository) ExecuteDepositCreationTransaction(args partner.ArgsD
 	if err != nil { return 0, errors.New("gagal update data pelanggan partner")}
 
 	log.Printf("Partner deposit tx successful. HeaderID: %d", depositHeaderID)
-	return depositHeaderID, err // Akan nil jika commit ok
+	return depositHeaderID, nil // err diisi defer jika commit gagal
+}
+
+// FindDepositByClientID mengambil deposit partner yang disinkronkan dengan client_id tertentu, nil jika belum ada
+func (r *PartnerRepository) FindDepositByClientID(partnerID int, clientID string) (*partner.DepositHistoryHeader, error) {
+	query := `SELECT id, partner_id, user_id, total_xpoin, transaction_time FROM partner_deposit_histories WHERE partner_id = $1 AND client_id = $2`
+	var h partner.DepositHistoryHeader
+	err := r.db.QueryRow(query, partnerID, clientID).Scan(&h.ID, &h.PartnerID, &h.UserID, &h.TotalXpoin, &h.TransactionTime)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("Error finding deposit by client ID %s for partner ID %d: %v", clientID, partnerID, err)
+		return nil, err
+	}
+	return &h, nil
 }
 
 // UpdateUserDepositHistoryIDReference menyimpan referensi ID riwayat user di riwayat partner
@@ -1346,4 +1518,72 @@ func (r *PartnerRepository) UpdateUserDepositHistoryIDReference(partnerDepositHi
 		// return errors.New("riwayat deposit partner tidak ditemukan saat update referensi") // Mungkin tidak perlu gagalkan proses?
 	}
 	return nil
-}
\ No newline at end of file
+}
+// ApplyWastePriceImport menyimpan hasil import harga sampah (buat, ubah, hapus) secara atomik
+func (r *PartnerRepository) ApplyWastePriceImport(changes *partner.WastePriceImportChanges) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		log.Printf("Error starting tx for waste price import: %v", err)
+		return errors.New("gagal memulai transaksi")
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	for _, id := range changes.DeleteIDs {
+		if err = closeWastePriceVersions(tx, id); err != nil {
+			return err
+		}
+		result, errExec := tx.Exec(`DELETE FROM partner_waste_price_details WHERE id = $1 AND partner_waste_price_id = $2`, id, changes.HeaderID)
+		if errExec != nil {
+			log.Printf("Error deleting waste price detail ID %d during import: %v", id, errExec)
+			return errors.New("gagal menghapus detail harga sampah")
+		}
+		if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+			return fmt.Errorf("item harga ID %d tidak ditemukan, ulangi import", id)
+		}
+	}
+
+	queryUpdate := `
+		UPDATE partner_waste_price_details
+		SET name = $1, price = $2, unit = $3, xpoin = $4, waste_detail_id = $5, updated_at = NOW()
+		WHERE id = $6 AND partner_waste_price_id = $7`
+	for _, d := range changes.Updates {
+		result, errExec := tx.Exec(queryUpdate, d.Name, d.Price, d.Unit, d.Xpoin, d.WasteDetailID, d.ID, changes.HeaderID)
+		if errExec != nil {
+			log.Printf("Error updating waste price detail ID %d during import: %v", d.ID, errExec)
+			return errors.New("gagal mengupdate detail harga sampah")
+		}
+		if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+			return fmt.Errorf("item harga ID %d tidak ditemukan, ulangi import", d.ID)
+		}
+		if err = recordWastePriceVersion(tx, d.ID, partner.WastePriceSourceImport); err != nil {
+			return err
+		}
+	}
+
+	queryInsert := `
+		INSERT INTO partner_waste_price_details (partner_waste_price_id, waste_detail_id, name, price, unit, xpoin)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id`
+	for _, d := range changes.Creates {
+		var detailID int
+		if err = tx.QueryRow(queryInsert, changes.HeaderID, d.WasteDetailID, d.Name, d.Price, d.Unit, d.Xpoin).Scan(&detailID); err != nil {
+			log.Printf("Error creating waste price detail during import: %v", err)
+			return errors.New("gagal menyimpan detail harga sampah")
+		}
+		if err = recordWastePriceVersion(tx, detailID, partner.WastePriceSourceImport); err != nil {
+			return err
+		}
+	}
+	log.Printf("Waste price import applied for header ID %d: %d created, %d updated, %d deleted",
+		changes.HeaderID, len(changes.Creates), len(changes.Updates), len(changes.DeleteIDs))
+	return nil
+}
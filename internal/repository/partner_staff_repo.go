@@ -0,0 +1,152 @@
+// internal/repository/partner_staff_repo.go
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"strings"
+
+	"xetor.id/backend/internal/domain/partner"
+)
+
+// Akun staf partner (owner, cashier, finance).
+
+const partnerStaffSelect = `
+	SELECT id, partner_id, name, email, password, role, is_active, last_login_at, created_at, updated_at
+	FROM partner_staff`
+
+// scanPartnerStaff membaca satu baris hasil partnerStaffSelect
+func scanPartnerStaff(scanner interface{ Scan(dest ...interface{}) error }) (*partner.PartnerStaff, error) {
+	var st partner.PartnerStaff
+	var lastLoginAt sql.NullTime
+	err := scanner.Scan(&st.ID, &st.PartnerID, &st.Name, &st.Email, &st.Password, &st.Role, &st.IsActive,
+		&lastLoginAt, &st.CreatedAt, &st.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if lastLoginAt.Valid {
+		st.LastLoginAt = &lastLoginAt.Time
+	}
+	return &st, nil
+}
+
+// getPartnerStaff mengambil satu staf dengan kondisi WHERE tertentu, nil jika tidak ada
+func (r *PartnerRepository) getPartnerStaff(where string, args ...interface{}) (*partner.PartnerStaff, error) {
+	st, err := scanPartnerStaff(r.db.QueryRow(partnerStaffSelect+" WHERE "+where, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("Error getting partner staff (%s): %v", where, err)
+		return nil, err
+	}
+	return st, nil
+}
+
+// GetPartnerStaffByID mengambil satu staf milik partner, nil jika tidak ada
+func (r *PartnerRepository) GetPartnerStaffByID(staffID, partnerID int) (*partner.PartnerStaff, error) {
+	return r.getPartnerStaff("id = $1 AND partner_id = $2", staffID, partnerID)
+}
+
+// FindPartnerStaffByEmail mencari staf (non-owner) berdasarkan email login, nil jika tidak ada
+func (r *PartnerRepository) FindPartnerStaffByEmail(email string) (*partner.PartnerStaff, error) {
+	return r.getPartnerStaff("LOWER(email) = LOWER($1)", email)
+}
+
+// FindOrCreateOwnerStaff mengambil baris owner partner, membuatnya jika belum ada (partner lama)
+func (r *PartnerRepository) FindOrCreateOwnerStaff(partnerID int) (*partner.PartnerStaff, error) {
+	query := `
+		INSERT INTO partner_staff (partner_id, name, role)
+		SELECT id, business_name, $2 FROM partners WHERE id = $1
+		ON CONFLICT DO NOTHING`
+	if _, err := r.db.Exec(query, partnerID, partner.StaffRoleOwner); err != nil {
+		log.Printf("Error ensuring owner staff for partner ID %d: %v", partnerID, err)
+		return nil, err
+	}
+	return r.getPartnerStaff("partner_id = $1 AND role = $2", partnerID, partner.StaffRoleOwner)
+}
+
+// GetPartnerStaffByPartnerID mengambil semua staf partner, owner dulu
+func (r *PartnerRepository) GetPartnerStaffByPartnerID(partnerID int) ([]partner.PartnerStaff, error) {
+	rows, err := r.db.Query(partnerStaffSelect+` WHERE partner_id = $1 ORDER BY role = $2 DESC, created_at`, partnerID, partner.StaffRoleOwner)
+	if err != nil {
+		log.Printf("Error getting staff of partner ID %d: %v", partnerID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	staff := []partner.PartnerStaff{}
+	for rows.Next() {
+		st, err := scanPartnerStaff(rows)
+		if err != nil {
+			log.Printf("Error scanning partner staff: %v", err)
+			return nil, err
+		}
+		staff = append(staff, *st)
+	}
+	return staff, rows.Err()
+}
+
+// CreatePartnerStaff menyimpan akun staf baru; Password harus sudah berupa hash
+func (r *PartnerRepository) CreatePartnerStaff(st *partner.PartnerStaff) error {
+	query := `
+		INSERT INTO partner_staff (partner_id, name, email, password, role, is_active)
+		VALUES ($1, $2, $3, $4, $5, TRUE)
+		RETURNING id, is_active, created_at, updated_at`
+	err := r.db.QueryRow(query, st.PartnerID, st.Name, st.Email, st.Password, st.Role).
+		Scan(&st.ID, &st.IsActive, &st.CreatedAt, &st.UpdatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return errors.New("email sudah terdaftar")
+		}
+		log.Printf("Error creating staff for partner ID %d: %v", st.PartnerID, err)
+		return errors.New("gagal menyimpan akun staf")
+	}
+	return nil
+}
+
+// UpdatePartnerStaff mengubah nama, role, dan status aktif staf non-owner.
+// Mengembalikan sql.ErrNoRows jika staf tidak ada atau merupakan owner.
+func (r *PartnerRepository) UpdatePartnerStaff(st *partner.PartnerStaff) error {
+	query := `
+		UPDATE partner_staff
+		SET name = $1, role = $2, is_active = $3, updated_at = NOW()
+		WHERE id = $4 AND partner_id = $5 AND role <> $6`
+	result, err := r.db.Exec(query, st.Name, st.Role, st.IsActive, st.ID, st.PartnerID, partner.StaffRoleOwner)
+	if err != nil {
+		log.Printf("Error updating partner staff ID %d: %v", st.ID, err)
+		return err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// UpdatePartnerStaffPassword mengganti hash password staf non-owner
+func (r *PartnerRepository) UpdatePartnerStaffPassword(staffID, partnerID int, hashedPassword string) error {
+	query := `
+		UPDATE partner_staff SET password = $1, updated_at = NOW()
+		WHERE id = $2 AND partner_id = $3 AND role <> $4`
+	result, err := r.db.Exec(query, hashedPassword, staffID, partnerID, partner.StaffRoleOwner)
+	if err != nil {
+		log.Printf("Error updating password of partner staff ID %d: %v", staffID, err)
+		return err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// TouchPartnerStaffLogin mencatat waktu login terakhir staf
+func (r *PartnerRepository) TouchPartnerStaffLogin(staffID int) error {
+	_, err := r.db.Exec(`UPDATE partner_staff SET last_login_at = NOW() WHERE id = $1`, staffID)
+	if err != nil {
+		log.Printf("Error updating last login of partner staff ID %d: %v", staffID, err)
+	}
+	return err
+}
@@ -0,0 +1,373 @@
+// internal/repository/pickup_request_repo.go
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"xetor.id/backend/internal/domain/user"
+)
+
+// Permintaan jemput dibuat user dan diproses partner; query kedua sisi disatukan di file ini.
+
+// haversineKmSQL menghasilkan ekspresi SQL jarak (km) antara dua titik koordinat
+func haversineKmSQL(lat1, lng1, lat2, lng2 string) string {
+	return fmt.Sprintf(`(6371 * acos(LEAST(1.0, GREATEST(-1.0,
+		cos(radians(%[1]s)) * cos(radians(%[3]s)) * cos(radians(%[4]s) - radians(%[2]s)) +
+		sin(radians(%[1]s)) * sin(radians(%[3]s))))))`, lat1, lng1, lat2, lng2)
+}
+
+const pickupRequestColumns = `
+	SELECT pr.id, pr.user_id, u.fullname, pr.user_address_id, pr.partner_id, p.business_name, pr.status,
+	       pr.contact_name, pr.contact_phone, pr.address, pr.latitude, pr.longitude, pr.items, pr.estimated_weight,
+	       pr.slot_start, pr.slot_end, pr.notes, pr.cancelled_by, pr.cancel_reason, pr.partner_deposit_history_id,
+	       pr.scheduled_at, pr.on_the_way_at, pr.collected_at, pr.completed_at, pr.created_at, pr.updated_at`
+
+const pickupRequestFrom = `
+	FROM pickup_requests pr
+	LEFT JOIN partners p ON p.id = pr.partner_id
+	LEFT JOIN users u ON u.id = pr.user_id`
+
+// pickupRequestSelect dipakai jika jarak tidak dibutuhkan
+const pickupRequestSelect = pickupRequestColumns + `, NULL::float8` + pickupRequestFrom
+
+// scanPickupRequest membaca satu baris hasil pickupRequestColumns + kolom jarak
+func scanPickupRequest(scanner interface{ Scan(dest ...interface{}) error }) (*user.PickupRequest, error) {
+	var p user.PickupRequest
+	var itemsRaw []byte
+	var estimatedWeight float64
+	var scheduledAt, onTheWayAt, collectedAt, completedAt sql.NullTime
+	err := scanner.Scan(
+		&p.ID, &p.UserID, &p.UserName, &p.UserAddressID, &p.PartnerID, &p.PartnerName, &p.Status,
+		&p.ContactName, &p.ContactPhone, &p.Address, &p.Latitude, &p.Longitude, &itemsRaw, &estimatedWeight,
+		&p.SlotStart, &p.SlotEnd, &p.Notes, &p.CancelledBy, &p.CancelReason, &p.DepositHeaderID,
+		&scheduledAt, &onTheWayAt, &collectedAt, &completedAt, &p.CreatedAt, &p.UpdatedAt, &p.DistanceKm,
+	)
+	if err != nil {
+		return nil, err
+	}
+	p.Items = []user.PickupRequestItem{}
+	if err := json.Unmarshal(itemsRaw, &p.Items); err != nil {
+		return nil, fmt.Errorf("gagal membaca item permintaan jemput: %w", err)
+	}
+	p.EstimatedWeight = fmt.Sprintf("%.2f", estimatedWeight)
+	if scheduledAt.Valid {
+		p.ScheduledAt = &scheduledAt.Time
+	}
+	if onTheWayAt.Valid {
+		p.OnTheWayAt = &onTheWayAt.Time
+	}
+	if collectedAt.Valid {
+		p.CollectedAt = &collectedAt.Time
+	}
+	if completedAt.Valid {
+		p.CompletedAt = &completedAt.Time
+	}
+	return &p, nil
+}
+
+// queryPickupRequests menjalankan query daftar permintaan jemput
+func queryPickupRequests(db *sql.DB, query string, args ...interface{}) ([]user.PickupRequest, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pickups := []user.PickupRequest{}
+	for rows.Next() {
+		p, err := scanPickupRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		pickups = append(pickups, *p)
+	}
+	return pickups, rows.Err()
+}
+
+// listPickupRequests mengambil permintaan jemput milik user/partner, opsional difilter status
+func listPickupRequests(db *sql.DB, ownerColumn string, ownerID int, status string) ([]user.PickupRequest, error) {
+	query := pickupRequestSelect + fmt.Sprintf(" WHERE pr.%s = $1", ownerColumn)
+	args := []interface{}{ownerID}
+	if status != "" {
+		query += ` AND pr.status = $2`
+		args = append(args, status)
+	}
+	query += ` ORDER BY pr.slot_start DESC`
+
+	pickups, err := queryPickupRequests(db, query, args...)
+	if err != nil {
+		log.Printf("Error getting pickup requests for %s %d: %v", ownerColumn, ownerID, err)
+		return nil, err
+	}
+	return pickups, nil
+}
+
+// getPickupRequest mengambil satu permintaan jemput, opsional dibatasi user; nil jika tidak ada
+func getPickupRequest(db *sql.DB, pickupID int, ownerColumn string, ownerID int) (*user.PickupRequest, error) {
+	query := pickupRequestSelect + ` WHERE pr.id = $1`
+	args := []interface{}{pickupID}
+	if ownerColumn != "" {
+		query += fmt.Sprintf(" AND pr.%s = $2", ownerColumn)
+		args = append(args, ownerID)
+	}
+	p, err := scanPickupRequest(db.QueryRow(query, args...))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("Error getting pickup request ID %d: %v", pickupID, err)
+		return nil, err
+	}
+	return p, nil
+}
+
+// --- Sisi User ---
+
+// CreatePickupRequest menyimpan permintaan jemput baru berstatus Requested
+func (r *UserRepository) CreatePickupRequest(pickup *user.PickupRequest, estimatedWeight float64) error {
+	itemsJSON, err := json.Marshal(pickup.Items)
+	if err != nil {
+		return errors.New("gagal menyimpan permintaan jemput")
+	}
+	query := `
+		INSERT INTO pickup_requests
+			(user_id, user_address_id, status, contact_name, contact_phone, address, latitude, longitude,
+			 items, estimated_weight, slot_start, slot_end, notes)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id, created_at, updated_at`
+	pickup.Status = user.PickupRequested
+	err = r.db.QueryRow(query, pickup.UserID, pickup.UserAddressID, pickup.Status, pickup.ContactName, pickup.ContactPhone,
+		pickup.Address, pickup.Latitude, pickup.Longitude, itemsJSON, estimatedWeight, pickup.SlotStart, pickup.SlotEnd, pickup.Notes,
+	).Scan(&pickup.ID, &pickup.CreatedAt, &pickup.UpdatedAt)
+	if err != nil {
+		log.Printf("Error creating pickup request for user ID %d: %v", pickup.UserID, err)
+		return errors.New("gagal menyimpan permintaan jemput")
+	}
+	pickup.EstimatedWeight = fmt.Sprintf("%.2f", estimatedWeight)
+	return nil
+}
+
+// GetPickupRequestsByUserID mengambil permintaan jemput milik user, opsional difilter status
+func (r *UserRepository) GetPickupRequestsByUserID(userID int, status string) ([]user.PickupRequest, error) {
+	return listPickupRequests(r.db, "user_id", userID, status)
+}
+
+// GetPickupRequestByIDForUser mengambil satu permintaan jemput milik user, nil jika tidak ada
+func (r *UserRepository) GetPickupRequestByIDForUser(pickupID, userID int) (*user.PickupRequest, error) {
+	return getPickupRequest(r.db, pickupID, "user_id", userID)
+}
+
+// CancelPickupRequestByUser membatalkan permintaan jemput yang partnernya belum berangkat
+func (r *UserRepository) CancelPickupRequestByUser(pickupID, userID int, reason string) error {
+	query := `
+		UPDATE pickup_requests
+		SET status = $1, cancelled_by = 'user', cancel_reason = NULLIF($2, ''), updated_at = NOW()
+		WHERE id = $3 AND user_id = $4 AND status IN ($5, $6)`
+	result, err := r.db.Exec(query, user.PickupCancelled, reason, pickupID, userID, user.PickupRequested, user.PickupScheduled)
+	if err != nil {
+		log.Printf("Error cancelling pickup request ID %d: %v", pickupID, err)
+		return err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetPickupPartnerCandidates mengambil partner disetujui yang mengaktifkan jemput beserta jaraknya dari titik
+// user dan jumlah jemput yang sudah terjadwal pada tanggal slot. Area layanan dicek di service (radius/poligon).
+func (r *UserRepository) GetPickupPartnerCandidates(latitude, longitude float64, slotDate time.Time) ([]user.EligiblePickupPartner, error) {
+	distance := haversineKmSQL("$1", "$2", "pa.latitude", "pa.longitude")
+	query := `
+		SELECT p.id, p.business_name, pa.address, pa.city_regency, pa.latitude, pa.longitude,
+		       ` + distance + ` AS distance_km,
+		       pa.service_radius_km, pa.service_area_polygon, pa.daily_pickup_capacity, pa.min_pickup_weight,
+		       (SELECT COUNT(*) FROM pickup_requests pr
+		        WHERE pr.partner_id = p.id AND pr.status <> $3 AND pr.slot_start::date = $4::date) AS booked
+		FROM partners p
+		JOIN partner_addresses pa ON pa.partner_id = p.id
+		JOIN xetor_partners xp ON xp.partner_id = p.id
+		WHERE xp.status = 'Approved' AND pa.pickup_enabled
+		  AND pa.latitude IS NOT NULL AND pa.longitude IS NOT NULL
+		ORDER BY distance_km`
+
+	rows, err := r.db.Query(query, latitude, longitude, user.PickupCancelled, slotDate.Format("2006-01-02"))
+	if err != nil {
+		log.Printf("Error getting pickup partner candidates: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	candidates := []user.EligiblePickupPartner{}
+	for rows.Next() {
+		var c user.EligiblePickupPartner
+		var polygonRaw []byte
+		var minWeight float64
+		if err := rows.Scan(
+			&c.PartnerID, &c.BusinessName, &c.Address, &c.CityRegency, &c.Latitude, &c.Longitude, &c.DistanceKm,
+			&c.ServiceRadiusKm, &polygonRaw, &c.DailyPickupCapacity, &minWeight, &c.BookedCount,
+		); err != nil {
+			log.Printf("Error scanning pickup partner candidate: %v", err)
+			return nil, err
+		}
+		if len(polygonRaw) > 0 {
+			if err := json.Unmarshal(polygonRaw, &c.ServiceAreaPolygon); err != nil {
+				return nil, fmt.Errorf("gagal membaca area layanan partner: %w", err)
+			}
+		}
+		c.MinPickupWeight = fmt.Sprintf("%.2f", minWeight)
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
+
+// --- Sisi Partner ---
+
+// GetAvailablePickupRequests mengambil permintaan jemput yang belum diambil dalam radius dari alamat partner,
+// terdekat dulu. Partner tanpa titik lokasi atau tanpa pickup_enabled tidak mendapat hasil.
+func (r *PartnerRepository) GetAvailablePickupRequests(partnerID int, radiusKm float64) ([]user.PickupRequest, error) {
+	distance := haversineKmSQL("pa.latitude", "pa.longitude", "pr.latitude", "pr.longitude")
+	query := `SELECT * FROM (` + pickupRequestColumns + `, ` + distance + ` AS distance_km` + pickupRequestFrom + `
+		JOIN partner_addresses pa ON pa.partner_id = $1
+		WHERE pr.status = $2 AND pr.slot_end > NOW()
+		  AND pa.pickup_enabled AND pa.latitude IS NOT NULL AND pa.longitude IS NOT NULL
+	) nearby
+	WHERE distance_km <= $3
+	ORDER BY distance_km, slot_start`
+
+	pickups, err := queryPickupRequests(r.db, query, partnerID, user.PickupRequested, radiusKm)
+	if err != nil {
+		log.Printf("Error getting available pickup requests for partner ID %d: %v", partnerID, err)
+		return nil, err
+	}
+	return pickups, nil
+}
+
+// GetPickupRequestsByPartnerID mengambil permintaan jemput yang diterima partner, opsional difilter status
+func (r *PartnerRepository) GetPickupRequestsByPartnerID(partnerID int, status string) ([]user.PickupRequest, error) {
+	return listPickupRequests(r.db, "partner_id", partnerID, status)
+}
+
+// GetPickupRequestByID mengambil satu permintaan jemput tanpa filter pemilik, nil jika tidak ada
+func (r *PartnerRepository) GetPickupRequestByID(pickupID int) (*user.PickupRequest, error) {
+	return getPickupRequest(r.db, pickupID, "", 0)
+}
+
+// AcceptPickupRequest mengambil permintaan jemput secara atomik (hanya satu partner yang berhasil).
+// Baris alamat partner dikunci agar kapasitas harian (dihitung per tanggal slot) tidak terlampaui oleh
+// penerimaan bersamaan. Mengembalikan sql.ErrNoRows jika sudah diambil partner lain, dibatalkan, atau slot sudah lewat.
+func (r *PartnerRepository) AcceptPickupRequest(pickupID, partnerID int) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	var capacity int
+	err = tx.QueryRow(`SELECT daily_pickup_capacity FROM partner_addresses WHERE partner_id = $1 FOR UPDATE`, partnerID).Scan(&capacity)
+	if err != nil {
+		log.Printf("Error locking partner address for pickup accept (partner ID %d): %v", partnerID, err)
+		return err
+	}
+
+	if capacity > 0 {
+		var booked int
+		err = tx.QueryRow(`
+			SELECT COUNT(*) FROM pickup_requests other
+			JOIN pickup_requests pr ON pr.id = $2
+			WHERE other.partner_id = $1 AND other.status <> $3
+			  AND other.slot_start::date = pr.slot_start::date`,
+			partnerID, pickupID, user.PickupCancelled,
+		).Scan(&booked)
+		if err != nil {
+			log.Printf("Error counting booked pickups for partner ID %d: %v", partnerID, err)
+			return err
+		}
+		if booked >= capacity {
+			return errors.New("kapasitas jemput partner untuk hari tersebut sudah penuh")
+		}
+	}
+
+	result, err := tx.Exec(`
+		UPDATE pickup_requests
+		SET status = $1, partner_id = $2, scheduled_at = NOW(), updated_at = NOW()
+		WHERE id = $3 AND status = $4 AND partner_id IS NULL AND slot_end > NOW()`,
+		user.PickupScheduled, partnerID, pickupID, user.PickupRequested,
+	)
+	if err != nil {
+		log.Printf("Error accepting pickup request ID %d by partner ID %d: %v", pickupID, partnerID, err)
+		return err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// UpdatePickupRequestStatus memindahkan status permintaan jemput milik partner beserta waktu tahapannya.
+// Mengembalikan sql.ErrNoRows jika status saat ini bukan fromStatus.
+func (r *PartnerRepository) UpdatePickupRequestStatus(pickupID, partnerID int, fromStatus, toStatus string) error {
+	query := `
+		UPDATE pickup_requests
+		SET status = $1,
+		    on_the_way_at = CASE WHEN $1::text = $5::text THEN NOW() ELSE on_the_way_at END,
+		    collected_at = CASE WHEN $1::text = $6::text THEN NOW() ELSE collected_at END,
+		    completed_at = CASE WHEN $1::text = $7::text THEN NOW() WHEN $2::text = $7::text THEN NULL ELSE completed_at END,
+		    updated_at = NOW()
+		WHERE id = $3 AND partner_id = $4 AND status = $2`
+	result, err := r.db.Exec(query, toStatus, fromStatus, pickupID, partnerID,
+		user.PickupOnTheWay, user.PickupCollected, user.PickupCompleted)
+	if err != nil {
+		log.Printf("Error updating pickup request ID %d status %s -> %s: %v", pickupID, fromStatus, toStatus, err)
+		return err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ReleasePickupRequest melepas permintaan jemput Scheduled agar bisa diambil partner lain
+func (r *PartnerRepository) ReleasePickupRequest(pickupID, partnerID int) error {
+	query := `
+		UPDATE pickup_requests
+		SET status = $1, partner_id = NULL, scheduled_at = NULL, updated_at = NOW()
+		WHERE id = $2 AND partner_id = $3 AND status = $4`
+	result, err := r.db.Exec(query, user.PickupRequested, pickupID, partnerID, user.PickupScheduled)
+	if err != nil {
+		log.Printf("Error releasing pickup request ID %d: %v", pickupID, err)
+		return err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetPickupRequestDepositHeader menyimpan ID deposit yang dihasilkan permintaan jemput
+func (r *PartnerRepository) SetPickupRequestDepositHeader(pickupID, partnerDepositHistoryID int) error {
+	query := `UPDATE pickup_requests SET partner_deposit_history_id = $1, updated_at = NOW() WHERE id = $2`
+	_, err := r.db.Exec(query, partnerDepositHistoryID, pickupID)
+	if err != nil {
+		log.Printf("Error linking pickup request ID %d to deposit %d: %v", pickupID, partnerDepositHistoryID, err)
+	}
+	return err
+}
@@ -0,0 +1,249 @@
+// internal/repository/review_repo.go
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"xetor.id/backend/internal/domain/user"
+)
+
+// Ulasan partner dibaca oleh user, publik, partner, dan admin; query semuanya disatukan di file ini.
+
+const partnerReviewSelect = `
+	SELECT pr.id, pr.user_deposit_history_id, pr.partner_deposit_history_id, pr.user_id, u.fullname,
+	       pr.partner_id, p.business_name, pr.rating, pr.comment, pr.photo, pr.status,
+	       pr.partner_reply, pr.partner_replied_at, pr.moderation_note, pr.moderated_at,
+	       pr.created_at, pr.updated_at
+	FROM partner_reviews pr
+	LEFT JOIN partners p ON p.id = pr.partner_id
+	LEFT JOIN users u ON u.id = pr.user_id`
+
+// scanPartnerReview membaca satu baris hasil partnerReviewSelect
+func scanPartnerReview(scanner interface{ Scan(dest ...interface{}) error }) (*user.PartnerReview, error) {
+	var rv user.PartnerReview
+	var repliedAt, moderatedAt sql.NullTime
+	err := scanner.Scan(
+		&rv.ID, &rv.UserDepositHistoryID, &rv.PartnerDepositHistoryID, &rv.UserID, &rv.UserName,
+		&rv.PartnerID, &rv.PartnerName, &rv.Rating, &rv.Comment, &rv.Photo, &rv.Status,
+		&rv.PartnerReply, &repliedAt, &rv.ModerationNote, &moderatedAt,
+		&rv.CreatedAt, &rv.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if repliedAt.Valid {
+		rv.PartnerRepliedAt = &repliedAt.Time
+	}
+	if moderatedAt.Valid {
+		rv.ModeratedAt = &moderatedAt.Time
+	}
+	return &rv, nil
+}
+
+// queryPartnerReviews menjalankan query daftar ulasan
+func queryPartnerReviews(db *sql.DB, query string, args ...interface{}) ([]user.PartnerReview, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reviews := []user.PartnerReview{}
+	for rows.Next() {
+		rv, err := scanPartnerReview(rows)
+		if err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, *rv)
+	}
+	return reviews, rows.Err()
+}
+
+// getPartnerReview mengambil satu ulasan; nil jika tidak ada
+func getPartnerReview(db *sql.DB, reviewID int) (*user.PartnerReview, error) {
+	rv, err := scanPartnerReview(db.QueryRow(partnerReviewSelect+` WHERE pr.id = $1`, reviewID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("Error getting partner review ID %d: %v", reviewID, err)
+		return nil, err
+	}
+	return rv, nil
+}
+
+// --- Sisi User & Publik ---
+
+// CreatePartnerReview menyimpan ulasan baru berstatus Published
+func (r *UserRepository) CreatePartnerReview(review *user.PartnerReview) error {
+	query := `
+		INSERT INTO partner_reviews
+			(user_deposit_history_id, partner_deposit_history_id, user_id, partner_id, rating, comment, photo, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at, updated_at`
+	review.Status = user.PartnerReviewPublished
+	err := r.db.QueryRow(query, review.UserDepositHistoryID, review.PartnerDepositHistoryID, review.UserID, review.PartnerID,
+		review.Rating, review.Comment, review.Photo, review.Status,
+	).Scan(&review.ID, &review.CreatedAt, &review.UpdatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return errors.New("ulasan untuk deposit ini sudah ada")
+		}
+		log.Printf("Error creating partner review for deposit ID %d: %v", review.UserDepositHistoryID, err)
+		return errors.New("gagal menyimpan ulasan")
+	}
+	return nil
+}
+
+// PartnerReviewExists mengecek apakah deposit user sudah memiliki ulasan
+func (r *UserRepository) PartnerReviewExists(userDepositHistoryID int) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM partner_reviews WHERE user_deposit_history_id = $1)`, userDepositHistoryID).Scan(&exists)
+	if err != nil {
+		log.Printf("Error checking partner review of deposit ID %d: %v", userDepositHistoryID, err)
+		return false, err
+	}
+	return exists, nil
+}
+
+// GetPartnerReviewsByUserID mengambil ulasan yang ditulis user
+func (r *UserRepository) GetPartnerReviewsByUserID(userID int) ([]user.PartnerReview, error) {
+	reviews, err := queryPartnerReviews(r.db, partnerReviewSelect+` WHERE pr.user_id = $1 ORDER BY pr.created_at DESC`, userID)
+	if err != nil {
+		log.Printf("Error getting partner reviews of user ID %d: %v", userID, err)
+		return nil, err
+	}
+	return reviews, nil
+}
+
+// GetPublishedPartnerReviews mengambil ulasan yang tampil publik untuk satu partner, terbaru dulu
+func (r *UserRepository) GetPublishedPartnerReviews(partnerID, limit, offset int) ([]user.PartnerReview, error) {
+	reviews, err := queryPartnerReviews(r.db, partnerReviewSelect+`
+		WHERE pr.partner_id = $1 AND pr.status = $2
+		ORDER BY pr.created_at DESC
+		LIMIT $3 OFFSET $4`, partnerID, user.PartnerReviewPublished, limit, offset)
+	if err != nil {
+		log.Printf("Error getting published reviews of partner ID %d: %v", partnerID, err)
+		return nil, err
+	}
+	return reviews, nil
+}
+
+// GetPartnerRatingSummaries menghitung rata-rata rating & jumlah ulasan publik beberapa partner sekaligus
+func (r *UserRepository) GetPartnerRatingSummaries(partnerIDs []int) (map[int]user.PartnerRatingSummary, error) {
+	summaries := make(map[int]user.PartnerRatingSummary)
+	if len(partnerIDs) == 0 {
+		return summaries, nil
+	}
+	placeholders := make([]string, len(partnerIDs))
+	args := make([]interface{}, 0, len(partnerIDs)+1)
+	for i, id := range partnerIDs {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args = append(args, id)
+	}
+	args = append(args, user.PartnerReviewPublished)
+	query := fmt.Sprintf(`
+		SELECT partner_id, ROUND(AVG(rating), 1), COUNT(*)
+		FROM partner_reviews
+		WHERE partner_id IN (%s) AND status = $%d
+		GROUP BY partner_id`, strings.Join(placeholders, ","), len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		log.Printf("Error getting partner rating summaries: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var partnerID int
+		var average float64
+		var summary user.PartnerRatingSummary
+		if err := rows.Scan(&partnerID, &average, &summary.ReviewCount); err != nil {
+			log.Printf("Error scanning partner rating summary: %v", err)
+			return nil, err
+		}
+		summary.AverageRating = &average
+		summaries[partnerID] = summary
+	}
+	return summaries, rows.Err()
+}
+
+// --- Sisi Partner & Admin ---
+
+// GetPartnerReviewsByPartnerID mengambil semua ulasan atas partner (termasuk yang disembunyikan), opsional difilter status
+func (r *PartnerRepository) GetPartnerReviewsByPartnerID(partnerID int, status string) ([]user.PartnerReview, error) {
+	query := partnerReviewSelect + ` WHERE pr.partner_id = $1`
+	args := []interface{}{partnerID}
+	if status != "" {
+		query += ` AND pr.status = $2`
+		args = append(args, status)
+	}
+	query += ` ORDER BY pr.created_at DESC`
+
+	reviews, err := queryPartnerReviews(r.db, query, args...)
+	if err != nil {
+		log.Printf("Error getting reviews of partner ID %d: %v", partnerID, err)
+		return nil, err
+	}
+	return reviews, nil
+}
+
+// GetPartnerReviewByID mengambil satu ulasan tanpa filter pemilik, nil jika tidak ada
+func (r *PartnerRepository) GetPartnerReviewByID(reviewID int) (*user.PartnerReview, error) {
+	return getPartnerReview(r.db, reviewID)
+}
+
+// ReplyPartnerReview menyimpan/mengganti balasan publik partner atas ulasan yang tampil.
+// Mengembalikan sql.ErrNoRows jika ulasan bukan milik partner atau sedang disembunyikan.
+func (r *PartnerRepository) ReplyPartnerReview(reviewID, partnerID int, reply string) error {
+	query := `
+		UPDATE partner_reviews
+		SET partner_reply = $1, partner_replied_at = NOW(), updated_at = NOW()
+		WHERE id = $2 AND partner_id = $3 AND status = $4`
+	result, err := r.db.Exec(query, reply, reviewID, partnerID, user.PartnerReviewPublished)
+	if err != nil {
+		log.Printf("Error replying to partner review ID %d: %v", reviewID, err)
+		return err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetPartnerReviewsForAdmin mengambil ulasan untuk moderasi admin, opsional difilter status & partner
+func (r *PartnerRepository) GetPartnerReviewsForAdmin(status string, partnerID int) ([]user.PartnerReview, error) {
+	query := partnerReviewSelect + ` WHERE ($1 = '' OR pr.status = $1) AND ($2 = 0 OR pr.partner_id = $2) ORDER BY pr.created_at DESC`
+	reviews, err := queryPartnerReviews(r.db, query, status, partnerID)
+	if err != nil {
+		log.Printf("Error getting partner reviews for admin: %v", err)
+		return nil, err
+	}
+	return reviews, nil
+}
+
+// ModeratePartnerReview mengubah status tampil ulasan beserta catatan moderasi.
+// Mengembalikan sql.ErrNoRows jika ulasan tidak ada.
+func (r *PartnerRepository) ModeratePartnerReview(reviewID int, status, note string) error {
+	query := `
+		UPDATE partner_reviews
+		SET status = $1, moderation_note = $2, moderated_at = NOW(), updated_at = NOW()
+		WHERE id = $3`
+	result, err := r.db.Exec(query, status, note, reviewID)
+	if err != nil {
+		log.Printf("Error moderating partner review ID %d: %v", reviewID, err)
+		return err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	log.Printf("Partner review ID %d moderated to %s", reviewID, status)
+	return nil
+}
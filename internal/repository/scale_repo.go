@@ -0,0 +1,283 @@
+// internal/repository/scale_repo.go
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"xetor.id/backend/internal/domain/partner"
+)
+
+// Timbangan digital partner: registrasi perangkat, hasil timbang bertanda tangan, dan ringkasan untuk admin.
+
+const scaleDeviceSelect = `
+	SELECT sd.id, sd.partner_id, p.business_name, sd.device_id, sd.name, sd.certified, sd.certification_number,
+	       sd.certified_at, sd.last_reading_at, sd.revoked_at, sd.created_at,
+	       (SELECT COUNT(*) FROM scale_readings sr WHERE sr.scale_device_id = sd.id AND sr.used_at IS NOT NULL)
+	FROM partner_scale_devices sd
+	JOIN partners p ON p.id = sd.partner_id`
+
+// queryScaleDevices menjalankan query daftar timbangan berbasis scaleDeviceSelect
+func (r *PartnerRepository) queryScaleDevices(query string, args ...interface{}) ([]partner.ScaleDevice, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	devices := []partner.ScaleDevice{}
+	for rows.Next() {
+		var d partner.ScaleDevice
+		var certifiedAt, lastReadingAt, revokedAt sql.NullTime
+		err := rows.Scan(&d.ID, &d.PartnerID, &d.PartnerName, &d.DeviceID, &d.Name, &d.Certified, &d.CertificationNumber,
+			&certifiedAt, &lastReadingAt, &revokedAt, &d.CreatedAt, &d.ReadingsUsed)
+		if err != nil {
+			return nil, err
+		}
+		if certifiedAt.Valid {
+			d.CertifiedAt = &certifiedAt.Time
+		}
+		if lastReadingAt.Valid {
+			d.LastReadingAt = &lastReadingAt.Time
+		}
+		if revokedAt.Valid {
+			d.RevokedAt = &revokedAt.Time
+		}
+		devices = append(devices, d)
+	}
+	return devices, rows.Err()
+}
+
+// CreateScaleDevice mendaftarkan timbangan baru milik partner
+func (r *PartnerRepository) CreateScaleDevice(device *partner.ScaleDevice) error {
+	query := `
+		INSERT INTO partner_scale_devices (partner_id, device_id, name, secret)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`
+	err := r.db.QueryRow(query, device.PartnerID, device.DeviceID, device.Name, device.Secret).Scan(&device.ID, &device.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return errors.New("device_id timbangan sudah terdaftar")
+		}
+		log.Printf("Error creating scale device for partner ID %d: %v", device.PartnerID, err)
+		return errors.New("gagal mendaftarkan timbangan")
+	}
+	return nil
+}
+
+// GetScaleDevicesByPartnerID mengambil semua timbangan milik partner, termasuk yang sudah dicabut
+func (r *PartnerRepository) GetScaleDevicesByPartnerID(partnerID int) ([]partner.ScaleDevice, error) {
+	devices, err := r.queryScaleDevices(scaleDeviceSelect+` WHERE sd.partner_id = $1 ORDER BY sd.created_at DESC`, partnerID)
+	if err != nil {
+		log.Printf("Error getting scale devices for partner ID %d: %v", partnerID, err)
+		return nil, err
+	}
+	return devices, nil
+}
+
+// RevokeScaleDevice mencabut timbangan partner. Mengembalikan sql.ErrNoRows jika tidak ada atau sudah dicabut.
+func (r *PartnerRepository) RevokeScaleDevice(deviceRowID, partnerID int) error {
+	query := `UPDATE partner_scale_devices SET revoked_at = NOW(), updated_at = NOW() WHERE id = $1 AND partner_id = $2 AND revoked_at IS NULL`
+	result, err := r.db.Exec(query, deviceRowID, partnerID)
+	if err != nil {
+		log.Printf("Error revoking scale device ID %d: %v", deviceRowID, err)
+		return err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// FindScaleDeviceByDeviceID mengambil timbangan (termasuk secret) berdasarkan device_id, nil jika tidak ada
+func (r *PartnerRepository) FindScaleDeviceByDeviceID(deviceID string) (*partner.ScaleDevice, error) {
+	query := `SELECT id, partner_id, device_id, name, secret, certified, revoked_at FROM partner_scale_devices WHERE device_id = $1`
+	var d partner.ScaleDevice
+	var revokedAt sql.NullTime
+	err := r.db.QueryRow(query, deviceID).Scan(&d.ID, &d.PartnerID, &d.DeviceID, &d.Name, &d.Secret, &d.Certified, &revokedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("Error finding scale device %s: %v", deviceID, err)
+		return nil, err
+	}
+	if revokedAt.Valid {
+		d.RevokedAt = &revokedAt.Time
+	}
+	return &d, nil
+}
+
+// CreateScaleReading menyimpan hasil timbang terverifikasi. reading_id yang sama dari perangkat yang sama ditolak (replay).
+func (r *PartnerRepository) CreateScaleReading(device *partner.ScaleDevice, deviceReadingID string, weight float64, measuredAt time.Time) (*partner.ScaleReading, error) {
+	query := `
+		INSERT INTO scale_readings (scale_device_id, partner_id, device_reading_id, weight, measured_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id, created_at`
+	reading := &partner.ScaleReading{
+		ScaleDeviceID: device.ID,
+		DeviceName:    device.Name,
+		Certified:     device.Certified,
+		Weight:        fmt.Sprintf("%.3f", weight),
+		MeasuredAt:    measuredAt,
+	}
+	err := r.db.QueryRow(query, device.ID, device.PartnerID, deviceReadingID, weight, measuredAt).Scan(&reading.ID, &reading.CreatedAt)
+	if err != nil {
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return nil, errors.New("hasil timbang dengan reading_id ini sudah pernah dikirim")
+		}
+		log.Printf("Error saving scale reading from device ID %d: %v", device.ID, err)
+		return nil, errors.New("gagal menyimpan hasil timbang")
+	}
+
+	if _, err := r.db.Exec(`UPDATE partner_scale_devices SET last_reading_at = NOW() WHERE id = $1`, device.ID); err != nil {
+		log.Printf("Warning: failed to update last_reading_at for scale device ID %d: %v", device.ID, err)
+	}
+	return reading, nil
+}
+
+// GetScaleReadingsByPartnerID mengambil hasil timbang partner sejak waktu tertentu, opsional hanya yang belum dipakai
+func (r *PartnerRepository) GetScaleReadingsByPartnerID(partnerID int, since time.Time, unusedOnly bool) ([]partner.ScaleReading, error) {
+	query := `
+		SELECT sr.id, sr.scale_device_id, sd.name, sd.certified, sr.weight, sr.measured_at, sr.used_at, sr.created_at
+		FROM scale_readings sr
+		JOIN partner_scale_devices sd ON sd.id = sr.scale_device_id
+		WHERE sr.partner_id = $1 AND sr.measured_at >= $2`
+	if unusedOnly {
+		query += ` AND sr.used_at IS NULL`
+	}
+	query += ` ORDER BY sr.measured_at DESC`
+
+	rows, err := r.db.Query(query, partnerID, since)
+	if err != nil {
+		log.Printf("Error getting scale readings for partner ID %d: %v", partnerID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	readings := []partner.ScaleReading{}
+	for rows.Next() {
+		var sr partner.ScaleReading
+		var weight float64
+		var usedAt sql.NullTime
+		if err := rows.Scan(&sr.ID, &sr.ScaleDeviceID, &sr.DeviceName, &sr.Certified, &weight, &sr.MeasuredAt, &usedAt, &sr.CreatedAt); err != nil {
+			log.Printf("Error scanning scale reading for partner ID %d: %v", partnerID, err)
+			return nil, err
+		}
+		sr.Weight = fmt.Sprintf("%.3f", weight)
+		if usedAt.Valid {
+			sr.UsedAt = &usedAt.Time
+		}
+		readings = append(readings, sr)
+	}
+	return readings, rows.Err()
+}
+
+// GetScaleReadingForDeposit mengambil data hasil timbang untuk validasi item deposit, nil jika tidak ada
+func (r *PartnerRepository) GetScaleReadingForDeposit(readingID int) (*partner.ScaleReadingInfo, error) {
+	query := `
+		SELECT sr.id, sr.partner_id, sr.weight, sr.measured_at, sr.used_at IS NOT NULL, sd.revoked_at IS NOT NULL
+		FROM scale_readings sr
+		JOIN partner_scale_devices sd ON sd.id = sr.scale_device_id
+		WHERE sr.id = $1`
+	var info partner.ScaleReadingInfo
+	err := r.db.QueryRow(query, readingID).Scan(&info.ID, &info.PartnerID, &info.Weight, &info.MeasuredAt, &info.Used, &info.DeviceRevoked)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("Error getting scale reading ID %d: %v", readingID, err)
+		return nil, err
+	}
+	return &info, nil
+}
+
+// --- Sisi Admin ---
+
+// GetScaleDevicesForAdmin mengambil semua timbangan terdaftar, opsional difilter status sertifikasi
+func (r *PartnerRepository) GetScaleDevicesForAdmin(certified *bool) ([]partner.ScaleDevice, error) {
+	query := scaleDeviceSelect
+	args := []interface{}{}
+	if certified != nil {
+		query += ` WHERE sd.certified = $1`
+		args = append(args, *certified)
+	}
+	query += ` ORDER BY p.business_name, sd.created_at`
+
+	devices, err := r.queryScaleDevices(query, args...)
+	if err != nil {
+		log.Printf("Error getting scale devices for admin: %v", err)
+		return nil, err
+	}
+	return devices, nil
+}
+
+// CertifyScaleDevice mengubah status sertifikasi timbangan. Mengembalikan sql.ErrNoRows jika tidak ada.
+func (r *PartnerRepository) CertifyScaleDevice(deviceRowID int, certified bool, certificationNumber string) error {
+	query := `
+		UPDATE partner_scale_devices
+		SET certified = $1, certification_number = NULLIF($2, ''),
+		    certified_at = CASE WHEN $1 THEN NOW() ELSE NULL END, updated_at = NOW()
+		WHERE id = $3`
+	result, err := r.db.Exec(query, certified, certificationNumber, deviceRowID)
+	if err != nil {
+		log.Printf("Error certifying scale device ID %d: %v", deviceRowID, err)
+		return err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetScaleUsageByPartner meringkas pemakaian timbangan per partner (item deposit 30 hari terakhir)
+func (r *PartnerRepository) GetScaleUsageByPartner() ([]partner.ScalePartnerUsage, error) {
+	query := `
+		WITH devices AS (
+			SELECT partner_id, COUNT(*) AS devices, COUNT(*) FILTER (WHERE certified) AS certified_devices
+			FROM partner_scale_devices
+			WHERE revoked_at IS NULL
+			GROUP BY partner_id
+		), items AS (
+			SELECT pdh.partner_id,
+			       COUNT(*) AS total_items,
+			       COUNT(pdd.scale_reading_id) AS scale_items,
+			       COUNT(*) FILTER (WHERE sd.certified) AS certified_items
+			FROM partner_deposit_history_details pdd
+			JOIN partner_deposit_histories pdh ON pdh.id = pdd.partner_deposit_history_id
+			LEFT JOIN scale_readings sr ON sr.id = pdd.scale_reading_id
+			LEFT JOIN partner_scale_devices sd ON sd.id = sr.scale_device_id
+			WHERE pdh.transaction_time >= NOW() - INTERVAL '30 days'
+			GROUP BY pdh.partner_id
+		)
+		SELECT p.id, p.business_name, COALESCE(d.devices, 0), COALESCE(d.certified_devices, 0),
+		       COALESCE(i.scale_items, 0), COALESCE(i.certified_items, 0), COALESCE(i.total_items, 0)
+		FROM partners p
+		JOIN devices d ON d.partner_id = p.id
+		LEFT JOIN items i ON i.partner_id = p.id
+		ORDER BY COALESCE(d.certified_devices, 0) DESC, p.business_name`
+	rows, err := r.db.Query(query)
+	if err != nil {
+		log.Printf("Error getting scale usage by partner: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := []partner.ScalePartnerUsage{}
+	for rows.Next() {
+		var u partner.ScalePartnerUsage
+		if err := rows.Scan(&u.PartnerID, &u.BusinessName, &u.Devices, &u.CertifiedDevices,
+			&u.ScaleWeighedItems, &u.CertifiedItems, &u.TotalItems); err != nil {
+			log.Printf("Error scanning scale usage row: %v", err)
+			return nil, err
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
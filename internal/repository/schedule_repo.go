@@ -0,0 +1,326 @@
+// internal/repository/schedule_repo.go
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"xetor.id/backend/internal/domain/partner"
+	"xetor.id/backend/internal/schedule"
+)
+
+// Jadwal partner: interval mingguan, pengecualian bertanggal, dan libur nasional.
+// Dipakai bersama oleh PartnerRepository (kelola jadwal, anti-fraud) dan UserRepository (endpoint publik).
+
+// loadScheduleCalendars memuat kalender jadwal beberapa partner sekaligus. Pengecualian & libur nasional
+// hanya dimuat untuk rentang tanggal lokal [from, to]. Partner tanpa baris jadwal tidak ada di map (dianggap tutup).
+func loadScheduleCalendars(db *sql.DB, partnerIDs []int, from, to time.Time) (map[int]*schedule.Calendar, error) {
+	calendars := make(map[int]*schedule.Calendar)
+	if len(partnerIDs) == 0 {
+		return calendars, nil
+	}
+	placeholders := make([]string, len(partnerIDs))
+	args := make([]interface{}, len(partnerIDs))
+	for i, id := range partnerIDs {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = id
+	}
+	inList := strings.Join(placeholders, ",")
+
+	observers := 0
+	rows, err := db.Query(`
+		SELECT partner_id, operating_status, timezone, observe_national_holidays
+		FROM partner_schedules WHERE partner_id IN (`+inList+`)`, args...)
+	if err != nil {
+		log.Printf("Error getting schedule calendars: %v", err)
+		return nil, err
+	}
+	observe := make(map[int]bool)
+	for rows.Next() {
+		var partnerID int
+		var observeHolidays bool
+		cal := &schedule.Calendar{Intervals: []schedule.Interval{}, Exceptions: []schedule.Exception{}}
+		if err := rows.Scan(&partnerID, &cal.OperatingStatus, &cal.Timezone, &observeHolidays); err != nil {
+			rows.Close()
+			log.Printf("Error scanning schedule calendar row: %v", err)
+			return nil, err
+		}
+		calendars[partnerID] = cal
+		observe[partnerID] = observeHolidays
+		if observeHolidays {
+			observers++
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(calendars) == 0 {
+		return calendars, nil
+	}
+
+	rows, err = db.Query(`
+		SELECT partner_id, weekday, to_char(open_time, 'HH24:MI'), to_char(close_time, 'HH24:MI')
+		FROM partner_schedule_intervals WHERE partner_id IN (`+inList+`)
+		ORDER BY partner_id, weekday, open_time`, args...)
+	if err != nil {
+		log.Printf("Error getting schedule intervals: %v", err)
+		return nil, err
+	}
+	for rows.Next() {
+		var partnerID int
+		var in schedule.Interval
+		if err := rows.Scan(&partnerID, &in.Weekday, &in.OpenTime, &in.CloseTime); err != nil {
+			rows.Close()
+			log.Printf("Error scanning schedule interval row: %v", err)
+			return nil, err
+		}
+		if cal, ok := calendars[partnerID]; ok {
+			cal.Intervals = append(cal.Intervals, in)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	fromDate, toDate := from.Format(schedule.DateLayout), to.Format(schedule.DateLayout)
+	n := len(args)
+	rows, err = db.Query(fmt.Sprintf(`
+		SELECT partner_id, id, to_char(date, 'YYYY-MM-DD'), closed,
+		       COALESCE(to_char(open_time, 'HH24:MI'), ''), COALESCE(to_char(close_time, 'HH24:MI'), ''), COALESCE(reason, '')
+		FROM partner_schedule_exceptions
+		WHERE partner_id IN (%s) AND date BETWEEN $%d AND $%d
+		ORDER BY partner_id, date, open_time`, inList, n+1, n+2), append(args, fromDate, toDate)...)
+	if err != nil {
+		log.Printf("Error getting schedule exceptions: %v", err)
+		return nil, err
+	}
+	for rows.Next() {
+		var partnerID int
+		var ex schedule.Exception
+		if err := rows.Scan(&partnerID, &ex.ID, &ex.Date, &ex.Closed, &ex.OpenTime, &ex.CloseTime, &ex.Reason); err != nil {
+			rows.Close()
+			log.Printf("Error scanning schedule exception row: %v", err)
+			return nil, err
+		}
+		if cal, ok := calendars[partnerID]; ok {
+			cal.Exceptions = append(cal.Exceptions, ex)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if observers == 0 {
+		return calendars, nil
+	}
+	holidays, err := loadNationalHolidays(db, fromDate, toDate)
+	if err != nil {
+		return nil, err
+	}
+	for partnerID, cal := range calendars {
+		if observe[partnerID] {
+			cal.Holidays = holidays
+		}
+	}
+	return calendars, nil
+}
+
+// loadNationalHolidays mengambil libur nasional dalam rentang tanggal (YYYY-MM-DD, inklusif)
+func loadNationalHolidays(db *sql.DB, fromDate, toDate string) (map[string]string, error) {
+	rows, err := db.Query(`
+		SELECT to_char(date, 'YYYY-MM-DD'), name FROM national_holidays
+		WHERE date BETWEEN $1 AND $2`, fromDate, toDate)
+	if err != nil {
+		log.Printf("Error getting national holidays: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	holidays := make(map[string]string)
+	for rows.Next() {
+		var date, name string
+		if err := rows.Scan(&date, &name); err != nil {
+			log.Printf("Error scanning national holiday row: %v", err)
+			return nil, err
+		}
+		holidays[date] = name
+	}
+	return holidays, rows.Err()
+}
+
+// GetScheduleCalendar memuat kalender jadwal satu partner untuk rentang tanggal lokal [from, to], nil jika belum ada jadwal
+func (r *PartnerRepository) GetScheduleCalendar(partnerID int, from, to time.Time) (*schedule.Calendar, error) {
+	calendars, err := loadScheduleCalendars(r.db, []int{partnerID}, from, to)
+	if err != nil {
+		return nil, err
+	}
+	return calendars[partnerID], nil
+}
+
+// GetScheduleCalendars memuat kalender jadwal beberapa partner untuk rentang tanggal lokal [from, to]
+func (r *UserRepository) GetScheduleCalendars(partnerIDs []int, from, to time.Time) (map[int]*schedule.Calendar, error) {
+	return loadScheduleCalendars(r.db, partnerIDs, from, to)
+}
+
+// GetScheduleByPartnerID mengambil jadwal partner beserta interval mingguannya (tanpa pengecualian)
+func (r *PartnerRepository) GetScheduleByPartnerID(partnerID int) (*partner.PartnerSchedule, error) {
+	query := `
+		SELECT id, partner_id, operating_status, timezone, observe_national_holidays, created_at, updated_at
+		FROM partner_schedules
+		WHERE partner_id = $1`
+
+	var ps partner.PartnerSchedule
+	err := r.db.QueryRow(query, partnerID).Scan(
+		&ps.ID, &ps.PartnerID, &ps.OperatingStatus, &ps.Timezone, &ps.ObserveNationalHolidays, &ps.CreatedAt, &ps.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil // Belum punya jadwal
+		}
+		log.Printf("Error getting schedule for partner ID %d: %v", partnerID, err)
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`
+		SELECT weekday, to_char(open_time, 'HH24:MI'), to_char(close_time, 'HH24:MI')
+		FROM partner_schedule_intervals WHERE partner_id = $1
+		ORDER BY weekday, open_time`, partnerID)
+	if err != nil {
+		log.Printf("Error getting schedule intervals for partner ID %d: %v", partnerID, err)
+		return nil, err
+	}
+	ps.Intervals = []schedule.Interval{}
+	for rows.Next() {
+		var in schedule.Interval
+		if err := rows.Scan(&in.Weekday, &in.OpenTime, &in.CloseTime); err != nil {
+			rows.Close()
+			log.Printf("Error scanning schedule interval for partner ID %d: %v", partnerID, err)
+			return nil, err
+		}
+		ps.Intervals = append(ps.Intervals, in)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &ps, nil
+}
+
+// UpsertSchedule membuat/mengupdate baris jadwal partner dan mengganti seluruh interval mingguannya
+func (r *PartnerRepository) UpsertSchedule(sched *partner.PartnerSchedule) (err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		log.Printf("Error starting tx for schedule upsert: %v", err)
+		return errors.New("gagal memulai transaksi")
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	query := `
+		INSERT INTO partner_schedules (partner_id, operating_status, timezone, observe_national_holidays, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, NOW(), NOW())
+		ON CONFLICT (partner_id) DO UPDATE SET
+			operating_status = EXCLUDED.operating_status,
+			timezone = EXCLUDED.timezone,
+			observe_national_holidays = EXCLUDED.observe_national_holidays,
+			updated_at = NOW()
+		RETURNING id, created_at, updated_at`
+	err = tx.QueryRow(query, sched.PartnerID, sched.OperatingStatus, sched.Timezone, sched.ObserveNationalHolidays).
+		Scan(&sched.ID, &sched.CreatedAt, &sched.UpdatedAt)
+	if err != nil {
+		log.Printf("Error upserting schedule for partner ID %d: %v", sched.PartnerID, err)
+		return errors.New("gagal menyimpan jadwal usaha")
+	}
+
+	if _, err = tx.Exec(`DELETE FROM partner_schedule_intervals WHERE partner_id = $1`, sched.PartnerID); err != nil {
+		log.Printf("Error clearing schedule intervals for partner ID %d: %v", sched.PartnerID, err)
+		return errors.New("gagal menyimpan jadwal usaha")
+	}
+	for _, in := range sched.Intervals {
+		_, err = tx.Exec(`
+			INSERT INTO partner_schedule_intervals (partner_id, weekday, open_time, close_time)
+			VALUES ($1, $2, $3, $4)`, sched.PartnerID, in.Weekday, in.OpenTime, in.CloseTime)
+		if err != nil {
+			log.Printf("Error inserting schedule interval for partner ID %d: %v", sched.PartnerID, err)
+			return errors.New("gagal menyimpan jadwal usaha")
+		}
+	}
+	log.Printf("Schedule upserted with ID: %d for Partner ID: %d (%d intervals)", sched.ID, sched.PartnerID, len(sched.Intervals))
+	return nil
+}
+
+// GetScheduleExceptions mengambil pengecualian jadwal partner mulai tanggal fromDate (YYYY-MM-DD)
+func (r *PartnerRepository) GetScheduleExceptions(partnerID int, fromDate string) ([]schedule.Exception, error) {
+	rows, err := r.db.Query(`
+		SELECT id, to_char(date, 'YYYY-MM-DD'), closed,
+		       COALESCE(to_char(open_time, 'HH24:MI'), ''), COALESCE(to_char(close_time, 'HH24:MI'), ''), COALESCE(reason, '')
+		FROM partner_schedule_exceptions
+		WHERE partner_id = $1 AND date >= $2
+		ORDER BY date, open_time`, partnerID, fromDate)
+	if err != nil {
+		log.Printf("Error getting schedule exceptions for partner ID %d: %v", partnerID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	exceptions := []schedule.Exception{}
+	for rows.Next() {
+		var ex schedule.Exception
+		if err := rows.Scan(&ex.ID, &ex.Date, &ex.Closed, &ex.OpenTime, &ex.CloseTime, &ex.Reason); err != nil {
+			log.Printf("Error scanning schedule exception for partner ID %d: %v", partnerID, err)
+			return nil, err
+		}
+		exceptions = append(exceptions, ex)
+	}
+	return exceptions, rows.Err()
+}
+
+// CreateScheduleException menyimpan pengecualian jadwal baru
+func (r *PartnerRepository) CreateScheduleException(partnerID int, ex *schedule.Exception) error {
+	var openTime, closeTime interface{} // NULL untuk pengecualian tutup seharian
+	if !ex.Closed {
+		openTime, closeTime = ex.OpenTime, ex.CloseTime
+	}
+	query := `
+		INSERT INTO partner_schedule_exceptions (partner_id, date, closed, open_time, close_time, reason)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))
+		RETURNING id`
+	err := r.db.QueryRow(query, partnerID, ex.Date, ex.Closed, openTime, closeTime, ex.Reason).Scan(&ex.ID)
+	if err != nil {
+		log.Printf("Error creating schedule exception for partner ID %d: %v", partnerID, err)
+		return errors.New("gagal menyimpan pengecualian jadwal")
+	}
+	log.Printf("Schedule exception created with ID: %d for Partner ID: %d on %s", ex.ID, partnerID, ex.Date)
+	return nil
+}
+
+// DeleteScheduleException menghapus pengecualian jadwal milik partner
+func (r *PartnerRepository) DeleteScheduleException(partnerID, exceptionID int) error {
+	result, err := r.db.Exec(`DELETE FROM partner_schedule_exceptions WHERE id = $1 AND partner_id = $2`, exceptionID, partnerID)
+	if err != nil {
+		log.Printf("Error deleting schedule exception ID %d: %v", exceptionID, err)
+		return err
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	log.Printf("Schedule exception ID %d deleted for Partner ID: %d", exceptionID, partnerID)
+	return nil
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 	"xetor.id/backend/internal/config"
+	"xetor.id/backend/internal/domain/midtrans"
 	"xetor.id/backend/internal/domain/user"
 )
 
@@ -122,8 +123,8 @@ func (r *UserRepository) UpdatePassword(id int, newHashedPassword string) error
 
 func (r *UserRepository) CreateAddress(addr *user.UserAddress) error {
 	query := `
-		INSERT INTO user_addresses (user_id, fullname, phone, address, city_regency, province, postal_code)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		INSERT INTO user_addresses (user_id, fullname, phone, address, city_regency, province, postal_code, latitude, longitude)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
 		RETURNING id, created_at, updated_at`
 
 	var postalCode sql.NullString
@@ -133,6 +134,7 @@ func (r *UserRepository) CreateAddress(addr *user.UserAddress) error {
 
 	err := r.db.QueryRow(query,
 		addr.UserID, addr.Fullname, addr.Phone, addr.Address, addr.CityRegency, addr.Province, postalCode,
+		addr.Latitude, addr.Longitude,
 	).Scan(&addr.ID, &addr.CreatedAt, &addr.UpdatedAt)
 
 	if err != nil {
@@ -146,7 +148,7 @@ func (r *UserRepository) CreateAddress(addr *user.UserAddress) error {
 // GetAddressesByUserID mengambil semua alamat milik user tertentu
 func (r *UserRepository) GetAddressesByUserID(userID int) ([]user.UserAddress, error) {
 	query := `
-		SELECT id, user_id, fullname, phone, address, city_regency, province, postal_code, created_at, updated_at
+		SELECT id, user_id, fullname, phone, address, city_regency, province, postal_code, latitude, longitude, created_at, updated_at
 		FROM user_addresses
 		WHERE user_id = $1
 		ORDER BY created_at DESC`
@@ -163,7 +165,8 @@ func (r *UserRepository) GetAddressesByUserID(userID int) ([]user.UserAddress, e
 		var addr user.UserAddress
 		err := rows.Scan(
 			&addr.ID, &addr.UserID, &addr.Fullname, &addr.Phone, &addr.Address,
-			&addr.CityRegency, &addr.Province, &addr.PostalCode, &addr.CreatedAt, &addr.UpdatedAt,
+			&addr.CityRegency, &addr.Province, &addr.PostalCode, &addr.Latitude, &addr.Longitude,
+			&addr.CreatedAt, &addr.UpdatedAt,
 		)
 		if err != nil {
 			log.Printf("Error scanning address row for user ID %d: %v", userID, err)
@@ -177,14 +180,15 @@ func (r *UserRepository) GetAddressesByUserID(userID int) ([]user.UserAddress, e
 // GetAddressByID mengambil satu alamat berdasarkan ID-nya, memastikan milik user yang benar
 func (r *UserRepository) GetAddressByID(id int, userID int) (*user.UserAddress, error) {
 	query := `
-		SELECT id, user_id, fullname, phone, address, city_regency, province, postal_code, created_at, updated_at
+		SELECT id, user_id, fullname, phone, address, city_regency, province, postal_code, latitude, longitude, created_at, updated_at
 		FROM user_addresses
 		WHERE id = $1 AND user_id = $2` // Filter berdasarkan ID alamat dan ID user
 
 	var addr user.UserAddress
 	err := r.db.QueryRow(query, id, userID).Scan(
 		&addr.ID, &addr.UserID, &addr.Fullname, &addr.Phone, &addr.Address,
-		&addr.CityRegency, &addr.Province, &addr.PostalCode, &addr.CreatedAt, &addr.UpdatedAt,
+		&addr.CityRegency, &addr.Province, &addr.PostalCode, &addr.Latitude, &addr.Longitude,
+			&addr.CreatedAt, &addr.UpdatedAt,
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
@@ -237,6 +241,13 @@ func (r *UserRepository) UpdateAddress(id int, userID int, req *user.UpdateUserA
 		// fields = append(fields, fmt.Sprintf("postal_code = $%d", argId)); args = append(args, sql.NullString{Valid: false}); argId++
 	}
 
+	// Titik lokasi selalu diupdate berpasangan
+	if req.Latitude != nil && req.Longitude != nil {
+		fields = append(fields, fmt.Sprintf("latitude = $%d", argId), fmt.Sprintf("longitude = $%d", argId+1))
+		args = append(args, *req.Latitude, *req.Longitude)
+		argId += 2
+	}
+
 	if len(fields) == 0 {
 		return nil /* No fields to update */
 	}
@@ -1061,6 +1072,175 @@ func (r *UserRepository) UpdateTopupStatus(orderID string, newStatus string, tra
 	return userID, nil
 }
 
+// GetTopupRefundInfo mengambil data topup beserta total refund/chargeback yang sudah tercatat
+func (r *UserRepository) GetTopupRefundInfo(topupID int) (*midtrans.TopupRefundInfo, error) {
+	query := `
+		SELECT uth.id, uth.user_id, uth.amount, uth.status, uth.reversed_amount
+		FROM user_topup_histories uth
+		WHERE uth.id = $1`
+	var info midtrans.TopupRefundInfo
+	err := r.db.QueryRow(query, topupID).Scan(&info.TopupID, &info.UserID, &info.Amount, &info.Status, &info.TotalReversed)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("Error getting topup refund info for ID %d: %v", topupID, err)
+		return nil, err
+	}
+	return &info, nil
+}
+
+// ReverseTopup mendebit saldo user untuk topup yang direfund/di-chargeback dan mencatat entri reversal.
+// totalReversed adalah total kumulatif dari gateway; hanya selisih dengan reversal yang sudah tercatat
+// yang didebit, sehingga notifikasi duplikat aman. Saldo boleh menjadi negatif jika sudah terpakai.
+func (r *UserRepository) ReverseTopup(orderID string, reversalType string, totalReversed float64, transactionID string, reason string, source string) (userID int, debited float64, err error) {
+	parts := strings.Split(orderID, "-")
+	if len(parts) != 2 || parts[0] != "TP" {
+		log.Printf("Invalid topup order ID format for reversal: %s", orderID)
+		return 0, 0, nil // Jangan buat Midtrans retry
+	}
+	topupID, err := strconv.Atoi(parts[1])
+	if err != nil {
+		log.Printf("Error converting topup ID from order ID %s: %v", orderID, err)
+		return 0, 0, nil
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		log.Printf("Error starting transaction for topup reversal: %v", err)
+		return 0, 0, err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+			if err != nil {
+				log.Printf("Error committing topup reversal transaction: %v", err)
+			}
+		}
+	}()
+
+	// 1. Kunci record topup agar reversal paralel tidak mendebit dua kali
+	var topupAmount, alreadyReversed float64
+	var currentStatus string
+	queryTopup := `SELECT user_id, amount, status, reversed_amount FROM user_topup_histories WHERE id = $1 FOR UPDATE`
+	err = tx.QueryRow(queryTopup, topupID).Scan(&userID, &topupAmount, &currentStatus, &alreadyReversed)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			log.Printf("Topup record not found for reversal, Order ID: %s", orderID)
+			err = fmt.Errorf("record topup tidak ditemukan untuk order ID: %s", orderID)
+			return 0, 0, err
+		}
+		log.Printf("Error locking topup ID %d for reversal: %v", topupID, err)
+		return 0, 0, err
+	}
+
+	// Saldo hanya pernah dikreditkan untuk topup yang Completed (atau yang sudah sebagian direversal)
+	switch currentStatus {
+	case "Completed", "Partially Refunded", "Partially Charged Back", "Refunded", "Charged Back":
+	default:
+		log.Printf("Topup ID %d (Order ID: %s) has status %s, nothing to reverse", topupID, orderID, currentStatus)
+		return userID, 0, nil
+	}
+
+	if totalReversed > topupAmount {
+		totalReversed = topupAmount
+	}
+
+	// 2. Hitung selisih dengan reversal yang sudah tercatat
+	delta := totalReversed - alreadyReversed
+	if delta < 0.01 {
+		log.Printf("Reversal for Order ID %s already recorded (total %.2f)", orderID, alreadyReversed)
+		return userID, 0, nil
+	}
+
+	// 3. Catat entri reversal
+	queryInsert := `
+		INSERT INTO user_topup_reversals (topup_id, user_id, type, amount, transaction_id, reason, source, reversal_time)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, NOW())`
+	_, err = tx.Exec(queryInsert, topupID, userID, reversalType, delta,
+		sql.NullString{String: transactionID, Valid: transactionID != ""},
+		sql.NullString{String: reason, Valid: reason != ""},
+		source)
+	if err != nil {
+		log.Printf("Error inserting topup reversal for topup ID %d: %v", topupID, err)
+		return 0, 0, err
+	}
+
+	// 4. Debit saldo (boleh negatif jika saldo sudah terpakai)
+	queryWallet := `UPDATE user_wallets SET balance = balance - $1, updated_at = NOW() WHERE user_id = $2 RETURNING balance`
+	var newBalance float64
+	err = tx.QueryRow(queryWallet, delta, userID).Scan(&newBalance)
+	if err != nil {
+		log.Printf("Error debiting wallet for topup reversal, user ID %d: %v", userID, err)
+		err = errors.New("gagal mengupdate saldo")
+		return 0, 0, err
+	}
+
+	// 5. Update status topup; status akhir hanya jika seluruh jumlah topup sudah direversal,
+	// sehingga sisa chargeback sebagian masih bisa direfund admin
+	newStatus := "Partially Refunded"
+	if reversalType == "chargeback" {
+		newStatus = "Partially Charged Back"
+	}
+	if alreadyReversed+delta >= topupAmount-0.005 {
+		newStatus = "Refunded"
+		if reversalType == "chargeback" {
+			newStatus = "Charged Back"
+		}
+	}
+	queryStatus := `UPDATE user_topup_histories SET status = $1, reversed_amount = reversed_amount + $2, updated_at = NOW() WHERE id = $3`
+	_, err = tx.Exec(queryStatus, newStatus, delta, topupID)
+	if err != nil {
+		log.Printf("Error updating topup status to %s for ID %d: %v", newStatus, topupID, err)
+		return 0, 0, err
+	}
+
+	log.Printf("Topup reversal (%s) recorded for Order ID %s: %.2f debited, new balance %.2f", reversalType, orderID, delta, newBalance)
+	return userID, delta, nil
+}
+
+// GetTopupReversalHistoryForUser mengambil riwayat refund/chargeback topup
+func (r *UserRepository) GetTopupReversalHistoryForUser(userID int) ([]user.TransactionHistoryItem, error) {
+	query := `
+		SELECT id, topup_id, type, amount, reversal_time
+		FROM user_topup_reversals
+		WHERE user_id = $1
+		ORDER BY reversal_time DESC`
+	rows, err := r.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []user.TransactionHistoryItem
+	for rows.Next() {
+		var item user.TransactionHistoryItem
+		var id, topupID int
+		var amount float64
+		var reversalType string
+		item.Type = "refund"
+
+		if err := rows.Scan(&id, &topupID, &reversalType, &amount, &item.Timestamp); err != nil {
+			return nil, err
+		}
+		item.ID = fmt.Sprintf("RF%05d", id)
+		item.Amount = sql.NullString{String: fmt.Sprintf("%.2f", amount), Valid: true}
+		item.Status = "Completed"
+		if reversalType == "chargeback" {
+			item.Description = fmt.Sprintf("Chargeback Top Up TP%05d", topupID)
+		} else {
+			item.Description = fmt.Sprintf("Refund Top Up TP%05d", topupID)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
 // --- Transfer Xpoin Functions ---
 
 // FindUserByEmail mencari user berdasarkan email (hanya butuh ID untuk transfer)
@@ -1436,3 +1616,207 @@ func (r *UserRepository) CreateUserFromGoogle(u *user.User) error {
 
 	return nil
 }
+
+// IsApprovedPartner memeriksa apakah partner ada dan berstatus Approved
+func (r *UserRepository) IsApprovedPartner(partnerID int) (bool, error) {
+	var approved bool
+	query := `SELECT EXISTS (SELECT 1 FROM xetor_partners WHERE partner_id = $1 AND status = 'Approved')`
+	if err := r.db.QueryRow(query, partnerID).Scan(&approved); err != nil {
+		log.Printf("Error checking approved partner ID %d: %v", partnerID, err)
+		return false, err
+	}
+	return approved, nil
+}
+
+// GetPublicPartnerProfile mengambil info usaha, alamat, dan statistik deposit mitra yang disetujui, nil jika tidak ada
+func (r *UserRepository) GetPublicPartnerProfile(partnerID int) (*user.PublicPartnerProfile, error) {
+	query := `
+		SELECT p.id, p.business_name, p.photo, p.created_at,
+		       pa.id, pa.address, pa.city_regency, pa.province, pa.postal_code, pa.latitude, pa.longitude,
+		       COALESCE(pa.pickup_enabled, FALSE)
+		FROM partners p
+		JOIN xetor_partners xp ON xp.partner_id = p.id
+		LEFT JOIN partner_addresses pa ON pa.partner_id = p.id
+		WHERE p.id = $1 AND xp.status = 'Approved'`
+
+	var profile user.PublicPartnerProfile
+	var addressID sql.NullInt64
+	var address, cityRegency, province sql.NullString
+	var addr user.PublicPartnerAddress
+	err := r.db.QueryRow(query, partnerID).Scan(
+		&profile.ID, &profile.BusinessName, &profile.Photo, &profile.MemberSince,
+		&addressID, &address, &cityRegency, &province, &addr.PostalCode, &addr.Latitude, &addr.Longitude,
+		&addr.PickupEnabled,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("Error getting public profile for partner ID %d: %v", partnerID, err)
+		return nil, err
+	}
+	if addressID.Valid {
+		addr.Address, addr.CityRegency, addr.Province = address.String, cityRegency.String, province.String
+		profile.Address = &addr
+	}
+
+	statsQuery := `
+		SELECT COUNT(*), COALESCE(SUM(total_weight), 0), COUNT(DISTINCT user_id), MAX(transaction_time)
+		FROM partner_deposit_histories
+		WHERE partner_id = $1 AND voided_at IS NULL`
+	var totalWeight float64
+	var lastDepositAt sql.NullTime
+	err = r.db.QueryRow(statsQuery, partnerID).Scan(
+		&profile.Stats.TotalDeposits, &totalWeight, &profile.Stats.TotalCustomers, &lastDepositAt,
+	)
+	if err != nil {
+		log.Printf("Error getting public deposit stats for partner ID %d: %v", partnerID, err)
+		return nil, err
+	}
+	profile.Stats.TotalWeight = fmt.Sprintf("%.2f", totalWeight)
+	if lastDepositAt.Valid {
+		profile.Stats.LastDepositAt = &lastDepositAt.Time
+	}
+	return &profile, nil
+}
+
+// --- Pencarian Mitra Terdekat ---
+
+// GetNearbyPartners mengambil mitra disetujui dalam radius dari titik (urut jarak) beserta zona waktunya.
+// wasteDetailID > 0 membatasi ke mitra yang punya harga untuk jenis sampah tersebut.
+// offset dipakai untuk membaca halaman kandidat berikutnya (urutan stabil per jarak lalu ID).
+func (r *UserRepository) GetNearbyPartners(latitude, longitude, radiusKm float64, wasteDetailID int, limit, offset int) ([]user.NearbyPartner, error) {
+	distance := haversineKmSQL("$1", "$2", "pa.latitude", "pa.longitude")
+	query := `
+		SELECT * FROM (
+			SELECT p.id, p.business_name, p.photo, pa.address, pa.city_regency, pa.province, pa.latitude, pa.longitude,
+			       ` + distance + ` AS distance_km, COALESCE(ps.timezone, '')
+			FROM partners p
+			JOIN xetor_partners xp ON xp.partner_id = p.id
+			JOIN partner_addresses pa ON pa.partner_id = p.id
+			LEFT JOIN partner_schedules ps ON ps.partner_id = p.id
+			WHERE xp.status = 'Approved' AND pa.latitude IS NOT NULL AND pa.longitude IS NOT NULL
+			  AND ($4 = 0 OR EXISTS (
+				SELECT 1 FROM partner_waste_prices pwp
+				JOIN partner_waste_price_details pwpd ON pwpd.partner_waste_price_id = pwp.id
+				WHERE pwp.partner_id = p.id AND pwpd.waste_detail_id = $4))
+		) nearby
+		WHERE distance_km <= $3
+		ORDER BY distance_km, id
+		LIMIT $5 OFFSET $6`
+
+	rows, err := r.db.Query(query, latitude, longitude, radiusKm, wasteDetailID, limit, offset)
+	if err != nil {
+		log.Printf("Error getting nearby partners: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	partners := []user.NearbyPartner{}
+	for rows.Next() {
+		var np user.NearbyPartner
+		if err := rows.Scan(
+			&np.ID, &np.BusinessName, &np.Photo, &np.Address, &np.CityRegency, &np.Province, &np.Latitude, &np.Longitude,
+			&np.DistanceKm, &np.Timezone,
+		); err != nil {
+			log.Printf("Error scanning nearby partner row: %v", err)
+			return nil, err
+		}
+		np.Prices = []user.PublicWastePrice{}
+		partners = append(partners, np)
+	}
+	return partners, rows.Err()
+}
+
+// GetPublicWastePrices mengambil harga sampah saat ini untuk beberapa mitra sekaligus, dikelompokkan per partner ID
+func (r *UserRepository) GetPublicWastePrices(partnerIDs []int) (map[int][]user.PublicWastePrice, error) {
+	prices := make(map[int][]user.PublicWastePrice)
+	if len(partnerIDs) == 0 {
+		return prices, nil
+	}
+	placeholders := make([]string, len(partnerIDs))
+	args := make([]interface{}, len(partnerIDs))
+	for i, id := range partnerIDs {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = id
+	}
+	query := fmt.Sprintf(`
+		SELECT pwp.partner_id, pwpd.id, pwpd.waste_detail_id, pwpd.name, pwpd.image, pwpd.price, pwpd.unit, pwpd.xpoin,
+		       next.price, next.unit, next.effective_from
+		FROM partner_waste_price_details pwpd
+		JOIN partner_waste_prices pwp ON pwp.id = pwpd.partner_waste_price_id
+		LEFT JOIN LATERAL (
+			SELECT v.price, v.unit, v.effective_from
+			FROM partner_waste_price_versions v
+			WHERE v.partner_waste_price_detail_id = pwpd.id AND v.status = 'Scheduled'
+			ORDER BY v.effective_from
+			LIMIT 1
+		) next ON TRUE
+		WHERE pwp.partner_id IN (%s)
+		ORDER BY pwpd.name ASC`, strings.Join(placeholders, ","))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		log.Printf("Error getting public waste prices: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var partnerID int
+		var wp user.PublicWastePrice
+		var price float64
+		var nextPrice sql.NullFloat64
+		var nextUnit sql.NullString
+		var nextEffectiveAt sql.NullTime
+		if err := rows.Scan(&partnerID, &wp.ID, &wp.WasteDetailID, &wp.Name, &wp.Image, &price, &wp.Unit, &wp.Xpoin,
+			&nextPrice, &nextUnit, &nextEffectiveAt); err != nil {
+			log.Printf("Error scanning public waste price row: %v", err)
+			return nil, err
+		}
+		wp.Price = fmt.Sprintf("%.2f", price)
+		if nextEffectiveAt.Valid {
+			wp.NextPrice = fmt.Sprintf("%.2f", nextPrice.Float64)
+			wp.NextUnit = nextUnit.String
+			wp.NextEffectiveAt = &nextEffectiveAt.Time
+		}
+		prices[partnerID] = append(prices[partnerID], wp)
+	}
+	return prices, rows.Err()
+}
+
+// GetWastePriceTrend menghitung harga rata-rata/min/maks yang berlaku di akhir tiap hari (zona waktu timezone)
+// dari versi harga seluruh mitra untuk satu jenis sampah. Hari tanpa harga tidak dikembalikan.
+func (r *UserRepository) GetWastePriceTrend(wasteDetailID int, fromDate, toDate, timezone string) ([]user.WastePriceTrendPoint, error) {
+	query := `
+		WITH days AS (
+			SELECT day::date AS day, ((day + INTERVAL '1 day')::timestamp AT TIME ZONE $4) AS day_end
+			FROM generate_series($2::date, $3::date, INTERVAL '1 day') AS day
+		)
+		SELECT d.day, v.unit, ROUND(AVG(v.price), 2), MIN(v.price), MAX(v.price), COUNT(DISTINCT v.partner_id)
+		FROM days d
+		JOIN partner_waste_price_versions v
+			ON v.waste_detail_id = $1 AND v.status = 'Applied'
+			AND v.effective_from < d.day_end AND (v.effective_until IS NULL OR v.effective_until >= d.day_end)
+		GROUP BY d.day, v.unit
+		ORDER BY v.unit, d.day`
+	rows, err := r.db.Query(query, wasteDetailID, fromDate, toDate, timezone)
+	if err != nil {
+		log.Printf("Error getting price trend of waste detail ID %d: %v", wasteDetailID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	points := []user.WastePriceTrendPoint{}
+	for rows.Next() {
+		var p user.WastePriceTrendPoint
+		var day time.Time
+		if err := rows.Scan(&day, &p.Unit, &p.AveragePrice, &p.MinPrice, &p.MaxPrice, &p.PartnerCount); err != nil {
+			log.Printf("Error scanning price trend point: %v", err)
+			return nil, err
+		}
+		p.Date = day.Format("2006-01-02")
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
@@ -0,0 +1,242 @@
+// internal/repository/waste_price_version_repo.go
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"xetor.id/backend/internal/domain/partner"
+)
+
+// Riwayat versi harga sampah partner. Setiap perubahan harga, satuan, atau katalog item harga menutup versi
+// yang berlaku dan membuka versi baru dalam transaksi yang sama dengan perubahan itemnya.
+
+// recordWastePriceVersion mencatat nilai item harga saat ini sebagai versi baru bila berbeda dari versi yang berlaku
+func recordWastePriceVersion(tx *sql.Tx, detailID int, source string) error {
+	var currentID sql.NullInt32
+	var changed bool
+	queryCurrent := `
+		SELECT v.id, v.id IS NULL OR v.price <> d.price OR v.unit <> d.unit OR v.xpoin <> d.xpoin
+		       OR v.waste_detail_id IS DISTINCT FROM d.waste_detail_id
+		FROM partner_waste_price_details d
+		LEFT JOIN partner_waste_price_versions v
+			ON v.partner_waste_price_detail_id = d.id AND v.status = 'Applied' AND v.effective_until IS NULL
+		WHERE d.id = $1
+		FOR UPDATE OF d`
+	if err := tx.QueryRow(queryCurrent, detailID).Scan(&currentID, &changed); err != nil {
+		log.Printf("Error checking current price version of waste price detail ID %d: %v", detailID, err)
+		return errors.New("gagal mencatat riwayat harga sampah")
+	}
+	if !changed {
+		return nil
+	}
+
+	if currentID.Valid {
+		if _, err := tx.Exec(`UPDATE partner_waste_price_versions SET effective_until = NOW() WHERE id = $1`, currentID.Int32); err != nil {
+			log.Printf("Error closing price version ID %d: %v", currentID.Int32, err)
+			return errors.New("gagal mencatat riwayat harga sampah")
+		}
+	}
+	queryInsert := `
+		INSERT INTO partner_waste_price_versions
+			(partner_waste_price_detail_id, partner_id, waste_detail_id, name, price, unit, xpoin, status, source, effective_from)
+		SELECT d.id, h.partner_id, d.waste_detail_id, d.name, d.price, d.unit, d.xpoin, 'Applied', $2, NOW()
+		FROM partner_waste_price_details d
+		JOIN partner_waste_prices h ON h.id = d.partner_waste_price_id
+		WHERE d.id = $1`
+	if _, err := tx.Exec(queryInsert, detailID, source); err != nil {
+		log.Printf("Error recording price version of waste price detail ID %d: %v", detailID, err)
+		return errors.New("gagal mencatat riwayat harga sampah")
+	}
+	return nil
+}
+
+// closeWastePriceVersions dipanggil sebelum item harga dihapus: versi berlaku ditutup, jadwal yang tersisa dibatalkan
+func closeWastePriceVersions(tx *sql.Tx, detailID int) error {
+	query := `
+		UPDATE partner_waste_price_versions
+		SET effective_until = CASE WHEN status = 'Applied' THEN NOW() ELSE effective_until END,
+		    status = CASE WHEN status = 'Scheduled' THEN 'Cancelled' ELSE status END
+		WHERE partner_waste_price_detail_id = $1
+		  AND (status = 'Scheduled' OR (status = 'Applied' AND effective_until IS NULL))`
+	if _, err := tx.Exec(query, detailID); err != nil {
+		log.Printf("Error closing price versions of waste price detail ID %d: %v", detailID, err)
+		return errors.New("gagal mencatat riwayat harga sampah")
+	}
+	return nil
+}
+
+// GetWastePriceVersions mengambil seluruh versi (termasuk jadwal) satu item harga milik partner, terbaru dulu
+func (r *PartnerRepository) GetWastePriceVersions(detailID, partnerID int) ([]partner.WastePriceVersion, error) {
+	query := `
+		SELECT v.id, v.partner_waste_price_detail_id, v.waste_detail_id, v.name, v.price, v.unit, v.xpoin, v.status, v.source,
+		       v.effective_from, v.effective_until, v.staff_id, ps.name, v.created_at
+		FROM partner_waste_price_versions v
+		LEFT JOIN partner_staff ps ON ps.id = v.staff_id
+		WHERE v.partner_waste_price_detail_id = $1 AND v.partner_id = $2
+		ORDER BY v.effective_from DESC, v.id DESC`
+	rows, err := r.db.Query(query, detailID, partnerID)
+	if err != nil {
+		log.Printf("Error getting price versions of waste price detail ID %d: %v", detailID, err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := []partner.WastePriceVersion{}
+	for rows.Next() {
+		var v partner.WastePriceVersion
+		var price float64
+		var effectiveUntil sql.NullTime
+		err := rows.Scan(&v.ID, &v.PartnerWastePriceDetailID, &v.WasteDetailID, &v.Name, &price, &v.Unit, &v.Xpoin, &v.Status,
+			&v.Source, &v.EffectiveFrom, &effectiveUntil, &v.StaffID, &v.StaffName, &v.CreatedAt)
+		if err != nil {
+			log.Printf("Error scanning waste price version: %v", err)
+			return nil, err
+		}
+		v.Price = fmt.Sprintf("%.2f", price)
+		if effectiveUntil.Valid {
+			v.EffectiveUntil = &effectiveUntil.Time
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// CreateWastePriceSchedule menjadwalkan harga baru untuk item milik partner. Mengembalikan 0 jika item tidak ditemukan.
+func (r *PartnerRepository) CreateWastePriceSchedule(detailID, partnerID int, price float64, unit string, xpoin int, effectiveAt time.Time, staffID sql.NullInt64) (int, error) {
+	query := `
+		INSERT INTO partner_waste_price_versions
+			(partner_waste_price_detail_id, partner_id, waste_detail_id, name, price, unit, xpoin, status, source, effective_from, staff_id)
+		SELECT d.id, h.partner_id, d.waste_detail_id, d.name, $3, $4, $5, 'Scheduled', 'schedule', $6, $7
+		FROM partner_waste_price_details d
+		JOIN partner_waste_prices h ON h.id = d.partner_waste_price_id
+		WHERE d.id = $1 AND h.partner_id = $2
+		RETURNING id`
+	var versionID int
+	err := r.db.QueryRow(query, detailID, partnerID, price, unit, xpoin, effectiveAt, staffID).Scan(&versionID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		if strings.Contains(err.Error(), "duplicate key value violates unique constraint") {
+			return 0, errors.New("jadwal harga pada waktu tersebut sudah ada")
+		}
+		log.Printf("Error scheduling price for waste price detail ID %d: %v", detailID, err)
+		return 0, errors.New("gagal menyimpan jadwal harga")
+	}
+	log.Printf("Price version %d scheduled for waste price detail ID %d at %s", versionID, detailID, effectiveAt.Format(time.RFC3339))
+	return versionID, nil
+}
+
+// CancelWastePriceSchedule membatalkan jadwal harga yang belum diterapkan, sql.ErrNoRows jika tidak ada
+func (r *PartnerRepository) CancelWastePriceSchedule(versionID, detailID, partnerID int) error {
+	query := `
+		UPDATE partner_waste_price_versions SET status = 'Cancelled'
+		WHERE id = $1 AND partner_waste_price_detail_id = $2 AND partner_id = $3 AND status = 'Scheduled'`
+	result, err := r.db.Exec(query, versionID, detailID, partnerID)
+	if err != nil {
+		log.Printf("Error cancelling price schedule ID %d: %v", versionID, err)
+		return errors.New("gagal membatalkan jadwal harga")
+	}
+	if rowsAffected, _ := result.RowsAffected(); rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ApplyDueWastePriceSchedules menerapkan jadwal harga yang sudah jatuh tempo ke item harganya. Baris yang sedang
+// dikunci replika lain dilewati sehingga aman dijalankan paralel.
+func (r *PartnerRepository) ApplyDueWastePriceSchedules(now time.Time, limit int) (applied []partner.AppliedWastePriceVersion, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		log.Printf("Error starting tx for price schedules: %v", err)
+		return nil, errors.New("gagal memulai transaksi")
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		} else if err != nil {
+			tx.Rollback()
+		} else {
+			err = tx.Commit()
+		}
+	}()
+
+	type dueVersion struct {
+		id, partnerID int
+		detailID      sql.NullInt32
+		price         float64
+		unit          string
+		xpoin         int
+	}
+	rows, err := tx.Query(`
+		SELECT id, partner_id, partner_waste_price_detail_id, price, unit, xpoin
+		FROM partner_waste_price_versions
+		WHERE status = 'Scheduled' AND effective_from <= $1
+		ORDER BY effective_from, id
+		LIMIT $2
+		FOR UPDATE SKIP LOCKED`, now, limit)
+	if err != nil {
+		log.Printf("Error getting due price schedules: %v", err)
+		return nil, err
+	}
+	due := []dueVersion{}
+	for rows.Next() {
+		var v dueVersion
+		if err = rows.Scan(&v.id, &v.partnerID, &v.detailID, &v.price, &v.unit, &v.xpoin); err != nil {
+			rows.Close()
+			log.Printf("Error scanning due price schedule: %v", err)
+			return nil, err
+		}
+		due = append(due, v)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, v := range due {
+		if !v.detailID.Valid { // Item harga sudah dihapus
+			if _, err = tx.Exec(`UPDATE partner_waste_price_versions SET status = 'Cancelled' WHERE id = $1`, v.id); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		var wasteDetailID sql.NullInt32
+		var name string
+		err = tx.QueryRow(`
+			UPDATE partner_waste_price_details SET price = $1, unit = $2, xpoin = $3, updated_at = NOW()
+			WHERE id = $4
+			RETURNING waste_detail_id, name`, v.price, v.unit, v.xpoin, v.detailID.Int32).Scan(&wasteDetailID, &name)
+		if err != nil {
+			log.Printf("Error applying price schedule ID %d: %v", v.id, err)
+			return nil, err
+		}
+		// Versi berlaku dihitung sejak benar-benar diterapkan agar deposit di antaranya tetap memakai harga lama
+		_, err = tx.Exec(`
+			UPDATE partner_waste_price_versions SET effective_until = NOW()
+			WHERE partner_waste_price_detail_id = $1 AND status = 'Applied' AND effective_until IS NULL`, v.detailID.Int32)
+		if err != nil {
+			log.Printf("Error closing current price version of waste price detail ID %d: %v", v.detailID.Int32, err)
+			return nil, err
+		}
+		_, err = tx.Exec(`
+			UPDATE partner_waste_price_versions
+			SET status = 'Applied', effective_from = NOW(), waste_detail_id = $2, name = $3
+			WHERE id = $1`, v.id, wasteDetailID, name)
+		if err != nil {
+			log.Printf("Error marking price schedule ID %d as applied: %v", v.id, err)
+			return nil, err
+		}
+		applied = append(applied, partner.AppliedWastePriceVersion{
+			VersionID: v.id, PartnerID: v.partnerID, DetailID: int(v.detailID.Int32), WasteDetailID: wasteDetailID,
+		})
+	}
+	return applied, nil
+}
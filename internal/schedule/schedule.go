@@ -0,0 +1,229 @@
+package schedule
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// DefaultTimezone zona waktu jadwal partner jika belum diatur
+const DefaultTimezone = "Asia/Jakarta"
+
+// DateLayout format tanggal pengecualian & hari libur
+const DateLayout = "2006-01-02"
+
+// LookaheadDays batas pencarian jam buka berikutnya (next_open_at)
+const LookaheadDays = 14
+
+// Zona waktu Indonesia tidak memakai DST, jadi cukup offset tetap (tidak bergantung tzdata di server)
+var timezones = map[string]*time.Location{
+	"Asia/Jakarta":   time.FixedZone("WIB", 7*60*60),
+	"Asia/Pontianak": time.FixedZone("WIB", 7*60*60),
+	"Asia/Makassar":  time.FixedZone("WITA", 8*60*60),
+	"Asia/Jayapura":  time.FixedZone("WIT", 9*60*60),
+}
+
+// DayNames nama hari, diindeks time.Weekday (0 = Minggu)
+var DayNames = [...]string{"Minggu", "Senin", "Selasa", "Rabu", "Kamis", "Jumat", "Sabtu"}
+
+var clockRegex = regexp.MustCompile(`^([01]\d|2[0-3]):([0-5]\d)$`)
+
+// ValidTimezone memeriksa apakah zona waktu didukung
+func ValidTimezone(name string) bool {
+	_, ok := timezones[name]
+	return ok
+}
+
+// Location mengembalikan lokasi zona waktu; nama kosong/tidak dikenal memakai DefaultTimezone
+func Location(name string) *time.Location {
+	if loc, ok := timezones[name]; ok {
+		return loc
+	}
+	return timezones[DefaultTimezone]
+}
+
+// Interval satu rentang jam buka pada hari tertentu. Jam tutup <= jam buka berarti melewati tengah malam
+// (00:00-00:00 = buka 24 jam).
+type Interval struct {
+	Weekday   int    `json:"weekday"`    // 0 = Minggu ... 6 = Sabtu
+	OpenTime  string `json:"open_time"`  // "HH:MM"
+	CloseTime string `json:"close_time"` // "HH:MM"
+}
+
+// Exception pengecualian jadwal untuk tanggal tertentu: tutup seharian, atau jam khusus yang
+// menggantikan jadwal mingguan hari itu (boleh lebih dari satu baris per tanggal)
+type Exception struct {
+	ID        int    `json:"id"`
+	Date      string `json:"date"` // "YYYY-MM-DD"
+	Closed    bool   `json:"closed"`
+	OpenTime  string `json:"open_time,omitempty"`
+	CloseTime string `json:"close_time,omitempty"`
+	Reason    string `json:"reason,omitempty"`
+}
+
+// Calendar jadwal lengkap partner untuk menghitung status buka
+type Calendar struct {
+	OperatingStatus string // "Closed" = tutup sementara, mengabaikan semua jadwal
+	Timezone        string
+	Intervals       []Interval
+	Exceptions      []Exception
+	Holidays        map[string]string // Tanggal libur nasional yang diikuti partner -> nama libur
+}
+
+// span satu rentang buka absolut
+type span struct {
+	start, end time.Time
+}
+
+// ValidateInterval memeriksa format satu interval
+func ValidateInterval(in Interval) error {
+	if in.Weekday < 0 || in.Weekday > 6 {
+		return fmt.Errorf("hari interval tidak valid: %d (0 = Minggu ... 6 = Sabtu)", in.Weekday)
+	}
+	if !clockRegex.MatchString(in.OpenTime) || !clockRegex.MatchString(in.CloseTime) {
+		return fmt.Errorf("jam interval hari %s tidak valid (HH:MM)", DayNames[in.Weekday])
+	}
+	if in.OpenTime == in.CloseTime && in.OpenTime != "00:00" { // 00:00-00:00 = buka 24 jam
+		return fmt.Errorf("jam buka dan tutup hari %s tidak boleh sama", DayNames[in.Weekday])
+	}
+	return nil
+}
+
+// ValidateIntervals memeriksa format dan memastikan interval di hari yang sama tidak tumpang tindih
+func ValidateIntervals(intervals []Interval) error {
+	for _, in := range intervals {
+		if err := ValidateInterval(in); err != nil {
+			return err
+		}
+	}
+	// Bandingkan dalam satu minggu acuan agar interval lewat tengah malam ikut diperiksa. Interval Sabtu
+	// yang melewati tengah malam juga disalin ke awal minggu agar dibandingkan dengan interval Minggu.
+	ref := time.Date(2000, 1, 2, 0, 0, 0, 0, time.UTC) // Minggu
+	weekEnd := ref.AddDate(0, 0, 7)
+	spans := make([]span, 0, len(intervals))
+	for _, in := range intervals {
+		s := toSpan(ref.AddDate(0, 0, in.Weekday), in.OpenTime, in.CloseTime)
+		spans = append(spans, s)
+		if s.end.After(weekEnd) {
+			spans = append(spans, span{start: s.start.AddDate(0, 0, -7), end: s.end.AddDate(0, 0, -7)})
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start.Before(spans[j].start) })
+	for i := 1; i < len(spans); i++ {
+		if spans[i].start.Before(spans[i-1].end) {
+			return fmt.Errorf("interval hari %s tumpang tindih", DayNames[spans[i].start.Weekday()])
+		}
+	}
+	return nil
+}
+
+// ValidateException memeriksa tanggal dan jam pengecualian
+func ValidateException(ex Exception) error {
+	if _, err := time.Parse(DateLayout, ex.Date); err != nil {
+		return fmt.Errorf("tanggal pengecualian tidak valid (YYYY-MM-DD)")
+	}
+	if ex.Closed {
+		return nil
+	}
+	if !clockRegex.MatchString(ex.OpenTime) || !clockRegex.MatchString(ex.CloseTime) || ex.OpenTime == ex.CloseTime {
+		return fmt.Errorf("jam khusus pengecualian tidak valid (HH:MM)")
+	}
+	return nil
+}
+
+// toSpan mengubah jam "HH:MM" pada tanggal day menjadi rentang absolut
+func toSpan(day time.Time, openTime, closeTime string) span {
+	open, _ := time.Parse("15:04", openTime)
+	closeAt, _ := time.Parse("15:04", closeTime)
+	start := day.Add(time.Duration(open.Hour())*time.Hour + time.Duration(open.Minute())*time.Minute)
+	end := day.Add(time.Duration(closeAt.Hour())*time.Hour + time.Duration(closeAt.Minute())*time.Minute)
+	if !end.After(start) {
+		end = end.AddDate(0, 0, 1) // Melewati tengah malam
+	}
+	return span{start: start, end: end}
+}
+
+// spansOn rentang buka yang dimulai pada tanggal day (tengah malam waktu lokal)
+func (c Calendar) spansOn(day time.Time) []span {
+	date := day.Format(DateLayout)
+	spans := []span{}
+
+	special, hasException := false, false
+	for _, ex := range c.Exceptions {
+		if ex.Date != date {
+			continue
+		}
+		hasException = true
+		if ex.Closed {
+			return spans // Tutup seharian mengalahkan jam khusus
+		}
+		special = true
+		spans = append(spans, toSpan(day, ex.OpenTime, ex.CloseTime))
+	}
+	if special {
+		return spans
+	}
+	if !hasException {
+		if _, holiday := c.Holidays[date]; holiday {
+			return spans
+		}
+	}
+
+	for _, in := range c.Intervals {
+		if in.Weekday == int(day.Weekday()) {
+			spans = append(spans, toSpan(day, in.OpenTime, in.CloseTime))
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start.Before(spans[j].start) })
+	return spans
+}
+
+// Window rentang tanggal pengecualian & libur yang perlu dimuat agar IsOpenAt/NextOpenAt di sekitar t akurat
+// (dilebarkan agar aman untuk semua zona waktu)
+func Window(t time.Time) (from, to time.Time) {
+	return t.AddDate(0, 0, -2), t.AddDate(0, 0, LookaheadDays+1)
+}
+
+// startOfDay tengah malam waktu lokal untuk t
+func startOfDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// IsOpenAt memeriksa apakah partner buka pada waktu t. Hanya status "Closed" yang menutup partner;
+// status kosong (data lama) mengikuti jadwal.
+func (c Calendar) IsOpenAt(t time.Time) bool {
+	if c.OperatingStatus == "Closed" {
+		return false
+	}
+	local := t.In(Location(c.Timezone))
+	today := startOfDay(local)
+	// Interval kemarin yang melewati tengah malam masih bisa mencakup dini hari ini
+	for _, day := range []time.Time{today.AddDate(0, 0, -1), today} {
+		for _, s := range c.spansOn(day) {
+			if !local.Before(s.start) && local.Before(s.end) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// NextOpenAt waktu buka berikutnya setelah t dalam LookaheadDays hari, nil jika sedang buka,
+// tutup sementara, atau tidak ada jadwal buka dalam rentang tersebut
+func (c Calendar) NextOpenAt(t time.Time) *time.Time {
+	if c.OperatingStatus == "Closed" || c.IsOpenAt(t) {
+		return nil
+	}
+	local := t.In(Location(c.Timezone))
+	today := startOfDay(local)
+	for i := 0; i <= LookaheadDays; i++ {
+		for _, s := range c.spansOn(today.AddDate(0, 0, i)) {
+			if s.start.After(local) {
+				next := s.start
+				return &next
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+// testCalendar jadwal acuan minggu 2-8 Maret 2026 (Senin-Minggu) di zona WIB
+func testCalendar() Calendar {
+	return Calendar{
+		OperatingStatus: "Open",
+		Timezone:        "Asia/Jakarta",
+		Intervals: []Interval{
+			{Weekday: 1, OpenTime: "08:00", CloseTime: "12:00"},
+			{Weekday: 1, OpenTime: "13:00", CloseTime: "17:00"},
+			{Weekday: 2, OpenTime: "08:00", CloseTime: "16:00"},
+			{Weekday: 3, OpenTime: "08:00", CloseTime: "16:00"},
+			{Weekday: 5, OpenTime: "22:00", CloseTime: "02:00"}, // Melewati tengah malam
+			{Weekday: 0, OpenTime: "00:00", CloseTime: "00:00"}, // Buka 24 jam
+		},
+		Exceptions: []Exception{
+			{Date: "2026-03-04", Closed: true, Reason: "Inventaris"},
+			{Date: "2026-03-09", OpenTime: "10:00", CloseTime: "11:00"},
+		},
+		Holidays: map[string]string{"2026-03-03": "Libur"},
+	}
+}
+
+// wib waktu lokal WIB pada Maret 2026
+func wib(day, hour, minute int) time.Time {
+	return time.Date(2026, time.March, day, hour, minute, 0, 0, Location("Asia/Jakarta"))
+}
+
+func TestCalendarIsOpenAt(t *testing.T) {
+	closedCal := testCalendar()
+	closedCal.OperatingStatus = "Closed"
+	legacyCal := testCalendar()
+	legacyCal.OperatingStatus = ""
+
+	tests := []struct {
+		name string
+		cal  Calendar
+		at   time.Time
+		want bool
+	}{
+		{"inside first interval", testCalendar(), wib(2, 9, 0), true},
+		{"opening minute is open", testCalendar(), wib(2, 8, 0), true},
+		{"gap between intervals", testCalendar(), wib(2, 12, 30), false},
+		{"closing minute is closed", testCalendar(), wib(2, 17, 0), false},
+		{"national holiday", testCalendar(), wib(3, 9, 0), false},
+		{"closed exception", testCalendar(), wib(4, 9, 0), false},
+		{"day without interval", testCalendar(), wib(5, 9, 0), false},
+		{"overnight before midnight", testCalendar(), wib(6, 23, 0), true},
+		{"overnight after midnight", testCalendar(), wib(7, 1, 30), true},
+		{"overnight closing", testCalendar(), wib(7, 2, 0), false},
+		{"open 24 hours", testCalendar(), wib(8, 3, 0), true},
+		{"special hours replace weekly schedule", testCalendar(), wib(9, 9, 0), false},
+		{"inside special hours", testCalendar(), wib(9, 10, 30), true},
+		{"time in other zone", testCalendar(), time.Date(2026, time.March, 2, 2, 0, 0, 0, time.UTC), true},
+		{"temporarily closed", closedCal, wib(2, 9, 0), false},
+		{"empty status follows schedule", legacyCal, wib(2, 9, 0), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.cal.IsOpenAt(tt.at); got != tt.want {
+				t.Fatalf("IsOpenAt(%s) = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalendarNextOpenAt(t *testing.T) {
+	closedCal := testCalendar()
+	closedCal.OperatingStatus = "Closed"
+	legacyCal := testCalendar()
+	legacyCal.OperatingStatus = ""
+	emptyCal := Calendar{OperatingStatus: "Open", Timezone: "Asia/Makassar"}
+
+	tests := []struct {
+		name string
+		cal  Calendar
+		at   time.Time
+		want *time.Time
+	}{
+		{"currently open", testCalendar(), wib(2, 9, 0), nil},
+		{"later the same day", testCalendar(), wib(2, 12, 30), ptr(wib(2, 13, 0))},
+		{"skips holiday and closed exception", testCalendar(), wib(2, 18, 0), ptr(wib(6, 22, 0))},
+		{"after overnight interval", testCalendar(), wib(7, 3, 0), ptr(wib(8, 0, 0))},
+		{"special hours", testCalendar(), wib(9, 9, 0), ptr(wib(9, 10, 0))},
+		{"temporarily closed", closedCal, wib(2, 18, 0), nil},
+		{"empty status follows schedule", legacyCal, wib(2, 12, 30), ptr(wib(2, 13, 0))},
+		{"no schedule in lookahead", emptyCal, wib(2, 18, 0), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.cal.NextOpenAt(tt.at)
+			if (got == nil) != (tt.want == nil) || (got != nil && !got.Equal(*tt.want)) {
+				t.Fatalf("NextOpenAt(%s) = %v, want %v", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateIntervals(t *testing.T) {
+	tests := []struct {
+		name      string
+		intervals []Interval
+		wantErr   string
+	}{
+		{"test calendar", testCalendar().Intervals, ""},
+		{"back to back", []Interval{{Weekday: 1, OpenTime: "08:00", CloseTime: "12:00"}, {Weekday: 1, OpenTime: "12:00", CloseTime: "17:00"}}, ""},
+		{"same day overlap", []Interval{{Weekday: 1, OpenTime: "08:00", CloseTime: "13:00"}, {Weekday: 1, OpenTime: "12:00", CloseTime: "17:00"}},
+			"interval hari Senin tumpang tindih"},
+		{"overnight into next day", []Interval{{Weekday: 2, OpenTime: "22:00", CloseTime: "03:00"}, {Weekday: 3, OpenTime: "02:00", CloseTime: "10:00"}},
+			"interval hari Rabu tumpang tindih"},
+		{"saturday overnight into sunday", []Interval{{Weekday: 6, OpenTime: "22:00", CloseTime: "02:00"}, {Weekday: 0, OpenTime: "01:00", CloseTime: "05:00"}},
+			"interval hari Minggu tumpang tindih"},
+		{"saturday overnight before sunday opening", []Interval{{Weekday: 6, OpenTime: "22:00", CloseTime: "02:00"}, {Weekday: 0, OpenTime: "02:00", CloseTime: "05:00"}}, ""},
+		{"saturday open 24 hours and sunday", []Interval{{Weekday: 6, OpenTime: "00:00", CloseTime: "00:00"}, {Weekday: 0, OpenTime: "00:00", CloseTime: "00:00"}}, ""},
+		{"invalid weekday", []Interval{{Weekday: 7, OpenTime: "08:00", CloseTime: "12:00"}}, "hari interval tidak valid: 7 (0 = Minggu ... 6 = Sabtu)"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateIntervals(tt.intervals)
+			if (err == nil) != (tt.wantErr == "") || (err != nil && err.Error() != tt.wantErr) {
+				t.Fatalf("ValidateIntervals error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
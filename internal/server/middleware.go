@@ -61,6 +61,7 @@ func AuthMiddleware() gin.HandlerFunc {
 			// Simpan entityID (Subject) dan Role ke context
 			c.Set("entityID", claims.Subject) // ID User atau Partner sebagai string
 			c.Set("role", claims.Role)      // Role ("user" atau "partner")
+			c.Set("staffID", claims.StaffID) // ID staf partner, 0 untuk token lama / non-partner
 
 			c.Next()
 		} else {
@@ -36,6 +36,10 @@ func NewRouter(userHandler *user.Handler, adminHandler *admin.AdminHandler, midt
 		publicRoutes.GET("/payment-methods", userHandler.GetActivePaymentMethods)
 		publicRoutes.GET("/promotion-banners", userHandler.GetActivePromotionBanners)
 		publicRoutes.GET("/partners", userHandler.GetApprovedPartners) // Public endpoint untuk daftar mitra approved
+		publicRoutes.GET("/partners/nearby", userHandler.GetNearbyPartners) // Mitra terdekat dengan jarak, status buka & harga
+		publicRoutes.GET("/partners/:id", userHandler.GetPartnerProfile) // Profil mitra: info, jadwal, harga, statistik & rating
+		publicRoutes.GET("/partners/:id/reviews", userHandler.GetPublicPartnerReviews) // ?limit=&offset=
+		publicRoutes.GET("/partners/:id/schedule", userHandler.GetPartnerSchedule) // Jadwal mitra + is_open_now/next_open_at
 		publicRoutes.GET("/about-xetor/title/:title", adminHandler.GetAboutXetorByTitle) // Public endpoint untuk version, terms, privacy policy
 	}
 
@@ -77,11 +81,45 @@ func NewRouter(userHandler *user.Handler, adminHandler *admin.AdminHandler, midt
 		depositRoutes := userRoutes.Group("/deposit")
 		{
 			depositRoutes.POST("/generate-qr-token", userHandler.GenerateDepositQrToken)
-			// Endpoint lain terkait deposit bisa ditambahkan di sini nanti
+			depositRoutes.POST("/generate-offline-qr", userHandler.GenerateOfflineDepositQr)
+			depositRoutes.GET("/qr-tokens", userHandler.GetActiveDepositQrTokens)
+			depositRoutes.DELETE("/qr-tokens/:token", userHandler.RevokeDepositQrToken)
+
+			// Sesi deposit mandiri (scan QR stasiun partner)
+			depositRoutes.POST("/sessions", userHandler.OpenDepositSession)
+			depositRoutes.GET("/sessions", userHandler.GetDepositSessions)
+			depositRoutes.GET("/sessions/:id", userHandler.GetDepositSessionByID)
+			depositRoutes.POST("/sessions/:id/cancel", userHandler.CancelDepositSession)
+
+			// Konfirmasi deposit dua tahap dari partner
+			depositRoutes.GET("/drafts", userHandler.GetDepositDrafts)
+			depositRoutes.GET("/drafts/:id", userHandler.GetDepositDraftByID)
+			depositRoutes.POST("/drafts/:id/accept", userHandler.AcceptDepositDraft)
+			depositRoutes.POST("/drafts/:id/reject", userHandler.RejectDepositDraft)
+
+			// Sengketa deposit (keberatan user atas deposit yang sudah tercatat)
+			depositRoutes.POST("/disputes", userHandler.OpenDepositDispute)
+			depositRoutes.GET("/disputes", userHandler.GetDepositDisputes)
+			depositRoutes.GET("/disputes/:id", userHandler.GetDepositDisputeByID)
+
+			// Ulasan partner (satu ulasan per deposit)
+			depositRoutes.POST("/reviews", userHandler.CreatePartnerReview)
+			depositRoutes.GET("/reviews", userHandler.GetMyPartnerReviews)
+		}
+
+		// Rute untuk permintaan jemput sampah
+		pickupRoutes := userRoutes.Group("/pickups")
+		{
+			pickupRoutes.POST("/", userHandler.CreatePickupRequest)
+			pickupRoutes.GET("/", userHandler.GetPickupRequests)
+			pickupRoutes.GET("/eligible-partners", userHandler.GetEligiblePickupPartners)
+			pickupRoutes.GET("/:id", userHandler.GetPickupRequestByID)
+			pickupRoutes.POST("/:id/cancel", userHandler.CancelPickupRequest)
 		}
 
 		// Rute untuk Waste Details (untuk scan result)
 		userRoutes.GET("/waste-details/:id", userHandler.GetWasteDetailByID)
+		userRoutes.GET("/waste-details/:id/price-trend", userHandler.GetWastePriceTrend) // ?from=&to= (YYYY-MM-DD)
 
 	}
 
@@ -89,57 +127,167 @@ func NewRouter(userHandler *user.Handler, adminHandler *admin.AdminHandler, midt
 	partnerRoutes := r.Group("/partner")
 	partnerRoutes.Use(AuthMiddleware(), RoleCheckMiddleware("partner"))
 	{
+		// Hak akses per role staf partner; owner selalu diizinkan
+		ownerOnly := partnerHandler.RequireStaffRole()
+		cashierAccess := partnerHandler.RequireStaffRole(partner.StaffRoleCashier)
+		financeAccess := partnerHandler.RequireStaffRole(partner.StaffRoleFinance)
+		anyStaff := partnerHandler.RequireStaffRole(partner.StaffRoleCashier, partner.StaffRoleFinance)
+
 		// Ruter untuk profil partner
-		partnerRoutes.GET("/profile", partnerHandler.GetProfile)
-		partnerRoutes.PUT("/profile", partnerHandler.UpdateProfile)
-		partnerRoutes.POST("/profile/photo", partnerHandler.UploadProfilePhoto)
-		partnerRoutes.PUT("/password", partnerHandler.ChangePassword)
-		partnerRoutes.DELETE("/account", partnerHandler.DeleteAccount)
-		partnerRoutes.GET("/wallet", partnerHandler.GetPartnerWallet)
-		partnerRoutes.GET("/statistics", partnerHandler.GetPartnerStatistics)
-		partnerRoutes.POST("/withdraw", partnerHandler.RequestPartnerWithdrawal)
-		partnerRoutes.POST("/topup", partnerHandler.RequestPartnerTopup)
-		partnerRoutes.POST("/transfer", partnerHandler.TransferXpoin)
+		partnerRoutes.GET("/profile", anyStaff, partnerHandler.GetProfile)
+		partnerRoutes.PUT("/profile", ownerOnly, partnerHandler.UpdateProfile)
+		partnerRoutes.POST("/profile/photo", ownerOnly, partnerHandler.UploadProfilePhoto)
+		partnerRoutes.PUT("/password", ownerOnly, partnerHandler.ChangePassword)
+		partnerRoutes.DELETE("/account", ownerOnly, partnerHandler.DeleteAccount)
+		partnerRoutes.GET("/wallet", financeAccess, partnerHandler.GetPartnerWallet)
+		partnerRoutes.GET("/statistics", financeAccess, partnerHandler.GetPartnerStatistics)
+		partnerRoutes.POST("/withdraw", financeAccess, partnerHandler.RequestPartnerWithdrawal)
+		partnerRoutes.POST("/topup", financeAccess, partnerHandler.RequestPartnerTopup)
+		partnerRoutes.POST("/transfer", financeAccess, partnerHandler.TransferXpoin)
+
+		// Ruter untuk akun staf partner (kelola staf hanya owner)
+		staffRoutes := partnerRoutes.Group("/staff")
+		{
+			staffRoutes.GET("/me", anyStaff, partnerHandler.GetMyPartnerStaff)
+			staffRoutes.PUT("/me/password", anyStaff, partnerHandler.ChangeMyStaffPassword)
+			staffRoutes.GET("/", ownerOnly, partnerHandler.GetPartnerStaffList)
+			staffRoutes.POST("/", ownerOnly, partnerHandler.CreatePartnerStaff)
+			staffRoutes.PUT("/:id", ownerOnly, partnerHandler.UpdatePartnerStaff) // Ubah nama/role atau nonaktifkan
+			staffRoutes.PUT("/:id/password", ownerOnly, partnerHandler.ResetPartnerStaffPassword)
+		}
 
 		// Ruter untuk konversi Xpoin dan Rupiah
-		convertRoutes := partnerRoutes.Group("/convert")
+		convertRoutes := partnerRoutes.Group("/convert", financeAccess)
 		{
 			convertRoutes.POST("/xp-to-rp", partnerHandler.ConvertXpToRp)
 			convertRoutes.POST("/rp-to-xp", partnerHandler.ConvertRpToXp)
 		}
 
 		// Ruter untuk alamat partner
-		partnerRoutes.GET("/address", partnerHandler.GetAddress)
-		partnerRoutes.PUT("/address", partnerHandler.UpdateAddress)
+		partnerRoutes.GET("/address", anyStaff, partnerHandler.GetAddress)
+		partnerRoutes.PUT("/address", ownerOnly, partnerHandler.UpdateAddress)
+		partnerRoutes.GET("/service-area", anyStaff, partnerHandler.GetServiceArea)
+		partnerRoutes.PUT("/service-area", ownerOnly, partnerHandler.UpdateServiceArea)
 
 		// Ruter untuk jadwal operasional partner
-		partnerRoutes.GET("/schedule", partnerHandler.GetSchedule)
-		partnerRoutes.PUT("/schedule", partnerHandler.UpdateSchedule)
+		partnerRoutes.GET("/schedule", anyStaff, partnerHandler.GetSchedule)
+		partnerRoutes.PUT("/schedule", ownerOnly, partnerHandler.UpdateSchedule)
+		partnerRoutes.POST("/schedule/exceptions", ownerOnly, partnerHandler.CreateScheduleException) // Tutup seharian / jam khusus per tanggal
+		partnerRoutes.DELETE("/schedule/exceptions/:id", ownerOnly, partnerHandler.DeleteScheduleException)
 
-		// Ruter untuk harga sampah
+		// Ruter untuk harga sampah (kasir hanya membaca untuk menimbang)
 		wastePriceRoutes := partnerRoutes.Group("/waste-prices")
 		{
-			wastePriceRoutes.POST("/", partnerHandler.CreateWastePrice)
-			wastePriceRoutes.GET("/", partnerHandler.GetAllWastePrices)
-			wastePriceRoutes.GET("/:detail_id", partnerHandler.GetWastePriceByID)
-			wastePriceRoutes.PUT("/:detail_id", partnerHandler.UpdateWastePrice)
-			wastePriceRoutes.DELETE("/:detail_id", partnerHandler.DeleteWastePrice)
+			wastePriceRoutes.POST("/", ownerOnly, partnerHandler.CreateWastePrice)
+			wastePriceRoutes.GET("/", anyStaff, partnerHandler.GetAllWastePrices)
+			wastePriceRoutes.GET("/export", anyStaff, partnerHandler.ExportWastePrices) // ?format=csv|xlsx
+			wastePriceRoutes.POST("/import", ownerOnly, partnerHandler.ImportWastePrices) // multipart: file (csv/xlsx), dry_run
+			wastePriceRoutes.GET("/:detail_id", anyStaff, partnerHandler.GetWastePriceByID)
+			wastePriceRoutes.PUT("/:detail_id", ownerOnly, partnerHandler.UpdateWastePrice)
+			wastePriceRoutes.DELETE("/:detail_id", ownerOnly, partnerHandler.DeleteWastePrice)
+			// Riwayat versi harga & jadwal perubahan harga di masa depan
+			wastePriceRoutes.GET("/:detail_id/history", anyStaff, partnerHandler.GetWastePriceHistory)
+			wastePriceRoutes.POST("/:detail_id/schedules", ownerOnly, partnerHandler.ScheduleWastePrice)
+			wastePriceRoutes.DELETE("/:detail_id/schedules/:schedule_id", ownerOnly, partnerHandler.CancelWastePriceSchedule)
 		}
 
 		// Ruter untuk riwayat transaksi partner
-		partnerRoutes.GET("/transactions", partnerHandler.GetFinancialTransactionHistory)
+		partnerRoutes.GET("/transactions", financeAccess, partnerHandler.GetFinancialTransactionHistory)
 
-		// Ruter untuk riwayat deposit partner
+		// Ruter untuk riwayat deposit partner (kasir); void, koreksi & sengketa hanya owner
 		depositRoutes := partnerRoutes.Group("/deposit")
 		{
-			depositRoutes.GET("/history", partnerHandler.GetDepositHistory)
-			depositRoutes.POST("/verify-qr-token", partnerHandler.VerifyDepositQrToken)
-			depositRoutes.POST("/check-user", partnerHandler.CheckUserByEmail)
-			depositRoutes.POST("/create", partnerHandler.CreateDeposit)
+			depositRoutes.GET("/history", cashierAccess, partnerHandler.GetDepositHistory)
+			depositRoutes.POST("/verify-qr-token", cashierAccess, partnerHandler.VerifyDepositQrToken)
+			depositRoutes.GET("/offline-qr-keys", cashierAccess, partnerHandler.GetOfflineQrPublicKeys)
+			depositRoutes.POST("/check-user", cashierAccess, partnerHandler.CheckUserByEmail)
+			depositRoutes.POST("/create", cashierAccess, partnerHandler.CreateDeposit)
+			depositRoutes.POST("/batch", cashierAccess, partnerHandler.SyncDepositBatch) // Sinkronisasi deposit offline (idempoten per client_id)
+			depositRoutes.GET("/drafts", cashierAccess, partnerHandler.GetDepositDrafts) // Deposit dua tahap (require_confirmation=true)
+
+			// Sesi deposit dari QR stasiun (user check-in, partner menimbang)
+			depositRoutes.POST("/station-qr", cashierAccess, partnerHandler.GenerateStationQr)
+			depositRoutes.GET("/sessions", cashierAccess, partnerHandler.GetDepositSessions)
+			depositRoutes.GET("/sessions/:id", cashierAccess, partnerHandler.GetDepositSessionByID)
+			depositRoutes.PUT("/sessions/:id/items", cashierAccess, partnerHandler.UpdateDepositSessionItems)
+			depositRoutes.POST("/sessions/:id/confirm", cashierAccess, partnerHandler.ConfirmDepositSession)
+			depositRoutes.POST("/sessions/:id/cancel", cashierAccess, partnerHandler.CancelDepositSession)
+
+			// Void & koreksi deposit dalam masa tenggang
+			depositRoutes.POST("/:id/void", ownerOnly, partnerHandler.VoidDeposit)
+			depositRoutes.PUT("/:id/details/:detail_id", ownerOnly, partnerHandler.CorrectDepositDetail)
+			depositRoutes.GET("/:id/adjustments", cashierAccess, partnerHandler.GetDepositAdjustments)
+			depositRoutes.POST("/:id/photos", cashierAccess, partnerHandler.AddDepositPhotos)
+
+			// Sengketa deposit dari user
+			depositRoutes.GET("/disputes", ownerOnly, partnerHandler.GetDepositDisputes)
+			depositRoutes.GET("/disputes/:id", ownerOnly, partnerHandler.GetDepositDisputeByID)
+			depositRoutes.POST("/disputes/:id/respond", ownerOnly, partnerHandler.RespondDepositDispute)
+		}
+
+		// Ruter untuk stok sampah yang dipegang partner
+		inventoryRoutes := partnerRoutes.Group("/inventory")
+		{
+			inventoryRoutes.GET("/", anyStaff, partnerHandler.GetInventory) // Stok & valuasi pada harga saat ini
+			inventoryRoutes.GET("/movements", anyStaff, partnerHandler.GetInventoryMovements)
+			inventoryRoutes.POST("/outbound", financeAccess, partnerHandler.RecordInventoryOutbound) // type: disposal (penjualan lewat /offtake/sales)
+			inventoryRoutes.POST("/adjustments", ownerOnly, partnerHandler.AdjustInventory)
+		}
+
+		// Ruter untuk penjualan sampah ke recycler (mengurangi stok, dihitung sebagai pendapatan)
+		offtakeRoutes := partnerRoutes.Group("/offtake", financeAccess)
+		{
+			offtakeRoutes.GET("/recyclers", partnerHandler.GetOfftakeRecyclers)
+			offtakeRoutes.POST("/sales", partnerHandler.CreateOfftakeSale) // multipart: items_json + invoice_photo
+			offtakeRoutes.GET("/sales", partnerHandler.GetOfftakeSales)
+			offtakeRoutes.GET("/sales/:id", partnerHandler.GetOfftakeSaleByID)
+			offtakeRoutes.GET("/report", partnerHandler.GetOfftakeMarginReport) // Pendapatan vs biaya beli dari user
+		}
+
+		// Ruter untuk ulasan pelanggan
+		reviewRoutes := partnerRoutes.Group("/reviews", ownerOnly)
+		{
+			reviewRoutes.GET("/", partnerHandler.GetPartnerReviews)
+			reviewRoutes.POST("/:id/reply", partnerHandler.ReplyPartnerReview) // Balasan publik, mengganti balasan lama
+		}
+
+		// Ruter untuk pengajuan onboarding (KYC) partner
+		onboardingRoutes := partnerRoutes.Group("/onboarding", ownerOnly)
+		{
+			onboardingRoutes.GET("/", partnerHandler.GetOnboardingApplication)
+			onboardingRoutes.PUT("/", partnerHandler.UpdateOnboardingApplication)
+			onboardingRoutes.POST("/documents", partnerHandler.AddOnboardingDocument) // multipart: type (nib|ktp|site_photo), file
+			onboardingRoutes.DELETE("/documents/:id", partnerHandler.DeleteOnboardingDocument)
+			onboardingRoutes.POST("/submit", partnerHandler.SubmitOnboardingApplication)
+		}
+
+		// Ruter untuk permintaan jemput sampah (aktifkan pickup_enabled di alamat usaha)
+		pickupRoutes := partnerRoutes.Group("/pickups", cashierAccess)
+		{
+			pickupRoutes.GET("/available", partnerHandler.GetAvailablePickupRequests)
+			pickupRoutes.GET("/", partnerHandler.GetPickupRequests)
+			pickupRoutes.GET("/:id", partnerHandler.GetPickupRequestByID)
+			pickupRoutes.POST("/:id/accept", partnerHandler.AcceptPickupRequest)
+			pickupRoutes.POST("/:id/on-the-way", partnerHandler.StartPickupTrip)
+			pickupRoutes.POST("/:id/collected", partnerHandler.MarkPickupCollected)
+			pickupRoutes.POST("/:id/release", partnerHandler.ReleasePickupRequest)
+			pickupRoutes.POST("/:id/complete", partnerHandler.CompletePickupRequest)
+		}
+
+		// Ruter untuk timbangan digital partner
+		scaleRoutes := partnerRoutes.Group("/scales")
+		{
+			scaleRoutes.POST("/", ownerOnly, partnerHandler.RegisterScaleDevice) // Secret HMAC hanya ditampilkan sekali
+			scaleRoutes.GET("/", cashierAccess, partnerHandler.GetScaleDevices)
+			scaleRoutes.GET("/readings", cashierAccess, partnerHandler.GetScaleReadings)
+			scaleRoutes.DELETE("/:id", ownerOnly, partnerHandler.RevokeScaleDevice)
 		}
 
 	}
 
+	// Ingest hasil timbang dari perangkat (autentikasi lewat tanda tangan HMAC per perangkat)
+	r.POST("/devices/scale-readings", partnerHandler.IngestScaleReading)
+
 	// Grup routing untuk admin
 	adminRoutes := r.Group("/admin")
 	{
@@ -213,6 +361,86 @@ func NewRouter(userHandler *user.Handler, adminHandler *admin.AdminHandler, midt
 			xetorPartnerRoutes.PUT("/:id/status", adminHandler.UpdateXetorPartnerStatus) // Endpoint khusus update status
 			xetorPartnerRoutes.DELETE("/:id", adminHandler.DeleteXetorPartner)
 		}
+
+		// Rute untuk Recyclers (offtaker pembeli sampah dari partner)
+		recyclerRoutes := adminRoutes.Group("/recyclers")
+		{
+			recyclerRoutes.POST("/", adminHandler.CreateRecycler)
+			recyclerRoutes.GET("/", adminHandler.GetAllRecyclers) // ?status=Active|Inactive
+			recyclerRoutes.GET("/:id", adminHandler.GetRecyclerByID)
+			recyclerRoutes.PUT("/:id", adminHandler.UpdateRecycler)
+			recyclerRoutes.DELETE("/:id", adminHandler.DeleteRecycler)
+		}
+
+		// Rute untuk antrean review onboarding (KYC) partner
+		adminOnboardingRoutes := adminRoutes.Group("/onboarding")
+		{
+			adminOnboardingRoutes.GET("/", partnerHandler.AdminGetOnboardingApplications) // Default: Submitted, ?status=all
+			adminOnboardingRoutes.GET("/:id", partnerHandler.AdminGetOnboardingApplicationByID)
+			adminOnboardingRoutes.POST("/:id/approve", partnerHandler.AdminApproveOnboarding)
+			adminOnboardingRoutes.POST("/:id/reject", partnerHandler.AdminRejectOnboarding) // note wajib
+			adminOnboardingRoutes.POST("/:id/request-info", partnerHandler.AdminRequestOnboardingInfo) // note wajib
+		}
+
+		// Rute untuk kalender libur nasional (dipakai jadwal partner yang mengikuti libur nasional)
+		holidayRoutes := adminRoutes.Group("/holidays")
+		{
+			holidayRoutes.GET("/", adminHandler.GetNationalHolidays) // ?year=2026
+			holidayRoutes.POST("/import", adminHandler.ImportNationalHolidays)
+			holidayRoutes.DELETE("/:date", adminHandler.DeleteNationalHoliday)
+		}
+
+		// Rute untuk kunci kode QR deposit offline
+		offlineQrKeyRoutes := adminRoutes.Group("/offline-qr-keys")
+		{
+			offlineQrKeyRoutes.GET("/", adminHandler.GetOfflineQrPublicKeys)
+			offlineQrKeyRoutes.POST("/rotate", adminHandler.RotateOfflineQrKey) // Mencabut semua kode QR offline lama
+		}
+
+		// Rute untuk Refund Topup (memanggil API refund Midtrans)
+		adminRoutes.POST("/topups/:id/refund", midtransHandler.RefundTopup)
+
+		// Rute untuk void & koreksi deposit oleh admin (tanpa batas waktu)
+		adminDepositRoutes := adminRoutes.Group("/deposits")
+		{
+			adminDepositRoutes.POST("/:id/void", partnerHandler.AdminVoidDeposit)
+			adminDepositRoutes.PUT("/:id/details/:detail_id", partnerHandler.AdminCorrectDepositDetail)
+			adminDepositRoutes.GET("/:id/adjustments", partnerHandler.AdminGetDepositAdjustments)
+		}
+
+		// Rute untuk antrean & keputusan sengketa deposit
+		adminDisputeRoutes := adminRoutes.Group("/deposit-disputes")
+		{
+			adminDisputeRoutes.GET("/", partnerHandler.AdminGetDepositDisputes) // Default: menunggu keputusan admin
+			adminDisputeRoutes.GET("/:id", partnerHandler.AdminGetDepositDisputeByID)
+			adminDisputeRoutes.POST("/:id/resolve", partnerHandler.AdminResolveDepositDispute)
+		}
+
+		// Rute untuk moderasi ulasan partner
+		adminReviewRoutes := adminRoutes.Group("/reviews")
+		{
+			adminReviewRoutes.GET("/", partnerHandler.AdminGetPartnerReviews) // ?status=Published|Hidden&partner_id=
+			adminReviewRoutes.POST("/:id/moderate", partnerHandler.AdminModeratePartnerReview)
+		}
+
+		// Rute untuk aturan anti-fraud & review deposit yang ditahan
+		adminFraudRoutes := adminRoutes.Group("/fraud")
+		{
+			adminFraudRoutes.GET("/rules", partnerHandler.AdminGetFraudRules)
+			adminFraudRoutes.PUT("/rules/:code", partnerHandler.AdminUpdateFraudRule)
+			adminFraudRoutes.GET("/cases", partnerHandler.AdminGetDepositFraudCases) // Default: Held
+			adminFraudRoutes.GET("/cases/:id", partnerHandler.AdminGetDepositFraudCaseByID)
+			adminFraudRoutes.POST("/cases/:id/approve", partnerHandler.AdminApproveDepositFraudCase)
+			adminFraudRoutes.POST("/cases/:id/decline", partnerHandler.AdminDeclineDepositFraudCase)
+		}
+
+		// Rute untuk timbangan digital partner & sertifikasinya
+		adminScaleRoutes := adminRoutes.Group("/scales")
+		{
+			adminScaleRoutes.GET("/", partnerHandler.AdminGetScaleDevices)
+			adminScaleRoutes.GET("/usage", partnerHandler.AdminGetScaleUsage) // Partner yang memakai timbangan bersertifikat
+			adminScaleRoutes.PUT("/:id/certify", partnerHandler.AdminCertifyScaleDevice)
+		}
 	}
 
 	// Grup routing untuk Midtrans Webhook
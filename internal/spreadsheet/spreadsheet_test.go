@@ -0,0 +1,242 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// xlsxFile membuat file xlsx minimal dari bagian-bagian XML (nama part -> isi)
+func xlsxFile(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range parts {
+		fw, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func sheetXML(rows string) string {
+	return `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` + rows + `</sheetData></worksheet>`
+}
+
+func read(t *testing.T, data []byte, format string) ([][]string, error) {
+	t.Helper()
+	return Read(bytes.NewReader(data), int64(len(data)), format)
+}
+
+func TestFormatFromFilename(t *testing.T) {
+	tests := []struct {
+		name   string
+		want   string
+		wantOK bool
+	}{
+		{"harga.csv", FormatCSV, true},
+		{"Harga.XLSX", FormatXLSX, true},
+		{"harga.xls", "", false},
+		{"harga", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := FormatFromFilename(tt.name)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("FormatFromFilename(%q) = %q, %v; want %q, %v", tt.name, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    [][]string
+		wantErr bool
+	}{
+		{"comma", "name,price\nBotol,2500\n", [][]string{{"name", "price"}, {"Botol", "2500"}}, false},
+		{"semicolon from indonesian excel", "name;price\nBotol;2500,50\n", [][]string{{"name", "price"}, {"Botol", "2500,50"}}, false},
+		{"utf-8 bom", "\xEF\xBB\xBFname,price\nKardus,1200\n", [][]string{{"name", "price"}, {"Kardus", "1200"}}, false},
+		{"blank line keeps row numbers", "name\n\nBotol\n", [][]string{{"name"}, {}, {"Botol"}}, false},
+		{"quoted separator", "name,price\n\"Botol, bening\",2500\n", [][]string{{"name", "price"}, {"Botol, bening", "2500"}}, false},
+		{"ragged rows", "a,b,c\nd\n", [][]string{{"a", "b", "c"}, {"d"}}, false},
+		{"empty file", "", [][]string{}, false},
+		{"unterminated quote", "name\n\"Botol\n", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := read(t, []byte(tt.input), FormatCSV)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Read error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Read = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWriteReadRoundTrip(t *testing.T) {
+	rows := [][]string{
+		{"waste_detail_id", "name", "price", "unit", "action"},
+		{"12", "Botol PET <bening> & tutup", "2500.50", "kg", ""},
+		{"", "Kardus \"bekas\"", "1200", "pcs", "delete"},
+		{},
+		{"7", "Kaleng, aluminium", "15000", "kg", "upsert"},
+	}
+	for _, format := range []string{FormatCSV, FormatXLSX} {
+		t.Run(format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Write(&buf, format, "Harga & Sampah", rows); err != nil {
+				t.Fatalf("Write: %v", err)
+			}
+			got, err := read(t, buf.Bytes(), format)
+			if err != nil {
+				t.Fatalf("Read: %v", err)
+			}
+			if !reflect.DeepEqual(got, rows) {
+				t.Fatalf("round trip = %q, want %q", got, rows)
+			}
+		})
+	}
+
+	if err := Write(&bytes.Buffer{}, "ods", "x", rows); err == nil {
+		t.Fatal("Write with unknown format succeeded")
+	}
+	if _, err := read(t, []byte("a"), "ods"); err == nil {
+		t.Fatal("Read with unknown format succeeded")
+	}
+}
+
+func TestReadXLSX(t *testing.T) {
+	workbook := `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Data" sheetId="1" r:id="rId3"/><sheet name="Lain" sheetId="2" r:id="rId1"/></sheets></workbook>`
+	rels := func(target string) string {
+		return `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Target="worksheets/sheet1.xml"/><Relationship Id="rId3" Target="` + target + `"/></Relationships>`
+	}
+	shared := `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<si><t>name</t></si><si><r><t>Botol </t></r><r><t>PET</t></r></si><si><t>price</t></si></sst>`
+
+	tests := []struct {
+		name    string
+		parts   map[string]string
+		want    [][]string
+		wantErr string
+	}{
+		{
+			name: "shared strings with rich text runs",
+			parts: map[string]string{
+				"xl/sharedStrings.xml":     shared,
+				"xl/worksheets/sheet1.xml": sheetXML(`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>2</v></c></row><row r="2"><c r="A2" t="s"><v>1</v></c><c r="B2"><v>2500</v></c></row>`),
+			},
+			want: [][]string{{"name", "price"}, {"Botol PET", "2500"}},
+		},
+		{
+			name: "inline strings and booleans",
+			parts: map[string]string{
+				"xl/worksheets/sheet1.xml": sheetXML(`<row r="1"><c r="A1" t="inlineStr"><is><t>Kardus</t></is></c><c r="B1" t="inlineStr"><is><r><t>a</t></r><r><t>b</t></r></is></c><c r="C1" t="b"><v>1</v></c><c r="D1" t="b"><v>0</v></c></row>`),
+			},
+			want: [][]string{{"Kardus", "ab", "TRUE", "FALSE"}},
+		},
+		{
+			name: "missing cells and skipped rows",
+			parts: map[string]string{
+				"xl/worksheets/sheet1.xml": sheetXML(`<row r="1"><c r="A1"><v>1</v></c><c r="C1"><v>3</v></c></row><row r="4"><c r="B4"><v>x</v></c><c r="AB4"><v>y</v></c></row>`),
+			},
+			want: [][]string{{"1", "", "3"}, {}, {}, append(append([]string{"", "x"}, make([]string, 25)...), "y")},
+		},
+		{
+			name: "cells without references",
+			parts: map[string]string{
+				"xl/worksheets/sheet1.xml": sheetXML(`<row><c><v>a</v></c><c><v>b</v></c></row><row><c><v>c</v></c></row>`),
+			},
+			want: [][]string{{"a", "b"}, {"c"}},
+		},
+		{
+			name: "first sheet from workbook relationships",
+			parts: map[string]string{
+				"xl/workbook.xml":            workbook,
+				"xl/_rels/workbook.xml.rels": rels("worksheets/data.xml"),
+				"xl/worksheets/sheet1.xml":   sheetXML(`<row r="1"><c r="A1"><v>salah</v></c></row>`),
+				"xl/worksheets/data.xml":     sheetXML(`<row r="1"><c r="A1"><v>benar</v></c></row>`),
+			},
+			want: [][]string{{"benar"}},
+		},
+		{
+			name: "absolute relationship target",
+			parts: map[string]string{
+				"xl/workbook.xml":            workbook,
+				"xl/_rels/workbook.xml.rels": rels("/xl/worksheets/data.xml"),
+				"xl/worksheets/data.xml":     sheetXML(`<row r="1"><c r="A1"><v>benar</v></c></row>`),
+			},
+			want: [][]string{{"benar"}},
+		},
+		{
+			name: "shared string index out of range",
+			parts: map[string]string{
+				"xl/sharedStrings.xml":     shared,
+				"xl/worksheets/sheet1.xml": sheetXML(`<row r="1"><c r="A1" t="s"><v>9</v></c></row>`),
+			},
+			wantErr: `file xlsx tidak valid: shared string "9" di sel A1`,
+		},
+		{
+			name:    "missing worksheet",
+			parts:   map[string]string{"xl/sharedStrings.xml": shared},
+			wantErr: "file xlsx tidak valid: xl/worksheets/sheet1.xml tidak ada",
+		},
+		{
+			name: "oversized part",
+			parts: map[string]string{
+				"xl/worksheets/sheet1.xml": `<worksheet><sheetData>` + strings.Repeat(" ", maxPartSize) + `</sheetData></worksheet>`,
+			},
+			wantErr: "file xlsx tidak valid: xl/worksheets/sheet1.xml",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := read(t, xlsxFile(t, tt.parts), FormatXLSX)
+			if tt.wantErr != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+					t.Fatalf("Read error = %v, want prefix %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Read: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("Read = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("not a zip file", func(t *testing.T) {
+		if _, err := read(t, []byte("name,price\n"), FormatXLSX); err == nil || err.Error() != "file xlsx tidak valid" {
+			t.Fatalf("Read error = %v, want invalid xlsx", err)
+		}
+	})
+}
+
+func TestColumnNames(t *testing.T) {
+	for _, tt := range []struct {
+		index int
+		name  string
+	}{{0, "A"}, {25, "Z"}, {26, "AA"}, {27, "AB"}, {701, "ZZ"}, {702, "AAA"}} {
+		if got := columnName(tt.index); got != tt.name {
+			t.Errorf("columnName(%d) = %q, want %q", tt.index, got, tt.name)
+		}
+		if got := columnIndex(tt.name + "12"); got != tt.index {
+			t.Errorf("columnIndex(%q) = %d, want %d", tt.name+"12", got, tt.index)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package temporary_token
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"sync" // Untuk menangani akses bersamaan ke map (goroutine safety)
+	"time"
+)
+
+// MemoryTokenStore menyimpan token aktif di memori proses.
+// Cocok untuk satu instance API; token hilang saat binary di-restart.
+type MemoryTokenStore struct {
+	mu       sync.RWMutex // Mutex untuk melindungi akses ke map
+	tokens   map[string]TokenData
+	stations map[string]TokenData // Token QR stasiun partner; UserID berisi PartnerID
+}
+
+// Pastikan MemoryTokenStore memenuhi interface TokenStore
+var _ TokenStore = (*MemoryTokenStore)(nil)
+
+// NewMemoryTokenStore membuat instance MemoryTokenStore baru
+func NewMemoryTokenStore() *MemoryTokenStore {
+	store := &MemoryTokenStore{
+		tokens:   make(map[string]TokenData),
+		stations: make(map[string]TokenData),
+	}
+	// Jalankan pembersihan token kedaluwarsa secara berkala (misal: setiap menit)
+	go store.cleanupExpiredTokens(1 * time.Minute)
+	return store
+}
+
+// CreateToken membuat token baru untuk user, menyimpannya, dan mengembalikan token + expiry
+func (s *MemoryTokenStore) CreateToken(userIDStr string, validityDuration time.Duration) (string, time.Time, error) {
+	userID, token, err := newTokenForUser(userIDStr)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(validityDuration)
+	data := TokenData{
+		UserID:    userID,
+		ExpiresAt: expiresAt,
+		CreatedAt: now,
+	}
+
+	s.mu.Lock() // Kunci map sebelum menulis
+	s.tokens[token] = data
+	s.mu.Unlock() // Buka kunci setelah selesai
+
+	log.Printf("Generated QR token %s for user ID %d, expires at %s", token, userID, expiresAt.Format(time.RFC3339))
+	return token, expiresAt, nil
+}
+
+// ValidateToken memeriksa apakah token ada dan belum kedaluwarsa, mengembalikan UserID
+// (Fungsi ini akan dipakai oleh endpoint partner nanti)
+func (s *MemoryTokenStore) ValidateToken(token string) (int, error) {
+	s.mu.RLock() // Kunci map untuk membaca
+	data, exists := s.tokens[token]
+	s.mu.RUnlock() // Buka kunci setelah selesai
+
+	if !exists {
+		return 0, errors.New("token tidak ditemukan")
+	}
+
+	if time.Now().After(data.ExpiresAt) {
+		// Hapus token yang sudah kedaluwarsa saat divalidasi
+		s.mu.Lock()
+		delete(s.tokens, token)
+		s.mu.Unlock()
+		return 0, errors.New("token sudah kedaluwarsa")
+	}
+
+	return data.UserID, nil
+}
+
+// ClaimToken mengikat token ke partner yang pertama kali memverifikasinya secara atomik.
+// Partner yang sama boleh memverifikasi ulang; partner lain akan ditolak.
+func (s *MemoryTokenStore) ClaimToken(token string, partnerID int) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, exists := s.tokens[token]
+	if !exists {
+		return 0, errors.New("token tidak ditemukan")
+	}
+	if time.Now().After(data.ExpiresAt) {
+		delete(s.tokens, token)
+		return 0, errors.New("token sudah kedaluwarsa")
+	}
+	if data.ClaimedBy != 0 && data.ClaimedBy != partnerID {
+		return 0, errors.New("token sudah digunakan oleh mitra lain")
+	}
+
+	if data.ClaimedBy == 0 {
+		data.ClaimedBy = partnerID
+		data.ClaimedAt = time.Now()
+		s.tokens[token] = data
+		log.Printf("QR token %s for user ID %d claimed by partner ID %d", token, data.UserID, partnerID)
+	}
+	return data.UserID, nil
+}
+
+// ConsumeToken menghapus token yang sudah diklaim oleh partner, sehingga hanya bisa dipakai sekali.
+// Token di memori tidak ikut transaksi tx; restore dipanggil pemanggil jika transaksinya gagal.
+func (s *MemoryTokenStore) ConsumeToken(tx *sql.Tx, token string, partnerID int) (int, func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, exists := s.tokens[token]
+	if !exists {
+		return 0, nil, errors.New("token tidak ditemukan")
+	}
+	if time.Now().After(data.ExpiresAt) {
+		delete(s.tokens, token)
+		return 0, nil, errors.New("token sudah kedaluwarsa")
+	}
+	if data.ClaimedBy != partnerID {
+		return 0, nil, errors.New("token sudah digunakan oleh mitra lain")
+	}
+
+	delete(s.tokens, token)
+	log.Printf("QR token %s for user ID %d consumed by partner ID %d", token, data.UserID, partnerID)
+	restore := func() {
+		s.mu.Lock()
+		s.tokens[token] = data
+		s.mu.Unlock()
+		log.Printf("QR token %s for user ID %d restored after failed transaction", token, data.UserID)
+	}
+	return data.UserID, restore, nil
+}
+
+// ListActiveTokens mengembalikan token milik user yang belum kedaluwarsa
+func (s *MemoryTokenStore) ListActiveTokens(userID int) ([]ActiveToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now()
+	tokens := []ActiveToken{}
+	for token, data := range s.tokens {
+		if data.UserID == userID && now.Before(data.ExpiresAt) {
+			tokens = append(tokens, ActiveToken{Token: token, Data: data})
+		}
+	}
+	return tokens, nil
+}
+
+// RevokeToken menghapus token milik user sebelum kedaluwarsa
+func (s *MemoryTokenStore) RevokeToken(token string, userID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, exists := s.tokens[token]
+	if !exists || data.UserID != userID {
+		return errors.New("token tidak ditemukan")
+	}
+
+	delete(s.tokens, token)
+	log.Printf("QR token %s revoked by user ID %d", token, userID)
+	return nil
+}
+
+// CreateStationToken membuat token QR stasiun untuk partner
+func (s *MemoryTokenStore) CreateStationToken(partnerID int, validityDuration time.Duration) (string, time.Time, error) {
+	token, err := generateSecureToken(16)
+	if err != nil {
+		return "", time.Time{}, errors.New("gagal membuat token")
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(validityDuration)
+	s.mu.Lock()
+	s.stations[token] = TokenData{UserID: partnerID, ExpiresAt: expiresAt, CreatedAt: now}
+	s.mu.Unlock()
+
+	log.Printf("Generated station QR token %s for partner ID %d, expires at %s", token, partnerID, expiresAt.Format(time.RFC3339))
+	return token, expiresAt, nil
+}
+
+// ResolveStationToken memeriksa token QR stasiun dan mengembalikan PartnerID
+func (s *MemoryTokenStore) ResolveStationToken(token string) (int, error) {
+	s.mu.RLock()
+	data, exists := s.stations[token]
+	s.mu.RUnlock()
+
+	if !exists {
+		return 0, errors.New("token tidak ditemukan")
+	}
+	if time.Now().After(data.ExpiresAt) {
+		s.mu.Lock()
+		delete(s.stations, token)
+		s.mu.Unlock()
+		return 0, errors.New("token sudah kedaluwarsa")
+	}
+	return data.UserID, nil
+}
+
+// cleanupExpiredTokens berjalan di background untuk menghapus token yang sudah lewat
+func (s *MemoryTokenStore) cleanupExpiredTokens(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.mu.Lock() // Kunci map untuk operasi delete
+		now := time.Now()
+		deletedCount := 0
+		for token, data := range s.tokens {
+			if now.After(data.ExpiresAt) {
+				delete(s.tokens, token)
+				deletedCount++
+			}
+		}
+		for token, data := range s.stations {
+			if now.After(data.ExpiresAt) {
+				delete(s.stations, token)
+				deletedCount++
+			}
+		}
+		s.mu.Unlock() // Buka kunci setelah selesai
+		if deletedCount > 0 {
+			log.Printf("Cleaned up %d expired QR tokens", deletedCount)
+		}
+	}
+}
\ No newline at end of file
@@ -0,0 +1,258 @@
+package temporary_token
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+)
+
+// PostgresTokenStore menyimpan token di tabel deposit_qr_tokens sehingga token
+// tetap berlaku lintas replika API dan setelah binary di-restart.
+type PostgresTokenStore struct {
+	db *sql.DB
+}
+
+// Pastikan PostgresTokenStore memenuhi interface TokenStore
+var _ TokenStore = (*PostgresTokenStore)(nil)
+
+// NewPostgresTokenStore membuat instance PostgresTokenStore baru
+func NewPostgresTokenStore(db *sql.DB) *PostgresTokenStore {
+	store := &PostgresTokenStore{db: db}
+	// Jalankan pembersihan token kedaluwarsa secara berkala (misal: setiap menit)
+	go store.cleanupExpiredTokens(1 * time.Minute)
+	return store
+}
+
+// CreateToken membuat token baru untuk user, menyimpannya, dan mengembalikan token + expiry
+func (s *PostgresTokenStore) CreateToken(userIDStr string, validityDuration time.Duration) (string, time.Time, error) {
+	userID, token, err := newTokenForUser(userIDStr)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(validityDuration)
+	query := `INSERT INTO deposit_qr_tokens (token, user_id, expires_at, created_at) VALUES ($1, $2, $3, $4)`
+	if _, err := s.db.Exec(query, token, userID, expiresAt, now); err != nil {
+		log.Printf("Error storing QR token for user ID %d: %v", userID, err)
+		return "", time.Time{}, errors.New("gagal membuat token")
+	}
+
+	log.Printf("Generated QR token %s for user ID %d, expires at %s", token, userID, expiresAt.Format(time.RFC3339))
+	return token, expiresAt, nil
+}
+
+// ValidateToken memeriksa apakah token ada dan belum kedaluwarsa, mengembalikan UserID
+func (s *PostgresTokenStore) ValidateToken(token string) (int, error) {
+	data, err := s.findToken(token)
+	if err != nil {
+		return 0, err
+	}
+	if data == nil {
+		return 0, errors.New("token tidak ditemukan")
+	}
+	if time.Now().After(data.ExpiresAt) {
+		s.deleteToken(token)
+		return 0, errors.New("token sudah kedaluwarsa")
+	}
+	return data.UserID, nil
+}
+
+// ClaimToken mengikat token ke partner yang pertama kali memverifikasinya secara atomik.
+// Partner yang sama boleh memverifikasi ulang; partner lain akan ditolak.
+func (s *PostgresTokenStore) ClaimToken(token string, partnerID int) (int, error) {
+	query := `
+		UPDATE deposit_qr_tokens
+		SET claimed_by = $2, claimed_at = COALESCE(claimed_at, NOW())
+		WHERE token = $1 AND expires_at > $3 AND (claimed_by IS NULL OR claimed_by = $2)
+		RETURNING user_id`
+	var userID int
+	err := s.db.QueryRow(query, token, partnerID, time.Now()).Scan(&userID)
+	if err == nil {
+		return userID, nil
+	}
+	if err != sql.ErrNoRows {
+		log.Printf("Error claiming QR token %s for partner ID %d: %v", token, partnerID, err)
+		return 0, errors.New("gagal memvalidasi token")
+	}
+	return 0, s.explainRejectedToken(token)
+}
+
+// ConsumeToken menghapus token yang sudah diklaim oleh partner, sehingga hanya bisa dipakai sekali.
+// Jika tx diisi, DELETE ikut transaksi tersebut sehingga rollback mengembalikan token (restore tidak melakukan apa-apa).
+func (s *PostgresTokenStore) ConsumeToken(tx *sql.Tx, token string, partnerID int) (int, func(), error) {
+	query := `
+		DELETE FROM deposit_qr_tokens
+		WHERE token = $1 AND claimed_by = $2 AND expires_at > $3
+		RETURNING user_id`
+	var row *sql.Row
+	if tx != nil {
+		row = tx.QueryRow(query, token, partnerID, time.Now())
+	} else {
+		row = s.db.QueryRow(query, token, partnerID, time.Now())
+	}
+	var userID int
+	err := row.Scan(&userID)
+	if err == nil {
+		log.Printf("QR token %s for user ID %d consumed by partner ID %d", token, userID, partnerID)
+		return userID, func() {}, nil
+	}
+	if err != sql.ErrNoRows {
+		log.Printf("Error consuming QR token %s for partner ID %d: %v", token, partnerID, err)
+		return 0, nil, errors.New("gagal memvalidasi token")
+	}
+	return 0, nil, s.explainRejectedToken(token)
+}
+
+// ListActiveTokens mengembalikan token milik user yang belum kedaluwarsa
+func (s *PostgresTokenStore) ListActiveTokens(userID int) ([]ActiveToken, error) {
+	query := `
+		SELECT token, user_id, expires_at, created_at, claimed_by, claimed_at
+		FROM deposit_qr_tokens
+		WHERE user_id = $1 AND expires_at > $2`
+	rows, err := s.db.Query(query, userID, time.Now())
+	if err != nil {
+		log.Printf("Error listing QR tokens for user ID %d: %v", userID, err)
+		return nil, errors.New("gagal mengambil token")
+	}
+	defer rows.Close()
+
+	tokens := []ActiveToken{}
+	for rows.Next() {
+		var t ActiveToken
+		var claimedBy sql.NullInt32
+		var claimedAt sql.NullTime
+		if err := rows.Scan(&t.Token, &t.Data.UserID, &t.Data.ExpiresAt, &t.Data.CreatedAt, &claimedBy, &claimedAt); err != nil {
+			log.Printf("Error scanning QR token row: %v", err)
+			return nil, errors.New("gagal mengambil token")
+		}
+		t.Data.ClaimedBy = int(claimedBy.Int32)
+		t.Data.ClaimedAt = claimedAt.Time
+		tokens = append(tokens, t)
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("Error iterating QR tokens for user ID %d: %v", userID, err)
+		return nil, errors.New("gagal mengambil token")
+	}
+	return tokens, nil
+}
+
+// RevokeToken menghapus token milik user sebelum kedaluwarsa
+func (s *PostgresTokenStore) RevokeToken(token string, userID int) error {
+	result, err := s.db.Exec(`DELETE FROM deposit_qr_tokens WHERE token = $1 AND user_id = $2`, token, userID)
+	if err != nil {
+		log.Printf("Error revoking QR token %s for user ID %d: %v", token, userID, err)
+		return errors.New("gagal membatalkan token")
+	}
+	rowsAffected, _ := result.RowsAffected()
+	if rowsAffected == 0 {
+		return errors.New("token tidak ditemukan")
+	}
+	log.Printf("QR token %s revoked by user ID %d", token, userID)
+	return nil
+}
+
+// CreateStationToken membuat token QR stasiun untuk partner
+func (s *PostgresTokenStore) CreateStationToken(partnerID int, validityDuration time.Duration) (string, time.Time, error) {
+	token, err := generateSecureToken(16)
+	if err != nil {
+		return "", time.Time{}, errors.New("gagal membuat token")
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(validityDuration)
+	query := `INSERT INTO station_qr_tokens (token, partner_id, expires_at, created_at) VALUES ($1, $2, $3, $4)`
+	if _, err := s.db.Exec(query, token, partnerID, expiresAt, now); err != nil {
+		log.Printf("Error storing station QR token for partner ID %d: %v", partnerID, err)
+		return "", time.Time{}, errors.New("gagal membuat token")
+	}
+
+	log.Printf("Generated station QR token %s for partner ID %d, expires at %s", token, partnerID, expiresAt.Format(time.RFC3339))
+	return token, expiresAt, nil
+}
+
+// ResolveStationToken memeriksa token QR stasiun dan mengembalikan PartnerID
+func (s *PostgresTokenStore) ResolveStationToken(token string) (int, error) {
+	var partnerID int
+	var expiresAt time.Time
+	err := s.db.QueryRow(`SELECT partner_id, expires_at FROM station_qr_tokens WHERE token = $1`, token).Scan(&partnerID, &expiresAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, errors.New("token tidak ditemukan")
+		}
+		log.Printf("Error finding station QR token %s: %v", token, err)
+		return 0, errors.New("gagal memvalidasi token")
+	}
+	if time.Now().After(expiresAt) {
+		if _, err := s.db.Exec(`DELETE FROM station_qr_tokens WHERE token = $1`, token); err != nil {
+			log.Printf("Error deleting expired station QR token %s: %v", token, err)
+		}
+		return 0, errors.New("token sudah kedaluwarsa")
+	}
+	return partnerID, nil
+}
+
+// findToken mengambil data token, nil jika tidak ada
+func (s *PostgresTokenStore) findToken(token string) (*TokenData, error) {
+	query := `SELECT user_id, expires_at, created_at, claimed_by, claimed_at FROM deposit_qr_tokens WHERE token = $1`
+	var data TokenData
+	var claimedBy sql.NullInt32
+	var claimedAt sql.NullTime
+	err := s.db.QueryRow(query, token).Scan(&data.UserID, &data.ExpiresAt, &data.CreatedAt, &claimedBy, &claimedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		log.Printf("Error finding QR token %s: %v", token, err)
+		return nil, errors.New("gagal memvalidasi token")
+	}
+	data.ClaimedBy = int(claimedBy.Int32)
+	data.ClaimedAt = claimedAt.Time
+	return &data, nil
+}
+
+// explainRejectedToken menentukan alasan token ditolak oleh UPDATE/DELETE bersyarat,
+// dengan pesan error yang sama seperti MemoryTokenStore.
+func (s *PostgresTokenStore) explainRejectedToken(token string) error {
+	data, err := s.findToken(token)
+	if err != nil {
+		return err
+	}
+	if data == nil {
+		return errors.New("token tidak ditemukan")
+	}
+	if time.Now().After(data.ExpiresAt) {
+		s.deleteToken(token)
+		return errors.New("token sudah kedaluwarsa")
+	}
+	return errors.New("token sudah digunakan oleh mitra lain")
+}
+
+// deleteToken menghapus token tanpa syarat (dipakai untuk token kedaluwarsa)
+func (s *PostgresTokenStore) deleteToken(token string) {
+	if _, err := s.db.Exec(`DELETE FROM deposit_qr_tokens WHERE token = $1`, token); err != nil {
+		log.Printf("Error deleting expired QR token %s: %v", token, err)
+	}
+}
+
+// cleanupExpiredTokens berjalan di background untuk menghapus token yang sudah lewat.
+// Aman dijalankan di banyak replika sekaligus.
+func (s *PostgresTokenStore) cleanupExpiredTokens(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		result, err := s.db.Exec(`DELETE FROM deposit_qr_tokens WHERE expires_at <= $1`, time.Now())
+		if err != nil {
+			log.Printf("Error cleaning up expired QR tokens: %v", err)
+			continue
+		}
+		if deletedCount, _ := result.RowsAffected(); deletedCount > 0 {
+			log.Printf("Cleaned up %d expired QR tokens", deletedCount)
+		}
+		if _, err := s.db.Exec(`DELETE FROM station_qr_tokens WHERE expires_at <= $1`, time.Now()); err != nil {
+			log.Printf("Error cleaning up expired station QR tokens: %v", err)
+		}
+	}
+}
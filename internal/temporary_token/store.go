@@ -2,34 +2,49 @@ package temporary_token
 
 import (
 	"crypto/rand"
+	"database/sql"
 	"encoding/hex"
 	"errors"
-	"log"
 	"strconv"
-	"sync" // Untuk menangani akses bersamaan ke map (goroutine safety)
 	"time"
 )
 
+// TokenStore adalah abstraksi penyimpanan token QR deposit sementara.
+// Implementasi: MemoryTokenStore (satu instance) dan PostgresTokenStore (multi-instance).
+type TokenStore interface {
+	// CreateToken membuat token baru untuk user dan mengembalikan token + expiry
+	CreateToken(userIDStr string, validityDuration time.Duration) (string, time.Time, error)
+	// ValidateToken memeriksa token tanpa mengklaim atau memakainya, mengembalikan UserID
+	ValidateToken(token string) (int, error)
+	// ClaimToken mengikat token ke partner pertama yang memverifikasinya
+	ClaimToken(token string, partnerID int) (int, error)
+	// ConsumeToken menghapus token yang sudah diklaim partner (sekali pakai) di dalam transaksi tx
+	// (nil = langsung). restore mengembalikan token jika tx di-rollback, untuk penyimpanan yang tidak ikut rollback.
+	ConsumeToken(tx *sql.Tx, token string, partnerID int) (userID int, restore func(), err error)
+	// ListActiveTokens mengembalikan token milik user yang belum kedaluwarsa
+	ListActiveTokens(userID int) ([]ActiveToken, error)
+	// RevokeToken menghapus token milik user sebelum kedaluwarsa
+	RevokeToken(token string, userID int) error
+
+	// CreateStationToken membuat token QR stasiun milik partner (bisa dipindai banyak user)
+	CreateStationToken(partnerID int, validityDuration time.Duration) (string, time.Time, error)
+	// ResolveStationToken memeriksa token QR stasiun dan mengembalikan PartnerID
+	ResolveStationToken(token string) (int, error)
+}
+
 // TokenData menyimpan informasi yang terkait dengan token
 type TokenData struct {
 	UserID    int       // ID pengguna yang memiliki token
 	ExpiresAt time.Time // Waktu kedaluwarsa token
+	CreatedAt time.Time // Waktu token dibuat
+	ClaimedBy int       // ID partner yang sudah memverifikasi token (0 = belum diklaim)
+	ClaimedAt time.Time // Waktu token diklaim partner
 }
 
-// TokenStore menyimpan token aktif di memori
-type TokenStore struct {
-	mu     sync.RWMutex // Mutex untuk melindungi akses ke map
-	tokens map[string]TokenData
-}
-
-// NewTokenStore membuat instance TokenStore baru
-func NewTokenStore() *TokenStore {
-	store := &TokenStore{
-		tokens: make(map[string]TokenData),
-	}
-	// Jalankan pembersihan token kedaluwarsa secara berkala (misal: setiap menit)
-	go store.cleanupExpiredTokens(1 * time.Minute)
-	return store
+// ActiveToken adalah token yang masih aktif beserta datanya (untuk ditampilkan ke user)
+type ActiveToken struct {
+	Token string
+	Data  TokenData
 }
 
 // generateSecureToken membuat string acak yang aman
@@ -41,72 +56,16 @@ func generateSecureToken(length int) (string, error) {
 	return hex.EncodeToString(bytes), nil
 }
 
-// CreateToken membuat token baru untuk user, menyimpannya, dan mengembalikan token + expiry
-func (s *TokenStore) CreateToken(userIDStr string, validityDuration time.Duration) (string, time.Time, error) {
+// newTokenForUser memvalidasi ID user dan membuat string token baru (dipakai semua implementasi)
+func newTokenForUser(userIDStr string) (int, string, error) {
 	userID, err := strconv.Atoi(userIDStr)
 	if err != nil {
-		return "", time.Time{}, errors.New("ID pengguna tidak valid")
+		return 0, "", errors.New("ID pengguna tidak valid")
 	}
 
 	token, err := generateSecureToken(16) // Buat token 16 byte (32 karakter hex)
 	if err != nil {
-		return "", time.Time{}, errors.New("gagal membuat token")
+		return 0, "", errors.New("gagal membuat token")
 	}
-
-	expiresAt := time.Now().Add(validityDuration)
-	data := TokenData{
-		UserID:    userID,
-		ExpiresAt: expiresAt,
-	}
-
-	s.mu.Lock() // Kunci map sebelum menulis
-	s.tokens[token] = data
-	s.mu.Unlock() // Buka kunci setelah selesai
-
-	log.Printf("Generated QR token %s for user ID %d, expires at %s", token, userID, expiresAt.Format(time.RFC3339))
-	return token, expiresAt, nil
+	return userID, token, nil
 }
-
-// ValidateToken memeriksa apakah token ada dan belum kedaluwarsa, mengembalikan UserID
-// (Fungsi ini akan dipakai oleh endpoint partner nanti)
-func (s *TokenStore) ValidateToken(token string) (int, error) {
-	s.mu.RLock() // Kunci map untuk membaca
-	data, exists := s.tokens[token]
-	s.mu.RUnlock() // Buka kunci setelah selesai
-
-	if !exists {
-		return 0, errors.New("token tidak ditemukan")
-	}
-
-	if time.Now().After(data.ExpiresAt) {
-		// Hapus token yang sudah kedaluwarsa saat divalidasi
-		s.mu.Lock()
-		delete(s.tokens, token)
-		s.mu.Unlock()
-		return 0, errors.New("token sudah kedaluwarsa")
-	}
-
-	return data.UserID, nil
-}
-
-// cleanupExpiredTokens berjalan di background untuk menghapus token yang sudah lewat
-func (s *TokenStore) cleanupExpiredTokens(interval time.Duration) {
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		s.mu.Lock() // Kunci map untuk operasi delete
-		now := time.Now()
-		deletedCount := 0
-		for token, data := range s.tokens {
-			if now.After(data.ExpiresAt) {
-				delete(s.tokens, token)
-				deletedCount++
-			}
-		}
-		s.mu.Unlock() // Buka kunci setelah selesai
-		if deletedCount > 0 {
-			log.Printf("Cleaned up %d expired QR tokens", deletedCount)
-		}
-	}
-}
\ No newline at end of file
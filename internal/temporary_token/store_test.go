@@ -0,0 +1,232 @@
+package temporary_token
+
+import (
+	"database/sql"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// tokenStoreFixture berisi store yang diuji beserta ID user/partner yang valid untuk store tersebut
+type tokenStoreFixture struct {
+	store              TokenStore
+	userID             int
+	partnerA, partnerB int
+	beginTx            func(t *testing.T) *sql.Tx // nil tx berarti store tidak ikut transaksi database
+}
+
+// TestMemoryTokenStoreContract menjalankan kontrak TokenStore terhadap MemoryTokenStore
+func TestMemoryTokenStoreContract(t *testing.T) {
+	runTokenStoreContract(t, tokenStoreFixture{
+		store:    NewMemoryTokenStore(),
+		userID:   1,
+		partnerA: 10,
+		partnerB: 11,
+		beginTx:  func(t *testing.T) *sql.Tx { return nil },
+	})
+}
+
+// TestPostgresTokenStoreContract menjalankan kontrak yang sama terhadap PostgresTokenStore.
+// Butuh TEST_DATABASE_URL yang menunjuk database dengan migrasi terpasang, satu user, dan dua partner.
+func TestPostgresTokenStoreContract(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL tidak diisi")
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	fixture := tokenStoreFixture{store: NewPostgresTokenStore(db)}
+	if err := db.QueryRow(`SELECT id FROM users ORDER BY id LIMIT 1`).Scan(&fixture.userID); err != nil {
+		t.Skipf("butuh minimal satu user: %v", err)
+	}
+	rows, err := db.Query(`SELECT id FROM partners ORDER BY id LIMIT 2`)
+	if err != nil {
+		t.Fatalf("query partners: %v", err)
+	}
+	partnerIDs := []int{}
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			t.Fatalf("scan partner: %v", err)
+		}
+		partnerIDs = append(partnerIDs, id)
+	}
+	rows.Close()
+	if len(partnerIDs) < 2 {
+		t.Skip("butuh minimal dua partner")
+	}
+	fixture.partnerA, fixture.partnerB = partnerIDs[0], partnerIDs[1]
+	fixture.beginTx = func(t *testing.T) *sql.Tx {
+		tx, err := db.Begin()
+		if err != nil {
+			t.Fatalf("begin tx: %v", err)
+		}
+		return tx
+	}
+
+	startedAt := time.Now().Add(-time.Second)
+	t.Cleanup(func() {
+		db.Exec(`DELETE FROM deposit_qr_tokens WHERE user_id = $1 AND created_at >= $2`, fixture.userID, startedAt)
+		db.Exec(`DELETE FROM station_qr_tokens WHERE partner_id = $1 AND created_at >= $2`, fixture.partnerA, startedAt)
+	})
+	runTokenStoreContract(t, fixture)
+}
+
+// runTokenStoreContract memeriksa perilaku yang harus sama di semua implementasi TokenStore,
+// termasuk pesan error yang dipetakan handler menjadi status HTTP
+func runTokenStoreContract(t *testing.T, f tokenStoreFixture) {
+	userIDStr := strconv.Itoa(f.userID)
+	newToken := func(t *testing.T, validity time.Duration) string {
+		t.Helper()
+		token, expiresAt, err := f.store.CreateToken(userIDStr, validity)
+		if err != nil {
+			t.Fatalf("CreateToken: %v", err)
+		}
+		if token == "" || expiresAt.IsZero() {
+			t.Fatalf("CreateToken returned empty token or expiry")
+		}
+		return token
+	}
+	expectErr := func(t *testing.T, err error, want string) {
+		t.Helper()
+		if err == nil || err.Error() != want {
+			t.Fatalf("error = %v, want %q", err, want)
+		}
+	}
+
+	t.Run("create rejects invalid user id", func(t *testing.T) {
+		_, _, err := f.store.CreateToken("abc", time.Minute)
+		expectErr(t, err, "ID pengguna tidak valid")
+	})
+
+	t.Run("validate and list active token", func(t *testing.T) {
+		token := newToken(t, time.Minute)
+		userID, err := f.store.ValidateToken(token)
+		if err != nil || userID != f.userID {
+			t.Fatalf("ValidateToken = %d, %v; want %d", userID, err, f.userID)
+		}
+		tokens, err := f.store.ListActiveTokens(f.userID)
+		if err != nil {
+			t.Fatalf("ListActiveTokens: %v", err)
+		}
+		found := false
+		for _, active := range tokens {
+			if active.Token == token {
+				found = true
+				if active.Data.ClaimedBy != 0 {
+					t.Fatalf("new token already claimed by %d", active.Data.ClaimedBy)
+				}
+			}
+		}
+		if !found {
+			t.Fatalf("token %s not listed as active", token)
+		}
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		_, err := f.store.ValidateToken("tidak-ada")
+		expectErr(t, err, "token tidak ditemukan")
+		_, err = f.store.ClaimToken("tidak-ada", f.partnerA)
+		expectErr(t, err, "token tidak ditemukan")
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		token := newToken(t, -time.Second)
+		_, err := f.store.ValidateToken(token)
+		expectErr(t, err, "token sudah kedaluwarsa")
+		tokens, err := f.store.ListActiveTokens(f.userID)
+		if err != nil {
+			t.Fatalf("ListActiveTokens: %v", err)
+		}
+		for _, active := range tokens {
+			if active.Token == token {
+				t.Fatalf("expired token listed as active")
+			}
+		}
+	})
+
+	t.Run("claim binds token to first partner", func(t *testing.T) {
+		token := newToken(t, time.Minute)
+		if _, err := f.store.ClaimToken(token, f.partnerA); err != nil {
+			t.Fatalf("first claim: %v", err)
+		}
+		if _, err := f.store.ClaimToken(token, f.partnerA); err != nil {
+			t.Fatalf("same partner re-claim: %v", err)
+		}
+		_, err := f.store.ClaimToken(token, f.partnerB)
+		expectErr(t, err, "token sudah digunakan oleh mitra lain")
+	})
+
+	t.Run("consume is single use and claim bound", func(t *testing.T) {
+		token := newToken(t, time.Minute)
+		if _, err := f.store.ClaimToken(token, f.partnerA); err != nil {
+			t.Fatalf("claim: %v", err)
+		}
+		_, _, err := f.store.ConsumeToken(nil, token, f.partnerB)
+		expectErr(t, err, "token sudah digunakan oleh mitra lain")
+
+		userID, _, err := f.store.ConsumeToken(nil, token, f.partnerA)
+		if err != nil || userID != f.userID {
+			t.Fatalf("ConsumeToken = %d, %v; want %d", userID, err, f.userID)
+		}
+		_, _, err = f.store.ConsumeToken(nil, token, f.partnerA)
+		expectErr(t, err, "token tidak ditemukan")
+	})
+
+	t.Run("rolled back consume keeps token usable", func(t *testing.T) {
+		token := newToken(t, time.Minute)
+		if _, err := f.store.ClaimToken(token, f.partnerA); err != nil {
+			t.Fatalf("claim: %v", err)
+		}
+		tx := f.beginTx(t)
+		_, restore, err := f.store.ConsumeToken(tx, token, f.partnerA)
+		if err != nil {
+			t.Fatalf("ConsumeToken in tx: %v", err)
+		}
+		if tx != nil {
+			tx.Rollback()
+		}
+		restore()
+
+		if _, _, err := f.store.ConsumeToken(nil, token, f.partnerA); err != nil {
+			t.Fatalf("consume after rollback: %v", err)
+		}
+	})
+
+	t.Run("revoke only by owner", func(t *testing.T) {
+		token := newToken(t, time.Minute)
+		expectErr(t, f.store.RevokeToken(token, f.userID+1), "token tidak ditemukan")
+		if err := f.store.RevokeToken(token, f.userID); err != nil {
+			t.Fatalf("RevokeToken: %v", err)
+		}
+		_, err := f.store.ValidateToken(token)
+		expectErr(t, err, "token tidak ditemukan")
+	})
+
+	t.Run("station token", func(t *testing.T) {
+		token, _, err := f.store.CreateStationToken(f.partnerA, time.Minute)
+		if err != nil {
+			t.Fatalf("CreateStationToken: %v", err)
+		}
+		partnerID, err := f.store.ResolveStationToken(token)
+		if err != nil || partnerID != f.partnerA {
+			t.Fatalf("ResolveStationToken = %d, %v; want %d", partnerID, err, f.partnerA)
+		}
+
+		expired, _, err := f.store.CreateStationToken(f.partnerA, -time.Second)
+		if err != nil {
+			t.Fatalf("CreateStationToken: %v", err)
+		}
+		_, err = f.store.ResolveStationToken(expired)
+		expectErr(t, err, "token sudah kedaluwarsa")
+		_, err = f.store.ResolveStationToken("tidak-ada")
+		expectErr(t, err, "token tidak ditemukan")
+	})
+}
@@ -0,0 +1,61 @@
+package thumbnail
+
+import (
+	"image"
+	"image/color"
+	_ "image/gif" // Registrasi decoder GIF
+	"image/jpeg"
+	_ "image/png" // Registrasi decoder PNG
+	"io"
+)
+
+// DefaultMaxSize sisi terpanjang thumbnail (px)
+const DefaultMaxSize = 320
+
+// Generate membaca gambar (JPEG/PNG/GIF) dari r, mengecilkannya hingga sisi terpanjang maxSize,
+// lalu menulis hasilnya sebagai JPEG ke w. Gambar yang sudah kecil tidak diperbesar.
+func Generate(r io.Reader, w io.Writer, maxSize int) error {
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return err
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > maxSize || height > maxSize {
+		if width >= height {
+			height = max(1, height*maxSize/width)
+			width = maxSize
+		} else {
+			width = max(1, width*maxSize/height)
+			height = maxSize
+		}
+	}
+
+	return jpeg.Encode(w, resize(src, width, height), &jpeg.Options{Quality: 75})
+}
+
+// resize mengecilkan gambar dengan rata-rata area (box filter) agar hasil tidak bergerigi
+func resize(src image.Image, width, height int) image.Image {
+	bounds := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*bounds.Dy()/height)
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := max(x0+1, bounds.Min.X+(x+1)*bounds.Dx()/width)
+
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(cr), g+uint64(cg), b+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.Set(x, y, color.RGBA64{R: uint16(r / n), G: uint16(g / n), B: uint16(b / n), A: uint16(a / n)})
+		}
+	}
+	return dst
+}
@@ -0,0 +1,27 @@
+-- Entri reversal (refund / chargeback) untuk topup yang sudah dikreditkan.
+-- Satu baris per debit; total per topup tidak boleh melebihi user_topup_histories.amount.
+CREATE TABLE IF NOT EXISTS user_topup_reversals (
+    id             SERIAL PRIMARY KEY,
+    topup_id       INTEGER NOT NULL REFERENCES user_topup_histories(id) ON DELETE CASCADE,
+    user_id        INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type           VARCHAR(20) NOT NULL CHECK (type IN ('refund', 'chargeback')),
+    amount         NUMERIC(15, 2) NOT NULL CHECK (amount > 0),
+    transaction_id VARCHAR(100),
+    reason         TEXT,
+    source         VARCHAR(20) NOT NULL DEFAULT 'webhook', -- 'webhook' atau 'admin'
+    reversal_time  TIMESTAMP NOT NULL DEFAULT NOW(),
+    created_at     TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_user_topup_reversals_topup_id ON user_topup_reversals(topup_id);
+CREATE INDEX IF NOT EXISTS idx_user_topup_reversals_user_id ON user_topup_reversals(user_id);
+
+-- Saldo boleh negatif setelah refund/chargeback jika dana sudah terpakai.
+ALTER TABLE user_wallets DROP CONSTRAINT IF EXISTS user_wallets_balance_check;
+
+-- Total yang sudah direversal per topup; status akhir (Refunded / Charged Back) hanya jika mencapai amount
+ALTER TABLE user_topup_histories ADD COLUMN IF NOT EXISTS reversed_amount NUMERIC(15, 2) NOT NULL DEFAULT 0;
+UPDATE user_topup_histories uth
+SET reversed_amount = r.total
+FROM (SELECT topup_id, SUM(amount) AS total FROM user_topup_reversals GROUP BY topup_id) r
+WHERE r.topup_id = uth.id AND uth.reversed_amount <> r.total;
@@ -0,0 +1,12 @@
+-- Token QR deposit sementara untuk PostgresTokenStore (TOKEN_STORE=postgres).
+CREATE TABLE IF NOT EXISTS deposit_qr_tokens (
+    token      VARCHAR(64) PRIMARY KEY,
+    user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    claimed_by INTEGER REFERENCES partners(id) ON DELETE SET NULL, -- Partner yang memverifikasi token
+    claimed_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_deposit_qr_tokens_user_id ON deposit_qr_tokens(user_id);
+CREATE INDEX IF NOT EXISTS idx_deposit_qr_tokens_expires_at ON deposit_qr_tokens(expires_at);
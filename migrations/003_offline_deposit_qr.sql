@@ -0,0 +1,24 @@
+-- Kunci Ed25519 untuk menandatangani kode QR deposit offline.
+-- Rotasi kunci (status 'Retired') mencabut semua kode yang ditandatangani kunci tersebut.
+CREATE TABLE IF NOT EXISTS deposit_qr_signing_keys (
+    id          VARCHAR(16) PRIMARY KEY,
+    public_key  BYTEA NOT NULL,
+    private_key BYTEA NOT NULL,
+    status      VARCHAR(20) NOT NULL DEFAULT 'Active', -- 'Active' atau 'Retired'
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    retired_at  TIMESTAMPTZ
+);
+
+-- Nonce kode QR offline yang sudah dipakai untuk deposit (satu kode = satu deposit).
+CREATE TABLE IF NOT EXISTS deposit_qr_nonces (
+    nonce      VARCHAR(32) PRIMARY KEY,
+    key_id     VARCHAR(16) NOT NULL REFERENCES deposit_qr_signing_keys(id),
+    user_id    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    partner_id INTEGER NOT NULL REFERENCES partners(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Private key disimpan terenkripsi (AES-256-GCM, kunci dari OFFLINE_QR_KEY_ENCRYPTION_KEY).
+-- Baris lama yang masih plaintext (FALSE) dienkripsi ulang oleh aplikasi saat start.
+ALTER TABLE deposit_qr_signing_keys ADD COLUMN IF NOT EXISTS private_key_encrypted BOOLEAN NOT NULL DEFAULT FALSE;
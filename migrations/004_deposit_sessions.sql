@@ -0,0 +1,27 @@
+-- Token QR stasiun milik partner (dipindai user untuk check-in mandiri)
+CREATE TABLE IF NOT EXISTS station_qr_tokens (
+    token      VARCHAR(64) PRIMARY KEY,
+    partner_id INTEGER NOT NULL REFERENCES partners(id) ON DELETE CASCADE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_station_qr_tokens_expires_at ON station_qr_tokens(expires_at);
+
+-- Sesi deposit: dibuka user lewat QR stasiun, diisi item oleh partner, lalu dikonfirmasi menjadi deposit
+CREATE TABLE IF NOT EXISTS deposit_sessions (
+    id                         SERIAL PRIMARY KEY,
+    partner_id                 INTEGER NOT NULL REFERENCES partners(id) ON DELETE CASCADE,
+    user_id                    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status                     VARCHAR(20) NOT NULL DEFAULT 'Open', -- Open, Items Added, Confirmed, Cancelled
+    items                      JSONB NOT NULL DEFAULT '[]',         -- []DepositSessionItem
+    total_weight               NUMERIC(10, 2) NOT NULL DEFAULT 0,
+    estimated_xpoin            INTEGER NOT NULL DEFAULT 0,
+    partner_deposit_history_id INTEGER REFERENCES partner_deposit_histories(id) ON DELETE SET NULL,
+    cancelled_by               VARCHAR(10),                         -- 'user' atau 'partner'
+    created_at                 TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at                 TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_deposit_sessions_partner_status ON deposit_sessions(partner_id, status);
+CREATE INDEX IF NOT EXISTS idx_deposit_sessions_user_id ON deposit_sessions(user_id);
@@ -0,0 +1,28 @@
+-- Draft deposit dua tahap: partner mengajukan hasil timbang, user menerima/menolak.
+-- Wallet & statistik baru berubah setelah draft diterima (oleh user atau otomatis setelah expires_at).
+CREATE TABLE IF NOT EXISTS deposit_drafts (
+    id                         SERIAL PRIMARY KEY,
+    partner_id                 INTEGER NOT NULL REFERENCES partners(id) ON DELETE CASCADE,
+    user_id                    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    deposit_method_id          INTEGER NOT NULL,
+    status                     VARCHAR(20) NOT NULL DEFAULT 'Pending', -- Pending, Accepted, Rejected, Failed
+    items                      JSONB NOT NULL,                         -- []DepositDraftItem
+    total_weight               NUMERIC(10, 2) NOT NULL,
+    total_xpoin                INTEGER NOT NULL,
+    notes                      TEXT,
+    photo                      TEXT,
+    expires_at                 TIMESTAMPTZ NOT NULL,
+    decided_by                 VARCHAR(10),                            -- 'user' atau 'auto'
+    decided_at                 TIMESTAMPTZ,
+    reject_reason              TEXT,
+    partner_deposit_history_id INTEGER REFERENCES partner_deposit_histories(id) ON DELETE SET NULL,
+    created_at                 TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at                 TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_deposit_drafts_user_status ON deposit_drafts(user_id, status);
+CREATE INDEX IF NOT EXISTS idx_deposit_drafts_partner_status ON deposit_drafts(partner_id, status);
+CREATE INDEX IF NOT EXISTS idx_deposit_drafts_pending_expiry ON deposit_drafts(expires_at) WHERE status = 'Pending';
+
+-- Percobaan menjalankan deposit yang gagal sebelum commit; setelah batas percobaan draft menjadi Failed
+ALTER TABLE deposit_drafts ADD COLUMN IF NOT EXISTS attempts INTEGER NOT NULL DEFAULT 0;
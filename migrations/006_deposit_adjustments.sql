@@ -0,0 +1,24 @@
+-- Void & koreksi deposit setelah transaksi tercatat
+ALTER TABLE partner_deposit_histories ADD COLUMN IF NOT EXISTS voided_at TIMESTAMPTZ;
+
+-- Jejak audit setiap perubahan deposit (satu baris per detail yang berubah)
+CREATE TABLE IF NOT EXISTS deposit_adjustments (
+    id                         SERIAL PRIMARY KEY,
+    partner_deposit_history_id INTEGER NOT NULL REFERENCES partner_deposit_histories(id) ON DELETE CASCADE,
+    detail_id                  INTEGER REFERENCES partner_deposit_history_details(id) ON DELETE SET NULL,
+    type                       VARCHAR(20) NOT NULL, -- 'void' atau 'correction'
+    actor_type                 VARCHAR(10) NOT NULL, -- 'partner' atau 'admin'
+    actor_id                   INTEGER,              -- ID partner; NULL untuk admin
+    reason                     TEXT NOT NULL,
+    old_weight                 NUMERIC(10, 2) NOT NULL,
+    new_weight                 NUMERIC(10, 2) NOT NULL,
+    old_xpoin                  INTEGER NOT NULL,
+    new_xpoin                  INTEGER NOT NULL,
+    created_at                 TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_deposit_adjustments_deposit_id ON deposit_adjustments(partner_deposit_history_id);
+
+-- Jumlah sebelum/sesudah untuk item bersatuan pcs (NULL untuk item kg)
+ALTER TABLE deposit_adjustments ADD COLUMN IF NOT EXISTS old_quantity INTEGER;
+ALTER TABLE deposit_adjustments ADD COLUMN IF NOT EXISTS new_quantity INTEGER;
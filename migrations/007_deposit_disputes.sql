@@ -0,0 +1,26 @@
+-- Sengketa deposit: user mengajukan keberatan, partner menanggapi, admin memutuskan (opsional dengan void/koreksi)
+CREATE TABLE IF NOT EXISTS deposit_disputes (
+    id                         SERIAL PRIMARY KEY,
+    partner_deposit_history_id INTEGER NOT NULL REFERENCES partner_deposit_histories(id) ON DELETE CASCADE,
+    user_deposit_history_id    INTEGER NOT NULL,
+    user_id                    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    partner_id                 INTEGER NOT NULL REFERENCES partners(id) ON DELETE CASCADE,
+    status                     VARCHAR(20) NOT NULL DEFAULT 'Open', -- Open, Partner Responded, Escalated, Resolved, Rejected
+    reason                     TEXT NOT NULL,
+    photos                     JSONB NOT NULL DEFAULT '[]',         -- []string URL foto bukti dari user
+    response_deadline          TIMESTAMPTZ NOT NULL,                -- Batas waktu tanggapan partner sebelum eskalasi
+    partner_response           TEXT,
+    partner_responded_at       TIMESTAMPTZ,
+    resolution_note            TEXT,
+    adjustment_type            VARCHAR(20),                         -- 'void' atau 'correction' jika admin mengubah deposit
+    resolved_at                TIMESTAMPTZ,
+    created_at                 TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at                 TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Satu deposit hanya boleh punya satu sengketa yang belum selesai
+CREATE UNIQUE INDEX IF NOT EXISTS idx_deposit_disputes_unresolved
+    ON deposit_disputes(partner_deposit_history_id) WHERE status NOT IN ('Resolved', 'Rejected');
+CREATE INDEX IF NOT EXISTS idx_deposit_disputes_user_id ON deposit_disputes(user_id);
+CREATE INDEX IF NOT EXISTS idx_deposit_disputes_partner_status ON deposit_disputes(partner_id, status);
+CREATE INDEX IF NOT EXISTS idx_deposit_disputes_open_deadline ON deposit_disputes(response_deadline) WHERE status = 'Open';
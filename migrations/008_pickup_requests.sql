@@ -0,0 +1,38 @@
+-- Titik lokasi alamat user agar permintaan jemput bisa dicocokkan dengan partner terdekat
+ALTER TABLE user_addresses ADD COLUMN IF NOT EXISTS latitude DOUBLE PRECISION;
+ALTER TABLE user_addresses ADD COLUMN IF NOT EXISTS longitude DOUBLE PRECISION;
+
+-- Partner memilih apakah melayani penjemputan
+ALTER TABLE partner_addresses ADD COLUMN IF NOT EXISTS pickup_enabled BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Permintaan jemput sampah: Requested -> Scheduled -> On The Way -> Collected -> Completed (menjadi deposit)
+CREATE TABLE IF NOT EXISTS pickup_requests (
+    id                         SERIAL PRIMARY KEY,
+    user_id                    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    user_address_id            INTEGER REFERENCES user_addresses(id) ON DELETE SET NULL,
+    partner_id                 INTEGER REFERENCES partners(id) ON DELETE SET NULL, -- Terisi saat partner menerima
+    status                     VARCHAR(20) NOT NULL DEFAULT 'Requested',
+    contact_name               VARCHAR(255) NOT NULL, -- Salinan alamat saat permintaan dibuat
+    contact_phone              VARCHAR(50) NOT NULL,
+    address                    TEXT NOT NULL,
+    latitude                   DOUBLE PRECISION NOT NULL,
+    longitude                  DOUBLE PRECISION NOT NULL,
+    items                      JSONB NOT NULL,        -- []PickupRequestItem (estimasi dari user)
+    estimated_weight           NUMERIC(10, 2) NOT NULL,
+    slot_start                 TIMESTAMPTZ NOT NULL,
+    slot_end                   TIMESTAMPTZ NOT NULL,
+    notes                      TEXT,
+    cancelled_by               VARCHAR(10),           -- 'user'
+    cancel_reason              TEXT,
+    partner_deposit_history_id INTEGER REFERENCES partner_deposit_histories(id) ON DELETE SET NULL,
+    scheduled_at               TIMESTAMPTZ,
+    on_the_way_at              TIMESTAMPTZ,
+    collected_at               TIMESTAMPTZ,
+    completed_at               TIMESTAMPTZ,
+    created_at                 TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at                 TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_pickup_requests_user_id ON pickup_requests(user_id);
+CREATE INDEX IF NOT EXISTS idx_pickup_requests_partner_status ON pickup_requests(partner_id, status);
+CREATE INDEX IF NOT EXISTS idx_pickup_requests_open_slot ON pickup_requests(slot_start) WHERE status = 'Requested';
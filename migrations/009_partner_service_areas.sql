@@ -0,0 +1,8 @@
+-- Area layanan & kapasitas jemput partner (melengkapi pickup_enabled dari 008)
+ALTER TABLE partner_addresses ADD COLUMN IF NOT EXISTS service_radius_km NUMERIC(6, 2) NOT NULL DEFAULT 10;
+ALTER TABLE partner_addresses ADD COLUMN IF NOT EXISTS service_area_polygon JSONB;                      -- []geo.Point, jika diisi menggantikan radius
+ALTER TABLE partner_addresses ADD COLUMN IF NOT EXISTS daily_pickup_capacity INTEGER NOT NULL DEFAULT 0; -- 0 = tidak dibatasi
+ALTER TABLE partner_addresses ADD COLUMN IF NOT EXISTS min_pickup_weight NUMERIC(10, 2) NOT NULL DEFAULT 0;
+
+-- Hitung kapasitas harian per partner
+CREATE INDEX IF NOT EXISTS idx_pickup_requests_partner_slot ON pickup_requests(partner_id, slot_start) WHERE status <> 'Cancelled';
@@ -0,0 +1,5 @@
+-- ID deposit dari aplikasi partner (sinkronisasi offline), menjamin satu deposit per client_id per partner
+ALTER TABLE partner_deposit_histories ADD COLUMN IF NOT EXISTS client_id VARCHAR(64);
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_partner_deposit_histories_client_id
+    ON partner_deposit_histories(partner_id, client_id) WHERE client_id IS NOT NULL;
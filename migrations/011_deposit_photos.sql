@@ -0,0 +1,24 @@
+-- Foto bukti deposit (beberapa per deposit dan per item) beserta thumbnail
+CREATE TABLE IF NOT EXISTS deposit_photos (
+    id                                SERIAL PRIMARY KEY,
+    partner_deposit_history_id        INTEGER NOT NULL REFERENCES partner_deposit_histories(id) ON DELETE CASCADE,
+    partner_deposit_history_detail_id INTEGER REFERENCES partner_deposit_history_details(id) ON DELETE CASCADE, -- NULL = foto level deposit
+    deposit_dispute_id                INTEGER REFERENCES deposit_disputes(id) ON DELETE SET NULL,               -- Bukti tambahan partner saat sengketa
+    url                               TEXT NOT NULL,
+    thumbnail_url                     TEXT,
+    created_at                        TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_deposit_photos_deposit_id ON deposit_photos(partner_deposit_history_id);
+
+-- Foto lama (satu per deposit, disalin di tiap detail) dipindahkan sebagai foto level deposit
+INSERT INTO deposit_photos (partner_deposit_history_id, url, created_at)
+SELECT DISTINCT ON (pdd.partner_deposit_history_id) pdd.partner_deposit_history_id, pdd.photo, pdh.created_at
+FROM partner_deposit_history_details pdd
+JOIN partner_deposit_histories pdh ON pdh.id = pdd.partner_deposit_history_id
+WHERE pdd.photo IS NOT NULL AND pdd.photo <> ''
+  AND NOT EXISTS (SELECT 1 FROM deposit_photos dp WHERE dp.partner_deposit_history_id = pdd.partner_deposit_history_id)
+ORDER BY pdd.partner_deposit_history_id, pdd.id;
+
+-- Foto draft deposit ikut disimpan sampai draft diterima
+ALTER TABLE deposit_drafts ADD COLUMN IF NOT EXISTS photos JSONB NOT NULL DEFAULT '[]';
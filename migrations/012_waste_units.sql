@@ -0,0 +1,9 @@
+-- Deposit per satuan harga: kg (berat) atau pcs (jumlah). Berat pcs dikonversi dari rata-rata berat per buah
+ALTER TABLE waste_details ADD COLUMN IF NOT EXISTS average_unit_weight NUMERIC(10, 4); -- kg per pcs, NULL = belum diketahui
+
+ALTER TABLE partner_deposit_history_details ADD COLUMN IF NOT EXISTS unit VARCHAR(10) NOT NULL DEFAULT 'kg';
+ALTER TABLE partner_deposit_history_details ADD COLUMN IF NOT EXISTS quantity INTEGER; -- Hanya untuk unit pcs
+
+-- Seragamkan penulisan satuan harga yang sudah ada
+UPDATE partner_waste_price_details SET unit = 'kg' WHERE LOWER(TRIM(unit)) IN ('kg', 'kilo', 'kilogram');
+UPDATE partner_waste_price_details SET unit = 'pcs' WHERE LOWER(TRIM(unit)) IN ('pcs', 'pc', 'buah', 'biji', 'unit');
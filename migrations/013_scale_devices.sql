@@ -0,0 +1,33 @@
+-- Timbangan digital partner: perangkat terdaftar mengirim hasil timbang bertanda tangan (HMAC)
+CREATE TABLE IF NOT EXISTS partner_scale_devices (
+    id                   SERIAL PRIMARY KEY,
+    partner_id           INTEGER NOT NULL REFERENCES partners(id) ON DELETE CASCADE,
+    device_id            VARCHAR(64) NOT NULL UNIQUE,        -- ID/serial yang dikirim perangkat
+    name                 VARCHAR(100) NOT NULL,
+    secret               VARCHAR(64) NOT NULL,               -- Kunci HMAC-SHA256 (hex), hanya ditampilkan saat registrasi
+    certified            BOOLEAN NOT NULL DEFAULT FALSE,     -- Sudah ditera/disertifikasi (diatur admin)
+    certification_number VARCHAR(100),
+    certified_at         TIMESTAMPTZ,
+    last_reading_at      TIMESTAMPTZ,
+    revoked_at           TIMESTAMPTZ,
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at           TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_partner_scale_devices_partner_id ON partner_scale_devices(partner_id);
+
+CREATE TABLE IF NOT EXISTS scale_readings (
+    id                                SERIAL PRIMARY KEY,
+    scale_device_id                   INTEGER NOT NULL REFERENCES partner_scale_devices(id) ON DELETE CASCADE,
+    partner_id                        INTEGER NOT NULL REFERENCES partners(id) ON DELETE CASCADE,
+    device_reading_id                 VARCHAR(64) NOT NULL,   -- Nomor urut/nonce dari perangkat
+    weight                            NUMERIC(10, 3) NOT NULL, -- kg
+    measured_at                       TIMESTAMPTZ NOT NULL,
+    used_at                           TIMESTAMPTZ,             -- Terisi saat dipakai di deposit (sekali pakai)
+    partner_deposit_history_detail_id INTEGER REFERENCES partner_deposit_history_details(id) ON DELETE SET NULL,
+    created_at                        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (scale_device_id, device_reading_id)
+);
+CREATE INDEX IF NOT EXISTS idx_scale_readings_partner_unused ON scale_readings(partner_id, measured_at) WHERE used_at IS NULL;
+
+-- Item deposit yang beratnya berasal dari timbangan
+ALTER TABLE partner_deposit_history_details ADD COLUMN IF NOT EXISTS scale_reading_id INTEGER REFERENCES scale_readings(id) ON DELETE SET NULL;
@@ -0,0 +1,52 @@
+-- Aturan anti-fraud deposit yang dievaluasi saat partner membuat deposit. action: allow (nonaktif), hold, reject
+CREATE TABLE IF NOT EXISTS fraud_rules (
+    code         VARCHAR(50) PRIMARY KEY,
+    description  TEXT NOT NULL,
+    action       VARCHAR(10) NOT NULL DEFAULT 'hold' CHECK (action IN ('allow', 'hold', 'reject')),
+    threshold    NUMERIC(12, 2) NOT NULL,
+    window_hours INTEGER NOT NULL DEFAULT 24,
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO fraud_rules (code, description, action, threshold, window_hours) VALUES
+    ('daily_user_weight', 'Total berat setoran satu user per hari (kg) melebihi threshold', 'hold', 100, 24),
+    ('repeated_identical_weight', 'Berat item identik ke user yang sama tercatat >= threshold kali dalam window', 'hold', 3, 168),
+    ('same_user_frequency', 'Deposit partner ke user yang sama >= threshold kali dalam window', 'hold', 5, 24),
+    ('new_account_large_credit', 'Akun yang lebih muda dari window menerima >= threshold Xpoin', 'hold', 500, 72),
+    ('outside_schedule', 'Deposit tercatat di luar jam operasional partner', 'hold', 0, 0)
+ON CONFLICT (code) DO NOTHING;
+
+-- Kasus deposit yang memicu aturan. Deposit Held disimpan (seperti draft) dan baru dijalankan saat admin menyetujui.
+CREATE TABLE IF NOT EXISTS deposit_fraud_cases (
+    id                         SERIAL PRIMARY KEY,
+    partner_id                 INTEGER NOT NULL REFERENCES partners(id) ON DELETE CASCADE,
+    user_id                    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status                     VARCHAR(20) NOT NULL, -- Held, Blocked, Approved, Declined
+    hits                       JSONB NOT NULL DEFAULT '[]',
+    deposit_method_id          INTEGER NOT NULL,
+    items                      JSONB NOT NULL DEFAULT '[]',
+    total_weight               NUMERIC(10, 2) NOT NULL DEFAULT 0,
+    total_xpoin                INTEGER NOT NULL DEFAULT 0,
+    notes                      TEXT,
+    photo                      TEXT,
+    photos                     JSONB NOT NULL DEFAULT '[]',
+    client_id                  VARCHAR(64),
+    transaction_time           TIMESTAMPTZ NOT NULL,
+    partner_deposit_history_id INTEGER REFERENCES partner_deposit_histories(id) ON DELETE SET NULL,
+    review_note                TEXT,
+    reviewed_at                TIMESTAMPTZ,
+    created_at                 TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at                 TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_deposit_fraud_cases_status ON deposit_fraud_cases(status, created_at);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_deposit_fraud_cases_client_id ON deposit_fraud_cases(partner_id, client_id) WHERE client_id IS NOT NULL;
+
+-- Sinyal fraud: riwayat deposit per user & per pasangan partner-user
+CREATE INDEX IF NOT EXISTS idx_partner_deposit_histories_user_time ON partner_deposit_histories(user_id, transaction_time);
+
+-- Hasil timbang yang dicadangkan kasus Held (used_at terisi, detail deposit belum ada)
+ALTER TABLE scale_readings ADD COLUMN IF NOT EXISTS deposit_fraud_case_id INTEGER REFERENCES deposit_fraud_cases(id) ON DELETE SET NULL;
+
+-- Asal deposit yang ditahan (sesi, jemput, draft); asal berstatus Held selama kasus ditinjau
+ALTER TABLE deposit_fraud_cases ADD COLUMN IF NOT EXISTS source_type VARCHAR(20);
+ALTER TABLE deposit_fraud_cases ADD COLUMN IF NOT EXISTS source_id INTEGER;
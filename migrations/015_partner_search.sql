@@ -0,0 +1,5 @@
+-- Pencarian mitra terdekat: status buka dihitung dari jadwal di zona waktu partner
+ALTER TABLE partner_schedules ADD COLUMN IF NOT EXISTS timezone VARCHAR(40) NOT NULL DEFAULT 'Asia/Jakarta';
+
+-- Filter mitra yang menerima jenis sampah tertentu
+CREATE INDEX IF NOT EXISTS idx_partner_waste_price_details_waste_detail_id ON partner_waste_price_details(waste_detail_id);
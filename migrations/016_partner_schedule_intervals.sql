@@ -0,0 +1,55 @@
+-- Jadwal partner per hari: beberapa interval per hari, pengecualian bertanggal, dan libur nasional
+CREATE TABLE IF NOT EXISTS partner_schedule_intervals (
+    id         SERIAL PRIMARY KEY,
+    partner_id INTEGER NOT NULL REFERENCES partners(id) ON DELETE CASCADE,
+    weekday    SMALLINT NOT NULL CHECK (weekday BETWEEN 0 AND 6), -- 0 = Minggu ... 6 = Sabtu
+    open_time  TIME NOT NULL,
+    close_time TIME NOT NULL -- <= open_time berarti melewati tengah malam
+);
+CREATE INDEX IF NOT EXISTS idx_partner_schedule_intervals_partner_id ON partner_schedule_intervals(partner_id);
+
+CREATE TABLE IF NOT EXISTS partner_schedule_exceptions (
+    id         SERIAL PRIMARY KEY,
+    partner_id INTEGER NOT NULL REFERENCES partners(id) ON DELETE CASCADE,
+    date       DATE NOT NULL,
+    closed     BOOLEAN NOT NULL DEFAULT TRUE, -- FALSE = jam khusus menggantikan jadwal mingguan
+    open_time  TIME,
+    close_time TIME,
+    reason     VARCHAR(255),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_partner_schedule_exceptions_partner_date ON partner_schedule_exceptions(partner_id, date);
+
+CREATE TABLE IF NOT EXISTS national_holidays (
+    date       DATE PRIMARY KEY,
+    name       VARCHAR(255) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE partner_schedules ADD COLUMN IF NOT EXISTS observe_national_holidays BOOLEAN NOT NULL DEFAULT TRUE;
+
+-- Pindahkan jadwal lama (days_open + satu jam buka/tutup) menjadi interval per hari. Jadwal lama tanpa jam
+-- buka/tutup tidak dipindahkan (bukan dianggap buka 24 jam); partner perlu mengisi ulang jadwalnya.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name = 'partner_schedules' AND column_name = 'days_open') THEN
+        INSERT INTO partner_schedule_intervals (partner_id, weekday, open_time, close_time)
+        SELECT ps.partner_id,
+               CASE TRIM(d.day)
+                   WHEN 'Minggu' THEN 0 WHEN 'Senin' THEN 1 WHEN 'Selasa' THEN 2 WHEN 'Rabu' THEN 3
+                   WHEN 'Kamis' THEN 4 WHEN 'Jumat' THEN 5 WHEN 'Sabtu' THEN 6
+               END,
+               ps.open_time, ps.close_time
+        FROM partner_schedules ps
+        CROSS JOIN LATERAL unnest(string_to_array(ps.days_open, ',')) AS d(day)
+        WHERE ps.days_open IS NOT NULL AND ps.days_open <> ''
+          AND ps.open_time IS NOT NULL AND ps.close_time IS NOT NULL
+          AND TRIM(d.day) IN ('Minggu', 'Senin', 'Selasa', 'Rabu', 'Kamis', 'Jumat', 'Sabtu')
+          AND NOT EXISTS (SELECT 1 FROM partner_schedule_intervals psi WHERE psi.partner_id = ps.partner_id);
+    END IF;
+END $$;
+
+ALTER TABLE partner_schedules DROP COLUMN IF EXISTS days_open;
+ALTER TABLE partner_schedules DROP COLUMN IF EXISTS open_time;
+ALTER TABLE partner_schedules DROP COLUMN IF EXISTS close_time;
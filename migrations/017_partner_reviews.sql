@@ -0,0 +1,30 @@
+-- Ulasan partner oleh user setelah deposit: rating 1-5, komentar & foto opsional, balasan publik partner, moderasi admin
+CREATE TABLE IF NOT EXISTS partner_reviews (
+    id                         SERIAL PRIMARY KEY,
+    user_deposit_history_id    INTEGER NOT NULL UNIQUE REFERENCES user_deposit_histories(id) ON DELETE CASCADE, -- Satu ulasan per deposit
+    partner_deposit_history_id INTEGER NOT NULL REFERENCES partner_deposit_histories(id) ON DELETE CASCADE,
+    user_id                    INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    partner_id                 INTEGER NOT NULL REFERENCES partners(id) ON DELETE CASCADE,
+    rating                     SMALLINT NOT NULL CHECK (rating BETWEEN 1 AND 5),
+    comment                    TEXT,
+    photo                      VARCHAR(255),
+    status                     VARCHAR(20) NOT NULL DEFAULT 'Published', -- Published, Hidden (disembunyikan admin)
+    partner_reply              TEXT,
+    partner_replied_at         TIMESTAMPTZ,
+    moderation_note            TEXT,
+    moderated_at               TIMESTAMPTZ,
+    created_at                 TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at                 TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_partner_reviews_partner_status ON partner_reviews(partner_id, status, created_at DESC);
+CREATE INDEX IF NOT EXISTS idx_partner_reviews_user_id ON partner_reviews(user_id);
+
+-- Database yang sudah menjalankan migrasi ini sebelum foreign key ditambahkan
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM pg_constraint WHERE conname = 'partner_reviews_user_deposit_history_id_fkey') THEN
+        ALTER TABLE partner_reviews ADD CONSTRAINT partner_reviews_user_deposit_history_id_fkey
+            FOREIGN KEY (user_deposit_history_id) REFERENCES user_deposit_histories(id) ON DELETE CASCADE;
+    END IF;
+END $$;
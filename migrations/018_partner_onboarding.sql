@@ -0,0 +1,40 @@
+-- Pengajuan onboarding (KYC) partner: data usaha & pemilik, rekening pencairan, dokumen, dan riwayat status
+CREATE TABLE IF NOT EXISTS partner_onboarding_applications (
+    id                  SERIAL PRIMARY KEY,
+    partner_id          INTEGER NOT NULL UNIQUE REFERENCES partners(id) ON DELETE CASCADE,
+    status              VARCHAR(20) NOT NULL DEFAULT 'Draft', -- Draft, Submitted, Needs Info, Approved, Rejected
+    owner_name          VARCHAR(255),
+    owner_nik           VARCHAR(16),  -- NIK KTP pemilik
+    nib                 VARCHAR(13),  -- Nomor Induk Berusaha (OSS)
+    bank_name           VARCHAR(100),
+    bank_account_number VARCHAR(30),
+    bank_account_holder VARCHAR(255),
+    review_note         TEXT,         -- Alasan penolakan / info yang diminta admin
+    submitted_at        TIMESTAMPTZ,
+    reviewed_at         TIMESTAMPTZ,
+    created_at          TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at          TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_partner_onboarding_status ON partner_onboarding_applications(status, submitted_at);
+
+CREATE TABLE IF NOT EXISTS partner_onboarding_documents (
+    id             SERIAL PRIMARY KEY,
+    application_id INTEGER NOT NULL REFERENCES partner_onboarding_applications(id) ON DELETE CASCADE,
+    type           VARCHAR(20) NOT NULL, -- nib, ktp, site_photo
+    url            VARCHAR(255) NOT NULL,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_partner_onboarding_documents_application ON partner_onboarding_documents(application_id);
+
+-- Riwayat perubahan status pengajuan onboarding & status xetor_partners
+CREATE TABLE IF NOT EXISTS partner_status_histories (
+    id             SERIAL PRIMARY KEY,
+    partner_id     INTEGER NOT NULL REFERENCES partners(id) ON DELETE CASCADE,
+    application_id INTEGER REFERENCES partner_onboarding_applications(id) ON DELETE SET NULL,
+    from_status    VARCHAR(20),
+    to_status      VARCHAR(20) NOT NULL,
+    actor_type     VARCHAR(10) NOT NULL, -- 'partner' atau 'admin'
+    note           TEXT,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_partner_status_histories_partner ON partner_status_histories(partner_id, created_at);
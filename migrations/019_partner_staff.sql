@@ -0,0 +1,27 @@
+-- Akun staf di bawah satu partner dengan role: owner (akses penuh), cashier (deposit & verifikasi QR),
+-- finance (withdraw, topup, konversi, transfer). Owner login memakai email & password di tabel partners.
+CREATE TABLE IF NOT EXISTS partner_staff (
+    id            SERIAL PRIMARY KEY,
+    partner_id    INTEGER NOT NULL REFERENCES partners(id) ON DELETE CASCADE,
+    name          VARCHAR(255) NOT NULL,
+    email         VARCHAR(255) UNIQUE,  -- NULL untuk owner
+    password      VARCHAR(255),         -- bcrypt, NULL untuk owner
+    role          VARCHAR(20) NOT NULL, -- owner, cashier, finance
+    is_active     BOOLEAN NOT NULL DEFAULT TRUE,
+    last_login_at TIMESTAMPTZ,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_partner_staff_partner ON partner_staff(partner_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_partner_staff_owner ON partner_staff(partner_id) WHERE role = 'owner';
+
+-- Setiap partner yang sudah ada mendapat satu baris owner
+INSERT INTO partner_staff (partner_id, name, role)
+SELECT id, business_name, 'owner' FROM partners
+ON CONFLICT DO NOTHING;
+
+-- Staf yang mencatat deposit & transfer (NULL untuk data sebelum fitur staf)
+ALTER TABLE partner_deposit_histories ADD COLUMN IF NOT EXISTS staff_id INTEGER REFERENCES partner_staff(id) ON DELETE SET NULL;
+ALTER TABLE partner_transfer_histories ADD COLUMN IF NOT EXISTS staff_id INTEGER REFERENCES partner_staff(id) ON DELETE SET NULL;
+ALTER TABLE deposit_drafts ADD COLUMN IF NOT EXISTS staff_id INTEGER REFERENCES partner_staff(id) ON DELETE SET NULL;
+ALTER TABLE deposit_fraud_cases ADD COLUMN IF NOT EXISTS staff_id INTEGER REFERENCES partner_staff(id) ON DELETE SET NULL;
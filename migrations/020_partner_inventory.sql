@@ -0,0 +1,38 @@
+-- Stok fisik sampah yang dipegang partner per waste_detail_id, beserta mutasinya.
+-- Berat selalu dalam kg (item pcs memakai berat hasil konversi), quantity hanya untuk item pcs.
+CREATE TABLE IF NOT EXISTS partner_inventories (
+    partner_id      INTEGER NOT NULL REFERENCES partners(id) ON DELETE CASCADE,
+    waste_detail_id INTEGER NOT NULL REFERENCES waste_details(id) ON DELETE CASCADE,
+    weight          NUMERIC(12, 2) NOT NULL DEFAULT 0,
+    quantity        INTEGER NOT NULL DEFAULT 0,
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (partner_id, waste_detail_id)
+);
+
+CREATE TABLE IF NOT EXISTS partner_inventory_movements (
+    id                         SERIAL PRIMARY KEY,
+    partner_id                 INTEGER NOT NULL REFERENCES partners(id) ON DELETE CASCADE,
+    waste_detail_id            INTEGER NOT NULL REFERENCES waste_details(id) ON DELETE CASCADE,
+    type                       VARCHAR(20) NOT NULL, -- deposit, deposit_void, deposit_correction, sale, disposal, adjustment
+    weight_change              NUMERIC(12, 2) NOT NULL,
+    quantity_change            INTEGER NOT NULL DEFAULT 0,
+    partner_deposit_history_id INTEGER REFERENCES partner_deposit_histories(id) ON DELETE SET NULL,
+    reason                     TEXT,
+    staff_id                   INTEGER REFERENCES partner_staff(id) ON DELETE SET NULL,
+    created_at                 TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_partner_inventory_movements_partner ON partner_inventory_movements(partner_id, created_at DESC);
+
+-- Saldo awal dari riwayat deposit yang belum dibatalkan
+INSERT INTO partner_inventories (partner_id, waste_detail_id, weight, quantity)
+SELECT pdh.partner_id, pdd.waste_detail_id, SUM(pdd.waste_weight), COALESCE(SUM(pdd.quantity), 0)
+FROM partner_deposit_history_details pdd
+JOIN partner_deposit_histories pdh ON pdh.id = pdd.partner_deposit_history_id
+WHERE pdd.waste_detail_id IS NOT NULL AND pdd.status <> 'Voided' AND pdh.voided_at IS NULL
+GROUP BY pdh.partner_id, pdd.waste_detail_id
+ON CONFLICT DO NOTHING;
+
+INSERT INTO partner_inventory_movements (partner_id, waste_detail_id, type, weight_change, quantity_change, reason)
+SELECT partner_id, waste_detail_id, 'adjustment', weight, quantity, 'Saldo awal dari riwayat deposit'
+FROM partner_inventories
+WHERE NOT EXISTS (SELECT 1 FROM partner_inventory_movements);
@@ -0,0 +1,43 @@
+-- Recycler (offtaker) pembeli sampah terpilah dari partner, dikelola admin
+CREATE TABLE IF NOT EXISTS recyclers (
+    id             SERIAL PRIMARY KEY,
+    name           VARCHAR(255) NOT NULL,
+    contact_name   VARCHAR(255),
+    phone          VARCHAR(50),
+    email          VARCHAR(255),
+    address        TEXT,
+    city           VARCHAR(100),
+    status         VARCHAR(20) NOT NULL DEFAULT 'Active', -- Active, Inactive
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Penjualan sampah partner ke recycler; total_amount menjadi pendapatan partner
+CREATE TABLE IF NOT EXISTS partner_offtake_sales (
+    id             SERIAL PRIMARY KEY,
+    partner_id     INTEGER NOT NULL REFERENCES partners(id) ON DELETE CASCADE,
+    recycler_id    INTEGER NOT NULL REFERENCES recyclers(id) ON DELETE RESTRICT,
+    sale_date      DATE NOT NULL DEFAULT CURRENT_DATE,
+    invoice_number VARCHAR(100),
+    invoice_photo  TEXT,
+    total_weight   NUMERIC(12, 2) NOT NULL,
+    total_amount   NUMERIC(14, 2) NOT NULL,
+    notes          TEXT,
+    staff_id       INTEGER REFERENCES partner_staff(id) ON DELETE SET NULL,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_partner_offtake_sales_partner ON partner_offtake_sales(partner_id, sale_date DESC);
+
+CREATE TABLE IF NOT EXISTS partner_offtake_sale_details (
+    id                     SERIAL PRIMARY KEY,
+    partner_offtake_sale_id INTEGER NOT NULL REFERENCES partner_offtake_sales(id) ON DELETE CASCADE,
+    waste_detail_id        INTEGER NOT NULL REFERENCES waste_details(id),
+    weight                 NUMERIC(12, 2) NOT NULL,
+    quantity               INTEGER NOT NULL DEFAULT 0,
+    price_per_kg           NUMERIC(12, 2) NOT NULL,
+    subtotal               NUMERIC(14, 2) NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_partner_offtake_sale_details_sale ON partner_offtake_sale_details(partner_offtake_sale_id);
+
+-- Mutasi stok keluar karena penjualan merujuk ke transaksi penjualannya
+ALTER TABLE partner_inventory_movements ADD COLUMN IF NOT EXISTS partner_offtake_sale_id INTEGER REFERENCES partner_offtake_sales(id) ON DELETE SET NULL;
@@ -0,0 +1,40 @@
+-- Riwayat versi harga sampah partner. Versi Applied berlaku pada [effective_from, effective_until),
+-- effective_until NULL = versi yang sedang berlaku. Versi Scheduled diterapkan otomatis saat effective_from tiba.
+CREATE TABLE IF NOT EXISTS partner_waste_price_versions (
+    id                            SERIAL PRIMARY KEY,
+    partner_waste_price_detail_id INTEGER REFERENCES partner_waste_price_details(id) ON DELETE SET NULL,
+    partner_id                    INTEGER NOT NULL REFERENCES partners(id) ON DELETE CASCADE,
+    waste_detail_id               INTEGER REFERENCES waste_details(id) ON DELETE SET NULL,
+    name                          VARCHAR(255) NOT NULL,
+    price                         NUMERIC(12, 2) NOT NULL,
+    unit                          VARCHAR(10) NOT NULL,
+    xpoin                         INTEGER NOT NULL,
+    status                        VARCHAR(20) NOT NULL DEFAULT 'Applied', -- Scheduled, Applied, Cancelled
+    source                        VARCHAR(20) NOT NULL,                   -- initial, manual, import, schedule
+    effective_from                TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    effective_until               TIMESTAMPTZ,
+    staff_id                      INTEGER REFERENCES partner_staff(id) ON DELETE SET NULL,
+    created_at                    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_waste_price_versions_detail ON partner_waste_price_versions(partner_waste_price_detail_id, effective_from DESC);
+CREATE INDEX IF NOT EXISTS idx_waste_price_versions_trend ON partner_waste_price_versions(waste_detail_id, effective_from) WHERE status = 'Applied';
+CREATE INDEX IF NOT EXISTS idx_waste_price_versions_due ON partner_waste_price_versions(effective_from) WHERE status = 'Scheduled';
+-- Hanya satu versi berlaku dan satu jadwal per waktu efektif untuk setiap item harga
+CREATE UNIQUE INDEX IF NOT EXISTS idx_waste_price_versions_current ON partner_waste_price_versions(partner_waste_price_detail_id)
+    WHERE status = 'Applied' AND effective_until IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_waste_price_versions_schedule ON partner_waste_price_versions(partner_waste_price_detail_id, effective_from)
+    WHERE status = 'Scheduled';
+
+-- Harga yang sudah ada menjadi versi pertama
+INSERT INTO partner_waste_price_versions
+    (partner_waste_price_detail_id, partner_id, waste_detail_id, name, price, unit, xpoin, source, effective_from)
+SELECT pwpd.id, pwp.partner_id, pwpd.waste_detail_id, pwpd.name, pwpd.price, pwpd.unit, pwpd.xpoin, 'initial', pwpd.updated_at
+FROM partner_waste_price_details pwpd
+JOIN partner_waste_prices pwp ON pwp.id = pwpd.partner_waste_price_id
+WHERE NOT EXISTS (
+    SELECT 1 FROM partner_waste_price_versions v WHERE v.partner_waste_price_detail_id = pwpd.id
+);
+
+-- Versi harga yang dipakai saat menghitung Xpoin tiap item deposit
+ALTER TABLE partner_deposit_history_details
+    ADD COLUMN IF NOT EXISTS partner_waste_price_version_id INTEGER REFERENCES partner_waste_price_versions(id) ON DELETE SET NULL;